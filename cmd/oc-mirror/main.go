@@ -2,31 +2,40 @@ package main
 
 import (
 	"flag"
-	"io/ioutil"
+	"os"
 
 	"github.com/sirupsen/logrus"
 	klogv1 "k8s.io/klog"
 	klogv2 "k8s.io/klog/v2"
 
+	"github.com/openshift/oc-mirror/pkg/cli"
 	"github.com/openshift/oc-mirror/pkg/cli/mirror"
+	"github.com/openshift/oc-mirror/pkg/exitcode"
 )
 
 func main() {
-	// This attempts to configure klog (used by vendored Kubernetes code) not
-	// to log anything.
+	// This configures klog (used by vendored Kubernetes code) to log
+	// as debug entries, which are redacted and written to the log file,
+	// instead of writing to stderr.
 	// Handle k8s.io/klog
 	var fsv1 flag.FlagSet
 	klogv1.InitFlags(&fsv1)
+	checkErr(fsv1.Set("logtostderr", "false"))
 	checkErr(fsv1.Set("stderrthreshold", "4"))
-	klogv1.SetOutput(ioutil.Discard)
+	klogv1.SetOutput(cli.NewKlogWriter())
 	// Handle k8s.io/klog/v2
 	var fsv2 flag.FlagSet
 	klogv2.InitFlags(&fsv2)
+	checkErr(fsv2.Set("logtostderr", "false"))
 	checkErr(fsv2.Set("stderrthreshold", "4"))
-	klogv2.SetOutput(ioutil.Discard)
+	klogv2.SetOutput(cli.NewKlogWriter())
 
 	rootCmd := mirror.NewMirrorCmd()
-	checkErr(rootCmd.Execute())
+	// Commands only return errors for invalid flags and arguments
+	if err := rootCmd.Execute(); err != nil {
+		logrus.Error(err)
+		os.Exit(exitcode.ConfigError)
+	}
 }
 
 func checkErr(err error) {
@@ -22,6 +22,129 @@ type ImageSetConfigurationSpec struct {
 	ArchiveSize int64 `json:"archiveSize,omitempty"`
 	// StorageConfig for reading/writing metadata and files.
 	StorageConfig StorageConfig `json:"storageConfig"`
+	// Registries defines the connection configuration of
+	// individual registries.
+	Registries []Registry `json:"registries,omitempty"`
+	// Hooks are commands run before and after
+	// the phases of the runs.
+	Hooks []Hook `json:"hooks,omitempty"`
+}
+
+// HookPhase is a phase of a run that hooks run around.
+type HookPhase string
+
+const (
+	// HookPhasePlan is the planning of the images of the imageset.
+	HookPhasePlan HookPhase = "plan"
+	// HookPhaseArchive is the packing of the imageset into archives.
+	HookPhaseArchive HookPhase = "archive"
+	// HookPhasePublish is the push of the images to the
+	// destination registry, from archives or from their sources.
+	HookPhasePublish HookPhase = "publish"
+)
+
+// HookWhen is when a hook runs relative to its phase.
+type HookWhen string
+
+const (
+	// HookBefore hooks run before the phase.
+	HookBefore HookWhen = "before"
+	// HookAfter hooks run after the phase,
+	// including when the phase failed.
+	HookAfter HookWhen = "after"
+)
+
+// Hook is a command run before or after a phase of a run,
+// e.g. to mount transfer media or notify a change management
+// system. The command gets the context of the run in
+// OC_MIRROR_* environment variables.
+type Hook struct {
+	// Name identifies the hook in logs. Defaults to the command.
+	Name string `json:"name,omitempty"`
+	// Phase is the phase the hook runs around.
+	Phase HookPhase `json:"phase"`
+	// When is whether the hook runs before or after the phase.
+	When HookWhen `json:"when"`
+	// Command is the program and its arguments.
+	// It is not run in a shell.
+	Command []string `json:"command"`
+	// Env are additional environment variables of the command.
+	Env map[string]string `json:"env,omitempty"`
+	// TimeoutSeconds is how long the command may run.
+	// 0 means no timeout.
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+	// ContinueOnError logs the failure of the command
+	// instead of failing the run.
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+}
+
+// Registry defines the TLS and proxy configuration
+// used to connect to a registry.
+type Registry struct {
+	// Host is the registry hostname, with an optional port.
+	// Without a port, the configuration applies to all ports.
+	Host string `json:"host"`
+	// ClientCert is the path to a PEM encoded client certificate
+	// presented to registries requiring mutual TLS.
+	ClientCert string `json:"clientCert,omitempty"`
+	// ClientKey is the path to the PEM encoded private key
+	// of the client certificate.
+	ClientKey string `json:"clientKey,omitempty"`
+	// CAFile is the path to a PEM encoded CA bundle trusted
+	// for the registry in addition to the system trust store.
+	CAFile string `json:"caFile,omitempty"`
+	// Proxy is the URL of the proxy used to connect to the
+	// registry instead of the proxy of the environment.
+	Proxy string `json:"proxy,omitempty"`
+	// CredentialHelper is the name or path of a program implementing
+	// the docker credential helper protocol that returns the credentials
+	// of the registry, instead of the registry credentials file.
+	CredentialHelper string `json:"credentialHelper,omitempty"`
+	// Vault reads the credentials of the registry from a
+	// HashiCorp Vault secret instead of the registry credentials file.
+	Vault *VaultCredentials `json:"vault,omitempty"`
+	// Insecure allows the options skipping TLS verification or using
+	// plain HTTP to apply to the registry. The options only apply
+	// to the registries setting it or listed with --insecure-registry.
+	Insecure bool `json:"insecure,omitempty"`
+	// PinnedPublicKeys are the SHA-256 hashes of the public keys
+	// (SubjectPublicKeyInfo) trusted for the host, base64 encoded
+	// with a sha256// prefix. Connections fail unless a certificate
+	// presented by the host has one of them. They also apply to the
+	// Cincinnati host.
+	PinnedPublicKeys []string `json:"pinnedPublicKeys,omitempty"`
+	// Addresses are the IPv4 or IPv6 addresses connections to the
+	// host go to instead of the addresses it resolves to, like
+	// entries of /etc/hosts. They are tried in order. TLS certificates
+	// are still verified against the host. They also apply to the
+	// Cincinnati host.
+	Addresses []string `json:"addresses,omitempty"`
+	// PathPrefix is the path the registry API of the host is served
+	// under, for registries mounted under a path such as Artifactory
+	// Docker repositories (e.g. /artifactory/api/docker/docker-local).
+	// Requests to the /v2/ API of the host are sent under the prefix.
+	// Image references of the registry do not include it, and it is
+	// removed from the namespace of destinations including it.
+	PathPrefix string `json:"pathPrefix,omitempty"`
+}
+
+// VaultCredentials defines the HashiCorp Vault key/value
+// secret holding the credentials of a registry
+type VaultCredentials struct {
+	// Address is the URL of the Vault server.
+	// Defaults to the VAULT_ADDR environment variable.
+	Address string `json:"address,omitempty"`
+	// Path is the API path of the secret, which includes
+	// data/ for version 2 of the key/value secrets engine
+	// (e.g. secret/data/registries/quay).
+	Path string `json:"path"`
+	// UsernameKey is the key of the username in the secret.
+	// Defaults to username. Secrets without a username
+	// hold an identity token.
+	UsernameKey string `json:"usernameKey,omitempty"`
+	// PasswordKey is the key of the password or token
+	// in the secret. Defaults to password.
+	PasswordKey string `json:"passwordKey,omitempty"`
 }
 
 // Mirror defines the configuration for content types within the imageset.
@@ -42,6 +165,45 @@ type Mirror struct {
 	// Samples defines the configuration for Sample content types.
 	// This is currently not implemented.
 	Samples []SampleImages `json:"samples,omitempty"`
+	// Verification defines how the cosign signatures of images
+	// are verified before the images are added to the imageset.
+	Verification ImageVerification `json:"verification,omitempty"`
+}
+
+// ImageVerification defines the configuration for
+// cosign signature verification of images
+type ImageVerification struct {
+	// Policy is the verification failure policy (warn, enforce).
+	Policy VerificationPolicy `json:"policy,omitempty"`
+	// Images defines the signers trusted for the images of
+	// matching repositories. The first matching entry applies,
+	// and images of repositories matching no entry are not verified.
+	Images []ImageSigners `json:"images,omitempty"`
+}
+
+// ImageSigners defines the signers trusted for the images
+// of the repositories matching a pattern
+type ImageSigners struct {
+	// Pattern is matched against image repositories
+	// (e.g. registry.example.com/team/*). A pattern ending
+	// with /** matches all the repositories under its prefix.
+	Pattern string `json:"pattern"`
+	// PublicKeys are paths to PEM encoded cosign public keys.
+	PublicKeys []string `json:"publicKeys,omitempty"`
+	// Identities are the keyless signer identities
+	// of certificates issued by CAFile.
+	Identities []SignerIdentity `json:"identities,omitempty"`
+	// CAFile is the path to the PEM encoded Fulcio root and
+	// intermediate certificates issuing keyless certificates.
+	CAFile string `json:"caFile,omitempty"`
+}
+
+// SignerIdentity defines a keyless signer identity
+type SignerIdentity struct {
+	// Issuer is the OIDC issuer of the signer identity.
+	Issuer string `json:"issuer"`
+	// Subject is the email or URI of the signer identity.
+	Subject string `json:"subject"`
 }
 
 // Platform defines the configuration for OpenShift and OKD platform types.
@@ -49,9 +211,101 @@ type Platform struct {
 	// Graph defines whether Cincinnati graph data will
 	// downloaded and publish
 	Graph bool `json:"graph,omitempty"`
+	// GraphDataSource is a local path or URL of the Cincinnati
+	// graph data archive used to build the graph image. It
+	// defaults to the cincinnati-graph-data archive on GitHub.
+	GraphDataSource string `json:"graphDataSource,omitempty"`
+	// GraphBaseImage is the base image for the Cincinnati
+	// graph image. Pin it by digest for reproducible builds.
+	GraphBaseImage string `json:"graphBaseImage,omitempty"`
 	// Channels defines the configuration for individual
 	// OCP and OKD channels
 	Channels []ReleaseChannel `json:"channels,omitempty"`
+	// Releases defines release payload pullspecs to mirror
+	// directly. These payloads do not need to be present in
+	// a Cincinnati channel (e.g. nightly, CI, or hotfix builds).
+	Releases []Image `json:"releases,omitempty"`
+	// UpgradePaths defines upgrade subgraphs spanning multiple
+	// minor versions. The intermediate versions required to upgrade
+	// are computed from the upgrade graph across channels.
+	UpgradePaths []UpgradePath `json:"upgradePaths,omitempty"`
+	// BootImages defines the RHCOS boot artifacts to download
+	// for the mirrored release channels.
+	BootImages BootImages `json:"bootImages,omitempty"`
+	// Clients defines the OpenShift client and installer
+	// binaries to download for the mirrored releases.
+	Clients Clients `json:"clients,omitempty"`
+	// Verification defines how release payload signatures
+	// are verified before the payloads are added to the imageset.
+	Verification ReleaseVerification `json:"verification,omitempty"`
+	// CoreOS defines the layered RHCOS images to mirror.
+	CoreOS CoreOS `json:"coreOS,omitempty"`
+}
+
+// CoreOS defines the configuration for RHCOS layering content.
+// The OS and extension images shipped in the release payload
+// are mirrored with the payload.
+type CoreOS struct {
+	// OSImages are layered RHCOS images (osImageURL overrides) to mirror.
+	OSImages []Image `json:"osImages,omitempty"`
+	// MachineConfigs are paths to MachineConfig manifests
+	// whose osImageURL overrides will be mirrored.
+	MachineConfigs []string `json:"machineConfigs,omitempty"`
+}
+
+// VerificationPolicy defines how release signature
+// verification failures are handled
+type VerificationPolicy string
+
+const (
+	// VerificationPolicyWarn logs verification failures. This is the default.
+	VerificationPolicyWarn VerificationPolicy = "warn"
+	// VerificationPolicyEnforce fails the mirror on verification failures.
+	VerificationPolicyEnforce VerificationPolicy = "enforce"
+)
+
+// ReleaseVerification defines the configuration for
+// release payload signature verification
+type ReleaseVerification struct {
+	// Policy is the verification failure policy (warn, enforce).
+	Policy VerificationPolicy `json:"policy,omitempty"`
+	// PublicKeys are paths to ASCII armored GPG public keys
+	// that replace the Red Hat release keys.
+	PublicKeys []string `json:"publicKeys,omitempty"`
+}
+
+// UpgradePath defines the configuration for mirroring
+// the OCP upgrade subgraph between two versions
+type UpgradePath struct {
+	// From is the starting version (e.g. 4.10 or 4.10.20).
+	// A minor version starts at the first release of the minor.
+	From string `json:"from"`
+	// To is the target version (e.g. 4.12 or 4.12.3).
+	// A minor version ends at the latest release of the minor.
+	To string `json:"to"`
+	// ChannelGroup is the channel prefix used for each
+	// minor version (e.g. stable, fast, eus). Defaults to stable.
+	ChannelGroup string `json:"channelGroup,omitempty"`
+	// Architectures defines the release payload architectures
+	// to mirror for this path. If unset, the architectures
+	// provided on the command line are used.
+	Architectures []string `json:"architectures,omitempty"`
+}
+
+// BootImages defines the configuration for downloading
+// RHCOS boot artifacts
+type BootImages struct {
+	// Platforms are the CoreOS stream platforms to download
+	// artifacts for (e.g. qemu, metal, vmware).
+	Platforms []string `json:"platforms,omitempty"`
+}
+
+// Clients defines the configuration for downloading
+// OpenShift client and installer binaries
+type Clients struct {
+	// OperatingSystems are the operating systems to download
+	// binaries for (linux, mac, windows).
+	OperatingSystems []string `json:"operatingSystems,omitempty"`
 }
 
 // ReleaseChannel defines the configuration for individual
@@ -74,6 +328,10 @@ type ReleaseChannel struct {
 	// first release in the channel and the MaxVersion
 	// to the last release in the channel.
 	Full bool `json:"full,omitempty"`
+	// Architectures defines the release payload architectures
+	// to mirror for this channel (e.g. amd64, arm64, ppc64le, s390x, multi).
+	// If unset, the architectures provided on the command line are used.
+	Architectures []string `json:"architectures,omitempty"`
 }
 
 // IsHeadsOnly determine if the mode set mirrors only channel head.
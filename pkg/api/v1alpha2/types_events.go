@@ -0,0 +1,54 @@
+package v1alpha2
+
+import (
+	"time"
+)
+
+// EventType is the type of an oc-mirror run event.
+type EventType string
+
+const (
+	// EventRunStart is emitted when a run starts.
+	EventRunStart EventType = "runStart"
+	// EventRunEnd is emitted when a run ends.
+	EventRunEnd EventType = "runEnd"
+	// EventPhaseStart is emitted when a phase of the run starts.
+	EventPhaseStart EventType = "phaseStart"
+	// EventPhaseEnd is emitted when a phase of the run ends.
+	EventPhaseEnd EventType = "phaseEnd"
+	// EventImagePushed is emitted when an image manifest is written to its destination.
+	EventImagePushed EventType = "imagePushed"
+	// EventBlobFetched is emitted when a blob is copied from its source.
+	EventBlobFetched EventType = "blobFetched"
+	// EventBlobMounted is emitted when a blob is mounted from another repository of the destination.
+	EventBlobMounted EventType = "blobMounted"
+	// EventImageSkipped is emitted when an image is not mirrored.
+	EventImageSkipped EventType = "imageSkipped"
+	// EventError is emitted for errors, including errors that do not stop the run.
+	EventError EventType = "error"
+)
+
+// Event is a structured event emitted during an oc-mirror run.
+// Events are written as newline delimited JSON.
+type Event struct {
+	// Time is when the event occurred.
+	Time time.Time `json:"time"`
+	// Type is the type of the event.
+	Type EventType `json:"type"`
+	// Operation is the workflow of the run, set on run events.
+	Operation Operation `json:"operation,omitempty"`
+	// Phase is the phase of the run the event relates to.
+	Phase string `json:"phase,omitempty"`
+	// Image is the image reference the event relates to.
+	Image string `json:"image,omitempty"`
+	// Digest is the manifest or blob digest the event relates to.
+	Digest string `json:"digest,omitempty"`
+	// Size is the blob size in bytes.
+	Size int64 `json:"size,omitempty"`
+	// DurationSeconds is the duration of the phase or run, set on end events.
+	DurationSeconds float64 `json:"durationSeconds,omitempty"`
+	// Success is true if the phase or run completed without errors, set on end events.
+	Success *bool `json:"success,omitempty"`
+	// Error is the error message.
+	Error string `json:"error,omitempty"`
+}
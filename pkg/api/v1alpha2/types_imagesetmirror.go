@@ -0,0 +1,86 @@
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ImageSetMirrorKind defines the kind for the ImageSetMirror
+// custom resource reconciled by `oc-mirror controller`.
+const ImageSetMirrorKind = "ImageSetMirror"
+
+// ImageSetMirror mirrors an imageset to a registry with jobs
+// run on the cluster of the resource.
+type ImageSetMirror struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ImageSetMirrorSpec   `json:"spec"`
+	Status ImageSetMirrorStatus `json:"status,omitempty"`
+}
+
+// ImageSetMirrorSpec defines what to mirror, where, and when.
+type ImageSetMirrorSpec struct {
+	// ImageSet is the imageset configuration to mirror.
+	// Its registry storage config is required so the metadata
+	// of the runs persists between jobs.
+	ImageSet ImageSetConfigurationSpec `json:"imageSet"`
+	// Destination is the registry and namespace to mirror to,
+	// e.g. registry.example.com:5000/mirror.
+	Destination string `json:"destination"`
+	// Schedule is the cron schedule of the runs, in UTC.
+	// Without a schedule, the imageset is mirrored when the
+	// spec changes.
+	Schedule string `json:"schedule,omitempty"`
+	// Suspend stops the controller from starting runs.
+	// A run in progress completes.
+	Suspend bool `json:"suspend,omitempty"`
+	// Image is the oc-mirror image of the jobs. Defaults
+	// to the --job-image of the controller.
+	Image string `json:"image,omitempty"`
+	// PullSecret is the name of a secret of type
+	// kubernetes.io/dockerconfigjson in the namespace of the
+	// resource with the credentials of the registries.
+	PullSecret string `json:"pullSecret,omitempty"`
+	// ServiceAccountName is the service account of the jobs.
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	// Args are additional flags of the runs.
+	Args []string `json:"args,omitempty"`
+}
+
+// Conditions of an ImageSetMirror.
+const (
+	// ImageSetMirrorRunning is true while a run is in progress.
+	ImageSetMirrorRunning = "Running"
+	// ImageSetMirrorMirrored is true when the last run succeeded.
+	ImageSetMirrorMirrored = "Mirrored"
+)
+
+// ImageSetMirrorStatus is the observed state of an ImageSetMirror.
+type ImageSetMirrorStatus struct {
+	// ObservedGeneration is the generation of the spec
+	// last reconciled.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions are the Running and Mirrored conditions.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// LastRun is the latest run.
+	LastRun *ImageSetMirrorRun `json:"lastRun,omitempty"`
+	// LastSuccessfulTime is when the last successful
+	// run completed.
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+	// NextRunTime is when the next scheduled run starts.
+	NextRunTime *metav1.Time `json:"nextRunTime,omitempty"`
+}
+
+// ImageSetMirrorRun is a run of an ImageSetMirror.
+type ImageSetMirrorRun struct {
+	// JobName is the name of the job of the run.
+	JobName string `json:"jobName"`
+	// Generation is the generation of the spec mirrored.
+	Generation int64 `json:"generation"`
+	// StartTime is when the run started.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is when the run finished.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// ExitCode is the exit code of oc-mirror, set when the run failed.
+	ExitCode *int `json:"exitCode,omitempty"`
+}
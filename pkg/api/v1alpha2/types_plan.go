@@ -0,0 +1,25 @@
+package v1alpha2
+
+// ImagePlan is the list of images an imageset configuration
+// resolves to, as printed by `oc-mirror plan`.
+type ImagePlan struct {
+	// Images are the images, sorted by category and source.
+	Images []PlannedImage `json:"images"`
+	// Size is the total size of the unique blobs of the images.
+	Size int64 `json:"size"`
+}
+
+// PlannedImage is an image of an ImagePlan.
+type PlannedImage struct {
+	// Source is the image reference in the source registry.
+	Source string `json:"source"`
+	// Digest is the digest of the image, unset if it could not be resolved.
+	Digest string `json:"digest,omitempty"`
+	// Category is the type of the image, e.g. ocpRelease or operatorBundle.
+	Category string `json:"category"`
+	// Layers is the number of layers of the image.
+	Layers int `json:"layers"`
+	// Size is the size of the blobs of the image,
+	// 0 if it could not be sized.
+	Size int64 `json:"size"`
+}
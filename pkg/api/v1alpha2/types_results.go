@@ -0,0 +1,136 @@
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ResultsKind object kind.
+const ResultsKind = "Results"
+
+// Operation names the oc-mirror workflow of a run.
+type Operation string
+
+const (
+	// OperationMirrorToDisk mirrors images into an imageset archive.
+	OperationMirrorToDisk Operation = "mirrorToDisk"
+	// OperationDiskToMirror publishes an imageset archive to a registry.
+	OperationDiskToMirror Operation = "diskToMirror"
+	// OperationMirrorToMirror mirrors images directly to a registry.
+	OperationMirrorToMirror Operation = "mirrorToMirror"
+)
+
+// Results describes everything produced by an oc-mirror run.
+type Results struct {
+	metav1.TypeMeta `json:",inline"`
+	// ResultsSpec defines the outcome of the run.
+	ResultsSpec `json:",inline"`
+}
+
+// ResultsSpec defines the outcome of an oc-mirror run.
+type ResultsSpec struct {
+	// Operation is the workflow that was run.
+	Operation Operation `json:"operation"`
+	// Success is true if the run completed without errors.
+	Success bool `json:"success"`
+	// StartTime is when the run started.
+	StartTime metav1.Time `json:"startTime"`
+	// EndTime is when the run finished.
+	EndTime metav1.Time `json:"endTime"`
+	// DurationSeconds is the wall clock duration of the run.
+	DurationSeconds float64 `json:"durationSeconds"`
+	// Sequence is the imageset sequence number of the run.
+	Sequence int `json:"sequence,omitempty"`
+	// DryRun is true if the run only planned the images,
+	// writing their mapping and metadata without mirroring them.
+	DryRun bool `json:"dryRun,omitempty"`
+	// Archives are the paths of the imageset archives created.
+	Archives []string `json:"archives,omitempty"`
+	// Uploads are the URLs the archives, their checksums, and
+	// their table of contents were uploaded to.
+	Uploads []string `json:"uploads,omitempty"`
+	// Manifests are the paths of the cluster manifests generated.
+	Manifests []string `json:"manifests,omitempty"`
+	// Mappings are the paths of the image mapping files written.
+	Mappings []string `json:"mappings,omitempty"`
+	// Reports are the paths of the reports written.
+	Reports []string `json:"reports,omitempty"`
+	// Images counts the images processed by the run.
+	Images ImageCounts `json:"images"`
+	// Transfer measures the data transferred by the run
+	// and the data it did not need to transfer.
+	Transfer TransferCounts `json:"transfer"`
+	// Phases are the durations of the phases of the run in the order they ran.
+	Phases []PhaseTiming `json:"phases,omitempty"`
+	// ConfigDigest is the sha256 digest of the imageset configuration
+	// of the run, a copy of which is kept in the results directory.
+	ConfigDigest string `json:"configDigest,omitempty"`
+	// Failures are the errors encountered during the run.
+	Failures []Failure `json:"failures,omitempty"`
+	// FailedImages is the path of the list of the images that failed
+	// to mirror, one source=destination pair per line.
+	FailedImages string `json:"failedImages,omitempty"`
+}
+
+// ImageCounts counts images by type.
+type ImageCounts struct {
+	// Total is the number of images.
+	Total int `json:"total"`
+	// ByType is the number of images of each image type.
+	ByType map[string]int `json:"byType,omitempty"`
+}
+
+// TransferCounts measures the blobs transferred by a run
+// and the blobs skipped by incremental and deduplicated transfers.
+type TransferCounts struct {
+	// Bytes and Blobs count the blobs uploaded.
+	Bytes int64 `json:"bytes"`
+	Blobs int   `json:"blobs"`
+	// MountedBytes and MountedBlobs count the blobs mounted from another
+	// repository of the destination registry instead of uploaded.
+	MountedBytes int64 `json:"mountedBytes,omitempty"`
+	MountedBlobs int   `json:"mountedBlobs,omitempty"`
+	// CachedBytes and CachedBlobs count the blobs linked
+	// from the blob cache instead of downloaded.
+	CachedBytes int64 `json:"cachedBytes,omitempty"`
+	CachedBlobs int   `json:"cachedBlobs,omitempty"`
+	// ReusedBytes and ReusedBlobs count the blobs linked from other
+	// repositories of the workspace instead of downloaded.
+	ReusedBytes int64 `json:"reusedBytes,omitempty"`
+	ReusedBlobs int   `json:"reusedBlobs,omitempty"`
+	// PresentBytes is the size of the blobs already present in the destination, estimated
+	// as the size of the mirror plan less the uploaded, mounted, cached, and reused blobs.
+	PresentBytes int64 `json:"presentBytes,omitempty"`
+	// PreviousImages counts the images skipped because
+	// the metadata records them as previously mirrored.
+	PreviousImages int `json:"previousImages,omitempty"`
+	// PreviousBytes and PreviousBlobs count the blobs left out of
+	// the imageset archive because a previous imageset included them.
+	PreviousBytes int64 `json:"previousBytes,omitempty"`
+	PreviousBlobs int   `json:"previousBlobs,omitempty"`
+	// RegistryBytes and RegistryBlobs count the blobs left out of
+	// the imageset archive because the mirror registry has them.
+	RegistryBytes int64 `json:"registryBytes,omitempty"`
+	RegistryBlobs int   `json:"registryBlobs,omitempty"`
+	// DuplicateBytes and DuplicateBlobs count the copies of blobs
+	// shared between repositories that were only archived once.
+	DuplicateBytes int64 `json:"duplicateBytes,omitempty"`
+	DuplicateBlobs int   `json:"duplicateBlobs,omitempty"`
+}
+
+// PhaseTiming is the duration of a phase of a run.
+type PhaseTiming struct {
+	// Name is the name of the phase (e.g. plan, mirror, archive).
+	Name string `json:"name"`
+	// DurationSeconds is the wall clock duration of the phase.
+	DurationSeconds float64 `json:"durationSeconds"`
+	// Success is true if the phase completed without errors.
+	Success bool `json:"success"`
+}
+
+// Failure is an error encountered during a run.
+type Failure struct {
+	// Image is the image the error relates to, if known.
+	Image string `json:"image,omitempty"`
+	// Error is the error message.
+	Error string `json:"error"`
+}
@@ -0,0 +1,71 @@
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RunTrigger is what started a run of the oc-mirror daemon.
+type RunTrigger string
+
+const (
+	// RunTriggerSchedule runs were started by the schedule.
+	RunTriggerSchedule RunTrigger = "schedule"
+	// RunTriggerAPI runs were submitted to the control API.
+	RunTriggerAPI RunTrigger = "api"
+)
+
+// RunStatus is the status of a run of the oc-mirror daemon.
+type RunStatus string
+
+const (
+	// RunStatusQueued runs wait for the previous runs to complete.
+	RunStatusQueued RunStatus = "queued"
+	// RunStatusRunning runs are in progress.
+	RunStatusRunning RunStatus = "running"
+	// RunStatusSucceeded runs completed without errors.
+	RunStatusSucceeded RunStatus = "succeeded"
+	// RunStatusFailed runs failed or were interrupted.
+	RunStatusFailed RunStatus = "failed"
+)
+
+// Run is a run of the oc-mirror daemon, as returned by the control API.
+type Run struct {
+	// ID identifies the run.
+	ID string `json:"id"`
+	// Trigger is what started the run.
+	Trigger RunTrigger `json:"trigger"`
+	// Status is the status of the run.
+	Status RunStatus `json:"status"`
+	// SubmitTime is when the run was queued.
+	SubmitTime metav1.Time `json:"submitTime"`
+	// StartTime is when the run started.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// EndTime is when the run finished.
+	EndTime *metav1.Time `json:"endTime,omitempty"`
+	// ResultsDir is the results directory of the run, set when the run finished.
+	ResultsDir string `json:"resultsDir,omitempty"`
+	// Error is the error of a failed run.
+	Error string `json:"error,omitempty"`
+	// ExitCode is the exit code the run would have had as a command, set when the run finished.
+	ExitCode *int `json:"exitCode,omitempty"`
+	// Progress is the progress of the run, computed from its events.
+	Progress *RunProgress `json:"progress,omitempty"`
+	// Results are the results of the finished run.
+	Results *Results `json:"results,omitempty"`
+}
+
+// RunProgress is the progress of a run of the oc-mirror daemon.
+type RunProgress struct {
+	// Phase is the phase in progress.
+	Phase string `json:"phase,omitempty"`
+	// CompletedPhases are the phases that ended, in order.
+	CompletedPhases []string `json:"completedPhases,omitempty"`
+	// ImagesPushed is the number of images pushed so far.
+	ImagesPushed int `json:"imagesPushed"`
+	// BlobsFetched is the number of blobs fetched so far.
+	BlobsFetched int `json:"blobsFetched"`
+	// BytesFetched is the size of the blobs fetched so far.
+	BytesFetched int64 `json:"bytesFetched"`
+	// Errors is the number of errors so far.
+	Errors int `json:"errors"`
+}
@@ -35,6 +35,15 @@ type packager struct {
 	manifest    map[string]struct{}
 	blobs       map[string]struct{}
 	packedBlobs map[string]struct{}
+	progress    func(int64)
+	// firstSplit is the number of the first archive written
+	firstSplit int
+	// splitDone is called with each complete archive
+	splitDone func(path string, manifests, blobs []string) error
+	// splitManifests and splitBlobs are the files
+	// written to the current archive
+	splitManifests []string
+	splitBlobs     []string
 	Archiver
 }
 
@@ -70,11 +79,41 @@ func NewPackager(manifests []string, blobs []string) *packager {
 	}
 }
 
+// SetProgress sets a function called with the size of each file written to the archives
+func (p *packager) SetProgress(progress func(int64)) {
+	p.progress = progress
+}
+
+// Resume continues the archives of an interrupted run, writing the first
+// archive numbered firstSplit and leaving out the blobs of the complete archives
+func (p *packager) Resume(firstSplit int, packedBlobs []string) {
+	p.firstSplit = firstSplit
+	for _, blob := range packedBlobs {
+		p.packedBlobs[blob] = struct{}{}
+	}
+}
+
+// SetSplitDone sets a function called with the path, the manifest files, and the
+// blob digests of each archive once it is complete, so an interrupted run can be resumed
+func (p *packager) SetSplitDone(splitDone func(path string, manifests, blobs []string) error) {
+	p.splitDone = splitDone
+}
+
+// completeSplit reports the files of the complete archive at splitPath
+func (p *packager) completeSplit(splitPath string) error {
+	manifests, blobs := p.splitManifests, p.splitBlobs
+	p.splitManifests, p.splitBlobs = nil, nil
+	if p.splitDone == nil {
+		return nil
+	}
+	return p.splitDone(splitPath, manifests, blobs)
+}
+
 // CreateSplitArchive will create multiple tar archives from source directory
 func (p *packager) CreateSplitArchive(ctx context.Context, backend storage.Backend, maxSplitSize int64, destDir, sourceDir, prefix string, skipCleanup bool) error {
 
 	// Declare split variables
-	splitNum := 0
+	splitNum := p.firstSplit
 	splitSize := int64(0)
 	splitPath := filepath.Join(destDir, fmt.Sprintf("%s_%06d.%s", prefix, splitNum, p.String()))
 
@@ -110,6 +149,7 @@ func (p *packager) CreateSplitArchive(ctx context.Context, backend storage.Backe
 		}
 
 		var nameInArchive string
+		var blob bool
 
 		switch {
 		case pack(p.manifest, fpath):
@@ -120,6 +160,7 @@ func (p *packager) CreateSplitArchive(ctx context.Context, backend storage.Backe
 		case pack(p.blobs, info.Name()) && !pack(p.packedBlobs, info.Name()):
 			nameInArchive = blobInArchive(info.Name())
 			p.packedBlobs[info.Name()] = struct{}{}
+			blob = true
 
 		default:
 			logrus.Debugf("File %s will not be archived, skipping...", fpath)
@@ -153,6 +194,9 @@ func (p *packager) CreateSplitArchive(ctx context.Context, backend storage.Backe
 			if err := splitFile.Close(); err != nil {
 				return err
 			}
+			if err := p.completeSplit(splitPath); err != nil {
+				return err
+			}
 
 			// Increment split number and reset splitSize
 			splitNum += 1
@@ -171,6 +215,11 @@ func (p *packager) CreateSplitArchive(ctx context.Context, backend storage.Backe
 		if err = p.Write(f); err != nil {
 			return fmt.Errorf("%s: writing: %s", fpath, err)
 		}
+		if blob {
+			p.splitBlobs = append(p.splitBlobs, info.Name())
+		} else {
+			p.splitManifests = append(p.splitManifests, fpath)
+		}
 
 		// Delete file after written to archive
 		if shouldRemove(fpath, info) && !skipCleanup {
@@ -180,6 +229,9 @@ func (p *packager) CreateSplitArchive(ctx context.Context, backend storage.Backe
 		}
 
 		logrus.Debugf("File %s added to archive", fpath)
+		if p.progress != nil {
+			p.progress(info.Size())
+		}
 
 		splitSize += info.Size()
 
@@ -195,7 +247,10 @@ func (p *packager) CreateSplitArchive(ctx context.Context, backend storage.Backe
 		return err
 	}
 
-	return walkErr
+	if walkErr != nil {
+		return walkErr
+	}
+	return p.completeSplit(splitPath)
 }
 
 // Unarchive will extract files unless excluded to destination directory
@@ -262,6 +317,8 @@ func includeFile(fpath string) bool {
 		config.HelmDir:             {},
 		config.ReleaseSignatureDir: {},
 		config.GraphDataDir:        {},
+		config.BootImagesDir:       {},
+		config.ClientsDir:          {},
 	}
 	split := strings.Split(filepath.Clean(fpath), string(filepath.Separator))
 	_, found := includeFiles[split[0]]
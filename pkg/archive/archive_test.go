@@ -122,6 +122,78 @@ func TestSplitArchive(t *testing.T) {
 	}
 }
 
+func TestSplitArchiveProgress(t *testing.T) {
+	sourceDir := t.TempDir()
+	destDir := t.TempDir()
+	blob := "sha256:123456789"
+	if err := ioutil.WriteFile(filepath.Join(sourceDir, blob), []byte("hello\ngo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	backend, err := storage.NewLocalBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := v1alpha2.Metadata{}
+	if err := backend.WriteMetadata(context.Background(), &meta, config.MetadataBasePath); err != nil {
+		t.Fatal(err)
+	}
+
+	packager := NewPackager(nil, []string{blob})
+	var written int64
+	packager.SetProgress(func(n int64) { written += n })
+	if err := packager.CreateSplitArchive(context.Background(), backend, 5*1024*1024, destDir, sourceDir, "testbundle", true); err != nil {
+		t.Fatal(err)
+	}
+	if written != 9 {
+		t.Errorf("Expected 9 bytes of archive progress, got %d", written)
+	}
+}
+
+func TestSplitArchiveResume(t *testing.T) {
+	sourceDir := t.TempDir()
+	destDir := t.TempDir()
+	files := []string{"manifest", "sha256:a", "sha256:b", "sha256:c"}
+	for _, f := range files {
+		if err := ioutil.WriteFile(filepath.Join(sourceDir, f), []byte("hello\ngo\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backend, err := storage.NewLocalBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := v1alpha2.Metadata{}
+	if err := backend.WriteMetadata(context.Background(), &meta, config.MetadataBasePath); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first two archives and blob a were written by the interrupted run
+	packager := NewPackager([]string{filepath.Join(sourceDir, "manifest")}, files[1:])
+	packager.Resume(2, []string{"sha256:a"})
+	var got []string
+	packager.SetSplitDone(func(path string, manifests, blobs []string) error {
+		got = append(got, fmt.Sprintf("%s %d %v", filepath.Base(path), len(manifests), blobs))
+		return nil
+	})
+	if err := packager.CreateSplitArchive(context.Background(), backend, 10, destDir, sourceDir, "testbundle", true); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"testbundle_000002.tar 1 []",
+		"testbundle_000003.tar 0 [sha256:b]",
+		"testbundle_000004.tar 0 [sha256:c]",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected complete archives %q, got %q", want, got)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "testbundle_000000.tar")); err == nil {
+		t.Error("Archive testbundle_000000.tar was written, expected to be skipped")
+	}
+}
+
 // writeFiles write out testfiles to be archived
 func writeFiles() error {
 	d1 := []byte("hello\ngo\n")
@@ -16,11 +16,42 @@ import (
 	"github.com/openshift/oc-mirror/pkg/image"
 )
 
+// ReconcileStats counts the blobs left out of the Imageset by ReconcileV2Dir
+type ReconcileStats struct {
+	// PreviousBlobs and PreviousBytes count the blobs
+	// included in a previous Imageset
+	PreviousBlobs int
+	PreviousBytes int64
+	// RegistryBlobs and RegistryBytes count the blobs
+	// the mirror registry already has
+	RegistryBlobs int
+	RegistryBytes int64
+	// DuplicateBlobs and DuplicateBytes count the copies of blobs
+	// shared between repositories that are only archived once
+	DuplicateBlobs int
+	DuplicateBytes int64
+}
+
 // ReconcileV2Dir gathers all manifests and blobs that were collected during a run
 // and checks against the current list.
 // This function is used to prepare a list of files that need to added to the Imageset.
 func ReconcileV2Dir(assocs image.AssociationSet, filenames map[string]string) (manifests []string, blobs []string, err error) {
+	manifests, blobs, _, err = ReconcileV2DirWithStats(assocs, filenames)
+	return manifests, blobs, err
+}
+
+// ReconcileV2DirWithStats is ReconcileV2Dir and also returns
+// the statistics of the blobs left out of the Imageset.
+func ReconcileV2DirWithStats(assocs image.AssociationSet, filenames map[string]string) (manifests []string, blobs []string, stats ReconcileStats, err error) {
+	return ReconcileV2DirExcluding(assocs, nil, filenames)
+}
+
+// ReconcileV2DirExcluding is ReconcileV2DirWithStats and also leaves
+// out the blobs with the digests of registryBlobs, which the mirror
+// registry already has.
+func ReconcileV2DirExcluding(assocs image.AssociationSet, registryBlobs map[string]struct{}, filenames map[string]string) (manifests []string, blobs []string, stats ReconcileStats, err error) {
 
+	previousFiles := map[string]struct{}{}
 	foundFiles := map[string]struct{}{}
 
 	// Checking against all digest because mirroring
@@ -29,7 +60,7 @@ func ReconcileV2Dir(assocs image.AssociationSet, filenames map[string]string) (m
 	// TODO(jpower432): Investigate why this happens.
 	// Happens with oc image mirror as well.
 	for _, digest := range assocs.GetDigests() {
-		foundFiles[digest] = struct{}{}
+		previousFiles[digest] = struct{}{}
 	}
 
 	for rootOnDisk, rootInArchive := range filenames {
@@ -39,7 +70,7 @@ func ReconcileV2Dir(assocs image.AssociationSet, filenames map[string]string) (m
 		}
 
 		if filepath.Base(rootOnDisk) != config.V2Dir {
-			return manifests, blobs, fmt.Errorf("path %q is not a v2 directory", rootOnDisk)
+			return manifests, blobs, stats, fmt.Errorf("path %q is not a v2 directory", rootOnDisk)
 		}
 
 		err = filepath.WalkDir(rootOnDisk, func(filename string, d fs.DirEntry, err error) error {
@@ -60,8 +91,22 @@ func ReconcileV2Dir(assocs image.AssociationSet, filenames map[string]string) (m
 			switch filepath.Base(dir) {
 			case config.BlobDir:
 				if info.Mode().IsRegular() {
+					if _, found := previousFiles[info.Name()]; found {
+						logrus.Debugf("Blob %s exists in imageset, skipping...", info.Name())
+						stats.PreviousBlobs++
+						stats.PreviousBytes += info.Size()
+						return nil
+					}
+					if _, found := registryBlobs[info.Name()]; found {
+						logrus.Debugf("Blob %s exists in the mirror registry, skipping...", info.Name())
+						stats.RegistryBlobs++
+						stats.RegistryBytes += info.Size()
+						return nil
+					}
 					if _, found := foundFiles[info.Name()]; found {
 						logrus.Debugf("Blob %s exists in imageset, skipping...", info.Name())
+						stats.DuplicateBlobs++
+						stats.DuplicateBytes += info.Size()
 						return nil
 					}
 					blobs = append(blobs, info.Name())
@@ -84,7 +129,7 @@ func ReconcileV2Dir(assocs image.AssociationSet, filenames map[string]string) (m
 		})
 	}
 
-	return manifests, blobs, err
+	return manifests, blobs, stats, err
 }
 
 // ReadImageSet set will create a map with all the files located in the archives
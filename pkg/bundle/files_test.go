@@ -120,6 +120,58 @@ func TestReconcileV2Dir(t *testing.T) {
 	}
 }
 
+func TestReconcileV2DirWithStats(t *testing.T) {
+	assocs := image.AssociationSet{"imgname@sha256:d31c6ea5c50be93d6eb94d2b508f0208e84a308c011c6454ebf291d48b37df19": image.Associations{
+		"imgname@sha256:d31c6ea5c50be93d6eb94d2b508f0208e84a308c011c6454ebf291d48b37df19": {
+			Name:         "imgname@sha256:d31c6ea5c50be93d6eb94d2b508f0208e84a308c011c6454ebf291d48b37df19",
+			Path:         "single_manifest",
+			ID:           "sha256:d31c6ea5c50be93d6eb94d2b508f0208e84a308c011c6454ebf291d48b37df19",
+			Type:         v1alpha2.TypeGeneric,
+			LayerDigests: []string{"test1"},
+		},
+	},
+	}
+
+	tmpdir := t.TempDir()
+	dirPaths := []string{
+		filepath.Join("v2", "test", "blobs"),
+		filepath.Join("v2", "other", "blobs"),
+	}
+	filePaths := []string{
+		filepath.Join("v2", "test", "blobs", "test1"),
+		filepath.Join("v2", "test", "blobs", "test3"),
+		filepath.Join("v2", "other", "blobs", "test1"),
+		filepath.Join("v2", "other", "blobs", "test3"),
+		filepath.Join("v2", "other", "blobs", "test5"),
+	}
+	require.NoError(t, prepFiles(tmpdir, dirPaths, filePaths))
+	filenames := map[string]string{filepath.Join(tmpdir, "v2"): "v2"}
+	_, blobs, stats, err := ReconcileV2DirWithStats(assocs, filenames)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"test3", "test5"}, blobs)
+
+	// Each test file is 9 bytes
+	exp := ReconcileStats{
+		PreviousBlobs:  2,
+		PreviousBytes:  18,
+		DuplicateBlobs: 1,
+		DuplicateBytes: 9,
+	}
+	require.Equal(t, exp, stats)
+
+	// Blobs in the mirror registry are left out of every repository
+	_, blobs, stats, err = ReconcileV2DirExcluding(assocs, map[string]struct{}{"test3": {}}, filenames)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"test5"}, blobs)
+	exp = ReconcileStats{
+		PreviousBlobs: 2,
+		PreviousBytes: 18,
+		RegistryBlobs: 2,
+		RegistryBytes: 18,
+	}
+	require.Equal(t, exp, stats)
+}
+
 func prepFiles(root string, paths []string, files []string) error {
 	for _, path := range paths {
 		if err := os.MkdirAll(filepath.Join(root, path), os.ModePerm); err != nil {
@@ -28,6 +28,10 @@ const (
 	UpdateUrl    = "https://api.openshift.com/api/upgrades_info/v1/graph"
 	OkdUpdateURL = "https://origin-release.ci.openshift.org/graph"
 	OkdChannel   = "okd"
+	// EUSChannelPrefix is the prefix of the Extended Update Support channels
+	EUSChannelPrefix = "eus-"
+	// channelsMetadataKey lists the channels a release is in
+	channelsMetadataKey = "io.openshift.upgrades.graph.release.channels"
 )
 
 // Client is a Cincinnati client which can be used to fetch update graphs from
@@ -53,6 +57,33 @@ func (err *Error) Error() string {
 // Update is a single node from the update graph.
 type Update node
 
+// Channels returns the channels the release of the update is in
+func (u Update) Channels() []string {
+	var channels []string
+	for _, channel := range strings.Split(u.Metadata[channelsMetadataKey], ",") {
+		if channel != "" {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+// IsEUS returns whether the release of the update is in
+// an Extended Update Support channel
+func (u Update) IsEUS() bool {
+	for _, channel := range u.Channels() {
+		if IsEUSChannel(channel) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEUSChannel returns whether the channel is an Extended Update Support channel
+func IsEUSChannel(channel string) bool {
+	return strings.HasPrefix(channel, EUSChannelPrefix)
+}
+
 // GetUpdates fetches the current and requested (if applicable) update payload from the specified
 // upstream Cincinnati stack given the current version and channel. The next-
 // applicable updates are determined by downloading the update graph, finding
@@ -321,7 +352,7 @@ func GetChannels(ctx context.Context, c Client, channel string) (map[string]stru
 	channels := make(map[string]struct{})
 
 	for _, node := range graph.Nodes {
-		values := node.Metadata["io.openshift.upgrades.graph.release.channels"]
+		values := node.Metadata[channelsMetadataKey]
 
 		for _, value := range strings.Split(values, ",") {
 			channels[value] = struct{}{}
@@ -410,6 +410,33 @@ func (c mockClient) GetTransport() *http.Transport {
 	return &http.Transport{}
 }
 
+func TestUpdateChannels(t *testing.T) {
+	tests := []struct {
+		name        string
+		metadata    map[string]string
+		expChannels []string
+		expEUS      bool
+	}{{
+		name:        "Valid/EUS",
+		metadata:    map[string]string{channelsMetadataKey: "eus-4.12,fast-4.12,stable-4.12"},
+		expChannels: []string{"eus-4.12", "fast-4.12", "stable-4.12"},
+		expEUS:      true,
+	}, {
+		name:        "Valid/NotEUS",
+		metadata:    map[string]string{channelsMetadataKey: "fast-4.11,stable-4.11"},
+		expChannels: []string{"fast-4.11", "stable-4.11"},
+	}, {
+		name: "Valid/NoChannels",
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u := Update{Metadata: test.metadata}
+			require.Equal(t, test.expChannels, u.Channels())
+			require.Equal(t, test.expEUS, u.IsEUS())
+		})
+	}
+}
+
 func TestNodeUnmarshalJSON(t *testing.T) {
 	tests := []struct {
 		raw []byte
@@ -7,6 +7,8 @@ import (
 	"net/url"
 
 	"github.com/google/uuid"
+
+	"github.com/openshift/oc-mirror/pkg/image"
 )
 
 type Client interface {
@@ -24,21 +26,23 @@ type ocpClient struct {
 	url       url.URL
 }
 
-// NewOCPClient creates a new OCP Cincinnati client with the given client identifier.
-func NewOCPClient(id uuid.UUID) (Client, error) {
+// NewOCPClient creates a new OCP Cincinnati client with the given client identifier,
+// which connects through the proxy and the pinned keys of the registry clients.
+func NewOCPClient(id uuid.UUID, clients *image.Clients) (Client, error) {
 	upstream, err := url.Parse(UpdateUrl)
 	if err != nil {
 		return &ocpClient{}, err
 	}
 
-	tls, err := getTLSConfig()
+	tls, err := getTLSConfig(upstream.Host, clients)
 	if err != nil {
 		return &ocpClient{}, err
 	}
 
 	transport := &http.Transport{
 		TLSClientConfig: tls,
-		Proxy:           http.ProxyFromEnvironment,
+		Proxy:           clients.Proxy,
+		DialContext:     clients.DialContext,
 	}
 	return &ocpClient{id: id, transport: transport, url: *upstream}, nil
 }
@@ -79,21 +83,23 @@ type okdClient struct {
 	url       url.URL
 }
 
-// NewOKDClient creates a new OKD Cincinnati client with the given client identifier.
-func NewOKDClient(id uuid.UUID) (Client, error) {
+// NewOKDClient creates a new OKD Cincinnati client with the given client identifier,
+// which connects through the proxy and the pinned keys of the registry clients.
+func NewOKDClient(id uuid.UUID, clients *image.Clients) (Client, error) {
 	upstream, err := url.Parse(OkdUpdateURL)
 	if err != nil {
 		return &okdClient{}, err
 	}
 
-	tls, err := getTLSConfig()
+	tls, err := getTLSConfig(upstream.Host, clients)
 	if err != nil {
 		return &okdClient{}, err
 	}
 
 	transport := &http.Transport{
 		TLSClientConfig: tls,
-		Proxy:           http.ProxyFromEnvironment,
+		Proxy:           clients.Proxy,
+		DialContext:     clients.DialContext,
 	}
 	return &okdClient{id: id, transport: transport, url: *upstream}, nil
 }
@@ -110,18 +116,37 @@ func (c *okdClient) GetTransport() *http.Transport {
 	return c.transport
 }
 
-func (c *okdClient) SetQueryParams(_, _, _ string) {
-	// Do nothing
+func (c *okdClient) SetQueryParams(_, channel, version string) {
+	// The legacy OKD graph has no channels and serves a
+	// single architecture, so only the version can be requested.
+	if channel == OkdChannel {
+		channel = ""
+	}
+	queryParams := c.url.Query()
+	queryParams.Add("id", c.id.String())
+	params := map[string]string{
+		"channel": channel,
+		"version": version,
+	}
+	for key, value := range params {
+		if value != "" {
+			queryParams.Add(key, value)
+		}
+	}
+	c.url.RawQuery = queryParams.Encode()
 }
 
-func getTLSConfig() (*tls.Config, error) {
+// getTLSConfig returns the TLS configuration of the Cincinnati host,
+// verifying the public keys pinned for it in the registries configuration
+func getTLSConfig(host string, clients *image.Clients) (*tls.Config, error) {
 	certPool, err := x509.SystemCertPool()
 	if err != nil {
 		return nil, err
 	}
 	config := &tls.Config{
-		RootCAs:    certPool,
-		MinVersion: tls.VersionTLS12,
+		RootCAs:          certPool,
+		MinVersion:       tls.VersionTLS12,
+		VerifyConnection: clients.VerifyConnection(host),
 	}
 	return config, nil
 }
@@ -0,0 +1,43 @@
+package cincinnati
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestOKDClientSetQueryParams(t *testing.T) {
+	id := uuid.MustParse("01234567-0123-0123-0123-0123456789ab")
+
+	tests := []struct {
+		name     string
+		channel  string
+		version  string
+		expQuery string
+	}{
+		{
+			name:     "Valid/LegacyChannel",
+			channel:  OkdChannel,
+			expQuery: "id=" + id.String(),
+		},
+		{
+			name:     "Valid/NamedChannel",
+			channel:  "stable-4",
+			version:  "4.10.0-0.okd-2022-03-07-131213",
+			expQuery: "channel=stable-4&id=" + id.String() + "&version=4.10.0-0.okd-2022-03-07-131213",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := NewOKDClient(id, image.NewClients())
+			require.NoError(t, err)
+			// Architecture is not part of the OKD graph query
+			c.SetQueryParams("amd64", test.channel, test.version)
+			require.Equal(t, test.expQuery, c.GetURL().RawQuery)
+		})
+	}
+}
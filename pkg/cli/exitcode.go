@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"strings"
+
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	"github.com/openshift/oc-mirror/pkg/exitcode"
+)
+
+// exitStatusError reports an error with its exit code to kcmdutil.CheckErr
+type exitStatusError struct {
+	msg  string
+	code int
+}
+
+func (e exitStatusError) Error() string   { return e.msg }
+func (e exitStatusError) String() string  { return e.msg }
+func (e exitStatusError) Exited() bool    { return true }
+func (e exitStatusError) ExitStatus() int { return e.code }
+
+// CheckErr prints the error like kcmdutil.CheckErr and exits with the exit code of the error
+func CheckErr(err error) {
+	code := exitcode.Of(err)
+	if code == exitcode.Success || code == exitcode.Error {
+		kcmdutil.CheckErr(err)
+		return
+	}
+	msg := err.Error()
+	if !strings.HasPrefix(msg, "error: ") {
+		msg = "error: " + msg
+	}
+	kcmdutil.CheckErr(exitStatusError{msg: msg, code: code})
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	"github.com/openshift/oc-mirror/pkg/exitcode"
+)
+
+func TestCheckErr(t *testing.T) {
+	type fatal struct {
+		msg  string
+		code int
+	}
+	var got *fatal
+	kcmdutil.BehaviorOnFatal(func(msg string, code int) { got = &fatal{msg: msg, code: code} })
+	t.Cleanup(kcmdutil.DefaultBehaviorOnFatal)
+
+	CheckErr(nil)
+	require.Nil(t, got)
+
+	CheckErr(errors.New("unexpected error"))
+	require.Equal(t, &fatal{msg: "error: unexpected error", code: exitcode.Error}, got)
+
+	CheckErr(exitcode.With(exitcode.PartialFailure, errors.New("one or more errors occurred")))
+	require.Equal(t, &fatal{msg: "error: one or more errors occurred", code: exitcode.PartialFailure}, got)
+}
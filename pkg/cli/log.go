@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -70,7 +71,12 @@ func (h *fileHook) Fire(entry *logrus.Entry) error {
 	return nil
 }
 
-func setupFileHook(baseDir string) (func(), *os.File) {
+// newJSONFormatter returns the formatter of JSON log entries
+func newJSONFormatter() logrus.Formatter {
+	return &logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano}
+}
+
+func setupFileHook(baseDir, format string) (func(), *os.File) {
 	if baseDir != "" && baseDir != "." {
 		if err := os.MkdirAll(baseDir, 0750); err != nil {
 			logrus.Fatalf("failed to create base directory for logs: %v", err)
@@ -86,12 +92,16 @@ func setupFileHook(baseDir string) (func(), *os.File) {
 	for k, v := range logrus.StandardLogger().Hooks {
 		originalHooks[k] = v
 	}
-	logrus.AddHook(newFileHook(logfile, logrus.TraceLevel, &logrus.TextFormatter{
+	var formatter logrus.Formatter = &logrus.TextFormatter{
 		DisableColors:          true,
 		DisableTimestamp:       false,
 		FullTimestamp:          true,
 		DisableLevelTruncation: false,
-	}))
+	}
+	if format == JSONLogFormat {
+		formatter = newJSONFormatter()
+	}
+	logrus.AddHook(newFileHook(logfile, logrus.TraceLevel, formatter))
 
 	return func() {
 		if err := logfile.Close(); err != nil {
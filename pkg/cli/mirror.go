@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/oc-mirror/pkg/image"
+	"github.com/openshift/oc-mirror/pkg/mirror"
+)
+
+// NewMirrorOptions returns the options of a run of a command, which
+// logs to the standard logger and shares the registry clients of the process
+func NewMirrorOptions() *mirror.MirrorOptions {
+	return mirror.NewMirrorOptions(mirror.WithLogger(logrus.StandardLogger()), mirror.WithClients(image.SharedClients()))
+}
+
+// CompleteMirrorOptions sets the workspace, streams, and log format
+// of the run from the root options once the flags are parsed
+func (o *RootOptions) CompleteMirrorOptions(mo *mirror.MirrorOptions) {
+	mo.Dir = o.Dir
+	mo.Out = o.Out
+	mo.ErrOut = o.ErrOut
+	mo.JSONLogs = o.LogFormat == JSONLogFormat
+}
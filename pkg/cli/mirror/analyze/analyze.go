@@ -0,0 +1,170 @@
+package analyze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	units "github.com/docker/go-units"
+	"github.com/spf13/cobra"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift/oc-mirror/pkg/cli"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
+	"github.com/openshift/oc-mirror/pkg/mirror"
+)
+
+// defaultAnalyzeTop is the default number of largest images and entries printed
+const defaultAnalyzeTop = 10
+
+// AnalyzeOptions configures the analysis of the size of an imageset
+type AnalyzeOptions struct {
+	*cli.RootOptions
+	// Mirror are the options of the analysis run
+	Mirror *mirror.MirrorOptions
+	// Top is the number of largest images and entries to print
+	Top int
+}
+
+func NewAnalyzeCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
+	o := AnalyzeOptions{RootOptions: ro, Mirror: cli.NewMirrorOptions()}
+
+	cmd := &cobra.Command{
+		Use:   "analyze",
+		Short: "Print the largest images of an imageset and the configuration entries that include them",
+		Example: templates.Examples(`
+			# Print the 10 largest images and configuration entries of an imageset configuration
+			oc-mirror analyze --config imageset-config.yaml
+
+			# Print the 20 largest images and image types of an imageset archive
+			oc-mirror analyze --from mirror_seq1_000000.tar --top 20
+		`),
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckErr(o.Validate())
+			kcmdutil.CheckErr(o.Run(cmd.Context()))
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&o.Mirror.ConfigPath, "config", "c", o.Mirror.ConfigPath, "Path to the imageset configuration file to analyze")
+	fs.StringVar(&o.Mirror.From, "from", o.Mirror.From, "Path to an imageset archive, or a directory of archives, to analyze")
+	fs.IntVar(&o.Top, "top", defaultAnalyzeTop, "Number of largest images and entries to print")
+	fs.BoolVar(&o.Mirror.SourceSkipTLS, "source-skip-tls", o.Mirror.SourceSkipTLS, "Disable TLS validation for source registry")
+	fs.BoolVar(&o.Mirror.SourcePlainHTTP, "source-use-http", o.Mirror.SourcePlainHTTP, "Use plain HTTP for source registry")
+	fs.StringSliceVar(&o.Mirror.InsecureRegistries, "insecure-registry", o.Mirror.InsecureRegistries, "Registry the insecure options apply to, "+
+		"in addition to the registries marked insecure in the configuration (can be repeated)")
+	fs.BoolVar(&o.Mirror.SkipVerification, "skip-verification", o.Mirror.SkipVerification, "Skip digest verification")
+	fs.StringSliceVar(&o.Mirror.FilterOptions, "filter-by-os", []string{"amd64"}, "Release architectures to analyze")
+
+	return cmd
+}
+
+func (o *AnalyzeOptions) Validate() error {
+	if (o.Mirror.ConfigPath == "") == (o.Mirror.From == "") {
+		return errors.New("exactly one of --config or --from must be specified")
+	}
+	if o.Top <= 0 {
+		return errors.New("--top must be positive")
+	}
+	return nil
+}
+
+func (o *AnalyzeOptions) Run(ctx context.Context) error {
+	o.CompleteMirrorOptions(o.Mirror)
+	if o.Mirror.From != "" {
+		images, err := o.Mirror.AnalyzeArchive(ctx, o.Mirror.From)
+		if err != nil {
+			return err
+		}
+		return writeAnalysis(o.Out, images, o.Top, "IMAGE TYPE")
+	}
+
+	cfg, err := config.ReadConfig(o.Mirror.ConfigPath)
+	if err != nil {
+		return err
+	}
+	images, err := o.Mirror.AnalyzeConfig(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	return writeAnalysis(o.Out, images, o.Top, "CONFIG ENTRY")
+}
+
+// entrySize is the size of the images of an entry
+type entrySize struct {
+	name   string
+	images int
+	size   int64
+}
+
+// writeAnalysis writes the top largest images and entries to w.
+// Entry and total sizes count blobs shared between images once.
+func writeAnalysis(w io.Writer, images []*mirror.AnalyzedImage, top int, entryLabel string) error {
+	sort.SliceStable(images, func(i, j int) bool {
+		si, sj := images[i].Blobs.Size(), images[j].Blobs.Size()
+		if si != sj {
+			return si > sj
+		}
+		return images[i].Name < images[j].Name
+	})
+
+	entryBlobs := map[string]image.BlobSizes{}
+	entryImages := map[string]int{}
+	allBlobs := image.BlobSizes{}
+	for _, img := range images {
+		for _, entry := range img.Entries {
+			if entryBlobs[entry] == nil {
+				entryBlobs[entry] = image.BlobSizes{}
+			}
+			entryImages[entry]++
+			entryBlobs[entry].Add(img.Blobs)
+		}
+		allBlobs.Add(img.Blobs)
+	}
+	var entries []entrySize
+	for name, blobs := range entryBlobs {
+		entries = append(entries, entrySize{name: name, images: entryImages[name], size: blobs.Size()})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].size != entries[j].size {
+			return entries[i].size > entries[j].size
+		}
+		return entries[i].name < entries[j].name
+	})
+
+	size := func(bytes int64) string { return units.BytesSize(float64(bytes)) }
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "IMAGE\tTYPE\tLAYERS\tSIZE\t%s\n", entryLabel)
+	for i, img := range images {
+		if i == top {
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", img.Name, img.Type, len(img.Blobs.Layers), size(img.Blobs.Size()), strings.Join(img.Entries, ", "))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tIMAGES\tSIZE\n", entryLabel)
+	for i, entry := range entries {
+		if i == top {
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", entry.name, entry.images, size(entry.size))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Total: %d images, %s\n", len(images), size(allBlobs.Size()))
+	return nil
+}
@@ -0,0 +1,57 @@
+package analyze
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+	"github.com/openshift/oc-mirror/pkg/mirror"
+)
+
+func TestWriteAnalysis(t *testing.T) {
+	const mib = 1024 * 1024
+	images := []*mirror.AnalyzedImage{
+		{
+			Name:    "registry.redhat.io/ubi8/ubi:latest",
+			Type:    v1alpha2.TypeGeneric,
+			Entries: []string{"additionalImages[registry.redhat.io/ubi8/ubi:latest]"},
+			Blobs: image.ImageBlobs{
+				Layers:  map[string]int64{"sha256:ubi": 36 * mib},
+				Configs: map[string]int64{"sha256:ubiconfig": 0},
+			},
+		},
+		{
+			Name:    "quay.io/openshift-release-dev/ocp-release:4.9.0-x86_64",
+			Type:    v1alpha2.TypeOCPRelease,
+			Entries: []string{"platform.channels[stable-4.9]"},
+			Blobs: image.ImageBlobs{
+				Layers: map[string]int64{"sha256:rhel": 80 * mib, "sha256:release": 20 * mib},
+			},
+		},
+		{
+			Name:    "quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8",
+			Type:    v1alpha2.TypeOCPReleaseContent,
+			Entries: []string{"platform.channels[stable-4.9]", "platform.channels[stable-4.10]"},
+			Blobs: image.ImageBlobs{
+				Layers: map[string]int64{"sha256:rhel": 80 * mib, "sha256:component": 50 * mib},
+			},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	require.NoError(t, writeAnalysis(out, images, 2, "CONFIG ENTRY"))
+	exp := `IMAGE                                                                                                                   TYPE               LAYERS  SIZE    CONFIG ENTRY
+quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8  ocpReleaseContent  2       130MiB  platform.channels[stable-4.9], platform.channels[stable-4.10]
+quay.io/openshift-release-dev/ocp-release:4.9.0-x86_64                                                                  ocpRelease         2       100MiB  platform.channels[stable-4.9]
+
+CONFIG ENTRY                    IMAGES  SIZE
+platform.channels[stable-4.9]   2       150MiB
+platform.channels[stable-4.10]  1       130MiB
+
+Total: 3 images, 186MiB
+`
+	require.Equal(t, exp, out.String())
+}
@@ -0,0 +1,430 @@
+package cleanup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	units "github.com/docker/go-units"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift/oc-mirror/pkg/cli"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
+	"github.com/openshift/oc-mirror/pkg/mirror"
+)
+
+const (
+	cleanupTableOutput = "table"
+	cleanupJSONOutput  = "json"
+)
+
+// workspaceLeftovers are the patterns of the temporary directories runs
+// create in the workspace and remove when they complete
+var workspaceLeftovers = []string{
+	// workspaces of the images unpacked from the imageset by publish
+	"images.*",
+	// workspaces of the operator catalogs
+	"operators.*",
+	// signature bundles extracted by create
+	"signatures.*",
+	// catalogs rendered by list updates
+	"updatetmp-*",
+	// backends of the archives being packed
+	filepath.Join(config.SourceDir, "tmpbackend.*"),
+}
+
+// tempLeftovers are the patterns of the temporary directories runs
+// create in the system temporary directory and remove when they complete
+var tempLeftovers = []string{
+	"imageset-catalog-registry-*",
+	"oc-mirror-analyze*",
+	"oc-mirror-doctor*",
+	"oc-mirror-list-*",
+	"oc-mirror-metadata*",
+	"oc-mirror-plan*",
+	"oc-mirror-search-*",
+}
+
+// CleanupOptions configures the removal of the leftovers of interrupted runs
+type CleanupOptions struct {
+	*cli.RootOptions
+	// OlderThan is the age of the directories removed, which protects
+	// the directories of runs in progress
+	OlderThan time.Duration
+	// SkipTemp does not clean the system temporary directory
+	SkipTemp bool
+	DryRun   bool
+	Output   string
+
+	// tempDir is the system temporary directory
+	tempDir string
+	now     func() time.Time
+}
+
+func NewCleanupCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
+	o := CleanupOptions{}
+	o.RootOptions = ro
+
+	cmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Remove the temporary directories left by interrupted runs",
+		Long: templates.LongDesc(`
+			Remove the temporary directories left in the workspace and in the system
+			temporary directory by interrupted runs: the images unpacked by publishing,
+			the operator catalog workspaces, the extracted signature bundles, the
+			backends of archives being packed, and the rendered catalogs.
+
+			Only directories with no file modified for --older-than are removed, so
+			the directories of runs in progress are kept. The workspace is locked
+			while it is cleaned, and cleanup fails while a run uses it. The workspace
+			itself, its downloaded images, and its metadata are never removed.
+
+			The blob upload sessions runs left open in registries are recorded in the
+			workspace and aborted. Sessions of runs older than the recording, or
+			using another workspace, cannot be listed with the registry API and are
+			purged by the registries on their own.
+		`),
+		Example: templates.Examples(`
+			# Remove the leftovers of interrupted runs from a workspace
+			oc-mirror cleanup --dir oc-mirror-workspace
+
+			# List the leftovers without removing them
+			oc-mirror cleanup --dir oc-mirror-workspace --dry-run
+
+			# Also remove leftovers from the last 10 minutes
+			oc-mirror cleanup --dir oc-mirror-workspace --older-than 10m
+		`),
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckErr(o.Complete())
+			kcmdutil.CheckErr(o.Validate())
+			kcmdutil.CheckErr(o.Run(cmd.Context(), o.IOStreams.Out))
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.DurationVar(&o.OlderThan, "older-than", time.Hour, "Only remove directories with no file modified for this long")
+	fs.BoolVar(&o.SkipTemp, "skip-temp", o.SkipTemp, "Do not remove leftovers from the system temporary directory")
+	fs.BoolVar(&o.DryRun, "dry-run", o.DryRun, "List the leftovers without removing them or aborting uploads")
+	fs.StringVarP(&o.Output, "output", "o", cleanupTableOutput, "Output format: table or json")
+
+	return cmd
+}
+
+func (o *CleanupOptions) Complete() error {
+	if o.tempDir == "" {
+		o.tempDir = os.TempDir()
+	}
+	if o.now == nil {
+		o.now = time.Now
+	}
+	return nil
+}
+
+func (o *CleanupOptions) Validate() error {
+	if o.OlderThan < 0 {
+		return errors.New("--older-than must not be negative")
+	}
+	switch o.Output {
+	case cleanupTableOutput, cleanupJSONOutput:
+		return nil
+	default:
+		return fmt.Errorf("output format %q is not supported: must be %s or %s", o.Output, cleanupTableOutput, cleanupJSONOutput)
+	}
+}
+
+// leftover is a temporary directory left by a run
+type leftover struct {
+	Path string `json:"path"`
+	// ModTime is the last modification of the directory or its files
+	ModTime time.Time `json:"modTime"`
+	Size    int64     `json:"size"`
+	Removed bool      `json:"removed"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// leftoverUpload is a blob upload session left open by a run
+type leftoverUpload struct {
+	Location string `json:"location"`
+	Aborted  bool   `json:"aborted"`
+	Error    string `json:"error,omitempty"`
+}
+
+// cleanupReport is the result of a cleanup
+type cleanupReport struct {
+	DryRun    bool             `json:"dryRun"`
+	Leftovers []leftover       `json:"leftovers"`
+	Uploads   []leftoverUpload `json:"uploads"`
+	// Freed is the size of the directories removed
+	Freed int64 `json:"freed"`
+}
+
+func (o *CleanupOptions) Run(ctx context.Context, w io.Writer) error {
+	// Runs hold the workspace lock, so their directories
+	// and upload sessions are not cleaned while they run
+	if _, err := os.Stat(o.Dir); err == nil {
+		lock, err := mirror.LockWorkspace(o.Dir)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+	}
+
+	leftovers, err := o.findLeftovers()
+	if err != nil {
+		return err
+	}
+	uploads, failedUploads, err := o.abortUploads(ctx)
+	if err != nil {
+		return err
+	}
+
+	rep := cleanupReport{DryRun: o.DryRun, Leftovers: leftovers, Uploads: uploads}
+	var failed int
+	for i := range rep.Leftovers {
+		l := &rep.Leftovers[i]
+		if o.DryRun {
+			continue
+		}
+		logrus.Debugf("Removing %s", l.Path)
+		if err := os.RemoveAll(l.Path); err != nil {
+			l.Error = err.Error()
+			failed++
+			continue
+		}
+		l.Removed = true
+		rep.Freed += l.Size
+	}
+
+	if err := o.writeCleanupReport(w, rep); err != nil {
+		return err
+	}
+	if failed != 0 {
+		return fmt.Errorf("failed to remove %d of %d directories", failed, len(rep.Leftovers))
+	}
+	if failedUploads != 0 {
+		return fmt.Errorf("failed to abort %d of %d upload sessions", failedUploads, len(rep.Uploads))
+	}
+	return nil
+}
+
+// abortUploads aborts the upload sessions left open in the journal of the
+// workspace, and keeps the sessions that failed to abort in the journal
+func (o *CleanupOptions) abortUploads(ctx context.Context) ([]leftoverUpload, int, error) {
+	path := filepath.Join(o.Dir, mirror.UploadJournalFile)
+	sessions, err := image.OpenUploadSessions(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	uploads := make([]leftoverUpload, 0, len(sessions))
+	if o.DryRun {
+		for _, s := range sessions {
+			uploads = append(uploads, leftoverUpload{Location: s.Location})
+		}
+		return uploads, 0, nil
+	}
+
+	// The sessions are aborted with the insecure
+	// options of the runs that started them
+	var insecure []string
+	for _, s := range sessions {
+		if u, err := url.Parse(s.Location); err == nil && s.Insecure {
+			insecure = append(insecure, u.Host)
+		}
+	}
+	if len(insecure) != 0 {
+		if err := image.SharedClients().SetRegistries(image.MarkInsecure(nil, insecure)); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var remaining []image.UploadSession
+	for _, s := range sessions {
+		u := leftoverUpload{Location: s.Location}
+		logrus.Debugf("Aborting upload session %s", s.Location)
+		if err := image.SharedClients().AbortUpload(ctx, s); err != nil {
+			u.Error = err.Error()
+			remaining = append(remaining, s)
+		} else {
+			u.Aborted = true
+		}
+		uploads = append(uploads, u)
+	}
+	if err := image.WriteUploadSessions(path, remaining); err != nil {
+		return nil, 0, fmt.Errorf("error writing upload journal: %v", err)
+	}
+	return uploads, len(remaining), nil
+}
+
+// findLeftovers returns the leftover directories not modified for
+// the minimum age, sorted by path
+func (o *CleanupOptions) findLeftovers() ([]leftover, error) {
+	var patterns []string
+	for _, p := range workspaceLeftovers {
+		patterns = append(patterns, filepath.Join(o.Dir, p))
+	}
+	if !o.SkipTemp {
+		for _, p := range tempLeftovers {
+			patterns = append(patterns, filepath.Join(o.tempDir, p))
+		}
+	}
+
+	leftovers := []leftover{}
+	seen := map[string]struct{}{}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range matches {
+			if _, ok := seen[path]; ok {
+				continue
+			}
+			seen[path] = struct{}{}
+			info, err := os.Lstat(path)
+			if err != nil || !info.IsDir() {
+				continue
+			}
+			// Runs in progress can keep writing to a directory
+			// long after creating it, so its newest file is used
+			size, modTime, err := dirUsage(path)
+			if err != nil {
+				logrus.Debugf("Keeping %s, which could not be read: %v", path, err)
+				continue
+			}
+			if o.now().Sub(modTime) < o.OlderThan {
+				logrus.Debugf("Keeping %s, which was modified at %s", path, modTime.Format(time.RFC3339))
+				continue
+			}
+			leftovers = append(leftovers, leftover{Path: path, ModTime: modTime, Size: size})
+		}
+	}
+	sort.Slice(leftovers, func(i, j int) bool { return leftovers[i].Path < leftovers[j].Path })
+	return leftovers, nil
+}
+
+// dirUsage returns the total size of the regular files under dir,
+// and the last modification of dir or of anything under it
+func dirUsage(dir string) (int64, time.Time, error) {
+	var size int64
+	var modTime time.Time
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, modTime, err
+}
+
+// writeCleanupReport writes the leftovers as a table or JSON
+func (o *CleanupOptions) writeCleanupReport(w io.Writer, rep cleanupReport) error {
+	if o.Output == cleanupJSONOutput {
+		data, err := json.MarshalIndent(rep, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+
+	if len(rep.Leftovers) == 0 && len(rep.Uploads) == 0 {
+		_, err := fmt.Fprintln(w, "No leftovers found")
+		return err
+	}
+	if len(rep.Leftovers) != 0 {
+		if err := writeLeftovers(w, rep); err != nil {
+			return err
+		}
+	}
+	if len(rep.Uploads) != 0 {
+		return writeLeftoverUploads(w, rep)
+	}
+	return nil
+}
+
+// writeLeftovers writes the table of the leftover directories
+func writeLeftovers(w io.Writer, rep cleanupReport) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "PATH\tSIZE\tMODIFIED\tSTATUS"); err != nil {
+		return err
+	}
+	var total int64
+	var removed int
+	for _, l := range rep.Leftovers {
+		status := "removed"
+		switch {
+		case rep.DryRun:
+			status = "found"
+		case l.Error != "":
+			status = "error: " + l.Error
+		}
+		total += l.Size
+		if l.Removed {
+			removed++
+		}
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", l.Path, units.BytesSize(float64(l.Size)), l.ModTime.Format(time.RFC3339), status); err != nil {
+			return err
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if rep.DryRun {
+		_, err := fmt.Fprintf(w, "Would remove %d directories (%s)\n", len(rep.Leftovers), units.BytesSize(float64(total)))
+		return err
+	}
+	_, err := fmt.Fprintf(w, "Removed %d directories (%s)\n", removed, units.BytesSize(float64(rep.Freed)))
+	return err
+}
+
+// writeLeftoverUploads writes the table of the upload sessions left open
+func writeLeftoverUploads(w io.Writer, rep cleanupReport) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "UPLOAD SESSION\tSTATUS"); err != nil {
+		return err
+	}
+	var aborted int
+	for _, u := range rep.Uploads {
+		status := "aborted"
+		switch {
+		case rep.DryRun:
+			status = "found"
+		case u.Error != "":
+			status = "error: " + u.Error
+		}
+		if u.Aborted {
+			aborted++
+		}
+		if _, err := fmt.Fprintf(tw, "%s\t%s\n", u.Location, status); err != nil {
+			return err
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if rep.DryRun {
+		_, err := fmt.Fprintf(w, "Would abort %d upload sessions\n", len(rep.Uploads))
+		return err
+	}
+	_, err := fmt.Fprintf(w, "Aborted %d upload sessions\n", aborted)
+	return err
+}
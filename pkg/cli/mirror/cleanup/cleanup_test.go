@@ -0,0 +1,224 @@
+package cleanup
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/cli"
+	"github.com/openshift/oc-mirror/pkg/image"
+	"github.com/openshift/oc-mirror/pkg/mirror"
+)
+
+func TestCleanup(t *testing.T) {
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	mkdir := func(t *testing.T, path string, age time.Duration) {
+		require.NoError(t, os.MkdirAll(filepath.Join(path, "v2"), 0750))
+		require.NoError(t, os.WriteFile(filepath.Join(path, "v2", "blob"), []byte("blob"), 0600))
+		for _, p := range []string{filepath.Join(path, "v2", "blob"), filepath.Join(path, "v2"), path} {
+			require.NoError(t, os.Chtimes(p, now.Add(-age), now.Add(-age)))
+		}
+	}
+
+	type spec struct {
+		name     string
+		dryRun   bool
+		skipTemp bool
+		expFound []string
+		expKept  []string
+	}
+	cases := []spec{
+		{
+			name:     "Valid/Remove",
+			expFound: []string{"temp/oc-mirror-plan123", "workspace/images.123", "workspace/operators.1672628645", "workspace/src/tmpbackend.1672628645"},
+			expKept:  []string{"temp/unrelated", "workspace/images.456", "workspace/images.789", "workspace/publish", "workspace/src/v2"},
+		},
+		{
+			name:     "Valid/DryRun",
+			dryRun:   true,
+			expFound: []string{"temp/oc-mirror-plan123", "workspace/images.123", "workspace/operators.1672628645", "workspace/src/tmpbackend.1672628645"},
+			expKept: []string{"temp/oc-mirror-plan123", "temp/unrelated", "workspace/images.123", "workspace/images.456", "workspace/images.789",
+				"workspace/operators.1672628645", "workspace/publish", "workspace/src/tmpbackend.1672628645", "workspace/src/v2"},
+		},
+		{
+			name:     "Valid/SkipTemp",
+			skipTemp: true,
+			expFound: []string{"workspace/images.123", "workspace/operators.1672628645", "workspace/src/tmpbackend.1672628645"},
+			expKept:  []string{"temp/oc-mirror-plan123", "temp/unrelated", "workspace/images.456", "workspace/images.789", "workspace/publish", "workspace/src/v2"},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			root := t.TempDir()
+			workspace := filepath.Join(root, "workspace")
+			temp := filepath.Join(root, "temp")
+			mkdir(t, filepath.Join(workspace, "images.123"), 2*time.Hour)
+			mkdir(t, filepath.Join(workspace, "images.456"), time.Minute)
+			// A run in progress writing to an old directory
+			mkdir(t, filepath.Join(workspace, "images.789"), 2*time.Hour)
+			require.NoError(t, os.Chtimes(filepath.Join(workspace, "images.789", "v2", "blob"), now, now))
+			mkdir(t, filepath.Join(workspace, "operators.1672628645"), 2*time.Hour)
+			mkdir(t, filepath.Join(workspace, "src", "tmpbackend.1672628645"), 2*time.Hour)
+			mkdir(t, filepath.Join(workspace, "src", "v2"), 2*time.Hour)
+			mkdir(t, filepath.Join(workspace, "publish"), 2*time.Hour)
+			mkdir(t, filepath.Join(temp, "oc-mirror-plan123"), 2*time.Hour)
+			mkdir(t, filepath.Join(temp, "unrelated"), 2*time.Hour)
+
+			var out bytes.Buffer
+			o := &CleanupOptions{
+				RootOptions: &cli.RootOptions{Dir: workspace},
+				OlderThan:   time.Hour,
+				SkipTemp:    c.skipTemp,
+				DryRun:      c.dryRun,
+				Output:      cleanupTableOutput,
+				tempDir:     temp,
+				now:         func() time.Time { return now },
+			}
+			require.NoError(t, o.Complete())
+			require.NoError(t, o.Validate())
+
+			leftovers, err := o.findLeftovers()
+			require.NoError(t, err)
+			var found []string
+			for _, l := range leftovers {
+				rel, err := filepath.Rel(root, l.Path)
+				require.NoError(t, err)
+				found = append(found, rel)
+				require.Equal(t, int64(4), l.Size)
+			}
+			require.Equal(t, c.expFound, found)
+
+			require.NoError(t, o.Run(context.TODO(), &out))
+			var kept []string
+			for _, dir := range []string{workspace, filepath.Join(workspace, "src"), temp} {
+				entries, err := os.ReadDir(dir)
+				require.NoError(t, err)
+				for _, e := range entries {
+					if dir == workspace && (e.Name() == "src" || e.Name() == mirror.WorkspaceLockFile) {
+						continue
+					}
+					rel, err := filepath.Rel(root, filepath.Join(dir, e.Name()))
+					require.NoError(t, err)
+					kept = append(kept, rel)
+				}
+			}
+			require.ElementsMatch(t, c.expKept, kept)
+			if c.dryRun {
+				require.Contains(t, out.String(), "Would remove")
+			} else {
+				require.Contains(t, out.String(), "Removed")
+			}
+		})
+	}
+}
+
+func TestCleanupValidate(t *testing.T) {
+	type spec struct {
+		name     string
+		opts     *CleanupOptions
+		expError string
+	}
+	cases := []spec{
+		{
+			name:     "Invalid/OlderThan",
+			opts:     &CleanupOptions{OlderThan: -time.Hour, Output: cleanupTableOutput},
+			expError: "--older-than must not be negative",
+		},
+		{
+			name:     "Invalid/Output",
+			opts:     &CleanupOptions{Output: "yaml"},
+			expError: `output format "yaml" is not supported: must be table or json`,
+		},
+		{
+			name: "Valid/JSON",
+			opts: &CleanupOptions{OlderThan: time.Hour, Output: cleanupJSONOutput},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			err := c.opts.Validate()
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCleanupLocked(t *testing.T) {
+	workspace := t.TempDir()
+	lock, err := mirror.LockWorkspace(workspace)
+	require.NoError(t, err)
+
+	o := &CleanupOptions{
+		RootOptions: &cli.RootOptions{Dir: workspace},
+		OlderThan:   time.Hour,
+		SkipTemp:    true,
+		Output:      cleanupTableOutput,
+	}
+	require.NoError(t, o.Complete())
+	err = o.Run(context.TODO(), &bytes.Buffer{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is in use by another oc-mirror run")
+
+	lock.Unlock()
+	require.NoError(t, o.Run(context.TODO(), &bytes.Buffer{}))
+}
+
+func TestCleanupUploads(t *testing.T) {
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodDelete && r.URL.Path == "/v2/ns/image/blobs/uploads/1234":
+			deleted = append(deleted, r.URL.String())
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	workspace := t.TempDir()
+	journal := filepath.Join(workspace, mirror.UploadJournalFile)
+	sessions := []image.UploadSession{
+		{Location: server.URL + "/v2/ns/image/blobs/uploads/1234?_state=abc"},
+		{Location: server.URL + "/v2/ns/other/blobs/uploads/5678"},
+	}
+	require.NoError(t, image.WriteUploadSessions(journal, sessions))
+
+	o := &CleanupOptions{
+		RootOptions: &cli.RootOptions{Dir: workspace},
+		OlderThan:   time.Hour,
+		SkipTemp:    true,
+		DryRun:      true,
+		Output:      cleanupTableOutput,
+	}
+	require.NoError(t, o.Complete())
+	var out bytes.Buffer
+	require.NoError(t, o.Run(context.TODO(), &out))
+	require.Contains(t, out.String(), "Would abort 2 upload sessions")
+	require.Empty(t, deleted)
+
+	// The sessions failing to abort are kept in the journal
+	o.DryRun = false
+	out.Reset()
+	err := o.Run(context.TODO(), &out)
+	require.EqualError(t, err, "failed to abort 1 of 2 upload sessions")
+	require.Equal(t, []string{"/v2/ns/image/blobs/uploads/1234?_state=abc"}, deleted)
+	require.Contains(t, out.String(), "Aborted 1 upload sessions")
+	remaining, err := image.OpenUploadSessions(journal)
+	require.NoError(t, err)
+	require.Equal(t, sessions[1:], remaining)
+}
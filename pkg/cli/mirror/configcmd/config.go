@@ -0,0 +1,26 @@
+package configcmd
+
+import (
+	"github.com/spf13/cobra"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift/oc-mirror/pkg/cli"
+)
+
+func NewConfigCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
+
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Check imageset configurations",
+		Example: templates.Examples(`
+			# Check an imageset configuration for deprecated fields and unused sections
+			oc-mirror config lint --config imageset-config.yaml
+		`),
+		Run: kcmdutil.DefaultSubCommandRun(ro.IOStreams.ErrOut),
+	}
+
+	cmd.AddCommand(NewLintCommand(f, ro))
+
+	return cmd
+}
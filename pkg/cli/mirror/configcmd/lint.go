@@ -0,0 +1,158 @@
+package configcmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift/oc-mirror/pkg/cli"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/exitcode"
+)
+
+const (
+	tableOutput = "table"
+	jsonOutput  = "json"
+)
+
+type LintOptions struct {
+	*cli.RootOptions
+	ConfigPath string
+	Output     string
+	// Strict fails on warnings as well as errors
+	Strict bool
+}
+
+// lintReport is the result of linting a configuration
+type lintReport struct {
+	Findings []config.LintFinding `json:"findings"`
+	Errors   int                  `json:"errors"`
+	Warnings int                  `json:"warnings"`
+}
+
+func NewLintCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
+	o := LintOptions{}
+	o.RootOptions = ro
+
+	cmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Check an imageset configuration for problems before a run",
+		Long: templates.LongDesc(`
+			Check an imageset configuration for problems before a run, without
+			connecting to any registry.
+
+			In addition to the validation of every run, lint reports:
+
+			* Deprecated fields, such as the v1alpha1 apiVersion, mirror.ocp, the
+			  versions of release channels, and the headsOnly field of operators,
+			  with their newer equivalent
+			* Unused sections, such as graph settings without release channels, or
+			  clients and boot images without releases
+			* Filters matching nothing, such as a minVersion greater than the
+			  maxVersion of a release channel
+
+			Errors make the command fail. With --strict, warnings do too.
+		`),
+		Example: templates.Examples(`
+			# Check an imageset configuration
+			oc-mirror config lint --config imageset-config.yaml
+
+			# Fail on warnings too, and report the findings as JSON
+			oc-mirror config lint --config imageset-config.yaml --strict -o json
+		`),
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cli.CheckErr(exitcode.With(exitcode.ConfigError, o.Validate()))
+			cli.CheckErr(exitcode.With(exitcode.ConfigError, o.Run(o.IOStreams.Out)))
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&o.ConfigPath, "config", "c", o.ConfigPath, "Path to imageset configuration file")
+	fs.StringVarP(&o.Output, "output", "o", tableOutput, "Output format: table or json")
+	fs.BoolVar(&o.Strict, "strict", o.Strict, "Fail on warnings as well as errors")
+
+	return cmd
+}
+
+func (o *LintOptions) Validate() error {
+	if len(o.ConfigPath) == 0 {
+		return errors.New("must specify a configuration file with --config")
+	}
+	switch o.Output {
+	case tableOutput, jsonOutput:
+		return nil
+	default:
+		return fmt.Errorf("output format %q is not supported: must be %s or %s", o.Output, tableOutput, jsonOutput)
+	}
+}
+
+func (o *LintOptions) Run(out io.Writer) error {
+	data, err := ioutil.ReadFile(filepath.Clean(o.ConfigPath))
+	if err != nil {
+		return err
+	}
+
+	rep := lintReport{Findings: config.Lint(data)}
+	if rep.Findings == nil {
+		rep.Findings = []config.LintFinding{}
+	}
+	for _, f := range rep.Findings {
+		switch f.Severity {
+		case config.LintError:
+			rep.Errors++
+		case config.LintWarning:
+			rep.Warnings++
+		}
+	}
+
+	if err := o.writeReport(out, rep); err != nil {
+		return err
+	}
+	switch {
+	case rep.Errors != 0:
+		return fmt.Errorf("%s has %d errors and %d warnings", o.ConfigPath, rep.Errors, rep.Warnings)
+	case o.Strict && rep.Warnings != 0:
+		return fmt.Errorf("%s has %d warnings", o.ConfigPath, rep.Warnings)
+	}
+	return nil
+}
+
+func (o *LintOptions) writeReport(w io.Writer, rep lintReport) error {
+	if o.Output == jsonOutput {
+		data, err := json.MarshalIndent(rep, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+	if len(rep.Findings) != 0 {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		if _, err := fmt.Fprintln(tw, "SEVERITY\tPATH\tMESSAGE\tSUGGESTION"); err != nil {
+			return err
+		}
+		for _, f := range rep.Findings {
+			path := f.Path
+			if path == "" {
+				path = "-"
+			}
+			if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Severity, path, f.Message, f.Suggestion); err != nil {
+				return err
+			}
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d errors, %d warnings\n", rep.Errors, rep.Warnings)
+	return err
+}
@@ -0,0 +1,136 @@
+package configcmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/cli"
+)
+
+func TestLintRun(t *testing.T) {
+	type spec struct {
+		name      string
+		config    string
+		output    string
+		strict    bool
+		expOutput string
+		expError  string
+	}
+	cases := []spec{
+		{
+			name: "Valid/NoFindings",
+			config: `apiVersion: mirror.openshift.io/v1alpha2
+kind: ImageSetConfiguration
+storageConfig:
+  local:
+    path: /tmp/metadata
+mirror:
+  additionalImages:
+  - name: registry.example.com/app:v1
+`,
+			output:    tableOutput,
+			expOutput: "0 errors, 0 warnings\n",
+		},
+		{
+			name: "Valid/Warnings",
+			config: `apiVersion: mirror.openshift.io/v1alpha2
+kind: ImageSetConfiguration
+mirror:
+  additionalImages:
+  - name: registry.example.com/app:v1
+`,
+			output:    tableOutput,
+			expOutput: "set storageConfig.local.path or storageConfig.registry.imageURL",
+		},
+		{
+			name: "Invalid/StrictWarnings",
+			config: `apiVersion: mirror.openshift.io/v1alpha2
+kind: ImageSetConfiguration
+mirror:
+  additionalImages:
+  - name: registry.example.com/app:v1
+`,
+			output:   tableOutput,
+			strict:   true,
+			expError: "has 1 warnings",
+		},
+		{
+			name: "Invalid/MinGreaterThanMax",
+			config: `apiVersion: mirror.openshift.io/v1alpha2
+kind: ImageSetConfiguration
+storageConfig:
+  local:
+    path: /tmp/metadata
+mirror:
+  platform:
+    channels:
+    - name: stable-4.12
+      minVersion: 4.12.10
+      maxVersion: 4.12.3
+`,
+			output:   tableOutput,
+			expError: "has 1 errors and 0 warnings",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "imageset-config.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(c.config), 0600))
+			o := &LintOptions{
+				RootOptions: &cli.RootOptions{},
+				ConfigPath:  path,
+				Output:      c.output,
+				Strict:      c.strict,
+			}
+			require.NoError(t, o.Validate())
+			out := &bytes.Buffer{}
+			err := o.Run(out)
+			if c.expError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expError)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Contains(t, out.String(), c.expOutput)
+		})
+	}
+}
+
+func TestLintRunJSON(t *testing.T) {
+	config := `apiVersion: mirror.openshift.io/v1alpha1
+kind: ImageSetConfiguration
+storageConfig:
+  local:
+    path: /tmp/metadata
+mirror:
+  ocp:
+    channels:
+    - name: stable-4.12
+`
+	path := filepath.Join(t.TempDir(), "imageset-config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(config), 0600))
+	o := &LintOptions{RootOptions: &cli.RootOptions{}, ConfigPath: path, Output: jsonOutput}
+	out := &bytes.Buffer{}
+	require.NoError(t, o.Run(out))
+
+	var rep lintReport
+	require.NoError(t, json.Unmarshal(out.Bytes(), &rep))
+	require.Equal(t, 0, rep.Errors)
+	require.Equal(t, 2, rep.Warnings)
+	require.Equal(t, "apiVersion", rep.Findings[0].Path)
+	require.Equal(t, "mirror.ocp", rep.Findings[1].Path)
+}
+
+func TestLintValidate(t *testing.T) {
+	o := &LintOptions{RootOptions: &cli.RootOptions{}, Output: tableOutput}
+	require.EqualError(t, o.Validate(), "must specify a configuration file with --config")
+	o.ConfigPath = "imageset-config.yaml"
+	o.Output = "yaml"
+	require.EqualError(t, o.Validate(), `output format "yaml" is not supported: must be table or json`)
+}
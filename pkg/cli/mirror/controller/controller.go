@@ -0,0 +1,609 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/cli"
+	"github.com/openshift/oc-mirror/pkg/exitcode"
+	"github.com/openshift/oc-mirror/pkg/schedule"
+)
+
+const (
+	// imageSetMirrorLabel labels the jobs and config maps of an ImageSetMirror
+	imageSetMirrorLabel = "mirror.openshift.io/imagesetmirror"
+	// imageSetMirrorGenerationAnnotation is the generation of the spec mirrored by a job
+	imageSetMirrorGenerationAnnotation = "mirror.openshift.io/generation"
+	// controllerJobHistory is the number of finished jobs kept per ImageSetMirror
+	controllerJobHistory = 3
+	// controllerConfigDir is where the imageset configuration is mounted in the jobs
+	controllerConfigDir = "/etc/oc-mirror"
+	// controllerAuthDir is where the pull secret is mounted in the jobs
+	controllerAuthDir = "/etc/oc-mirror/auth"
+	// controllerWorkspaceDir is the workspace of the jobs
+	controllerWorkspaceDir = "/workspace"
+	// dockerDestinationPrefix is the scheme of registry destinations
+	dockerDestinationPrefix = "docker://"
+)
+
+// imageSetMirrorResource is the resource of the ImageSetMirror custom resource
+var imageSetMirrorResource = v1alpha2.GroupVersion.WithResource("imagesetmirrors")
+
+// exitCodeReasons are the condition reasons of the exit codes of failed runs
+var exitCodeReasons = map[int]string{
+	exitcode.ConfigError:    "ConfigError",
+	exitcode.AuthError:      "AuthError",
+	exitcode.SequenceError:  "SequenceError",
+	exitcode.PartialFailure: "PartialFailure",
+	exitcode.StorageError:   "StorageError",
+}
+
+// ControllerOptions configures the controller of ImageSetMirror resources
+type ControllerOptions struct {
+	*cli.RootOptions
+	// Namespace restricts the controller to the resources of a namespace
+	Namespace string
+	// JobImage is the oc-mirror image of the jobs of resources without an image
+	JobImage string
+	// ResyncPeriod is the interval between reconciliations
+	ResyncPeriod time.Duration
+
+	now func() time.Time
+}
+
+func NewControllerCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
+	o := ControllerOptions{RootOptions: ro, ResyncPeriod: 30 * time.Second}
+
+	cmd := &cobra.Command{
+		Use:   "controller",
+		Short: "Reconcile ImageSetMirror resources of a management cluster",
+		Long: templates.LongDesc(`
+			Run the controller of ImageSetMirror custom resources, which mirror an
+			imageset configuration to a registry from a management cluster. The
+			controller runs oc-mirror in a job for each run, mirroring directly to
+			the destination registry of the resource, and publishes the progress and
+			outcome of the runs in the status and conditions of the resource.
+
+			A run starts when the spec of a resource changes and on the cron
+			schedule of the resource, in UTC. Runs of a resource do not overlap, and
+			a suspended resource does not start runs. The imageset configuration of a
+			resource must store its metadata in a registry, so each run only mirrors
+			what changed since the previous one.
+
+			* The Running condition is true while a job of the resource is active
+			* The Mirrored condition is true when the last run succeeded, and its reason
+			  is the class of the exit code of a failed run, e.g. AuthError
+
+			The controller uses the kubeconfig of KUBECONFIG, or the service account
+			of its pod when run in a cluster. Install the ImageSetMirror custom
+			resource definition and the manifests of the controller from the deploy
+			directory of the oc-mirror repository.
+		`),
+		Example: templates.Examples(`
+			# Reconcile the ImageSetMirror resources of all namespaces
+			oc-mirror controller --job-image quay.io/example/oc-mirror:latest
+
+			# Reconcile the ImageSetMirror resources of a namespace
+			oc-mirror controller --namespace oc-mirror --job-image quay.io/example/oc-mirror:latest
+		`),
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cli.CheckErr(exitcode.With(exitcode.ConfigError, o.Validate()))
+			cli.CheckErr(o.Run(f))
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&o.Namespace, "namespace", "n", o.Namespace, "Only reconcile the resources of this namespace")
+	fs.StringVar(&o.JobImage, "job-image", o.JobImage, "The oc-mirror image of the jobs of resources without an image")
+	fs.DurationVar(&o.ResyncPeriod, "resync-period", o.ResyncPeriod, "The interval between reconciliations of the resources")
+
+	return cmd
+}
+
+func (o *ControllerOptions) Validate() error {
+	if o.ResyncPeriod <= 0 {
+		return errors.New("--resync-period must be positive")
+	}
+	if o.now == nil {
+		o.now = time.Now
+	}
+	return nil
+}
+
+func (o *ControllerOptions) Run(f kcmdutil.Factory) error {
+	dynamicClient, err := f.DynamicClient()
+	if err != nil {
+		return fmt.Errorf("error creating client: %v", err)
+	}
+	clientset, err := f.KubernetesClientSet()
+	if err != nil {
+		return fmt.Errorf("error creating client: %v", err)
+	}
+	c := &imageSetMirrorClient{dynamic: dynamicClient, kube: clientset}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	logrus.Infof("Reconciling ImageSetMirror resources every %s", o.ResyncPeriod)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		mirrors, err := c.listMirrors(ctx, o.Namespace)
+		if err != nil {
+			logrus.Errorf("error listing ImageSetMirror resources: %v", err)
+			return
+		}
+		for _, ism := range mirrors {
+			if err := o.sync(ctx, c, ism); err != nil {
+				logrus.Errorf("error reconciling ImageSetMirror %s/%s: %v", ism.Namespace, ism.Name, err)
+			}
+		}
+	}, o.ResyncPeriod)
+	logrus.Info("Stopping")
+	return nil
+}
+
+// sync reconciles an ImageSetMirror with its jobs
+func (o *ControllerOptions) sync(ctx context.Context, c *imageSetMirrorClient, ism v1alpha2.ImageSetMirror) error {
+	jobs, err := c.listJobs(ctx, ism)
+	if err != nil {
+		return err
+	}
+	exitCodes := map[string]int{}
+	for _, job := range jobs {
+		if jobFailed(job) {
+			if code, ok := c.jobExitCode(ctx, job); ok {
+				exitCodes[job.Name] = code
+			}
+		}
+	}
+
+	r := o.reconcile(ism, jobs, exitCodes)
+	if r.job != nil {
+		logrus.Infof("Starting job %s/%s of ImageSetMirror %s", ism.Namespace, r.job.Name, ism.Name)
+		if err := c.createRun(ctx, r.configMap, r.job); err != nil {
+			return err
+		}
+	}
+	for _, name := range r.prune {
+		if err := c.deleteRun(ctx, ism.Namespace, name); err != nil {
+			return err
+		}
+	}
+	if equality.Semantic.DeepEqual(ism.Status, r.status) {
+		return nil
+	}
+	ism.Status = r.status
+	return c.updateStatus(ctx, ism)
+}
+
+// reconcileResult is the desired state of an ImageSetMirror and its jobs
+type reconcileResult struct {
+	status v1alpha2.ImageSetMirrorStatus
+	// configMap and job start a run when set
+	configMap *corev1.ConfigMap
+	job       *batchv1.Job
+	// prune are the names of the finished jobs to delete
+	prune []string
+}
+
+// reconcile computes the status of an ImageSetMirror from its jobs,
+// and the run to start when the spec changed or a scheduled run is due.
+// exitCodes are the exit codes of the failed jobs by name.
+func (o *ControllerOptions) reconcile(ism v1alpha2.ImageSetMirror, jobs []batchv1.Job, exitCodes map[string]int) reconcileResult {
+	now := o.now().UTC()
+	r := reconcileResult{status: ism.Status}
+	r.status.Conditions = append([]metav1.Condition(nil), ism.Status.Conditions...)
+	r.status.ObservedGeneration = ism.Generation
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreationTimestamp.Before(&jobs[j].CreationTimestamp)
+	})
+	var latest *batchv1.Job
+	if len(jobs) != 0 {
+		latest = &jobs[len(jobs)-1]
+		r.status.LastRun = jobRun(*latest, exitCodes)
+	}
+	running := latest != nil && !jobFinished(*latest)
+
+	// Finished jobs beyond the history are pruned, latest first
+	var finished []string
+	for i := len(jobs) - 1; i >= 0; i-- {
+		if jobFinished(jobs[i]) {
+			finished = append(finished, jobs[i].Name)
+		}
+	}
+	if len(finished) > controllerJobHistory {
+		r.prune = finished[controllerJobHistory:]
+	}
+
+	if latest != nil && !running {
+		setMirroredCondition(&r.status, *latest, exitCodes, ism.Generation)
+	}
+
+	sched, err := validateImageSetMirror(ism)
+	if err != nil {
+		setCondition(&r.status, v1alpha2.ImageSetMirrorMirrored, metav1.ConditionFalse, "InvalidSpec", err.Error(), ism.Generation)
+		setRunningCondition(&r.status, running, ism.Generation)
+		r.status.NextRunTime = nil
+		return r
+	}
+
+	r.status.NextRunTime = nil
+	if sched != nil && !ism.Spec.Suspend {
+		last := ism.CreationTimestamp.Time
+		if latest != nil {
+			last = latest.CreationTimestamp.Time
+		}
+		if next := sched.Next(last.UTC()); !next.IsZero() {
+			t := metav1.NewTime(next)
+			r.status.NextRunTime = &t
+		}
+	}
+
+	if !running && !ism.Spec.Suspend {
+		// The spec changed since the latest run, or a scheduled run is due
+		due := latest == nil || jobGeneration(*latest) != ism.Generation
+		if r.status.NextRunTime != nil && !r.status.NextRunTime.Time.After(now) {
+			due = true
+		}
+		if due {
+			configMap, job, err := o.runObjects(ism, now)
+			if err != nil {
+				setCondition(&r.status, v1alpha2.ImageSetMirrorMirrored, metav1.ConditionFalse, "InvalidSpec", err.Error(), ism.Generation)
+			} else {
+				r.configMap, r.job = configMap, job
+				start := metav1.NewTime(now)
+				r.status.LastRun = &v1alpha2.ImageSetMirrorRun{JobName: job.Name, Generation: ism.Generation, StartTime: &start}
+				running = true
+				if sched != nil {
+					if next := sched.Next(now); !next.IsZero() {
+						t := metav1.NewTime(next)
+						r.status.NextRunTime = &t
+					}
+				}
+			}
+		}
+	}
+	setRunningCondition(&r.status, running, ism.Generation)
+	return r
+}
+
+// validateImageSetMirror checks that the spec can be mirrored
+// and returns its parsed schedule
+func validateImageSetMirror(ism v1alpha2.ImageSetMirror) (*schedule.Schedule, error) {
+	if ism.Spec.Destination == "" {
+		return nil, errors.New("spec.destination must be set")
+	}
+	if strings.Contains(ism.Spec.Destination, "://") && !strings.HasPrefix(ism.Spec.Destination, dockerDestinationPrefix) {
+		return nil, fmt.Errorf("spec.destination %q must be a registry", ism.Spec.Destination)
+	}
+	if ism.Spec.ImageSet.StorageConfig.Registry == nil {
+		return nil, errors.New("spec.imageSet.storageConfig.registry must be set to keep the metadata of the runs")
+	}
+	// Hooks would run commands in the jobs on behalf of anyone
+	// who can edit the resource
+	if len(ism.Spec.ImageSet.Hooks) != 0 {
+		return nil, errors.New("spec.imageSet.hooks is not supported")
+	}
+	if ism.Spec.Schedule == "" {
+		return nil, nil
+	}
+	sched, err := schedule.Parse(ism.Spec.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid spec.schedule: %v", err)
+	}
+	return sched, nil
+}
+
+// runObjects returns the config map of the imageset configuration
+// and the job of a run of an ImageSetMirror
+func (o *ControllerOptions) runObjects(ism v1alpha2.ImageSetMirror, now time.Time) (*corev1.ConfigMap, *batchv1.Job, error) {
+	image := ism.Spec.Image
+	if image == "" {
+		image = o.JobImage
+	}
+	if image == "" {
+		return nil, nil, errors.New("spec.image must be set since the controller has no --job-image")
+	}
+	config := v1alpha2.ImageSetConfiguration{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1alpha2.GroupVersion.String(),
+			Kind:       v1alpha2.ImageSetConfigurationKind,
+		},
+		ImageSetConfigurationSpec: ism.Spec.ImageSet,
+	}
+	config.Hooks = nil
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error marshaling imageset configuration: %v", err)
+	}
+
+	// Names of jobs are limited to 63 characters since they label their pods
+	name := ism.Name
+	if len(name) > 52 {
+		name = strings.TrimRight(name[:52], "-.")
+	}
+	name = fmt.Sprintf("%s-%d", name, now.Unix())
+	labels := map[string]string{imageSetMirrorLabel: ism.Name}
+	meta := metav1.ObjectMeta{
+		Name:      name,
+		Namespace: ism.Namespace,
+		Labels:    labels,
+		Annotations: map[string]string{
+			imageSetMirrorGenerationAnnotation: strconv.FormatInt(ism.Generation, 10),
+		},
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion:         v1alpha2.GroupVersion.String(),
+			Kind:               v1alpha2.ImageSetMirrorKind,
+			Name:               ism.Name,
+			UID:                ism.UID,
+			Controller:         pointer.BoolPtr(true),
+			BlockOwnerDeletion: pointer.BoolPtr(true),
+		}},
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: *meta.DeepCopy(),
+		Data:       map[string]string{"imageset-config.yaml": string(data)},
+	}
+
+	destination := ism.Spec.Destination
+	if !strings.HasPrefix(destination, dockerDestinationPrefix) {
+		destination = dockerDestinationPrefix + destination
+	}
+	args := []string{
+		"--config", controllerConfigDir + "/imageset-config.yaml",
+		"--dir", controllerWorkspaceDir + "/oc-mirror-workspace",
+	}
+	args = append(append(args, ism.Spec.Args...), destination)
+	container := corev1.Container{
+		Name:       "oc-mirror",
+		Image:      image,
+		Command:    []string{"oc-mirror"},
+		Args:       args,
+		WorkingDir: controllerWorkspaceDir,
+		Env:        []corev1.EnvVar{{Name: "HOME", Value: controllerWorkspaceDir}},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "config", MountPath: controllerConfigDir, ReadOnly: true},
+			{Name: "workspace", MountPath: controllerWorkspaceDir},
+		},
+	}
+	volumes := []corev1.Volume{
+		{Name: "config", VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}},
+		}},
+		{Name: "workspace", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+	}
+	if ism.Spec.PullSecret != "" {
+		container.Env = append(container.Env, corev1.EnvVar{Name: "REGISTRY_AUTH_FILE", Value: controllerAuthDir + "/" + corev1.DockerConfigJsonKey})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: "pull-secret", MountPath: controllerAuthDir, ReadOnly: true})
+		volumes = append(volumes, corev1.Volume{Name: "pull-secret", VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: ism.Spec.PullSecret},
+		}})
+	}
+	job := &batchv1.Job{
+		ObjectMeta: meta,
+		Spec: batchv1.JobSpec{
+			// A failed run is retried by the next scheduled run or spec change
+			BackoffLimit: pointer.Int32Ptr(0),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					RestartPolicy:      corev1.RestartPolicyNever,
+					ServiceAccountName: ism.Spec.ServiceAccountName,
+					Containers:         []corev1.Container{container},
+					Volumes:            volumes,
+				},
+			},
+		},
+	}
+	return configMap, job, nil
+}
+
+// jobRun returns the run of a job
+func jobRun(job batchv1.Job, exitCodes map[string]int) *v1alpha2.ImageSetMirrorRun {
+	run := &v1alpha2.ImageSetMirrorRun{
+		JobName:        job.Name,
+		Generation:     jobGeneration(job),
+		StartTime:      job.Status.StartTime,
+		CompletionTime: job.Status.CompletionTime,
+	}
+	if run.StartTime == nil {
+		t := job.CreationTimestamp
+		run.StartTime = &t
+	}
+	if jobFailed(job) {
+		if code, ok := exitCodes[job.Name]; ok {
+			run.ExitCode = &code
+		}
+		if run.CompletionTime == nil {
+			if c := jobCondition(job, batchv1.JobFailed); c != nil {
+				t := c.LastTransitionTime
+				run.CompletionTime = &t
+			}
+		}
+	}
+	return run
+}
+
+// jobGeneration returns the generation of the spec mirrored by a job
+func jobGeneration(job batchv1.Job) int64 {
+	gen, err := strconv.ParseInt(job.Annotations[imageSetMirrorGenerationAnnotation], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return gen
+}
+
+func jobCondition(job batchv1.Job, typ batchv1.JobConditionType) *batchv1.JobCondition {
+	for i, c := range job.Status.Conditions {
+		if c.Type == typ && c.Status == corev1.ConditionTrue {
+			return &job.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+func jobFailed(job batchv1.Job) bool {
+	return jobCondition(job, batchv1.JobFailed) != nil
+}
+
+func jobFinished(job batchv1.Job) bool {
+	return jobFailed(job) || jobCondition(job, batchv1.JobComplete) != nil
+}
+
+// setMirroredCondition sets the Mirrored condition from the outcome of a finished job
+func setMirroredCondition(status *v1alpha2.ImageSetMirrorStatus, job batchv1.Job, exitCodes map[string]int, generation int64) {
+	if !jobFailed(job) {
+		setCondition(status, v1alpha2.ImageSetMirrorMirrored, metav1.ConditionTrue, "Succeeded",
+			fmt.Sprintf("job %s succeeded", job.Name), generation)
+		status.LastSuccessfulTime = job.Status.CompletionTime
+		return
+	}
+	reason, msg := "Failed", fmt.Sprintf("job %s failed", job.Name)
+	if code, ok := exitCodes[job.Name]; ok {
+		msg = fmt.Sprintf("job %s failed with exit code %d", job.Name, code)
+		if r, ok := exitCodeReasons[code]; ok {
+			reason = r
+		}
+	} else if c := jobCondition(job, batchv1.JobFailed); c != nil && c.Message != "" {
+		msg = fmt.Sprintf("%s: %s", msg, c.Message)
+	}
+	setCondition(status, v1alpha2.ImageSetMirrorMirrored, metav1.ConditionFalse, reason, msg, generation)
+}
+
+func setRunningCondition(status *v1alpha2.ImageSetMirrorStatus, running bool, generation int64) {
+	if running {
+		setCondition(status, v1alpha2.ImageSetMirrorRunning, metav1.ConditionTrue, "JobActive",
+			fmt.Sprintf("job %s is running", status.LastRun.JobName), generation)
+		return
+	}
+	setCondition(status, v1alpha2.ImageSetMirrorRunning, metav1.ConditionFalse, "NoActiveJob", "no job is running", generation)
+}
+
+func setCondition(status *v1alpha2.ImageSetMirrorStatus, typ string, value metav1.ConditionStatus, reason, msg string, generation int64) {
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               typ,
+		Status:             value,
+		Reason:             reason,
+		Message:            msg,
+		ObservedGeneration: generation,
+	})
+}
+
+// imageSetMirrorClient reads and writes ImageSetMirror resources and their jobs
+type imageSetMirrorClient struct {
+	dynamic dynamic.Interface
+	kube    kubernetes.Interface
+}
+
+func (c *imageSetMirrorClient) listMirrors(ctx context.Context, namespace string) ([]v1alpha2.ImageSetMirror, error) {
+	list, err := c.dynamic.Resource(imageSetMirrorResource).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var mirrors []v1alpha2.ImageSetMirror
+	for _, u := range list.Items {
+		var ism v1alpha2.ImageSetMirror
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &ism); err != nil {
+			logrus.Errorf("error decoding ImageSetMirror %s/%s: %v", u.GetNamespace(), u.GetName(), err)
+			continue
+		}
+		mirrors = append(mirrors, ism)
+	}
+	return mirrors, nil
+}
+
+func (c *imageSetMirrorClient) updateStatus(ctx context.Context, ism v1alpha2.ImageSetMirror) error {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&ism)
+	if err != nil {
+		return err
+	}
+	_, err = c.dynamic.Resource(imageSetMirrorResource).Namespace(ism.Namespace).
+		UpdateStatus(ctx, &unstructured.Unstructured{Object: obj}, metav1.UpdateOptions{})
+	if apierrors.IsConflict(err) {
+		// The resource changed since it was listed and is reconciled again at the next resync
+		return nil
+	}
+	return err
+}
+
+func (c *imageSetMirrorClient) listJobs(ctx context.Context, ism v1alpha2.ImageSetMirror) ([]batchv1.Job, error) {
+	list, err := c.kube.BatchV1().Jobs(ism.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: imageSetMirrorLabel + "=" + ism.Name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing jobs: %v", err)
+	}
+	var jobs []batchv1.Job
+	for _, job := range list.Items {
+		if metav1.IsControlledBy(&job, &ism) {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+// jobExitCode returns the exit code of the oc-mirror container of a job
+func (c *imageSetMirrorClient) jobExitCode(ctx context.Context, job batchv1.Job) (int, bool) {
+	pods, err := c.kube.CoreV1().Pods(job.Namespace).List(ctx, metav1.ListOptions{LabelSelector: "job-name=" + job.Name})
+	if err != nil {
+		logrus.Warnf("error listing pods of job %s/%s: %v", job.Namespace, job.Name, err)
+		return 0, false
+	}
+	for _, pod := range pods.Items {
+		for _, s := range pod.Status.ContainerStatuses {
+			if s.Name == "oc-mirror" && s.State.Terminated != nil && s.State.Terminated.ExitCode != 0 {
+				return int(s.State.Terminated.ExitCode), true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (c *imageSetMirrorClient) createRun(ctx context.Context, configMap *corev1.ConfigMap, job *batchv1.Job) error {
+	if _, err := c.kube.CoreV1().ConfigMaps(configMap.Namespace).Create(ctx, configMap, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("error creating config map: %v", err)
+	}
+	if _, err := c.kube.BatchV1().Jobs(job.Namespace).Create(ctx, job, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("error creating job: %v", err)
+	}
+	return nil
+}
+
+func (c *imageSetMirrorClient) deleteRun(ctx context.Context, namespace, name string) error {
+	propagation := metav1.DeletePropagationBackground
+	err := c.kube.BatchV1().Jobs(namespace).Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("error deleting job: %v", err)
+	}
+	err = c.kube.CoreV1().ConfigMaps(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("error deleting config map: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+func TestControllerReconcile(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	created := metav1.NewTime(now.Add(-24 * time.Hour))
+
+	newMirror := func(generation int64, spec func(*v1alpha2.ImageSetMirrorSpec)) v1alpha2.ImageSetMirror {
+		ism := v1alpha2.ImageSetMirror{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "ocp",
+				Namespace:         "mirror",
+				UID:               "uid",
+				Generation:        generation,
+				CreationTimestamp: created,
+			},
+			Spec: v1alpha2.ImageSetMirrorSpec{
+				Destination: "registry.example.com:5000/mirror",
+				ImageSet: v1alpha2.ImageSetConfigurationSpec{
+					StorageConfig: v1alpha2.StorageConfig{
+						Registry: &v1alpha2.RegistryConfig{ImageURL: "registry.example.com:5000/mirror/metadata:latest"},
+					},
+				},
+			},
+		}
+		if spec != nil {
+			spec(&ism.Spec)
+		}
+		return ism
+	}
+	newJob := func(name string, generation string, age time.Duration, condition batchv1.JobConditionType) batchv1.Job {
+		job := batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+				Annotations:       map[string]string{imageSetMirrorGenerationAnnotation: generation},
+			},
+		}
+		if condition != "" {
+			completed := metav1.NewTime(now.Add(-age + time.Minute))
+			job.Status.CompletionTime = &completed
+			job.Status.Conditions = []batchv1.JobCondition{{Type: condition, Status: corev1.ConditionTrue}}
+		}
+		return job
+	}
+
+	type spec struct {
+		name      string
+		mirror    v1alpha2.ImageSetMirror
+		jobs      []batchv1.Job
+		exitCodes map[string]int
+		// expJob is whether a run is started
+		expJob      bool
+		expRunning  metav1.ConditionStatus
+		expMirrored string
+		expLastRun  string
+		expNextRun  *time.Time
+		expPrune    []string
+	}
+	nextRun := time.Date(2022, 6, 2, 2, 0, 0, 0, time.UTC)
+	cases := []spec{
+		{
+			name:       "Valid/NewResource",
+			mirror:     newMirror(1, nil),
+			expJob:     true,
+			expRunning: metav1.ConditionTrue,
+		},
+		{
+			name:       "Valid/JobRunning",
+			mirror:     newMirror(1, nil),
+			jobs:       []batchv1.Job{newJob("ocp-1", "1", time.Minute, "")},
+			expRunning: metav1.ConditionTrue,
+			expLastRun: "ocp-1",
+		},
+		{
+			name:        "Valid/JobSucceeded",
+			mirror:      newMirror(1, nil),
+			jobs:        []batchv1.Job{newJob("ocp-1", "1", time.Hour, batchv1.JobComplete)},
+			expRunning:  metav1.ConditionFalse,
+			expMirrored: "Succeeded",
+			expLastRun:  "ocp-1",
+		},
+		{
+			name:        "Valid/JobFailedNotRetried",
+			mirror:      newMirror(1, nil),
+			jobs:        []batchv1.Job{newJob("ocp-1", "1", time.Hour, batchv1.JobFailed)},
+			exitCodes:   map[string]int{"ocp-1": 3},
+			expRunning:  metav1.ConditionFalse,
+			expMirrored: "AuthError",
+			expLastRun:  "ocp-1",
+		},
+		{
+			name:        "Valid/SpecChanged",
+			mirror:      newMirror(2, nil),
+			jobs:        []batchv1.Job{newJob("ocp-1", "1", time.Hour, batchv1.JobComplete)},
+			expJob:      true,
+			expRunning:  metav1.ConditionTrue,
+			expMirrored: "Succeeded",
+		},
+		{
+			name: "Valid/ScheduledRunDue",
+			mirror: newMirror(1, func(s *v1alpha2.ImageSetMirrorSpec) {
+				s.Schedule = "0 2 * * *"
+			}),
+			jobs:        []batchv1.Job{newJob("ocp-1", "1", 24*time.Hour, batchv1.JobComplete)},
+			expJob:      true,
+			expRunning:  metav1.ConditionTrue,
+			expMirrored: "Succeeded",
+			expNextRun:  &nextRun,
+		},
+		{
+			name: "Valid/ScheduledRunNotDue",
+			mirror: newMirror(1, func(s *v1alpha2.ImageSetMirrorSpec) {
+				s.Schedule = "0 2 * * *"
+			}),
+			jobs:        []batchv1.Job{newJob("ocp-1", "1", 9*time.Hour, batchv1.JobComplete)},
+			expRunning:  metav1.ConditionFalse,
+			expMirrored: "Succeeded",
+			expLastRun:  "ocp-1",
+			expNextRun:  &nextRun,
+		},
+		{
+			name: "Valid/Suspended",
+			mirror: newMirror(2, func(s *v1alpha2.ImageSetMirrorSpec) {
+				s.Suspend = true
+			}),
+			jobs:        []batchv1.Job{newJob("ocp-1", "1", time.Hour, batchv1.JobComplete)},
+			expRunning:  metav1.ConditionFalse,
+			expMirrored: "Succeeded",
+			expLastRun:  "ocp-1",
+		},
+		{
+			name: "Valid/PruneHistory",
+			mirror: newMirror(1, func(s *v1alpha2.ImageSetMirrorSpec) {
+				s.Schedule = "0 2 * * *"
+			}),
+			jobs: []batchv1.Job{
+				newJob("ocp-2", "1", 4*time.Hour, batchv1.JobFailed),
+				newJob("ocp-4", "1", 2*time.Hour, batchv1.JobComplete),
+				newJob("ocp-1", "1", 5*time.Hour, batchv1.JobComplete),
+				newJob("ocp-5", "1", time.Minute, ""),
+				newJob("ocp-3", "1", 3*time.Hour, batchv1.JobComplete),
+			},
+			expRunning: metav1.ConditionTrue,
+			expLastRun: "ocp-5",
+			expNextRun: &nextRun,
+			expPrune:   []string{"ocp-1"},
+		},
+		{
+			name: "Invalid/NoRegistryStorage",
+			mirror: newMirror(1, func(s *v1alpha2.ImageSetMirrorSpec) {
+				s.ImageSet.StorageConfig = v1alpha2.StorageConfig{Local: &v1alpha2.LocalConfig{Path: "/metadata"}}
+			}),
+			expRunning:  metav1.ConditionFalse,
+			expMirrored: "InvalidSpec",
+		},
+		{
+			name: "Invalid/Schedule",
+			mirror: newMirror(1, func(s *v1alpha2.ImageSetMirrorSpec) {
+				s.Schedule = "0 25 * * *"
+			}),
+			expRunning:  metav1.ConditionFalse,
+			expMirrored: "InvalidSpec",
+		},
+		{
+			name: "Invalid/Destination",
+			mirror: newMirror(1, func(s *v1alpha2.ImageSetMirrorSpec) {
+				s.Destination = "file://archives"
+			}),
+			expRunning:  metav1.ConditionFalse,
+			expMirrored: "InvalidSpec",
+		},
+		{
+			name: "Invalid/Hooks",
+			mirror: newMirror(1, func(s *v1alpha2.ImageSetMirrorSpec) {
+				s.ImageSet.Hooks = []v1alpha2.Hook{{Phase: v1alpha2.HookPhasePlan, When: v1alpha2.HookBefore, Command: []string{"true"}}}
+			}),
+			expRunning:  metav1.ConditionFalse,
+			expMirrored: "InvalidSpec",
+		},
+		{
+			name:        "Invalid/NoImage",
+			mirror:      newMirror(1, nil),
+			expRunning:  metav1.ConditionFalse,
+			expMirrored: "InvalidSpec",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			o := &ControllerOptions{JobImage: "quay.io/example/oc-mirror:latest", now: func() time.Time { return now }}
+			if c.name == "Invalid/NoImage" {
+				o.JobImage = ""
+			}
+			r := o.reconcile(c.mirror, c.jobs, c.exitCodes)
+
+			require.Equal(t, c.mirror.Generation, r.status.ObservedGeneration)
+			if c.expJob {
+				require.NotNil(t, r.job)
+				require.Equal(t, "ocp-1654084800", r.job.Name)
+				require.Equal(t, c.mirror.Generation, jobGeneration(*r.job))
+				require.Equal(t, r.job.Name, r.status.LastRun.JobName)
+				require.Equal(t, r.job.Name, r.configMap.Name)
+			} else {
+				require.Nil(t, r.job)
+				if c.expLastRun == "" {
+					require.Nil(t, r.status.LastRun)
+				} else {
+					require.Equal(t, c.expLastRun, r.status.LastRun.JobName)
+				}
+			}
+
+			running := meta.FindStatusCondition(r.status.Conditions, v1alpha2.ImageSetMirrorRunning)
+			require.NotNil(t, running)
+			require.Equal(t, c.expRunning, running.Status)
+			mirrored := meta.FindStatusCondition(r.status.Conditions, v1alpha2.ImageSetMirrorMirrored)
+			if c.expMirrored == "" {
+				require.Nil(t, mirrored)
+			} else {
+				require.NotNil(t, mirrored)
+				require.Equal(t, c.expMirrored, mirrored.Reason)
+				require.Equal(t, c.expMirrored == "Succeeded", mirrored.Status == metav1.ConditionTrue)
+			}
+
+			if c.expNextRun == nil {
+				require.Nil(t, r.status.NextRunTime)
+			} else {
+				require.NotNil(t, r.status.NextRunTime)
+				require.Equal(t, *c.expNextRun, r.status.NextRunTime.Time)
+			}
+			require.Equal(t, c.expPrune, r.prune)
+		})
+	}
+}
+
+func TestControllerReconcileFailedExitCode(t *testing.T) {
+	o := &ControllerOptions{JobImage: "oc-mirror", now: time.Now}
+	ism := v1alpha2.ImageSetMirror{ObjectMeta: metav1.ObjectMeta{Name: "ocp", Generation: 1}}
+	ism.Spec.Destination = "registry.example.com"
+	ism.Spec.ImageSet.StorageConfig.Registry = &v1alpha2.RegistryConfig{ImageURL: "registry.example.com/metadata"}
+	job := batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+		Name:        "ocp-1",
+		Annotations: map[string]string{imageSetMirrorGenerationAnnotation: "1"},
+	}}
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "BackoffLimitExceeded"}}
+
+	r := o.reconcile(ism, []batchv1.Job{job}, map[string]int{"ocp-1": 5})
+	require.Equal(t, 5, *r.status.LastRun.ExitCode)
+	mirrored := meta.FindStatusCondition(r.status.Conditions, v1alpha2.ImageSetMirrorMirrored)
+	require.Equal(t, "PartialFailure", mirrored.Reason)
+	require.Equal(t, "job ocp-1 failed with exit code 5", mirrored.Message)
+
+	r = o.reconcile(ism, []batchv1.Job{job}, nil)
+	require.Nil(t, r.status.LastRun.ExitCode)
+	mirrored = meta.FindStatusCondition(r.status.Conditions, v1alpha2.ImageSetMirrorMirrored)
+	require.Equal(t, "Failed", mirrored.Reason)
+	require.Equal(t, "job ocp-1 failed: BackoffLimitExceeded", mirrored.Message)
+}
+
+func TestControllerRunObjects(t *testing.T) {
+	o := &ControllerOptions{JobImage: "quay.io/example/oc-mirror:latest"}
+	ism := v1alpha2.ImageSetMirror{
+		ObjectMeta: metav1.ObjectMeta{Name: strings.Repeat("a", 60), Namespace: "mirror", UID: "uid", Generation: 3},
+		Spec: v1alpha2.ImageSetMirrorSpec{
+			Destination: "docker://registry.example.com:5000/mirror",
+			PullSecret:  "pull-secret",
+			Args:        []string{"--continue-on-error"},
+			ImageSet: v1alpha2.ImageSetConfigurationSpec{
+				StorageConfig: v1alpha2.StorageConfig{
+					Registry: &v1alpha2.RegistryConfig{ImageURL: "registry.example.com:5000/mirror/metadata:latest"},
+				},
+			},
+		},
+	}
+	configMap, job, err := o.runObjects(ism, time.Unix(1654084800, 0))
+	require.NoError(t, err)
+
+	require.Equal(t, strings.Repeat("a", 52)+"-1654084800", job.Name)
+	require.LessOrEqual(t, len(job.Name), 63)
+	require.Equal(t, "3", job.Annotations[imageSetMirrorGenerationAnnotation])
+	require.Equal(t, ism.Name, job.Labels[imageSetMirrorLabel])
+	require.True(t, metav1.IsControlledBy(job, &ism))
+	require.True(t, metav1.IsControlledBy(configMap, &ism))
+
+	container := job.Spec.Template.Spec.Containers[0]
+	require.Equal(t, "quay.io/example/oc-mirror:latest", container.Image)
+	require.Equal(t, []string{"oc-mirror"}, container.Command)
+	require.Equal(t, []string{
+		"--config", "/etc/oc-mirror/imageset-config.yaml",
+		"--dir", "/workspace/oc-mirror-workspace",
+		"--continue-on-error",
+		"docker://registry.example.com:5000/mirror",
+	}, container.Args)
+	require.Contains(t, container.Env, corev1.EnvVar{Name: "REGISTRY_AUTH_FILE", Value: "/etc/oc-mirror/auth/.dockerconfigjson"})
+	require.Equal(t, "pull-secret", job.Spec.Template.Spec.Volumes[2].Secret.SecretName)
+	require.Equal(t, job.Name, job.Spec.Template.Spec.Volumes[0].ConfigMap.Name)
+
+	var config v1alpha2.ImageSetConfiguration
+	require.NoError(t, yaml.Unmarshal([]byte(configMap.Data["imageset-config.yaml"]), &config))
+	require.Equal(t, v1alpha2.ImageSetConfigurationKind, config.Kind)
+	require.Equal(t, ism.Spec.ImageSet, config.ImageSetConfigurationSpec)
+
+	// Hooks are never written to the configuration of the jobs
+	ism.Spec.ImageSet.Hooks = []v1alpha2.Hook{{Phase: v1alpha2.HookPhasePlan, When: v1alpha2.HookBefore, Command: []string{"true"}}}
+	configMap, _, err = o.runObjects(ism, time.Unix(1654084800, 0))
+	require.NoError(t, err)
+	config = v1alpha2.ImageSetConfiguration{}
+	require.NoError(t, yaml.Unmarshal([]byte(configMap.Data["imageset-config.yaml"]), &config))
+	require.Empty(t, config.Hooks)
+}
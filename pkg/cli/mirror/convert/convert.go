@@ -0,0 +1,29 @@
+package convert
+
+import (
+	"github.com/spf13/cobra"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift/oc-mirror/pkg/cli"
+)
+
+func NewConvertCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
+
+	cmd := &cobra.Command{
+		Use:   "convert",
+		Short: "Convert previously generated manifests to newer APIs",
+		Example: templates.Examples(`
+			# Convert the ICSPs in a results directory to IDMS and ITMS manifests
+			oc-mirror convert icsp-to-idms oc-mirror-workspace/results-1639608409
+
+			# Convert the ICSPs of the current cluster
+			oc-mirror convert icsp-to-idms --from-cluster
+		`),
+		Run: kcmdutil.DefaultSubCommandRun(ro.IOStreams.ErrOut),
+	}
+
+	cmd.AddCommand(NewICSPToIDMSCommand(f, ro))
+
+	return cmd
+}
@@ -0,0 +1,315 @@
+package convert
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	operatorv1alpha1 "github.com/openshift/api/operator/v1alpha1"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/oc-mirror/pkg/cli"
+)
+
+const (
+	idmsFile = "imageDigestMirrorSet.yaml"
+	itmsFile = "imageTagMirrorSet.yaml"
+)
+
+var icspResource = schema.GroupVersionResource{
+	Group:    operatorv1alpha1.GroupName,
+	Version:  operatorv1alpha1.GroupVersion.Version,
+	Resource: "imagecontentsourcepolicies",
+}
+
+// ImageDigestMirrorSet and ImageTagMirrorSet are the config.openshift.io/v1
+// replacements of ImageContentSourcePolicy. They are defined here until
+// the vendored openshift/api provides them.
+type ImageDigestMirrorSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              ImageDigestMirrorSetSpec `json:"spec"`
+}
+
+type ImageDigestMirrorSetSpec struct {
+	ImageDigestMirrors []ImageMirrors `json:"imageDigestMirrors"`
+}
+
+type ImageTagMirrorSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              ImageTagMirrorSetSpec `json:"spec"`
+}
+
+type ImageTagMirrorSetSpec struct {
+	ImageTagMirrors []ImageMirrors `json:"imageTagMirrors"`
+}
+
+// ImageMirrors are the mirrors of a source repository
+type ImageMirrors struct {
+	Source  string   `json:"source"`
+	Mirrors []string `json:"mirrors,omitempty"`
+}
+
+type ICSPToIDMSOptions struct {
+	*cli.RootOptions
+	Path        string
+	FromCluster bool
+	OutputDir   string
+	SkipITMS    bool
+}
+
+func NewICSPToIDMSCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
+	o := ICSPToIDMSOptions{}
+	o.RootOptions = ro
+
+	cmd := &cobra.Command{
+		Use:   "icsp-to-idms <dir-or-file>",
+		Short: "Convert ImageContentSourcePolicies to ImageDigestMirrorSets and ImageTagMirrorSets",
+		Example: templates.Examples(`
+			# Print the IDMS and ITMS for the ICSPs in a results directory
+			oc-mirror convert icsp-to-idms oc-mirror-workspace/results-1639608409
+
+			# Write the IDMS and ITMS for the ICSPs of the current cluster to a directory
+			oc-mirror convert icsp-to-idms --from-cluster --output-dir ./idms
+		`),
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckErr(o.Complete(args))
+			kcmdutil.CheckErr(o.Validate())
+			kcmdutil.CheckErr(o.Run(cmd.Context(), f))
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.BoolVar(&o.FromCluster, "from-cluster", o.FromCluster, "Convert the ICSPs of the cluster in the current kubeconfig")
+	fs.StringVar(&o.OutputDir, "output-dir", o.OutputDir, "Directory to write the IDMS and ITMS manifests to. "+
+		"The manifests are printed to stdout if not set")
+	fs.BoolVar(&o.SkipITMS, "skip-itms", o.SkipITMS, "Do not generate ImageTagMirrorSets. ICSPs only apply to "+
+		"pulls by digest, so only ImageDigestMirrorSets are equivalent")
+	o.BindFlags(cmd.PersistentFlags())
+
+	return cmd
+}
+
+func (o *ICSPToIDMSOptions) Complete(args []string) error {
+	if len(args) == 1 {
+		o.Path = args[0]
+	}
+	return nil
+}
+
+func (o *ICSPToIDMSOptions) Validate() error {
+	if o.FromCluster && o.Path != "" {
+		return errors.New("must specify a directory or file or --from-cluster, not both")
+	}
+	if !o.FromCluster && o.Path == "" {
+		return errors.New("must specify a directory or file or --from-cluster")
+	}
+	return nil
+}
+
+func (o *ICSPToIDMSOptions) Run(ctx context.Context, f kcmdutil.Factory) error {
+	var icsps []operatorv1alpha1.ImageContentSourcePolicy
+	var err error
+	if o.FromCluster {
+		icsps, err = readClusterICSPs(ctx, f)
+	} else {
+		icsps, err = readICSPs(o.Path)
+	}
+	if err != nil {
+		return err
+	}
+	if len(icsps) == 0 {
+		logrus.Warn("No ImageContentSourcePolicies found to convert")
+		return nil
+	}
+
+	idmsList, itmsList := ConvertICSPs(icsps)
+	var idmsObjs, itmsObjs []interface{}
+	for i := range idmsList {
+		idmsObjs = append(idmsObjs, &idmsList[i])
+		if !o.SkipITMS {
+			itmsObjs = append(itmsObjs, &itmsList[i])
+		}
+	}
+	idmsData, err := marshalManifests(idmsObjs)
+	if err != nil {
+		return err
+	}
+	itmsData, err := marshalManifests(itmsObjs)
+	if err != nil {
+		return err
+	}
+
+	if o.OutputDir == "" {
+		fmt.Fprint(o.Out, string(idmsData))
+		fmt.Fprint(o.Out, string(itmsData))
+		return nil
+	}
+
+	if err := os.MkdirAll(o.OutputDir, 0750); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(o.OutputDir, idmsFile), idmsData, 0640); err != nil {
+		return fmt.Errorf("error writing ImageDigestMirrorSets: %v", err)
+	}
+	if !o.SkipITMS {
+		if err := ioutil.WriteFile(filepath.Join(o.OutputDir, itmsFile), itmsData, 0640); err != nil {
+			return fmt.Errorf("error writing ImageTagMirrorSets: %v", err)
+		}
+	}
+	logrus.Infof("Wrote %d converted ImageContentSourcePolicies to %s", len(icsps), o.OutputDir)
+	return nil
+}
+
+// ConvertICSPs converts each ImageContentSourcePolicy to an ImageDigestMirrorSet
+// and an ImageTagMirrorSet of the same name and mirrors
+func ConvertICSPs(icsps []operatorv1alpha1.ImageContentSourcePolicy) ([]ImageDigestMirrorSet, []ImageTagMirrorSet) {
+	sort.Slice(icsps, func(i, j int) bool {
+		return icsps[i].Name < icsps[j].Name
+	})
+
+	idmsList := make([]ImageDigestMirrorSet, 0, len(icsps))
+	itmsList := make([]ImageTagMirrorSet, 0, len(icsps))
+	for _, icsp := range icsps {
+		var mirrors []ImageMirrors
+		for _, rdm := range icsp.Spec.RepositoryDigestMirrors {
+			mirrors = append(mirrors, ImageMirrors{Source: rdm.Source, Mirrors: rdm.Mirrors})
+		}
+		meta := metav1.ObjectMeta{
+			Name:   icsp.Name,
+			Labels: icsp.Labels,
+		}
+		idmsList = append(idmsList, ImageDigestMirrorSet{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "config.openshift.io/v1", Kind: "ImageDigestMirrorSet"},
+			ObjectMeta: meta,
+			Spec:       ImageDigestMirrorSetSpec{ImageDigestMirrors: mirrors},
+		})
+		itmsList = append(itmsList, ImageTagMirrorSet{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "config.openshift.io/v1", Kind: "ImageTagMirrorSet"},
+			ObjectMeta: meta,
+			Spec:       ImageTagMirrorSetSpec{ImageTagMirrors: mirrors},
+		})
+	}
+	return idmsList, itmsList
+}
+
+// readICSPs reads the ImageContentSourcePolicies in the file at path
+// or in the YAML and JSON files of the directory at path
+func readICSPs(path string) ([]operatorv1alpha1.ImageContentSourcePolicy, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		files = nil
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			switch strings.ToLower(filepath.Ext(entry.Name())) {
+			case ".yaml", ".yml", ".json":
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+
+	var icsps []operatorv1alpha1.ImageContentSourcePolicy
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		fileICSPs, err := decodeICSPs(data)
+		if err != nil {
+			return nil, fmt.Errorf("error reading manifest %s: %v", file, err)
+		}
+		icsps = append(icsps, fileICSPs...)
+	}
+	return icsps, nil
+}
+
+// decodeICSPs decodes the ImageContentSourcePolicies in a multi-document
+// YAML or JSON manifest, skipping objects of other kinds
+func decodeICSPs(data []byte) ([]operatorv1alpha1.ImageContentSourcePolicy, error) {
+	var icsps []operatorv1alpha1.ImageContentSourcePolicy
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return icsps, nil
+			}
+			return nil, err
+		}
+		if obj.GetKind() != "ImageContentSourcePolicy" || obj.GroupVersionKind().Group != operatorv1alpha1.GroupName {
+			continue
+		}
+		var icsp operatorv1alpha1.ImageContentSourcePolicy
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &icsp); err != nil {
+			return nil, err
+		}
+		icsps = append(icsps, icsp)
+	}
+}
+
+// readClusterICSPs lists the ImageContentSourcePolicies of the cluster configured by the factory
+func readClusterICSPs(ctx context.Context, f kcmdutil.Factory) ([]operatorv1alpha1.ImageContentSourcePolicy, error) {
+	client, err := f.DynamicClient()
+	if err != nil {
+		return nil, fmt.Errorf("error creating cluster client: %v", err)
+	}
+	list, err := client.Resource(icspResource).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing ImageContentSourcePolicies: %v", err)
+	}
+	icsps := make([]operatorv1alpha1.ImageContentSourcePolicy, len(list.Items))
+	for i, item := range list.Items {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &icsps[i]); err != nil {
+			return nil, err
+		}
+	}
+	return icsps, nil
+}
+
+// marshalManifests marshals objs to a multi-document YAML manifest
+func marshalManifests(objs []interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, obj := range objs {
+		// Convert to unstructured for removing creationTimestamp
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, fmt.Errorf("error converting to unstructured: %v", err)
+		}
+		delete(u["metadata"].(map[string]interface{}), "creationTimestamp")
+		data, err := yaml.Marshal(u)
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal manifest: %v", err)
+		}
+		buf.WriteString("---\n")
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
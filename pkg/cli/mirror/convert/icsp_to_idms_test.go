@@ -0,0 +1,153 @@
+package convert
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/openshift/oc-mirror/pkg/cli"
+)
+
+func TestICSPToIDMSRun(t *testing.T) {
+	icsps := `---
+apiVersion: operator.openshift.io/v1alpha1
+kind: ImageContentSourcePolicy
+metadata:
+  labels:
+    operators.openshift.org/catalog: "true"
+  name: operator-0
+spec:
+  repositoryDigestMirrors:
+  - mirrors:
+    - reg.mirror.com/ubi8/ubi
+    source: registry.redhat.io/ubi8/ubi
+---
+apiVersion: operator.openshift.io/v1alpha1
+kind: ImageContentSourcePolicy
+metadata:
+  name: release-0
+spec:
+  repositoryDigestMirrors:
+  - mirrors:
+    - reg.mirror.com/openshift/release-images
+    source: quay.io/openshift-release-dev/ocp-release
+`
+	catalogSource := `apiVersion: operators.coreos.com/v1alpha1
+kind: CatalogSource
+metadata:
+  name: redhat-operator-index
+spec:
+  image: reg.mirror.com/redhat/redhat-operator-index:v4.9
+`
+	expIDMS := `---
+apiVersion: config.openshift.io/v1
+kind: ImageDigestMirrorSet
+metadata:
+  labels:
+    operators.openshift.org/catalog: "true"
+  name: operator-0
+spec:
+  imageDigestMirrors:
+  - mirrors:
+    - reg.mirror.com/ubi8/ubi
+    source: registry.redhat.io/ubi8/ubi
+---
+apiVersion: config.openshift.io/v1
+kind: ImageDigestMirrorSet
+metadata:
+  name: release-0
+spec:
+  imageDigestMirrors:
+  - mirrors:
+    - reg.mirror.com/openshift/release-images
+    source: quay.io/openshift-release-dev/ocp-release
+`
+	expITMS := `---
+apiVersion: config.openshift.io/v1
+kind: ImageTagMirrorSet
+metadata:
+  labels:
+    operators.openshift.org/catalog: "true"
+  name: operator-0
+spec:
+  imageTagMirrors:
+  - mirrors:
+    - reg.mirror.com/ubi8/ubi
+    source: registry.redhat.io/ubi8/ubi
+---
+apiVersion: config.openshift.io/v1
+kind: ImageTagMirrorSet
+metadata:
+  name: release-0
+spec:
+  imageTagMirrors:
+  - mirrors:
+    - reg.mirror.com/openshift/release-images
+    source: quay.io/openshift-release-dev/ocp-release
+`
+
+	resultsDir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(resultsDir, "imageContentSourcePolicy.yaml"), []byte(icsps), 0640))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(resultsDir, "catalogSource-redhat-operator-index.yaml"), []byte(catalogSource), 0640))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(resultsDir, "mapping.txt"), []byte("a=b"), 0640))
+
+	tests := []struct {
+		name     string
+		opts     ICSPToIDMSOptions
+		expOut   string
+		expFiles map[string]string
+		expError string
+	}{
+		{
+			name:   "Valid/Stdout",
+			opts:   ICSPToIDMSOptions{Path: resultsDir},
+			expOut: expIDMS + expITMS,
+		},
+		{
+			name:   "Valid/File",
+			opts:   ICSPToIDMSOptions{Path: filepath.Join(resultsDir, "imageContentSourcePolicy.yaml"), SkipITMS: true},
+			expOut: expIDMS,
+		},
+		{
+			name:     "Valid/OutputDir",
+			opts:     ICSPToIDMSOptions{Path: resultsDir, OutputDir: filepath.Join(t.TempDir(), "idms")},
+			expFiles: map[string]string{idmsFile: expIDMS, itmsFile: expITMS},
+		},
+		{
+			name:     "Invalid/MissingPath",
+			opts:     ICSPToIDMSOptions{Path: filepath.Join(resultsDir, "missing")},
+			expError: "no such file or directory",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			test.opts.RootOptions = &cli.RootOptions{IOStreams: genericclioptions.IOStreams{Out: out}}
+			require.NoError(t, test.opts.Validate())
+			err := test.opts.Run(context.Background(), nil)
+			if test.expError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), test.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expOut, out.String())
+			for name, exp := range test.expFiles {
+				data, err := ioutil.ReadFile(filepath.Join(test.opts.OutputDir, name))
+				require.NoError(t, err)
+				require.Equal(t, exp, string(data))
+			}
+		})
+	}
+}
+
+func TestICSPToIDMSValidate(t *testing.T) {
+	require.Error(t, (&ICSPToIDMSOptions{}).Validate())
+	require.Error(t, (&ICSPToIDMSOptions{Path: "results", FromCluster: true}).Validate())
+	require.NoError(t, (&ICSPToIDMSOptions{FromCluster: true}).Validate())
+}
@@ -0,0 +1,294 @@
+package diff
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mholt/archiver/v3"
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/archive"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/metadata/storage"
+)
+
+// workspaceDir is the name of the workspace in a mirror directory
+const workspaceDir = "oc-mirror-workspace"
+
+// content is the content of an imageset configuration, an imageset, or
+// the metadata of a workspace. Configurations only declare content, while
+// the metadata of imagesets and workspaces also records the images mirrored.
+type content struct {
+	// releases describes the release channels and payloads by name
+	releases map[string]string
+	// operators describes the operator catalogs, and
+	// the packages of catalogs as "<catalog> <package>"
+	operators map[string]string
+	// additionalImages are the additional images
+	additionalImages map[string]string
+	// charts describes the Helm charts by repository and name, or path
+	charts map[string]string
+	// images are the mirrored images with the digests of
+	// their blobs, nil when the images are not resolved
+	images map[string]resolvedImage
+	// blobSizes are the sizes of the blobs by digest that are available
+	blobSizes map[string]int64
+}
+
+// resolvedImage is an image recorded in metadata
+type resolvedImage struct {
+	imageType v1alpha2.ImageType
+	blobs     map[string]struct{}
+}
+
+// loadContent loads the content of an imageset configuration file, an imageset
+// archive or a directory of archives, or a workspace, which can be the workspace
+// directory, a mirror directory containing one, or a local storage directory
+func loadContent(ctx context.Context, path string) (*content, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			cfg, err := config.ReadConfig(path)
+			if err != nil {
+				return nil, err
+			}
+			return newContent(cfg.Mirror), nil
+		case ".tar":
+			return loadArchives(ctx, []string{path})
+		default:
+			return nil, fmt.Errorf("%s is not an imageset configuration or archive", path)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(path, workspaceDir)); err == nil {
+		path = filepath.Join(path, workspaceDir)
+	}
+	for _, dir := range []string{filepath.Join(path, config.SourceDir), path} {
+		if _, err := os.Stat(filepath.Join(dir, config.MetadataBasePath)); err == nil {
+			return loadWorkspace(ctx, dir)
+		}
+	}
+	archives, err := filepath.Glob(filepath.Join(path, "*.tar"))
+	if err != nil {
+		return nil, err
+	}
+	if len(archives) == 0 {
+		return nil, fmt.Errorf("no workspace metadata or archives found in directory %s", path)
+	}
+	return loadArchives(ctx, archives)
+}
+
+// newContent returns the content declared by the mirror configuration
+func newContent(mirror v1alpha2.Mirror) *content {
+	c := &content{
+		releases:         map[string]string{},
+		operators:        map[string]string{},
+		additionalImages: map[string]string{},
+		charts:           map[string]string{},
+		blobSizes:        map[string]int64{},
+	}
+	for _, ch := range mirror.Platform.Channels {
+		c.releases[ch.Name] = describeChannel(ch)
+	}
+	for _, release := range mirror.Platform.Releases {
+		c.releases[release.Name] = "payload"
+	}
+	for _, ctlg := range mirror.Operators {
+		mode := "heads only"
+		if ctlg.Full {
+			mode = "full"
+		}
+		if len(ctlg.Packages) == 0 {
+			mode += ", all packages"
+		}
+		c.operators[ctlg.Catalog] = mode
+		for _, pkg := range ctlg.Packages {
+			c.operators[ctlg.Catalog+" "+pkg.Name] = describePackage(pkg)
+		}
+	}
+	for _, img := range mirror.AdditionalImages {
+		c.additionalImages[img.Name] = ""
+	}
+	for _, repo := range mirror.Helm.Repositories {
+		for _, chart := range repo.Charts {
+			c.charts[repo.Name+"/"+chart.Name] = chart.Version
+		}
+	}
+	for _, chart := range mirror.Helm.Local {
+		c.charts[chart.Path] = chart.Version
+	}
+	return c
+}
+
+// describeChannel describes the versions mirrored from a release channel
+func describeChannel(ch v1alpha2.ReleaseChannel) string {
+	var versions string
+	switch {
+	case ch.MinVersion != "" || ch.MaxVersion != "":
+		versions = ch.MinVersion + " - " + ch.MaxVersion
+	case ch.Full:
+		versions = "full"
+	default:
+		versions = "heads only"
+	}
+	if ch.ShortestPath {
+		versions += ", shortest path"
+	}
+	if len(ch.Architectures) != 0 {
+		versions += ", " + strings.Join(ch.Architectures, "/")
+	}
+	return versions
+}
+
+// describePackage describes the channels and versions mirrored from a package
+func describePackage(pkg v1alpha2.IncludePackage) string {
+	var parts []string
+	for _, ch := range pkg.Channels {
+		parts = append(parts, "channel "+ch.Name)
+	}
+	sort.Strings(parts)
+	if pkg.StartingVersion.String() != "0.0.0" {
+		parts = append(parts, "from "+pkg.StartingVersion.String())
+	}
+	if pkg.StartingBundle != "" {
+		parts = append(parts, "from "+pkg.StartingBundle)
+	}
+	if len(parts) == 0 {
+		return "all channels"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// newMetadataContent returns the content of the last run recorded in metadata.
+// The blobs of images with several manifests are the blobs of their children.
+func newMetadataContent(meta v1alpha2.Metadata) *content {
+	c := newContent(meta.PastMirror.Mirror)
+	// Children are keyed by their digest and the path of their parent
+	byKey := make(map[string]v1alpha2.Association, len(meta.PastMirror.Associations))
+	children := map[string]struct{}{}
+	for _, assoc := range meta.PastMirror.Associations {
+		byKey[assoc.Name+assoc.Path] = assoc
+		for _, dgst := range assoc.ManifestDigests {
+			children[dgst+assoc.Path] = struct{}{}
+		}
+	}
+	c.images = map[string]resolvedImage{}
+	for key, assoc := range byKey {
+		if _, ok := children[key]; ok {
+			continue
+		}
+		img := resolvedImage{imageType: assoc.Type, blobs: map[string]struct{}{}}
+		for _, dgst := range assoc.LayerDigests {
+			img.blobs[dgst] = struct{}{}
+		}
+		for _, dgst := range assoc.ManifestDigests {
+			for _, layer := range byKey[dgst+assoc.Path].LayerDigests {
+				img.blobs[layer] = struct{}{}
+			}
+		}
+		c.images[assoc.Name] = img
+	}
+	return c
+}
+
+// loadWorkspace loads the metadata of a workspace, and
+// the sizes of the blobs left in its v2 directory
+func loadWorkspace(ctx context.Context, dir string) (*content, error) {
+	backend, err := storage.NewLocalBackend(dir)
+	if err != nil {
+		return nil, err
+	}
+	meta := v1alpha2.NewMetadata()
+	if err := backend.ReadMetadata(ctx, &meta, config.MetadataBasePath); err != nil {
+		return nil, fmt.Errorf("error reading workspace metadata: %v", err)
+	}
+	c := newMetadataContent(meta)
+	err = filepath.WalkDir(filepath.Join(dir, config.V2Dir), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() || filepath.Base(filepath.Dir(path)) != config.BlobDir {
+			return nil
+		}
+		if _, err := digest.Parse(d.Name()); err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		c.blobSizes[d.Name()] = info.Size()
+		return nil
+	})
+	return c, err
+}
+
+// loadArchives loads the metadata of an imageset, and
+// the sizes of the blobs its archives contain
+func loadArchives(ctx context.Context, archives []string) (*content, error) {
+	a := archive.NewArchiver()
+	blobSizes := map[string]int64{}
+	var metadataArchive string
+	for _, path := range archives {
+		logrus.Debugf("Reading archive %s", path)
+		err := a.Walk(path, func(f archiver.File) error {
+			hdr, ok := f.Header.(*tar.Header)
+			if !ok {
+				return fmt.Errorf("file type not currently implemented %v", f.Header)
+			}
+			name := filepath.Clean(hdr.Name)
+			if name == config.MetadataBasePath {
+				metadataArchive = path
+			}
+			if filepath.Dir(name) == config.BlobDir {
+				if _, err := digest.Parse(filepath.Base(name)); err == nil {
+					blobSizes[filepath.Base(name)] = hdr.Size
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if metadataArchive == "" {
+		return nil, errors.New("metadata is not in archive")
+	}
+
+	tmpdir, err := ioutil.TempDir("", "metadata")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpdir)
+	if err := a.Extract(metadataArchive, config.MetadataBasePath, tmpdir); err != nil {
+		return nil, err
+	}
+	backend, err := storage.NewLocalBackend(tmpdir)
+	if err != nil {
+		return nil, err
+	}
+	meta := v1alpha2.NewMetadata()
+	if err := backend.ReadMetadata(ctx, &meta, config.MetadataBasePath); err != nil {
+		return nil, err
+	}
+	c := newMetadataContent(meta)
+	c.blobSizes = blobSizes
+	return c, nil
+}
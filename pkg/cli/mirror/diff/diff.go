@@ -0,0 +1,303 @@
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	units "github.com/docker/go-units"
+	"github.com/spf13/cobra"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift/oc-mirror/pkg/cli"
+)
+
+const (
+	tableOutput = "table"
+	jsonOutput  = "json"
+)
+
+// Changes of the content
+const (
+	added   = "added"
+	removed = "removed"
+	changed = "changed"
+)
+
+type DiffOptions struct {
+	*cli.RootOptions
+	Old    string
+	New    string
+	Output string
+}
+
+func NewDiffCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
+	o := DiffOptions{}
+	o.RootOptions = ro
+
+	cmd := &cobra.Command{
+		Use:   "diff OLD NEW",
+		Short: "Compare the content of two imageset configurations, imagesets, or workspaces",
+		Long: templates.LongDesc(`
+			Compare the content of two imageset configurations, imagesets, or workspaces,
+			and report the release channels, operator catalogs and packages, additional
+			images, and Helm charts added, removed, or changed between them.
+
+			OLD and NEW can each be an imageset configuration file, an imageset archive or
+			a directory of archives, or a workspace, given as the workspace directory, a
+			mirror directory containing one, or a local storage directory.
+
+			Imagesets and workspaces record the images they mirrored, which are compared
+			with their sizes when both sides record them. Sizes only count the blobs
+			available in the archives or workspaces compared, so images whose blobs were
+			shipped in previous imagesets may be reported smaller than they are.
+		`),
+		Example: templates.Examples(`
+			# Compare two imageset configurations
+			oc-mirror diff imageset-config-old.yaml imageset-config.yaml
+
+			# Compare an imageset configuration to the content last mirrored by the workspace
+			oc-mirror diff ./oc-mirror-workspace imageset-config.yaml
+
+			# Compare the images of two imagesets as JSON
+			oc-mirror diff mirror_seq1_000000.tar mirror_seq2_000000.tar -o json
+		`),
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckErr(o.Complete(args))
+			kcmdutil.CheckErr(o.Validate())
+			kcmdutil.CheckErr(o.Run(cmd.Context(), o.IOStreams.Out))
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&o.Output, "output", "o", tableOutput, "Output format: table or json")
+
+	return cmd
+}
+
+func (o *DiffOptions) Complete(args []string) error {
+	o.Old, o.New = args[0], args[1]
+	return nil
+}
+
+func (o *DiffOptions) Validate() error {
+	switch o.Output {
+	case tableOutput, jsonOutput:
+		return nil
+	default:
+		return fmt.Errorf("output format %q is not supported: must be %s or %s", o.Output, tableOutput, jsonOutput)
+	}
+}
+
+func (o *DiffOptions) Run(ctx context.Context, out io.Writer) error {
+	oldContent, err := loadContent(ctx, o.Old)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", o.Old, err)
+	}
+	newContent, err := loadContent(ctx, o.New)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", o.New, err)
+	}
+	report := compare(oldContent, newContent)
+	report.Old, report.New = o.Old, o.New
+
+	if o.Output == jsonOutput {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	}
+	return writeReport(out, report)
+}
+
+// report is the difference between two contents
+type report struct {
+	Old              string        `json:"old"`
+	New              string        `json:"new"`
+	Releases         []change      `json:"releases"`
+	Operators        []change      `json:"operators"`
+	AdditionalImages []change      `json:"additionalImages"`
+	HelmCharts       []change      `json:"helmCharts"`
+	Images           []imageChange `json:"images,omitempty"`
+	// SizeDelta is the change of the size of the
+	// unique blobs of the images, when they are compared
+	SizeDelta *int64 `json:"sizeDelta,omitempty"`
+}
+
+// change is the change of a declared item
+type change struct {
+	Change string `json:"change"`
+	Name   string `json:"name"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+// imageChange is an image added or removed
+type imageChange struct {
+	Change string `json:"change"`
+	Image  string `json:"image"`
+	Type   string `json:"type"`
+	// Size is the size of the blobs of the image that are available
+	Size int64 `json:"size"`
+}
+
+// compare returns the changes from the old content to the new content
+func compare(oldContent, newContent *content) report {
+	r := report{
+		Releases:         compareItems(oldContent.releases, newContent.releases),
+		Operators:        compareItems(oldContent.operators, newContent.operators),
+		AdditionalImages: compareItems(oldContent.additionalImages, newContent.additionalImages),
+		HelmCharts:       compareItems(oldContent.charts, newContent.charts),
+	}
+	if oldContent.images == nil || newContent.images == nil {
+		return r
+	}
+
+	// Blobs have the same size on both sides, so the sizes
+	// of the blobs available on either side are used
+	sizes := make(map[string]int64, len(oldContent.blobSizes)+len(newContent.blobSizes))
+	for _, blobSizes := range []map[string]int64{oldContent.blobSizes, newContent.blobSizes} {
+		for dgst, size := range blobSizes {
+			sizes[dgst] = size
+		}
+	}
+	imageSize := func(img resolvedImage) int64 {
+		var size int64
+		for dgst := range img.blobs {
+			size += sizes[dgst]
+		}
+		return size
+	}
+	r.Images = []imageChange{}
+	for name, img := range newContent.images {
+		if _, ok := oldContent.images[name]; !ok {
+			r.Images = append(r.Images, imageChange{Change: added, Image: name, Type: img.imageType.String(), Size: imageSize(img)})
+		}
+	}
+	for name, img := range oldContent.images {
+		if _, ok := newContent.images[name]; !ok {
+			r.Images = append(r.Images, imageChange{Change: removed, Image: name, Type: img.imageType.String(), Size: imageSize(img)})
+		}
+	}
+	sort.Slice(r.Images, func(i, j int) bool {
+		if r.Images[i].Change != r.Images[j].Change {
+			return r.Images[i].Change < r.Images[j].Change
+		}
+		return r.Images[i].Image < r.Images[j].Image
+	})
+
+	delta := totalSize(newContent.images, sizes) - totalSize(oldContent.images, sizes)
+	r.SizeDelta = &delta
+	return r
+}
+
+// compareItems returns the items added, removed, or
+// with another description, sorted by change and name
+func compareItems(oldItems, newItems map[string]string) []change {
+	changes := []change{}
+	for name, desc := range newItems {
+		oldDesc, ok := oldItems[name]
+		switch {
+		case !ok:
+			changes = append(changes, change{Change: added, Name: name, New: desc})
+		case oldDesc != desc:
+			changes = append(changes, change{Change: changed, Name: name, Old: oldDesc, New: desc})
+		}
+	}
+	for name, desc := range oldItems {
+		if _, ok := newItems[name]; !ok {
+			changes = append(changes, change{Change: removed, Name: name, Old: desc})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Change != changes[j].Change {
+			return changes[i].Change < changes[j].Change
+		}
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+// totalSize returns the size of the unique blobs of the images
+func totalSize(images map[string]resolvedImage, sizes map[string]int64) int64 {
+	seen := map[string]struct{}{}
+	var total int64
+	for _, img := range images {
+		for dgst := range img.blobs {
+			if _, ok := seen[dgst]; !ok {
+				seen[dgst] = struct{}{}
+				total += sizes[dgst]
+			}
+		}
+	}
+	return total
+}
+
+// writeReport writes the changes as tables by kind of content
+func writeReport(w io.Writer, r report) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	sections := []struct {
+		title   string
+		changes []change
+	}{
+		{"RELEASES", r.Releases},
+		{"OPERATORS", r.Operators},
+		{"ADDITIONAL IMAGES", r.AdditionalImages},
+		{"HELM CHARTS", r.HelmCharts},
+	}
+	for _, section := range sections {
+		if len(section.changes) == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\tCHANGE\tOLD\tNEW\n", section.title)
+		for _, c := range section.changes {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Name, c.Change, orDash(c.Old), orDash(c.New))
+		}
+		fmt.Fprintln(tw)
+	}
+	if len(r.Images) != 0 {
+		fmt.Fprintln(tw, "IMAGE\tCHANGE\tTYPE\tSIZE")
+		for _, img := range r.Images {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", img.Image, img.Change, img.Type, units.BytesSize(float64(img.Size)))
+		}
+		fmt.Fprintln(tw)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	summary := fmt.Sprintf("%d release, %d operator, %d additional image, and %d Helm chart changes",
+		len(r.Releases), len(r.Operators), len(r.AdditionalImages), len(r.HelmCharts))
+	if r.SizeDelta != nil {
+		var addedImages, removedImages int
+		for _, img := range r.Images {
+			if img.Change == added {
+				addedImages++
+			} else {
+				removedImages++
+			}
+		}
+		sign := "+"
+		size := *r.SizeDelta
+		if size < 0 {
+			sign, size = "-", -size
+		}
+		summary += fmt.Sprintf("; %d images added and %d removed, size %s%s", addedImages, removedImages, sign, units.BytesSize(float64(size)))
+	}
+	_, err := fmt.Fprintln(w, summary)
+	return err
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,213 @@
+package diff
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/config"
+)
+
+const (
+	blobA = "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	blobB = "sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	blobC = "sha256:cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc"
+	child = "sha256:dddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddd"
+)
+
+func TestDiff(t *testing.T) {
+	tmp := t.TempDir()
+
+	oldConfig := filepath.Join(tmp, "old.yaml")
+	require.NoError(t, os.WriteFile(oldConfig, []byte(`
+apiVersion: mirror.openshift.io/v1alpha2
+kind: ImageSetConfiguration
+mirror:
+  platform:
+    channels:
+      - name: stable-4.11
+      - name: stable-4.12
+        minVersion: 4.12.1
+        maxVersion: 4.12.10
+  operators:
+    - catalog: registry.redhat.io/redhat/redhat-operator-index:v4.12
+      full: true
+      packages:
+        - name: aws-load-balancer-operator
+        - name: local-storage-operator
+  additionalImages:
+    - name: registry.redhat.io/ubi8/ubi:latest
+`), 0600))
+
+	// The workspace of the old configuration mirrored a multi-arch image and a generic image
+	cfg, err := config.ReadConfig(oldConfig)
+	require.NoError(t, err)
+	oldMirror := cfg.Mirror
+	workspace := filepath.Join(tmp, "mirror", workspaceDir)
+	writeMetadata(t, filepath.Join(workspace, config.SourceDir), oldMirror, []v1alpha2.Association{
+		{Name: "registry.redhat.io/ubi8/ubi:latest", Path: "ubi8/ubi", TagSymlink: "latest", Type: v1alpha2.TypeGeneric, ManifestDigests: []string{child}},
+		{Name: child, Path: "ubi8/ubi", ID: child, Type: v1alpha2.TypeGeneric, LayerDigests: []string{blobA, blobB}},
+		{Name: "registry.redhat.io/ubi8/ubi-minimal:latest", Path: "ubi8/ubi-minimal", TagSymlink: "latest", Type: v1alpha2.TypeGeneric, LayerDigests: []string{blobA}},
+	})
+	writeBlob(t, filepath.Join(workspace, config.SourceDir, config.V2Dir, "ubi8", "ubi", config.BlobDir, blobA), 1000)
+	writeBlob(t, filepath.Join(workspace, config.SourceDir, config.V2Dir, "ubi8", "ubi", config.BlobDir, blobB), 200)
+
+	newConfig := filepath.Join(tmp, "new.yaml")
+	require.NoError(t, os.WriteFile(newConfig, []byte(`
+apiVersion: mirror.openshift.io/v1alpha2
+kind: ImageSetConfiguration
+mirror:
+  platform:
+    channels:
+      - name: stable-4.12
+        minVersion: 4.12.1
+        maxVersion: 4.12.20
+      - name: stable-4.13
+  operators:
+    - catalog: registry.redhat.io/redhat/redhat-operator-index:v4.12
+      full: true
+      packages:
+        - name: local-storage-operator
+          channels:
+            - name: stable
+  additionalImages:
+    - name: registry.redhat.io/ubi8/ubi:latest
+    - name: registry.redhat.io/ubi9/ubi:latest
+`), 0600))
+	cfg, err = config.ReadConfig(newConfig)
+	require.NoError(t, err)
+
+	// The imageset of the new configuration only ships the blobs not mirrored before
+	archive := filepath.Join(tmp, "mirror_seq2_000000.tar")
+	writeArchive(t, archive, cfg.Mirror, []v1alpha2.Association{
+		{Name: "registry.redhat.io/ubi8/ubi:latest", Path: "ubi8/ubi", TagSymlink: "latest", Type: v1alpha2.TypeGeneric, ManifestDigests: []string{child}},
+		{Name: child, Path: "ubi8/ubi", ID: child, Type: v1alpha2.TypeGeneric, LayerDigests: []string{blobA, blobB}},
+		{Name: "registry.redhat.io/ubi9/ubi:latest", Path: "ubi9/ubi", TagSymlink: "latest", Type: v1alpha2.TypeGeneric, LayerDigests: []string{blobA, blobC}},
+	}, map[string]int64{blobC: 3000})
+
+	expDeclared := report{
+		Releases: []change{
+			{Change: added, Name: "stable-4.13", New: "heads only"},
+			{Change: changed, Name: "stable-4.12", Old: "4.12.1 - 4.12.10", New: "4.12.1 - 4.12.20"},
+			{Change: removed, Name: "stable-4.11", Old: "heads only"},
+		},
+		Operators: []change{
+			{Change: changed, Name: "registry.redhat.io/redhat/redhat-operator-index:v4.12 local-storage-operator", Old: "all channels", New: "channel stable"},
+			{Change: removed, Name: "registry.redhat.io/redhat/redhat-operator-index:v4.12 aws-load-balancer-operator", Old: "all channels"},
+		},
+		AdditionalImages: []change{
+			{Change: added, Name: "registry.redhat.io/ubi9/ubi:latest"},
+		},
+		HelmCharts: []change{},
+	}
+
+	type spec struct {
+		name      string
+		old       string
+		new       string
+		expReport report
+		expError  string
+	}
+	withImages := expDeclared
+	withImages.Images = []imageChange{
+		{Change: added, Image: "registry.redhat.io/ubi9/ubi:latest", Type: "generic", Size: 4000},
+		{Change: removed, Image: "registry.redhat.io/ubi8/ubi-minimal:latest", Type: "generic", Size: 1000},
+	}
+	delta := int64(3000)
+	withImages.SizeDelta = &delta
+	cases := []spec{
+		{
+			name:      "Valid/Configs",
+			old:       oldConfig,
+			new:       newConfig,
+			expReport: expDeclared,
+		},
+		{
+			name:      "Valid/WorkspaceAndConfig",
+			old:       filepath.Join(tmp, "mirror"),
+			new:       newConfig,
+			expReport: expDeclared,
+		},
+		{
+			name:      "Valid/WorkspaceAndArchive",
+			old:       workspace,
+			new:       archive,
+			expReport: withImages,
+		},
+		{
+			name:     "Invalid/NoContent",
+			old:      oldConfig,
+			new:      t.TempDir(),
+			expError: "no workspace metadata or archives found in directory",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			o := &DiffOptions{Old: c.old, New: c.new, Output: jsonOutput}
+			out := &bytes.Buffer{}
+			err := o.Run(context.Background(), out)
+			if c.expError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expError)
+				return
+			}
+			require.NoError(t, err)
+			var r report
+			require.NoError(t, json.Unmarshal(out.Bytes(), &r))
+			c.expReport.Old, c.expReport.New = c.old, c.new
+			require.Equal(t, c.expReport, r)
+		})
+	}
+
+	out := &bytes.Buffer{}
+	require.NoError(t, writeReport(out, withImages))
+	require.Contains(t, out.String(), "stable-4.12  changed  4.12.1 - 4.12.10")
+	require.True(t, strings.HasSuffix(out.String(), "3 release, 2 operator, 1 additional image, and 0 Helm chart changes; 1 images added and 1 removed, size +2.93KiB\n"), out.String())
+}
+
+func writeMetadata(t *testing.T, dir string, mirror v1alpha2.Mirror, assocs []v1alpha2.Association) {
+	meta := v1alpha2.NewMetadata()
+	meta.PastMirror.Mirror = mirror
+	meta.PastMirror.Associations = assocs
+	data, err := json.Marshal(&meta)
+	require.NoError(t, err)
+	path := filepath.Join(dir, config.MetadataBasePath)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+	require.NoError(t, os.WriteFile(path, data, 0600))
+}
+
+func writeBlob(t *testing.T, path string, size int) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+	require.NoError(t, os.WriteFile(path, make([]byte, size), 0600))
+}
+
+func writeArchive(t *testing.T, path string, mirror v1alpha2.Mirror, assocs []v1alpha2.Association, blobs map[string]int64) {
+	meta := v1alpha2.NewMetadata()
+	meta.PastMirror.Mirror = mirror
+	meta.PastMirror.Associations = assocs
+	data, err := json.Marshal(&meta)
+	require.NoError(t, err)
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: config.MetadataBasePath, Mode: 0600, Size: int64(len(data))}))
+	_, err = tw.Write(data)
+	require.NoError(t, err)
+	for dgst, size := range blobs {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: filepath.Join(config.BlobDir, dgst), Mode: 0600, Size: size}))
+		_, err = tw.Write(make([]byte, size))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+}
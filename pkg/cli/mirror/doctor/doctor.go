@@ -0,0 +1,639 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	dockercfg "github.com/docker/cli/cli/config"
+	units "github.com/docker/go-units"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/cincinnati"
+	"github.com/openshift/oc-mirror/pkg/cli"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
+	"github.com/openshift/oc-mirror/pkg/metadata/storage"
+)
+
+const (
+	tableOutput = "table"
+	jsonOutput  = "json"
+)
+
+// Statuses of a check
+const (
+	statusPass = "pass"
+	statusWarn = "warn"
+	statusFail = "fail"
+)
+
+// releaseRegistry hosts the OCP and OKD release payloads
+const releaseRegistry = "quay.io"
+
+type DoctorOptions struct {
+	*cli.RootOptions
+	ConfigPath string
+	// Registries are checked in addition to the registries of the configuration
+	Registries []string
+	// MinFreeSpace is the free space the workspace directory should have
+	MinFreeSpace string
+	Insecure     bool
+	// InsecureRegistries are the registries --insecure applies to,
+	// in addition to those marked insecure in the configuration
+	InsecureRegistries []string
+	Timeout            time.Duration
+	Output             string
+
+	minFreeSpace int64
+}
+
+func NewDoctorCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
+	o := DoctorOptions{}
+	o.RootOptions = ro
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose the environment oc-mirror runs in",
+		Long: templates.LongDesc(`
+			Diagnose the environment oc-mirror runs in, and print how to fix each
+			problem found.
+
+			The registry credential files are parsed, the registries of the imageset
+			configuration and of --registry are connected to and authenticated with,
+			the Cincinnati update services of the release channels are queried, and the
+			proxy settings, the free disk space, the umask, and the permissions of the
+			workspace directory are checked. The metadata storage backend of the
+			configuration is read to confirm it is reachable.
+
+			The command fails when any check fails. Warnings do not fail the command.
+		`),
+		Example: templates.Examples(`
+			# Diagnose the environment for an imageset configuration
+			oc-mirror doctor --config imageset-config.yaml
+
+			# Also check the mirror registry
+			oc-mirror doctor --config imageset-config.yaml --registry registry.example.com:5000
+
+			# Report the checks as JSON
+			oc-mirror doctor --config imageset-config.yaml -o json
+		`),
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckErr(o.Complete())
+			kcmdutil.CheckErr(o.Validate())
+			kcmdutil.CheckErr(o.Run(cmd.Context(), o.IOStreams.Out))
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&o.ConfigPath, "config", "c", o.ConfigPath, "Path to imageset configuration file")
+	fs.StringSliceVar(&o.Registries, "registry", o.Registries, "Registry to check, such as the mirror registry (can be repeated)")
+	fs.StringVar(&o.MinFreeSpace, "min-free-space", "50GiB", "Free space the workspace directory should have")
+	fs.BoolVar(&o.Insecure, "insecure", o.Insecure, "Skip TLS verification and allow plain HTTP for the registries marked insecure in the configuration or with --insecure-registry")
+	fs.StringSliceVar(&o.InsecureRegistries, "insecure-registry", o.InsecureRegistries, "Registry --insecure applies to, "+
+		"in addition to the registries marked insecure in the configuration (can be repeated)")
+	fs.DurationVar(&o.Timeout, "timeout", 30*time.Second, "Timeout of each network check")
+	fs.StringVarP(&o.Output, "output", "o", tableOutput, "Output format: table or json")
+
+	return cmd
+}
+
+func (o *DoctorOptions) Complete() error {
+	if len(o.MinFreeSpace) > 0 {
+		size, err := units.RAMInBytes(o.MinFreeSpace)
+		if err != nil {
+			return fmt.Errorf("invalid --min-free-space %q: %v", o.MinFreeSpace, err)
+		}
+		o.minFreeSpace = size
+	}
+	return nil
+}
+
+func (o *DoctorOptions) Validate() error {
+	if o.Timeout <= 0 {
+		return errors.New("--timeout must be positive")
+	}
+	switch o.Output {
+	case tableOutput, jsonOutput:
+		return nil
+	default:
+		return fmt.Errorf("output format %q is not supported: must be %s or %s", o.Output, tableOutput, jsonOutput)
+	}
+}
+
+// check is the result of a diagnostic
+type check struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	// Remediation is how to fix a warning or a failure
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// report is the result of the diagnostics
+type report struct {
+	Checks   []check `json:"checks"`
+	Failed   int     `json:"failed"`
+	Warnings int     `json:"warnings"`
+}
+
+func (r *report) add(c check) {
+	r.Checks = append(r.Checks, c)
+	switch c.Status {
+	case statusFail:
+		r.Failed++
+	case statusWarn:
+		r.Warnings++
+	}
+}
+
+func (o *DoctorOptions) Run(ctx context.Context, out io.Writer) error {
+	rep := report{Checks: []check{}}
+
+	var cfg *v1alpha2.ImageSetConfiguration
+	if len(o.ConfigPath) == 0 {
+		if err := image.SharedClients().SetRegistries(image.MarkInsecure(nil, o.InsecureRegistries)); err != nil {
+			return err
+		}
+	} else {
+		c, err := config.ReadConfig(o.ConfigPath)
+		if err != nil {
+			rep.add(check{
+				Name:        "config",
+				Status:      statusFail,
+				Message:     err.Error(),
+				Remediation: fmt.Sprintf("fix the imageset configuration %s", o.ConfigPath),
+			})
+		} else if err := image.SharedClients().SetRegistries(image.MarkInsecure(c.Registries, o.InsecureRegistries)); err != nil {
+			rep.add(check{
+				Name:        "config",
+				Status:      statusFail,
+				Message:     fmt.Sprintf("invalid registries configuration: %v", err),
+				Remediation: "fix the registries of the imageset configuration",
+			})
+		} else {
+			cfg = &c
+			rep.add(check{Name: "config", Status: statusPass, Message: fmt.Sprintf("%s is valid", o.ConfigPath)})
+		}
+	}
+
+	rep.add(checkAuthFiles())
+	hosts := o.registryHosts(cfg)
+	rep.add(checkProxy(hosts))
+	for _, host := range hosts {
+		rep.add(o.checkRegistry(ctx, host))
+	}
+	if cfg != nil {
+		for _, c := range o.checkCincinnati(ctx, cfg.Mirror.Platform) {
+			rep.add(c)
+		}
+	}
+	rep.add(o.checkDiskSpace())
+	rep.add(checkUmask())
+	rep.add(checkWritable(o.Dir))
+	if cfg != nil && cfg.StorageConfig.IsSet() {
+		rep.add(o.checkBackend(ctx, cfg.StorageConfig))
+	}
+
+	if err := o.writeReport(out, rep); err != nil {
+		return err
+	}
+	if rep.Failed != 0 {
+		return fmt.Errorf("%d of %d checks failed", rep.Failed, len(rep.Checks))
+	}
+	return nil
+}
+
+// checkAuthFiles parses the registry credential files
+func checkAuthFiles() check {
+	c := check{Name: "auth"}
+	files, err := image.AuthFiles()
+	if err != nil {
+		c.Status, c.Message = statusFail, fmt.Sprintf("error finding registry credentials: %v", err)
+		c.Remediation = "make the registry credential files readable"
+		return c
+	}
+	if len(files) == 0 {
+		c.Status, c.Message = statusWarn, "no registry credential files found"
+		c.Remediation = "log in to the registries with podman login, or set REGISTRY_AUTH_FILE to your pull secret"
+		return c
+	}
+	var problems, remediations []string
+	for _, path := range files {
+		f, err := os.Open(filepath.Clean(path))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+			remediations = append(remediations, fmt.Sprintf("make %s readable", path))
+			continue
+		}
+		_, err = dockercfg.LoadFromReader(f)
+		f.Close()
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid JSON: %v", path, err))
+			remediations = append(remediations, fmt.Sprintf("fix or replace %s with a valid pull secret", path))
+		}
+	}
+	if len(problems) != 0 {
+		c.Status, c.Message = statusFail, strings.Join(problems, "; ")
+		c.Remediation = strings.Join(remediations, "; ")
+		return c
+	}
+	var permissive []string
+	for _, path := range files {
+		if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0077 != 0 {
+			permissive = append(permissive, path)
+		}
+	}
+	if len(permissive) != 0 {
+		c.Status = statusWarn
+		c.Message = fmt.Sprintf("credentials are readable by other users: %s", strings.Join(permissive, ", "))
+		c.Remediation = fmt.Sprintf("chmod 600 %s", strings.Join(permissive, " "))
+		return c
+	}
+	c.Status, c.Message = statusPass, fmt.Sprintf("using %s", strings.Join(files, ", "))
+	return c
+}
+
+// registryHosts returns the registries of the configuration and of the
+// command line, sorted and without duplicates
+func (o *DoctorOptions) registryHosts(cfg *v1alpha2.ImageSetConfiguration) []string {
+	seen := map[string]struct{}{}
+	// addRegistry adds the registry of a registry or repository name
+	addRegistry := func(ref string) {
+		host := strings.SplitN(strings.TrimPrefix(ref, "docker://"), "/", 2)[0]
+		if reg, err := name.NewRegistry(host); err == nil {
+			host = reg.RegistryStr()
+		}
+		if len(host) != 0 {
+			seen[host] = struct{}{}
+		}
+	}
+	// addImage adds the registry of an image reference
+	addImage := func(ref string) {
+		if r, err := name.ParseReference(strings.TrimPrefix(ref, "docker://")); err == nil {
+			seen[r.Context().RegistryStr()] = struct{}{}
+		}
+	}
+	if cfg != nil {
+		platform := cfg.Mirror.Platform
+		if len(platform.Channels) != 0 || len(platform.UpgradePaths) != 0 {
+			addRegistry(releaseRegistry)
+		}
+		for _, img := range platform.Releases {
+			addImage(img.Name)
+		}
+		for _, ctlg := range cfg.Mirror.Operators {
+			addImage(ctlg.Catalog)
+		}
+		for _, img := range cfg.Mirror.AdditionalImages {
+			addImage(img.Name)
+		}
+		if cfg.StorageConfig.Registry != nil {
+			addImage(cfg.StorageConfig.Registry.ImageURL)
+		}
+	}
+	for _, reg := range o.Registries {
+		addRegistry(reg)
+	}
+	hosts := make([]string, 0, len(seen))
+	for host := range seen {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// checkRegistry connects to the registry and authenticates
+// with the credentials of the registry, if any
+func (o *DoctorOptions) checkRegistry(ctx context.Context, host string) check {
+	c := check{Name: fmt.Sprintf("registry %s", host)}
+	insecure := o.Insecure && image.SharedClients().AllowsInsecure(host)
+	var opts []name.Option
+	if insecure {
+		opts = append(opts, name.Insecure)
+	}
+	reg, err := name.NewRegistry(host, opts...)
+	if err != nil {
+		c.Status, c.Message = statusFail, err.Error()
+		c.Remediation = "fix the registry name"
+		return c
+	}
+	auth, err := image.SharedClients().Keychain().Resolve(reg)
+	if err != nil {
+		c.Status, c.Message = statusFail, fmt.Sprintf("error resolving credentials: %v", err)
+		c.Remediation = "fix the registry credential files or the credential helper of the registry"
+		return c
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, o.Timeout)
+	defer cancel()
+	_, err = transport.NewWithContext(ctx, reg, auth, image.SharedClients().Transport(insecure), []string{})
+	var terr *transport.Error
+	switch {
+	case err == nil:
+		c.Status, c.Message = statusPass, "reachable"
+	case errors.As(err, &terr) && (terr.StatusCode == http.StatusUnauthorized || terr.StatusCode == http.StatusForbidden):
+		c.Status, c.Message = statusFail, fmt.Sprintf("authentication failed: %v", err)
+		c.Remediation = fmt.Sprintf("log in again with podman login %s, or refresh the pull secret", host)
+	default:
+		c.Status, c.Message = statusFail, fmt.Sprintf("unreachable: %v", err)
+		c.Remediation = registryRemediation(host, err)
+	}
+	return c
+}
+
+// registryRemediation suggests how to reach a registry
+func registryRemediation(host string, err error) string {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "x509"):
+		return fmt.Sprintf("add the CA of %s to the system trust store or to the caBundle of the registry in the configuration, or use --insecure --insecure-registry %s", host, host)
+	case strings.Contains(msg, "no such host"):
+		return fmt.Sprintf("check that %s resolves in DNS", host)
+	case strings.Contains(msg, "server gave HTTP response to HTTPS client"):
+		return fmt.Sprintf("mark %s insecure in the configuration or use --insecure --insecure-registry %s", host, host)
+	}
+	if proxyURL, _ := proxyFor(host); proxyURL == "" {
+		return fmt.Sprintf("check the network access to %s, or set HTTPS_PROXY if it is only reachable through a proxy", host)
+	}
+	return fmt.Sprintf("check the network access to %s through the proxy, or add it to NO_PROXY", host)
+}
+
+// checkProxy reports the proxy used for each registry
+func checkProxy(hosts []string) check {
+	c := check{Name: "proxy"}
+	var env []string
+	for _, key := range []string{"HTTPS_PROXY", "HTTP_PROXY", "NO_PROXY"} {
+		value := os.Getenv(key)
+		if value == "" {
+			value = os.Getenv(strings.ToLower(key))
+		}
+		if value != "" {
+			env = append(env, fmt.Sprintf("%s=%s", key, value))
+		}
+	}
+	if len(env) == 0 {
+		c.Status, c.Message = statusPass, "no proxy configured"
+		return c
+	}
+	var proxied []string
+	for _, host := range hosts {
+		proxyURL, err := proxyFor(host)
+		if err != nil {
+			c.Status, c.Message = statusFail, fmt.Sprintf("invalid proxy configuration: %v", err)
+			c.Remediation = "set HTTPS_PROXY and HTTP_PROXY to proxy URLs such as http://proxy.example.com:3128"
+			return c
+		}
+		if proxyURL != "" {
+			proxied = append(proxied, fmt.Sprintf("%s via %s", host, proxyURL))
+		}
+	}
+	c.Status = statusPass
+	c.Message = strings.Join(env, ", ")
+	if len(proxied) != 0 {
+		c.Message += "; " + strings.Join(proxied, ", ")
+	}
+	return c
+}
+
+// proxyFor returns the proxy of the environment used for
+// HTTPS requests to the host, or an empty string for none
+func proxyFor(host string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://%s/v2/", host), nil)
+	if err != nil {
+		return "", err
+	}
+	proxyURL, err := http.ProxyFromEnvironment(req)
+	if err != nil || proxyURL == nil {
+		return "", err
+	}
+	return proxyURL.Redacted(), nil
+}
+
+// checkCincinnati queries the update service of
+// the first release channel of each platform type
+func (o *DoctorOptions) checkCincinnati(ctx context.Context, platform v1alpha2.Platform) []check {
+	var checks []check
+	seen := map[v1alpha2.PlatformType]struct{}{}
+	for _, ch := range platform.Channels {
+		if _, ok := seen[ch.Type]; ok {
+			continue
+		}
+		seen[ch.Type] = struct{}{}
+		c := check{Name: fmt.Sprintf("cincinnati %s", ch.Type)}
+		var client cincinnati.Client
+		var err error
+		switch ch.Type {
+		case v1alpha2.TypeOCP:
+			client, err = cincinnati.NewOCPClient(uuid.New(), image.SharedClients())
+		case v1alpha2.TypeOKD:
+			client, err = cincinnati.NewOKDClient(uuid.New(), image.SharedClients())
+		default:
+			continue
+		}
+		if err == nil {
+			err = o.queryCincinnati(ctx, client, ch.Name)
+		}
+		if err != nil {
+			c.Status, c.Message = statusFail, err.Error()
+			c.Remediation = fmt.Sprintf("check the network access to %s, or set HTTPS_PROXY if it is only reachable through a proxy", client.GetURL().Host)
+		} else {
+			c.Status, c.Message = statusPass, fmt.Sprintf("channel %s is available", ch.Name)
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// queryCincinnati requests the update graph of the channel
+func (o *DoctorOptions) queryCincinnati(ctx context.Context, client cincinnati.Client, channel string) error {
+	client.SetQueryParams("", channel, "")
+	ctx, cancel := context.WithTimeout(ctx, o.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, client.GetURL().String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", cincinnati.GraphMediaType)
+	httpClient := http.Client{}
+	if t := client.GetTransport(); t != nil {
+		httpClient.Transport = t
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected HTTP status querying channel %s: %s", channel, resp.Status)
+	}
+	return nil
+}
+
+// checkDiskSpace checks the free space of the workspace directory
+func (o *DoctorOptions) checkDiskSpace() check {
+	c := check{Name: "disk space"}
+	dir := existingParent(o.Dir)
+	free, err := freeSpace(dir)
+	if err != nil {
+		c.Status, c.Message = statusWarn, fmt.Sprintf("error reading the free space of %s: %v", dir, err)
+		return c
+	}
+	msg := fmt.Sprintf("%s free in %s", units.BytesSize(float64(free)), dir)
+	if int64(free) < o.minFreeSpace {
+		c.Status, c.Message = statusFail, msg
+		c.Remediation = fmt.Sprintf("free up space or use a --dir on a filesystem with at least %s free", units.BytesSize(float64(o.minFreeSpace)))
+		return c
+	}
+	c.Status, c.Message = statusPass, msg
+	return c
+}
+
+// checkUmask checks the umask allows the owner to write
+// the workspace and other users to read the imagesets
+func checkUmask() check {
+	c := check{Name: "umask"}
+	mask, err := umask()
+	if err != nil {
+		c.Status, c.Message = statusPass, err.Error()
+		return c
+	}
+	c.Message = fmt.Sprintf("%04o", mask)
+	switch {
+	case mask&0700 != 0:
+		c.Status = statusFail
+		c.Remediation = "set a umask that lets the owner read and write files, such as umask 0022"
+	case mask&0044 != 0:
+		c.Status = statusWarn
+		c.Remediation = "imagesets and workspaces are unreadable by other users; set umask 0022 if another user transfers or publishes them"
+	default:
+		c.Status = statusPass
+	}
+	return c
+}
+
+// checkWritable checks the workspace directory, or the
+// directory it will be created in, is writable
+func checkWritable(dir string) check {
+	c := check{Name: "permissions"}
+	parent := existingParent(dir)
+	f, err := ioutil.TempFile(parent, ".oc-mirror-doctor-")
+	if err != nil {
+		c.Status, c.Message = statusFail, fmt.Sprintf("%s is not writable: %v", parent, err)
+		c.Remediation = fmt.Sprintf("make %s writable by the current user, or use another --dir", parent)
+		return c
+	}
+	f.Close()
+	os.Remove(f.Name())
+	c.Status, c.Message = statusPass, fmt.Sprintf("%s is writable", parent)
+	return c
+}
+
+// existingParent returns the directory, or its closest parent that exists
+func existingParent(dir string) string {
+	dir = filepath.Clean(dir)
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// checkBackend reads the metadata of the storage backend
+func (o *DoctorOptions) checkBackend(ctx context.Context, storageConfig v1alpha2.StorageConfig) check {
+	c := check{Name: "backend"}
+	if storageConfig.Local != nil {
+		c.Name = fmt.Sprintf("backend %s", storageConfig.Local.Path)
+		if _, err := os.Stat(storageConfig.Local.Path); errors.Is(err, os.ErrNotExist) {
+			writable := checkWritable(storageConfig.Local.Path)
+			c.Status, c.Message, c.Remediation = writable.Status, "no metadata yet: "+writable.Message, writable.Remediation
+			return c
+		}
+	}
+	if storageConfig.Registry != nil {
+		c.Name = fmt.Sprintf("backend %s", storageConfig.Registry.ImageURL)
+	}
+
+	tmp, err := ioutil.TempDir("", "oc-mirror-doctor")
+	if err != nil {
+		c.Status, c.Message = statusFail, err.Error()
+		return c
+	}
+	defer os.RemoveAll(tmp)
+	backend, err := storage.ByConfig(tmp, storageConfig, image.SharedClients())
+	if err != nil {
+		c.Status, c.Message = statusFail, err.Error()
+		c.Remediation = "fix the storageConfig of the imageset configuration"
+		return c
+	}
+	ctx, cancel := context.WithTimeout(ctx, o.Timeout)
+	defer cancel()
+	var meta v1alpha2.Metadata
+	switch err := backend.ReadMetadata(ctx, &meta, config.MetadataBasePath); {
+	case err == nil:
+		c.Status = statusPass
+		c.Message = fmt.Sprintf("metadata of sequence %d found", meta.PastMirror.Sequence)
+	case errors.Is(err, storage.ErrMetadataNotExist):
+		c.Status, c.Message = statusPass, "no metadata yet"
+	default:
+		c.Status, c.Message = statusFail, fmt.Sprintf("error reading metadata: %v", err)
+		c.Remediation = "check the access to the storage backend, or remove its metadata to start over if it is corrupted"
+	}
+	return c
+}
+
+// writeReport writes the checks as a table or JSON
+func (o *DoctorOptions) writeReport(w io.Writer, rep report) error {
+	if o.Output == jsonOutput {
+		data, err := json.MarshalIndent(rep, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "CHECK\tSTATUS\tMESSAGE"); err != nil {
+		return err
+	}
+	for _, c := range rep.Checks {
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Name, strings.ToUpper(c.Status), c.Message); err != nil {
+			return err
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, c := range rep.Checks {
+		if c.Status == statusPass || c.Remediation == "" {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s\n", c.Name, c.Remediation); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d checks, %d failed, %d warnings\n", len(rep.Checks), rep.Failed, rep.Warnings)
+	return err
+}
@@ -0,0 +1,19 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package doctor
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// freeSpace returns an error because it is not implemented on this platform
+func freeSpace(dir string) (uint64, error) {
+	return 0, fmt.Errorf("reading the free space is not supported on %s", runtime.GOOS)
+}
+
+// umask returns an error because there is no umask on this platform
+func umask() (int, error) {
+	return 0, fmt.Errorf("umask is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,209 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/cli"
+)
+
+func TestCheckAuthFiles(t *testing.T) {
+	type spec struct {
+		name      string
+		content   string
+		mode      os.FileMode
+		expStatus string
+		expMsg    string
+	}
+	cases := []spec{
+		{
+			name:      "Valid/PullSecret",
+			content:   `{"auths": {"registry.example.com": {"auth": "dXNlcjpwYXNz"}}}`,
+			mode:      0600,
+			expStatus: statusPass,
+			expMsg:    "using ",
+		},
+		{
+			name:      "Valid/ReadableByOthers",
+			content:   `{"auths": {}}`,
+			mode:      0644,
+			expStatus: statusWarn,
+			expMsg:    "credentials are readable by other users",
+		},
+		{
+			name:      "Invalid/JSON",
+			content:   `{"auths": `,
+			mode:      0600,
+			expStatus: statusFail,
+			expMsg:    "invalid JSON",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			isolateAuthFiles(t)
+			path := filepath.Join(t.TempDir(), "auth.json")
+			require.NoError(t, os.WriteFile(path, []byte(c.content), c.mode))
+			require.NoError(t, os.Chmod(path, c.mode))
+			t.Setenv("REGISTRY_AUTH_FILE", path)
+
+			res := checkAuthFiles()
+			require.Equal(t, c.expStatus, res.Status)
+			require.Contains(t, res.Message, c.expMsg)
+		})
+	}
+
+	t.Run("Valid/NoFiles", func(t *testing.T) {
+		isolateAuthFiles(t)
+		res := checkAuthFiles()
+		require.Equal(t, statusWarn, res.Status)
+		require.Contains(t, res.Remediation, "podman login")
+	})
+}
+
+// isolateAuthFiles points the credential file lookups at an empty directory
+func isolateAuthFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("REGISTRY_AUTH_FILE", "")
+	t.Setenv("DOCKER_CONFIG", filepath.Join(dir, "docker"))
+	t.Setenv("XDG_RUNTIME_DIR", filepath.Join(dir, "run"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+}
+
+func TestCheckRegistry(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	o := &DoctorOptions{Insecure: true, Timeout: 10 * time.Second}
+	res := o.checkRegistry(context.Background(), host)
+	require.Equal(t, statusPass, res.Status, res.Message)
+
+	server.Close()
+	res = o.checkRegistry(context.Background(), host)
+	require.Equal(t, statusFail, res.Status)
+	require.Contains(t, res.Message, "unreachable")
+	require.NotEmpty(t, res.Remediation)
+}
+
+func TestRegistryHosts(t *testing.T) {
+	cfg := &v1alpha2.ImageSetConfiguration{}
+	cfg.Mirror.Platform.Channels = []v1alpha2.ReleaseChannel{{Name: "stable-4.12"}}
+	cfg.Mirror.Operators = []v1alpha2.Operator{{Catalog: "registry.redhat.io/redhat/redhat-operator-index:v4.12"}}
+	cfg.Mirror.AdditionalImages = []v1alpha2.Image{{Name: "registry.example.com:5000/ubi8/ubi:latest"}, {Name: "quay.io/foo/bar:latest"}}
+	cfg.StorageConfig.Registry = &v1alpha2.RegistryConfig{ImageURL: "mirror.example.com/oc-mirror-metadata"}
+
+	o := &DoctorOptions{Registries: []string{"docker://mirror.example.com", "localhost:5000"}}
+	require.Equal(t, []string{
+		"localhost:5000",
+		"mirror.example.com",
+		"quay.io",
+		"registry.example.com:5000",
+		"registry.redhat.io",
+	}, o.registryHosts(cfg))
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "oc-mirror-workspace")
+
+	o := &DoctorOptions{RootOptions: &cli.RootOptions{Dir: dir}, MinFreeSpace: "1KiB"}
+	require.NoError(t, o.Complete())
+	res := o.checkDiskSpace()
+	require.Equal(t, statusPass, res.Status, res.Message)
+	require.Contains(t, res.Message, filepath.Dir(dir))
+
+	o.MinFreeSpace = "1000PiB"
+	require.NoError(t, o.Complete())
+	res = o.checkDiskSpace()
+	require.Equal(t, statusFail, res.Status)
+	require.NotEmpty(t, res.Remediation)
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	res := checkWritable(filepath.Join(dir, "oc-mirror-workspace", "src"))
+	require.Equal(t, statusPass, res.Status)
+	require.Equal(t, fmt.Sprintf("%s is writable", dir), res.Message)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestCheckBackend(t *testing.T) {
+	dir := t.TempDir()
+	o := &DoctorOptions{Timeout: 10 * time.Second}
+
+	res := o.checkBackend(context.Background(), v1alpha2.StorageConfig{Local: &v1alpha2.LocalConfig{Path: filepath.Join(dir, "missing")}})
+	require.Equal(t, statusPass, res.Status)
+	require.Contains(t, res.Message, "no metadata yet")
+
+	res = o.checkBackend(context.Background(), v1alpha2.StorageConfig{Local: &v1alpha2.LocalConfig{Path: dir}})
+	require.Equal(t, statusPass, res.Status)
+	require.Equal(t, "no metadata yet", res.Message)
+}
+
+func TestWriteReport(t *testing.T) {
+	rep := report{}
+	rep.add(check{Name: "auth", Status: statusPass, Message: "using /run/containers/auth.json"})
+	rep.add(check{Name: "registry quay.io", Status: statusFail, Message: "unreachable", Remediation: "set HTTPS_PROXY"})
+	rep.add(check{Name: "umask", Status: statusWarn, Message: "0077", Remediation: "set umask 0022"})
+
+	var buf bytes.Buffer
+	o := &DoctorOptions{Output: tableOutput}
+	require.NoError(t, o.writeReport(&buf, rep))
+	require.Equal(t, `CHECK             STATUS  MESSAGE
+auth              PASS    using /run/containers/auth.json
+registry quay.io  FAIL    unreachable
+umask             WARN    0077
+registry quay.io: set HTTPS_PROXY
+umask: set umask 0022
+3 checks, 1 failed, 1 warnings
+`, buf.String())
+}
+
+func TestDoctorValidate(t *testing.T) {
+	type spec struct {
+		name     string
+		opts     *DoctorOptions
+		expError string
+	}
+	cases := []spec{
+		{
+			name:     "Invalid/Timeout",
+			opts:     &DoctorOptions{Output: tableOutput},
+			expError: "--timeout must be positive",
+		},
+		{
+			name:     "Invalid/Output",
+			opts:     &DoctorOptions{Output: "yaml", Timeout: time.Second},
+			expError: `output format "yaml" is not supported: must be table or json`,
+		},
+		{
+			name: "Valid/JSON",
+			opts: &DoctorOptions{Output: jsonOutput, Timeout: time.Second},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			err := c.opts.Validate()
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
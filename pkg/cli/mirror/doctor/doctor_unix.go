@@ -0,0 +1,22 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package doctor
+
+import "syscall"
+
+// freeSpace returns the space available to the user in the filesystem of dir
+func freeSpace(dir string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
+
+// umask returns the file mode creation mask of the process
+func umask() (int, error) {
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+	return mask, nil
+}
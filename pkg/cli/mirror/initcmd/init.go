@@ -0,0 +1,248 @@
+package initcmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/template"
+
+	"github.com/spf13/cobra"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/cli"
+	"github.com/openshift/oc-mirror/pkg/config"
+)
+
+const (
+	// defaultVersion is the OpenShift version of the starter configuration
+	defaultVersion = "4.12"
+	// defaultStoragePath is the local directory storing the metadata
+	// when no registry is given
+	defaultStoragePath = "./oc-mirror-storage"
+	// redHatCatalog is the catalog offered for the OpenShift version
+	redHatCatalog = "registry.redhat.io/redhat/redhat-operator-index"
+)
+
+type InitOptions struct {
+	*cli.RootOptions
+	// Interactive walks through the configuration with prompts
+	Interactive bool
+	// Registry is the image storing the metadata,
+	// a local directory is used when empty
+	Registry string
+	// Version is the OpenShift version of the starter configuration
+	Version string
+	// OutputPath is the file to write the configuration to,
+	// the configuration is printed when empty
+	OutputPath string
+
+	sources sources
+}
+
+func NewInitCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
+	o := InitOptions{}
+	o.RootOptions = ro
+
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Write an imageset configuration to start from",
+		Long: templates.LongDesc(`
+			Write an imageset configuration to start from.
+
+			By default, the configuration mirrors the heads of the stable release
+			channel and of an operator of the Red Hat catalog of an OpenShift version,
+			and an additional image, to edit before use.
+
+			With --interactive, the release channels and versions are selected from
+			the channels of the OpenShift update service, the operators and their
+			channels from the packages of rendered catalogs, and the metadata storage
+			is chosen, before the configuration is validated and written. Prompts are
+			written to standard error, so the configuration can be redirected.
+		`),
+		Example: templates.Examples(`
+			# Print a starter imageset configuration storing metadata in a registry
+			oc-mirror init --registry registry.example.com/mirror/oc-mirror-metadata
+
+			# Walk through the configuration and write it to a file
+			oc-mirror init --interactive --output imageset-config.yaml
+		`),
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckErr(o.Complete())
+			kcmdutil.CheckErr(o.Run(cmd.Context()))
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.BoolVarP(&o.Interactive, "interactive", "i", o.Interactive, "Select the content and storage of the configuration with prompts")
+	fs.StringVar(&o.Registry, "registry", o.Registry, "Image to store the metadata in, instead of a local directory")
+	fs.StringVar(&o.Version, "version", defaultVersion, "OpenShift version of the starter configuration")
+	fs.StringVar(&o.OutputPath, "output", o.OutputPath, "File to write the configuration to instead of printing it")
+
+	return cmd
+}
+
+func (o *InitOptions) Complete() error {
+	if o.sources == nil {
+		o.sources = remoteSources{}
+	}
+	return nil
+}
+
+func (o *InitOptions) Run(ctx context.Context) error {
+	var cfg v1alpha2.ImageSetConfiguration
+	if o.Interactive {
+		w := &wizard{sources: o.sources, in: newPrompter(o.In, o.ErrOut), out: o.ErrOut}
+		var err error
+		if cfg, err = w.run(ctx); err != nil {
+			return err
+		}
+	} else {
+		cfg = starterConfig(o.Version, o.Registry)
+	}
+
+	data, err := renderConfig(cfg)
+	if err != nil {
+		return err
+	}
+	if o.OutputPath == "" {
+		_, err = o.Out.Write(data)
+		return err
+	}
+	if err := os.WriteFile(o.OutputPath, data, 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.ErrOut, "Imageset configuration written to %s\n", o.OutputPath)
+	return nil
+}
+
+// starterConfig returns the configuration written without prompts
+func starterConfig(version, registry string) v1alpha2.ImageSetConfiguration {
+	var cfg v1alpha2.ImageSetConfiguration
+	cfg.StorageConfig = storageConfig(registry, defaultStoragePath, false)
+	cfg.Mirror.Platform.Channels = []v1alpha2.ReleaseChannel{{Name: "stable-" + version}}
+	cfg.Mirror.Operators = []v1alpha2.Operator{{
+		Catalog: fmt.Sprintf("%s:v%s", redHatCatalog, version),
+		Full:    true,
+		IncludeConfig: v1alpha2.IncludeConfig{
+			Packages: []v1alpha2.IncludePackage{{Name: "serverless-operator", Channels: []v1alpha2.IncludeChannel{{Name: "stable"}}}},
+		},
+	}}
+	cfg.Mirror.AdditionalImages = []v1alpha2.Image{{Name: "registry.redhat.io/ubi8/ubi:latest"}}
+	return cfg
+}
+
+// storageConfig returns the storage of the metadata
+// in the registry, or in the local directory
+func storageConfig(registry, path string, skipTLS bool) v1alpha2.StorageConfig {
+	if registry != "" {
+		return v1alpha2.StorageConfig{Registry: &v1alpha2.RegistryConfig{ImageURL: registry, SkipTLS: skipTLS}}
+	}
+	return v1alpha2.StorageConfig{Local: &v1alpha2.LocalConfig{Path: path}}
+}
+
+// configTemplate writes the fields of a configuration that are set,
+// since marshaling the configuration also writes the empty fields
+var configTemplate = template.Must(template.New("config").Funcs(template.FuncMap{"quote": strconv.Quote}).Parse(
+	`apiVersion: {{ .APIVersion }}
+kind: {{ .Kind }}
+storageConfig:
+{{- with .StorageConfig.Registry }}
+  registry:
+    imageURL: {{ quote .ImageURL }}
+    skipTLS: {{ .SkipTLS }}
+{{- end }}
+{{- with .StorageConfig.Local }}
+  local:
+    path: {{ quote .Path }}
+{{- end }}
+mirror:
+{{- with .Mirror.Platform }}
+{{- if .Channels }}
+  platform:
+    channels:
+{{- range .Channels }}
+    - name: {{ quote .Name }}
+{{- if .MinVersion }}
+      minVersion: {{ quote .MinVersion }}
+{{- end }}
+{{- if .MaxVersion }}
+      maxVersion: {{ quote .MaxVersion }}
+{{- end }}
+{{- end }}
+{{- if .Graph }}
+    graph: true
+{{- end }}
+{{- end }}
+{{- end }}
+{{- if .Mirror.Operators }}
+  operators:
+{{- range .Mirror.Operators }}
+  - catalog: {{ quote .Catalog }}
+{{- if .Full }}
+    full: true
+{{- end }}
+{{- if .Packages }}
+    packages:
+{{- range .Packages }}
+    - name: {{ quote .Name }}
+{{- if .Channels }}
+      channels:
+{{- range .Channels }}
+      - name: {{ quote .Name }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- if .Mirror.AdditionalImages }}
+  additionalImages:
+{{- range .Mirror.AdditionalImages }}
+  - name: {{ quote .Name }}
+{{- end }}
+{{- end }}
+`))
+
+// renderConfig writes the configuration as YAML, and
+// checks that it loads back as a valid configuration
+func renderConfig(cfg v1alpha2.ImageSetConfiguration) ([]byte, error) {
+	cfg.SetGroupVersionKind(v1alpha2.GroupVersion.WithKind(v1alpha2.ImageSetConfigurationKind))
+	if len(cfg.Mirror.Platform.Channels) == 0 && len(cfg.Mirror.Operators) == 0 && len(cfg.Mirror.AdditionalImages) == 0 {
+		return nil, errors.New("the configuration does not mirror any content")
+	}
+	var buf bytes.Buffer
+	if err := configTemplate.Execute(&buf, cfg); err != nil {
+		return nil, err
+	}
+	loaded, err := config.LoadConfig(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Validate(&loaded); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sources are the remote sources of the release channels and operators
+type sources interface {
+	// channels returns the release channels of the OpenShift version
+	channels(ctx context.Context, version string) ([]string, error)
+	// versions returns the sorted release versions of the channel
+	versions(ctx context.Context, channel string) ([]string, error)
+	// packages returns the packages of the catalog
+	packages(ctx context.Context, catalog string) ([]catalogPackage, error)
+}
+
+// catalogPackage is a package of a catalog
+type catalogPackage struct {
+	name           string
+	defaultChannel string
+	channels       []string
+}
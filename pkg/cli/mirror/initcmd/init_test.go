@@ -0,0 +1,158 @@
+package initcmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/openshift/oc-mirror/pkg/cli"
+	"github.com/openshift/oc-mirror/pkg/config"
+)
+
+type fakeSources struct{}
+
+func (fakeSources) channels(_ context.Context, version string) ([]string, error) {
+	return []string{"candidate-" + version, "fast-" + version, "stable-" + version}, nil
+}
+
+func (fakeSources) versions(_ context.Context, channel string) ([]string, error) {
+	return []string{"4.12.1", "4.12.2", "4.12.3"}, nil
+}
+
+func (fakeSources) packages(_ context.Context, catalog string) ([]catalogPackage, error) {
+	if catalog != "registry.redhat.io/redhat/redhat-operator-index:v4.12" {
+		return nil, errors.New("not found")
+	}
+	return []catalogPackage{
+		{name: "local-storage-operator", defaultChannel: "stable", channels: []string{"preview", "stable"}},
+		{name: "serverless-operator", defaultChannel: "stable", channels: []string{"stable"}},
+	}, nil
+}
+
+func TestInit(t *testing.T) {
+	type spec struct {
+		name        string
+		interactive bool
+		registry    string
+		input       string
+		expConfig   string
+		expError    string
+	}
+	cases := []spec{
+		{
+			name:     "Valid/Starter",
+			registry: "registry.example.com/mirror/metadata",
+			expConfig: `apiVersion: mirror.openshift.io/v1alpha2
+kind: ImageSetConfiguration
+storageConfig:
+  registry:
+    imageURL: "registry.example.com/mirror/metadata"
+    skipTLS: false
+mirror:
+  platform:
+    channels:
+    - name: "stable-4.12"
+  operators:
+  - catalog: "registry.redhat.io/redhat/redhat-operator-index:v4.12"
+    full: true
+    packages:
+    - name: "serverless-operator"
+      channels:
+      - name: "stable"
+  additionalImages:
+  - name: "registry.redhat.io/ubi8/ubi:latest"
+`,
+		},
+		{
+			name:        "Valid/Interactive",
+			interactive: true,
+			input: strings.Join([]string{
+				"4.12",
+				// Unknown channels are asked again
+				"stable-4.12, eus-4.12",
+				"stable-4.12, fast-4.12",
+				"4.12.2",
+				"",
+				"",
+				"y",
+				"registry.example.com/missing:latest",
+				"registry.redhat.io/redhat/redhat-operator-index:v4.12",
+				"local-storage-operator",
+				"stable",
+				"",
+				"registry.redhat.io/ubi8/ubi:latest",
+				"",
+				"./storage",
+			}, "\n"),
+			expConfig: `apiVersion: mirror.openshift.io/v1alpha2
+kind: ImageSetConfiguration
+storageConfig:
+  local:
+    path: "./storage"
+mirror:
+  platform:
+    channels:
+    - name: "stable-4.12"
+      minVersion: "4.12.2"
+      maxVersion: "4.12.3"
+    - name: "fast-4.12"
+    graph: true
+  operators:
+  - catalog: "registry.redhat.io/redhat/redhat-operator-index:v4.12"
+    full: true
+    packages:
+    - name: "local-storage-operator"
+      channels:
+      - name: "stable"
+  additionalImages:
+  - name: "registry.redhat.io/ubi8/ubi:latest"
+`,
+		},
+		{
+			name:        "Invalid/NoContent",
+			interactive: true,
+			input:       "\n\n\n\n\n",
+			expError:    "the configuration does not mirror any content",
+		},
+		{
+			name:        "Invalid/InputEnded",
+			interactive: true,
+			input:       "4.12\n",
+			expError:    errInputEnded.Error(),
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			streams, in, out, _ := genericclioptions.NewTestIOStreams()
+			in.WriteString(c.input)
+			o := &InitOptions{
+				RootOptions: &cli.RootOptions{IOStreams: streams},
+				Interactive: c.interactive,
+				Registry:    c.registry,
+				Version:     defaultVersion,
+				OutputPath:  filepath.Join(t.TempDir(), "imageset-config.yaml"),
+				sources:     fakeSources{},
+			}
+			err := o.Run(context.Background())
+			if c.expError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Empty(t, out.String())
+			data, err := os.ReadFile(o.OutputPath)
+			require.NoError(t, err)
+			require.Equal(t, c.expConfig, string(data))
+			_, err = config.ReadConfig(o.OutputPath)
+			require.NoError(t, err)
+		})
+	}
+}
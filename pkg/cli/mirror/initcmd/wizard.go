@@ -0,0 +1,364 @@
+package initcmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/operator-framework/operator-registry/alpha/action"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/cincinnati"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+// errInputEnded is returned when the input ends before the configuration is complete
+var errInputEnded = errors.New("input ended before the configuration was complete")
+
+// prompter reads the answers to prompts line by line
+type prompter struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func newPrompter(in io.Reader, out io.Writer) *prompter {
+	return &prompter{scanner: bufio.NewScanner(in), out: out}
+}
+
+// ask prompts for an answer, which is the default answer when empty
+func (p *prompter) ask(prompt, defaultAnswer string) (string, error) {
+	if defaultAnswer != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", prompt, defaultAnswer)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", prompt)
+	}
+	// Line buffered output is flushed to show the prompt
+	if f, ok := p.out.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return "", err
+		}
+	}
+	if !p.scanner.Scan() {
+		if err := p.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", errInputEnded
+	}
+	answer := strings.TrimSpace(p.scanner.Text())
+	if answer == "" {
+		return defaultAnswer, nil
+	}
+	return answer, nil
+}
+
+// askValid prompts for an answer, prompting again until an
+// answer other than the default is accepted by valid
+func (p *prompter) askValid(prompt, defaultAnswer string, valid func(string) error) (string, error) {
+	for {
+		answer, err := p.ask(prompt, defaultAnswer)
+		if err != nil || answer == defaultAnswer {
+			return answer, err
+		}
+		if err := valid(answer); err != nil {
+			fmt.Fprintf(p.out, "%v\n", err)
+			continue
+		}
+		return answer, nil
+	}
+}
+
+// askList prompts for a comma separated list of answers,
+// prompting again until each answer is accepted by valid
+func (p *prompter) askList(prompt, defaultAnswer string, valid func(string) error) ([]string, error) {
+	for {
+		answer, err := p.ask(prompt, defaultAnswer)
+		if err != nil {
+			return nil, err
+		}
+		items, err := splitList(answer, valid)
+		if err == nil {
+			return items, nil
+		}
+		fmt.Fprintf(p.out, "%v\n", err)
+	}
+}
+
+// confirm prompts for a yes or no answer
+func (p *prompter) confirm(prompt string) (bool, error) {
+	for {
+		answer, err := p.ask(prompt+" (y/N)", "")
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			return true, nil
+		case "", "n", "no":
+			return false, nil
+		}
+		fmt.Fprintln(p.out, "Answer y or n")
+	}
+}
+
+// splitList splits a comma separated list, checking each item with valid
+func splitList(answer string, valid func(string) error) ([]string, error) {
+	var items []string
+	seen := map[string]bool{}
+	for _, item := range strings.Split(answer, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
+		}
+		if valid != nil {
+			if err := valid(item); err != nil {
+				return nil, err
+			}
+		}
+		seen[item] = true
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// wizard builds a configuration from the answers to prompts
+type wizard struct {
+	sources sources
+	in      *prompter
+	out     io.Writer
+}
+
+func (w *wizard) run(ctx context.Context) (v1alpha2.ImageSetConfiguration, error) {
+	var cfg v1alpha2.ImageSetConfiguration
+	version, err := w.in.ask("OpenShift version to mirror releases and catalogs of (e.g. 4.12), empty for none", "")
+	if err != nil {
+		return cfg, err
+	}
+	if version != "" {
+		if cfg.Mirror.Platform, err = w.platform(ctx, version); err != nil {
+			return cfg, err
+		}
+	}
+	if cfg.Mirror.Operators, err = w.operators(ctx, version); err != nil {
+		return cfg, err
+	}
+	images, err := w.in.askList("Additional images to mirror, comma separated, empty for none", "", nil)
+	if err != nil {
+		return cfg, err
+	}
+	for _, img := range images {
+		cfg.Mirror.AdditionalImages = append(cfg.Mirror.AdditionalImages, v1alpha2.Image{Name: img})
+	}
+	if cfg.StorageConfig, err = w.storage(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// platform prompts for the release channels of the version and their versions
+func (w *wizard) platform(ctx context.Context, version string) (v1alpha2.Platform, error) {
+	var platform v1alpha2.Platform
+	fmt.Fprintf(w.out, "Querying the release channels of OpenShift %s\n", version)
+	channels, err := w.sources.channels(ctx, version)
+	if err != nil {
+		return platform, fmt.Errorf("error getting the release channels of OpenShift %s: %v", version, err)
+	}
+	if len(channels) == 0 {
+		return platform, fmt.Errorf("no release channels found for OpenShift %s", version)
+	}
+	fmt.Fprintf(w.out, "Release channels: %s\n", strings.Join(channels, ", "))
+	defaultChannel := "stable-" + version
+	if !containsString(channels, defaultChannel) {
+		defaultChannel = channels[0]
+	}
+	selected, err := w.in.askList("Release channels to mirror, comma separated", defaultChannel, oneOf("release channel", channels))
+	if err != nil {
+		return platform, err
+	}
+
+	for _, name := range selected {
+		versions, err := w.sources.versions(ctx, name)
+		if err != nil {
+			return platform, fmt.Errorf("error getting the versions of release channel %s: %v", name, err)
+		}
+		ch := v1alpha2.ReleaseChannel{Name: name}
+		if len(versions) != 0 {
+			fmt.Fprintf(w.out, "Channel %s has %d versions from %s to %s\n", name, len(versions), versions[0], versions[len(versions)-1])
+			if ch.MinVersion, err = w.in.askValid(fmt.Sprintf("Minimum version of %s to mirror, empty for the channel head", name), "", oneOf("version", versions)); err != nil {
+				return platform, err
+			}
+			if ch.MinVersion != "" {
+				if ch.MaxVersion, err = w.in.askValid(fmt.Sprintf("Maximum version of %s to mirror", name), versions[len(versions)-1], oneOf("version", versions)); err != nil {
+					return platform, err
+				}
+			}
+		}
+		platform.Channels = append(platform.Channels, ch)
+	}
+
+	if platform.Graph, err = w.in.confirm("Build the update graph image for the OpenShift update service?"); err != nil {
+		return platform, err
+	}
+	return platform, nil
+}
+
+// operators prompts for catalogs, and the packages and channels of each catalog
+func (w *wizard) operators(ctx context.Context, version string) ([]v1alpha2.Operator, error) {
+	var operators []v1alpha2.Operator
+	example := "registry.example.com/catalog:latest"
+	if version != "" {
+		example = fmt.Sprintf("%s:v%s", redHatCatalog, version)
+	}
+	for {
+		prompt := fmt.Sprintf("Operator catalog to mirror (e.g. %s), empty for none", example)
+		if len(operators) != 0 {
+			prompt = "Another operator catalog to mirror, empty for none"
+		}
+		catalog, err := w.in.ask(prompt, "")
+		if err != nil {
+			return nil, err
+		}
+		if catalog == "" {
+			return operators, nil
+		}
+
+		fmt.Fprintf(w.out, "Rendering catalog %s\n", catalog)
+		pkgs, err := w.sources.packages(ctx, catalog)
+		if err != nil {
+			fmt.Fprintf(w.out, "Unable to render catalog %s: %v\n", catalog, err)
+			continue
+		}
+		byName := make(map[string]catalogPackage, len(pkgs))
+		names := make([]string, 0, len(pkgs))
+		for _, pkg := range pkgs {
+			byName[pkg.name] = pkg
+			names = append(names, pkg.name)
+		}
+		fmt.Fprintf(w.out, "Catalog %s has %d packages: %s\n", catalog, len(names), strings.Join(names, ", "))
+		selected, err := w.in.askList("Packages to mirror, comma separated, empty for the heads of all packages", "", oneOf("package", names))
+		if err != nil {
+			return nil, err
+		}
+
+		op := v1alpha2.Operator{Catalog: catalog}
+		for _, name := range selected {
+			pkg := byName[name]
+			fmt.Fprintf(w.out, "Package %s has channels %s, the default channel is %s\n", name, strings.Join(pkg.channels, ", "), pkg.defaultChannel)
+			channels, err := w.in.askList(fmt.Sprintf("Channels of %s to mirror, comma separated, empty for all channels", name), "", oneOf("channel", pkg.channels))
+			if err != nil {
+				return nil, err
+			}
+			include := v1alpha2.IncludePackage{Name: name}
+			for _, ch := range channels {
+				include.Channels = append(include.Channels, v1alpha2.IncludeChannel{Name: ch})
+			}
+			op.Packages = append(op.Packages, include)
+		}
+		// Packages can only be selected from full catalogs
+		op.Full = len(op.Packages) != 0
+		operators = append(operators, op)
+	}
+}
+
+// storage prompts for the storage of the metadata
+func (w *wizard) storage() (v1alpha2.StorageConfig, error) {
+	registry, err := w.in.ask("Image to store the metadata in (e.g. registry.example.com/mirror/oc-mirror-metadata), empty for a local directory", "")
+	if err != nil {
+		return v1alpha2.StorageConfig{}, err
+	}
+	if registry == "" {
+		path, err := w.in.ask("Local directory to store the metadata in", defaultStoragePath)
+		if err != nil {
+			return v1alpha2.StorageConfig{}, err
+		}
+		return storageConfig("", path, false), nil
+	}
+	skipTLS, err := w.in.confirm("Skip TLS verification of the metadata registry?")
+	if err != nil {
+		return v1alpha2.StorageConfig{}, err
+	}
+	return storageConfig(registry, "", skipTLS), nil
+}
+
+// oneOf returns a check that answers are one of the options
+func oneOf(kind string, options []string) func(string) error {
+	return func(answer string) error {
+		if !containsString(options, answer) {
+			return fmt.Errorf("unknown %s %q", kind, answer)
+		}
+		return nil
+	}
+}
+
+func containsString(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteSources queries the OpenShift update service and renders catalogs
+type remoteSources struct{}
+
+func (remoteSources) channels(ctx context.Context, version string) ([]string, error) {
+	client, err := cincinnati.NewOCPClient(uuid.New(), image.SharedClients())
+	if err != nil {
+		return nil, err
+	}
+	found, err := cincinnati.GetChannels(ctx, client, "stable-"+version)
+	if err != nil {
+		return nil, err
+	}
+	var channels []string
+	for ch := range found {
+		if strings.HasSuffix(ch, "-"+version) {
+			channels = append(channels, ch)
+		}
+	}
+	sort.Strings(channels)
+	return channels, nil
+}
+
+func (remoteSources) versions(ctx context.Context, channel string) ([]string, error) {
+	client, err := cincinnati.NewOCPClient(uuid.New(), image.SharedClients())
+	if err != nil {
+		return nil, err
+	}
+	found, err := cincinnati.GetVersions(ctx, client, channel)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, 0, len(found))
+	for _, v := range found {
+		versions = append(versions, v.String())
+	}
+	return versions, nil
+}
+
+func (remoteSources) packages(ctx context.Context, catalog string) ([]catalogPackage, error) {
+	lp := action.ListPackages{IndexReference: catalog}
+	res, err := lp.Run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pkgs := make([]catalogPackage, 0, len(res.Packages))
+	for _, p := range res.Packages {
+		pkg := catalogPackage{name: p.Name}
+		if p.DefaultChannel != nil {
+			pkg.defaultChannel = p.DefaultChannel.Name
+		}
+		for name := range p.Channels {
+			pkg.channels = append(pkg.channels, name)
+		}
+		sort.Strings(pkg.channels)
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].name < pkgs[j].name })
+	return pkgs, nil
+}
@@ -1,12 +1,14 @@
 package list
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 
-	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/operator-framework/operator-registry/alpha/action"
@@ -17,6 +19,7 @@ import (
 	"k8s.io/kubectl/pkg/util/templates"
 
 	"github.com/openshift/oc-mirror/pkg/cli"
+	"github.com/openshift/oc-mirror/pkg/image"
 )
 
 type OperatorsOptions struct {
@@ -26,6 +29,63 @@ type OperatorsOptions struct {
 	Channel  string
 	Version  string
 	Catalogs bool
+	Output   string
+	// Tree lists the channels of packages with their versions
+	Tree bool
+	// ConfigPath filters the tree by the content the
+	// imageset configuration selects from its catalogs
+	ConfigPath string
+}
+
+// catalogVersionsOutput is the structured output of the versions of a catalog
+type catalogVersionsOutput struct {
+	Catalog  string   `json:"catalog"`
+	Versions []string `json:"versions"`
+}
+
+// releaseCatalogsOutput is the structured output of
+// the catalogs of an OpenShift release version
+type releaseCatalogsOutput struct {
+	Version  string           `json:"version"`
+	Catalogs []releaseCatalog `json:"catalogs"`
+}
+
+type releaseCatalog struct {
+	Catalog string `json:"catalog"`
+	// Available is whether the catalog has a tag for the version
+	Available bool `json:"available"`
+}
+
+// catalogPackagesOutput is the structured output of the packages of a catalog
+type catalogPackagesOutput struct {
+	Catalog  string           `json:"catalog"`
+	Packages []catalogPackage `json:"packages"`
+}
+
+type catalogPackage struct {
+	Name           string `json:"name"`
+	DisplayName    string `json:"displayName"`
+	DefaultChannel string `json:"defaultChannel"`
+}
+
+// packageChannelsOutput is the structured output of the channels of a package
+type packageChannelsOutput struct {
+	Catalog  string           `json:"catalog"`
+	Package  string           `json:"package"`
+	Channels []packageChannel `json:"channels"`
+}
+
+type packageChannel struct {
+	Name string `json:"name"`
+	Head string `json:"head"`
+}
+
+// channelBundlesOutput is the structured output of the versions in a channel
+type channelBundlesOutput struct {
+	Catalog  string   `json:"catalog"`
+	Package  string   `json:"package"`
+	Channel  string   `json:"channel"`
+	Versions []string `json:"versions"`
 }
 
 func NewOperatorsCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
@@ -50,6 +110,15 @@ func NewOperatorsCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command
 
 			# List all available versions for a specified operator in a channel
 			oc-mirror list operators --catalog=catalog-name --package=operator-name --channel=channel-name
+
+			# Show the channels of a package with their versions and deprecations
+			oc-mirror list operators --catalog=catalog-name --package=operator-name --tree
+
+			# Show the channels and versions that an imageset configuration selects from its catalogs
+			oc-mirror list operators --config=imageset-config.yaml --tree
+
+			# List all operator packages in a catalog as YAML
+			oc-mirror list operators --catalog=catalog-name -o yaml
 		`),
 		Run: func(cmd *cobra.Command, args []string) {
 			kcmdutil.CheckErr(o.Complete())
@@ -64,6 +133,9 @@ func NewOperatorsCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command
 	fs.StringVar(&o.Package, "package", o.Package, "List information for a specified package")
 	fs.StringVar(&o.Channel, "channel", o.Channel, "List information for a specified channel")
 	fs.StringVar(&o.Version, "version", o.Version, "Specify an OpenShift release version")
+	fs.BoolVar(&o.Tree, "tree", o.Tree, "List the channels of the packages of a catalog with their versions, default channels, and deprecations")
+	fs.StringVarP(&o.ConfigPath, "config", "c", o.ConfigPath, "Path to an imageset configuration file to list the tree of the content selected from its catalogs")
+	bindOutputFlag(fs, &o.Output)
 
 	o.BindFlags(cmd.PersistentFlags())
 
@@ -74,6 +146,9 @@ func (o *OperatorsOptions) Complete() error {
 	if len(o.Version) > 0 {
 		o.Catalogs = true
 	}
+	if len(o.ConfigPath) > 0 {
+		o.Tree = true
+	}
 	return nil
 }
 
@@ -81,13 +156,22 @@ func (o *OperatorsOptions) Validate() error {
 	if len(o.Version) == 0 && o.Catalogs {
 		return errors.New("must specify --version with --catalogs")
 	}
-	if len(o.Channel) > 0 && (len(o.Package) == 0 || len(o.Catalog) == 0) {
+	if o.Tree && len(o.Catalog) == 0 && len(o.ConfigPath) == 0 {
+		return errors.New("must specify --catalog or --config with --tree")
+	}
+	if o.Tree && o.Catalogs {
+		return errors.New("--tree cannot be used with --catalogs")
+	}
+	if o.Tree && len(o.Channel) > 0 && len(o.Package) == 0 {
+		return errors.New("must specify --package with --channel")
+	}
+	if !o.Tree && len(o.Channel) > 0 && (len(o.Package) == 0 || len(o.Catalog) == 0) {
 		return errors.New("must specify --catalog and --package with --channel")
 	}
 	if len(o.Package) > 0 && len(o.Catalog) == 0 {
 		return errors.New("must specify --catalog with --package")
 	}
-	return nil
+	return validateOutput(o.Output)
 }
 
 func (o *OperatorsOptions) Run(cmd *cobra.Command) error {
@@ -97,6 +181,8 @@ func (o *OperatorsOptions) Run(cmd *cobra.Command) error {
 
 	// Process cases from most specific to most broad
 	switch {
+	case o.Tree:
+		return o.runTree(ctx, w)
 	case len(o.Channel) > 0:
 		// Print Version for all bundles in a channel
 		var ch model.Channel
@@ -116,6 +202,14 @@ func (o *OperatorsOptions) Run(cmd *cobra.Command) error {
 			}
 		}
 
+		if structuredOutput(o.Output) {
+			out := channelBundlesOutput{Catalog: o.Catalog, Package: o.Package, Channel: o.Channel, Versions: []string{}}
+			for _, bndl := range ch.Bundles {
+				out.Versions = append(out.Versions, bndl.Version.String())
+			}
+			return writeOutput(w, o.Output, out)
+		}
+
 		if _, err := fmt.Fprintln(w, "VERSIONS"); err != nil {
 			return err
 		}
@@ -134,6 +228,17 @@ func (o *OperatorsOptions) Run(cmd *cobra.Command) error {
 		if err != nil {
 			logrus.Fatal(err)
 		}
+		if structuredOutput(o.Output) {
+			out := packageChannelsOutput{Catalog: o.Catalog, Package: o.Package, Channels: []packageChannel{}}
+			for _, ch := range res.Channels {
+				head, err := ch.Head()
+				if err != nil {
+					return fmt.Errorf("error getting the head of channel %s: %v", ch.Name, err)
+				}
+				out.Channels = append(out.Channels, packageChannel{Name: ch.Name, Head: head.Name})
+			}
+			return writeOutput(w, o.Output, out)
+		}
 		if err := res.WriteColumns(o.IOStreams.Out); err != nil {
 			logrus.Fatal(err)
 		}
@@ -145,26 +250,49 @@ func (o *OperatorsOptions) Run(cmd *cobra.Command) error {
 		if err != nil {
 			logrus.Fatal(err)
 		}
+		if structuredOutput(o.Output) {
+			out := catalogPackagesOutput{Catalog: o.Catalog, Packages: []catalogPackage{}}
+			for _, pkg := range res.Packages {
+				p := catalogPackage{Name: pkg.Name, DisplayName: displayName(pkg)}
+				if pkg.DefaultChannel != nil {
+					p.DefaultChannel = pkg.DefaultChannel.Name
+				}
+				out.Packages = append(out.Packages, p)
+			}
+			return writeOutput(w, o.Output, out)
+		}
 		if err := res.WriteColumns(o.IOStreams.Out); err != nil {
 			logrus.Fatal(err)
 		}
 	case o.Catalogs:
+		if structuredOutput(o.Output) {
+			return writeOutput(w, o.Output, releaseCatalogsOutput{Version: o.Version, Catalogs: o.releaseCatalogs(ctx)})
+		}
 		if _, err := fmt.Fprintln(w, "Available OpenShift OperatorHub catalogs:"); err != nil {
 			return err
 		}
-		if err := o.listCatalogs(w); err != nil {
+		if err := o.listCatalogs(ctx, w); err != nil {
 			return err
 		}
 	default:
 
-		vm, err := getVersionMap(catalogs[0])
+		vm, err := getVersionMap(ctx, catalogs[0])
 		if err != nil {
 			return err
 		}
+		versions := make([]string, 0, len(vm))
+		for v := range vm {
+			versions = append(versions, v)
+		}
+		sort.Strings(versions)
+
+		if structuredOutput(o.Output) {
+			return writeOutput(w, o.Output, catalogVersionsOutput{Catalog: catalogs[0], Versions: versions})
+		}
 
 		fmt.Fprintln(w, "Available OpenShift OperatorHub catalog versions:")
 
-		for v := range vm {
+		for _, v := range versions {
 
 			if _, err := fmt.Fprintf(w, "  %s\n", v); err != nil {
 				return err
@@ -183,33 +311,66 @@ var catalogs = []string{
 	"registry.redhat.io/redhat/redhat-marketplace-index",
 }
 
-func (o *OperatorsOptions) listCatalogs(w io.Writer) error {
+func (o *OperatorsOptions) listCatalogs(ctx context.Context, w io.Writer) error {
 
 	if _, err := fmt.Fprintf(w, "OpenShift %s:\n", o.Version); err != nil {
 		return err
 	}
+	for _, catalog := range o.releaseCatalogs(ctx) {
+		if catalog.Available {
+			fmt.Fprintf(w, "%s\n", catalog.Catalog)
+		} else {
+			fmt.Fprintf(w, "Invalid catalog reference, please check version: %s\n", catalog.Catalog)
+		}
+	}
+	return nil
+}
+
+// releaseCatalogs returns the default catalogs of the OpenShift version.
+// Catalogs whose versions cannot be listed are left out.
+func (o *OperatorsOptions) releaseCatalogs(ctx context.Context) []releaseCatalog {
+	result := []releaseCatalog{}
 	for _, catalog := range catalogs {
-		versions, err := getVersionMap(catalog)
+		versions, err := getVersionMap(ctx, catalog)
 		if err != nil {
 			logrus.Error("Failed to get catalog version details: ", err)
 			continue
 		}
+		result = append(result, releaseCatalog{
+			Catalog:   fmt.Sprintf("%s:v%s", catalog, o.Version),
+			Available: versions["v"+o.Version] > 0,
+		})
+	}
+	return result
+}
 
-		if versions["v"+o.Version] > 0 {
-			fmt.Fprintf(w, "%s:v%s\n", catalog, o.Version)
-		} else {
-			fmt.Fprintf(w, "Invalid catalog reference, please check version: %s:v%s\n", catalog, o.Version)
-		}
+// displayName returns the display name of the CSV of the
+// head of the default channel of the package, if any
+func displayName(pkg model.Package) string {
+	if pkg.DefaultChannel == nil {
+		return ""
 	}
-	return nil
+	head, err := pkg.DefaultChannel.Head()
+	if err != nil || head == nil || head.CsvJSON == "" {
+		return ""
+	}
+	var csv struct {
+		Spec struct {
+			DisplayName string `json:"displayName"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal([]byte(head.CsvJSON), &csv); err != nil {
+		return ""
+	}
+	return csv.Spec.DisplayName
 }
 
-func getVersionMap(c string) (map[string]int, error) {
+func getVersionMap(ctx context.Context, c string) (map[string]int, error) {
 	repo, err := name.NewRepository(c)
 	if err != nil {
 		return nil, err
 	}
-	versionTags, err := remote.List(repo, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	versionTags, err := remote.List(repo, image.SharedClients().RemoteOptions(ctx, false)...)
 	if err != nil {
 		return nil, err
 	}
@@ -32,6 +32,16 @@ func TestOperatorsComplete(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Valid/ConfigSet",
+			opts: &OperatorsOptions{
+				ConfigPath: "imageset-config.yaml",
+			},
+			expOpts: &OperatorsOptions{
+				ConfigPath: "imageset-config.yaml",
+				Tree:       true,
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -85,6 +95,47 @@ func TestOperatorsValidate(t *testing.T) {
 			},
 			expError: `must specify --catalog and --package with --channel`,
 		},
+		{
+			name: "Invalid/Output",
+			opts: &OperatorsOptions{
+				Output: "table",
+			},
+			expError: `output format "table" is not supported: must be text, json, or yaml`,
+		},
+		{
+			name: "Invalid/TreeNoCatalog",
+			opts: &OperatorsOptions{
+				Tree: true,
+			},
+			expError: "must specify --catalog or --config with --tree",
+		},
+		{
+			name: "Invalid/TreeWithCatalogs",
+			opts: &OperatorsOptions{
+				Tree:     true,
+				Catalogs: true,
+				Version:  "4.12",
+				Catalog:  "foo-catalog",
+			},
+			expError: "--tree cannot be used with --catalogs",
+		},
+		{
+			name: "Invalid/TreeNoPackage",
+			opts: &OperatorsOptions{
+				Tree:    true,
+				Catalog: "foo-catalog",
+				Channel: "foo-channel",
+			},
+			expError: "must specify --package with --channel",
+		},
+		{
+			name: "Valid/TreeConfig",
+			opts: &OperatorsOptions{
+				Tree:       true,
+				ConfigPath: "imageset-config.yaml",
+			},
+			expError: "",
+		},
 		{
 			name: "Valid/Catalogs",
 			opts: &OperatorsOptions{
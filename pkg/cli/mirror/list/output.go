@@ -0,0 +1,56 @@
+package list
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// textOutput is the human readable output of the list subcommands
+	textOutput = "text"
+	jsonOutput = "json"
+	yamlOutput = "yaml"
+)
+
+// bindOutputFlag binds the output format flag of a list subcommand
+func bindOutputFlag(fs *pflag.FlagSet, output *string) {
+	fs.StringVarP(output, "output", "o", textOutput, "Output format: text, json, or yaml")
+}
+
+// validateOutput checks the output format of a list subcommand,
+// which is text when empty
+func validateOutput(output string) error {
+	switch output {
+	case "", textOutput, jsonOutput, yamlOutput:
+		return nil
+	default:
+		return fmt.Errorf("output format %q is not supported: must be %s, %s, or %s", output, textOutput, jsonOutput, yamlOutput)
+	}
+}
+
+// structuredOutput returns whether the output format is JSON or YAML
+func structuredOutput(output string) bool {
+	return output == jsonOutput || output == yamlOutput
+}
+
+// writeOutput writes v to w as JSON or YAML. The fields of v
+// are the schema of the output, so they are only ever added to.
+func writeOutput(w io.Writer, output string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if output == yamlOutput {
+		if data, err = yaml.JSONToYAML(data); err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
@@ -0,0 +1,162 @@
+package list
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/openshift/oc-mirror/pkg/cli"
+)
+
+func TestWriteOutput(t *testing.T) {
+	out := channelVersionsOutput{
+		Channel:  "stable-4.12",
+		Versions: []string{"4.12.1", "4.12.2"},
+		Releases: []releaseInfo{
+			{Version: "4.12.1", Architectures: []string{"amd64"}, EUS: true},
+			{Version: "4.12.2", Architectures: []string{"amd64", "arm64"}, EUS: false},
+		},
+	}
+
+	type spec struct {
+		name   string
+		output string
+		exp    string
+	}
+	cases := []spec{
+		{
+			name:   "Valid/JSON",
+			output: jsonOutput,
+			exp: `{
+  "channel": "stable-4.12",
+  "eus": false,
+  "versions": [
+    "4.12.1",
+    "4.12.2"
+  ],
+  "releases": [
+    {
+      "version": "4.12.1",
+      "architectures": [
+        "amd64"
+      ],
+      "eus": true
+    },
+    {
+      "version": "4.12.2",
+      "architectures": [
+        "amd64",
+        "arm64"
+      ],
+      "eus": false
+    }
+  ]
+}
+`,
+		},
+		{
+			name:   "Valid/YAML",
+			output: yamlOutput,
+			exp: `channel: stable-4.12
+eus: false
+releases:
+- architectures:
+  - amd64
+  eus: true
+  version: 4.12.1
+- architectures:
+  - amd64
+  - arm64
+  eus: false
+  version: 4.12.2
+versions:
+- 4.12.1
+- 4.12.2
+`,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, writeOutput(&buf, c.output, out))
+			require.Equal(t, c.exp, buf.String())
+		})
+	}
+}
+
+func TestValidateOutput(t *testing.T) {
+	for _, output := range []string{"", textOutput, jsonOutput, yamlOutput} {
+		require.NoError(t, validateOutput(output))
+	}
+	err := validateOutput("table")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `output format "table" is not supported`)
+}
+
+func TestWriteUpdates(t *testing.T) {
+	size := int64(2048)
+	updates := updatesOutput{
+		Releases: []releaseUpdates{
+			{Channel: "stable-4.12", Versions: []string{"4.12.2"}},
+			{Channel: "fast-4.12", Versions: []string{}},
+		},
+		Operators: []catalogUpdates{
+			{Catalog: "registry.redhat.io/redhat/redhat-operator-index:v4.12", Bundles: []bundleUpdate{
+				{Package: "foo", Channel: "stable", Bundle: "foo.v0.2.0", Replaces: "foo.v0.1.0"},
+			}},
+		},
+		EstimatedSize: &size,
+	}
+
+	type spec struct {
+		name   string
+		output string
+		exp    string
+	}
+	cases := []spec{
+		{
+			name:   "Valid/Text",
+			output: textOutput,
+			exp: `TARGET CHANNEL:  stable-4.12
+VERSIONS
+4.12.2
+No updates found for release channel fast-4.12
+Listing update for catalog:  registry.redhat.io/redhat/redhat-operator-index:v4.12
+PACKAGE                      CHANNEL  BUNDLE      REPLACES
+foo                          stable   foo.v0.2.0  foo.v0.1.0
+ESTIMATED DOWNLOAD SIZE: 2.0 KiB
+`,
+		},
+		{
+			name:   "Valid/YAML",
+			output: yamlOutput,
+			exp: `estimatedSize: 2048
+operators:
+- bundles:
+  - bundle: foo.v0.2.0
+    channel: stable
+    package: foo
+    replaces: foo.v0.1.0
+  catalog: registry.redhat.io/redhat/redhat-operator-index:v4.12
+releases:
+- channel: stable-4.12
+  versions:
+  - 4.12.2
+- channel: fast-4.12
+  versions: []
+`,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			streams, _, out, _ := genericclioptions.NewTestIOStreams()
+			o := UpdatesOptions{RootOptions: &cli.RootOptions{IOStreams: streams}, Output: c.output}
+			require.NoError(t, o.writeUpdates(updates))
+			require.Equal(t, c.exp, out.String())
+		})
+	}
+}
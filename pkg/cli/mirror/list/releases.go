@@ -8,8 +8,9 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"text/tabwriter"
 
-	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/blang/semver/v4"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/uuid"
@@ -19,6 +20,8 @@ import (
 
 	"github.com/openshift/oc-mirror/pkg/cincinnati"
 	"github.com/openshift/oc-mirror/pkg/cli"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
 )
 
 type ReleasesOptions struct {
@@ -26,6 +29,7 @@ type ReleasesOptions struct {
 	Channel  string
 	Channels bool
 	Version  string
+	Output   string
 }
 
 // used to capture major.minor version from release tags
@@ -34,6 +38,45 @@ type releaseVersion struct {
 	minor int
 }
 
+// releaseInfo annotates a release version with the architectures it is
+// available for and whether it is an Extended Update Support release
+type releaseInfo struct {
+	Version       string   `json:"version"`
+	Architectures []string `json:"architectures"`
+	EUS           bool     `json:"eus"`
+}
+
+// releaseVersionsOutput is the structured output of the release versions
+type releaseVersionsOutput struct {
+	Versions []string      `json:"versions"`
+	Releases []releaseInfo `json:"releases"`
+}
+
+// releaseChannelsOutput is the structured output of the channels of a version
+type releaseChannelsOutput struct {
+	Version     string   `json:"version"`
+	Channels    []string `json:"channels"`
+	EUSChannels []string `json:"eusChannels"`
+}
+
+// channelVersionsOutput is the structured output of the versions in a channel
+type channelVersionsOutput struct {
+	Channel  string        `json:"channel"`
+	EUS      bool          `json:"eus"`
+	Versions []string      `json:"versions"`
+	Releases []releaseInfo `json:"releases"`
+}
+
+// releaseTagArchitectures maps the architecture suffixes of
+// the release image tags to Cincinnati architectures
+var releaseTagArchitectures = map[string]string{
+	"x86_64":  "amd64",
+	"aarch64": "arm64",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+	"multi":   "multi",
+}
+
 const OCPReleaseRepo = "quay.io/openshift-release-dev/ocp-release"
 
 func NewReleasesCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
@@ -55,6 +98,10 @@ func NewReleasesCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command
 
 			# List all OpenShift channels for a specific version
 			oc-mirror list releases --channels --version=4.8
+
+			# List all OpenShift versions in a specified channel with their
+			# architectures and Extended Update Support (EUS) status as JSON
+			oc-mirror list releases --channel=stable-4.8 -o json
 		`),
 		Run: func(cmd *cobra.Command, args []string) {
 			kcmdutil.CheckErr(o.Complete())
@@ -67,6 +114,7 @@ func NewReleasesCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command
 	fs.StringVar(&o.Channel, "channel", o.Channel, "List information for a specified channel")
 	fs.BoolVar(&o.Channels, "channels", o.Channels, "List all channel information")
 	fs.StringVar(&o.Version, "version", o.Version, "Specify an OpenShift release version")
+	bindOutputFlag(fs, &o.Output)
 
 	o.BindFlags(cmd.PersistentFlags())
 
@@ -87,31 +135,53 @@ func (o *ReleasesOptions) Validate() error {
 	if o.Channel == "stable-" {
 		return errors.New("must specify --version or --channel")
 	}
-	return nil
+	return validateOutput(o.Output)
 }
 
 func (o *ReleasesOptions) Run(ctx context.Context) error {
 
 	w := o.IOStreams.Out
 
-	client, err := cincinnati.NewOCPClient(uuid.New())
-	if err != nil {
-		return err
+	id := uuid.New()
+	newClient := func() (cincinnati.Client, error) {
+		return cincinnati.NewOCPClient(id, image.SharedClients())
 	}
 
 	if o.Channels {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
 		return listChannelsForVersion(ctx, client, o, w)
 	}
 
 	if len(o.Channel) == 0 {
-		return listOCPReleaseVersions(w)
+		return listOCPReleaseVersions(ctx, o, w)
 	}
 
-	return listChannels(o, w, ctx, client)
+	return listChannels(o, w, ctx, newClient)
 
 }
 
-func listChannels(o *ReleasesOptions, w io.Writer, ctx context.Context, client cincinnati.Client) error {
+func listChannels(o *ReleasesOptions, w io.Writer, ctx context.Context, newClient func() (cincinnati.Client, error)) error {
+	releases, err := channelReleases(ctx, newClient, o.Channel)
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput(o.Output) {
+		out := channelVersionsOutput{
+			Channel:  o.Channel,
+			EUS:      cincinnati.IsEUSChannel(o.Channel),
+			Versions: make([]string, 0, len(releases)),
+			Releases: releases,
+		}
+		for _, release := range releases {
+			out.Versions = append(out.Versions, release.Version)
+		}
+		return writeOutput(w, o.Output, out)
+	}
+
 	// By default, the stable channel versions will be listed
 	if strings.HasPrefix(o.Channel, "stable") {
 		if _, err := fmt.Fprintln(w, "Listing stable channels. Use --channel=<channel-name> to filter."); err != nil {
@@ -125,59 +195,149 @@ func listChannels(o *ReleasesOptions, w io.Writer, ctx context.Context, client c
 		}
 	}
 
-	vers, err := cincinnati.GetVersions(ctx, client, o.Channel)
-	if err != nil {
+	if _, err := fmt.Fprintf(w, "Channel: %v%s\n", o.Channel, eusNote(cincinnati.IsEUSChannel(o.Channel))); err != nil {
 		return err
 	}
-
-	if _, err := fmt.Fprintf(w, "Channel: %v\n", o.Channel); err != nil {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "VERSION\tARCHITECTURES\tEUS"); err != nil {
 		return err
 	}
-	for _, ver := range vers {
-		if _, err := fmt.Fprintf(w, "%s\n", ver); err != nil {
+	for _, release := range releases {
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%t\n", release.Version, strings.Join(release.Architectures, ","), release.EUS); err != nil {
 			return err
 		}
 	}
-	return nil
+	return tw.Flush()
+}
+
+// channelReleases queries the channel for each supported architecture
+// and returns its versions, sorted from the lowest, with the
+// architectures they are available for
+func channelReleases(ctx context.Context, newClient func() (cincinnati.Client, error), channel string) ([]releaseInfo, error) {
+	archs := make([]string, 0, len(config.SupportedArchitectures))
+	for arch := range config.SupportedArchitectures {
+		archs = append(archs, arch)
+	}
+	sort.Strings(archs)
+
+	releasesByVersion := map[string]*releaseInfo{}
+	var vers []semver.Version
+	for _, arch := range archs {
+		// The query parameters of a client are only ever added to,
+		// so each architecture is queried with a new client.
+		client, err := newClient()
+		if err != nil {
+			return nil, err
+		}
+		updates, err := cincinnati.GetUpdatesInRange(ctx, client, channel, arch, func(semver.Version) bool { return true })
+		if err != nil {
+			return nil, err
+		}
+		for _, update := range updates {
+			release, ok := releasesByVersion[update.Version.String()]
+			if !ok {
+				release = &releaseInfo{Version: update.Version.String(), Architectures: []string{}}
+				releasesByVersion[release.Version] = release
+				vers = append(vers, update.Version)
+			}
+			release.Architectures = append(release.Architectures, arch)
+			release.EUS = release.EUS || update.IsEUS()
+		}
+	}
+
+	if len(vers) == 0 {
+		return nil, &cincinnati.Error{
+			Reason:  "NoVersionsFound",
+			Message: fmt.Sprintf("no cluster versions found in the %q channel", channel),
+		}
+	}
+
+	semver.Sort(vers)
+	releases := make([]releaseInfo, 0, len(vers))
+	for _, ver := range vers {
+		releases = append(releases, *releasesByVersion[ver.String()])
+	}
+	return releases, nil
 }
 
 func listChannelsForVersion(ctx context.Context, client cincinnati.Client, o *ReleasesOptions, w io.Writer) error {
-	channels, err := cincinnati.GetChannels(ctx, client, o.Channel)
+	found, err := cincinnati.GetChannels(ctx, client, o.Channel)
 	if err != nil {
 		return err
 	}
+	channels := make([]string, 0, len(found))
+	eusChannels := []string{}
+	for channel := range found {
+		channels = append(channels, channel)
+		if cincinnati.IsEUSChannel(channel) {
+			eusChannels = append(eusChannels, channel)
+		}
+	}
+	sort.Strings(channels)
+	sort.Strings(eusChannels)
+
+	if structuredOutput(o.Output) {
+		return writeOutput(w, o.Output, releaseChannelsOutput{Version: o.Version, Channels: channels, EUSChannels: eusChannels})
+	}
 
 	if _, err := fmt.Fprintf(w, "Listing channels for version %v.\n\n", o.Version); err != nil {
 		return err
 	}
-	for channel := range channels {
-		if _, err := fmt.Fprintf(w, "%s\n", channel); err != nil {
+	for _, channel := range channels {
+		if _, err := fmt.Fprintf(w, "%s%s\n", channel, eusNote(cincinnati.IsEUSChannel(channel))); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func listOCPReleaseVersions(w io.Writer) error {
+func listOCPReleaseVersions(ctx context.Context, o *ReleasesOptions, w io.Writer) error {
 
 	repo, err := name.NewRepository(OCPReleaseRepo)
 	if err != nil {
 		return err
 	}
-	versionTags, err := remote.List(repo, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	versionTags, err := remote.List(repo, image.SharedClients().RemoteOptions(ctx, false)...)
 	if err != nil {
 		return err
 	}
 
 	versions := parseVersionTags(versionTags)
+	archs := parseVersionArchitectures(versionTags)
+
+	releases := make([]releaseInfo, 0, len(versions))
+	for _, ver := range versions {
+		release := releaseInfo{Version: ver.String(), Architectures: archs[ver.String()], EUS: ver.isEUS()}
+		if release.Architectures == nil {
+			release.Architectures = []string{}
+		}
+		releases = append(releases, release)
+	}
+
+	if structuredOutput(o.Output) {
+		out := releaseVersionsOutput{Versions: make([]string, 0, len(versions)), Releases: releases}
+		for _, ver := range versions {
+			out.Versions = append(out.Versions, ver.String())
+		}
+		return writeOutput(w, o.Output, out)
+	}
 
 	fmt.Fprint(w, "Available OpenShift Container Platform release versions: \n")
 
-	for _, ver := range versions {
-		fmt.Fprintf(w, "  %s\n", ver.String())
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, release := range releases {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", release.Version, strings.Join(release.Architectures, ","), strings.TrimSpace(eusNote(release.EUS)))
 	}
 
-	return nil
+	return tw.Flush()
+}
+
+// eusNote marks Extended Update Support channels and versions
+func eusNote(eus bool) string {
+	if eus {
+		return " (EUS)"
+	}
+	return ""
 }
 
 // Parse all the release image tags, and create a list of just the major.minor versions.
@@ -207,6 +367,45 @@ func parseVersionTags(versionTags []string) []releaseVersion {
 	return versions
 }
 
+// parseVersionArchitectures returns the architectures of the major.minor
+// versions of the release image tags, from the architecture suffix of the tags
+func parseVersionArchitectures(versionTags []string) map[string][]string {
+	found := map[string]map[string]struct{}{}
+	for _, tag := range versionTags {
+		r := releaseVersion{}
+		if err := r.parseTag(tag); err != nil {
+			continue
+		}
+		idx := strings.LastIndex(tag, "-")
+		if idx == -1 {
+			continue
+		}
+		arch, ok := releaseTagArchitectures[tag[idx+1:]]
+		if !ok {
+			continue
+		}
+		if _, ok := found[r.String()]; !ok {
+			found[r.String()] = map[string]struct{}{}
+		}
+		found[r.String()][arch] = struct{}{}
+	}
+
+	archs := make(map[string][]string, len(found))
+	for ver, set := range found {
+		for arch := range set {
+			archs[ver] = append(archs[ver], arch)
+		}
+		sort.Strings(archs[ver])
+	}
+	return archs
+}
+
+// isEUS returns whether the version is an Extended Update Support release.
+// Every even-numbered minor version of OpenShift 4 from 4.6 is an EUS release.
+func (r *releaseVersion) isEUS() bool {
+	return r.major == 4 && r.minor >= 6 && r.minor%2 == 0
+}
+
 func (r *releaseVersion) String() string {
 	return fmt.Sprintf("%d.%d", r.major, r.minor)
 }
@@ -1,10 +1,19 @@
 package list
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 
-	"github.com/openshift/oc-mirror/pkg/cli"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/cincinnati"
+	"github.com/openshift/oc-mirror/pkg/cli"
 )
 
 func TestReleasesComplete(t *testing.T) {
@@ -71,6 +80,13 @@ func TestReleasesValidate(t *testing.T) {
 			},
 			expError: `must specify --version`,
 		},
+		{
+			name: "Invalid/Output",
+			opts: &ReleasesOptions{
+				Output: "table",
+			},
+			expError: `output format "table" is not supported: must be text, json, or yaml`,
+		},
 		{
 			name: "Valid/Channels",
 			opts: &ReleasesOptions{
@@ -126,3 +142,124 @@ func TestParseVersionTags(t *testing.T) {
 	require.Equal(t, len(expected), len(verlist))
 
 }
+
+func TestParseVersionArchitectures(t *testing.T) {
+	tags := []string{
+		"4.11.2-x86_64",
+		"4.12.1-x86_64",
+		"4.12.1-aarch64",
+		"4.12.2-multi",
+		"4.12.2-x86_64",
+		"4.13.0-rc.1-s390x",
+		"4.13.0-other",
+		"4.2",
+		"sometag",
+	}
+	require.Equal(t, map[string][]string{
+		"4.11": {"amd64"},
+		"4.12": {"amd64", "arm64", "multi"},
+		"4.13": {"s390x"},
+	}, parseVersionArchitectures(tags))
+}
+
+func TestReleaseVersionIsEUS(t *testing.T) {
+	for _, r := range []releaseVersion{{major: 4, minor: 6}, {major: 4, minor: 12}} {
+		require.True(t, r.isEUS(), r.String())
+	}
+	for _, r := range []releaseVersion{{major: 4, minor: 4}, {major: 4, minor: 11}, {major: 5, minor: 0}} {
+		require.False(t, r.isEUS(), r.String())
+	}
+}
+
+func TestListChannels(t *testing.T) {
+	graphs := map[string]string{
+		"amd64": `{"nodes": [
+			{"version": "4.12.2", "payload": "quay.io/openshift-release-dev/ocp-release:4.12.2-x86_64",
+			 "metadata": {"io.openshift.upgrades.graph.release.channels": "fast-4.12,stable-4.12"}},
+			{"version": "4.12.1", "payload": "quay.io/openshift-release-dev/ocp-release:4.12.1-x86_64",
+			 "metadata": {"io.openshift.upgrades.graph.release.channels": "eus-4.12,fast-4.12,stable-4.12"}}
+		], "edges": [[1, 0]]}`,
+		"arm64": `{"nodes": [
+			{"version": "4.12.1", "payload": "quay.io/openshift-release-dev/ocp-release:4.12.1-aarch64",
+			 "metadata": {"io.openshift.upgrades.graph.release.channels": "eus-4.12,fast-4.12,stable-4.12"}}
+		], "edges": []}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		graph, ok := graphs[r.URL.Query().Get("arch")]
+		if !ok || r.URL.Query().Get("channel") != "stable-4.12" {
+			graph = `{"nodes": [], "edges": []}`
+		}
+		fmt.Fprint(w, graph)
+	}))
+	t.Cleanup(server.Close)
+	newClient := func() (cincinnati.Client, error) {
+		u, err := url.Parse(server.URL)
+		if err != nil {
+			return nil, err
+		}
+		return &testCincinnatiClient{url: u}, nil
+	}
+
+	type spec struct {
+		name     string
+		channel  string
+		exp      string
+		expError string
+	}
+	cases := []spec{
+		{
+			name:    "Valid/Channel",
+			channel: "stable-4.12",
+			exp: `Listing stable channels. Use --channel=<channel-name> to filter.
+Use oc-mirror list release --channels to discover other channels.
+
+Channel: stable-4.12
+VERSION  ARCHITECTURES  EUS
+4.12.1   amd64,arm64    true
+4.12.2   amd64          false
+`,
+		},
+		{
+			name:     "Invalid/NoVersions",
+			channel:  "stable-4.99",
+			expError: `NoVersionsFound: no cluster versions found in the "stable-4.99" channel`,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			o := &ReleasesOptions{Channel: c.channel}
+			err := listChannels(o, &buf, context.Background(), newClient)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, buf.String())
+		})
+	}
+}
+
+type testCincinnatiClient struct {
+	url *url.URL
+}
+
+func (c *testCincinnatiClient) GetID() uuid.UUID {
+	return uuid.MustParse("01234567-0123-0123-0123-0123456789ab")
+}
+
+func (c *testCincinnatiClient) SetQueryParams(arch, channel, _ string) {
+	queryParams := c.url.Query()
+	queryParams.Add("arch", arch)
+	queryParams.Add("channel", channel)
+	c.url.RawQuery = queryParams.Encode()
+}
+
+func (c *testCincinnatiClient) GetURL() *url.URL {
+	return c.url
+}
+
+func (c *testCincinnatiClient) GetTransport() *http.Transport {
+	return &http.Transport{}
+}
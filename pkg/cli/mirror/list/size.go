@@ -0,0 +1,162 @@
+package list
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+// imageReferencesFile lists the component images of a release payload
+const imageReferencesFile = "release-manifests/image-references"
+
+// sizeEstimator sums the size of image blobs
+// that have not been previously mirrored
+type sizeEstimator struct {
+	opts  []crane.Option
+	seen  map[string]struct{}
+	total int64
+}
+
+// newSizeEstimator creates a sizeEstimator that skips
+// the layers recorded in the past associations
+func newSizeEstimator(assocs []v1alpha2.Association, opts ...crane.Option) *sizeEstimator {
+	e := &sizeEstimator{
+		opts: opts,
+		seen: map[string]struct{}{},
+	}
+	for _, assoc := range assocs {
+		for _, layer := range assoc.LayerDigests {
+			e.seen[layer] = struct{}{}
+		}
+	}
+	return e
+}
+
+// addImage adds the size of the new blobs of img
+func (e *sizeEstimator) addImage(ref string) (v1.Image, error) {
+	img, err := crane.Pull(ref, e.opts...)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	e.addBlob(manifest.Config)
+	for _, layer := range manifest.Layers {
+		e.addBlob(layer)
+	}
+	return img, nil
+}
+
+func (e *sizeEstimator) addBlob(desc v1.Descriptor) {
+	if _, ok := e.seen[desc.Digest.String()]; ok {
+		return
+	}
+	e.seen[desc.Digest.String()] = struct{}{}
+	e.total += desc.Size
+}
+
+// addRelease adds the size of the release payload and its component images
+func (e *sizeEstimator) addRelease(ref string) error {
+	img, err := e.addImage(ref)
+	if err != nil {
+		return err
+	}
+	components, err := releaseComponents(img)
+	if err != nil {
+		return fmt.Errorf("error reading release components of %s: %v", ref, err)
+	}
+	for _, component := range components {
+		if _, err := e.addImage(component); err != nil {
+			logrus.Warnf("unable to estimate size of %s: %v", component, err)
+		}
+	}
+	return nil
+}
+
+// releaseComponents reads the component image pullspecs of a release payload
+func releaseComponents(img v1.Image) ([]string, error) {
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+	// The release manifests are in the top layers
+	for i := len(layers) - 1; i >= 0; i-- {
+		data, err := readLayerFile(layers[i], imageReferencesFile)
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			continue
+		}
+		var is struct {
+			Spec struct {
+				Tags []struct {
+					From struct {
+						Name string `json:"name"`
+					} `json:"from"`
+				} `json:"tags"`
+			} `json:"spec"`
+		}
+		if err := json.Unmarshal(data, &is); err != nil {
+			return nil, err
+		}
+		var components []string
+		for _, tag := range is.Spec.Tags {
+			if tag.From.Name != "" {
+				components = append(components, tag.From.Name)
+			}
+		}
+		return components, nil
+	}
+	return nil, fmt.Errorf("%s not found", imageReferencesFile)
+}
+
+// readLayerFile returns the contents of the file at path in layer
+// or nil if the layer does not contain it
+func readLayerFile(layer v1.Layer, path string) ([]byte, error) {
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimPrefix(hdr.Name, "./") == path {
+			return ioutil.ReadAll(tr)
+		}
+	}
+}
+
+// formatSize formats a size in bytes with a binary unit suffix
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
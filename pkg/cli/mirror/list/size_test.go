@@ -0,0 +1,84 @@
+package list
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+func TestSizeEstimator(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	var layers []v1.Layer
+	for _, content := range []string{"layer1", "layer2"} {
+		content := content
+		l, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(content)), nil
+		})
+		require.NoError(t, err)
+		layers = append(layers, l)
+	}
+	img, err := mutate.AppendLayers(empty.Image, layers...)
+	require.NoError(t, err)
+	ref := fmt.Sprintf("%s/test/image:latest", u.Host)
+	require.NoError(t, crane.Push(img, ref))
+
+	manifest, err := img.Manifest()
+	require.NoError(t, err)
+	layer := manifest.Layers[0]
+
+	type spec struct {
+		name   string
+		assocs []v1alpha2.Association
+		exp    int64
+	}
+
+	cases := []spec{
+		{
+			name: "Valid/NoPastLayers",
+			exp:  manifest.Config.Size + manifest.Layers[0].Size + manifest.Layers[1].Size,
+		},
+		{
+			name: "Valid/SkipPastLayers",
+			assocs: []v1alpha2.Association{
+				{LayerDigests: []string{layer.Digest.String()}},
+			},
+			exp: manifest.Config.Size + manifest.Layers[1].Size,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := newSizeEstimator(c.assocs)
+			_, err := e.addImage(ref)
+			require.NoError(t, err)
+			// Blobs are only counted once
+			_, err = e.addImage(ref)
+			require.NoError(t, err)
+			require.Equal(t, c.exp, e.total)
+		})
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	require.Equal(t, "512 B", formatSize(512))
+	require.Equal(t, "1.5 KiB", formatSize(1536))
+	require.Equal(t, "2.0 GiB", formatSize(2<<30))
+}
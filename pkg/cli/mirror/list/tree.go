@@ -0,0 +1,342 @@
+package list
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/operator-framework/operator-registry/alpha/action"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/model"
+	"github.com/operator-framework/operator-registry/pkg/image/containerdregistry"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+const (
+	// schemaDeprecations is the schema of the deprecations of a package
+	schemaDeprecations = "olm.deprecations"
+	// propertyDeprecated marks deprecated bundles in catalogs built from sqlite databases
+	propertyDeprecated = "olm.deprecated"
+)
+
+// operatorTreeOutput is the structured output of the channel trees of packages
+type operatorTreeOutput struct {
+	Catalogs []catalogTree `json:"catalogs"`
+}
+
+type catalogTree struct {
+	Catalog  string        `json:"catalog"`
+	Packages []packageTree `json:"packages"`
+}
+
+type packageTree struct {
+	Name           string        `json:"name"`
+	DefaultChannel string        `json:"defaultChannel"`
+	Deprecation    *deprecation  `json:"deprecation,omitempty"`
+	Channels       []channelTree `json:"channels"`
+}
+
+type channelTree struct {
+	Name        string       `json:"name"`
+	Head        string       `json:"head"`
+	Deprecation *deprecation `json:"deprecation,omitempty"`
+	// Bundles are sorted from the highest version
+	Bundles []bundleNode `json:"bundles"`
+}
+
+type bundleNode struct {
+	Name        string       `json:"name"`
+	Version     string       `json:"version"`
+	Replaces    string       `json:"replaces,omitempty"`
+	Skips       []string     `json:"skips,omitempty"`
+	SkipRange   string       `json:"skipRange,omitempty"`
+	Deprecation *deprecation `json:"deprecation,omitempty"`
+}
+
+type deprecation struct {
+	Message string `json:"message"`
+}
+
+// deprecations are the deprecation messages of the
+// packages, channels, and bundles of a catalog
+type deprecations struct {
+	packages map[string]string
+	// channels are keyed by package and channel name
+	channels map[string]string
+	bundles  map[string]string
+}
+
+// runTree writes the channel trees of the packages of the catalog, or of the
+// catalogs of the imageset configuration as the mirror would select them
+func (o *OperatorsOptions) runTree(ctx context.Context, w io.Writer) error {
+	targets := []v1alpha2.Operator{{Catalog: o.Catalog}}
+	if len(o.ConfigPath) > 0 {
+		cfg, err := config.ReadConfig(o.ConfigPath)
+		if err != nil {
+			return err
+		}
+		if err := image.SharedClients().SetRegistries(cfg.Registries); err != nil {
+			return err
+		}
+		targets = nil
+		for _, ctlg := range cfg.Mirror.Operators {
+			if len(o.Catalog) == 0 || ctlg.Catalog == o.Catalog {
+				targets = append(targets, ctlg)
+			}
+		}
+		if len(targets) == 0 {
+			return fmt.Errorf("catalog %s is not in the imageset configuration", o.Catalog)
+		}
+	}
+
+	cacheDir, err := ioutil.TempDir("", "oc-mirror-list-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(cacheDir)
+	reg, err := containerdregistry.NewRegistry(
+		containerdregistry.WithRootCAs(image.SharedClients().RootCAs()),
+		containerdregistry.WithCacheDir(cacheDir),
+	)
+	if err != nil {
+		return err
+	}
+	defer reg.Destroy()
+
+	out := operatorTreeOutput{Catalogs: []catalogTree{}}
+	for _, ctlg := range targets {
+		full, err := action.Render{Registry: reg, Refs: []string{ctlg.Catalog}}.Run(ctx)
+		if err != nil {
+			return fmt.Errorf("error rendering catalog %s: %v", ctlg.Catalog, err)
+		}
+		selected := full
+		if len(o.ConfigPath) > 0 {
+			if selected, err = selectedContent(ctx, reg, ctlg, full); err != nil {
+				return fmt.Errorf("error selecting the content of catalog %s: %v", ctlg.Catalog, err)
+			}
+		}
+		tree, err := newCatalogTree(ctlg.Catalog, *selected, parseDeprecations(*full), o.Package, o.Channel)
+		if err != nil {
+			return err
+		}
+		out.Catalogs = append(out.Catalogs, tree)
+	}
+
+	if structuredOutput(o.Output) {
+		return writeOutput(w, o.Output, out)
+	}
+	return writeTree(w, out)
+}
+
+// selectedContent returns the content of the catalog that the mirror
+// selects for the configuration of the catalog, as it renders it
+// before mirroring: the full catalog, or the heads of its channels and
+// the packages and channels included by the configuration.
+func selectedContent(ctx context.Context, reg *containerdregistry.Registry, ctlg v1alpha2.Operator, full *declcfg.DeclarativeConfig) (*declcfg.DeclarativeConfig, error) {
+	hasInclude := len(ctlg.IncludeConfig.Packages) != 0
+	if !ctlg.IsHeadsOnly() && !hasInclude {
+		return full, nil
+	}
+	dic, err := ctlg.IncludeConfig.ConvertToDiffIncludeConfig()
+	if err != nil {
+		return nil, err
+	}
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	return action.Diff{
+		Registry:          reg,
+		NewRefs:           []string{ctlg.Catalog},
+		Logger:            logrus.NewEntry(logger),
+		IncludeConfig:     dic,
+		IncludeAdditively: ctlg.IsHeadsOnly() && hasInclude,
+		SkipDependencies:  ctlg.SkipDependencies,
+	}.Run(ctx)
+}
+
+// parseDeprecations reads the olm.deprecations blobs of the
+// catalog and the olm.deprecated properties of its bundles
+func parseDeprecations(dc declcfg.DeclarativeConfig) deprecations {
+	deps := deprecations{
+		packages: map[string]string{},
+		channels: map[string]string{},
+		bundles:  map[string]string{},
+	}
+	for _, meta := range dc.Others {
+		if meta.Schema != schemaDeprecations {
+			continue
+		}
+		var blob struct {
+			Package string `json:"package"`
+			Entries []struct {
+				Reference struct {
+					Schema string `json:"schema"`
+					Name   string `json:"name"`
+				} `json:"reference"`
+				Message string `json:"message"`
+			} `json:"entries"`
+		}
+		if err := json.Unmarshal(meta.Blob, &blob); err != nil {
+			logrus.Warnf("invalid %s blob of package %s: %v", schemaDeprecations, meta.Package, err)
+			continue
+		}
+		for _, entry := range blob.Entries {
+			message := strings.Join(strings.Fields(entry.Message), " ")
+			switch entry.Reference.Schema {
+			case "olm.package":
+				deps.packages[blob.Package] = message
+			case "olm.channel":
+				deps.channels[blob.Package+"/"+entry.Reference.Name] = message
+			case "olm.bundle":
+				deps.bundles[entry.Reference.Name] = message
+			}
+		}
+	}
+	for _, b := range dc.Bundles {
+		for _, prop := range b.Properties {
+			if prop.Type == propertyDeprecated {
+				if _, ok := deps.bundles[b.Name]; !ok {
+					deps.bundles[b.Name] = ""
+				}
+			}
+		}
+	}
+	return deps
+}
+
+// newCatalogTree returns the channel trees of the packages of the
+// declarative config, filtered by package and channel when set
+func newCatalogTree(catalog string, dc declcfg.DeclarativeConfig, deps deprecations, pkgName, chName string) (catalogTree, error) {
+	tree := catalogTree{Catalog: catalog, Packages: []packageTree{}}
+	m, err := declcfg.ConvertToModel(dc)
+	if err != nil {
+		return tree, fmt.Errorf("error reading catalog %s: %v", catalog, err)
+	}
+	for _, pkg := range m {
+		if len(pkgName) > 0 && pkg.Name != pkgName {
+			continue
+		}
+		pt := packageTree{Name: pkg.Name, Channels: []channelTree{}, Deprecation: lookupDeprecation(deps.packages, pkg.Name)}
+		if pkg.DefaultChannel != nil {
+			pt.DefaultChannel = pkg.DefaultChannel.Name
+		}
+		for _, ch := range pkg.Channels {
+			if len(chName) > 0 && ch.Name != chName {
+				continue
+			}
+			pt.Channels = append(pt.Channels, newChannelTree(ch, deps))
+		}
+		sort.Slice(pt.Channels, func(i, j int) bool { return pt.Channels[i].Name < pt.Channels[j].Name })
+		tree.Packages = append(tree.Packages, pt)
+	}
+	sort.Slice(tree.Packages, func(i, j int) bool { return tree.Packages[i].Name < tree.Packages[j].Name })
+	if len(pkgName) > 0 && len(tree.Packages) == 0 {
+		return tree, fmt.Errorf("package %s not found in catalog %s", pkgName, catalog)
+	}
+	return tree, nil
+}
+
+func newChannelTree(ch *model.Channel, deps deprecations) channelTree {
+	ct := channelTree{
+		Name:        ch.Name,
+		Bundles:     []bundleNode{},
+		Deprecation: lookupDeprecation(deps.channels, ch.Package.Name+"/"+ch.Name),
+	}
+	if head, err := ch.Head(); err == nil {
+		ct.Head = head.Name
+	}
+	bundles := make([]*model.Bundle, 0, len(ch.Bundles))
+	for _, b := range ch.Bundles {
+		bundles = append(bundles, b)
+	}
+	sort.Slice(bundles, func(i, j int) bool {
+		if c := bundles[i].Version.Compare(bundles[j].Version); c != 0 {
+			return c > 0
+		}
+		return bundles[i].Name < bundles[j].Name
+	})
+	for _, b := range bundles {
+		ct.Bundles = append(ct.Bundles, bundleNode{
+			Name:        b.Name,
+			Version:     b.Version.String(),
+			Replaces:    b.Replaces,
+			Skips:       b.Skips,
+			SkipRange:   b.SkipRange,
+			Deprecation: lookupDeprecation(deps.bundles, b.Name),
+		})
+	}
+	return ct
+}
+
+// lookupDeprecation returns the deprecation of key, if any
+func lookupDeprecation(m map[string]string, key string) *deprecation {
+	message, ok := m[key]
+	if !ok {
+		return nil
+	}
+	return &deprecation{Message: message}
+}
+
+// writeTree writes the channel trees as text
+func writeTree(w io.Writer, out operatorTreeOutput) error {
+	for _, ctlg := range out.Catalogs {
+		if _, err := fmt.Fprintln(w, ctlg.Catalog); err != nil {
+			return err
+		}
+		for i, pkg := range ctlg.Packages {
+			pkgPrefix, pkgIndent := treeBranch(i, len(ctlg.Packages))
+			if _, err := fmt.Fprintf(w, "%s%s (default channel: %s)%s\n", pkgPrefix, pkg.Name, pkg.DefaultChannel, deprecationNote(pkg.Deprecation)); err != nil {
+				return err
+			}
+			for j, ch := range pkg.Channels {
+				chPrefix, chIndent := treeBranch(j, len(pkg.Channels))
+				name := ch.Name
+				if ch.Name == pkg.DefaultChannel {
+					name += " (default)"
+				}
+				if _, err := fmt.Fprintf(w, "%s%s%s%s\n", pkgIndent, chPrefix, name, deprecationNote(ch.Deprecation)); err != nil {
+					return err
+				}
+				for k, b := range ch.Bundles {
+					bPrefix, _ := treeBranch(k, len(ch.Bundles))
+					line := fmt.Sprintf("%s %s", b.Version, b.Name)
+					if b.Name == ch.Head {
+						line += " (head)"
+					}
+					if _, err := fmt.Fprintf(w, "%s%s%s%s%s\n", pkgIndent, chIndent, bPrefix, line, deprecationNote(b.Deprecation)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// treeBranch returns the prefix of the i-th of n nodes,
+// and the indent of the children of the node
+func treeBranch(i, n int) (string, string) {
+	if i == n-1 {
+		return "└── ", "    "
+	}
+	return "├── ", "│   "
+}
+
+func deprecationNote(d *deprecation) string {
+	switch {
+	case d == nil:
+		return ""
+	case len(d.Message) == 0:
+		return " [deprecated]"
+	default:
+		return fmt.Sprintf(" [deprecated: %s]", d.Message)
+	}
+}
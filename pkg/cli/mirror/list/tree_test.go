@@ -0,0 +1,136 @@
+package list
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/property"
+	"github.com/stretchr/testify/require"
+)
+
+func testTreeConfig() declcfg.DeclarativeConfig {
+	bundle := func(name, version string, props ...property.Property) declcfg.Bundle {
+		return declcfg.Bundle{
+			Schema:     "olm.bundle",
+			Name:       name,
+			Package:    "foo",
+			Image:      "registry.example.com/foo-bundle:" + version,
+			Properties: append([]property.Property{property.MustBuildPackage("foo", version)}, props...),
+		}
+	}
+	return declcfg.DeclarativeConfig{
+		Packages: []declcfg.Package{
+			{Schema: "olm.package", Name: "foo", DefaultChannel: "stable"},
+			{Schema: "olm.package", Name: "bar", DefaultChannel: "stable"},
+		},
+		Channels: []declcfg.Channel{
+			{Schema: "olm.channel", Package: "foo", Name: "stable", Entries: []declcfg.ChannelEntry{
+				{Name: "foo.v0.1.0"},
+				{Name: "foo.v0.2.0", Replaces: "foo.v0.1.0"},
+				{Name: "foo.v0.3.0", Replaces: "foo.v0.2.0", SkipRange: "<0.2.0"},
+			}},
+			{Schema: "olm.channel", Package: "foo", Name: "alpha", Entries: []declcfg.ChannelEntry{
+				{Name: "foo.v0.1.0"},
+			}},
+			{Schema: "olm.channel", Package: "bar", Name: "stable", Entries: []declcfg.ChannelEntry{
+				{Name: "bar.v1.0.0"},
+			}},
+		},
+		Bundles: []declcfg.Bundle{
+			bundle("foo.v0.1.0", "0.1.0"),
+			bundle("foo.v0.2.0", "0.2.0", property.Property{Type: propertyDeprecated, Value: json.RawMessage(`{}`)}),
+			bundle("foo.v0.3.0", "0.3.0"),
+			{
+				Schema:     "olm.bundle",
+				Name:       "bar.v1.0.0",
+				Package:    "bar",
+				Image:      "registry.example.com/bar-bundle:1.0.0",
+				Properties: []property.Property{property.MustBuildPackage("bar", "1.0.0")},
+			},
+		},
+		Others: []declcfg.Meta{
+			{Schema: schemaDeprecations, Package: "foo", Blob: json.RawMessage(`{
+				"schema": "olm.deprecations",
+				"package": "foo",
+				"entries": [
+					{"reference": {"schema": "olm.channel", "name": "alpha"}, "message": "alpha is no longer\nupdated, use stable"},
+					{"reference": {"schema": "olm.bundle", "name": "foo.v0.1.0"}, "message": "foo.v0.1.0 has a CVE"}
+				]
+			}`)},
+		},
+	}
+}
+
+func TestCatalogTree(t *testing.T) {
+	dc := testTreeConfig()
+
+	type spec struct {
+		name     string
+		pkg      string
+		channel  string
+		exp      string
+		expError string
+	}
+	cases := []spec{
+		{
+			name: "Valid/Catalog",
+			exp: `registry.example.com/catalog:latest
+├── bar (default channel: stable)
+│   └── stable (default)
+│       └── 1.0.0 bar.v1.0.0 (head)
+└── foo (default channel: stable)
+    ├── alpha [deprecated: alpha is no longer updated, use stable]
+    │   └── 0.1.0 foo.v0.1.0 (head) [deprecated: foo.v0.1.0 has a CVE]
+    └── stable (default)
+        ├── 0.3.0 foo.v0.3.0 (head)
+        ├── 0.2.0 foo.v0.2.0 [deprecated]
+        └── 0.1.0 foo.v0.1.0 [deprecated: foo.v0.1.0 has a CVE]
+`,
+		},
+		{
+			name:    "Valid/Channel",
+			pkg:     "foo",
+			channel: "stable",
+			exp: `registry.example.com/catalog:latest
+└── foo (default channel: stable)
+    └── stable (default)
+        ├── 0.3.0 foo.v0.3.0 (head)
+        ├── 0.2.0 foo.v0.2.0 [deprecated]
+        └── 0.1.0 foo.v0.1.0 [deprecated: foo.v0.1.0 has a CVE]
+`,
+		},
+		{
+			name:     "Invalid/UnknownPackage",
+			pkg:      "baz",
+			expError: "package baz not found in catalog registry.example.com/catalog:latest",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			tree, err := newCatalogTree("registry.example.com/catalog:latest", dc, parseDeprecations(dc), c.pkg, c.channel)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			var buf bytes.Buffer
+			require.NoError(t, writeTree(&buf, operatorTreeOutput{Catalogs: []catalogTree{tree}}))
+			require.Equal(t, c.exp, buf.String())
+		})
+	}
+}
+
+func TestCatalogTreeOutput(t *testing.T) {
+	dc := testTreeConfig()
+	tree, err := newCatalogTree("registry.example.com/catalog:latest", dc, parseDeprecations(dc), "foo", "stable")
+	require.NoError(t, err)
+	require.Equal(t, []bundleNode{
+		{Name: "foo.v0.3.0", Version: "0.3.0", Replaces: "foo.v0.2.0", SkipRange: "<0.2.0"},
+		{Name: "foo.v0.2.0", Version: "0.2.0", Replaces: "foo.v0.1.0", Deprecation: &deprecation{}},
+		{Name: "foo.v0.1.0", Version: "0.1.0", Deprecation: &deprecation{Message: "foo.v0.1.0 has a CVE"}},
+	}, tree.Packages[0].Channels[0].Bundles)
+	require.Equal(t, "foo.v0.3.0", tree.Packages[0].Channels[0].Head)
+}
@@ -23,12 +23,43 @@ import (
 	"github.com/openshift/oc-mirror/pkg/cincinnati"
 	"github.com/openshift/oc-mirror/pkg/cli"
 	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
 	"github.com/openshift/oc-mirror/pkg/metadata/storage"
 )
 
 type UpdatesOptions struct {
 	*cli.RootOptions
-	ConfigPath string
+	ConfigPath   string
+	EstimateSize bool
+	Output       string
+}
+
+// updatesOutput is the structured output of the updates
+type updatesOutput struct {
+	Releases  []releaseUpdates `json:"releases"`
+	Operators []catalogUpdates `json:"operators"`
+	// EstimatedSize is the estimated download size in bytes
+	// of the updates, when requested
+	EstimatedSize *int64 `json:"estimatedSize,omitempty"`
+}
+
+// releaseUpdates are the versions a release channel can be updated to
+type releaseUpdates struct {
+	Channel  string   `json:"channel"`
+	Versions []string `json:"versions"`
+}
+
+// catalogUpdates are the bundles added to a catalog
+type catalogUpdates struct {
+	Catalog string         `json:"catalog"`
+	Bundles []bundleUpdate `json:"bundles"`
+}
+
+type bundleUpdate struct {
+	Package  string `json:"package"`
+	Channel  string `json:"channel"`
+	Bundle   string `json:"bundle"`
+	Replaces string `json:"replaces"`
 }
 
 func NewUpdatesCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
@@ -45,6 +76,12 @@ func NewUpdatesCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
 		Example: templates.Examples(`
 			# List updates between remote and current workspace
 			oc-mirror list updates --config mirror-config.yaml
+
+			# List updates and estimate the size of the next imageset
+			oc-mirror list updates --config mirror-config.yaml --estimate-size
+
+			# List updates as JSON
+			oc-mirror list updates --config mirror-config.yaml -o json
 		`),
 		Run: func(cmd *cobra.Command, args []string) {
 			kcmdutil.CheckErr(o.Validate())
@@ -56,6 +93,9 @@ func NewUpdatesCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
 
 	fs := cmd.Flags()
 	fs.StringVarP(&o.ConfigPath, "config", "c", o.ConfigPath, "Path to imageset configuration file")
+	fs.BoolVar(&o.EstimateSize, "estimate-size", o.EstimateSize, "Estimate the download size of the updates. "+
+		"Layers mirrored in previous runs are not counted")
+	bindOutputFlag(fs, &o.Output)
 	return cmd
 }
 
@@ -63,7 +103,7 @@ func (o *UpdatesOptions) Validate() error {
 	if len(o.ConfigPath) == 0 {
 		return fmt.Errorf("must specify config using --config")
 	}
-	return nil
+	return validateOutput(o.Output)
 }
 
 func (o *UpdatesOptions) Run(ctx context.Context) error {
@@ -71,9 +111,12 @@ func (o *UpdatesOptions) Run(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	if err := image.SharedClients().SetRegistries(cfg.Registries); err != nil {
+		return err
+	}
 
 	path := filepath.Join(o.Dir, config.SourceDir)
-	backend, err := storage.ByConfig(path, cfg.StorageConfig)
+	backend, err := storage.ByConfig(path, cfg.StorageConfig, image.SharedClients())
 	if err != nil {
 		return fmt.Errorf("error opening backend: %v", err)
 	}
@@ -85,28 +128,58 @@ func (o *UpdatesOptions) Run(ctx context.Context) error {
 	case err != nil && errors.Is(err, storage.ErrMetadataNotExist):
 		return fmt.Errorf("no metadata detected")
 	default:
+		var estimator *sizeEstimator
+		if o.EstimateSize {
+			estimator = newSizeEstimator(meta.PastAssociations, image.SharedClients().CraneOptions(ctx, false)...)
+		}
+		out := updatesOutput{Releases: []releaseUpdates{}, Operators: []catalogUpdates{}}
 		if len(cfg.Mirror.Platform.Channels) != 0 {
-			if err := o.releaseUpdates(ctx, "amd64", cfg, meta.PastMirror); err != nil {
+			if out.Releases, err = o.releaseUpdates(ctx, "amd64", cfg, meta.PastMirror, estimator); err != nil {
 				return err
 			}
 		}
 		if len(cfg.Mirror.Operators) != 0 {
-			if err := o.operatorUpdates(ctx, cfg, meta); err != nil {
+			if out.Operators, err = o.operatorUpdates(ctx, cfg, meta, estimator); err != nil {
 				return err
 			}
 		}
+		if estimator != nil {
+			out.EstimatedSize = &estimator.total
+		}
+		return o.writeUpdates(out)
 	}
+}
 
+// writeUpdates writes the updates in the output format
+func (o UpdatesOptions) writeUpdates(out updatesOutput) error {
+	if structuredOutput(o.Output) {
+		return writeOutput(o.IOStreams.Out, o.Output, out)
+	}
+	for _, updates := range out.Releases {
+		if err := o.writeReleaseColumns(updates); err != nil {
+			return err
+		}
+	}
+	for _, updates := range out.Operators {
+		if err := o.writeCatalogColumns(updates); err != nil {
+			return err
+		}
+	}
+	if out.EstimatedSize != nil {
+		if _, err := fmt.Fprintf(o.IOStreams.Out, "ESTIMATED DOWNLOAD SIZE: %s\n", formatSize(*out.EstimatedSize)); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (o UpdatesOptions) releaseUpdates(ctx context.Context, arch string, cfg v1alpha2.ImageSetConfiguration, last v1alpha2.PastMirror) error {
+func (o UpdatesOptions) releaseUpdates(ctx context.Context, arch string, cfg v1alpha2.ImageSetConfiguration, last v1alpha2.PastMirror, estimator *sizeEstimator) ([]releaseUpdates, error) {
 	logrus.Info("Getting release update information")
 	lastMaxVersion := map[string]semver.Version{}
 	for _, ch := range last.Mirror.Platform.Channels {
 		version, err := semver.Parse(ch.MaxVersion)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		lastMaxVersion[ch.Name] = version
 	}
@@ -115,21 +188,22 @@ func (o UpdatesOptions) releaseUpdates(ctx context.Context, arch string, cfg v1a
 	// versions if available
 	id := uuid.New()
 
+	var result []releaseUpdates
 	for _, ch := range cfg.Mirror.Platform.Channels {
 
 		var c cincinnati.Client
 		var err error
 		if ch.Name == cincinnati.OkdChannel {
-			c, err = cincinnati.NewOKDClient(id)
+			c, err = cincinnati.NewOKDClient(id, image.SharedClients())
 		} else {
-			c, err = cincinnati.NewOCPClient(id)
+			c, err = cincinnati.NewOCPClient(id, image.SharedClients())
 		}
 		if err != nil {
-			return err
+			return nil, err
 		}
 		latest, err := cincinnati.GetChannelMinOrMax(ctx, c, arch, ch.Name, false)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		ver, found := lastMaxVersion[ch.Name]
 		if !found {
@@ -137,26 +211,28 @@ func (o UpdatesOptions) releaseUpdates(ctx context.Context, arch string, cfg v1a
 		}
 		_, _, upgrades, err := cincinnati.GetUpdates(ctx, c, arch, ch.Name, ver, latest)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		var vers []semver.Version
+		updates := releaseUpdates{Channel: ch.Name, Versions: []string{}}
 		for _, upgrade := range upgrades {
-			vers = append(vers, upgrade.Version)
-		}
-
-		if err := o.writeReleaseColumns(vers, ch.Name); err != nil {
-			return err
+			updates.Versions = append(updates.Versions, upgrade.Version.String())
+			if estimator != nil {
+				if err := estimator.addRelease(upgrade.Image); err != nil {
+					logrus.Warnf("unable to estimate size of release %s: %v", upgrade.Version, err)
+				}
+			}
 		}
+		result = append(result, updates)
 	}
-	return nil
+	return result, nil
 }
 
-func (o UpdatesOptions) operatorUpdates(ctx context.Context, cfg v1alpha2.ImageSetConfiguration, meta v1alpha2.Metadata) error {
+func (o UpdatesOptions) operatorUpdates(ctx context.Context, cfg v1alpha2.ImageSetConfiguration, meta v1alpha2.Metadata, estimator *sizeEstimator) ([]catalogUpdates, error) {
 	logrus.Info("Getting operator update information")
 	dstDir, err := os.MkdirTemp(o.Dir, "updatetmp-")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer os.RemoveAll(dstDir)
 
@@ -172,17 +248,19 @@ func (o UpdatesOptions) operatorUpdates(ctx context.Context, cfg v1alpha2.ImageS
 
 	reg, err := containerdregistry.NewRegistry(
 		containerdregistry.SkipTLSVerify(false),
+		containerdregistry.WithRootCAs(image.SharedClients().RootCAs()),
 		containerdregistry.WithCacheDir(filepath.Join(dstDir, "cache")),
 	)
 	defer reg.Destroy()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	var result []catalogUpdates
 	for _, ctlg := range cfg.Mirror.Operators {
 		catLogger := logrus.WithField("catalog", ctlg.Catalog)
 		dic, err := ctlg.IncludeConfig.ConvertToDiffIncludeConfig()
 		if err != nil {
-			return err
+			return nil, err
 		}
 		diff := action.Diff{
 			Registry:      reg,
@@ -195,76 +273,103 @@ func (o UpdatesOptions) operatorUpdates(ctx context.Context, cfg v1alpha2.ImageS
 		}
 		dc, err := diff.Run(ctx)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		if err := o.writeCatalogColumns(*dc, ctlg.Catalog); err != nil {
-			return err
+		updates, err := newCatalogUpdates(*dc, ctlg.Catalog)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, updates)
+
+		if estimator != nil {
+			for _, b := range dc.Bundles {
+				images := []string{b.Image}
+				for _, related := range b.RelatedImages {
+					images = append(images, related.Image)
+				}
+				for _, img := range images {
+					if img == "" {
+						continue
+					}
+					if _, err := estimator.addImage(img); err != nil {
+						logrus.Warnf("unable to estimate size of %s: %v", img, err)
+					}
+				}
+			}
 		}
 	}
-	return nil
+	return result, nil
+}
+
+// newCatalogUpdates returns the bundles of the catalog diff
+func newCatalogUpdates(dc declcfg.DeclarativeConfig, catalog string) (catalogUpdates, error) {
+	updates := catalogUpdates{Catalog: catalog, Bundles: []bundleUpdate{}}
+	mod, err := declcfg.ConvertToModel(dc)
+	if err != nil {
+		return updates, err
+	}
+
+	pkgs := []model.Package{}
+	for _, pkg := range mod {
+		pkgs = append(pkgs, *pkg)
+	}
+
+	for _, pkg := range pkgs {
+		for _, ch := range pkg.Channels {
+			for _, b := range ch.Bundles {
+				updates.Bundles = append(updates.Bundles, bundleUpdate{
+					Package:  b.Package.Name,
+					Channel:  b.Channel.Name,
+					Bundle:   b.Name,
+					Replaces: b.Replaces,
+				})
+			}
+		}
+	}
+	return updates, nil
 }
 
-func (o UpdatesOptions) writeReleaseColumns(upgrades []semver.Version, channel string) error {
-	if len(upgrades) == 0 {
-		if _, err := fmt.Fprintf(os.Stdout, "No updates found for release channel %s\n", channel); err != nil {
+func (o UpdatesOptions) writeReleaseColumns(updates releaseUpdates) error {
+	if len(updates.Versions) == 0 {
+		if _, err := fmt.Fprintf(o.IOStreams.Out, "No updates found for release channel %s\n", updates.Channel); err != nil {
 			return err
 		}
 		return nil
 	}
 	tw := tabwriter.NewWriter(o.IOStreams.Out, 0, 4, 2, ' ', 0)
-	if _, err := fmt.Fprintf(tw, "TARGET CHANNEL:\t%s\n", channel); err != nil {
+	if _, err := fmt.Fprintf(tw, "TARGET CHANNEL:\t%s\n", updates.Channel); err != nil {
 		return err
 	}
 	if _, err := fmt.Fprintln(tw, "VERSIONS"); err != nil {
 		return err
 	}
-	for _, upgrade := range upgrades {
-		if _, err := fmt.Fprintf(tw, "%s\n", upgrade); err != nil {
+	for _, version := range updates.Versions {
+		if _, err := fmt.Fprintf(tw, "%s\n", version); err != nil {
 			return err
 		}
 	}
 	return tw.Flush()
 }
 
-func (o UpdatesOptions) writeCatalogColumns(dc declcfg.DeclarativeConfig, catalog string) error {
-	if len(dc.Packages) == 0 {
-		if _, err := fmt.Fprintf(os.Stdout, "No updates found for catalog %s\n", catalog); err != nil {
+func (o UpdatesOptions) writeCatalogColumns(updates catalogUpdates) error {
+	if len(updates.Bundles) == 0 {
+		if _, err := fmt.Fprintf(o.IOStreams.Out, "No updates found for catalog %s\n", updates.Catalog); err != nil {
 			return err
 		}
 		return nil
 	}
 	tw := tabwriter.NewWriter(o.IOStreams.Out, 0, 4, 2, ' ', 0)
-	if _, err := fmt.Fprintf(tw, "Listing update for catalog:\t%s", catalog); err != nil {
+	if _, err := fmt.Fprintf(tw, "Listing update for catalog:\t%s\n", updates.Catalog); err != nil {
 		return err
 	}
 	if _, err := fmt.Fprintln(tw, "PACKAGE\tCHANNEL\tBUNDLE\tREPLACES"); err != nil {
 		return err
 	}
-	mod, err := declcfg.ConvertToModel(dc)
-	if err != nil {
-		return err
-	}
-
-	pkgs := []model.Package{}
-	for _, pkg := range mod {
-		pkgs = append(pkgs, *pkg)
-	}
-
-	bundles := []model.Bundle{}
-	for _, pkg := range pkgs {
-		for _, ch := range pkg.Channels {
-			for _, b := range ch.Bundles {
-				bundles = append(bundles, *b)
-			}
-		}
-	}
-
-	for _, b := range bundles {
-		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", b.Package.Name, b.Channel.Name, b.Name, b.Replaces); err != nil {
+	for _, b := range updates.Bundles {
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", b.Package, b.Channel, b.Bundle, b.Replaces); err != nil {
 			return err
 		}
 	}
-
 	return tw.Flush()
 }
@@ -0,0 +1,247 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	units "github.com/docker/go-units"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/cli"
+)
+
+const (
+	// resultsFile is the name of the results file
+	// oc-mirror writes to the results directory of a run
+	resultsFile = "results.json"
+	// workspaceDir is the name of the workspace in a mirror directory
+	workspaceDir = "oc-mirror-workspace"
+
+	tableOutput = "table"
+	jsonOutput  = "json"
+)
+
+type HistoryOptions struct {
+	*cli.RootOptions
+	Workspace string
+	Output    string
+	Limit     int
+}
+
+// historyEntry is a previous run recorded in the workspace
+type historyEntry struct {
+	ResultsDir string            `json:"resultsDir"`
+	Results    *v1alpha2.Results `json:"results"`
+}
+
+func NewHistoryCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
+	o := HistoryOptions{}
+	o.RootOptions = ro
+
+	cmd := &cobra.Command{
+		Use:   "history [workspace]",
+		Short: "Show the timing of previous runs in a workspace",
+		Long: templates.LongDesc(`
+			Show the duration, phase timings, transfer size, and imageset configuration
+			digest of the previous runs recorded in the results directories of a workspace.
+
+			The CHANGE column compares the duration of each run to the previous
+			run of the same operation to show when incremental runs get slower.
+		`),
+		Example: templates.Examples(`
+			# Show the runs in ./oc-mirror-workspace
+			oc-mirror metadata history
+
+			# Show the last 10 runs that mirrored to ./archives
+			oc-mirror metadata history ./archives --limit 10
+
+			# Output the results of each run as JSON
+			oc-mirror metadata history -o json
+		`),
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckErr(o.Complete(args))
+			kcmdutil.CheckErr(o.Validate())
+			kcmdutil.CheckErr(o.Run(o.IOStreams.Out))
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&o.Output, "output", "o", tableOutput, "Output format: table or json")
+	fs.IntVar(&o.Limit, "limit", o.Limit, "Only show the most recent runs, 0 shows all runs")
+
+	return cmd
+}
+
+// Complete resolves the workspace from the argument, which can be the workspace
+// or a mirror directory containing one, defaulting to the workspace directory
+func (o *HistoryOptions) Complete(args []string) error {
+	o.Workspace = o.Dir
+	if len(args) == 1 {
+		o.Workspace = args[0]
+		if _, err := os.Stat(filepath.Join(args[0], workspaceDir)); err == nil {
+			o.Workspace = filepath.Join(args[0], workspaceDir)
+		}
+	}
+	return nil
+}
+
+func (o *HistoryOptions) Validate() error {
+	switch o.Output {
+	case tableOutput, jsonOutput:
+	default:
+		return fmt.Errorf("output format %q is not supported: must be %s or %s", o.Output, tableOutput, jsonOutput)
+	}
+	if o.Limit < 0 {
+		return fmt.Errorf("--limit must not be negative")
+	}
+	return nil
+}
+
+func (o *HistoryOptions) Run(out io.Writer) error {
+	entries, err := readHistory(o.Workspace)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no run results found in workspace %s", o.Workspace)
+	}
+
+	// Compare against the full history before limiting
+	changes := durationChanges(entries)
+	if o.Limit > 0 && len(entries) > o.Limit {
+		entries = entries[len(entries)-o.Limit:]
+		changes = changes[len(changes)-o.Limit:]
+	}
+
+	if o.Output == jsonOutput {
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	}
+	return writeHistory(out, entries, changes)
+}
+
+// readHistory reads the results of the runs in the workspace in the order they started
+func readHistory(workspace string) ([]historyEntry, error) {
+	// Results directories may be named with a template,
+	// so every directory with a results file is a run
+	paths, err := filepath.Glob(filepath.Join(workspace, "*", resultsFile))
+	if err != nil {
+		return nil, err
+	}
+	var entries []historyEntry
+	for _, path := range paths {
+		// Skip the link to the latest run
+		if info, err := os.Lstat(filepath.Dir(path)); err != nil || info.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		results := &v1alpha2.Results{}
+		if err := json.Unmarshal(data, results); err != nil {
+			logrus.Warnf("skipping unreadable run results %s: %v", path, err)
+			continue
+		}
+		entries = append(entries, historyEntry{ResultsDir: filepath.Dir(path), Results: results})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Results.StartTime.Before(&entries[j].Results.StartTime)
+	})
+	return entries, nil
+}
+
+// durationChanges returns the relative change of the duration of each
+// successful run from the previous successful run of the same operation
+func durationChanges(entries []historyEntry) []string {
+	changes := make([]string, len(entries))
+	previous := map[v1alpha2.Operation]float64{}
+	for i, entry := range entries {
+		changes[i] = "-"
+		results := entry.Results
+		if !results.Success {
+			continue
+		}
+		if prev, ok := previous[results.Operation]; ok && prev > 0 {
+			changes[i] = fmt.Sprintf("%+.0f%%", (results.DurationSeconds-prev)/prev*100)
+		}
+		previous[results.Operation] = results.DurationSeconds
+	}
+	return changes
+}
+
+// writeHistory writes a table of the runs
+func writeHistory(w io.Writer, entries []historyEntry, changes []string) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STARTED\tOPERATION\tSEQUENCE\tRESULT\tDURATION\tCHANGE\tIMAGES\tTRANSFERRED\tCONFIG\tPHASES")
+	for i, entry := range entries {
+		results := entry.Results
+		result := "succeeded"
+		if !results.Success {
+			result = "failed"
+		}
+		sequence := "-"
+		if results.Sequence != 0 {
+			sequence = fmt.Sprint(results.Sequence)
+		}
+		config := "-"
+		if results.ConfigDigest != "" {
+			config = shortDigest(results.ConfigDigest)
+		}
+		phases := make([]string, 0, len(results.Phases))
+		for _, phase := range results.Phases {
+			timing := fmt.Sprintf("%s=%s", phase.Name, formatSeconds(phase.DurationSeconds))
+			if !phase.Success {
+				timing += "!"
+			}
+			phases = append(phases, timing)
+		}
+		if len(phases) == 0 {
+			phases = append(phases, "-")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			results.StartTime.Local().Format("2006-01-02 15:04:05"),
+			results.Operation,
+			sequence,
+			result,
+			formatSeconds(results.DurationSeconds),
+			changes[i],
+			results.Images.Total,
+			units.BytesSize(float64(results.Transfer.Bytes)),
+			config,
+			strings.Join(phases, " "),
+		)
+	}
+	return tw.Flush()
+}
+
+// formatSeconds formats a duration in seconds rounded to the second
+func formatSeconds(seconds float64) string {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Second).String()
+}
+
+// shortDigest returns the first 12 characters of the hex of a digest
+func shortDigest(dgst string) string {
+	hex := dgst[strings.Index(dgst, ":")+1:]
+	if len(hex) > 12 {
+		hex = hex[:12]
+	}
+	return hex
+}
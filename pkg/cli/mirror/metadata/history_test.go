@@ -0,0 +1,127 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/cli"
+)
+
+func writeRun(t *testing.T, workspace string, results v1alpha2.Results) {
+	dir := filepath.Join(workspace, "results-"+results.StartTime.Format("150405"))
+	require.NoError(t, os.MkdirAll(dir, 0750))
+	data, err := json.Marshal(results)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, resultsFile), data, 0640))
+}
+
+func TestHistory(t *testing.T) {
+	mirrorDir := t.TempDir()
+	workspace := filepath.Join(mirrorDir, workspaceDir)
+	start := time.Date(2022, 3, 1, 10, 0, 0, 0, time.Local)
+	run := func(offset time.Duration, operation v1alpha2.Operation, sequence int, success bool, seconds float64) v1alpha2.Results {
+		results := v1alpha2.Results{}
+		results.Operation = operation
+		results.Sequence = sequence
+		results.Success = success
+		results.StartTime = metav1.NewTime(start.Add(offset))
+		results.DurationSeconds = seconds
+		return results
+	}
+
+	first := run(0, v1alpha2.OperationMirrorToDisk, 1, true, 600)
+	first.Images.Total = 120
+	first.Transfer.Bytes = 10 * 1024 * 1024 * 1024
+	first.ConfigDigest = "sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8"
+	first.Phases = []v1alpha2.PhaseTiming{
+		{Name: "plan", DurationSeconds: 30, Success: true},
+		{Name: "mirror", DurationSeconds: 500.4, Success: true},
+		{Name: "archive", DurationSeconds: 69.6, Success: true},
+	}
+	writeRun(t, workspace, first)
+	failed := run(time.Hour, v1alpha2.OperationMirrorToDisk, 0, false, 20)
+	failed.Phases = []v1alpha2.PhaseTiming{{Name: "plan", DurationSeconds: 20}}
+	writeRun(t, workspace, failed)
+	writeRun(t, workspace, run(2*time.Hour, v1alpha2.OperationMirrorToDisk, 2, true, 900))
+	writeRun(t, workspace, run(3*time.Hour, v1alpha2.OperationDiskToMirror, 2, true, 300))
+	// The latest link is not counted as another run
+	require.NoError(t, os.Symlink("results-130000", filepath.Join(workspace, "latest")))
+	// Unreadable results are skipped
+	require.NoError(t, os.MkdirAll(filepath.Join(workspace, "results-1"), 0750))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(workspace, "results-1", resultsFile), []byte("{"), 0640))
+
+	o := &HistoryOptions{RootOptions: &cli.RootOptions{Dir: "oc-mirror-workspace"}, Output: tableOutput}
+	require.NoError(t, o.Complete([]string{mirrorDir}))
+	require.Equal(t, workspace, o.Workspace)
+	require.NoError(t, o.Validate())
+
+	out := &bytes.Buffer{}
+	require.NoError(t, o.Run(out))
+	exp := `STARTED              OPERATION     SEQUENCE  RESULT     DURATION  CHANGE  IMAGES  TRANSFERRED  CONFIG        PHASES
+2022-03-01 10:00:00  mirrorToDisk  1         succeeded  10m0s     -       120     10GiB        3e590f0381f7  plan=30s mirror=8m20s archive=1m10s
+2022-03-01 11:00:00  mirrorToDisk  -         failed     20s       -       0       0B           -             plan=20s!
+2022-03-01 12:00:00  mirrorToDisk  2         succeeded  15m0s     +50%    0       0B           -             -
+2022-03-01 13:00:00  diskToMirror  2         succeeded  5m0s      -       0       0B           -             -
+`
+	require.Equal(t, exp, out.String())
+
+	// Changes are relative to runs before the limit
+	o.Limit = 2
+	o.Output = jsonOutput
+	out.Reset()
+	require.NoError(t, o.Run(out))
+	var entries []historyEntry
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entries))
+	require.Len(t, entries, 2)
+	require.Equal(t, filepath.Join(workspace, "results-120000"), entries[0].ResultsDir)
+	require.Equal(t, 900.0, entries[0].Results.DurationSeconds)
+	require.Equal(t, v1alpha2.OperationDiskToMirror, entries[1].Results.Operation)
+}
+
+func TestHistoryValidate(t *testing.T) {
+	type spec struct {
+		name     string
+		opts     *HistoryOptions
+		expError string
+	}
+	cases := []spec{
+		{name: "Valid/Table", opts: &HistoryOptions{Output: tableOutput}},
+		{name: "Valid/JSON", opts: &HistoryOptions{Output: jsonOutput, Limit: 5}},
+		{
+			name:     "Invalid/Output",
+			opts:     &HistoryOptions{Output: "yaml"},
+			expError: `output format "yaml" is not supported: must be table or json`,
+		},
+		{
+			name:     "Invalid/Limit",
+			opts:     &HistoryOptions{Output: tableOutput, Limit: -1},
+			expError: "--limit must not be negative",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.opts.Validate()
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestHistoryEmptyWorkspace(t *testing.T) {
+	workspace := t.TempDir()
+	o := &HistoryOptions{RootOptions: &cli.RootOptions{Dir: workspace}, Output: tableOutput}
+	require.NoError(t, o.Complete(nil))
+	require.EqualError(t, o.Run(&bytes.Buffer{}), "no run results found in workspace "+workspace)
+}
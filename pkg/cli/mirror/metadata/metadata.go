@@ -0,0 +1,26 @@
+package metadata
+
+import (
+	"github.com/spf13/cobra"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift/oc-mirror/pkg/cli"
+)
+
+func NewMetadataCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
+
+	cmd := &cobra.Command{
+		Use:   "metadata",
+		Short: "Inspect the metadata recorded in a workspace",
+		Example: templates.Examples(`
+			# Show the timing of previous runs in the workspace
+			oc-mirror metadata history
+		`),
+		Run: kcmdutil.DefaultSubCommandRun(ro.IOStreams.ErrOut),
+	}
+
+	cmd.AddCommand(NewHistoryCommand(f, ro))
+
+	return cmd
+}
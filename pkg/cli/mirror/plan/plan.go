@@ -0,0 +1,178 @@
+package plan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	units "github.com/docker/go-units"
+	"github.com/spf13/cobra"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/cli"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/mirror"
+)
+
+// Output formats of the plan
+const (
+	planJSONOutput  = "json"
+	planYAMLOutput  = "yaml"
+	planTableOutput = "table"
+)
+
+// PlanOptions configures the planning of an imageset
+type PlanOptions struct {
+	*cli.RootOptions
+	// Mirror are the options of the planning run
+	Mirror *mirror.MirrorOptions
+	// Output is the format of the plan
+	Output string
+	// Estimate prints the size of the images by category
+	// instead of the images
+	Estimate bool
+}
+
+func NewPlanCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
+	o := PlanOptions{RootOptions: ro, Mirror: cli.NewMirrorOptions()}
+
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Print the images an imageset configuration resolves to without mirroring them",
+		Long: templates.LongDesc(`
+			Resolve an imageset configuration into the images it mirrors, with their
+			digests, categories, and sizes, and print them as JSON or YAML.
+
+			Only manifests are queried to resolve and size images, so no image,
+			graph data, or boot image is downloaded. The plan can be reviewed or
+			checked before creating the imageset.
+
+			With --estimate, the total size of the images of each category is
+			printed instead, to size removable media and registry storage before
+			creating the imageset. Sizes are the sizes reported by manifests, which
+			are compressed sizes, and count blobs shared between images once.
+		`),
+		Example: templates.Examples(`
+			# Print the images of an imageset configuration as JSON
+			oc-mirror plan --config imageset-config.yaml
+
+			# Print the images of an imageset configuration as YAML
+			oc-mirror plan --config imageset-config.yaml -o yaml
+
+			# Print the estimated size of an imageset configuration by category
+			oc-mirror plan --config imageset-config.yaml --estimate -o table
+		`),
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckErr(o.Validate())
+			kcmdutil.CheckErr(o.Run(cmd.Context()))
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&o.Mirror.ConfigPath, "config", "c", o.Mirror.ConfigPath, "Path to the imageset configuration file to plan")
+	fs.StringVarP(&o.Output, "output", "o", planJSONOutput, "Output format: json or yaml, or table with --estimate")
+	fs.BoolVar(&o.Estimate, "estimate", o.Estimate, "Print the estimated size of the images by category instead of the images")
+	fs.BoolVar(&o.Mirror.SourceSkipTLS, "source-skip-tls", o.Mirror.SourceSkipTLS, "Disable TLS validation for source registry")
+	fs.BoolVar(&o.Mirror.SourcePlainHTTP, "source-use-http", o.Mirror.SourcePlainHTTP, "Use plain HTTP for source registry")
+	fs.StringSliceVar(&o.Mirror.InsecureRegistries, "insecure-registry", o.Mirror.InsecureRegistries, "Registry the insecure options apply to, "+
+		"in addition to the registries marked insecure in the configuration (can be repeated)")
+	fs.BoolVar(&o.Mirror.SkipVerification, "skip-verification", o.Mirror.SkipVerification, "Skip digest verification")
+	fs.StringSliceVar(&o.Mirror.FilterOptions, "filter-by-os", []string{"amd64"}, "Release architectures to plan")
+
+	return cmd
+}
+
+func (o *PlanOptions) Validate() error {
+	if o.Mirror.ConfigPath == "" {
+		return errors.New("must specify imageset configuration with --config")
+	}
+	switch {
+	case o.Output == planJSONOutput, o.Output == planYAMLOutput:
+		return nil
+	case o.Output == planTableOutput && o.Estimate:
+		return nil
+	case o.Output == planTableOutput:
+		return fmt.Errorf("output format %s is only supported with --estimate", planTableOutput)
+	default:
+		return fmt.Errorf("output format %q is not supported: must be %s, %s, or %s", o.Output, planJSONOutput, planYAMLOutput, planTableOutput)
+	}
+}
+
+func (o *PlanOptions) Run(ctx context.Context) error {
+	cfg, err := config.ReadConfig(o.Mirror.ConfigPath)
+	if err != nil {
+		return err
+	}
+	o.CompleteMirrorOptions(o.Mirror)
+	if o.Estimate {
+		est, err := o.Mirror.EstimatePlan(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		return writeEstimate(o.Out, est, o.Output)
+	}
+	plan, err := o.Mirror.Plan(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	return writePlan(o.Out, plan, o.Output)
+}
+
+// writePlan writes the plan to w in the output format
+func writePlan(w io.Writer, plan v1alpha2.ImagePlan, output string) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	if output == planYAMLOutput {
+		if data, err = yaml.JSONToYAML(data); err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// writeEstimate writes the size estimate to w in the output format
+func writeEstimate(w io.Writer, est mirror.SizeEstimate, output string) error {
+	if output != planTableOutput {
+		data, err := json.MarshalIndent(est, "", "  ")
+		if err != nil {
+			return err
+		}
+		if output == planYAMLOutput {
+			if data, err = yaml.JSONToYAML(data); err != nil {
+				return err
+			}
+			_, err = w.Write(data)
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+
+	size := func(bytes int64) string { return units.BytesSize(float64(bytes)) }
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CATEGORY\tIMAGES\tSIZE")
+	for _, c := range est.Categories {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", c.Category, c.Images, size(c.Size))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Total: %d images, %s\n", est.Images, size(est.Size))
+	if est.Unsized != 0 {
+		fmt.Fprintf(w, "%d images could not be sized and are not included\n", est.Unsized)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/mirror"
+)
+
+func TestWritePlan(t *testing.T) {
+	plan := v1alpha2.ImagePlan{
+		Images: []v1alpha2.PlannedImage{
+			{Source: "registry.redhat.io/ubi8/ubi:latest", Digest: "sha256:aaaa", Category: v1alpha2.TypeGeneric.String(), Layers: 2, Size: 155},
+			{Source: "quay.io/openshift-release-dev/ocp-release@sha256:bbbb", Digest: "sha256:bbbb", Category: v1alpha2.TypeOCPRelease.String(), Layers: 1, Size: 105},
+		},
+		Size: 160,
+	}
+
+	out := &bytes.Buffer{}
+	require.NoError(t, writePlan(out, plan, planJSONOutput))
+	var got v1alpha2.ImagePlan
+	require.NoError(t, json.Unmarshal(out.Bytes(), &got))
+	require.Equal(t, plan, got)
+
+	out.Reset()
+	require.NoError(t, writePlan(out, plan, planYAMLOutput))
+	got = v1alpha2.ImagePlan{}
+	require.NoError(t, yaml.Unmarshal(out.Bytes(), &got))
+	require.Equal(t, plan, got)
+}
+
+func TestWriteEstimate(t *testing.T) {
+	est := mirror.SizeEstimate{
+		Categories: []mirror.CategorySize{
+			{Category: v1alpha2.TypeGeneric.String(), Images: 3, Size: 1500},
+			{Category: v1alpha2.TypeOperatorCatalog.String(), Images: 1, Size: 200},
+		},
+		Images:  4,
+		Size:    1700,
+		Unsized: 1,
+	}
+
+	out := &bytes.Buffer{}
+	require.NoError(t, writeEstimate(out, est, planTableOutput))
+	require.Contains(t, out.String(), "Total: 4 images, 1.66KiB\n")
+	require.Contains(t, out.String(), "1 images could not be sized and are not included\n")
+}
+
+func TestPlanValidate(t *testing.T) {
+	type spec struct {
+		name     string
+		opts     PlanOptions
+		expError string
+	}
+	cases := []spec{
+		{
+			name: "Valid/JSON",
+			opts: PlanOptions{Mirror: &mirror.MirrorOptions{ConfigPath: "foo.yaml"}, Output: planJSONOutput},
+		},
+		{
+			name: "Valid/EstimateTable",
+			opts: PlanOptions{Mirror: &mirror.MirrorOptions{ConfigPath: "foo.yaml"}, Output: planTableOutput, Estimate: true},
+		},
+		{
+			name:     "Invalid/TableWithoutEstimate",
+			opts:     PlanOptions{Mirror: &mirror.MirrorOptions{ConfigPath: "foo.yaml"}, Output: planTableOutput},
+			expError: "output format table is only supported with --estimate",
+		},
+		{
+			name:     "Invalid/NoConfig",
+			opts:     PlanOptions{Mirror: &mirror.MirrorOptions{}, Output: planJSONOutput},
+			expError: "must specify imageset configuration with --config",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			err := c.opts.Validate()
+			if c.expError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
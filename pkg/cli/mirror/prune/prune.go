@@ -0,0 +1,516 @@
+package prune
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/cli"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/mirror"
+)
+
+const (
+	// unrecordedCategory is the category of repositories
+	// that no metadata records images in
+	unrecordedCategory = "unrecorded"
+
+	pruneTableOutput = "table"
+	pruneJSONOutput  = "json"
+
+	// pruneWorkers is the number of repositories listed concurrently
+	pruneWorkers = 8
+)
+
+// defaultPruneCategories are the categories pruned by default. Releases,
+// catalogs, and unrecorded repositories are only pruned when requested.
+var defaultPruneCategories = []string{
+	v1alpha2.TypeOperatorBundle.String(),
+	v1alpha2.TypeOperatorRelatedImage.String(),
+	v1alpha2.TypeGeneric.String(),
+}
+
+// PruneOptions configures the pruning of a destination registry
+type PruneOptions struct {
+	*cli.RootOptions
+	// Mirror are the options of the destination
+	Mirror *mirror.MirrorOptions
+	// Categories are the categories of images that can be pruned
+	Categories []string
+	// Confirm deletes the images, which are only listed otherwise
+	Confirm bool
+	// Output is the format of the report
+	Output string
+}
+
+func NewPruneCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
+	o := PruneOptions{RootOptions: ro, Mirror: cli.NewMirrorOptions()}
+
+	cmd := &cobra.Command{
+		Use:   "prune <destination registry>",
+		Short: "List or delete the images of a destination registry that are no longer mirrored",
+		Long: templates.LongDesc(`
+			List or delete the tags of a destination registry namespace that are no
+			longer retained by the metadata of the workspace or of the destination.
+
+			The images retained are the images of the last sequence of each metadata
+			found in the storage of the imageset configuration and published to the
+			destination. Tags of other images in the namespace are pruned, unless a
+			retained image has the same digest. Signatures, attestations, and SBOMs
+			of retained images, the metadata images, and the graph image are kept.
+
+			Only the tags are listed unless --confirm is given. Images are deleted by
+			digest, and only images of the categories given with --categories are
+			pruned: release payloads, catalogs, and repositories that no metadata
+			records are protected unless requested. Blobs are reclaimed by the
+			garbage collection of the registry.
+
+			Content removed from the imageset configuration stays retained until a
+			run with the configuration updates the metadata.
+		`),
+		Example: templates.Examples(`
+			# List the images that are no longer mirrored to a registry namespace
+			oc-mirror prune docker://registry.example.com/mirror --config imageset-config.yaml
+
+			# Delete the operator and additional images that are no longer mirrored
+			oc-mirror prune docker://registry.example.com/mirror --config imageset-config.yaml --confirm
+
+			# Also delete release payloads that are no longer mirrored
+			oc-mirror prune docker://registry.example.com/mirror --categories ocpRelease,ocpReleaseContent --confirm
+		`),
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckErr(o.Complete(cmd, args))
+			kcmdutil.CheckErr(o.Validate())
+			kcmdutil.CheckErr(o.Run(cmd.Context()))
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&o.Mirror.ConfigPath, "config", "c", o.Mirror.ConfigPath, "Path to the imageset configuration file with the metadata storage and registries")
+	fs.StringSliceVar(&o.Categories, "categories", defaultPruneCategories, "Categories of images to prune: "+strings.Join(pruneCategories(), ", "))
+	fs.BoolVar(&o.Confirm, "confirm", o.Confirm, "Delete the images instead of listing them")
+	fs.StringVarP(&o.Output, "output", "o", pruneTableOutput, "Output format: table or json")
+	fs.BoolVar(&o.Mirror.DestSkipTLS, "dest-skip-tls", o.Mirror.DestSkipTLS, "Disable TLS validation for destination registry")
+	fs.BoolVar(&o.Mirror.DestPlainHTTP, "dest-use-http", o.Mirror.DestPlainHTTP, "Use plain HTTP for destination registry")
+	fs.StringSliceVar(&o.Mirror.InsecureRegistries, "insecure-registry", o.Mirror.InsecureRegistries, "Registry the insecure options apply to, "+
+		"in addition to the registries marked insecure in the configuration (can be repeated)")
+
+	return cmd
+}
+
+// pruneCategories returns the categories that can be pruned
+func pruneCategories() []string {
+	categories := []string{}
+	for typ := v1alpha2.TypeOCPRelease; typ <= v1alpha2.TypeGeneric; typ++ {
+		categories = append(categories, typ.String())
+	}
+	return append(categories, unrecordedCategory)
+}
+
+func (o *PruneOptions) Complete(cmd *cobra.Command, args []string) error {
+	o.CompleteMirrorOptions(o.Mirror)
+	return o.Mirror.CompleteDestination(args[0])
+}
+
+func (o *PruneOptions) Validate() error {
+	if o.Mirror.ToMirror == "" {
+		return errors.New("destination must be a registry reference (docker://registry[/namespace])")
+	}
+	if len(o.Categories) == 0 {
+		return errors.New("at least one category must be given with --categories")
+	}
+	for _, category := range o.Categories {
+		if !containsString(pruneCategories(), category) {
+			return fmt.Errorf("unknown category %q: must be one of %s", category, strings.Join(pruneCategories(), ", "))
+		}
+	}
+	switch o.Output {
+	case pruneTableOutput, pruneJSONOutput:
+		return nil
+	default:
+		return fmt.Errorf("output format %q is not supported: must be %s or %s", o.Output, pruneTableOutput, pruneJSONOutput)
+	}
+}
+
+func (o *PruneOptions) Run(ctx context.Context) error {
+	if err := o.Mirror.ConfigureRegistries(); err != nil {
+		return err
+	}
+	var cfg *v1alpha2.ImageSetConfiguration
+	if o.Mirror.ConfigPath != "" {
+		c, err := config.ReadConfig(o.Mirror.ConfigPath)
+		if err != nil {
+			return err
+		}
+		cfg = &c
+	}
+
+	metas, err := o.Mirror.ReadDestinationMetadata(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	retained, err := o.retainedRepos(metas)
+	if err != nil {
+		return err
+	}
+	if len(retained) == 0 {
+		return fmt.Errorf("no metadata retaining images found for %s: refusing to prune", path.Join(o.Mirror.ToMirror, o.Mirror.UserNamespace))
+	}
+
+	candidates, err := o.pruneCandidates(ctx, retained)
+	if err != nil {
+		return err
+	}
+	report := pruneReport{
+		Destination: path.Join(o.Mirror.ToMirror, o.Mirror.UserNamespace),
+		DryRun:      !o.Confirm,
+		Pruned:      []pruneCandidate{},
+		Protected:   []pruneCandidate{},
+	}
+	for _, c := range candidates {
+		if containsAll(o.Categories, c.Categories) {
+			report.Pruned = append(report.Pruned, c)
+		} else {
+			report.Protected = append(report.Protected, c)
+		}
+	}
+	if o.Confirm {
+		o.deleteCandidates(ctx, report.Pruned)
+	}
+
+	if err := writePruneReport(o.Out, report, o.Output); err != nil {
+		return err
+	}
+	for _, c := range report.Pruned {
+		if c.Error != "" {
+			return errors.New("one or more images could not be deleted")
+		}
+	}
+	return nil
+}
+
+// retainedRepo is the content of a repository retained by metadata
+type retainedRepo struct {
+	tags       map[string]struct{}
+	digests    map[string]struct{}
+	categories map[string]struct{}
+}
+
+// retainedRepos returns the content retained by the metadata by repository
+func (o *PruneOptions) retainedRepos(metas []v1alpha2.Metadata) (map[string]*retainedRepo, error) {
+	toMirrorRef := imagesource.TypedImageReference{Type: imagesource.DestinationRegistry}
+	toMirrorRef.Ref.Registry = o.Mirror.ToMirror
+	repos := map[string]*retainedRepo{}
+	for _, meta := range metas {
+		assocs := append(append([]v1alpha2.Association{}, meta.PastAssociations...), meta.PastMirror.Associations...)
+		for _, assoc := range assocs {
+			dst, err := o.Mirror.PublishDestination(toMirrorRef, assoc)
+			if err != nil {
+				return nil, err
+			}
+			repoName := dst.Ref.AsRepository().RepositoryName()
+			r, ok := repos[repoName]
+			if !ok {
+				r = &retainedRepo{tags: map[string]struct{}{}, digests: map[string]struct{}{}, categories: map[string]struct{}{}}
+				repos[repoName] = r
+			}
+			if assoc.TagSymlink != "" {
+				r.tags[assoc.TagSymlink] = struct{}{}
+			}
+			if assoc.ID != "" {
+				r.digests[assoc.ID] = struct{}{}
+			}
+			for _, dgst := range assoc.ManifestDigests {
+				r.digests[dgst] = struct{}{}
+			}
+			r.categories[assoc.Type.String()] = struct{}{}
+		}
+	}
+	return repos, nil
+}
+
+// pruneCandidate is a tag of the destination that is not retained
+type pruneCandidate struct {
+	Repository string   `json:"repository"`
+	Tag        string   `json:"tag"`
+	Digest     string   `json:"digest"`
+	Categories []string `json:"categories"`
+	Deleted    bool     `json:"deleted,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// pruneCandidates lists the tags of the repositories in the destination
+// namespace and returns those that are not retained. Tags with the digest
+// of a retained image are kept, since images are deleted by digest.
+func (o *PruneOptions) pruneCandidates(ctx context.Context, retained map[string]*retainedRepo) ([]pruneCandidate, error) {
+	insecure := o.Mirror.DestPlainHTTP || o.Mirror.DestSkipTLS
+	nameOpts := nameOptions(insecure)
+	remoteOpts := o.Mirror.Clients().RemoteOptions(ctx, insecure)
+	reg, err := name.NewRegistry(o.Mirror.ToMirror, nameOpts...)
+	if err != nil {
+		return nil, err
+	}
+	repoNames, err := remote.Catalog(ctx, reg, remoteOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing the repositories of %s: %v", o.Mirror.ToMirror, err)
+	}
+	protectedRepos := []string{
+		path.Join(o.Mirror.UserNamespace, "oc-mirror"),
+		path.Join(o.Mirror.UserNamespace, "openshift", "graph-image"),
+	}
+
+	var mu sync.Mutex
+	var candidates []pruneCandidate
+	work := make(chan string)
+	g, gctx := errgroup.WithContext(ctx)
+	for i := 0; i < pruneWorkers; i++ {
+		g.Go(func() error {
+			for repoName := range work {
+				repoCandidates, err := o.repoCandidates(gctx, repoName, retained[repoName])
+				if err != nil {
+					return err
+				}
+				mu.Lock()
+				candidates = append(candidates, repoCandidates...)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	g.Go(func() error {
+		defer close(work)
+		for _, repoName := range repoNames {
+			if o.Mirror.UserNamespace != "" && !strings.HasPrefix(repoName, o.Mirror.UserNamespace+"/") {
+				continue
+			}
+			if containsString(protectedRepos, repoName) {
+				continue
+			}
+			select {
+			case work <- repoName:
+			case <-gctx.Done():
+				return gctx.Err()
+			}
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Repository != candidates[j].Repository {
+			return candidates[i].Repository < candidates[j].Repository
+		}
+		return candidates[i].Tag < candidates[j].Tag
+	})
+	return candidates, nil
+}
+
+// repoCandidates returns the tags of the repository that are not retained
+func (o *PruneOptions) repoCandidates(ctx context.Context, repoName string, r *retainedRepo) ([]pruneCandidate, error) {
+	insecure := o.Mirror.DestPlainHTTP || o.Mirror.DestSkipTLS
+	remoteOpts := o.Mirror.Clients().RemoteOptions(ctx, insecure)
+	repo, err := name.NewRepository(path.Join(o.Mirror.ToMirror, repoName), nameOptions(insecure)...)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := remote.List(repo, remoteOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing the tags of %s: %v", repo, err)
+	}
+
+	categories := []string{unrecordedCategory}
+	retainedDigests := map[string]struct{}{}
+	if r != nil {
+		categories = make([]string, 0, len(r.categories))
+		for category := range r.categories {
+			categories = append(categories, category)
+		}
+		sort.Strings(categories)
+		for dgst := range r.digests {
+			retainedDigests[dgst] = struct{}{}
+		}
+	}
+
+	digests := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		desc, err := remote.Head(repo.Tag(tag), remoteOpts...)
+		if err != nil {
+			if isNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("error getting the digest of %s:%s: %v", repo, tag, err)
+		}
+		digests[tag] = desc.Digest.String()
+		if r != nil {
+			if _, ok := r.tags[tag]; ok {
+				retainedDigests[desc.Digest.String()] = struct{}{}
+			}
+		}
+	}
+
+	var candidates []pruneCandidate
+	for _, tag := range tags {
+		dgst, ok := digests[tag]
+		if !ok {
+			continue
+		}
+		if _, ok := retainedDigests[dgst]; ok {
+			continue
+		}
+		// Signatures, attestations, and SBOMs are tagged after the digest of their image
+		if subject, ok := sigstoreSubject(tag); ok {
+			if _, ok := retainedDigests[subject]; ok {
+				continue
+			}
+		}
+		candidates = append(candidates, pruneCandidate{Repository: repoName, Tag: tag, Digest: dgst, Categories: categories})
+	}
+	return candidates, nil
+}
+
+// sigstoreSubject returns the digest of the image that a
+// sigstore tag of the form sha256-<hex>.<suffix> refers to
+func sigstoreSubject(tag string) (string, bool) {
+	if !strings.HasPrefix(tag, "sha256-") {
+		return "", false
+	}
+	hex := strings.TrimPrefix(tag, "sha256-")
+	if i := strings.Index(hex, "."); i != -1 {
+		hex = hex[:i]
+	}
+	return "sha256:" + hex, true
+}
+
+// deleteCandidates deletes the images of the candidates by digest.
+// Candidates sharing a digest are deleted once.
+func (o *PruneOptions) deleteCandidates(ctx context.Context, candidates []pruneCandidate) {
+	insecure := o.Mirror.DestPlainHTTP || o.Mirror.DestSkipTLS
+	remoteOpts := o.Mirror.Clients().RemoteOptions(ctx, insecure)
+	results := map[string]error{}
+	for i := range candidates {
+		c := &candidates[i]
+		key := c.Repository + "@" + c.Digest
+		err, done := results[key]
+		if !done {
+			var ref name.Digest
+			ref, err = name.NewDigest(path.Join(o.Mirror.ToMirror, key), nameOptions(insecure)...)
+			if err == nil {
+				logrus.Infof("Deleting %s", ref)
+				err = remote.Delete(ref, remoteOpts...)
+			}
+			results[key] = err
+		}
+		if err != nil {
+			c.Error = err.Error()
+			continue
+		}
+		c.Deleted = true
+	}
+}
+
+// pruneReport is the report of the images pruned from a destination
+type pruneReport struct {
+	Destination string `json:"destination"`
+	DryRun      bool   `json:"dryRun"`
+	// Pruned are the images deleted, or listed for deletion
+	Pruned []pruneCandidate `json:"pruned"`
+	// Protected are the images that are not retained,
+	// but that are not of the categories pruned
+	Protected []pruneCandidate `json:"protected"`
+}
+
+// writePruneReport writes the report to w in the output format
+func writePruneReport(w io.Writer, report pruneReport, output string) error {
+	if output == pruneJSONOutput {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPOSITORY\tTAG\tDIGEST\tCATEGORY\tSTATUS")
+	var deleted, failed int
+	for _, c := range report.Pruned {
+		status := "would delete"
+		switch {
+		case c.Error != "":
+			status = "failed: " + c.Error
+			failed++
+		case c.Deleted:
+			status = "deleted"
+			deleted++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.Repository, c.Tag, c.Digest, strings.Join(c.Categories, ","), status)
+	}
+	for _, c := range report.Protected {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.Repository, c.Tag, c.Digest, strings.Join(c.Categories, ","), "protected")
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+	if report.DryRun {
+		_, err := fmt.Fprintf(w, "%d tags would be deleted and %d are protected by category, rerun with --confirm to delete them\n", len(report.Pruned), len(report.Protected))
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d tags deleted, %d failed, and %d protected by category\n", deleted, failed, len(report.Protected))
+	return err
+}
+
+// containsAll returns whether all items are in list
+func containsAll(list, items []string) bool {
+	for _, item := range items {
+		if !containsString(list, item) {
+			return false
+		}
+	}
+	return true
+}
+
+// nameOptions returns the options parsing references
+// of registries that are insecure or not
+func nameOptions(insecure bool) (options []name.Option) {
+	if insecure {
+		options = append(options, name.Insecure)
+	}
+	return options
+}
+
+// isNotFound returns whether the registry error is a 404
+func isNotFound(err error) bool {
+	var terr *transport.Error
+	return errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
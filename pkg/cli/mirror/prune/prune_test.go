@@ -0,0 +1,208 @@
+package prune
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/cli"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
+	"github.com/openshift/oc-mirror/pkg/metadata/storage"
+	"github.com/openshift/oc-mirror/pkg/mirror"
+)
+
+func TestPrune(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	clients := insecureClients(t, u.Host)
+
+	push := func(content, repoTag string) string {
+		layer, err := crane.Layer(map[string][]byte{"file": []byte(content)})
+		require.NoError(t, err)
+		img, err := mutate.AppendLayers(empty.Image, layer)
+		require.NoError(t, err)
+		require.NoError(t, crane.Push(img, fmt.Sprintf("%s/mirror/%s", u.Host, repoTag), crane.Insecure))
+		dgst, err := img.Digest()
+		require.NoError(t, err)
+		return dgst.String()
+	}
+	ubi := push("ubi", "ubi8/ubi:latest")
+	stale := push("stale", "ubi8/ubi:old")
+	// The signature of the retained image and a tag of its digest are kept
+	push("signature", "ubi8/ubi:sha256-"+ubi[len("sha256:"):]+".sig")
+	push("ubi", "ubi8/ubi:copy")
+	release := push("release", "openshift/release-images:4.12.1-x86_64")
+	staleRelease := push("old release", "openshift/release-images:4.12.0-x86_64")
+	unrecorded := push("unrecorded", "other/image:latest")
+
+	// Publish the metadata to the destination
+	meta := v1alpha2.NewMetadata()
+	meta.Uid = uuid.New()
+	meta.PastMirror = v1alpha2.PastMirror{
+		Sequence: 2,
+		Associations: []v1alpha2.Association{
+			{Name: "registry.redhat.io/ubi8/ubi:latest", Path: "ubi8/ubi", ID: ubi, TagSymlink: "latest", Type: v1alpha2.TypeGeneric},
+			{Name: "quay.io/openshift-release-dev/ocp-release:4.12.1-x86_64", Path: "openshift/release-images", ID: release, TagSymlink: "4.12.1-x86_64", Type: v1alpha2.TypeOCPRelease},
+		},
+	}
+	backend, err := storage.NewRegistryBackend(&v1alpha2.RegistryConfig{
+		ImageURL: fmt.Sprintf("%s/mirror/oc-mirror:%s", u.Host, meta.Uid),
+		SkipTLS:  true,
+	}, t.TempDir(), clients)
+	require.NoError(t, err)
+	require.NoError(t, backend.WriteMetadata(context.Background(), &meta, config.MetadataBasePath))
+
+	newOptions := func(confirm bool, categories ...string) (*PruneOptions, *bytes.Buffer) {
+		streams, _, out, _ := genericclioptions.NewTestIOStreams()
+		mo := mirror.NewMirrorOptions(mirror.WithClients(clients))
+		mo.ToMirror = u.Host
+		mo.UserNamespace = "mirror"
+		mo.DestPlainHTTP = true
+		mo.InsecureRegistries = []string{u.Host}
+		o := &PruneOptions{
+			RootOptions: &cli.RootOptions{IOStreams: streams, Dir: filepath.Join(t.TempDir(), "oc-mirror-workspace")},
+			Mirror:      mo,
+			Categories:  categories,
+			Confirm:     confirm,
+			Output:      pruneJSONOutput,
+		}
+		return o, out
+	}
+
+	t.Run("Valid/DryRun", func(t *testing.T) {
+		o, _ := newOptions(false, defaultPruneCategories...)
+		require.NoError(t, o.Validate())
+		metas, err := o.Mirror.ReadDestinationMetadata(context.Background(), nil)
+		require.NoError(t, err)
+		require.Len(t, metas, 1)
+		retained, err := o.retainedRepos(metas)
+		require.NoError(t, err)
+		candidates, err := o.pruneCandidates(context.Background(), retained)
+		require.NoError(t, err)
+		require.Equal(t, []pruneCandidate{
+			{Repository: "mirror/openshift/release-images", Tag: "4.12.0-x86_64", Digest: staleRelease, Categories: []string{v1alpha2.TypeOCPRelease.String()}},
+			{Repository: "mirror/other/image", Tag: "latest", Digest: unrecorded, Categories: []string{unrecordedCategory}},
+			{Repository: "mirror/ubi8/ubi", Tag: "old", Digest: stale, Categories: []string{v1alpha2.TypeGeneric.String()}},
+		}, candidates)
+		require.NoError(t, o.Run(context.Background()))
+		_, err = crane.Head(fmt.Sprintf("%s/mirror/ubi8/ubi@%s", u.Host, stale), crane.Insecure)
+		require.NoError(t, err)
+	})
+
+	t.Run("Valid/Confirm", func(t *testing.T) {
+		o, out := newOptions(true, defaultPruneCategories...)
+		require.NoError(t, o.Run(context.Background()))
+		require.Contains(t, out.String(), `"deleted": true`)
+
+		_, err := crane.Head(fmt.Sprintf("%s/mirror/ubi8/ubi@%s", u.Host, stale), crane.Insecure)
+		require.Error(t, err)
+		for _, ref := range []string{
+			"mirror/ubi8/ubi@" + ubi,
+			"mirror/openshift/release-images@" + staleRelease,
+			"mirror/other/image@" + unrecorded,
+		} {
+			_, err := crane.Head(fmt.Sprintf("%s/%s", u.Host, ref), crane.Insecure)
+			require.NoError(t, err, ref)
+		}
+	})
+
+	t.Run("Invalid/NoMetadata", func(t *testing.T) {
+		o, _ := newOptions(false, defaultPruneCategories...)
+		o.Mirror.UserNamespace = "empty"
+		err := o.Run(context.Background())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "refusing to prune")
+	})
+}
+
+// insecureClients returns registry clients with the registry marked insecure
+func insecureClients(t *testing.T, host string) *image.Clients {
+	clients := image.NewClients()
+	require.NoError(t, clients.SetRegistries([]v1alpha2.Registry{{Host: host, Insecure: true}}))
+	return clients
+}
+
+func TestPruneValidate(t *testing.T) {
+	type spec struct {
+		name       string
+		toMirror   string
+		categories []string
+		output     string
+		expError   string
+	}
+	cases := []spec{
+		{
+			name:       "Valid/Defaults",
+			toMirror:   "registry.example.com",
+			categories: defaultPruneCategories,
+			output:     pruneTableOutput,
+		},
+		{
+			name:       "Valid/Unrecorded",
+			toMirror:   "registry.example.com",
+			categories: []string{unrecordedCategory},
+			output:     pruneJSONOutput,
+		},
+		{
+			name:       "Invalid/NoRegistry",
+			categories: defaultPruneCategories,
+			output:     pruneTableOutput,
+			expError:   "destination must be a registry reference",
+		},
+		{
+			name:       "Invalid/UnknownCategory",
+			toMirror:   "registry.example.com",
+			categories: []string{"images"},
+			output:     pruneTableOutput,
+			expError:   `unknown category "images"`,
+		},
+		{
+			name:       "Invalid/Output",
+			toMirror:   "registry.example.com",
+			categories: defaultPruneCategories,
+			output:     "yaml",
+			expError:   `output format "yaml" is not supported`,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			o := &PruneOptions{
+				Mirror:     &mirror.MirrorOptions{ToMirror: c.toMirror},
+				Categories: c.categories,
+				Output:     c.output,
+			}
+			err := o.Validate()
+			if c.expError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestSigstoreSubject(t *testing.T) {
+	subject, ok := sigstoreSubject("sha256-abc.sig")
+	require.True(t, ok)
+	require.Equal(t, "sha256:abc", subject)
+	_, ok = sigstoreSubject("latest")
+	require.False(t, ok)
+}
@@ -0,0 +1,340 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/operator-framework/operator-registry/alpha/action"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/model"
+	"github.com/operator-framework/operator-registry/pkg/image/containerdregistry"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift/oc-mirror/pkg/cli"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+const (
+	tableOutput = "table"
+	jsonOutput  = "json"
+)
+
+// Fields of a package a keyword can match
+const (
+	fieldName        = "name"
+	fieldDisplayName = "displayName"
+	fieldDescription = "description"
+	fieldKeywords    = "keywords"
+)
+
+// propertyCSVMetadata carries the CSV metadata of bundles
+// in catalogs that do not embed the CSV of their bundles
+const propertyCSVMetadata = "olm.csv.metadata"
+
+type SearchOptions struct {
+	*cli.RootOptions
+	Keyword    string
+	ConfigPath string
+	Catalogs   []string
+	Output     string
+}
+
+func NewSearchCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
+	o := SearchOptions{}
+	o.RootOptions = ro
+
+	cmd := &cobra.Command{
+		Use:   "search KEYWORD",
+		Short: "Search the operator packages of catalogs",
+		Long: templates.LongDesc(`
+			Search the operator packages of catalogs for a keyword, to discover the
+			package names to include in an imageset configuration.
+
+			The catalogs of the imageset configuration and the catalogs given with
+			--catalog are rendered, and the keyword is matched, ignoring case, against
+			the name of each package, and the display name, description, and keywords
+			of the head of its default channel. Packages whose name matches are listed
+			first.
+		`),
+		Example: templates.Examples(`
+			# Search the catalogs of an imageset configuration for logging operators
+			oc-mirror search logging --config imageset-config.yaml
+
+			# Search a catalog
+			oc-mirror search "service mesh" --catalog registry.redhat.io/redhat/redhat-operator-index:v4.12
+
+			# Search the catalogs of an imageset configuration as JSON
+			oc-mirror search kiali --config imageset-config.yaml -o json
+		`),
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckErr(o.Complete(args))
+			kcmdutil.CheckErr(o.Validate())
+			kcmdutil.CheckErr(o.Run(cmd.Context(), o.IOStreams.Out))
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&o.ConfigPath, "config", "c", o.ConfigPath, "Path to imageset configuration file whose catalogs are searched")
+	fs.StringSliceVar(&o.Catalogs, "catalog", o.Catalogs, "Catalog to search, in addition to the catalogs of the imageset configuration (can be repeated)")
+	fs.StringVarP(&o.Output, "output", "o", tableOutput, "Output format: table or json")
+
+	return cmd
+}
+
+func (o *SearchOptions) Complete(args []string) error {
+	o.Keyword = strings.TrimSpace(args[0])
+	return nil
+}
+
+func (o *SearchOptions) Validate() error {
+	if len(o.Keyword) == 0 {
+		return errors.New("must specify a keyword")
+	}
+	if len(o.ConfigPath) == 0 && len(o.Catalogs) == 0 {
+		return errors.New("must specify --config or --catalog")
+	}
+	switch o.Output {
+	case tableOutput, jsonOutput:
+		return nil
+	default:
+		return fmt.Errorf("output format %q is not supported: must be %s or %s", o.Output, tableOutput, jsonOutput)
+	}
+}
+
+func (o *SearchOptions) Run(ctx context.Context, out io.Writer) error {
+	catalogs, err := o.targetCatalogs()
+	if err != nil {
+		return err
+	}
+
+	cacheDir, err := ioutil.TempDir("", "oc-mirror-search-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(cacheDir)
+	reg, err := containerdregistry.NewRegistry(
+		containerdregistry.WithRootCAs(image.SharedClients().RootCAs()),
+		containerdregistry.WithCacheDir(cacheDir),
+	)
+	if err != nil {
+		return err
+	}
+	defer reg.Destroy()
+
+	res := result{Keyword: o.Keyword, Catalogs: catalogs, Matches: []match{}}
+	for _, ctlg := range catalogs {
+		logrus.Infof("Rendering catalog %s", ctlg)
+		dc, err := action.Render{Registry: reg, Refs: []string{ctlg}}.Run(ctx)
+		if err != nil {
+			return fmt.Errorf("error rendering catalog %s: %v", ctlg, err)
+		}
+		matches, err := searchCatalog(ctlg, *dc, o.Keyword)
+		if err != nil {
+			return err
+		}
+		res.Matches = append(res.Matches, matches...)
+	}
+	sortMatches(res.Matches)
+
+	if o.Output == jsonOutput {
+		data, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	}
+	return writeResult(out, res)
+}
+
+// targetCatalogs returns the catalogs of the imageset configuration
+// and the catalogs of the command line, without duplicates
+func (o *SearchOptions) targetCatalogs() ([]string, error) {
+	var catalogs []string
+	seen := map[string]struct{}{}
+	add := func(ctlg string) {
+		if _, ok := seen[ctlg]; !ok {
+			seen[ctlg] = struct{}{}
+			catalogs = append(catalogs, ctlg)
+		}
+	}
+	if len(o.ConfigPath) > 0 {
+		cfg, err := config.ReadConfig(o.ConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := image.SharedClients().SetRegistries(cfg.Registries); err != nil {
+			return nil, err
+		}
+		for _, ctlg := range cfg.Mirror.Operators {
+			add(ctlg.Catalog)
+		}
+	}
+	for _, ctlg := range o.Catalogs {
+		add(ctlg)
+	}
+	if len(catalogs) == 0 {
+		return nil, fmt.Errorf("imageset configuration %s has no operator catalogs", o.ConfigPath)
+	}
+	return catalogs, nil
+}
+
+// result is the result of a search
+type result struct {
+	Keyword  string   `json:"keyword"`
+	Catalogs []string `json:"catalogs"`
+	Matches  []match  `json:"matches"`
+}
+
+// match is a package matching the keyword
+type match struct {
+	Catalog        string `json:"catalog"`
+	Package        string `json:"package"`
+	DisplayName    string `json:"displayName,omitempty"`
+	DefaultChannel string `json:"defaultChannel"`
+	// Fields are the fields of the package matching the keyword
+	Fields []string `json:"fields"`
+}
+
+// packageMetadata is the searchable metadata of a package
+type packageMetadata struct {
+	DisplayName string
+	Description string
+	Keywords    []string
+}
+
+// searchCatalog returns the packages of the catalog matching the keyword
+func searchCatalog(catalog string, dc declcfg.DeclarativeConfig, keyword string) ([]match, error) {
+	m, err := declcfg.ConvertToModel(dc)
+	if err != nil {
+		return nil, fmt.Errorf("error reading catalog %s: %v", catalog, err)
+	}
+	keyword = strings.ToLower(keyword)
+	contains := func(s string) bool {
+		return strings.Contains(strings.ToLower(s), keyword)
+	}
+
+	var matches []match
+	for _, pkg := range m {
+		meta := metadataOf(*pkg)
+		var fields []string
+		if contains(pkg.Name) {
+			fields = append(fields, fieldName)
+		}
+		if contains(meta.DisplayName) {
+			fields = append(fields, fieldDisplayName)
+		}
+		if contains(pkg.Description) || contains(meta.Description) {
+			fields = append(fields, fieldDescription)
+		}
+		for _, kw := range meta.Keywords {
+			if contains(kw) {
+				fields = append(fields, fieldKeywords)
+				break
+			}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		mt := match{Catalog: catalog, Package: pkg.Name, DisplayName: meta.DisplayName, Fields: fields}
+		if pkg.DefaultChannel != nil {
+			mt.DefaultChannel = pkg.DefaultChannel.Name
+		}
+		matches = append(matches, mt)
+	}
+	return matches, nil
+}
+
+// metadataOf returns the metadata of the head of the default channel
+// of the package, from its CSV or its olm.csv.metadata property
+func metadataOf(pkg model.Package) packageMetadata {
+	var meta packageMetadata
+	if pkg.DefaultChannel == nil {
+		return meta
+	}
+	head, err := pkg.DefaultChannel.Head()
+	if err != nil || head == nil {
+		return meta
+	}
+	if head.CsvJSON != "" {
+		var csv struct {
+			Metadata struct {
+				Annotations map[string]string `json:"annotations"`
+			} `json:"metadata"`
+			Spec struct {
+				DisplayName string   `json:"displayName"`
+				Description string   `json:"description"`
+				Keywords    []string `json:"keywords"`
+			} `json:"spec"`
+		}
+		if err := json.Unmarshal([]byte(head.CsvJSON), &csv); err == nil {
+			meta.DisplayName = csv.Spec.DisplayName
+			meta.Description = strings.Join([]string{csv.Metadata.Annotations["description"], csv.Spec.Description}, "\n")
+			meta.Keywords = csv.Spec.Keywords
+			return meta
+		}
+	}
+	for _, prop := range head.Properties {
+		if prop.Type != propertyCSVMetadata {
+			continue
+		}
+		var csvMeta struct {
+			DisplayName string            `json:"displayName"`
+			Description string            `json:"description"`
+			Keywords    []string          `json:"keywords"`
+			Annotations map[string]string `json:"annotations"`
+		}
+		if err := json.Unmarshal(prop.Value, &csvMeta); err == nil {
+			meta.DisplayName = csvMeta.DisplayName
+			meta.Description = strings.Join([]string{csvMeta.Annotations["description"], csvMeta.Description}, "\n")
+			meta.Keywords = csvMeta.Keywords
+		}
+	}
+	return meta
+}
+
+// sortMatches sorts the packages whose name matches the keyword first,
+// then by package and catalog
+func sortMatches(matches []match) {
+	sort.SliceStable(matches, func(i, j int) bool {
+		ni, nj := matches[i].Fields[0] == fieldName, matches[j].Fields[0] == fieldName
+		if ni != nj {
+			return ni
+		}
+		if matches[i].Package != matches[j].Package {
+			return matches[i].Package < matches[j].Package
+		}
+		return matches[i].Catalog < matches[j].Catalog
+	})
+}
+
+// writeResult writes the matches as a table
+func writeResult(w io.Writer, res result) error {
+	if len(res.Matches) == 0 {
+		_, err := fmt.Fprintf(w, "No packages found matching %q\n", res.Keyword)
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "PACKAGE\tDISPLAY NAME\tDEFAULT CHANNEL\tMATCHED\tCATALOG"); err != nil {
+		return err
+	}
+	for _, m := range res.Matches {
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", m.Package, m.DisplayName, m.DefaultChannel, strings.Join(m.Fields, ","), m.Catalog); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
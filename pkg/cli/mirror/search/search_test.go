@@ -0,0 +1,159 @@
+package search
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/property"
+	"github.com/stretchr/testify/require"
+)
+
+func testCatalog() declcfg.DeclarativeConfig {
+	return declcfg.DeclarativeConfig{
+		Packages: []declcfg.Package{
+			{Schema: "olm.package", Name: "cluster-logging", DefaultChannel: "stable"},
+			{Schema: "olm.package", Name: "loki-operator", DefaultChannel: "stable"},
+			{Schema: "olm.package", Name: "kiali-ossm", DefaultChannel: "stable", Description: "Kiali provides observability for the service mesh"},
+		},
+		Channels: []declcfg.Channel{
+			{Schema: "olm.channel", Package: "cluster-logging", Name: "stable", Entries: []declcfg.ChannelEntry{{Name: "cluster-logging.v5.6.0"}}},
+			{Schema: "olm.channel", Package: "loki-operator", Name: "stable", Entries: []declcfg.ChannelEntry{{Name: "loki-operator.v5.6.0"}}},
+			{Schema: "olm.channel", Package: "kiali-ossm", Name: "stable", Entries: []declcfg.ChannelEntry{{Name: "kiali-operator.v1.57.0"}}},
+		},
+		Bundles: []declcfg.Bundle{
+			{
+				Schema:     "olm.bundle",
+				Name:       "cluster-logging.v5.6.0",
+				Package:    "cluster-logging",
+				Image:      "registry.example.com/cluster-logging-bundle:v5.6.0",
+				Properties: []property.Property{property.MustBuildPackage("cluster-logging", "5.6.0")},
+				CsvJSON:    `{"spec": {"displayName": "Red Hat OpenShift Logging", "description": "Cluster logging", "keywords": ["elasticsearch", "kibana"]}}`,
+			},
+			{
+				Schema:  "olm.bundle",
+				Name:    "loki-operator.v5.6.0",
+				Package: "loki-operator",
+				Image:   "registry.example.com/loki-operator-bundle:v5.6.0",
+				Properties: []property.Property{
+					property.MustBuildPackage("loki-operator", "5.6.0"),
+					{Type: propertyCSVMetadata, Value: json.RawMessage(`{"displayName": "Loki Operator", "keywords": ["logging", "loki"]}`)},
+				},
+			},
+			{
+				Schema:     "olm.bundle",
+				Name:       "kiali-operator.v1.57.0",
+				Package:    "kiali-ossm",
+				Image:      "registry.example.com/kiali-operator-bundle:v1.57.0",
+				Properties: []property.Property{property.MustBuildPackage("kiali-ossm", "1.57.0")},
+			},
+		},
+	}
+}
+
+func TestSearchCatalog(t *testing.T) {
+	type spec struct {
+		name    string
+		keyword string
+		exp     []match
+	}
+	cases := []spec{
+		{
+			name:    "Valid/NameAndKeywords",
+			keyword: "Logging",
+			exp: []match{
+				{Catalog: "registry.example.com/catalog:latest", Package: "cluster-logging", DisplayName: "Red Hat OpenShift Logging", DefaultChannel: "stable", Fields: []string{fieldName, fieldDisplayName, fieldDescription}},
+				{Catalog: "registry.example.com/catalog:latest", Package: "loki-operator", DisplayName: "Loki Operator", DefaultChannel: "stable", Fields: []string{fieldKeywords}},
+			},
+		},
+		{
+			name:    "Valid/PackageDescription",
+			keyword: "service mesh",
+			exp: []match{
+				{Catalog: "registry.example.com/catalog:latest", Package: "kiali-ossm", DefaultChannel: "stable", Fields: []string{fieldDescription}},
+			},
+		},
+		{
+			name:    "Valid/NoMatches",
+			keyword: "foo",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			matches, err := searchCatalog("registry.example.com/catalog:latest", testCatalog(), c.keyword)
+			require.NoError(t, err)
+			sortMatches(matches)
+			require.Equal(t, c.exp, matches)
+		})
+	}
+}
+
+func TestSortMatches(t *testing.T) {
+	matches := []match{
+		{Catalog: "b", Package: "alpha", Fields: []string{fieldKeywords}},
+		{Catalog: "b", Package: "zeta", Fields: []string{fieldName}},
+		{Catalog: "a", Package: "zeta", Fields: []string{fieldName, fieldDescription}},
+	}
+	sortMatches(matches)
+	require.Equal(t, []match{
+		{Catalog: "a", Package: "zeta", Fields: []string{fieldName, fieldDescription}},
+		{Catalog: "b", Package: "zeta", Fields: []string{fieldName}},
+		{Catalog: "b", Package: "alpha", Fields: []string{fieldKeywords}},
+	}, matches)
+}
+
+func TestWriteResult(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeResult(&buf, result{Keyword: "loki", Matches: []match{
+		{Catalog: "registry.example.com/catalog:latest", Package: "loki-operator", DisplayName: "Loki Operator", DefaultChannel: "stable", Fields: []string{fieldName, fieldKeywords}},
+	}}))
+	require.Equal(t, `PACKAGE        DISPLAY NAME   DEFAULT CHANNEL  MATCHED        CATALOG
+loki-operator  Loki Operator  stable           name,keywords  registry.example.com/catalog:latest
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeResult(&buf, result{Keyword: "foo"}))
+	require.Equal(t, "No packages found matching \"foo\"\n", buf.String())
+}
+
+func TestSearchValidate(t *testing.T) {
+	type spec struct {
+		name     string
+		opts     *SearchOptions
+		expError string
+	}
+	cases := []spec{
+		{
+			name:     "Invalid/NoKeyword",
+			opts:     &SearchOptions{ConfigPath: "imageset-config.yaml", Output: tableOutput},
+			expError: "must specify a keyword",
+		},
+		{
+			name:     "Invalid/NoCatalogs",
+			opts:     &SearchOptions{Keyword: "logging", Output: tableOutput},
+			expError: "must specify --config or --catalog",
+		},
+		{
+			name:     "Invalid/Output",
+			opts:     &SearchOptions{Keyword: "logging", Catalogs: []string{"registry.example.com/catalog:latest"}, Output: "yaml"},
+			expError: `output format "yaml" is not supported: must be table or json`,
+		},
+		{
+			name: "Valid/Config",
+			opts: &SearchOptions{Keyword: "logging", ConfigPath: "imageset-config.yaml", Output: jsonOutput},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			err := c.opts.Validate()
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
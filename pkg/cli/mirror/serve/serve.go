@@ -0,0 +1,314 @@
+package serve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/cli"
+	"github.com/openshift/oc-mirror/pkg/exitcode"
+	"github.com/openshift/oc-mirror/pkg/mirror"
+	"github.com/openshift/oc-mirror/pkg/schedule"
+)
+
+// ServeOptions configures the scheduled runs of oc-mirror
+type ServeOptions struct {
+	*cli.RootOptions
+	// Mirror are the options of the runs
+	Mirror *mirror.MirrorOptions
+	// Schedule is the cron schedule of the runs
+	Schedule string
+	// RunOnStart runs once when the daemon starts
+	// before following the schedule
+	RunOnStart bool
+	// APIAddr is the address to serve the control API on
+	APIAddr string
+	// APITokenFile is the file of the bearer token
+	// required by the control API
+	APITokenFile string
+
+	schedule *schedule.Schedule
+	now      func() time.Time
+}
+
+func NewServeCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
+	o := ServeOptions{RootOptions: ro, Mirror: cli.NewMirrorOptions()}
+
+	cmd := &cobra.Command{
+		Use:   "serve <destination>",
+		Short: "Mirror on a schedule or on request of a control API",
+		Long: templates.LongDesc(`
+			Run oc-mirror as a daemon that mirrors to disk or to a mirror registry on
+			a cron schedule, so mirrors are kept up to date without systemd timers or
+			wrapper scripts. The destination and flags are the ones of a regular run.
+
+			The schedule has the five fields of crontab: minute, hour, day of month,
+			month, and day of week, in the local time zone. The macros @hourly,
+			@daily, @weekly, @monthly, and @yearly are also accepted.
+
+			The imageset configuration is read again at each run, so changes apply
+			to the next run, except for its registries, which are configured when
+			the daemon starts. A run that is still in progress when the next one is
+			due delays it to the following scheduled time. Each run locks the
+			workspace, so runs of other oc-mirror processes on the workspace fail
+			until the run completes.
+
+			Each run writes its results to a results directory and notifies the
+			--webhook URLs. Use 'oc-mirror metadata history' to list the previous
+			runs. A failed run is logged and does not stop the daemon.
+
+			With --api-addr, the daemon also serves an HTTP control API to submit
+			imageset configurations, track the progress of their runs, and download
+			the manifests and reports of their results directories. Runs are queued
+			and run one at a time. Submitted configurations must not have hooks or
+			registries, which are only read from the --config of the daemon. The API is not encrypted, so serve it on
+			localhost or behind a TLS reverse proxy. A bearer token is required with
+			--api-token-file unless the API is served on a loopback address.
+
+			* GET /api/v1/runs lists the runs
+			* POST /api/v1/runs queues a run of the imageset configuration in the body
+			* GET /api/v1/runs/{id} shows the status, progress, and results of a run
+			* GET /api/v1/runs/{id}/events downloads the events of a run
+			* GET /api/v1/runs/{id}/files lists the files of the results directory of a run
+			* GET /api/v1/runs/{id}/files/{path} downloads a file of the results directory of a run
+
+			The daemon stops after the current run on SIGINT or SIGTERM. A second
+			signal aborts the current run.
+		`),
+		Example: templates.Examples(`
+			# Mirror to disk every Saturday at 2am
+			oc-mirror serve --schedule "0 2 * * 6" --config imageset-config.yaml file://archives
+
+			# Mirror to a registry every night, starting with a run now
+			oc-mirror serve --schedule @daily --run-on-start --config imageset-config.yaml docker://registry.example.com:5000
+
+			# Serve the control API on localhost to run submitted imageset configurations
+			oc-mirror serve --api-addr localhost:8080 --api-token-file token docker://registry.example.com:5000
+			curl -H "Authorization: Bearer $(cat token)" --data-binary @imageset-config.yaml http://localhost:8080/api/v1/runs
+
+			# Notify a webhook of the results of each run
+			oc-mirror serve --schedule "0 */6 * * *" --config imageset-config.yaml docker://registry.example.com:5000 \
+				--webhook https://ci.example.com/hooks/oc-mirror
+		`),
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			cli.CheckErr(exitcode.With(exitcode.ConfigError, o.Complete(cmd, args)))
+			cli.CheckErr(exitcode.With(exitcode.ConfigError, o.Mirror.ConfigureRegistries()))
+			cli.CheckErr(exitcode.With(exitcode.ConfigError, o.Validate()))
+			cli.CheckErr(o.Run(cmd, f))
+		},
+	}
+
+	o.Mirror.BindFlags(cmd.Flags())
+	fs := cmd.Flags()
+	fs.StringVar(&o.Schedule, "schedule", o.Schedule, "Cron schedule of the runs (e.g. \"0 2 * * 6\" or @daily)")
+	fs.BoolVar(&o.RunOnStart, "run-on-start", o.RunOnStart, "Run once when the daemon starts, then follow the schedule")
+	fs.StringVar(&o.APIAddr, "api-addr", o.APIAddr, "Serve the control API on this address to submit runs "+
+		"and track their progress and results (e.g. localhost:8080)")
+	fs.StringVar(&o.APITokenFile, "api-token-file", o.APITokenFile, "File with the bearer token required "+
+		"by the control API (in the Authorization header of the requests)")
+
+	return cmd
+}
+
+func (o *ServeOptions) Complete(cmd *cobra.Command, args []string) error {
+	if strings.HasPrefix(args[0], "file://") && cmd.Flags().Changed("dir") {
+		return fmt.Errorf("--dir cannot be specified with file destination scheme")
+	}
+	o.CompleteMirrorOptions(o.Mirror)
+	if err := o.Mirror.CompleteDestination(args[0]); err != nil {
+		return err
+	}
+	if o.now == nil {
+		o.now = time.Now
+	}
+	return nil
+}
+
+func (o *ServeOptions) Validate() error {
+	switch {
+	case o.Schedule == "" && o.APIAddr == "":
+		return errors.New("must specify a schedule with --schedule or a control API address with --api-addr")
+	case o.Schedule != "" && o.Mirror.ConfigPath == "":
+		return errors.New("must specify imageset configuration with --config to run on a schedule")
+	case o.RunOnStart && o.Schedule == "":
+		return errors.New("--run-on-start requires --schedule")
+	case o.APITokenFile != "" && o.APIAddr == "":
+		return errors.New("--api-token-file requires --api-addr")
+	case o.APIAddr != "" && o.APITokenFile == "" && !isLoopbackAddr(o.APIAddr):
+		return errors.New("--api-token-file is required to serve the control API on an address other than localhost")
+	case o.Mirror.From != "":
+		return errors.New("--from is not supported: imagesets are published when they are transferred, not on a schedule")
+	}
+	if o.Schedule != "" {
+		var err error
+		if o.schedule, err = schedule.Parse(o.Schedule); err != nil {
+			return err
+		}
+	}
+	// The configuration is not required since runs
+	// submitted to the control API bring their own
+	return o.Mirror.ValidateOptions()
+}
+
+// isLoopbackAddr returns whether the host of the address
+// only accepts connections from the local host
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (o *ServeOptions) Run(cmd *cobra.Command, f kcmdutil.Factory) error {
+	store, err := newRunStore(filepath.Join(o.Dir, serveRunsDir))
+	if err != nil {
+		return err
+	}
+	queue := make(chan string, serveQueueSize)
+	if o.APIAddr != "" {
+		api := &serveAPI{store: store, queue: queue, now: o.now}
+		if o.APITokenFile != "" {
+			if api.token, err = readAPIToken(o.APITokenFile); err != nil {
+				return err
+			}
+		}
+		// The API keeps serving the status of the current run after a stop signal
+		apiCtx, stopAPI := context.WithCancel(context.Background())
+		defer stopAPI()
+		addr, err := api.serveAt(apiCtx, o.APIAddr)
+		if err != nil {
+			return err
+		}
+		logrus.Infof("Serving the control API at http://%s/api/v1/runs", addr)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// Restore the default handling so a second signal aborts the current run
+		stop()
+	}()
+	return o.serve(ctx, store, queue, func(run v1alpha2.Run) (string, error) {
+		configPath, outputEvents := o.Mirror.ConfigPath, o.Mirror.OutputEvents
+		defer func() {
+			o.Mirror.ConfigPath, o.Mirror.OutputEvents, o.Mirror.RemoteConfig = configPath, outputEvents, false
+		}()
+		if run.Trigger == v1alpha2.RunTriggerAPI {
+			o.Mirror.ConfigPath = filepath.Join(store.runDir(run.ID), serveConfigFile)
+			o.Mirror.RemoteConfig = true
+		}
+		// Events are recorded to track the progress of the run,
+		// unless they are written elsewhere
+		if o.Mirror.OutputEvents == "" {
+			o.Mirror.OutputEvents = filepath.Join(store.runDir(run.ID), serveEventsFile)
+		}
+		_, err := o.Mirror.Execute(cmd.Context(), f)
+		return o.Mirror.ResultsDir(), err
+	})
+}
+
+// serve runs the runs of the schedule and the runs of the queue
+// one at a time until the context is done. Each run returns its
+// results directory.
+func (o *ServeOptions) serve(ctx context.Context, store *runStore, queue <-chan string, run func(v1alpha2.Run) (string, error)) error {
+	var next time.Time
+	if o.schedule != nil {
+		next = o.now()
+		if !o.RunOnStart {
+			next = o.schedule.Next(next)
+		}
+	}
+	for {
+		var due <-chan time.Time
+		stopTimer := func() bool { return false }
+		if o.schedule != nil {
+			if next.IsZero() {
+				return errors.New("schedule has no upcoming runs")
+			}
+			logrus.Infof("Next scheduled run at %s", next.Format(time.RFC3339))
+			timer := time.NewTimer(next.Sub(o.now()))
+			due, stopTimer = timer.C, timer.Stop
+		}
+
+		var id string
+		select {
+		case <-ctx.Done():
+			stopTimer()
+			logrus.Info("Stopping")
+			return nil
+		case <-due:
+			r, err := store.add(v1alpha2.RunTriggerSchedule, nil, o.now())
+			if err != nil {
+				return err
+			}
+			id = r.ID
+		case id = <-queue:
+			stopTimer()
+		}
+		o.execute(store, id, run)
+
+		if ctx.Err() != nil {
+			logrus.Info("Stopping")
+			return nil
+		}
+		// Scheduled times missed during the run are skipped
+		if o.schedule != nil && !next.After(o.now()) {
+			next = o.schedule.Next(o.now())
+		}
+	}
+}
+
+// execute runs the queued run and records its outcome
+func (o *ServeOptions) execute(store *runStore, id string, run func(v1alpha2.Run) (string, error)) {
+	start := o.now()
+	if err := store.update(id, func(r *v1alpha2.Run) {
+		r.Status = v1alpha2.RunStatusRunning
+		t := metav1.NewTime(start)
+		r.StartTime = &t
+	}); err != nil {
+		logrus.Errorf("error recording run %s: %v", id, err)
+	}
+	r, _ := store.get(id)
+	logrus.Infof("Starting %s run %s", r.Trigger, id)
+
+	resultsDir, err := run(r)
+	code := exitcode.Of(err)
+	if err != nil {
+		logrus.Errorf("Run %s failed with exit code %d: %v", id, code, err)
+	} else {
+		logrus.Infof("Run %s succeeded in %s", id, o.now().Sub(start).Round(time.Second))
+	}
+	if uerr := store.update(id, func(r *v1alpha2.Run) {
+		r.Status = v1alpha2.RunStatusSucceeded
+		if err != nil {
+			r.Status = v1alpha2.RunStatusFailed
+			r.Error = err.Error()
+		}
+		t := metav1.NewTime(o.now())
+		r.EndTime = &t
+		r.ExitCode = &code
+		r.ResultsDir = resultsDir
+	}); uerr != nil {
+		logrus.Errorf("error recording run %s: %v", id, uerr)
+	}
+}
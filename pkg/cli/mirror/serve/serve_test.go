@@ -0,0 +1,160 @@
+package serve
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/cli"
+	"github.com/openshift/oc-mirror/pkg/exitcode"
+	"github.com/openshift/oc-mirror/pkg/mirror"
+	"github.com/openshift/oc-mirror/pkg/schedule"
+)
+
+func TestServe(t *testing.T) {
+	s, err := schedule.Parse("@hourly")
+	require.NoError(t, err)
+	o := &ServeOptions{
+		RootOptions: &cli.RootOptions{},
+		Mirror:      mirror.NewMirrorOptions(),
+		RunOnStart:  true,
+		schedule:    s,
+		now:         time.Now,
+	}
+	store, err := newRunStore(t.TempDir())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var runs []v1alpha2.Run
+	require.NoError(t, o.serve(ctx, store, nil, func(r v1alpha2.Run) (string, error) {
+		runs = append(runs, r)
+		cancel()
+		return "results-2", exitcode.With(exitcode.PartialFailure, errors.New("failed runs do not stop the daemon"))
+	}))
+	require.Len(t, runs, 1)
+	require.Equal(t, v1alpha2.RunTriggerSchedule, runs[0].Trigger)
+	require.Equal(t, v1alpha2.RunStatusRunning, runs[0].Status)
+
+	recorded := store.list()
+	require.Len(t, recorded, 1)
+	require.Equal(t, v1alpha2.RunStatusFailed, recorded[0].Status)
+	require.Equal(t, "results-2", recorded[0].ResultsDir)
+	require.Equal(t, exitcode.PartialFailure, *recorded[0].ExitCode)
+	require.NotNil(t, recorded[0].EndTime)
+
+	never, err := schedule.Parse("0 0 30 feb *")
+	require.NoError(t, err)
+	o.schedule = never
+	o.RunOnStart = false
+	require.EqualError(t, o.serve(context.Background(), store, nil, func(v1alpha2.Run) (string, error) { return "", nil }), "schedule has no upcoming runs")
+}
+
+func TestServeQueue(t *testing.T) {
+	o := &ServeOptions{RootOptions: &cli.RootOptions{}, Mirror: mirror.NewMirrorOptions(), now: time.Now}
+	store, err := newRunStore(t.TempDir())
+	require.NoError(t, err)
+	queued, err := store.add(v1alpha2.RunTriggerAPI, []byte("kind: ImageSetConfiguration"), time.Now())
+	require.NoError(t, err)
+	queue := make(chan string, 1)
+	queue <- queued.ID
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, o.serve(ctx, store, queue, func(r v1alpha2.Run) (string, error) {
+		require.Equal(t, queued.ID, r.ID)
+		cancel()
+		return "", nil
+	}))
+	run, ok := store.get(queued.ID)
+	require.True(t, ok)
+	require.Equal(t, v1alpha2.RunStatusSucceeded, run.Status)
+	require.Equal(t, exitcode.Success, *run.ExitCode)
+
+	// Runs interrupted by a restart are recorded as failed
+	require.NoError(t, store.update(queued.ID, func(r *v1alpha2.Run) { r.Status = v1alpha2.RunStatusRunning }))
+	store, err = newRunStore(store.dir)
+	require.NoError(t, err)
+	run, ok = store.get(queued.ID)
+	require.True(t, ok)
+	require.Equal(t, v1alpha2.RunStatusFailed, run.Status)
+	require.Equal(t, "interrupted by a restart of the daemon", run.Error)
+}
+
+func TestServeValidate(t *testing.T) {
+	type spec struct {
+		name     string
+		opts     *ServeOptions
+		expError string
+	}
+	cases := []spec{
+		{
+			name:     "Invalid/NoScheduleOrAPI",
+			opts:     &ServeOptions{Mirror: &mirror.MirrorOptions{ConfigPath: "imageset-config.yaml"}},
+			expError: "must specify a schedule with --schedule or a control API address with --api-addr",
+		},
+		{
+			name:     "Invalid/NoConfig",
+			opts:     &ServeOptions{Mirror: &mirror.MirrorOptions{}, Schedule: "@daily"},
+			expError: "must specify imageset configuration with --config to run on a schedule",
+		},
+		{
+			name:     "Invalid/RunOnStartWithoutSchedule",
+			opts:     &ServeOptions{Mirror: &mirror.MirrorOptions{}, APIAddr: "localhost:8080", RunOnStart: true},
+			expError: "--run-on-start requires --schedule",
+		},
+		{
+			name:     "Invalid/TokenWithoutAPI",
+			opts:     &ServeOptions{Mirror: &mirror.MirrorOptions{ConfigPath: "imageset-config.yaml"}, Schedule: "@daily", APITokenFile: "token"},
+			expError: "--api-token-file requires --api-addr",
+		},
+		{
+			name:     "Invalid/RemoteAPIWithoutToken",
+			opts:     &ServeOptions{Mirror: &mirror.MirrorOptions{}, APIAddr: ":8080"},
+			expError: "--api-token-file is required to serve the control API on an address other than localhost",
+		},
+		{
+			name: "Valid/LoopbackAPIWithoutToken",
+			opts: &ServeOptions{Mirror: &mirror.MirrorOptions{}, APIAddr: "127.0.0.1:8080"},
+		},
+		{
+			name: "Valid/RemoteAPIWithToken",
+			opts: &ServeOptions{Mirror: &mirror.MirrorOptions{}, APIAddr: "0.0.0.0:8080", APITokenFile: "token"},
+		},
+		{
+			name:     "Invalid/From",
+			opts:     &ServeOptions{Mirror: &mirror.MirrorOptions{ConfigPath: "imageset-config.yaml", From: "archives"}, Schedule: "@daily"},
+			expError: "--from is not supported: imagesets are published when they are transferred, not on a schedule",
+		},
+		{
+			name:     "Invalid/Schedule",
+			opts:     &ServeOptions{Mirror: &mirror.MirrorOptions{ConfigPath: "imageset-config.yaml"}, Schedule: "daily"},
+			expError: `invalid schedule "daily": expected 5 fields (minute hour day-of-month month day-of-week), got 1`,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			err := c.opts.Validate()
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestIsLoopbackAddr(t *testing.T) {
+	require.True(t, isLoopbackAddr("localhost:8080"))
+	require.True(t, isLoopbackAddr("127.0.0.1:8080"))
+	require.True(t, isLoopbackAddr("[::1]:8080"))
+	require.False(t, isLoopbackAddr(":8080"))
+	require.False(t, isLoopbackAddr("0.0.0.0:8080"))
+	require.False(t, isLoopbackAddr("mirror.example.com:8080"))
+	require.False(t, isLoopbackAddr("localhost"))
+}
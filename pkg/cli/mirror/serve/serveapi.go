@@ -0,0 +1,467 @@
+package serve
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/config"
+)
+
+const (
+	// serveRunsDir is the directory of the workspace recording the runs of the daemon
+	serveRunsDir = "serve-runs"
+	// serveRunFile is the record of a run in its directory
+	serveRunFile = "run.json"
+	// serveConfigFile is the imageset configuration submitted for a run
+	serveConfigFile = "imageset-config.yaml"
+	// serveEventsFile are the events of a run
+	serveEventsFile = "events.ndjson"
+	// resultsFile is the name of the results file
+	// oc-mirror writes to the results directory of a run
+	resultsFile = "results.json"
+	// serveQueueSize is the number of submitted runs that can wait for the current run
+	serveQueueSize = 10
+	// maxConfigSize is the size limit of submitted imageset configurations
+	maxConfigSize = 1 << 20
+)
+
+// runStore records the runs of the daemon in the workspace
+type runStore struct {
+	mu   sync.Mutex
+	dir  string
+	runs map[string]*v1alpha2.Run
+}
+
+// newRunStore loads the runs recorded in dir. Runs left queued or running
+// by a previous daemon are recorded as failed.
+func newRunStore(dir string) (*runStore, error) {
+	s := &runStore{dir: dir, runs: map[string]*v1alpha2.Run{}}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*", serveRunFile))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		run := &v1alpha2.Run{}
+		if err := json.Unmarshal(data, run); err != nil {
+			logrus.Warnf("skipping unreadable run record %s: %v", path, err)
+			continue
+		}
+		s.runs[run.ID] = run
+		if run.Status == v1alpha2.RunStatusQueued || run.Status == v1alpha2.RunStatusRunning {
+			if err := s.update(run.ID, func(r *v1alpha2.Run) {
+				r.Status = v1alpha2.RunStatusFailed
+				r.Error = "interrupted by a restart of the daemon"
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// runDir returns the directory of the run
+func (s *runStore) runDir(id string) string {
+	return filepath.Join(s.dir, id)
+}
+
+// add records a new queued run, writing its imageset configuration if set
+func (s *runStore) add(trigger v1alpha2.RunTrigger, cfg []byte, now time.Time) (v1alpha2.Run, error) {
+	run := &v1alpha2.Run{
+		ID:         uuid.New().String(),
+		Trigger:    trigger,
+		Status:     v1alpha2.RunStatusQueued,
+		SubmitTime: metav1.NewTime(now),
+	}
+	if err := os.MkdirAll(s.runDir(run.ID), os.ModePerm); err != nil {
+		return v1alpha2.Run{}, err
+	}
+	if cfg != nil {
+		if err := ioutil.WriteFile(filepath.Join(s.runDir(run.ID), serveConfigFile), cfg, 0640); err != nil {
+			return v1alpha2.Run{}, err
+		}
+	}
+	s.mu.Lock()
+	s.runs[run.ID] = run
+	s.mu.Unlock()
+	return *run, s.update(run.ID, func(*v1alpha2.Run) {})
+}
+
+// remove forgets a run and removes its directory
+func (s *runStore) remove(id string) error {
+	s.mu.Lock()
+	delete(s.runs, id)
+	s.mu.Unlock()
+	return os.RemoveAll(s.runDir(id))
+}
+
+// update changes the run and writes its record
+func (s *runStore) update(id string, change func(*v1alpha2.Run)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[id]
+	if !ok {
+		return fmt.Errorf("run %s not found", id)
+	}
+	change(run)
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(s.runDir(id), serveRunFile), append(data, '\n'), 0640)
+}
+
+// get returns a copy of the run
+func (s *runStore) get(id string) (v1alpha2.Run, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[id]
+	if !ok {
+		return v1alpha2.Run{}, false
+	}
+	return *run, true
+}
+
+// list returns copies of the runs in the order they were submitted
+func (s *runStore) list() []v1alpha2.Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := make([]v1alpha2.Run, 0, len(s.runs))
+	for _, run := range s.runs {
+		runs = append(runs, *run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		if !runs[i].SubmitTime.Equal(&runs[j].SubmitTime) {
+			return runs[i].SubmitTime.Before(&runs[j].SubmitTime)
+		}
+		return runs[i].ID < runs[j].ID
+	})
+	return runs
+}
+
+// progress computes the progress of the run from its events
+func (s *runStore) progress(id string) (*v1alpha2.RunProgress, error) {
+	f, err := os.Open(filepath.Join(s.runDir(id), serveEventsFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	progress := &v1alpha2.RunProgress{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event v1alpha2.Event
+		// The last line may be partially written
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		switch event.Type {
+		case v1alpha2.EventPhaseStart:
+			progress.Phase = event.Phase
+		case v1alpha2.EventPhaseEnd:
+			progress.Phase = ""
+			progress.CompletedPhases = append(progress.CompletedPhases, event.Phase)
+		case v1alpha2.EventImagePushed:
+			progress.ImagesPushed++
+		case v1alpha2.EventBlobFetched:
+			progress.BlobsFetched++
+			progress.BytesFetched += event.Size
+		case v1alpha2.EventError:
+			progress.Errors++
+		}
+	}
+	return progress, scanner.Err()
+}
+
+// serveAPI is the control API of the daemon
+type serveAPI struct {
+	store *runStore
+	queue chan<- string
+	// token is the bearer token of the requests, if set
+	token string
+	now   func() time.Time
+}
+
+func (a *serveAPI) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.Handle("/api/v1/runs", a.authenticate(http.HandlerFunc(a.runs)))
+	mux.Handle("/api/v1/runs/", a.authenticate(http.HandlerFunc(a.run)))
+	return mux
+}
+
+// authenticate requires the bearer token on the requests if set
+func (a *serveAPI) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.token != "" {
+			token, ok := bearerToken(r.Header.Get("Authorization"))
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+				writeAPIError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bearerToken returns the token of a bearer authorization header
+func bearerToken(header string) (string, bool) {
+	const prefix = "Bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	return header[len(prefix):], true
+}
+
+// runs lists the runs and submits new runs
+func (a *serveAPI) runs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeAPIJSON(w, http.StatusOK, a.store.list())
+	case http.MethodPost:
+		a.submit(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// submit queues a run of the imageset configuration in the request body
+func (a *serveAPI) submit(w http.ResponseWriter, r *http.Request) {
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxConfigSize))
+	if err != nil {
+		writeAPIError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("error reading imageset configuration: %v", err))
+		return
+	}
+	run, err := a.store.add(v1alpha2.RunTriggerAPI, data, a.now())
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	cfg, err := config.ReadConfig(filepath.Join(a.store.runDir(run.ID), serveConfigFile))
+	if err != nil {
+		a.discard(run.ID)
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid imageset configuration: %v", err))
+		return
+	}
+	// Hooks run commands on the host, so they are only
+	// accepted from the --config of the daemon
+	if len(cfg.Hooks) != 0 {
+		a.discard(run.ID)
+		writeAPIError(w, http.StatusBadRequest, "hooks are not accepted in submitted imageset configurations")
+		return
+	}
+	// Registries are configured when the daemon starts, and their
+	// credential helpers and vault addresses are trusted with the
+	// credentials of the host, so they are not accepted either
+	if len(cfg.Registries) != 0 {
+		a.discard(run.ID)
+		writeAPIError(w, http.StatusBadRequest, "registries are not accepted in submitted imageset configurations")
+		return
+	}
+	select {
+	case a.queue <- run.ID:
+	default:
+		a.discard(run.ID)
+		writeAPIError(w, http.StatusServiceUnavailable, fmt.Sprintf("%d runs are already queued", serveQueueSize))
+		return
+	}
+	logrus.Infof("Queued run %s submitted by %s", run.ID, r.RemoteAddr)
+	w.Header().Set("Location", "/api/v1/runs/"+run.ID)
+	writeAPIJSON(w, http.StatusAccepted, run)
+}
+
+func (a *serveAPI) discard(id string) {
+	if err := a.store.remove(id); err != nil {
+		logrus.Errorf("error removing run %s: %v", id, err)
+	}
+}
+
+// run serves a run, its events, and the files of its results directory:
+// /api/v1/runs/{id}, /api/v1/runs/{id}/events, /api/v1/runs/{id}/files[/{path}]
+func (a *serveAPI) run(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/api/v1/runs/"), "/", 3)
+	run, ok := a.store.get(parts[0])
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("run %q not found", parts[0]))
+		return
+	}
+	switch {
+	case len(parts) == 1:
+		a.runStatus(w, run)
+	case parts[1] == "events" && len(parts) == 2:
+		path := filepath.Join(a.store.runDir(run.ID), serveEventsFile)
+		if _, err := os.Stat(path); err != nil {
+			writeAPIError(w, http.StatusNotFound, "the run has no events, which are not recorded with --output-events")
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		http.ServeFile(w, r, path)
+	case parts[1] == "files" && run.ResultsDir == "":
+		writeAPIError(w, http.StatusNotFound, "the run has no results directory yet")
+	case parts[1] == "files" && (len(parts) == 2 || parts[2] == ""):
+		files, err := listRunFiles(run.ResultsDir)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeAPIJSON(w, http.StatusOK, files)
+	case parts[1] == "files":
+		path, err := runFilePath(run.ResultsDir, parts[2])
+		if err != nil {
+			writeAPIError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		http.ServeFile(w, r, path)
+	default:
+		writeAPIError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// runStatus writes the run with its progress and results
+func (a *serveAPI) runStatus(w http.ResponseWriter, run v1alpha2.Run) {
+	progress, err := a.store.progress(run.ID)
+	if err != nil {
+		logrus.Debugf("error reading the events of run %s: %v", run.ID, err)
+	}
+	run.Progress = progress
+	if run.ResultsDir != "" {
+		if data, err := ioutil.ReadFile(filepath.Join(run.ResultsDir, resultsFile)); err == nil {
+			results := &v1alpha2.Results{}
+			if err := json.Unmarshal(data, results); err == nil {
+				run.Results = results
+			}
+		}
+	}
+	writeAPIJSON(w, http.StatusOK, run)
+}
+
+// listRunFiles returns the paths of the regular files in the results directory
+func listRunFiles(dir string) ([]string, error) {
+	files := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	return files, err
+}
+
+// runFilePath returns the path of a regular file in the results directory,
+// rejecting paths outside of it
+func runFilePath(dir, name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file %q not found", name)
+	}
+	path := filepath.Join(dir, clean)
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", fmt.Errorf("file %q not found", name)
+	}
+	return path, nil
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(data, '\n'))
+}
+
+func writeAPIError(w http.ResponseWriter, status int, msg string) {
+	data, _ := json.Marshal(map[string]string{"error": msg})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(data, '\n'))
+}
+
+// serveAt serves the control API on addr until the context is done,
+// returning the address it listens on
+func (a *serveAPI) serveAt(ctx context.Context, addr string) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("error listening for the control API on %s: %v", addr, err)
+	}
+	server := &http.Server{Handler: a.handler()}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Errorf("error serving the control API: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(sctx); err != nil {
+			logrus.Errorf("error stopping the control API: %v", err)
+		}
+	}()
+	return listener.Addr().String(), nil
+}
+
+// readAPIToken reads the bearer token of the control API from the file
+func readAPIToken(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading the control API token: %v", err)
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(f, 4096))
+	if err != nil {
+		return "", fmt.Errorf("error reading the control API token: %v", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("control API token file %s is empty", path)
+	}
+	return token, nil
+}
@@ -0,0 +1,171 @@
+package serve
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+const testServeConfig = `apiVersion: mirror.openshift.io/v1alpha2
+kind: ImageSetConfiguration
+mirror:
+  additionalImages:
+  - name: registry.example.com/ubi8/ubi:latest
+`
+
+func TestServeAPI(t *testing.T) {
+	store, err := newRunStore(t.TempDir())
+	require.NoError(t, err)
+	queue := make(chan string, 1)
+	api := &serveAPI{store: store, queue: queue, token: "s3cret", now: time.Now}
+	server := httptest.NewServer(api.handler())
+	t.Cleanup(server.Close)
+
+	do := func(method, path, body string) (*http.Response, string) {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(data)
+	}
+
+	// Requests without the token are rejected
+	resp, err := http.Get(server.URL + "/api/v1/runs")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// The token must be sent with the bearer scheme
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/runs", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "s3cret")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, body := do(http.MethodPost, "/api/v1/runs", "kind: Foo")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+	require.Contains(t, body, "invalid imageset configuration")
+
+	// Hooks are not run from submitted configurations
+	resp, body = do(http.MethodPost, "/api/v1/runs", testServeConfig+`hooks:
+- phase: plan
+  when: before
+  command: ["touch", "/tmp/pwned"]
+`)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+	require.Contains(t, body, "hooks are not accepted")
+	require.Empty(t, store.list())
+
+	// Nor are registries, whose credential helpers run on the host
+	resp, body = do(http.MethodPost, "/api/v1/runs", testServeConfig+`registries:
+- host: registry.example.com
+  credentialHelper: /tmp/pwned
+`)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+	require.Contains(t, body, "registries are not accepted")
+	require.Empty(t, store.list())
+
+	resp, body = do(http.MethodPost, "/api/v1/runs", testServeConfig)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode, body)
+	var run v1alpha2.Run
+	require.NoError(t, json.Unmarshal([]byte(body), &run))
+	require.Equal(t, v1alpha2.RunStatusQueued, run.Status)
+	require.Equal(t, "/api/v1/runs/"+run.ID, resp.Header.Get("Location"))
+	require.Equal(t, run.ID, <-queue)
+	config, err := ioutil.ReadFile(filepath.Join(store.runDir(run.ID), serveConfigFile))
+	require.NoError(t, err)
+	require.Equal(t, testServeConfig, string(config))
+
+	// Runs are not queued beyond the queue size
+	queue <- "other"
+	resp, body = do(http.MethodPost, "/api/v1/runs", testServeConfig)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, body)
+	require.Len(t, store.list(), 1)
+
+	// The progress is computed from the events of the run
+	events := `{"type":"phaseStart","phase":"mirror"}
+{"type":"blobFetched","size":100}
+{"type":"blobFetched","size":50}
+{"type":"phaseEnd","phase":"mirror"}
+{"type":"phaseStart","phase":"archive"}
+{"type":"err`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(store.runDir(run.ID), serveEventsFile), []byte(events), 0640))
+	resp, body = do(http.MethodGet, "/api/v1/runs/"+run.ID, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	var status v1alpha2.Run
+	require.NoError(t, json.Unmarshal([]byte(body), &status))
+	require.Equal(t, &v1alpha2.RunProgress{Phase: "archive", CompletedPhases: []string{"mirror"}, BlobsFetched: 2, BytesFetched: 150}, status.Progress)
+
+	resp, body = do(http.MethodGet, "/api/v1/runs/"+run.ID+"/files", "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode, body)
+
+	// Files of the results directory are served once the run finished
+	resultsDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(resultsDir, "manifests"), 0750))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(resultsDir, resultsFile), []byte(`{"success": true}`), 0640))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(resultsDir, "manifests", "icsp.yaml"), []byte("kind: ImageContentSourcePolicy\n"), 0640))
+	require.NoError(t, store.update(run.ID, func(r *v1alpha2.Run) {
+		r.Status = v1alpha2.RunStatusSucceeded
+		r.ResultsDir = resultsDir
+	}))
+
+	resp, body = do(http.MethodGet, "/api/v1/runs/"+run.ID, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	require.NoError(t, json.Unmarshal([]byte(body), &status))
+	require.NotNil(t, status.Results)
+	require.True(t, status.Results.Success)
+
+	resp, body = do(http.MethodGet, "/api/v1/runs/"+run.ID+"/files", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	var files []string
+	require.NoError(t, json.Unmarshal([]byte(body), &files))
+	require.Equal(t, []string{"manifests/icsp.yaml", resultsFile}, files)
+
+	resp, body = do(http.MethodGet, "/api/v1/runs/"+run.ID+"/files/manifests/icsp.yaml", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	require.Equal(t, "kind: ImageContentSourcePolicy\n", body)
+
+	resp, body = do(http.MethodGet, "/api/v1/runs/"+run.ID+"/files/..%2F..%2Fetc%2Fpasswd", "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode, body)
+
+	resp, body = do(http.MethodGet, "/api/v1/runs", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	var runs []v1alpha2.Run
+	require.NoError(t, json.Unmarshal([]byte(body), &runs))
+	require.Len(t, runs, 1)
+	require.Equal(t, v1alpha2.RunStatusSucceeded, runs[0].Status)
+
+	resp, body = do(http.MethodGet, "/api/v1/runs/unknown", "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode, body)
+}
+
+func TestRunFilePath(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, resultsFile), []byte("{}"), 0640))
+	require.NoError(t, os.Symlink("/etc/passwd", filepath.Join(dir, "passwd")))
+
+	path, err := runFilePath(dir, resultsFile)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, resultsFile), path)
+
+	for _, name := range []string{"../" + filepath.Base(dir) + "/" + resultsFile, "/etc/passwd", "passwd", "missing"} {
+		_, err := runFilePath(dir, name)
+		require.EqualError(t, err, `file "`+name+`" not found`)
+	}
+}
@@ -0,0 +1,366 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/opencontainers/go-digest"
+	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/cli"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/mirror"
+	"github.com/openshift/oc-mirror/pkg/version"
+)
+
+const (
+	verifyTableOutput = "table"
+	verifyJSONOutput  = "json"
+
+	// verifyWorkers is the number of images verified concurrently
+	verifyWorkers = 8
+)
+
+// VerifyOptions configures the verification of a destination registry
+type VerifyOptions struct {
+	*cli.RootOptions
+	// Mirror are the options of the destination
+	Mirror *mirror.MirrorOptions
+	// VerifyBlobContent downloads the blobs to check their
+	// content digests instead of checking that they exist
+	VerifyBlobContent bool
+	// Output is the format of the report
+	Output string
+}
+
+func NewVerifyCommand(f kcmdutil.Factory, ro *cli.RootOptions) *cobra.Command {
+	o := VerifyOptions{RootOptions: ro, Mirror: cli.NewMirrorOptions()}
+
+	cmd := &cobra.Command{
+		Use:   "verify <destination registry>",
+		Short: "Verify that a destination registry has the images recorded by the metadata",
+		Long: templates.LongDesc(`
+			Verify that a destination registry namespace has every image recorded by
+			the metadata of the workspace or of the destination, with the digests
+			recorded from the source registries.
+
+			The images verified are the images of the last sequence of each metadata
+			found in the storage of the imageset configuration and published to the
+			destination. For each image, the manifest and the manifests of its
+			platforms are downloaded and their content digests checked, the tag must
+			point to the recorded digest, and the layers and configuration blobs must
+			exist. With --verify-blob-content, the blobs are also downloaded and their
+			content digests checked.
+
+			The report lists every image checked with its result and the metadata it
+			was recorded by, and the command fails if any image does not match. Use
+			the JSON output to keep the report as evidence of the mirrored content.
+		`),
+		Example: templates.Examples(`
+			# Verify the images mirrored to a registry namespace
+			oc-mirror verify docker://registry.example.com/mirror --config imageset-config.yaml
+
+			# Check the content of every blob and write the report as JSON
+			oc-mirror verify docker://registry.example.com/mirror --verify-blob-content -o json > verify-report.json
+		`),
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckErr(o.Complete(cmd, args))
+			kcmdutil.CheckErr(o.Validate())
+			kcmdutil.CheckErr(o.Run(cmd.Context()))
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&o.Mirror.ConfigPath, "config", "c", o.Mirror.ConfigPath, "Path to the imageset configuration file with the metadata storage and registries")
+	fs.BoolVar(&o.VerifyBlobContent, "verify-blob-content", o.VerifyBlobContent, "Download the blobs and check their content digests instead of checking they exist")
+	fs.StringVarP(&o.Output, "output", "o", verifyTableOutput, "Output format: table or json")
+	fs.BoolVar(&o.Mirror.DestSkipTLS, "dest-skip-tls", o.Mirror.DestSkipTLS, "Disable TLS validation for destination registry")
+	fs.BoolVar(&o.Mirror.DestPlainHTTP, "dest-use-http", o.Mirror.DestPlainHTTP, "Use plain HTTP for destination registry")
+	fs.StringSliceVar(&o.Mirror.InsecureRegistries, "insecure-registry", o.Mirror.InsecureRegistries, "Registry the insecure options apply to, "+
+		"in addition to the registries marked insecure in the configuration (can be repeated)")
+
+	return cmd
+}
+
+func (o *VerifyOptions) Complete(cmd *cobra.Command, args []string) error {
+	o.CompleteMirrorOptions(o.Mirror)
+	return o.Mirror.CompleteDestination(args[0])
+}
+
+func (o *VerifyOptions) Validate() error {
+	if o.Mirror.ToMirror == "" {
+		return errors.New("destination must be a registry reference (docker://registry[/namespace])")
+	}
+	switch o.Output {
+	case verifyTableOutput, verifyJSONOutput:
+		return nil
+	default:
+		return fmt.Errorf("output format %q is not supported: must be %s or %s", o.Output, verifyTableOutput, verifyJSONOutput)
+	}
+}
+
+func (o *VerifyOptions) Run(ctx context.Context) error {
+	if err := o.Mirror.ConfigureRegistries(); err != nil {
+		return err
+	}
+	var cfg *v1alpha2.ImageSetConfiguration
+	if o.Mirror.ConfigPath != "" {
+		c, err := config.ReadConfig(o.Mirror.ConfigPath)
+		if err != nil {
+			return err
+		}
+		cfg = &c
+	}
+
+	metas, err := o.Mirror.ReadDestinationMetadata(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	report := verifyReport{
+		Destination:       path.Join(o.Mirror.ToMirror, o.Mirror.UserNamespace),
+		Time:              time.Now().UTC().Format(time.RFC3339),
+		Version:           version.Get().GitVersion,
+		VerifyBlobContent: o.VerifyBlobContent,
+		Metadata:          []verifiedMetadata{},
+	}
+	var assocs []v1alpha2.Association
+	seen := map[string]struct{}{}
+	for _, meta := range metas {
+		report.Metadata = append(report.Metadata, verifiedMetadata{UID: meta.Uid.String(), Sequence: meta.PastMirror.Sequence})
+		for _, assoc := range append(append([]v1alpha2.Association{}, meta.PastAssociations...), meta.PastMirror.Associations...) {
+			key := strings.Join([]string{assoc.Name, assoc.Path, assoc.ID, assoc.TagSymlink}, " ")
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			assocs = append(assocs, assoc)
+		}
+	}
+	if len(assocs) == 0 {
+		return fmt.Errorf("no metadata recording images found for %s", report.Destination)
+	}
+
+	if report.Images, err = o.verifyImages(ctx, assocs); err != nil {
+		return err
+	}
+	for _, img := range report.Images {
+		if len(img.Failures) != 0 {
+			report.Failed++
+		}
+	}
+	report.Passed = report.Failed == 0
+
+	if err := writeVerifyReport(o.Out, report, o.Output); err != nil {
+		return err
+	}
+	if !report.Passed {
+		return fmt.Errorf("%d of %d images do not match the metadata", report.Failed, len(report.Images))
+	}
+	return nil
+}
+
+// verifyReport is the report of the verification of a destination
+type verifyReport struct {
+	Destination       string `json:"destination"`
+	Time              string `json:"time"`
+	Version           string `json:"version"`
+	VerifyBlobContent bool   `json:"verifyBlobContent"`
+	// Metadata are the metadata the images were recorded by
+	Metadata []verifiedMetadata `json:"metadata"`
+	Images   []verifiedImage    `json:"images"`
+	Failed   int                `json:"failed"`
+	Passed   bool               `json:"passed"`
+}
+
+// verifiedMetadata identifies metadata by its UID and last sequence
+type verifiedMetadata struct {
+	UID      string `json:"uid"`
+	Sequence int    `json:"sequence"`
+}
+
+// verifiedImage is the result of the verification of an association
+type verifiedImage struct {
+	Name        string   `json:"name"`
+	Destination string   `json:"destination"`
+	Digest      string   `json:"digest"`
+	Type        string   `json:"type"`
+	Manifests   int      `json:"manifests"`
+	Blobs       int      `json:"blobs"`
+	Failures    []string `json:"failures,omitempty"`
+}
+
+// verifyImages checks the destination of each association concurrently
+func (o *VerifyOptions) verifyImages(ctx context.Context, assocs []v1alpha2.Association) ([]verifiedImage, error) {
+	toMirrorRef := imagesource.TypedImageReference{Type: imagesource.DestinationRegistry}
+	toMirrorRef.Ref.Registry = o.Mirror.ToMirror
+
+	var mu sync.Mutex
+	images := make([]verifiedImage, 0, len(assocs))
+	work := make(chan v1alpha2.Association)
+	g, gctx := errgroup.WithContext(ctx)
+	for i := 0; i < verifyWorkers; i++ {
+		g.Go(func() error {
+			for assoc := range work {
+				dst, err := o.Mirror.PublishDestination(toMirrorRef, assoc)
+				if err != nil {
+					return err
+				}
+				img := o.verifyImage(gctx, dst, assoc)
+				mu.Lock()
+				images = append(images, img)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	g.Go(func() error {
+		defer close(work)
+		for _, assoc := range assocs {
+			select {
+			case work <- assoc:
+			case <-gctx.Done():
+				return gctx.Err()
+			}
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	sort.Slice(images, func(i, j int) bool {
+		if images[i].Destination != images[j].Destination {
+			return images[i].Destination < images[j].Destination
+		}
+		return images[i].Name < images[j].Name
+	})
+	return images, nil
+}
+
+// verifyImage checks that the destination has the manifests and
+// blobs of the association, and that its tag points to its digest
+func (o *VerifyOptions) verifyImage(ctx context.Context, dst imagesource.TypedImageReference, assoc v1alpha2.Association) verifiedImage {
+	insecure := o.Mirror.DestPlainHTTP || o.Mirror.DestSkipTLS
+	remoteOpts := o.Mirror.Clients().RemoteOptions(ctx, insecure)
+	img := verifiedImage{
+		Name:        assoc.Name,
+		Destination: dst.Ref.Exact(),
+		Digest:      assoc.ID,
+		Type:        assoc.Type.String(),
+	}
+	fail := func(format string, args ...interface{}) {
+		img.Failures = append(img.Failures, fmt.Sprintf(format, args...))
+	}
+
+	repo, err := name.NewRepository(path.Join(o.Mirror.ToMirror, dst.Ref.AsRepository().RepositoryName()), nameOptions(insecure)...)
+	if err != nil {
+		fail("invalid destination: %v", err)
+		return img
+	}
+	if assoc.TagSymlink != "" {
+		desc, err := remote.Head(repo.Tag(assoc.TagSymlink), remoteOpts...)
+		switch {
+		case err != nil:
+			fail("tag %s: %v", assoc.TagSymlink, err)
+		case desc.Digest.String() != assoc.ID:
+			fail("tag %s points to %s", assoc.TagSymlink, desc.Digest)
+		}
+	}
+
+	for _, dgst := range append([]string{assoc.ID}, assoc.ManifestDigests...) {
+		img.Manifests++
+		desc, err := remote.Get(repo.Digest(dgst), remoteOpts...)
+		if err != nil {
+			fail("manifest %s: %v", dgst, err)
+			continue
+		}
+		if actual := digest.FromBytes(desc.Manifest); actual.String() != dgst {
+			fail("manifest %s has content digest %s", dgst, actual)
+		}
+	}
+
+	for _, dgst := range assoc.LayerDigests {
+		img.Blobs++
+		layer, err := remote.Layer(repo.Digest(dgst), remoteOpts...)
+		if err != nil {
+			fail("blob %s: %v", dgst, err)
+			continue
+		}
+		if err := verifyBlob(layer, o.VerifyBlobContent); err != nil {
+			fail("blob %s: %v", dgst, err)
+		}
+	}
+	return img
+}
+
+// verifyBlob checks that the blob exists, or that its content
+// matches its digest when checking the content
+func verifyBlob(layer v1.Layer, content bool) error {
+	if !content {
+		_, err := layer.Size()
+		return err
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	// The reader fails when the content does not match the digest
+	_, err = io.Copy(ioutil.Discard, rc)
+	return err
+}
+
+// writeVerifyReport writes the report to w in the output format
+func writeVerifyReport(w io.Writer, report verifyReport, output string) error {
+	if output == verifyJSONOutput {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DESTINATION\tTYPE\tMANIFESTS\tBLOBS\tSTATUS")
+	for _, img := range report.Images {
+		status := "ok"
+		if len(img.Failures) != 0 {
+			status = strings.Join(img.Failures, "; ")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\n", img.Destination, img.Type, img.Manifests, img.Blobs, status)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+	_, err := fmt.Fprintf(w, "%d images verified, %d failed\n", len(report.Images), report.Failed)
+	return err
+}
+
+// nameOptions returns the options parsing references
+// of registries that are insecure or not
+func nameOptions(insecure bool) (options []name.Option) {
+	if insecure {
+		options = append(options, name.Insecure)
+	}
+	return options
+}
@@ -0,0 +1,221 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/cli"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
+	"github.com/openshift/oc-mirror/pkg/metadata/storage"
+	"github.com/openshift/oc-mirror/pkg/mirror"
+)
+
+func TestVerify(t *testing.T) {
+	newImage := func(content string) v1.Image {
+		layer, err := crane.Layer(map[string][]byte{"file": []byte(content)})
+		require.NoError(t, err)
+		img, err := mutate.AppendLayers(empty.Image, layer)
+		require.NoError(t, err)
+		return img
+	}
+	assoc := func(img v1.Image, path, tag string) v1alpha2.Association {
+		dgst, err := img.Digest()
+		require.NoError(t, err)
+		cfgName, err := img.ConfigName()
+		require.NoError(t, err)
+		layers, err := img.Layers()
+		require.NoError(t, err)
+		a := v1alpha2.Association{
+			Name:       fmt.Sprintf("registry.redhat.io/%s:%s", path, tag),
+			Path:       path,
+			ID:         dgst.String(),
+			TagSymlink: tag,
+			Type:       v1alpha2.TypeGeneric,
+		}
+		for _, layer := range layers {
+			layerDigest, err := layer.Digest()
+			require.NoError(t, err)
+			a.LayerDigests = append(a.LayerDigests, layerDigest.String())
+		}
+		a.LayerDigests = append(a.LayerDigests, cfgName.String())
+		return a
+	}
+
+	type spec struct {
+		name        string
+		blobContent bool
+	}
+	cases := []spec{
+		{
+			name: "Valid/Exists",
+		},
+		{
+			name:        "Valid/BlobContent",
+			blobContent: true,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			server := httptest.NewServer(registry.New())
+			t.Cleanup(server.Close)
+			u, err := url.Parse(server.URL)
+			require.NoError(t, err)
+			clients := insecureClients(t, u.Host)
+
+			ubi := newImage("ubi")
+			require.NoError(t, crane.Push(ubi, fmt.Sprintf("%s/mirror/ubi8/ubi:latest", u.Host), crane.Insecure))
+			meta := v1alpha2.NewMetadata()
+			meta.Uid = uuid.New()
+			meta.PastMirror = v1alpha2.PastMirror{
+				Sequence:     1,
+				Associations: []v1alpha2.Association{assoc(ubi, "ubi8/ubi", "latest")},
+			}
+			backend, err := storage.NewRegistryBackend(&v1alpha2.RegistryConfig{
+				ImageURL: fmt.Sprintf("%s/mirror/oc-mirror:%s", u.Host, meta.Uid),
+				SkipTLS:  true,
+			}, t.TempDir(), clients)
+			require.NoError(t, err)
+			require.NoError(t, backend.WriteMetadata(context.Background(), &meta, config.MetadataBasePath))
+
+			streams, _, out, _ := genericclioptions.NewTestIOStreams()
+			mo := mirror.NewMirrorOptions(mirror.WithClients(clients))
+			mo.ToMirror = u.Host
+			mo.UserNamespace = "mirror"
+			mo.DestPlainHTTP = true
+			mo.InsecureRegistries = []string{u.Host}
+			o := &VerifyOptions{
+				RootOptions:       &cli.RootOptions{IOStreams: streams},
+				Mirror:            mo,
+				VerifyBlobContent: c.blobContent,
+				Output:            verifyJSONOutput,
+			}
+			require.NoError(t, o.Validate())
+			require.NoError(t, o.Run(context.Background()))
+
+			var report verifyReport
+			require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+			require.True(t, report.Passed)
+			require.Equal(t, c.blobContent, report.VerifyBlobContent)
+			require.Equal(t, []verifiedMetadata{{UID: meta.Uid.String(), Sequence: 1}}, report.Metadata)
+			require.Len(t, report.Images, 1)
+			require.Equal(t, 1, report.Images[0].Manifests)
+			require.Equal(t, 2, report.Images[0].Blobs)
+			require.Empty(t, report.Images[0].Failures)
+		})
+	}
+}
+
+func TestVerifyImages(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	clients := insecureClients(t, u.Host)
+
+	push := func(content, repoTag string) (string, string) {
+		layer, err := crane.Layer(map[string][]byte{"file": []byte(content)})
+		require.NoError(t, err)
+		img, err := mutate.AppendLayers(empty.Image, layer)
+		require.NoError(t, err)
+		require.NoError(t, crane.Push(img, fmt.Sprintf("%s/mirror/%s", u.Host, repoTag), crane.Insecure))
+		dgst, err := img.Digest()
+		require.NoError(t, err)
+		layerDigest, err := layer.Digest()
+		require.NoError(t, err)
+		return dgst.String(), layerDigest.String()
+	}
+	ubi, ubiLayer := push("ubi", "ubi8/ubi:latest")
+	other, _ := push("other", "ubi8/ubi:other")
+	const missing = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+
+	mo := mirror.NewMirrorOptions(mirror.WithClients(clients))
+	mo.ToMirror = u.Host
+	mo.UserNamespace = "mirror"
+	mo.DestPlainHTTP = true
+	o := &VerifyOptions{RootOptions: &cli.RootOptions{}, Mirror: mo}
+	images, err := o.verifyImages(context.Background(), []v1alpha2.Association{
+		{Name: "ubi8/ubi:latest", Path: "ubi8/ubi", ID: ubi, TagSymlink: "latest", LayerDigests: []string{ubiLayer}},
+		{Name: "ubi8/ubi:other", Path: "ubi8/ubi", ID: ubi, TagSymlink: "other", LayerDigests: []string{ubiLayer, missing}},
+		{Name: "ubi8/ubi-minimal:latest", Path: "ubi8/ubi-minimal", ID: other, TagSymlink: "latest", LayerDigests: []string{ubiLayer}},
+	})
+	require.NoError(t, err)
+	require.Len(t, images, 3)
+
+	// Nothing was published to the repository, the test
+	// registry serves the blobs of every repository
+	require.Equal(t, "ubi8/ubi-minimal:latest", images[0].Name)
+	require.Len(t, images[0].Failures, 2)
+	require.Contains(t, images[0].Failures[0], "tag latest")
+	require.Contains(t, images[0].Failures[1], "manifest "+other)
+
+	require.Equal(t, "ubi8/ubi:latest", images[1].Name)
+	require.Empty(t, images[1].Failures)
+
+	require.Equal(t, "ubi8/ubi:other", images[2].Name)
+	require.Len(t, images[2].Failures, 2)
+	require.Contains(t, images[2].Failures[0], "tag other points to "+other)
+	require.Contains(t, images[2].Failures[1], "blob "+missing)
+}
+
+// insecureClients returns registry clients with the registry marked insecure
+func insecureClients(t *testing.T, host string) *image.Clients {
+	clients := image.NewClients()
+	require.NoError(t, clients.SetRegistries([]v1alpha2.Registry{{Host: host, Insecure: true}}))
+	return clients
+}
+
+func TestVerifyValidate(t *testing.T) {
+	type spec struct {
+		name     string
+		toMirror string
+		output   string
+		expError string
+	}
+	cases := []spec{
+		{
+			name:     "Valid/Table",
+			toMirror: "registry.example.com",
+			output:   verifyTableOutput,
+		},
+		{
+			name:     "Invalid/NoRegistry",
+			output:   verifyJSONOutput,
+			expError: "destination must be a registry reference",
+		},
+		{
+			name:     "Invalid/Output",
+			toMirror: "registry.example.com",
+			output:   "yaml",
+			expError: `output format "yaml" is not supported`,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			o := &VerifyOptions{Mirror: &mirror.MirrorOptions{ToMirror: c.toMirror}, Output: c.output}
+			err := o.Validate()
+			if c.expError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -1,35 +1,76 @@
 package cli
 
 import (
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"golang.org/x/crypto/ssh/terminal"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	"github.com/openshift/oc-mirror/pkg/httplog"
+	"github.com/openshift/oc-mirror/pkg/redact"
+)
+
+// Log formats
+const (
+	TextLogFormat = "text"
+	JSONLogFormat = "json"
 )
 
 type RootOptions struct {
 	genericclioptions.IOStreams
 
-	Dir      string
-	LogLevel string
+	Dir       string
+	LogLevel  string
+	LogFormat string
+	// Syslog is the syslog address to duplicate logs to
+	Syslog string
+	// SyslogTag is the tag of the syslog messages
+	SyslogTag string
+	// PprofAddr is the address to serve pprof on
+	PprofAddr string
+	// CPUProfile is the file to write the CPU profile of the run to
+	CPUProfile string
+	// MemProfile is the file to write the heap profile to at the end of the run
+	MemProfile string
 
 	logfileCleanup func()
+	syslogHook     *syslogHook
+	profiler       *profiler
+	// redactWriters redact the output of the streams
+	redactWriters []*redact.Writer
 }
 
 func (o *RootOptions) BindFlags(fs *pflag.FlagSet) {
 	fs.StringVarP(&o.Dir, "dir", "d", "oc-mirror-workspace", "Assets directory")
-	fs.StringVar(&o.LogLevel, "log-level", "info", "Log level (e.g. \"debug | info | warn | error\")")
-	if err := fs.MarkHidden("dir"); err != nil {
-		logrus.Panic(err.Error())
+	fs.StringVar(&o.LogLevel, "log-level", "info", "Log level (e.g. \"trace | debug | info | warn | error\"). "+
+		"The trace level also logs registry requests with their credentials redacted")
+	fs.StringVar(&o.LogFormat, "log-format", TextLogFormat, "Log format (e.g. \"text | json\")")
+	fs.StringVar(&o.Syslog, "syslog", o.Syslog, "Duplicate logs at the log level to syslog: \"local\" for the local "+
+		"syslog daemon or systemd journal, or udp://host:port, tcp://host:port, unix:///path, or unixgram:///path")
+	fs.StringVar(&o.SyslogTag, "syslog-tag", "oc-mirror", "Tag of the messages written to syslog")
+	fs.StringVar(&o.PprofAddr, "pprof-addr", o.PprofAddr, "Serve pprof at /debug/pprof/ on this address during the run (e.g. localhost:6060)")
+	fs.StringVar(&o.CPUProfile, "cpuprofile", o.CPUProfile, "Write a CPU profile of the run to this file")
+	fs.StringVar(&o.MemProfile, "memprofile", o.MemProfile, "Write a heap profile to this file when the run completes")
+	for _, name := range []string{"dir", "pprof-addr", "cpuprofile", "memprofile"} {
+		if err := fs.MarkHidden(name); err != nil {
+			logrus.Panic(err.Error())
+		}
 	}
 }
 
 func (o *RootOptions) LogfilePreRun(cmd *cobra.Command, _ []string) {
+	if o.LogFormat != TextLogFormat && o.LogFormat != JSONLogFormat && o.LogFormat != "" {
+		logrus.Fatalf("parse root options log-format: unsupported log format %q", o.LogFormat)
+	}
+
 	logrus.SetOutput(ioutil.Discard)
 	logrus.SetLevel(logrus.TraceLevel)
 
@@ -37,32 +78,95 @@ func (o *RootOptions) LogfilePreRun(cmd *cobra.Command, _ []string) {
 	if err != nil {
 		logrus.Fatalf("parse root options log-level: %v", err)
 	}
+	// The logger always logs at the trace level for the log file,
+	// so only log registry requests when trace is requested
+	httplog.SetEnabled(level == logrus.TraceLevel)
+
+	// Secrets are redacted before any other hook writes the entry
+	logrus.AddHook(redactHook{})
+
+	switch o.LogFormat {
+	case TextLogFormat, "":
+		logrus.AddHook(newFileHookWithNewlineTruncate(os.Stderr, level, &logrus.TextFormatter{
+			// Setting ForceColors is necessary because logrus.TextFormatter determines
+			// whether or not to enable colors by looking at the output of the logger.
+			// In this case, the output is ioutil.Discard, which is not a terminal.
+			// Overriding it here allows the same check to be done, but against the
+			// hook's output instead of the logger's output.
+			ForceColors:            terminal.IsTerminal(int(os.Stderr.Fd())),
+			DisableTimestamp:       true,
+			DisableLevelTruncation: true,
+			DisableQuote:           true,
+		}))
+		// Print command errors redacted, after the buffered output
+		kcmdutil.BehaviorOnFatal(func(msg string, code int) {
+			o.LogfilePostRun(cmd, nil)
+			if len(msg) != 0 {
+				if !strings.HasSuffix(msg, "\n") {
+					msg += "\n"
+				}
+				fmt.Fprint(os.Stderr, redact.String(msg))
+			}
+			os.Exit(code)
+		})
+	case JSONLogFormat:
+		logrus.AddHook(newFileHook(os.Stderr, level, newJSONFormatter()))
+		// Log command errors as structured entries
+		kcmdutil.BehaviorOnFatal(func(msg string, code int) {
+			logrus.Error(strings.TrimSuffix(strings.TrimPrefix(msg, "error: "), "\n"))
+			o.LogfilePostRun(cmd, nil)
+			os.Exit(code)
+		})
+	}
 
-	logrus.AddHook(newFileHookWithNewlineTruncate(os.Stderr, level, &logrus.TextFormatter{
-		// Setting ForceColors is necessary because logrus.TextFormatter determines
-		// whether or not to enable colors by looking at the output of the logger.
-		// In this case, the output is ioutil.Discard, which is not a terminal.
-		// Overriding it here allows the same check to be done, but against the
-		// hook's output instead of the logger's output.
-		ForceColors:            terminal.IsTerminal(int(os.Stderr.Fd())),
-		DisableTimestamp:       true,
-		DisableLevelTruncation: true,
-		DisableQuote:           true,
-	}))
-
-	cleanup, logfile := setupFileHook(".")
+	cleanup, logfile := setupFileHook(".", o.LogFormat)
 	o.logfileCleanup = cleanup
 
-	// Add to root IOStream options
+	if o.Syslog != "" {
+		hook, err := newSyslogHook(o.Syslog, o.SyslogTag, level, o.LogFormat)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		o.syslogHook = hook
+		logrus.AddHook(hook)
+	}
+
+	if err := o.startProfiling(); err != nil {
+		logrus.Fatal(err)
+	}
+
+	// Only structured log entries are written to
+	// the log file when the log format is JSON
+	out, errOut := o.IOStreams.Out, o.IOStreams.ErrOut
+	if o.LogFormat != JSONLogFormat {
+		out, errOut = io.MultiWriter(out, logfile), io.MultiWriter(errOut, logfile)
+	}
+
+	// Add to root IOStream options, redacting
+	// the output of the console and the log file
+	o.redactWriters = []*redact.Writer{redact.NewWriter(out), redact.NewWriter(errOut)}
 	o.IOStreams = genericclioptions.IOStreams{
 		In:     o.IOStreams.In,
-		Out:    io.MultiWriter(o.IOStreams.Out, logfile),
-		ErrOut: io.MultiWriter(o.IOStreams.ErrOut, logfile),
+		Out:    o.redactWriters[0],
+		ErrOut: o.redactWriters[1],
 	}
 }
 
 func (o *RootOptions) LogfilePostRun(*cobra.Command, []string) {
+	o.stopProfiling()
+	for _, w := range o.redactWriters {
+		if err := w.Flush(); err != nil {
+			logrus.Error(err)
+		}
+	}
+	o.redactWriters = nil
 	if o.logfileCleanup != nil {
 		o.logfileCleanup()
 	}
+	if o.syslogHook != nil {
+		if err := o.syslogHook.Close(); err != nil {
+			logrus.Error(err)
+		}
+		o.syslogHook = nil
+	}
 }
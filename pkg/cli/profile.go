@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+// profiler holds the profiles of the run requested with
+// the hidden profiling flags for performance triage
+type profiler struct {
+	cpuFile *os.File
+	memPath string
+	server  *http.Server
+	addr    string
+}
+
+// startProfiling starts the CPU profile and the pprof server when requested
+func (o *RootOptions) startProfiling() error {
+	if o.CPUProfile == "" && o.MemProfile == "" && o.PprofAddr == "" {
+		return nil
+	}
+	p := &profiler{memPath: o.MemProfile}
+	if o.CPUProfile != "" {
+		f, err := os.Create(o.CPUProfile)
+		if err != nil {
+			return fmt.Errorf("error creating CPU profile: %v", err)
+		}
+		if err := runtimepprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return fmt.Errorf("error starting CPU profile: %v", err)
+		}
+		p.cpuFile = f
+	}
+	if o.PprofAddr != "" {
+		listener, err := net.Listen("tcp", o.PprofAddr)
+		if err != nil {
+			p.stop()
+			return fmt.Errorf("error listening for pprof on %s: %v", o.PprofAddr, err)
+		}
+		// The handlers are registered on their own mux so they
+		// are not served by other servers using the default mux
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		p.server = &http.Server{Handler: mux}
+		p.addr = listener.Addr().String()
+		go func() {
+			if err := p.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logrus.Errorf("error serving pprof: %v", err)
+			}
+		}()
+		logrus.Infof("Serving pprof at http://%s/debug/pprof/", p.addr)
+	}
+	o.profiler = p
+
+	// Commands exit on errors without running their post run,
+	// so profiles are also written before exiting on errors
+	if o.LogFormat != JSONLogFormat {
+		kcmdutil.BehaviorOnFatal(func(msg string, code int) {
+			o.stopProfiling()
+			if len(msg) > 0 {
+				if !strings.HasSuffix(msg, "\n") {
+					msg += "\n"
+				}
+				fmt.Fprint(os.Stderr, msg)
+			}
+			os.Exit(code)
+		})
+	}
+	return nil
+}
+
+// stopProfiling writes the requested profiles and stops the pprof server
+func (o *RootOptions) stopProfiling() {
+	if o.profiler == nil {
+		return
+	}
+	o.profiler.stop()
+	o.profiler = nil
+}
+
+func (p *profiler) stop() {
+	if p.cpuFile != nil {
+		runtimepprof.StopCPUProfile()
+		if err := p.cpuFile.Close(); err != nil {
+			logrus.Errorf("error writing CPU profile: %v", err)
+		}
+	}
+	if p.memPath != "" {
+		if err := writeHeapProfile(p.memPath); err != nil {
+			logrus.Errorf("error writing memory profile: %v", err)
+		}
+	}
+	if p.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := p.server.Shutdown(ctx); err != nil {
+			logrus.Errorf("error stopping pprof server: %v", err)
+		}
+	}
+}
+
+// writeHeapProfile writes the heap profile after a
+// garbage collection so it reflects the live objects
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	runtime.GC()
+	if err := runtimepprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfiling(t *testing.T) {
+	dir := t.TempDir()
+	o := &RootOptions{
+		LogFormat:  JSONLogFormat,
+		PprofAddr:  "127.0.0.1:0",
+		CPUProfile: filepath.Join(dir, "cpu.pprof"),
+		MemProfile: filepath.Join(dir, "mem.pprof"),
+	}
+	require.NoError(t, o.startProfiling())
+	require.NotNil(t, o.profiler)
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/debug/pprof/cmdline", o.profiler.addr))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	o.stopProfiling()
+	require.Nil(t, o.profiler)
+	for _, name := range []string{"cpu.pprof", "mem.pprof"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.NotZero(t, info.Size())
+	}
+
+	// Profiling is off unless requested
+	o = &RootOptions{}
+	require.NoError(t, o.startProfiling())
+	require.Nil(t, o.profiler)
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/oc-mirror/pkg/redact"
+)
+
+// redactHook scrubs secrets from the message and fields of log entries.
+// It must be the first hook so the entries written by the other hooks are redacted.
+type redactHook struct{}
+
+func (redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (redactHook) Fire(entry *logrus.Entry) error {
+	entry.Message = redact.String(entry.Message)
+	for k, v := range entry.Data {
+		switch value := v.(type) {
+		case string:
+			entry.Data[k] = redact.String(value)
+		case error:
+			entry.Data[k] = redact.String(value.Error())
+		case fmt.Stringer:
+			entry.Data[k] = redact.String(value.String())
+		}
+	}
+	return nil
+}
+
+// klogWriter logs the output of klog, which the Kubernetes and
+// oc libraries log with, as debug entries so it is redacted
+type klogWriter struct{}
+
+// NewKlogWriter returns the writer klog output is redirected to
+func NewKlogWriter() io.Writer {
+	return klogWriter{}
+}
+
+// Write logs each line, klog writing whole entries at once
+func (klogWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		logrus.Debug(line)
+	}
+	return len(p), nil
+}
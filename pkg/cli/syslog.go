@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LocalSyslog is the syslog address of the local syslog
+// daemon or systemd journal
+const LocalSyslog = "local"
+
+// syslogWriter writes messages at a syslog priority
+type syslogWriter interface {
+	Crit(m string) error
+	Err(m string) error
+	Warning(m string) error
+	Info(m string) error
+	Debug(m string) error
+	Close() error
+}
+
+// syslogHook duplicates log entries to syslog
+type syslogHook struct {
+	writer    syslogWriter
+	formatter logrus.Formatter
+	level     logrus.Level
+}
+
+// parseSyslogAddress returns the network and address of a syslog address
+// of the form local, udp://host:port, tcp://host:port, unix:///path, or unixgram:///path
+func parseSyslogAddress(addr string) (network, raddr string, err error) {
+	if addr == LocalSyslog {
+		return "", "", nil
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid syslog address %q: %v", addr, err)
+	}
+	switch u.Scheme {
+	case "udp", "tcp":
+		if u.Host == "" {
+			return "", "", fmt.Errorf("syslog address %q must include a host and port", addr)
+		}
+		return u.Scheme, u.Host, nil
+	case "unix", "unixgram":
+		if u.Path == "" {
+			return "", "", fmt.Errorf("syslog address %q must include a socket path", addr)
+		}
+		return u.Scheme, u.Path, nil
+	default:
+		return "", "", fmt.Errorf("syslog address %q is not supported: "+
+			"must be %s, udp://host:port, tcp://host:port, unix:///path, or unixgram:///path", addr, LocalSyslog)
+	}
+}
+
+// newSyslogHook connects to the syslog address and returns a hook
+// writing entries up to the level with the tag
+func newSyslogHook(addr, tag string, level logrus.Level, format string) (*syslogHook, error) {
+	network, raddr, err := parseSyslogAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := dialSyslog(network, raddr, tag)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to syslog: %v", err)
+	}
+	var formatter logrus.Formatter = syslogFormatter{}
+	if format == JSONLogFormat {
+		formatter = &logrus.JSONFormatter{DisableTimestamp: true}
+	}
+	return &syslogHook{writer: writer, formatter: formatter, level: level}, nil
+}
+
+func (h *syslogHook) Levels() []logrus.Level {
+	var levels []logrus.Level
+	for _, level := range logrus.AllLevels {
+		if level <= h.level {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
+
+// Fire writes the entry at the syslog priority of its level
+func (h *syslogHook) Fire(entry *logrus.Entry) error {
+	line, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	msg := strings.TrimSuffix(string(line), "\n")
+	switch entry.Level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return h.writer.Crit(msg)
+	case logrus.ErrorLevel:
+		return h.writer.Err(msg)
+	case logrus.WarnLevel:
+		return h.writer.Warning(msg)
+	case logrus.InfoLevel:
+		return h.writer.Info(msg)
+	default:
+		return h.writer.Debug(msg)
+	}
+}
+
+// Close closes the connection to syslog
+func (h *syslogHook) Close() error {
+	return h.writer.Close()
+}
+
+// syslogFormatter formats entries as the message followed by
+// the sorted fields, leaving the timestamp and priority to syslog
+type syslogFormatter struct{}
+
+func (syslogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString(entry.Message)
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%v", key, entry.Data[key])
+	}
+	b.WriteString("\n")
+	return []byte(b.String()), nil
+}
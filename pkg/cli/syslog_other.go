@@ -0,0 +1,14 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package cli
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// dialSyslog returns an error because syslog is not available on this platform
+func dialSyslog(network, raddr, tag string) (syslogWriter, error) {
+	return nil, fmt.Errorf("syslog is not supported on %s", runtime.GOOS)
+}
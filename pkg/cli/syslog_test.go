@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSyslog struct {
+	messages []string
+}
+
+func (f *fakeSyslog) write(priority, m string) error {
+	f.messages = append(f.messages, priority+": "+m)
+	return nil
+}
+
+func (f *fakeSyslog) Crit(m string) error    { return f.write("crit", m) }
+func (f *fakeSyslog) Err(m string) error     { return f.write("err", m) }
+func (f *fakeSyslog) Warning(m string) error { return f.write("warning", m) }
+func (f *fakeSyslog) Info(m string) error    { return f.write("info", m) }
+func (f *fakeSyslog) Debug(m string) error   { return f.write("debug", m) }
+func (f *fakeSyslog) Close() error           { return nil }
+
+func TestParseSyslogAddress(t *testing.T) {
+	type spec struct {
+		name       string
+		addr       string
+		expNetwork string
+		expAddr    string
+		expError   string
+	}
+	cases := []spec{
+		{name: "Valid/Local", addr: "local"},
+		{name: "Valid/UDP", addr: "udp://logs.example.com:514", expNetwork: "udp", expAddr: "logs.example.com:514"},
+		{name: "Valid/TCP", addr: "tcp://[fd00::1]:601", expNetwork: "tcp", expAddr: "[fd00::1]:601"},
+		{name: "Valid/Unix", addr: "unix:///run/systemd/journal/syslog", expNetwork: "unix", expAddr: "/run/systemd/journal/syslog"},
+		{
+			name:     "Invalid/NoHost",
+			addr:     "udp://",
+			expError: `syslog address "udp://" must include a host and port`,
+		},
+		{
+			name:     "Invalid/Scheme",
+			addr:     "journald",
+			expError: `syslog address "journald" is not supported: must be local, udp://host:port, tcp://host:port, unix:///path, or unixgram:///path`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			network, addr, err := parseSyslogAddress(c.addr)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expNetwork, network)
+			require.Equal(t, c.expAddr, addr)
+		})
+	}
+}
+
+func TestSyslogHook(t *testing.T) {
+	writer := &fakeSyslog{}
+	logger := logrus.New()
+	logger.SetLevel(logrus.TraceLevel)
+	logger.AddHook(&syslogHook{writer: writer, formatter: syslogFormatter{}, level: logrus.DebugLevel})
+
+	logger.Trace("not written")
+	logger.Debug("debug")
+	logger.WithField("image", "ubi8/ubi").WithField("attempt", 2).Info("mirroring")
+	logger.Warn("warning")
+	logger.Error("error")
+
+	require.Equal(t, []string{
+		"debug: debug",
+		"info: mirroring attempt=2 image=ubi8/ubi",
+		"warning: warning",
+		"err: error",
+	}, writer.messages)
+}
@@ -0,0 +1,12 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package cli
+
+import "log/syslog"
+
+// dialSyslog connects to the syslog daemon at the address,
+// or to the local syslog daemon if network is empty
+func dialSyslog(network, raddr, tag string) (syslogWriter, error) {
+	return syslog.Dial(network, raddr, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver/v4"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+// Channel alias expressions that can follow the channel name
+// (e.g. stable-4.12:latest)
+const (
+	channelAliasSeparator = ":"
+	// channelAliasLatest mirrors the latest release in the channel
+	channelAliasLatest = "latest"
+	// channelAliasHeadsOnly mirrors the channel head
+	channelAliasHeadsOnly = "heads-only"
+	// channelAliasFull mirrors every release in the channel
+	channelAliasFull = "full"
+	// channelAliasRangeSeparator separates the minimum
+	// and maximum version of a version range
+	channelAliasRangeSeparator = "-"
+)
+
+// expandChannelAliases expands the shorthand channel expressions
+// <channel>:latest, <channel>:heads-only, <channel>:full,
+// <channel>:<version> and <channel>:<minVersion>-<maxVersion>
+// into the equivalent release channel configuration.
+func expandChannelAliases(cfg *v1alpha2.ImageSetConfiguration) error {
+	for i, ch := range cfg.Mirror.Platform.Channels {
+		expanded, err := expandChannelAlias(ch)
+		if err != nil {
+			return fmt.Errorf("invalid release channel %q: %v", ch.Name, err)
+		}
+		cfg.Mirror.Platform.Channels[i] = expanded
+	}
+	return nil
+}
+
+func expandChannelAlias(ch v1alpha2.ReleaseChannel) (v1alpha2.ReleaseChannel, error) {
+	split := strings.SplitN(ch.Name, channelAliasSeparator, 2)
+	if len(split) != 2 {
+		return ch, nil
+	}
+	name, alias := split[0], split[1]
+	if name == "" || alias == "" {
+		return ch, fmt.Errorf("channel alias must be in the form <channel>:<expression>")
+	}
+	// Mixing aliases with explicit fields makes the intent ambiguous
+	if ch.MinVersion != "" || ch.MaxVersion != "" || ch.Full {
+		return ch, fmt.Errorf("channel alias %q cannot be used with minVersion, maxVersion, or full", alias)
+	}
+	ch.Name = name
+
+	switch alias {
+	case channelAliasLatest, channelAliasHeadsOnly:
+		// Heads-only with unset versions resolves to the latest release
+		return ch, nil
+	case channelAliasFull:
+		ch.Full = true
+		return ch, nil
+	}
+
+	minVersion, maxVersion := splitVersionRange(alias)
+	if minVersion == alias {
+		if _, err := semver.Parse(alias); err != nil {
+			return ch, fmt.Errorf("unknown channel alias %q", alias)
+		}
+	}
+
+	min, err := semver.Parse(minVersion)
+	if err != nil {
+		return ch, fmt.Errorf("invalid minimum version %q: %v", minVersion, err)
+	}
+	max, err := semver.Parse(maxVersion)
+	if err != nil {
+		return ch, fmt.Errorf("invalid maximum version %q: %v", maxVersion, err)
+	}
+	if min.GT(max) {
+		return ch, fmt.Errorf("minimum version %s is greater than maximum version %s", min, max)
+	}
+	ch.MinVersion = min.String()
+	ch.MaxVersion = max.String()
+	return ch, nil
+}
+
+// splitVersionRange splits a <minVersion>-<maxVersion> expression.
+// Versions may contain pre-release identifiers, so the expression is
+// split on the first separator followed by a valid version. A single
+// version is returned as both the minimum and the maximum.
+func splitVersionRange(expr string) (string, string) {
+	for i := range expr {
+		if !strings.HasPrefix(expr[i:], channelAliasRangeSeparator) {
+			continue
+		}
+		max := expr[i+len(channelAliasRangeSeparator):]
+		if _, err := semver.Parse(max); err == nil {
+			return expr[:i], max
+		}
+	}
+	return expr, expr
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+func TestExpandChannelAlias(t *testing.T) {
+	type spec struct {
+		name     string
+		channel  v1alpha2.ReleaseChannel
+		exp      v1alpha2.ReleaseChannel
+		expError string
+	}
+
+	cases := []spec{
+		{
+			name:    "Valid/NoAlias",
+			channel: v1alpha2.ReleaseChannel{Name: "stable-4.12", MinVersion: "4.12.1"},
+			exp:     v1alpha2.ReleaseChannel{Name: "stable-4.12", MinVersion: "4.12.1"},
+		},
+		{
+			name:    "Valid/Latest",
+			channel: v1alpha2.ReleaseChannel{Name: "stable-4.12:latest"},
+			exp:     v1alpha2.ReleaseChannel{Name: "stable-4.12"},
+		},
+		{
+			name:    "Valid/HeadsOnly",
+			channel: v1alpha2.ReleaseChannel{Name: "eus-4.12:heads-only"},
+			exp:     v1alpha2.ReleaseChannel{Name: "eus-4.12"},
+		},
+		{
+			name:    "Valid/Full",
+			channel: v1alpha2.ReleaseChannel{Name: "stable-4.12:full"},
+			exp:     v1alpha2.ReleaseChannel{Name: "stable-4.12", Full: true},
+		},
+		{
+			name:    "Valid/Range",
+			channel: v1alpha2.ReleaseChannel{Name: "stable-4.12:4.12.10-4.12.20"},
+			exp:     v1alpha2.ReleaseChannel{Name: "stable-4.12", MinVersion: "4.12.10", MaxVersion: "4.12.20"},
+		},
+		{
+			name:    "Valid/PreReleaseRange",
+			channel: v1alpha2.ReleaseChannel{Name: "candidate-4.12:4.12.0-rc.1-4.12.3"},
+			exp:     v1alpha2.ReleaseChannel{Name: "candidate-4.12", MinVersion: "4.12.0-rc.1", MaxVersion: "4.12.3"},
+		},
+		{
+			name:    "Valid/SingleVersion",
+			channel: v1alpha2.ReleaseChannel{Name: "stable-4.12:4.12.10"},
+			exp:     v1alpha2.ReleaseChannel{Name: "stable-4.12", MinVersion: "4.12.10", MaxVersion: "4.12.10"},
+		},
+		{
+			name:     "Invalid/UnknownAlias",
+			channel:  v1alpha2.ReleaseChannel{Name: "stable-4.12:newest"},
+			expError: `unknown channel alias "newest"`,
+		},
+		{
+			name:     "Invalid/ReversedRange",
+			channel:  v1alpha2.ReleaseChannel{Name: "stable-4.12:4.12.20-4.12.10"},
+			expError: "minimum version 4.12.20 is greater than maximum version 4.12.10",
+		},
+		{
+			name:     "Invalid/AliasWithVersions",
+			channel:  v1alpha2.ReleaseChannel{Name: "stable-4.12:latest", MaxVersion: "4.12.20"},
+			expError: `channel alias "latest" cannot be used with minVersion, maxVersion, or full`,
+		},
+		{
+			name:     "Invalid/EmptyAlias",
+			channel:  v1alpha2.ReleaseChannel{Name: "stable-4.12:"},
+			expError: "channel alias must be in the form <channel>:<expression>",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ch, err := expandChannelAlias(c.channel)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, ch)
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+// deprecatedAPIVersion is the API version of the
+// configuration replaced by v1alpha2
+const deprecatedAPIVersion = "mirror.openshift.io/v1alpha1"
+
+// LintSeverity is the severity of a lint finding
+type LintSeverity string
+
+const (
+	// LintError findings make the configuration unusable
+	// or make parts of it match nothing.
+	LintError LintSeverity = "error"
+	// LintWarning findings are deprecated or
+	// ignored parts of the configuration.
+	LintWarning LintSeverity = "warning"
+)
+
+// LintFinding is a problem found in an imageset configuration
+type LintFinding struct {
+	Severity LintSeverity `json:"severity"`
+	// Path is the location of the problem in the
+	// configuration (e.g. mirror.platform.channels[0]).
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+	// Suggestion is how to fix the problem.
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+type linter struct {
+	findings []LintFinding
+}
+
+func (l *linter) add(severity LintSeverity, path, message, suggestion string) {
+	l.findings = append(l.findings, LintFinding{
+		Severity:   severity,
+		Path:       path,
+		Message:    message,
+		Suggestion: suggestion,
+	})
+}
+
+// Lint checks an imageset configuration for deprecated fields, unused
+// sections, and filters that match nothing, in addition to the checks of
+// Validate. Deprecated fields are reported with their newer equivalent, and
+// the rest of the configuration is checked as if they were replaced.
+func Lint(data []byte) []LintFinding {
+	l := &linter{}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		l.add(LintError, "", fmt.Sprintf("invalid YAML: %v", err), "")
+		return l.findings
+	}
+	if kind, _ := raw["kind"].(string); kind != v1alpha2.ImageSetConfigurationKind {
+		l.add(LintError, "kind", fmt.Sprintf("kind %q is not %s", kind, v1alpha2.ImageSetConfigurationKind),
+			fmt.Sprintf("set kind to %s", v1alpha2.ImageSetConfigurationKind))
+		return l.findings
+	}
+
+	l.lintDeprecated(raw)
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		l.add(LintError, "", err.Error(), "")
+		return l.findings
+	}
+	cfg, err := LoadConfig(data)
+	if err != nil {
+		l.add(LintError, "", err.Error(), "")
+		return l.findings
+	}
+	if err := Validate(&cfg); err != nil {
+		var agg utilerrors.Aggregate
+		if errors.As(err, &agg) {
+			for _, e := range agg.Errors() {
+				l.add(LintError, "", e.Error(), "")
+			}
+		} else {
+			l.add(LintError, "", err.Error(), "")
+		}
+	}
+
+	l.lintUnused(cfg)
+	l.lintChannels(cfg.Mirror.Platform.Channels)
+	l.lintUpgradePaths(cfg.Mirror.Platform.UpgradePaths)
+	return l.findings
+}
+
+// lintDeprecated reports the fields of the v1alpha1 configuration
+// and replaces them with their v1alpha2 equivalent
+func (l *linter) lintDeprecated(raw map[string]interface{}) {
+	if apiVersion, _ := raw["apiVersion"].(string); apiVersion == deprecatedAPIVersion {
+		l.add(LintWarning, "apiVersion", fmt.Sprintf("%s is deprecated", apiVersion),
+			fmt.Sprintf("set apiVersion to %s", v1alpha2.GroupVersion))
+		raw["apiVersion"] = v1alpha2.GroupVersion.String()
+	}
+
+	mirror, _ := raw["mirror"].(map[string]interface{})
+	if mirror == nil {
+		return
+	}
+
+	platformPath := "mirror.platform"
+	if ocp, found := mirror["ocp"]; found {
+		platformPath = "mirror.ocp"
+		l.add(LintWarning, platformPath, "ocp is deprecated", "rename mirror.ocp to mirror.platform")
+		if _, found := mirror["platform"]; !found {
+			mirror["platform"] = ocp
+		}
+		delete(mirror, "ocp")
+	}
+	platform, _ := mirror["platform"].(map[string]interface{})
+	channels, _ := platform["channels"].([]interface{})
+	for i, c := range channels {
+		ch, _ := c.(map[string]interface{})
+		versions, found := ch["versions"]
+		if !found {
+			continue
+		}
+		path := fmt.Sprintf("%s.channels[%d].versions", platformPath, i)
+		suggestion := "replace versions with minVersion and maxVersion"
+		if min, max, ok := versionBounds(versions); ok {
+			suggestion = fmt.Sprintf("replace versions with minVersion: %s and maxVersion: %s", min, max)
+			if _, found := ch["minVersion"]; !found {
+				ch["minVersion"] = min
+			}
+			if _, found := ch["maxVersion"]; !found {
+				ch["maxVersion"] = max
+			}
+		}
+		l.add(LintWarning, path, "versions is deprecated", suggestion)
+		delete(ch, "versions")
+	}
+
+	operators, _ := mirror["operators"].([]interface{})
+	for i, o := range operators {
+		op, _ := o.(map[string]interface{})
+		v, found := op["headsOnly"]
+		if !found {
+			continue
+		}
+		path := fmt.Sprintf("mirror.operators[%d].headsOnly", i)
+		if headsOnly, _ := v.(bool); headsOnly {
+			l.add(LintWarning, path, "headsOnly is deprecated",
+				"remove headsOnly, only the channel heads are mirrored unless full is true")
+		} else {
+			l.add(LintWarning, path, "headsOnly is deprecated", "replace headsOnly: false with full: true")
+			if _, found := op["full"]; !found {
+				op["full"] = true
+			}
+		}
+		delete(op, "headsOnly")
+	}
+
+	if samples, _ := mirror["samples"].([]interface{}); len(samples) != 0 {
+		l.add(LintWarning, "mirror.samples", "samples are not implemented and are ignored", "remove mirror.samples")
+	}
+}
+
+// versionBounds returns the lowest and highest of a list of versions
+func versionBounds(v interface{}) (min, max string, ok bool) {
+	list, _ := v.([]interface{})
+	var versions []semver.Version
+	for _, item := range list {
+		s, _ := item.(string)
+		version, err := semver.Parse(s)
+		if err != nil {
+			return "", "", false
+		}
+		versions = append(versions, version)
+	}
+	if len(versions) == 0 {
+		return "", "", false
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].LT(versions[j]) })
+	return versions[0].String(), versions[len(versions)-1].String(), true
+}
+
+// lintUnused reports the sections of the configuration that have no effect
+func (l *linter) lintUnused(cfg v1alpha2.ImageSetConfiguration) {
+	mirror := cfg.Mirror
+	platform := mirror.Platform
+	hasReleases := len(platform.Channels) != 0 || len(platform.Releases) != 0 || len(platform.UpgradePaths) != 0
+	hasCoreOS := len(platform.CoreOS.OSImages) != 0 || len(platform.CoreOS.MachineConfigs) != 0
+	hasHelm := len(mirror.Helm.Repositories) != 0 || len(mirror.Helm.Local) != 0
+	if !hasReleases && !hasCoreOS && !hasHelm && len(mirror.Operators) == 0 && len(mirror.AdditionalImages) == 0 {
+		l.add(LintWarning, "mirror", "the configuration mirrors nothing",
+			"add platform releases, operators, additionalImages, or helm charts")
+	}
+
+	if !cfg.StorageConfig.IsSet() {
+		l.add(LintWarning, "storageConfig", "without storageConfig, the metadata of runs is not kept and every run mirrors all the images",
+			"set storageConfig.local.path or storageConfig.registry.imageURL")
+	}
+
+	if platform.Graph && len(platform.Channels) == 0 {
+		l.add(LintWarning, "mirror.platform.graph", "the graph image is only built for release channels",
+			"add release channels or remove graph")
+	}
+	if !platform.Graph {
+		if platform.GraphDataSource != "" {
+			l.add(LintWarning, "mirror.platform.graphDataSource", "graphDataSource is ignored without graph",
+				"set graph to true or remove graphDataSource")
+		}
+		if platform.GraphBaseImage != "" {
+			l.add(LintWarning, "mirror.platform.graphBaseImage", "graphBaseImage is ignored without graph",
+				"set graph to true or remove graphBaseImage")
+		}
+	}
+	if !hasReleases {
+		if len(platform.BootImages.Platforms) != 0 {
+			l.add(LintWarning, "mirror.platform.bootImages", "boot images are only downloaded for mirrored releases",
+				"add releases or remove bootImages")
+		}
+		if len(platform.Clients.OperatingSystems) != 0 {
+			l.add(LintWarning, "mirror.platform.clients", "clients are only downloaded for mirrored releases",
+				"add releases or remove clients")
+		}
+		if platform.Verification.Policy != "" || len(platform.Verification.PublicKeys) != 0 {
+			l.add(LintWarning, "mirror.platform.verification", "release verification only applies to mirrored releases",
+				"add releases or remove verification")
+		}
+	}
+
+	for i, repo := range mirror.Helm.Repositories {
+		if len(repo.Charts) == 0 {
+			l.add(LintWarning, fmt.Sprintf("mirror.helm.repos[%d]", i), fmt.Sprintf("repository %q has no charts", repo.Name),
+				"add charts or remove the repository")
+		}
+	}
+
+	seen := map[string]bool{}
+	for i, img := range mirror.AdditionalImages {
+		if seen[img.Name] {
+			l.add(LintWarning, fmt.Sprintf("mirror.additionalImages[%d]", i), fmt.Sprintf("image %q is listed more than once", img.Name),
+				"remove the duplicate")
+		}
+		seen[img.Name] = true
+	}
+}
+
+// lintChannels reports release channel filters matching no release
+func (l *linter) lintChannels(channels []v1alpha2.ReleaseChannel) {
+	for i, ch := range channels {
+		path := fmt.Sprintf("mirror.platform.channels[%d]", i)
+		min, minOK := l.channelVersion(path, ch.Name, "minVersion", ch.MinVersion)
+		max, maxOK := l.channelVersion(path, ch.Name, "maxVersion", ch.MaxVersion)
+		if !minOK || !maxOK {
+			continue
+		}
+		if min.GT(max) {
+			l.add(LintError, path, fmt.Sprintf("channel %q: minVersion %s is greater than maxVersion %s, no release matches", ch.Name, min, max),
+				"swap minVersion and maxVersion")
+		}
+		if ch.Full {
+			l.add(LintWarning, path+".full", fmt.Sprintf("channel %q: full is ignored when minVersion and maxVersion are set", ch.Name),
+				"remove full, or remove minVersion and maxVersion to mirror the whole channel")
+		}
+	}
+}
+
+// channelVersion parses a version bound of a release channel,
+// returning false if the bound is not set or not a version
+func (l *linter) channelVersion(path, channel, field, version string) (semver.Version, bool) {
+	if version == "" {
+		return semver.Version{}, false
+	}
+	v, err := semver.Parse(version)
+	if err != nil {
+		l.add(LintError, path+"."+field, fmt.Sprintf("channel %q: %s %q is not a version: %v", channel, field, version, err),
+			"set a release version such as 4.12.3")
+		return semver.Version{}, false
+	}
+	return v, true
+}
+
+// lintUpgradePaths reports upgrade paths ending before they start
+func (l *linter) lintUpgradePaths(paths []v1alpha2.UpgradePath) {
+	for i, p := range paths {
+		from, err := semver.ParseTolerant(p.From)
+		if err != nil {
+			continue
+		}
+		to, err := semver.ParseTolerant(p.To)
+		if err != nil {
+			continue
+		}
+		// A minor version ends at the latest release of the minor
+		if len(strings.Split(p.To, ".")) == 2 {
+			from.Patch, from.Pre, from.Build = 0, nil, nil
+		}
+		if from.GT(to) {
+			l.add(LintError, fmt.Sprintf("mirror.platform.upgradePaths[%d]", i),
+				fmt.Sprintf("upgrade path from %s to %s ends before it starts, no release matches", p.From, p.To),
+				"swap from and to")
+		}
+	}
+}
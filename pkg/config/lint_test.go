@@ -0,0 +1,181 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+
+	type spec struct {
+		name        string
+		config      string
+		expFindings []LintFinding
+	}
+
+	const header = `apiVersion: mirror.openshift.io/v1alpha2
+kind: ImageSetConfiguration
+storageConfig:
+  local:
+    path: /var/lib/oc-mirror
+`
+
+	cases := []spec{
+		{
+			name: "Valid/Clean",
+			config: header + `mirror:
+  platform:
+    channels:
+    - name: stable-4.12
+      minVersion: 4.12.1
+      maxVersion: 4.12.3
+  additionalImages:
+  - name: registry.redhat.io/ubi8/ubi:latest
+`,
+		},
+		{
+			name: "Valid/Deprecated",
+			config: `apiVersion: mirror.openshift.io/v1alpha1
+kind: ImageSetConfiguration
+storageConfig:
+  local:
+    path: /var/lib/oc-mirror
+mirror:
+  ocp:
+    channels:
+    - name: stable-4.12
+      versions:
+      - 4.12.3
+      - 4.12.1
+  operators:
+  - catalog: registry.redhat.io/redhat/redhat-operator-index:v4.12
+    headsOnly: false
+    packages:
+    - name: rhacs-operator
+  - catalog: registry.redhat.io/redhat/certified-operator-index:v4.12
+    headsOnly: true
+  samples:
+  - name: registry.redhat.io/ubi8/ubi:latest
+`,
+			expFindings: []LintFinding{
+				{Severity: LintWarning, Path: "apiVersion", Message: "mirror.openshift.io/v1alpha1 is deprecated",
+					Suggestion: "set apiVersion to mirror.openshift.io/v1alpha2"},
+				{Severity: LintWarning, Path: "mirror.ocp", Message: "ocp is deprecated",
+					Suggestion: "rename mirror.ocp to mirror.platform"},
+				{Severity: LintWarning, Path: "mirror.ocp.channels[0].versions", Message: "versions is deprecated",
+					Suggestion: "replace versions with minVersion: 4.12.1 and maxVersion: 4.12.3"},
+				{Severity: LintWarning, Path: "mirror.operators[0].headsOnly", Message: "headsOnly is deprecated",
+					Suggestion: "replace headsOnly: false with full: true"},
+				{Severity: LintWarning, Path: "mirror.operators[1].headsOnly", Message: "headsOnly is deprecated",
+					Suggestion: "remove headsOnly, only the channel heads are mirrored unless full is true"},
+				{Severity: LintWarning, Path: "mirror.samples", Message: "samples are not implemented and are ignored",
+					Suggestion: "remove mirror.samples"},
+			},
+		},
+		{
+			name: "Valid/Unused",
+			config: `apiVersion: mirror.openshift.io/v1alpha2
+kind: ImageSetConfiguration
+mirror:
+  platform:
+    graph: true
+    graphBaseImage: registry.access.redhat.com/ubi8/ubi:latest
+    clients:
+      operatingSystems:
+      - linux
+  helm:
+    repos:
+    - name: podinfo
+      url: https://stefanprodan.github.io/podinfo
+`,
+			expFindings: []LintFinding{
+				{Severity: LintWarning, Path: "storageConfig",
+					Message:    "without storageConfig, the metadata of runs is not kept and every run mirrors all the images",
+					Suggestion: "set storageConfig.local.path or storageConfig.registry.imageURL"},
+				{Severity: LintWarning, Path: "mirror.platform.graph", Message: "the graph image is only built for release channels",
+					Suggestion: "add release channels or remove graph"},
+				{Severity: LintWarning, Path: "mirror.platform.clients", Message: "clients are only downloaded for mirrored releases",
+					Suggestion: "add releases or remove clients"},
+				{Severity: LintWarning, Path: "mirror.helm.repos[0]", Message: `repository "podinfo" has no charts`,
+					Suggestion: "add charts or remove the repository"},
+			},
+		},
+		{
+			name:   "Valid/Empty",
+			config: header,
+			expFindings: []LintFinding{
+				{Severity: LintWarning, Path: "mirror", Message: "the configuration mirrors nothing",
+					Suggestion: "add platform releases, operators, additionalImages, or helm charts"},
+			},
+		},
+		{
+			name: "Invalid/Unreachable",
+			config: header + `mirror:
+  platform:
+    channels:
+    - name: stable-4.12
+      minVersion: 4.12.3
+      maxVersion: 4.12.1
+      full: true
+    - name: stable-4.13
+      maxVersion: latest
+    upgradePaths:
+    - from: "4.12"
+      to: 4.12.3
+    - from: 4.13.2
+      to: "4.12"
+`,
+			expFindings: []LintFinding{
+				{Severity: LintError, Path: "mirror.platform.channels[0]",
+					Message:    `channel "stable-4.12": minVersion 4.12.3 is greater than maxVersion 4.12.1, no release matches`,
+					Suggestion: "swap minVersion and maxVersion"},
+				{Severity: LintWarning, Path: "mirror.platform.channels[0].full",
+					Message:    `channel "stable-4.12": full is ignored when minVersion and maxVersion are set`,
+					Suggestion: "remove full, or remove minVersion and maxVersion to mirror the whole channel"},
+				{Severity: LintError, Path: "mirror.platform.channels[1].maxVersion",
+					Message:    `channel "stable-4.13": maxVersion "latest" is not a version: No Major.Minor.Patch elements found`,
+					Suggestion: "set a release version such as 4.12.3"},
+				{Severity: LintError, Path: "mirror.platform.upgradePaths[1]",
+					Message:    "upgrade path from 4.13.2 to 4.12 ends before it starts, no release matches",
+					Suggestion: "swap from and to"},
+			},
+		},
+		{
+			name: "Invalid/Validation",
+			config: header + `mirror:
+  platform:
+    channels:
+    - name: stable-4.12
+      architectures:
+      - sparc64
+`,
+			expFindings: []LintFinding{
+				{Severity: LintError,
+					Message: `invalid configuration: release channel "stable-4.12": architecture "sparc64" is not a supported release architecture`},
+			},
+		},
+		{
+			name:   "Invalid/UnknownField",
+			config: header + "mirror:\n  images: []\n",
+			expFindings: []LintFinding{
+				{Severity: LintError, Message: `decode mirror.openshift.io/v1alpha2, Kind=ImageSetConfiguration: json: unknown field "images"`},
+			},
+		},
+		{
+			name:   "Invalid/Kind",
+			config: "apiVersion: mirror.openshift.io/v1alpha2\nkind: ImageSetMirror\n",
+			expFindings: []LintFinding{
+				{Severity: LintError, Path: "kind", Message: `kind "ImageSetMirror" is not ImageSetConfiguration`,
+					Suggestion: "set kind to ImageSetConfiguration"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			require.Equal(t, c.expFindings, Lint([]byte(c.config)))
+		})
+	}
+}
@@ -56,6 +56,10 @@ func LoadConfig(data []byte) (c v1alpha2.ImageSetConfiguration, err error) {
 		return c, fmt.Errorf("decode %s: %v", gvk, err)
 	}
 
+	if err := expandChannelAliases(&c); err != nil {
+		return c, err
+	}
+
 	c.SetGroupVersionKind(gvk)
 
 	return c, nil
@@ -15,6 +15,8 @@ const (
 	AssociationsFile    = "image-associations.gob"
 	ReleaseSignatureDir = "release-signatures"
 	GraphDataDir        = "cincinnati"
+	BootImagesDir       = "rhcos"
+	ClientsDir          = "clients"
 	CatalogsDir         = "catalogs"
 	LayoutsDir          = "layout"
 	IndexDir            = "index"
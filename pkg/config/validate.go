@@ -1,16 +1,62 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
 
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 
+	"github.com/openshift/library-go/pkg/image/reference"
+
 	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
 )
 
+// SupportedArchitectures are the release payload architectures
+// that can be requested from Cincinnati.
+var SupportedArchitectures = map[string]struct{}{
+	"amd64":   {},
+	"arm64":   {},
+	"ppc64le": {},
+	"s390x":   {},
+	"multi":   {},
+}
+
+// PublicKeyPinPrefix prefixes the SHA-256 pins of public keys, as in curl
+const PublicKeyPinPrefix = "sha256//"
+
+// ParsePublicKeyPin returns the SHA-256 hash of the subject public key
+// info of a pin, which is base64 encoded with the sha256// prefix.
+func ParsePublicKeyPin(pin string) ([]byte, error) {
+	hash, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, PublicKeyPinPrefix))
+	if !strings.HasPrefix(pin, PublicKeyPinPrefix) || err != nil || len(hash) != sha256.Size {
+		return nil, fmt.Errorf("public key pin %q must be a base64 encoded SHA-256 hash prefixed with %s", pin, PublicKeyPinPrefix)
+	}
+	return hash, nil
+}
+
+// SupportedClientOperatingSystems are the operating systems
+// client binaries are published for.
+var SupportedClientOperatingSystems = map[string]struct{}{
+	"linux":   {},
+	"mac":     {},
+	"windows": {},
+}
+
+// supportedProxySchemes are the URL schemes of registry proxies.
+var supportedProxySchemes = map[string]struct{}{
+	"http":   {},
+	"https":  {},
+	"socks5": {},
+}
+
 type validationFunc func(cfg *v1alpha2.ImageSetConfiguration) error
 
-var validationChecks = []validationFunc{validateOperatorOptions, validateReleaseChannels}
+var validationChecks = []validationFunc{validateOperatorOptions, validateReleaseChannels, validateReleases, validateUpgradePaths, validateClients, validateGraphBaseImage, validateReleaseVerification, validateCoreOS, validateRegistries, validateImageVerification, validateHooks}
 
 func Validate(cfg *v1alpha2.ImageSetConfiguration) error {
 	var errs []error
@@ -40,6 +86,186 @@ func validateReleaseChannels(cfg *v1alpha2.ImageSetConfiguration) error {
 			)
 		}
 		seen[channel.Name] = true
+		for _, arch := range channel.Architectures {
+			if _, ok := SupportedArchitectures[arch]; !ok {
+				return fmt.Errorf(
+					"release channel %q: architecture %q is not a supported release architecture", channel.Name, arch,
+				)
+			}
+		}
+	}
+	return nil
+}
+
+func validateReleases(cfg *v1alpha2.ImageSetConfiguration) error {
+	seen := map[string]bool{}
+	for _, release := range cfg.Mirror.Platform.Releases {
+		if _, err := reference.Parse(release.Name); err != nil {
+			return fmt.Errorf("release %q: %v", release.Name, err)
+		}
+		if seen[release.Name] {
+			return fmt.Errorf(
+				"release %q: duplicate found in configuration", release.Name,
+			)
+		}
+		seen[release.Name] = true
+	}
+	return nil
+}
+
+func validateUpgradePaths(cfg *v1alpha2.ImageSetConfiguration) error {
+	for _, path := range cfg.Mirror.Platform.UpgradePaths {
+		if path.From == "" || path.To == "" {
+			return fmt.Errorf("upgrade path: from and to must be set")
+		}
+		for _, arch := range path.Architectures {
+			if _, ok := SupportedArchitectures[arch]; !ok {
+				return fmt.Errorf(
+					"upgrade path %s to %s: architecture %q is not a supported release architecture", path.From, path.To, arch,
+				)
+			}
+		}
+	}
+	return nil
+}
+
+func validateClients(cfg *v1alpha2.ImageSetConfiguration) error {
+	for _, opSys := range cfg.Mirror.Platform.Clients.OperatingSystems {
+		if _, ok := SupportedClientOperatingSystems[opSys]; !ok {
+			return fmt.Errorf("clients: operating system %q is not supported", opSys)
+		}
+	}
+	return nil
+}
+
+func validateGraphBaseImage(cfg *v1alpha2.ImageSetConfiguration) error {
+	if cfg.Mirror.Platform.GraphBaseImage == "" {
+		return nil
+	}
+	if _, err := reference.Parse(cfg.Mirror.Platform.GraphBaseImage); err != nil {
+		return fmt.Errorf("graph base image %q: %v", cfg.Mirror.Platform.GraphBaseImage, err)
+	}
+	return nil
+}
+
+func validateReleaseVerification(cfg *v1alpha2.ImageSetConfiguration) error {
+	switch policy := cfg.Mirror.Platform.Verification.Policy; policy {
+	case "", v1alpha2.VerificationPolicyWarn, v1alpha2.VerificationPolicyEnforce:
+	default:
+		return fmt.Errorf("verification: policy %q is not supported", policy)
+	}
+	return nil
+}
+
+func validateImageVerification(cfg *v1alpha2.ImageSetConfiguration) error {
+	verification := cfg.Mirror.Verification
+	switch verification.Policy {
+	case "", v1alpha2.VerificationPolicyWarn, v1alpha2.VerificationPolicyEnforce:
+	default:
+		return fmt.Errorf("image verification: policy %q is not supported", verification.Policy)
+	}
+	for _, signers := range verification.Images {
+		if _, err := path.Match(signers.Pattern, ""); err != nil || signers.Pattern == "" {
+			return fmt.Errorf("image verification: invalid pattern %q", signers.Pattern)
+		}
+		if len(signers.PublicKeys) == 0 && len(signers.Identities) == 0 {
+			return fmt.Errorf("image verification %q: publicKeys or identities must be set", signers.Pattern)
+		}
+		if len(signers.Identities) != 0 && signers.CAFile == "" {
+			return fmt.Errorf("image verification %q: caFile must be set to verify identities", signers.Pattern)
+		}
+		for _, id := range signers.Identities {
+			if id.Issuer == "" || id.Subject == "" {
+				return fmt.Errorf("image verification %q: identity issuer and subject must be set", signers.Pattern)
+			}
+		}
+	}
+	return nil
+}
+
+func validateCoreOS(cfg *v1alpha2.ImageSetConfiguration) error {
+	for _, img := range cfg.Mirror.Platform.CoreOS.OSImages {
+		if _, err := reference.Parse(img.Name); err != nil {
+			return fmt.Errorf("coreOS image %q: %v", img.Name, err)
+		}
+	}
+	return nil
+}
+
+func validateRegistries(cfg *v1alpha2.ImageSetConfiguration) error {
+	seen := make(map[string]struct{}, len(cfg.Registries))
+	for _, reg := range cfg.Registries {
+		if reg.Host == "" {
+			return fmt.Errorf("registries: host must be set")
+		}
+		if _, found := seen[reg.Host]; found {
+			return fmt.Errorf("registry %q: host is configured more than once", reg.Host)
+		}
+		seen[reg.Host] = struct{}{}
+		if (reg.ClientCert == "") != (reg.ClientKey == "") {
+			return fmt.Errorf("registry %q: clientCert and clientKey must be set together", reg.Host)
+		}
+		if reg.CredentialHelper != "" && reg.Vault != nil {
+			return fmt.Errorf("registry %q: credentialHelper and vault are mutually exclusive", reg.Host)
+		}
+		if reg.Vault != nil && reg.Vault.Path == "" {
+			return fmt.Errorf("registry %q: vault path must be set", reg.Host)
+		}
+		for _, pin := range reg.PinnedPublicKeys {
+			if _, err := ParsePublicKeyPin(pin); err != nil {
+				return fmt.Errorf("registry %q: %v", reg.Host, err)
+			}
+		}
+		for _, addr := range reg.Addresses {
+			if net.ParseIP(addr) == nil {
+				return fmt.Errorf("registry %q: address %q must be an IPv4 or IPv6 address", reg.Host, addr)
+			}
+		}
+		if reg.PathPrefix != "" {
+			prefix := strings.TrimSuffix(reg.PathPrefix, "/")
+			if !strings.HasPrefix(prefix, "/") || strings.ContainsAny(prefix, "?#") || prefix == "" {
+				return fmt.Errorf("registry %q: path prefix %q must be an absolute URL path", reg.Host, reg.PathPrefix)
+			}
+			if prefix == "/v2" || strings.HasSuffix(prefix, "/v2") {
+				return fmt.Errorf("registry %q: path prefix %q must not include the /v2 API path", reg.Host, reg.PathPrefix)
+			}
+		}
+		if reg.Proxy != "" {
+			u, err := url.Parse(reg.Proxy)
+			if err != nil {
+				return fmt.Errorf("registry %q: invalid proxy: %v", reg.Host, err)
+			}
+			if _, ok := supportedProxySchemes[u.Scheme]; !ok || u.Host == "" {
+				return fmt.Errorf("registry %q: proxy %q must be an http, https, or socks5 URL", reg.Host, reg.Proxy)
+			}
+		}
+	}
+	return nil
+}
+
+func validateHooks(cfg *v1alpha2.ImageSetConfiguration) error {
+	for i, hook := range cfg.Hooks {
+		name := hook.Name
+		if name == "" {
+			name = fmt.Sprintf("hooks[%d]", i)
+		}
+		switch hook.Phase {
+		case v1alpha2.HookPhasePlan, v1alpha2.HookPhaseArchive, v1alpha2.HookPhasePublish:
+		default:
+			return fmt.Errorf("hook %q: phase %q is not supported: must be %s, %s, or %s", name, hook.Phase,
+				v1alpha2.HookPhasePlan, v1alpha2.HookPhaseArchive, v1alpha2.HookPhasePublish)
+		}
+		switch hook.When {
+		case v1alpha2.HookBefore, v1alpha2.HookAfter:
+		default:
+			return fmt.Errorf("hook %q: when %q is not supported: must be %s or %s", name, hook.When, v1alpha2.HookBefore, v1alpha2.HookAfter)
+		}
+		if len(hook.Command) == 0 || hook.Command[0] == "" {
+			return fmt.Errorf("hook %q: command must be set", name)
+		}
+		if hook.TimeoutSeconds < 0 {
+			return fmt.Errorf("hook %q: timeoutSeconds must not be negative", name)
+		}
 	}
 	return nil
 }
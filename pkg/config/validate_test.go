@@ -127,6 +127,275 @@ func TestValidate(t *testing.T) {
 			},
 			expError: "invalid configuration: release channel \"channel\": duplicate found in configuration",
 		},
+		{
+			name: "Invalid/DuplicateReleases",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Mirror: v1alpha2.Mirror{
+						Platform: v1alpha2.Platform{
+							Releases: []v1alpha2.Image{
+								{Name: "quay.io/openshift-release-dev/ocp-release:4.9.0-x86_64"},
+								{Name: "quay.io/openshift-release-dev/ocp-release:4.9.0-x86_64"},
+							},
+						},
+					},
+				},
+			},
+			expError: "invalid configuration: release \"quay.io/openshift-release-dev/ocp-release:4.9.0-x86_64\": duplicate found in configuration",
+		},
+		{
+			name: "Invalid/ReleasePullspec",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Mirror: v1alpha2.Mirror{
+						Platform: v1alpha2.Platform{
+							Releases: []v1alpha2.Image{
+								{Name: ""},
+							},
+						},
+					},
+				},
+			},
+			expError: "invalid configuration: release \"\": repository name must have at least one component",
+		},
+		{
+			name: "Invalid/ClientOperatingSystem",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Mirror: v1alpha2.Mirror{
+						Platform: v1alpha2.Platform{
+							Clients: v1alpha2.Clients{
+								OperatingSystems: []string{"plan9"},
+							},
+						},
+					},
+				},
+			},
+			expError: "invalid configuration: clients: operating system \"plan9\" is not supported",
+		},
+		{
+			name: "Invalid/GraphBaseImage",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Mirror: v1alpha2.Mirror{
+						Platform: v1alpha2.Platform{
+							GraphBaseImage: "registry.access.redhat.com/ubi8/UBI",
+						},
+					},
+				},
+			},
+			expError: "invalid configuration: graph base image \"registry.access.redhat.com/ubi8/UBI\": repository name must be lowercase",
+		},
+		{
+			name: "Invalid/VerificationPolicy",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Mirror: v1alpha2.Mirror{
+						Platform: v1alpha2.Platform{
+							Verification: v1alpha2.ReleaseVerification{Policy: "ignore"},
+						},
+					},
+				},
+			},
+			expError: "invalid configuration: verification: policy \"ignore\" is not supported",
+		},
+		{
+			name: "Invalid/UpgradePathMissingTarget",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Mirror: v1alpha2.Mirror{
+						Platform: v1alpha2.Platform{
+							UpgradePaths: []v1alpha2.UpgradePath{{From: "4.10"}},
+						},
+					},
+				},
+			},
+			expError: "invalid configuration: upgrade path: from and to must be set",
+		},
+		{
+			name: "Invalid/RegistryClientKeyMissing",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Registries: []v1alpha2.Registry{{Host: "registry.example.com", ClientCert: "client.crt"}},
+				},
+			},
+			expError: "invalid configuration: registry \"registry.example.com\": clientCert and clientKey must be set together",
+		},
+		{
+			name: "Invalid/RegistryHelperAndVault",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Registries: []v1alpha2.Registry{{
+						Host:             "registry.example.com",
+						CredentialHelper: "docker-credential-exec",
+						Vault:            &v1alpha2.VaultCredentials{Path: "secret/data/registry"},
+					}},
+				},
+			},
+			expError: "invalid configuration: registry \"registry.example.com\": credentialHelper and vault are mutually exclusive",
+		},
+		{
+			name: "Invalid/RegistryVaultPathMissing",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Registries: []v1alpha2.Registry{{Host: "registry.example.com", Vault: &v1alpha2.VaultCredentials{}}},
+				},
+			},
+			expError: "invalid configuration: registry \"registry.example.com\": vault path must be set",
+		},
+		{
+			name: "Invalid/RegistryDuplicateHost",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Registries: []v1alpha2.Registry{{Host: "registry.example.com"}, {Host: "registry.example.com"}},
+				},
+			},
+			expError: "invalid configuration: registry \"registry.example.com\": host is configured more than once",
+		},
+		{
+			name: "Invalid/ImageVerificationNoSigners",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Mirror: v1alpha2.Mirror{
+						Verification: v1alpha2.ImageVerification{
+							Images: []v1alpha2.ImageSigners{{Pattern: "registry.example.com/**"}},
+						},
+					},
+				},
+			},
+			expError: "invalid configuration: image verification \"registry.example.com/**\": publicKeys or identities must be set",
+		},
+		{
+			name: "Invalid/ImageVerificationIdentityCA",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Mirror: v1alpha2.Mirror{
+						Verification: v1alpha2.ImageVerification{
+							Images: []v1alpha2.ImageSigners{{
+								Pattern:    "registry.example.com/**",
+								Identities: []v1alpha2.SignerIdentity{{Issuer: "https://accounts.example.com", Subject: "ci@example.com"}},
+							}},
+						},
+					},
+				},
+			},
+			expError: "invalid configuration: image verification \"registry.example.com/**\": caFile must be set to verify identities",
+		},
+		{
+			name: "Valid/RegistryProxy",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Registries: []v1alpha2.Registry{{Host: "registry.example.com", Proxy: "http://proxy.example.com:3128"}},
+				},
+			},
+		},
+		{
+			name: "Invalid/RegistryProxyScheme",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Registries: []v1alpha2.Registry{{Host: "registry.example.com", Proxy: "proxy.example.com:3128"}},
+				},
+			},
+			expError: "invalid configuration: registry \"registry.example.com\": proxy \"proxy.example.com:3128\" must be an http, https, or socks5 URL",
+		},
+		{
+			name: "Valid/RegistryAddresses",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Registries: []v1alpha2.Registry{{Host: "registry.lab", Addresses: []string{"fd00::10", "192.0.2.10"}}},
+				},
+			},
+		},
+		{
+			name: "Invalid/RegistryAddress",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Registries: []v1alpha2.Registry{{Host: "registry.lab", Addresses: []string{"[fd00::10]"}}},
+				},
+			},
+			expError: "invalid configuration: registry \"registry.lab\": address \"[fd00::10]\" must be an IPv4 or IPv6 address",
+		},
+		{
+			name: "Valid/RegistryPathPrefix",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Registries: []v1alpha2.Registry{{Host: "artifactory.example.com", PathPrefix: "/artifactory/api/docker/docker-local/"}},
+				},
+			},
+		},
+		{
+			name: "Invalid/RegistryPathPrefixRelative",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Registries: []v1alpha2.Registry{{Host: "artifactory.example.com", PathPrefix: "artifactory/api/docker/docker-local"}},
+				},
+			},
+			expError: "invalid configuration: registry \"artifactory.example.com\": path prefix \"artifactory/api/docker/docker-local\" must be an absolute URL path",
+		},
+		{
+			name: "Invalid/RegistryPathPrefixAPI",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Registries: []v1alpha2.Registry{{Host: "registry.example.com", PathPrefix: "/registry/v2/"}},
+				},
+			},
+			expError: "invalid configuration: registry \"registry.example.com\": path prefix \"/registry/v2/\" must not include the /v2 API path",
+		},
+		{
+			name: "Invalid/PublicKeyPin",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Registries: []v1alpha2.Registry{{Host: "api.openshift.com", PinnedPublicKeys: []string{"sha256//c2hvcnQ="}}},
+				},
+			},
+			expError: "invalid configuration: registry \"api.openshift.com\": public key pin \"sha256//c2hvcnQ=\" must be a base64 encoded SHA-256 hash prefixed with sha256//",
+		},
+		{
+			name: "Valid/PublicKeyPin",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Registries: []v1alpha2.Registry{{Host: "api.openshift.com", PinnedPublicKeys: []string{"sha256//47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}}},
+				},
+			},
+		},
+		{
+			name: "Valid/Hooks",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Hooks: []v1alpha2.Hook{
+						{Phase: v1alpha2.HookPhaseArchive, When: v1alpha2.HookBefore, Command: []string{"mount", "/mnt/media"}},
+						{Phase: v1alpha2.HookPhasePublish, When: v1alpha2.HookAfter, Command: []string{"notify"}, TimeoutSeconds: 60},
+					},
+				},
+			},
+		},
+		{
+			name: "Invalid/HookPhase",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Hooks: []v1alpha2.Hook{{Name: "scan", Phase: "mirror", When: v1alpha2.HookAfter, Command: []string{"scan"}}},
+				},
+			},
+			expError: "invalid configuration: hook \"scan\": phase \"mirror\" is not supported: must be plan, archive, or publish",
+		},
+		{
+			name: "Invalid/HookWhen",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Hooks: []v1alpha2.Hook{{Phase: v1alpha2.HookPhasePlan, When: "during", Command: []string{"notify"}}},
+				},
+			},
+			expError: "invalid configuration: hook \"hooks[0]\": when \"during\" is not supported: must be before or after",
+		},
+		{
+			name: "Invalid/HookCommand",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Hooks: []v1alpha2.Hook{{Name: "notify", Phase: v1alpha2.HookPhasePlan, When: v1alpha2.HookBefore}},
+				},
+			},
+			expError: "invalid configuration: hook \"notify\": command must be set",
+		},
 	}
 
 	for _, c := range cases {
@@ -0,0 +1,101 @@
+// Package exitcode classifies the errors of oc-mirror by exit code,
+// so wrapper scripts and library callers can branch on the class of failure.
+package exitcode
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"syscall"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// Exit codes of oc-mirror.
+// Changing the value of an exit code is a breaking change.
+const (
+	// Success is returned when the command succeeded
+	Success = 0
+	// Error is returned for failures without a more specific exit code
+	Error = 1
+	// ConfigError is returned for invalid flags, arguments, and imageset configurations
+	ConfigError = 2
+	// AuthError is returned when a registry rejected the credentials
+	AuthError = 3
+	// SequenceError is returned when publishing an imageset out of sequence
+	SequenceError = 4
+	// PartialFailure is returned when errors were skipped with --continue-on-error
+	PartialFailure = 5
+	// StorageError is returned when the metadata backend or the local disk failed
+	StorageError = 6
+)
+
+// authErrorMessages are the messages of registry authentication
+// failures reported without a structured error
+var authErrorMessages = []string{
+	"unauthorized",
+	"authentication required",
+	"denied: requested access",
+	"incorrect username or password",
+}
+
+// CodeError is an error with the exit code of its class
+type CodeError struct {
+	Code int
+	Err  error
+}
+
+func (e *CodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CodeError) Unwrap() error {
+	return e.Err
+}
+
+// With returns the error with an exit code, keeping the
+// exit code of an error that already has one. A nil error stays nil.
+func With(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	var cerr *CodeError
+	if errors.As(err, &cerr) {
+		return err
+	}
+	return &CodeError{Code: code, Err: err}
+}
+
+// Of returns the exit code of the error. Authentication failures
+// take precedence since they are detected wherever the error was returned.
+func Of(err error) int {
+	if err == nil {
+		return Success
+	}
+	if isAuthError(err) {
+		return AuthError
+	}
+	var cerr *CodeError
+	if errors.As(err, &cerr) {
+		return cerr.Code
+	}
+	if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EROFS) {
+		return StorageError
+	}
+	return Error
+}
+
+// isAuthError returns whether the error is a registry authentication failure
+func isAuthError(err error) bool {
+	var terr *transport.Error
+	if errors.As(err, &terr) && (terr.StatusCode == http.StatusUnauthorized || terr.StatusCode == http.StatusForbidden) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range authErrorMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package exitcode
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOf(t *testing.T) {
+	type spec struct {
+		name string
+		err  error
+		exp  int
+	}
+	cases := []spec{
+		{
+			name: "Valid/Nil",
+			exp:  Success,
+		},
+		{
+			name: "Valid/Unclassified",
+			err:  errors.New("unexpected error"),
+			exp:  Error,
+		},
+		{
+			name: "Valid/Wrapped",
+			err:  fmt.Errorf("error publishing: %w", With(SequenceError, errors.New("invalid mirror sequence order"))),
+			exp:  SequenceError,
+		},
+		{
+			name: "Valid/KeepsFirstCode",
+			err:  With(StorageError, With(ConfigError, errors.New("invalid configuration"))),
+			exp:  ConfigError,
+		},
+		{
+			name: "Valid/TransportUnauthorized",
+			err:  With(StorageError, fmt.Errorf("error reading metadata: %w", &transport.Error{StatusCode: http.StatusUnauthorized})),
+			exp:  AuthError,
+		},
+		{
+			name: "Valid/AuthMessage",
+			err:  errors.New("unable to retrieve source image quay.io/foo/bar: unauthorized: authentication required"),
+			exp:  AuthError,
+		},
+		{
+			name: "Valid/NoSpace",
+			err:  fmt.Errorf("error writing blob: %w", &os.PathError{Op: "write", Path: "blob", Err: syscall.ENOSPC}),
+			exp:  StorageError,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			require.Equal(t, c.exp, Of(c.err))
+		})
+	}
+}
@@ -0,0 +1,131 @@
+// Package httplog logs the HTTP requests of registry clients for troubleshooting.
+package httplog
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// component is the component field of the logged requests
+const component = "http"
+
+// redacted replaces secrets in logged requests
+const redacted = "REDACTED"
+
+var enabled int32
+
+// SetEnabled turns logging of the requests of wrapped transports on or off
+func SetEnabled(enable bool) {
+	var v int32
+	if enable {
+		v = 1
+	}
+	atomic.StoreInt32(&enabled, v)
+}
+
+// Enabled returns whether requests of wrapped transports are logged
+func Enabled() bool {
+	return atomic.LoadInt32(&enabled) == 1
+}
+
+// roundTripper logs the requests of the wrapped transport at the trace level
+type roundTripper struct {
+	rt http.RoundTripper
+}
+
+// Wrap returns a transport that logs the method, URL, status, duration,
+// and authentication challenges of the requests of rt when enabled.
+// Credentials, tokens, and signatures are redacted.
+func Wrap(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	if _, ok := rt.(*roundTripper); ok {
+		return rt
+	}
+	return &roundTripper{rt: rt}
+}
+
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !Enabled() {
+		return t.rt.RoundTrip(req)
+	}
+
+	start := time.Now()
+	resp, err := t.rt.RoundTrip(req)
+	fields := logrus.Fields{
+		"component": component,
+		"method":    req.Method,
+		"url":       RedactURL(req.URL),
+		"duration":  time.Since(start).Round(time.Millisecond).String(),
+	}
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		fields["authorization"] = authScheme(auth) + " " + redacted
+	}
+	log := logrus.WithFields(fields)
+	if err != nil {
+		log.WithError(err).Tracef("%s %s failed", req.Method, fields["url"])
+		return resp, err
+	}
+
+	log = log.WithField("status", resp.StatusCode)
+	if challenge := resp.Header.Get("WWW-Authenticate"); challenge != "" {
+		log = log.WithField("challenge", challenge)
+	}
+	if location := resp.Header.Get("Location"); location != "" {
+		if u, err := req.URL.Parse(location); err == nil {
+			log = log.WithField("location", RedactURL(u))
+		}
+	}
+	log.Tracef("%s %s %s", req.Method, fields["url"], resp.Status)
+	return resp, nil
+}
+
+// authScheme returns the scheme of an Authorization header
+func authScheme(auth string) string {
+	if i := strings.IndexByte(auth, ' '); i > 0 {
+		return auth[:i]
+	}
+	return "<unknown>"
+}
+
+// RedactURL returns the URL with its user info and
+// the values of its secret query parameters redacted
+func RedactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	redactedURL := *u
+	if u.User != nil {
+		redactedURL.User = url.User(redacted)
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			if isSecret(key) {
+				query[key] = []string{redacted}
+			}
+		}
+		redactedURL.RawQuery = query.Encode()
+	}
+	return redactedURL.String()
+}
+
+// secretParams are substrings of the names of query parameters carrying
+// secrets, such as tokens and the signatures of pre-signed blob URLs
+var secretParams = []string{"token", "signature", "sig", "credential", "secret", "password", "key", "code", "auth"}
+
+func isSecret(param string) bool {
+	param = strings.ToLower(param)
+	for _, secret := range secretParams {
+		if strings.Contains(param, secret) {
+			return true
+		}
+	}
+	return false
+}
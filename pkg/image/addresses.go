@@ -0,0 +1,53 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// hostDialer connects to hosts at the addresses configured for
+// them instead of the addresses their name resolves to
+type hostDialer struct {
+	dialer *net.Dialer
+	// addresses holds the addresses of hosts by host, with or without the port
+	addresses map[string][]string
+}
+
+// DialContext connects to the address. When addresses are configured for
+// its host, they are tried in order with the port of the address, and the
+// error of the last one is returned if none accepts the connection.
+func (d *hostDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	addrs, ok := d.addresses[address]
+	hostname, port, err := net.SplitHostPort(address)
+	if !ok && err == nil {
+		addrs, ok = d.addresses[hostname]
+	}
+	if !ok {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+	var lastErr error
+	for _, addr := range addrs {
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(addr, port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("error connecting to %s at its configured addresses: %v", address, lastErr)
+}
+
+// DialContext connects to the address, or to the addresses configured for
+// its host with SetRegistries. Every client of `oc mirror` uses it, including
+// the clients of Cincinnati and of downloads.
+func (c *Clients) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return c.dialer(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext(ctx, network, address)
+}
+
+// dialer returns a dialer using the addresses of the registries
+func (c *Clients) dialer(d *net.Dialer) *hostDialer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &hostDialer{dialer: d, addresses: c.addresses}
+}
@@ -0,0 +1,92 @@
+package image
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+func TestRegistryAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	_, port, err := net.SplitHostPort(u.Host)
+	require.NoError(t, err)
+
+	type spec struct {
+		name       string
+		registries []v1alpha2.Registry
+		expError   bool
+	}
+	cases := []spec{
+		{
+			name:     "Invalid/NoAddresses",
+			expError: true,
+		},
+		{
+			name:       "Invalid/OtherHost",
+			registries: []v1alpha2.Registry{{Host: "registry.example.com", Addresses: []string{"127.0.0.1"}}},
+			expError:   true,
+		},
+		{
+			name:       "Valid/Hostname",
+			registries: []v1alpha2.Registry{{Host: "registry.invalid", Insecure: true, Addresses: []string{"127.0.0.1"}}},
+		},
+		{
+			name:       "Valid/HostAndPort",
+			registries: []v1alpha2.Registry{{Host: "registry.invalid:" + port, Insecure: true, Addresses: []string{"127.0.0.1"}}},
+		},
+		{
+			// The port of the server is closed on the IPv6
+			// loopback address, or IPv6 is not available
+			name:       "Valid/SecondAddress",
+			registries: []v1alpha2.Registry{{Host: "registry.invalid", Insecure: true, Addresses: []string{"::1", "127.0.0.1"}}},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			clients := NewClients()
+			require.NoError(t, clients.SetRegistries(c.registries))
+			target := "http://registry.invalid:" + port + "/v2/"
+
+			for _, client := range []*http.Client{{Transport: clients.Transport(true)}, clients.HTTPClient()} {
+				resp, err := client.Get(target)
+				if c.expError {
+					require.Error(t, err)
+					continue
+				}
+				require.NoError(t, err)
+				resp.Body.Close()
+				require.Equal(t, http.StatusOK, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestRegistryAddressesIPv6(t *testing.T) {
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 is not available: %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+
+	clients := NewClients()
+	require.NoError(t, clients.SetRegistries([]v1alpha2.Registry{{Host: "registry.invalid", Addresses: []string{"::1"}}}))
+	resp, err := clients.HTTPClient().Get("http://registry.invalid:" + port + "/v2/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
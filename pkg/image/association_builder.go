@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 
 	ctrsimgmanifest "github.com/containers/image/v5/manifest"
 	"github.com/docker/distribution"
@@ -16,7 +19,8 @@ import (
 	"github.com/opencontainers/go-digest"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/openshift/oc/pkg/cli/image/imagesource"
-	"github.com/operator-framework/operator-registry/pkg/image/containerdregistry"
+	imagemanifest "github.com/openshift/oc/pkg/cli/image/manifest"
+	"github.com/sirupsen/logrus"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 
 	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
@@ -39,30 +43,94 @@ func (e *ErrInvalidComponent) Error() string {
 	return fmt.Sprintf("image %q has invalid component %q", e.image, e.tag)
 }
 
-// AssociateLocalImageLayers traverses a V2 directory and gathers all child manifests and layer digest information
-// for mirrored images
-func AssociateLocalImageLayers(rootDir string, imgMappings TypedImageMapping) (AssociationSet, utilerrors.Aggregate) {
+// associateFunc returns the key and associations of the image of a mapping
+type associateFunc func(ctx context.Context, srcImg, dstImg TypedImage, skipParse func(string) bool) (string, []v1alpha2.Association, error)
+
+// associateImages associates the images of the mapping concurrently, up to
+// MaxPerRegistry images of each source registry at a time, and stops
+// associating images when ctx is cancelled. The associations of images
+// that failed are dropped.
+func associateImages(ctx context.Context, imgMappings TypedImageMapping, parallel imagemanifest.ParallelOptions, associate associateFunc) (AssociationSet, utilerrors.Aggregate) {
+	var mu sync.Mutex
 	errs := []error{}
 	bundleAssociations := AssociationSet{}
 
 	skipParse := func(ref string) bool {
-		seen := bundleAssociations.SetContainsKey(ref)
-		return seen
+		mu.Lock()
+		defer mu.Unlock()
+		return bundleAssociations.SetContainsKey(ref)
+	}
+
+	type work struct {
+		src, dst TypedImage
+	}
+	byRegistry := map[string][]work{}
+	for srcImg, dstImg := range imgMappings {
+		byRegistry[srcImg.Ref.Registry] = append(byRegistry[srcImg.Ref.Registry], work{src: srcImg, dst: dstImg})
+	}
+	maxPerRegistry := parallel.MaxPerRegistry
+	if maxPerRegistry < 1 {
+		maxPerRegistry = 1
+	}
+
+	var wg sync.WaitGroup
+	for _, works := range byRegistry {
+		queue := make(chan work)
+		for i := 0; i < maxPerRegistry; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for w := range queue {
+					key, associations, err := associate(ctx, w.src, w.dst, skipParse)
+					mu.Lock()
+					if err != nil {
+						errs = append(errs, err)
+					} else {
+						for _, association := range associations {
+							bundleAssociations.Add(key, association)
+						}
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Add(1)
+		go func(works []work) {
+			defer wg.Done()
+			defer close(queue)
+			for _, w := range works {
+				if ctx.Err() != nil {
+					return
+				}
+				select {
+				case queue <- w:
+				case <-ctx.Done():
+				}
+			}
+		}(works)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, fmt.Errorf("associating images: %w", err))
 	}
 
+	return bundleAssociations, utilerrors.NewAggregate(errs)
+}
+
+// AssociateLocalImageLayers traverses a V2 directory and gathers all child manifests and layer digest information
+// for mirrored images
+func AssociateLocalImageLayers(ctx context.Context, rootDir string, imgMappings TypedImageMapping, parallel imagemanifest.ParallelOptions) (AssociationSet, utilerrors.Aggregate) {
 	localRoot := filepath.Join(rootDir, "v2")
-	for image, diskLoc := range imgMappings {
+	return associateImages(ctx, imgMappings, parallel, func(_ context.Context, image, diskLoc TypedImage, skipParse func(string) bool) (string, []v1alpha2.Association, error) {
 		if diskLoc.Type != imagesource.DestinationFile {
-			errs = append(errs, fmt.Errorf("image destination for %q is not type file", image.Ref.Exact()))
-			continue
+			return "", nil, fmt.Errorf("image destination for %q is not type file", image.Ref.Exact())
 		}
 		dirRef := diskLoc.Ref.AsRepository().String()
 		imagePath := filepath.Join(localRoot, dirRef)
 
 		// Verify that the dirRef exists before proceeding
 		if _, err := os.Stat(imagePath); err != nil {
-			errs = append(errs, &ErrInvalidImage{image.String()})
-			continue
+			return "", nil, &ErrInvalidImage{image.String()}
 		}
 
 		var tagOrID string
@@ -73,22 +141,12 @@ func AssociateLocalImageLayers(rootDir string, imgMappings TypedImageMapping) (A
 		}
 
 		if tagOrID == "" {
-			errs = append(errs, &ErrInvalidComponent{image.String(), tagOrID})
-			continue
+			return "", nil, &ErrInvalidComponent{image.String(), tagOrID}
 		}
 
-		// TODO(estroz): parallelize
 		associations, err := associateLocalImageLayers(image.Ref.String(), localRoot, dirRef, tagOrID, "oc-mirror", image.Category, skipParse)
-		if err != nil {
-			errs = append(errs, err)
-			continue
-		}
-		for _, association := range associations {
-			bundleAssociations.Add(image.Ref.String(), association)
-		}
-	}
-
-	return bundleAssociations, utilerrors.NewAggregate(errs)
+		return image.Ref.String(), associations, err
+	})
 }
 
 func associateLocalImageLayers(image, localRoot, dirRef, tagOrID, defaultTag string, typ v1alpha2.ImageType, skipParse func(string) bool) (associations []v1alpha2.Association, err error) {
@@ -134,7 +192,7 @@ func associateLocalImageLayers(image, localRoot, dirRef, tagOrID, defaultTag str
 	default:
 		return nil, fmt.Errorf("expected symlink or regular file mode, got: %b", m)
 	}
-	manifestBytes, err := ioutil.ReadFile(filepath.Clean(manifestPath))
+	manifestBytes, err := readManifestFile(manifestPath)
 	if err != nil {
 		return nil, fmt.Errorf("error reading image manifest file: %v", err)
 	}
@@ -185,81 +243,124 @@ func associateLocalImageLayers(image, localRoot, dirRef, tagOrID, defaultTag str
 	return associations, nil
 }
 
+// maxManifestSize is the size of the largest manifest read,
+// matching the limit of the containers/image library
+const maxManifestSize = 4 * 1024 * 1024
+
+// readManifestFile reads a manifest file, failing on files too large to be manifests
+// so a blob stored in place of a manifest is not read into memory
+func readManifestFile(path string) ([]byte, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(f, maxManifestSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxManifestSize {
+		return nil, fmt.Errorf("manifest %s exceeds the maximum manifest size of %d bytes", path, maxManifestSize)
+	}
+	return data, nil
+}
+
 // AssociateRemoteImageLayers queries remote manifests and gathers all child manifests and layer digest information
-// for mirrored images
-func AssociateRemoteImageLayers(ctx context.Context, imgMappings TypedImageMapping, skipTlS, plainHTTP, skipVerification bool) (AssociationSet, utilerrors.Aggregate) {
+// for mirrored images, or for images planned for an imageset without downloading them. Images that resolve to the digest they had in the previous associations are not queried,
+// their previous associations are reused instead.
+func (c *Clients) AssociateRemoteImageLayers(ctx context.Context, imgMappings TypedImageMapping, prev AssociationSet, skipTlS, plainHTTP, skipVerification bool, parallel imagemanifest.ParallelOptions) (AssociationSet, utilerrors.Aggregate) {
 	var insecure bool
 	if skipTlS || plainHTTP {
 		insecure = true
 	}
-	errs := []error{}
-	bundleAssociations := AssociationSet{}
-
-	skipParse := func(ref string) bool {
-		seen := bundleAssociations.SetContainsKey(ref)
-		return seen
-	}
 
-	resolver, err := containerdregistry.NewResolver("", skipTlS, plainHTTP, nil)
+	resolver := c.Resolver(skipTlS, plainHTTP)
+	// The registry context caches credentials and
+	// connections, so it is shared by the workers
+	regctx, err := c.Context(skipVerification)
 	if err != nil {
-		errs = append(errs, fmt.Errorf("error creating image resolver: %v", err))
-		return bundleAssociations, utilerrors.NewAggregate(errs)
+		return AssociationSet{}, utilerrors.NewAggregate([]error{fmt.Errorf("error creating registry context: %v", err)})
 	}
 
-	for srcImg, dstImg := range imgMappings {
-		if dstImg.Type != imagesource.DestinationRegistry {
-			errs = append(errs, fmt.Errorf("image destination for %q is not type registry", srcImg.Ref.Exact()))
-			continue
+	var reused int64
+	defer func() {
+		if reused != 0 {
+			logrus.Infof("Reused the associations of %d unchanged images from previous runs", reused)
+		}
+	}()
+
+	return associateImages(ctx, imgMappings, parallel, func(ctx context.Context, srcImg, dstImg TypedImage, skipParse func(string) bool) (string, []v1alpha2.Association, error) {
+		dstPath := dstImg.String()
+		switch dstImg.Type {
+		case imagesource.DestinationRegistry:
+		case imagesource.DestinationFile:
+			// Images planned for an imageset are associated with
+			// their path in the workspace, as they are once downloaded
+			dstPath = dstImg.Ref.AsRepository().String()
+		default:
+			return "", nil, fmt.Errorf("image destination for %q is not type registry or file", srcImg.Ref.Exact())
 		}
 
 		if srcImg.Ref.ID == "" {
 			if srcImg.Ref.Tag == "" {
-				errs = append(errs, &ErrInvalidComponent{srcImg.String(), srcImg.Ref.Tag})
-				continue
+				return "", nil, &ErrInvalidComponent{srcImg.String(), srcImg.Ref.Tag}
 			}
 			imgWithID, err := ResolveToPin(ctx, resolver, srcImg.Ref.Exact())
 			if err != nil {
-				errs = append(errs, err)
-				continue
+				return "", nil, err
 			}
 			pinnedRef, err := imagesource.ParseReference(imgWithID)
 			if err != nil {
-				errs = append(errs, fmt.Errorf("error parsing source image %s: %v", imgWithID, err))
-				continue
+				return "", nil, fmt.Errorf("error parsing source image %s: %v", imgWithID, err)
 			}
 			srcImg.Ref.ID = pinnedRef.Ref.ID
 		}
 
-		regctx, err := NewContext(skipVerification)
-		if err != nil {
-			errs = append(errs, fmt.Errorf("error creating registry context: %v", err))
-			continue
+		if associations, ok := reuseAssociations(prev, srcImg, dstPath); ok {
+			atomic.AddInt64(&reused, 1)
+			return srcImg.String(), associations, nil
 		}
 
 		repo, err := regctx.RepositoryForRef(ctx, srcImg.Ref, insecure)
 		if err != nil {
-			errs = append(errs, fmt.Errorf("create repo for %s: %v", srcImg.Ref.Exact(), err))
-			continue
+			return "", nil, fmt.Errorf("create repo for %s: %v", srcImg.Ref.Exact(), err)
 		}
 
 		ms, err := repo.Manifests(ctx)
 		if err != nil {
-			errs = append(errs, fmt.Errorf("open blob: %v", err))
-			continue
+			return "", nil, fmt.Errorf("open blob: %v", err)
 		}
 
-		// TODO(estroz): parallelize
-		associations, err := associateRemoteImageLayers(ctx, srcImg.String(), dstImg.String(), srcImg, ms, skipParse, insecure)
-		if err != nil {
-			errs = append(errs, err)
-			continue
-		}
-		for _, association := range associations {
-			bundleAssociations.Add(srcImg.String(), association)
+		associations, err := associateRemoteImageLayers(ctx, srcImg.String(), dstPath, srcImg, ms, skipParse, insecure)
+		return srcImg.String(), associations, err
+	})
+}
+
+// reuseAssociations returns the previous associations of the pinned image
+// for the destination when the image resolved to the same digest before
+func reuseAssociations(prev AssociationSet, srcImg TypedImage, dstImg string) ([]v1alpha2.Association, bool) {
+	key := srcImg.String()
+	assocs, ok := prev[key]
+	if !ok {
+		return nil, false
+	}
+	if top, ok := assocs[key]; !ok || top.ID != srcImg.Ref.ID {
+		return nil, false
+	}
+	// Associations missing child manifests are associated again
+	if err := (AssociationSet{key: assocs}).Validate(); err != nil {
+		return nil, false
+	}
+	associations := make([]v1alpha2.Association, 0, len(assocs))
+	for _, association := range assocs {
+		association.Path = dstImg
+		association.Type = srcImg.Category
+		if association.Name == key {
+			association.TagSymlink = srcImg.Ref.Tag
 		}
+		associations = append(associations, association)
 	}
-
-	return bundleAssociations, utilerrors.NewAggregate(errs)
+	return associations, true
 }
 
 func associateRemoteImageLayers(ctx context.Context, srcImg, dstImg string, srcInfo TypedImage, ms distribution.ManifestService, skipParse func(string) bool, insecure bool) (associations []v1alpha2.Association, err error) {
@@ -13,12 +13,16 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/docker/distribution/manifest"
 	"github.com/openshift/library-go/pkg/image/reference"
 	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
 	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	imagemanifest "github.com/openshift/oc/pkg/cli/image/manifest"
 	"github.com/stretchr/testify/require"
 )
 
@@ -258,7 +262,7 @@ func TestAssociateLocalImageLayers(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			tmpdir := t.TempDir()
 			require.NoError(t, copyV2("testdata", tmpdir))
-			asSet, err := AssociateLocalImageLayers(tmpdir, test.imgMapping)
+			asSet, err := AssociateLocalImageLayers(context.TODO(), tmpdir, test.imgMapping, imagemanifest.ParallelOptions{MaxPerRegistry: 2})
 			if !test.wantErr {
 				require.NoError(t, err)
 				require.Equal(t, test.expResult, asSet)
@@ -269,12 +273,69 @@ func TestAssociateLocalImageLayers(t *testing.T) {
 	}
 }
 
+func TestAssociateImages(t *testing.T) {
+	parallel := imagemanifest.ParallelOptions{MaxPerRegistry: 2}
+	registries := []string{"quay.io", "registry.redhat.io"}
+	mapping := TypedImageMapping{}
+	for i := 0; i < 12; i++ {
+		src := TypedImage{
+			TypedImageReference: imagesource.TypedImageReference{
+				Ref:  reference.DockerImageReference{Registry: registries[i%len(registries)], Name: fmt.Sprintf("img%d", i), Tag: "latest"},
+				Type: imagesource.DestinationRegistry,
+			},
+			Category: v1alpha2.TypeGeneric,
+		}
+		mapping[src] = src
+	}
+	var mu sync.Mutex
+	inFlight, maxInFlight := map[string]int{}, map[string]int{}
+	associate := func(_ context.Context, srcImg, _ TypedImage, skipParse func(string) bool) (string, []v1alpha2.Association, error) {
+		mu.Lock()
+		inFlight[srcImg.Ref.Registry]++
+		if inFlight[srcImg.Ref.Registry] > maxInFlight[srcImg.Ref.Registry] {
+			maxInFlight[srcImg.Ref.Registry] = inFlight[srcImg.Ref.Registry]
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight[srcImg.Ref.Registry]--
+		mu.Unlock()
+
+		associations := []v1alpha2.Association{{Name: srcImg.String(), Type: v1alpha2.TypeGeneric}}
+		if srcImg.Ref.Name == "img0" {
+			// Partial associations of failed images are dropped
+			return srcImg.String(), associations, fmt.Errorf("invalid image %s", srcImg.Ref.Name)
+		}
+		require.False(t, skipParse(srcImg.String()))
+		return srcImg.String(), associations, nil
+	}
+
+	t.Run("Valid/AllImages", func(t *testing.T) {
+		asSet, err := associateImages(context.Background(), mapping, parallel, associate)
+		require.EqualError(t, err, "invalid image img0")
+		require.Len(t, asSet.Keys(), len(mapping)-1)
+		require.True(t, asSet.SetContainsKey("registry.redhat.io/img1:latest"))
+		require.False(t, asSet.SetContainsKey("quay.io/img0:latest"))
+		for _, registry := range registries {
+			require.LessOrEqual(t, maxInFlight[registry], parallel.MaxPerRegistry)
+		}
+	})
+	t.Run("Invalid/Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := associateImages(ctx, mapping, parallel, associate)
+		require.Error(t, err)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
 func TestAssociateRemoteImageLayers(t *testing.T) {
 
 	server := httptest.NewServer(mirrorV2("testdata"))
 	t.Cleanup(server.Close)
 	u, err := url.Parse(server.URL)
 	require.NoError(t, err)
+	clients := insecureClients(t, u.Host)
 
 	tests := []struct {
 		name       string
@@ -472,6 +533,45 @@ func TestAssociateRemoteImageLayers(t *testing.T) {
 				},
 			}},
 		},
+		{
+			name:   "Valid/FileDestination",
+			imgTyp: v1alpha2.TypeGeneric,
+			imgMapping: map[TypedImage]TypedImage{
+				{
+					TypedImageReference: imagesource.TypedImageReference{
+						Ref: reference.DockerImageReference{
+							Name:     "single_manifest",
+							Tag:      "latest",
+							Registry: u.Host,
+						}},
+					Category: v1alpha2.TypeGeneric}: {
+					TypedImageReference: imagesource.TypedImageReference{
+						Ref: reference.DockerImageReference{
+							Name: "single_manifest",
+							Tag:  "latest",
+						},
+						Type: imagesource.DestinationFile,
+					},
+					Category: v1alpha2.TypeGeneric}},
+			expResult: AssociationSet{fmt.Sprintf("%s/single_manifest@sha256:d31c6ea5c50be93d6eb94d2b508f0208e84a308c011c6454ebf291d48b37df19", u.Host): Associations{
+				fmt.Sprintf("%s/single_manifest@sha256:d31c6ea5c50be93d6eb94d2b508f0208e84a308c011c6454ebf291d48b37df19", u.Host): {
+					Name:            fmt.Sprintf("%s/single_manifest@sha256:d31c6ea5c50be93d6eb94d2b508f0208e84a308c011c6454ebf291d48b37df19", u.Host),
+					Path:            "single_manifest",
+					TagSymlink:      "latest",
+					ID:              "sha256:d31c6ea5c50be93d6eb94d2b508f0208e84a308c011c6454ebf291d48b37df19",
+					Type:            v1alpha2.TypeGeneric,
+					ManifestDigests: nil,
+					LayerDigests: []string{
+						"sha256:e8614d09b7bebabd9d8a450f44e88a8807c98a438a2ddd63146865286b132d1b",
+						"sha256:601401253d0aac2bc95cccea668761a6e69216468809d1cee837b2e8b398e241",
+						"sha256:211941188a4f55ffc6bcefa4f69b69b32c13fafb65738075de05808bbfcec086",
+						"sha256:f0fd5be261dfd2e36d01069a387a3e5125f5fd5adfec90f3cb190d1d5f1d1ad9",
+						"sha256:0c0beb258254c0566315c641b4107b080a96fa78d4f96833453dd6c5b9edf2b7",
+						"sha256:30c794a11b4c340c77238c5b7ca845752904bd8b74b73a9b16d31253234da031",
+					},
+				},
+			}},
+		},
 		{
 			name:   "Invalid/InvalidComponent",
 			imgTyp: v1alpha2.TypeGeneric,
@@ -495,7 +595,7 @@ func TestAssociateRemoteImageLayers(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			asSet, err := AssociateRemoteImageLayers(context.TODO(), test.imgMapping, true, true, false)
+			asSet, err := clients.AssociateRemoteImageLayers(context.TODO(), test.imgMapping, nil, true, true, false, imagemanifest.ParallelOptions{MaxPerRegistry: 2})
 			if !test.wantErr {
 				require.NoError(t, err)
 				require.Equal(t, test.expResult, asSet)
@@ -506,6 +606,79 @@ func TestAssociateRemoteImageLayers(t *testing.T) {
 	}
 }
 
+func TestAssociateRemoteImageLayersReuse(t *testing.T) {
+	var manifestGets int64
+	v2 := mirrorV2("testdata")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodGet && path.Base(path.Dir(req.URL.Path)) == "manifests" {
+			atomic.AddInt64(&manifestGets, 1)
+		}
+		v2.ServeHTTP(w, req)
+	}))
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	clients := insecureClients(t, u.Host)
+
+	id := "sha256:d31c6ea5c50be93d6eb94d2b508f0208e84a308c011c6454ebf291d48b37df19"
+	src := TypedImage{
+		TypedImageReference: imagesource.TypedImageReference{
+			Ref: reference.DockerImageReference{Name: "single_manifest", ID: id, Tag: "latest", Registry: u.Host},
+		},
+		Category: v1alpha2.TypeGeneric,
+	}
+	dst := TypedImage{
+		TypedImageReference: imagesource.TypedImageReference{
+			Ref:  reference.DockerImageReference{Name: "single_manifest", ID: id, Registry: "test-registry"},
+			Type: imagesource.DestinationRegistry,
+		},
+		Category: v1alpha2.TypeGeneric,
+	}
+	key := fmt.Sprintf("%s/single_manifest@%s", u.Host, id)
+	previous := func(id string) AssociationSet {
+		return AssociationSet{key: Associations{key: {
+			Name:         key,
+			Path:         "old-registry/single_manifest",
+			ID:           id,
+			Type:         v1alpha2.TypeGeneric,
+			LayerDigests: []string{"sha256:e8614d09b7bebabd9d8a450f44e88a8807c98a438a2ddd63146865286b132d1b"},
+		}}}
+	}
+
+	type spec struct {
+		name        string
+		prev        AssociationSet
+		expLayers   int
+		expManifest bool
+	}
+	cases := []spec{
+		{
+			name:      "Valid/Unchanged",
+			prev:      previous(id),
+			expLayers: 1,
+		},
+		{
+			name:        "Valid/Changed",
+			prev:        previous("sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8"),
+			expLayers:   6,
+			expManifest: true,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			atomic.StoreInt64(&manifestGets, 0)
+			assocs, errs := clients.AssociateRemoteImageLayers(context.TODO(), TypedImageMapping{src: dst}, c.prev, true, true, false, imagemanifest.ParallelOptions{MaxPerRegistry: 2})
+			require.NoError(t, errs)
+			assoc := assocs[key][key]
+			require.Equal(t, "test-registry/single_manifest@"+id, assoc.Path)
+			require.Equal(t, "latest", assoc.TagSymlink)
+			require.Len(t, assoc.LayerDigests, c.expLayers)
+			require.Equal(t, c.expManifest, atomic.LoadInt64(&manifestGets) != 0)
+		})
+	}
+}
+
 func mirrorV2(v2Dir string) http.HandlerFunc {
 	dir := http.Dir(v2Dir)
 	fileHandler := http.FileServer(dir)
@@ -563,3 +736,17 @@ func copyV2(source, destination string) error {
 	})
 	return err
 }
+
+func TestReadManifestFile(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "manifest")
+	require.NoError(t, ioutil.WriteFile(manifest, []byte(`{"schemaVersion":2}`), 0600))
+	data, err := readManifestFile(manifest)
+	require.NoError(t, err)
+	require.Equal(t, `{"schemaVersion":2}`, string(data))
+
+	blob := filepath.Join(dir, "blob")
+	require.NoError(t, ioutil.WriteFile(blob, make([]byte, maxManifestSize+1), 0600))
+	_, err = readManifestFile(blob)
+	require.EqualError(t, err, fmt.Sprintf("manifest %s exceeds the maximum manifest size of 4194304 bytes", blob))
+}
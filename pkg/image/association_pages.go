@@ -0,0 +1,121 @@
+package image
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// AssociationPages iterates over the images of a slice of Associations
+// in pages, so an AssociationSet is only built for the images of
+// the current page instead of for all the images at once.
+// Images are paged by type, releases first, then by name.
+type AssociationPages struct {
+	assocs []v1alpha2.Association
+	// images holds the names of the top level images in page order
+	images []string
+	// members holds the indexes of the associations of each image,
+	// its own and those of its child manifests
+	members  map[string][]int
+	pageSize int
+	next     int
+}
+
+// NewAssociationPages returns AssociationPages over the associations with
+// pages of up to pageSize images. The associations are validated and the
+// child manifests of every index resolved up front, so a page cannot fail
+// after previous pages were processed.
+func NewAssociationPages(assocs []v1alpha2.Association, pageSize int) (*AssociationPages, error) {
+	if pageSize < 1 {
+		return nil, fmt.Errorf("invalid page size %d", pageSize)
+	}
+
+	// Associations are unique by name and path, as in ConvertToAssociationSet
+	byKey := make(map[string]int, len(assocs))
+	var errs []error
+	for i, a := range assocs {
+		if err := a.Validate(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		byKey[a.Name+a.Path] = i
+	}
+	if len(errs) != 0 {
+		return nil, utilerrors.NewAggregate(errs)
+	}
+
+	p := &AssociationPages{
+		assocs:   assocs,
+		members:  map[string][]int{},
+		pageSize: pageSize,
+	}
+	visited := make(map[string]struct{}, len(byKey))
+	for _, i := range byKey {
+		value := assocs[i]
+		if len(value.ManifestDigests) == 0 {
+			continue
+		}
+		p.members[value.Name] = append(p.members[value.Name], i)
+		for _, digest := range value.ManifestDigests {
+			child, ok := byKey[digest+value.Path]
+			if !ok {
+				return nil, fmt.Errorf("invalid associations: association for %q is missing", digest)
+			}
+			p.members[value.Name] = append(p.members[value.Name], child)
+			visited[assocs[child].Name] = struct{}{}
+		}
+		visited[value.Name] = struct{}{}
+	}
+	for _, i := range byKey {
+		if _, found := visited[assocs[i].Name]; !found {
+			p.members[assocs[i].Name] = append(p.members[assocs[i].Name], i)
+		}
+	}
+
+	types := make(map[string]v1alpha2.ImageType, len(p.members))
+	for name, members := range p.members {
+		p.images = append(p.images, name)
+		types[name] = assocs[members[0]].Type
+		for _, i := range members {
+			if assocs[i].Name == name {
+				types[name] = assocs[i].Type
+				break
+			}
+		}
+	}
+	sort.Slice(p.images, func(i, j int) bool {
+		ti, tj := types[p.images[i]], types[p.images[j]]
+		if ti != tj {
+			return ti < tj
+		}
+		return p.images[i] < p.images[j]
+	})
+	return p, nil
+}
+
+// Len returns the number of images of all the pages
+func (p *AssociationPages) Len() int {
+	return len(p.images)
+}
+
+// Next returns the AssociationSet of the next page of images.
+// It returns false when all the pages have been returned.
+func (p *AssociationPages) Next() (AssociationSet, bool) {
+	if p.next >= len(p.images) {
+		return nil, false
+	}
+	end := p.next + p.pageSize
+	if end > len(p.images) {
+		end = len(p.images)
+	}
+	page := AssociationSet{}
+	for _, name := range p.images[p.next:end] {
+		for _, i := range p.members[name] {
+			page.Add(name, p.assocs[i])
+		}
+	}
+	p.next = end
+	return page, true
+}
@@ -0,0 +1,102 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+func TestAssociationPages(t *testing.T) {
+	child := "sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8"
+	index := v1alpha2.Association{
+		Name:            "registry.redhat.io/ubi8/ubi:latest",
+		Path:            "ubi8/ubi",
+		ID:              "sha256:d31c6ea5c50be93d6eb94d2b508f0208e84a308c011c6454ebf291d48b37df19",
+		TagSymlink:      "latest",
+		Type:            v1alpha2.TypeGeneric,
+		ManifestDigests: []string{child},
+	}
+	manifest := v1alpha2.Association{
+		Name:         child,
+		Path:         "ubi8/ubi",
+		ID:           child,
+		Type:         v1alpha2.TypeGeneric,
+		LayerDigests: []string{"sha256:c8ebd4e0a2a5a8b2bbcf1a9c3ba0d5d6c2e1a0b3f9e4d8c7b6a5f4e3d2c1b0a9"},
+	}
+	release := v1alpha2.Association{
+		Name:         "quay.io/openshift-release-dev/ocp-release:4.9.0-x86_64",
+		Path:         "openshift/release",
+		ID:           "sha256:1f1a1e1d1c1b1a19181716151413121110f0e0d0c0b0a0908070605040302010",
+		TagSymlink:   "4.9.0-x86_64",
+		Type:         v1alpha2.TypeOCPRelease,
+		LayerDigests: []string{"sha256:c8ebd4e0a2a5a8b2bbcf1a9c3ba0d5d6c2e1a0b3f9e4d8c7b6a5f4e3d2c1b0a9"},
+	}
+
+	type spec struct {
+		name     string
+		assocs   []v1alpha2.Association
+		pageSize int
+		exp      [][]string
+		expError string
+	}
+	cases := []spec{
+		{
+			name:     "Valid/OnePerPage",
+			assocs:   []v1alpha2.Association{manifest, index, release},
+			pageSize: 1,
+			exp:      [][]string{{release.Name}, {index.Name}},
+		},
+		{
+			name:     "Valid/SinglePage",
+			assocs:   []v1alpha2.Association{manifest, index, release},
+			pageSize: 50,
+			exp:      [][]string{{release.Name, index.Name}},
+		},
+		{
+			name:     "Invalid/MissingChild",
+			assocs:   []v1alpha2.Association{index},
+			pageSize: 1,
+			expError: `invalid associations: association for "` + child + `" is missing`,
+		},
+		{
+			name:     "Invalid/PageSize",
+			assocs:   []v1alpha2.Association{release},
+			expError: "invalid page size 0",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			pages, err := NewAssociationPages(c.assocs, c.pageSize)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, 2, pages.Len())
+
+			var got [][]string
+			for {
+				page, ok := pages.Next()
+				if !ok {
+					break
+				}
+				var keys []string
+				for _, key := range []string{release.Name, index.Name} {
+					if page.SetContainsKey(key) {
+						keys = append(keys, key)
+					}
+				}
+				require.Len(t, page, len(keys))
+				got = append(got, keys)
+				// Child manifests are paged with their index
+				if page.SetContainsKey(index.Name) {
+					require.True(t, page.ContainsKey(index.Name, child))
+				}
+			}
+			require.Equal(t, c.exp, got)
+		})
+	}
+}
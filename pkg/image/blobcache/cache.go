@@ -0,0 +1,254 @@
+// Package blobcache stores image blobs by digest in a directory shared
+// across runs, so blobs downloaded by one run are reused by later runs.
+package blobcache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/openshift/oc-mirror/pkg/image/blobfile"
+)
+
+const (
+	// blobsDir is the directory of the cache holding blobs by algorithm and encoded digest
+	blobsDir = "blobs"
+	// tmpPrefix is the prefix of the temporary files of blobs being added
+	tmpPrefix = ".tmp-"
+)
+
+// staleTmpAge is the age at which temporary files left
+// by interrupted runs are removed when pruning
+var staleTmpAge = 24 * time.Hour
+
+// Cache is a content-addressed blob cache directory.
+// Blobs are hard linked in and out of the cache when it is on the same
+// filesystem as the workspace, and cloned with a reflink on
+// filesystems that support them or copied otherwise.
+type Cache struct {
+	dir string
+	// maxSize is the size the cache is pruned to, or zero for no limit
+	maxSize int64
+}
+
+// New returns the cache in dir, creating it if it does not exist
+func New(dir string, maxSize int64) (*Cache, error) {
+	if maxSize < 0 {
+		return nil, fmt.Errorf("cache max size %d must not be negative", maxSize)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, blobsDir), 0750); err != nil {
+		return nil, fmt.Errorf("error creating blob cache %s: %v", dir, err)
+	}
+	return &Cache{dir: dir, maxSize: maxSize}, nil
+}
+
+// Dir returns the directory of the cache
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// path returns the path of the blob in the cache
+func (c *Cache) path(dgst string) (string, error) {
+	d, err := digest.Parse(dgst)
+	if err != nil {
+		return "", fmt.Errorf("invalid blob digest %q: %v", dgst, err)
+	}
+	return filepath.Join(c.dir, blobsDir, d.Algorithm().String(), d.Encoded()), nil
+}
+
+// Link places the cached blob with the digest at dst and returns its size,
+// or false if the blob is not cached. Existing files at dst are kept.
+func (c *Cache) Link(dgst, dst string) (int64, bool, error) {
+	src, err := c.path(dgst)
+	if err != nil {
+		return 0, false, err
+	}
+	info, err := os.Stat(src)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return 0, false, nil
+	}
+	if err := place(src, dst, dgst); err != nil {
+		if errors.Is(err, errDigestMismatch) {
+			// Drop corrupted blobs so they are downloaded again
+			return 0, false, os.Remove(src)
+		}
+		return 0, false, err
+	}
+	// Blobs are evicted least recently used first
+	now := time.Now()
+	if err := os.Chtimes(src, now, now); err != nil {
+		return 0, false, err
+	}
+	return info.Size(), true, nil
+}
+
+// Add adds the blob at src with the digest to the cache
+// and returns false if the blob was already cached
+func (c *Cache) Add(dgst, src string) (bool, error) {
+	dst, err := c.path(dgst)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(dst); err == nil {
+		now := time.Now()
+		return false, os.Chtimes(dst, now, now)
+	}
+	if err := place(src, dst, dgst); err != nil {
+		return false, fmt.Errorf("error caching blob %s: %v", dgst, err)
+	}
+	return true, nil
+}
+
+var errDigestMismatch = errors.New("digest mismatch")
+
+// place hard links src to dst, or clones or copies it if it cannot be linked.
+// Copies are verified against the digest. The file appears at dst atomically.
+func place(src, dst, dgst string) error {
+	dir := filepath.Dir(dst)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, tmpPrefix)
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Remove(tmpPath); err != nil {
+		return err
+	}
+	if err := os.Link(src, tmpPath); err != nil {
+		// Links fail across filesystems and on filesystems without hard links,
+		// where blobs are cloned if the filesystem supports reflinks
+		switch err := blobfile.Clone(src, tmpPath); {
+		case errors.Is(err, blobfile.ErrCloneNotSupported):
+			if err := copyVerified(src, tmpPath, dgst); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		}
+	}
+	return os.Rename(tmpPath, dst)
+}
+
+// copyVerified copies src to dst and checks that the content matches the digest
+func copyVerified(src, dst, dgst string) error {
+	expected, err := digest.Parse(dgst)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(filepath.Clean(src))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(filepath.Clean(dst), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	verifier := expected.Verifier()
+	if _, err := io.Copy(io.MultiWriter(out, verifier), in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if !verifier.Verified() {
+		return fmt.Errorf("%w: %s does not match %s", errDigestMismatch, src, dgst)
+	}
+	return nil
+}
+
+// PruneStats are the blobs removed and kept by Prune
+type PruneStats struct {
+	// RemovedBlobs and RemovedBytes count the evicted blobs
+	RemovedBlobs int
+	RemovedBytes int64
+	// Blobs and Bytes count the blobs left in the cache
+	Blobs int
+	Bytes int64
+}
+
+// Prune evicts the least recently used blobs until the cache
+// fits in its max size, and removes stale temporary files
+func (c *Cache) Prune() (PruneStats, error) {
+	type entry struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var stats PruneStats
+	var entries []entry
+	root := filepath.Join(c.dir, blobsDir)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Blobs removed by concurrent runs are skipped
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), tmpPrefix) {
+			if time.Since(info.ModTime()) > staleTmpAge {
+				return os.Remove(path)
+			}
+			return nil
+		}
+		entries = append(entries, entry{path: path, size: info.Size(), modTime: info.ModTime()})
+		stats.Blobs++
+		stats.Bytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("error reading blob cache %s: %v", c.dir, err)
+	}
+	if c.maxSize == 0 || stats.Bytes <= c.maxSize {
+		return stats, nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+	for _, e := range entries {
+		if stats.Bytes <= c.maxSize {
+			break
+		}
+		if err := os.Remove(e.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return stats, fmt.Errorf("error evicting blob %s: %v", e.path, err)
+		}
+		stats.Blobs--
+		stats.Bytes -= e.size
+		stats.RemovedBlobs++
+		stats.RemovedBytes += e.size
+	}
+	return stats, nil
+}
@@ -0,0 +1,115 @@
+package blobcache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func writeBlob(t *testing.T, dir, content string) (string, string) {
+	dgst := digest.FromString(content).String()
+	path := filepath.Join(dir, dgst)
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return dgst, path
+}
+
+func TestCache(t *testing.T) {
+	src := t.TempDir()
+	c, err := New(filepath.Join(t.TempDir(), "cache"), 0)
+	require.NoError(t, err)
+
+	layer, layerPath := writeBlob(t, src, "layer")
+	added, err := c.Add(layer, layerPath)
+	require.NoError(t, err)
+	require.True(t, added)
+	added, err = c.Add(layer, layerPath)
+	require.NoError(t, err)
+	require.False(t, added)
+
+	dst := filepath.Join(t.TempDir(), "v2", "ubi8", "ubi", "blobs", layer)
+	size, linked, err := c.Link(layer, dst)
+	require.NoError(t, err)
+	require.True(t, linked)
+	require.Equal(t, int64(len("layer")), size)
+	data, err := ioutil.ReadFile(dst)
+	require.NoError(t, err)
+	require.Equal(t, "layer", string(data))
+
+	// Blobs already in the destination are kept
+	_, linked, err = c.Link(layer, dst)
+	require.NoError(t, err)
+	require.False(t, linked)
+
+	_, linked, err = c.Link(digest.FromString("missing").String(), filepath.Join(t.TempDir(), "missing"))
+	require.NoError(t, err)
+	require.False(t, linked)
+
+	_, err = c.Add("sha256:../../etc", layerPath)
+	require.Error(t, err)
+}
+
+func TestCopyVerified(t *testing.T) {
+	dir := t.TempDir()
+	layer, layerPath := writeBlob(t, dir, "layer")
+	require.NoError(t, copyVerified(layerPath, filepath.Join(dir, "copy"), layer))
+
+	other := digest.FromString("other").String()
+	err := copyVerified(layerPath, filepath.Join(dir, "corrupt"), other)
+	require.ErrorIs(t, err, errDigestMismatch)
+}
+
+func TestPrune(t *testing.T) {
+	src := t.TempDir()
+	c, err := New(t.TempDir(), 10)
+	require.NoError(t, err)
+
+	// Blobs are evicted least recently used first
+	now := time.Now()
+	var digests []string
+	for i, content := range []string{"aaaa", "bbbb", "cccc", "dddd"} {
+		dgst, path := writeBlob(t, src, content)
+		_, err := c.Add(dgst, path)
+		require.NoError(t, err)
+		cachePath, err := c.path(dgst)
+		require.NoError(t, err)
+		modTime := now.Add(time.Duration(i-10) * time.Minute)
+		require.NoError(t, os.Chtimes(cachePath, modTime, modTime))
+		digests = append(digests, dgst)
+	}
+	// Using a blob makes it the most recently used
+	_, linked, err := c.Link(digests[0], filepath.Join(t.TempDir(), digests[0]))
+	require.NoError(t, err)
+	require.True(t, linked)
+
+	stale := filepath.Join(c.dir, blobsDir, "sha256", tmpPrefix+"stale")
+	require.NoError(t, ioutil.WriteFile(stale, []byte("partial"), 0600))
+	staleTime := now.Add(-2 * staleTmpAge)
+	require.NoError(t, os.Chtimes(stale, staleTime, staleTime))
+
+	stats, err := c.Prune()
+	require.NoError(t, err)
+	require.Equal(t, PruneStats{RemovedBlobs: 2, RemovedBytes: 8, Blobs: 2, Bytes: 8}, stats)
+	for i, dgst := range digests {
+		path, err := c.path(dgst)
+		require.NoError(t, err)
+		_, err = os.Stat(path)
+		if i == 1 || i == 2 {
+			require.True(t, os.IsNotExist(err), dgst)
+		} else {
+			require.NoError(t, err, dgst)
+		}
+	}
+	_, err = os.Stat(stale)
+	require.True(t, os.IsNotExist(err))
+
+	// Caches without a max size are not pruned
+	c.maxSize = 0
+	stats, err = c.Prune()
+	require.NoError(t, err)
+	require.Equal(t, PruneStats{Blobs: 2, Bytes: 8}, stats)
+}
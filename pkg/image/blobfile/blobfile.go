@@ -0,0 +1,108 @@
+// Package blobfile places blob files without copying their content
+// when the filesystem allows it.
+package blobfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Method is how a blob file was placed
+type Method int
+
+const (
+	// Linked files are hard links to the source file
+	Linked Method = iota
+	// Cloned files share the extents of the source file
+	// through a reflink until either file is modified
+	Cloned
+	// Copied files are copies of the content of the source file
+	Copied
+)
+
+func (m Method) String() string {
+	switch m {
+	case Linked:
+		return "linked"
+	case Cloned:
+		return "cloned"
+	default:
+		return "copied"
+	}
+}
+
+// ErrCloneNotSupported is returned by Clone when
+// the platform or filesystem does not support reflinks
+var ErrCloneNotSupported = errors.New("reflinks are not supported")
+
+// Clone creates the file dst as a reflink of src, sharing its extents
+// until either file is modified. It returns ErrCloneNotSupported when
+// the filesystem of the files does not support reflinks.
+func Clone(src, dst string) error {
+	return clone(src, dst)
+}
+
+// Place places the file at src at dst by hard linking it, cloning it with
+// a reflink when it cannot be linked, such as across btrfs subvolumes, and
+// copying it otherwise. Blobs are immutable, so sharing their content is safe.
+// The file appears at dst atomically, replacing any existing file.
+func Place(src, dst string) (Method, error) {
+	dir := filepath.Dir(dst)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return Copied, err
+	}
+	tmp, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return Copied, err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+	if err := tmp.Close(); err != nil {
+		return Copied, err
+	}
+
+	method, err := place(src, tmpPath)
+	if err != nil {
+		return method, err
+	}
+	return method, os.Rename(tmpPath, dst)
+}
+
+// place places src at the temporary file dst
+func place(src, dst string) (Method, error) {
+	if err := os.Remove(dst); err != nil {
+		return Linked, err
+	}
+	if err := os.Link(src, dst); err == nil {
+		return Linked, nil
+	}
+	switch err := clone(src, dst); {
+	case err == nil:
+		return Cloned, nil
+	case !errors.Is(err, ErrCloneNotSupported):
+		return Cloned, err
+	}
+	return Copied, copyFile(src, dst)
+}
+
+// copyFile copies the content of src to the new file dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(filepath.Clean(src))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(filepath.Clean(dst), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("error copying %s: %v", src, err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,58 @@
+package blobfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlace(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	require.NoError(t, ioutil.WriteFile(src, []byte("blob"), 0600))
+
+	// Files on the same filesystem are linked
+	dst := filepath.Join(dir, "v2", "ubi8", "ubi", "blobs", "blob")
+	method, err := Place(src, dst)
+	require.NoError(t, err)
+	require.Equal(t, Linked, method)
+	srcInfo, err := os.Stat(src)
+	require.NoError(t, err)
+	dstInfo, err := os.Stat(dst)
+	require.NoError(t, err)
+	require.True(t, os.SameFile(srcInfo, dstInfo))
+
+	// Existing files are replaced
+	other := filepath.Join(dir, "other")
+	require.NoError(t, ioutil.WriteFile(other, []byte("other"), 0600))
+	_, err = Place(other, dst)
+	require.NoError(t, err)
+	data, err := ioutil.ReadFile(dst)
+	require.NoError(t, err)
+	require.Equal(t, "other", string(data))
+
+	// No temporary files are left behind
+	entries, err := ioutil.ReadDir(filepath.Dir(dst))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	_, err = Place(filepath.Join(dir, "missing"), dst)
+	require.Error(t, err)
+}
+
+func TestCopyFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	require.NoError(t, ioutil.WriteFile(src, []byte("blob"), 0600))
+	dst := filepath.Join(dir, "dst")
+	require.NoError(t, copyFile(src, dst))
+	data, err := ioutil.ReadFile(dst)
+	require.NoError(t, err)
+	require.Equal(t, "blob", string(data))
+
+	// Copies never write to existing files
+	require.Error(t, copyFile(src, dst))
+}
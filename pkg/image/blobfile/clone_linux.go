@@ -0,0 +1,39 @@
+package blobfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// clone creates dst as a reflink of src with the FICLONE ioctl,
+// supported by filesystems such as XFS and btrfs
+func clone(src, dst string) error {
+	in, err := os.Open(filepath.Clean(src))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(filepath.Clean(dst), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	cerr := unix.IoctlFileClone(int(out.Fd()), int(in.Fd()))
+	if err := out.Close(); err != nil && cerr == nil {
+		cerr = err
+	}
+	if cerr == nil {
+		return nil
+	}
+	if err := os.Remove(dst); err != nil {
+		return err
+	}
+	// Filesystems without reflinks, and sources on other filesystems
+	if errors.Is(cerr, unix.EOPNOTSUPP) || errors.Is(cerr, unix.ENOTTY) ||
+		errors.Is(cerr, unix.EXDEV) || errors.Is(cerr, unix.EINVAL) || errors.Is(cerr, unix.ENOSYS) {
+		return ErrCloneNotSupported
+	}
+	return cerr
+}
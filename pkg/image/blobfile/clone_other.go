@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package blobfile
+
+// clone returns ErrCloneNotSupported because
+// reflinks are only supported on Linux
+func clone(src, dst string) error {
+	return ErrCloneNotSupported
+}
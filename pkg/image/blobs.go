@@ -0,0 +1,138 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	ctrsimgmanifest "github.com/containers/image/v5/manifest"
+	"github.com/docker/distribution"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/openshift/library-go/pkg/image/reference"
+	"github.com/openshift/oc/pkg/cli/image/imagesource"
+)
+
+// ImageBlobs are the sizes of the blobs referenced
+// by the manifests of an image by digest
+type ImageBlobs struct {
+	// Layers are the sizes of the layer blobs
+	Layers map[string]int64
+	// Configs are the sizes of the image config blobs
+	Configs map[string]int64
+}
+
+// Size returns the total size of the blobs
+func (b ImageBlobs) Size() int64 {
+	var size int64
+	for _, s := range b.Layers {
+		size += s
+	}
+	for _, s := range b.Configs {
+		size += s
+	}
+	return size
+}
+
+// BlobSizes are the sizes of the blobs of a set of images by digest.
+// Blobs shared between images are counted once.
+type BlobSizes map[string]int64
+
+// Add adds the blobs of the image
+func (s BlobSizes) Add(img ImageBlobs) {
+	for digest, size := range img.Layers {
+		s[digest] = size
+	}
+	for digest, size := range img.Configs {
+		s[digest] = size
+	}
+}
+
+// Size returns the total size of the blobs
+func (s BlobSizes) Size() int64 {
+	var size int64
+	for _, blobSize := range s {
+		size += blobSize
+	}
+	return size
+}
+
+// GetRemoteImageBlobs queries the manifests of a remote image for the sizes of its blobs.
+// The blobs of every manifest of a manifest list or index are included.
+func (c *Clients) GetRemoteImageBlobs(ctx context.Context, ref reference.DockerImageReference, skipTLS, plainHTTP, skipVerification bool) (ImageBlobs, error) {
+	blobs := ImageBlobs{Layers: map[string]int64{}, Configs: map[string]int64{}}
+	insecure := skipTLS || plainHTTP
+
+	if ref.ID == "" {
+		if ref.Tag == "" {
+			return blobs, &ErrInvalidComponent{ref.Exact(), ref.Tag}
+		}
+		resolver := c.Resolver(skipTLS, plainHTTP)
+		imgWithID, err := ResolveToPin(ctx, resolver, ref.Exact())
+		if err != nil {
+			return blobs, err
+		}
+		pinnedRef, err := imagesource.ParseReference(imgWithID)
+		if err != nil {
+			return blobs, fmt.Errorf("error parsing source image %s: %v", imgWithID, err)
+		}
+		ref.ID = pinnedRef.Ref.ID
+	}
+
+	regctx, err := c.Context(skipVerification)
+	if err != nil {
+		return blobs, fmt.Errorf("error creating registry context: %v", err)
+	}
+	repo, err := regctx.RepositoryForRef(ctx, ref, insecure)
+	if err != nil {
+		return blobs, fmt.Errorf("create repo for %s: %v", ref.Exact(), err)
+	}
+	ms, err := repo.Manifests(ctx)
+	if err != nil {
+		return blobs, fmt.Errorf("open blob: %v", err)
+	}
+	return blobs, addManifestBlobs(ctx, ms, ref.ID, blobs)
+}
+
+// addManifestBlobs adds the blobs of the manifest with the digest
+// and of its child manifests to blobs
+func addManifestBlobs(ctx context.Context, ms distribution.ManifestService, id string, blobs ImageBlobs) error {
+	dgst, err := digest.Parse(id)
+	if err != nil {
+		return err
+	}
+	mn, err := ms.Get(ctx, dgst, preferManifestList)
+	if err != nil {
+		return fmt.Errorf("error getting manifest %s: %v", dgst, err)
+	}
+	mt, payload, err := mn.Payload()
+	if err != nil {
+		return err
+	}
+
+	switch mt {
+	case "":
+		return errors.New("unparseable manifest mediaType")
+	case imgspecv1.MediaTypeImageIndex, ctrsimgmanifest.DockerV2ListMediaType:
+		list, err := ctrsimgmanifest.ListFromBlob(payload, mt)
+		if err != nil {
+			return err
+		}
+		for _, instance := range list.Instances() {
+			if err := addManifestBlobs(ctx, ms, instance.String(), blobs); err != nil {
+				return err
+			}
+		}
+	default:
+		manifest, err := ctrsimgmanifest.FromBlob(payload, mt)
+		if err != nil {
+			return err
+		}
+		for _, layerInfo := range manifest.LayerInfos() {
+			blobs.Layers[layerInfo.Digest.String()] = layerInfo.Size
+		}
+		config := manifest.ConfigInfo()
+		blobs.Configs[config.Digest.String()] = config.Size
+	}
+	return nil
+}
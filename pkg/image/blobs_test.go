@@ -0,0 +1,88 @@
+package image
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/openshift/library-go/pkg/image/reference"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRemoteImageBlobs(t *testing.T) {
+
+	server := httptest.NewServer(mirrorV2("testdata"))
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	clients := insecureClients(t, u.Host)
+
+	type spec struct {
+		name       string
+		ref        reference.DockerImageReference
+		expLayers  int
+		expConfigs int
+		expSize    int64
+		expError   string
+	}
+
+	cases := []spec{
+		{
+			name: "Valid/ManifestWithTag",
+			ref: reference.DockerImageReference{
+				Registry: u.Host,
+				Name:     "single_manifest",
+				Tag:      "latest",
+			},
+			expLayers:  5,
+			expConfigs: 1,
+			expSize:    18950939,
+		},
+		{
+			name: "Valid/ManifestWithDigest",
+			ref: reference.DockerImageReference{
+				Registry: u.Host,
+				Name:     "single_manifest",
+				ID:       "sha256:d31c6ea5c50be93d6eb94d2b508f0208e84a308c011c6454ebf291d48b37df19",
+			},
+			expLayers:  5,
+			expConfigs: 1,
+			expSize:    18950939,
+		},
+		{
+			name: "Valid/IndexManifest",
+			ref: reference.DockerImageReference{
+				Registry: u.Host,
+				Name:     "index_manifest",
+				ID:       "sha256:d15a206e4ee462e82ab722ed84dfa514ab9ed8d85100d591c04314ae7c2162ee",
+			},
+			expLayers:  28,
+			expConfigs: 4,
+			expSize:    96986227,
+		},
+		{
+			name: "Invalid/NoTagOrDigest",
+			ref: reference.DockerImageReference{
+				Registry: u.Host,
+				Name:     "single_manifest",
+			},
+			expError: `has invalid component ""`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			blobs, err := clients.GetRemoteImageBlobs(context.TODO(), c.ref, false, true, true)
+			if c.expError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, blobs.Layers, c.expLayers)
+			require.Len(t, blobs.Configs, c.expConfigs)
+			require.Equal(t, c.expSize, blobs.Size())
+		})
+	}
+}
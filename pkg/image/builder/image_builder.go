@@ -2,7 +2,6 @@ package builder
 
 import (
 	"archive/tar"
-	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -148,16 +147,30 @@ func (b *ImageBuilder) CreateLayout(srcRef, dir string) (layout.Path, error) {
 }
 
 // LayerFromFile will write the contents of the path(s) the target
-// directory and build a v1.Layer
+// directory and build a v1.Layer. The layer tar is streamed from the
+// files each time the layer is read instead of being held in memory,
+// so the files must not change while the layer is in use.
 func LayerFromPath(targetPath, path string) (v1.Layer, error) {
-	var b bytes.Buffer
-	tw := tar.NewWriter(&b)
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(writeLayerTar(pw, targetPath, path))
+		}()
+		return pr, nil
+	})
+}
+
+// writeLayerTar writes a tar of the path(s) under the target directory to w
+func writeLayerTar(w io.Writer, targetPath, path string) error {
+	tw := tar.NewWriter(w)
 
 	pathInfo, err := os.Stat(path)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
 	processPaths := func(hdr *tar.Header, info os.FileInfo, fp string) error {
 		if !info.IsDir() {
 			hdr.Size = info.Size()
@@ -211,7 +224,7 @@ func LayerFromPath(targetPath, path string) (v1.Layer, error) {
 			return nil
 		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan files: %w", err)
+			return fmt.Errorf("failed to scan files: %w", err)
 		}
 
 	} else {
@@ -221,12 +234,12 @@ func LayerFromPath(targetPath, path string) (v1.Layer, error) {
 			Mode: int64(pathInfo.Mode()),
 		}
 		if err := processPaths(hdr, pathInfo, path); err != nil {
-			return nil, err
+			return err
 		}
 	}
 
 	if err := tw.Close(); err != nil {
-		return nil, fmt.Errorf("failed to finish tar: %w", err)
+		return fmt.Errorf("failed to finish tar: %w", err)
 	}
-	return tarball.LayerFromReader(&b)
+	return nil
 }
@@ -1,8 +1,11 @@
 package builder
 
 import (
+	"archive/tar"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http/httptest"
 	"net/url"
@@ -148,6 +151,7 @@ func TestLayoutFromPath(t *testing.T) {
 	tests := []struct {
 		name       string
 		dir        bool
+		missing    bool
 		targetPath string
 		err        string
 	}{
@@ -161,6 +165,12 @@ func TestLayoutFromPath(t *testing.T) {
 			targetPath: "testfile",
 			dir:        false,
 		},
+		{
+			name:       "Invalid/MissingPath",
+			targetPath: "testfile",
+			missing:    true,
+			err:        "stat missing: no such file or directory",
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -172,9 +182,12 @@ func TestLayoutFromPath(t *testing.T) {
 			require.NoError(t, ioutil.WriteFile(filepath.Join(tmpdir, "test"), d1, 0644))
 
 			var sourcePath string
-			if test.dir {
+			switch {
+			case test.missing:
+				sourcePath = "missing"
+			case test.dir:
 				sourcePath = tmpdir
-			} else {
+			default:
 				sourcePath = filepath.Join(tmpdir, "test")
 			}
 
@@ -184,6 +197,25 @@ func TestLayoutFromPath(t *testing.T) {
 				digest, err := layer.Digest()
 				require.NoError(t, err)
 				require.Contains(t, digest.String(), ":")
+
+				// The layer is streamed from the files on each read
+				rc, err := layer.Uncompressed()
+				require.NoError(t, err)
+				tr := tar.NewReader(rc)
+				var names []string
+				for {
+					hdr, err := tr.Next()
+					if errors.Is(err, io.EOF) {
+						break
+					}
+					require.NoError(t, err)
+					names = append(names, hdr.Name)
+				}
+				require.NoError(t, rc.Close())
+				require.Contains(t, names, filepath.Join(test.targetPath, "test"))
+				diffID, err := layer.DiffID()
+				require.NoError(t, err)
+				require.Equal(t, diffID, mustDiffID(t, layer))
 			} else {
 				require.EqualError(t, err, test.err)
 			}
@@ -191,6 +223,16 @@ func TestLayoutFromPath(t *testing.T) {
 	}
 }
 
+// mustDiffID computes the diff ID of the layer from its uncompressed contents
+func mustDiffID(t *testing.T, layer v1.Layer) v1.Hash {
+	rc, err := layer.Uncompressed()
+	require.NoError(t, err)
+	defer rc.Close()
+	h, _, err := v1.SHA256(rc)
+	require.NoError(t, err)
+	return h
+}
+
 func prepareImage(t *testing.T, dir string) string {
 	server := httptest.NewServer(registry.New())
 	t.Cleanup(server.Close)
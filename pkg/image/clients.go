@@ -0,0 +1,240 @@
+package image
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/remotes"
+	"github.com/containerd/containerd/remotes/docker"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/openshift/library-go/pkg/image/registryclient"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+
+	"github.com/openshift/oc-mirror/pkg/httplog"
+)
+
+// Clients creates the registry clients of a run.
+// The clients share connection pools, credentials, and TLS and proxy
+// configuration, and registry contexts are reused so the auth tokens
+// they cache are only requested once per registry and scope.
+// Requests to a registry share the limits set when it throttles clients.
+// Bearer tokens are renewed before they expire, so long runs do not fail
+// when the tokens the registry libraries cache expire.
+// Cloud registries without credentials in the docker config are
+// authenticated with the credential helper of their provider.
+// Registries configured with a credential helper or Vault secret
+// are authenticated with it instead of the docker config.
+// Registries configured with SetRegistries are connected to with their
+// own TLS and proxy options, at their own addresses, and under the path
+// prefix of their registry API.
+// Blob upload sessions are recorded with RecordUploads, so the sessions
+// of interrupted runs can be aborted with AbortUpload.
+type Clients struct {
+	mu         sync.Mutex
+	transports map[bool]http.RoundTripper
+	contexts   map[bool]*registryclient.Context
+	limits     *hostLimits
+	tokens     *bearerTokens
+	cloud      *cloudCredentials
+	external   *externalCredentials
+	// hostTLS holds the TLS options of registries by host
+	hostTLS map[string]*tls.Config
+	// insecureHosts holds the registries the insecure options apply to,
+	// none when it is empty
+	insecureHosts map[string]struct{}
+	// proxies holds the proxies of registries by host
+	proxies map[string]*url.URL
+	// addresses holds the addresses connections to registries go to by host
+	addresses map[string][]string
+	// pathPrefixes holds the path prefixes of the registry API by host
+	pathPrefixes map[string]string
+	// rootCAs holds the CA bundles of all the registries
+	rootCAs *x509.CertPool
+	// uploads records the upload sessions while uploads are recorded
+	uploads *uploadJournal
+}
+
+var sharedClients = NewClients()
+
+// SharedClients returns the registry clients shared by the subsystems of `oc mirror`
+func SharedClients() *Clients {
+	return sharedClients
+}
+
+// NewClients returns a registry client factory
+// that does not share clients with other factories
+func NewClients() *Clients {
+	return &Clients{
+		transports: map[bool]http.RoundTripper{},
+		contexts:   map[bool]*registryclient.Context{},
+		limits:     newHostLimits(),
+		tokens:     newBearerTokens(),
+		cloud:      newCloudCredentials(),
+		external:   newExternalCredentials(nil),
+	}
+}
+
+// Transport returns the transport for registries,
+// which skips TLS verification when insecure is true
+func (c *Clients) Transport(insecure bool) http.RoundTripper {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.transport(insecure)
+}
+
+func (c *Clients) transport(insecure bool) http.RoundTripper {
+	if rt, ok := c.transports[insecure]; ok {
+		return rt
+	}
+	throttle := &throttleTransport{base: c.newBaseTransport(insecure), hosts: c.limits}
+	refresh := &tokenRefreshTransport{base: throttle, tokens: c.tokens}
+	uploads := &uploadTransport{base: refresh, clients: c, insecure: insecure}
+	rt := httplog.Wrap(transport.NewUserAgentRoundTripper(rest.DefaultKubernetesUserAgent(), uploads))
+	c.transports[insecure] = rt
+	return rt
+}
+
+// Context returns the context for the registryClient of `oc mirror`.
+// Contexts are safe for concurrent use and must not be modified,
+// copy them to change their options.
+func (c *Clients) Context(skipVerification bool) (*registryclient.Context, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if regctx, ok := c.contexts[skipVerification]; ok {
+		return regctx, nil
+	}
+
+	regctx := registryclient.NewContext(c.transport(false), c.transport(true))
+	creds, err := loadCredentials()
+	if err != nil {
+		return nil, err
+	}
+	regctx.WithCredentials(&cloudCredentialStore{store: creds, cloud: c.cloud, external: c.external})
+	regctx.Retries = 3
+	regctx.DisableDigestVerification = skipVerification
+	c.contexts[skipVerification] = regctx
+	return regctx, nil
+}
+
+// Keychain returns the keychain of the external credentials of the
+// configured registries, of the docker config, and of the credential
+// helpers of cloud registries
+func (c *Clients) Keychain() authn.Keychain {
+	c.mu.Lock()
+	external := c.external
+	c.mu.Unlock()
+	return authn.NewMultiKeychain(external, authFileKeychain{}, c.cloud)
+}
+
+// Resolver returns a resolver of image references using the transport
+// and credentials of the clients. Registries are accessed over plain HTTP
+// when plainHTTP is true and they allow insecure options.
+func (c *Clients) Resolver(skipTLS, plainHTTP bool) remotes.Resolver {
+	client := &http.Client{Transport: c.Transport(skipTLS || plainHTTP)}
+	headers := http.Header{}
+	headers.Set("User-Agent", rest.DefaultKubernetesUserAgent())
+	regopts := []docker.RegistryOpt{
+		docker.WithAuthorizer(docker.NewDockerAuthorizer(
+			docker.WithAuthClient(client),
+			docker.WithAuthHeader(headers),
+			docker.WithAuthCreds(c.resolverCredentials),
+		)),
+		docker.WithClient(client),
+	}
+	if plainHTTP {
+		regopts = append(regopts, docker.WithPlainHTTP(func(host string) (bool, error) {
+			return c.AllowsInsecure(host), nil
+		}))
+	}
+	return docker.NewResolver(docker.ResolverOptions{
+		Hosts:   docker.ConfigureDefaultRegistries(regopts...),
+		Headers: headers,
+	})
+}
+
+// resolverCredentials returns the credentials of a registry host from the keychain.
+// An empty username means the secret is an identity token.
+func (c *Clients) resolverCredentials(host string) (string, string, error) {
+	if host == "registry-1.docker.io" {
+		host = name.DefaultRegistry
+	}
+	reg, err := name.NewRegistry(host)
+	if err != nil {
+		return "", "", err
+	}
+	authenticator, err := c.Keychain().Resolve(reg)
+	if err != nil {
+		return "", "", err
+	}
+	cfg, err := authenticator.Authorization()
+	if err != nil {
+		return "", "", err
+	}
+	if cfg.IdentityToken != "" {
+		return "", cfg.IdentityToken, nil
+	}
+	return cfg.Username, cfg.Password, nil
+}
+
+// CraneOptions returns the options of crane commands.
+// Plain HTTP registries are allowed when insecure is true.
+func (c *Clients) CraneOptions(ctx context.Context, insecure bool) []crane.Option {
+	options := []crane.Option{
+		crane.WithAuthFromKeychain(c.Keychain()),
+		crane.WithContext(ctx),
+		crane.WithTransport(c.Transport(insecure)),
+	}
+	if insecure {
+		options = append(options, crane.Insecure)
+	}
+	return options
+}
+
+// RemoteOptions returns the options of remote registry operations
+func (c *Clients) RemoteOptions(ctx context.Context, insecure bool) []remote.Option {
+	return []remote.Option{
+		remote.WithAuthFromKeychain(c.Keychain()),
+		remote.WithTransport(c.Transport(insecure)),
+		remote.WithContext(ctx),
+	}
+}
+
+// newRegistryDialer returns the dialer of registry connections
+func newRegistryDialer() *net.Dialer {
+	return &net.Dialer{
+		// By default, we wrap the transport in retries, so reduce the
+		// default dial timeout to 5s to avoid 5x 30s of connection
+		// timeouts when doing the "ping" on certain http registries.
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+}
+
+// newRegistryTransport returns a pooled transport using the proxy of the environment.
+// Clients replace the proxy and the dialer with their own.
+func newRegistryTransport(insecure bool) *http.Transport {
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           newRegistryDialer().DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   32,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: insecure,
+			MinVersion:         tls.VersionTLS12,
+		},
+	}
+}
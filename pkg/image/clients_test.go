@@ -0,0 +1,63 @@
+package image
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+func TestClients(t *testing.T) {
+	c := NewClients()
+
+	// Clients of a factory share transports and contexts
+	require.Same(t, c.Transport(false), c.Transport(false))
+	require.NotSame(t, c.Transport(false), c.Transport(true))
+
+	regctx, err := c.Context(false)
+	require.NoError(t, err)
+	again, err := c.Context(false)
+	require.NoError(t, err)
+	require.Same(t, regctx, again)
+	require.Same(t, c.Transport(false), regctx.Transport)
+	require.Same(t, c.Transport(true), regctx.InsecureTransport)
+	require.Equal(t, 3, regctx.Retries)
+
+	skipVerification, err := c.Context(true)
+	require.NoError(t, err)
+	require.NotSame(t, regctx, skipVerification)
+	require.True(t, skipVerification.DisableDigestVerification)
+
+	// Factories do not share clients
+	require.NotSame(t, c.Transport(false), NewClients().Transport(false))
+
+	require.Len(t, c.CraneOptions(context.Background(), false), 3)
+	require.Len(t, c.CraneOptions(context.Background(), true), 4)
+	require.Len(t, c.RemoteOptions(context.Background(), true), 3)
+}
+
+func TestClientsResolver(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	img, err := crane.Image(map[string][]byte{"file": []byte("content")})
+	require.NoError(t, err)
+	ref := u.Host + "/test/image:latest"
+	require.NoError(t, crane.Push(img, ref))
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	clients := NewClients()
+	require.NoError(t, clients.SetRegistries([]v1alpha2.Registry{{Host: u.Host, Insecure: true}}))
+	_, desc, err := clients.Resolver(false, true).Resolve(context.Background(), ref)
+	require.NoError(t, err)
+	require.Equal(t, digest.String(), desc.Digest.String())
+}
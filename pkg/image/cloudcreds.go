@@ -0,0 +1,188 @@
+package image
+
+import (
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/registry/client/auth"
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/docker/docker-credential-helpers/credentials"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/sirupsen/logrus"
+)
+
+// cloudCredentialTTL is how long the credentials of a cloud credential
+// helper are used before running the helper again. Cloud registries issue
+// tokens valid for an hour or more, so runs outlasting their tokens get
+// new ones without refreshing them manually.
+const cloudCredentialTTL = 15 * time.Minute
+
+// identityTokenUsername is the username credential helpers
+// return with identity tokens instead of passwords
+const identityTokenUsername = "<token>"
+
+var ecrHost = regexp.MustCompile(`^[0-9]{12}\.dkr\.ecr(-fips)?\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// cloudHelper is a credential helper of the registries of a cloud provider
+type cloudHelper struct {
+	// programs are the names of the helper programs, the first found on the PATH is used
+	programs []string
+	match    func(host string) bool
+}
+
+// cloudHelpers are the helpers of AWS ECR, Google Container and Artifact Registry, and ACR
+var cloudHelpers = []cloudHelper{
+	{
+		programs: []string{"docker-credential-ecr-login"},
+		match:    ecrHost.MatchString,
+	},
+	{
+		programs: []string{"docker-credential-gcr", "docker-credential-gcloud"},
+		match: func(host string) bool {
+			return host == "gcr.io" || strings.HasSuffix(host, ".gcr.io") || strings.HasSuffix(host, "-docker.pkg.dev")
+		},
+	},
+	{
+		programs: []string{"docker-credential-acr-env"},
+		match: func(host string) bool {
+			return strings.HasSuffix(host, ".azurecr.io") || strings.HasSuffix(host, ".azurecr.cn") || strings.HasSuffix(host, ".azurecr.us")
+		},
+	},
+}
+
+// cloudCredentials gets the credentials of cloud registries from the
+// credential helper of their provider, caching them for cloudCredentialTTL.
+// Credentials configured in the docker config take precedence.
+type cloudCredentials struct {
+	mu      sync.Mutex
+	cache   map[string]cachedCredentials
+	helpers []cloudHelper
+	// lookPath and program are replaced for testing
+	lookPath func(string) (string, error)
+	program  func(string) client.ProgramFunc
+	now      func() time.Time
+}
+
+type cachedCredentials struct {
+	creds   *credentials.Credentials
+	expires time.Time
+}
+
+func newCloudCredentials() *cloudCredentials {
+	return &cloudCredentials{
+		cache:    map[string]cachedCredentials{},
+		helpers:  cloudHelpers,
+		lookPath: exec.LookPath,
+		program:  client.NewShellProgramFunc,
+		now:      time.Now,
+	}
+}
+
+// get returns the credentials of the registry host,
+// or nil if it is not a cloud registry or the helper fails
+func (c *cloudCredentials) get(host string) *credentials.Credentials {
+	var helper *cloudHelper
+	for i := range c.helpers {
+		if c.helpers[i].match(host) {
+			helper = &c.helpers[i]
+			break
+		}
+	}
+	if helper == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.cache[host]; ok && c.now().Before(cached.expires) {
+		return cached.creds
+	}
+	// Failures are cached too, so a missing or failing
+	// helper is not run again for every request
+	var creds *credentials.Credentials
+	for _, name := range helper.programs {
+		path, err := c.lookPath(name)
+		if err != nil {
+			continue
+		}
+		if creds, err = client.Get(c.program(path), host); err != nil {
+			logrus.Warnf("unable to get credentials for registry %s from %s: %v", host, name, err)
+			creds = nil
+		} else {
+			logrus.Debugf("using credentials for registry %s from %s", host, name)
+		}
+		break
+	}
+	c.cache[host] = cachedCredentials{creds: creds, expires: c.now().Add(cloudCredentialTTL)}
+	return creds
+}
+
+// Resolve implements authn.Keychain
+func (c *cloudCredentials) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	creds := c.get(target.RegistryStr())
+	switch {
+	case creds == nil:
+		return authn.Anonymous, nil
+	case creds.Username == identityTokenUsername:
+		return authn.FromConfig(authn.AuthConfig{IdentityToken: creds.Secret}), nil
+	default:
+		return authn.FromConfig(authn.AuthConfig{Username: creds.Username, Password: creds.Secret}), nil
+	}
+}
+
+// cloudCredentialStore falls back to the credentials of cloud
+// registries for the registries without credentials in the store.
+// The external credentials of configured registries take precedence.
+type cloudCredentialStore struct {
+	store    auth.CredentialStore
+	cloud    *cloudCredentials
+	external *externalCredentials
+}
+
+var _ auth.CredentialStore = &cloudCredentialStore{}
+
+func (s *cloudCredentialStore) Basic(u *url.URL) (string, string) {
+	if creds := s.external.get(u.Host); creds != nil {
+		if creds.Username == identityTokenUsername {
+			return "", ""
+		}
+		return creds.Username, creds.Secret
+	}
+	if s.store != nil {
+		if username, password := s.store.Basic(u); username != "" || password != "" {
+			return username, password
+		}
+	}
+	if creds := s.cloud.get(u.Host); creds != nil && creds.Username != identityTokenUsername {
+		return creds.Username, creds.Secret
+	}
+	return "", ""
+}
+
+func (s *cloudCredentialStore) RefreshToken(u *url.URL, service string) string {
+	if creds := s.external.get(u.Host); creds != nil {
+		if creds.Username == identityTokenUsername {
+			return creds.Secret
+		}
+		return ""
+	}
+	if s.store != nil {
+		if token := s.store.RefreshToken(u, service); token != "" {
+			return token
+		}
+	}
+	if creds := s.cloud.get(u.Host); creds != nil && creds.Username == identityTokenUsername {
+		return creds.Secret
+	}
+	return ""
+}
+
+func (s *cloudCredentialStore) SetRefreshToken(realm *url.URL, service, token string) {
+	if s.store != nil {
+		s.store.SetRefreshToken(realm, service, token)
+	}
+}
@@ -0,0 +1,160 @@
+package image
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHelper is a credential helper program returning its output
+type fakeHelper struct {
+	output string
+	host   *string
+	calls  *int
+}
+
+func (h *fakeHelper) Output() ([]byte, error) {
+	*h.calls++
+	return []byte(h.output), nil
+}
+
+func (h *fakeHelper) Input(in io.Reader) {
+	data, _ := ioutil.ReadAll(in)
+	*h.host = string(data)
+}
+
+func TestCloudCredentials(t *testing.T) {
+	var calls int
+	var host string
+	now := time.Now()
+	creds := newCloudCredentials()
+	creds.now = func() time.Time { return now }
+	creds.lookPath = func(name string) (string, error) {
+		if name == "docker-credential-gcr" {
+			return "", errors.New("not found")
+		}
+		return "/usr/bin/" + name, nil
+	}
+	outputs := map[string]string{
+		"/usr/bin/docker-credential-ecr-login": `{"Username":"AWS","Secret":"ecr-token"}`,
+		"/usr/bin/docker-credential-gcloud":    `{"Username":"oauth2accesstoken","Secret":"gcr-token"}`,
+		"/usr/bin/docker-credential-acr-env":   `{"Username":"<token>","Secret":"acr-refresh-token"}`,
+	}
+	creds.program = func(path string) client.ProgramFunc {
+		return func(args ...string) client.Program {
+			return &fakeHelper{output: outputs[path], host: &host, calls: &calls}
+		}
+	}
+
+	type spec struct {
+		name     string
+		registry string
+		expAuth  *authn.AuthConfig
+	}
+	cases := []spec{
+		{
+			name:     "Valid/ECR",
+			registry: "123456789012.dkr.ecr.us-east-1.amazonaws.com",
+			expAuth:  &authn.AuthConfig{Username: "AWS", Password: "ecr-token"},
+		},
+		{
+			name:     "Valid/ArtifactRegistry",
+			registry: "us-central1-docker.pkg.dev",
+			expAuth:  &authn.AuthConfig{Username: "oauth2accesstoken", Password: "gcr-token"},
+		},
+		{
+			name:     "Valid/ACRIdentityToken",
+			registry: "example.azurecr.io",
+			expAuth:  &authn.AuthConfig{IdentityToken: "acr-refresh-token"},
+		},
+		{
+			name:     "Valid/NotCloud",
+			registry: "quay.io",
+			expAuth:  &authn.AuthConfig{},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			reg, err := name.NewRegistry(c.registry)
+			require.NoError(t, err)
+			authenticator, err := creds.Resolve(reg)
+			require.NoError(t, err)
+			cfg, err := authenticator.Authorization()
+			require.NoError(t, err)
+			require.Equal(t, c.expAuth, cfg)
+		})
+	}
+
+	// Credentials are cached until they expire
+	calls = 0
+	require.NotNil(t, creds.get("123456789012.dkr.ecr.us-east-1.amazonaws.com"))
+	require.Equal(t, 0, calls)
+	now = now.Add(cloudCredentialTTL)
+	require.NotNil(t, creds.get("123456789012.dkr.ecr.us-east-1.amazonaws.com"))
+	require.Equal(t, 1, calls)
+	require.Equal(t, "123456789012.dkr.ecr.us-east-1.amazonaws.com", host)
+}
+
+// staticStore is a credential store with the credentials of one host
+type staticStore struct {
+	host, username, password string
+}
+
+func (s *staticStore) Basic(u *url.URL) (string, string) {
+	if u.Host == s.host {
+		return s.username, s.password
+	}
+	return "", ""
+}
+
+func (s *staticStore) RefreshToken(*url.URL, string) string { return "" }
+
+func (s *staticStore) SetRefreshToken(*url.URL, string, string) {}
+
+func TestCloudCredentialStore(t *testing.T) {
+	c := newCloudCredentials()
+	c.lookPath = func(name string) (string, error) { return "/usr/bin/" + name, nil }
+	c.program = func(path string) client.ProgramFunc {
+		return func(args ...string) client.Program {
+			var calls int
+			var host string
+			output := `{"Username":"AWS","Secret":"ecr-token"}`
+			if path == "/usr/bin/docker-credential-acr-env" {
+				output = `{"Username":"<token>","Secret":"acr-refresh-token"}`
+			}
+			return &fakeHelper{output: output, host: &host, calls: &calls}
+		}
+	}
+	ecr := "123456789012.dkr.ecr.us-east-1.amazonaws.com"
+	store := &cloudCredentialStore{
+		store: &staticStore{host: "other.dkr.ecr.us-east-1.amazonaws.com", username: "user", password: "pass"},
+		cloud: c,
+	}
+
+	username, password := store.Basic(&url.URL{Host: ecr})
+	require.Equal(t, "AWS", username)
+	require.Equal(t, "ecr-token", password)
+
+	// Credentials of the docker config take precedence
+	username, password = store.Basic(&url.URL{Host: "other.dkr.ecr.us-east-1.amazonaws.com"})
+	require.Equal(t, "user", username)
+	require.Equal(t, "pass", password)
+
+	username, password = store.Basic(&url.URL{Host: "example.azurecr.io"})
+	require.Empty(t, username+password)
+	require.Equal(t, "acr-refresh-token", store.RefreshToken(&url.URL{Host: "example.azurecr.io"}, "example.azurecr.io"))
+
+	// Stores are optional
+	store.store = nil
+	username, _ = store.Basic(&url.URL{Host: ecr})
+	require.Equal(t, "AWS", username)
+}
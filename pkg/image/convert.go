@@ -2,6 +2,7 @@ package image
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
 	"github.com/sirupsen/logrus"
@@ -62,7 +63,9 @@ func ConvertToAssociationSet(assocs []v1alpha2.Association) (AssociationSet, err
 	return assocSet, nil
 }
 
-// COnvertFromAssociationSet will return a slice of Association from an AssociationSet
+// ConvertFromAssociationSet will return a slice of Association from an AssociationSet.
+// Associations are sorted by name and path so metadata written from the
+// same AssociationSet is identical between runs.
 func ConvertFromAssociationSet(assocSet AssociationSet) ([]v1alpha2.Association, error) {
 	assocs := []v1alpha2.Association{}
 	var errs []error
@@ -75,5 +78,11 @@ func ConvertFromAssociationSet(assocSet AssociationSet) ([]v1alpha2.Association,
 			assocs = append(assocs, a)
 		}
 	}
+	sort.Slice(assocs, func(i, j int) bool {
+		if assocs[i].Name != assocs[j].Name {
+			return assocs[i].Name < assocs[j].Name
+		}
+		return assocs[i].Path < assocs[j].Path
+	})
 	return assocs, utilerrors.NewAggregate(errs)
 }
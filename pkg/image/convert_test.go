@@ -666,3 +666,28 @@ func TestConvertToAssociationSet(t *testing.T) {
 		})
 	}
 }
+
+func TestConvertFromAssociationSet(t *testing.T) {
+	assoc := func(name, path string) v1alpha2.Association {
+		return v1alpha2.Association{
+			Name:         name,
+			Path:         path,
+			ID:           "sha256:d31c6ea5c50be93d6eb94d2b508f0208e84a308c011c6454ebf291d48b37df19",
+			Type:         v1alpha2.TypeGeneric,
+			LayerDigests: []string{"sha256:e8614d09b7bebabd9d8a450f44e88a8807c98a438a2ddd63146865286b132d1b"},
+		}
+	}
+	set := AssociationSet{}
+	set.Add("imgname:b", assoc("imgname:b", "b"))
+	set.Add("imgname:a", assoc("imgname:a", "z"))
+	set.Add("other:a", assoc("imgname:a", "a"))
+
+	// Associations are ordered for stable metadata
+	assocs, err := ConvertFromAssociationSet(set)
+	require.NoError(t, err)
+	require.Equal(t, []v1alpha2.Association{
+		assoc("imgname:a", "a"),
+		assoc("imgname:a", "z"),
+		assoc("imgname:b", "b"),
+	}, assocs)
+}
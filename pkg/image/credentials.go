@@ -2,52 +2,164 @@ package image
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	dockercfg "github.com/docker/cli/cli/config"
-	"github.com/openshift/library-go/pkg/image/registryclient"
+	"github.com/docker/cli/cli/config/types"
+	"github.com/docker/distribution/registry/client/auth"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/openshift/oc/pkg/cli/image/manifest/dockercredentials"
-	"k8s.io/client-go/rest"
 )
 
-// NewContext creates a context for the registryClient of `oc mirror`
-func NewContext(skipVerification bool) (*registryclient.Context, error) {
-	userAgent := rest.DefaultKubernetesUserAgent()
-	rt, err := rest.TransportFor(&rest.Config{UserAgent: userAgent})
-	if err != nil {
-		return nil, err
+// registryAuthFileEnv overrides the auth file of podman and skopeo
+const registryAuthFileEnv = "REGISTRY_AUTH_FILE"
+
+// AuthFiles returns the registry credential files that exist, in the order
+// their credentials are used: the file of REGISTRY_AUTH_FILE, the docker
+// config, and the auth files written by `podman login` in the runtime
+// directory and the config directory of the user
+func AuthFiles() ([]string, error) {
+	candidates := []string{
+		os.Getenv(registryAuthFileEnv),
+		filepath.Join(dockercfg.Dir(), dockercfg.ConfigFileName),
+	}
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		candidates = append(candidates, filepath.Join(runtimeDir, "containers", "auth.json"))
+	} else {
+		// podman falls back to this directory without a user runtime directory
+		candidates = append(candidates, filepath.Join("/run/containers", strconv.Itoa(os.Getuid()), "auth.json"))
+	}
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			configDir = filepath.Join(home, ".config")
+		}
+	}
+	if configDir != "" {
+		candidates = append(candidates, filepath.Join(configDir, "containers", "auth.json"))
+	}
+
+	var files []string
+	seen := map[string]struct{}{}
+	for _, path := range candidates {
+		if path == "" {
+			continue
+		}
+		if _, ok := seen[path]; ok {
+			continue
+		}
+		seen[path] = struct{}{}
+		switch _, err := os.Stat(path); {
+		case err == nil:
+			files = append(files, path)
+		case errors.Is(err, os.ErrNotExist), errors.Is(err, os.ErrPermission):
+		default:
+			return nil, err
+		}
 	}
-	insecureRT, err := rest.TransportFor(&rest.Config{TLSClientConfig: rest.TLSClientConfig{Insecure: true}, UserAgent: userAgent})
+	return files, nil
+}
+
+// loadCredentials loads the registry credentials from the auth files,
+// returning nil if none exists
+func loadCredentials() (auth.CredentialStore, error) {
+	files, err := AuthFiles()
 	if err != nil {
 		return nil, err
 	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	var stores multiCredentialStore
+	for _, path := range files {
+		store, err := dockercredentials.NewFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		stores = append(stores, store)
+	}
+	return stores, nil
+}
 
-	ctx := registryclient.NewContext(rt, insecureRT)
+// multiCredentialStore returns the credentials of
+// the first store with credentials for a registry
+type multiCredentialStore []auth.CredentialStore
 
-	// Set default options
-	var registryConfig string
-	dockerConfigJSON := filepath.Join(dockercfg.Dir(), dockercfg.ConfigFileName)
-	switch _, err := os.Stat(dockerConfigJSON); {
-	case err == nil:
-		registryConfig = dockerConfigJSON
-	case errors.Is(err, os.ErrNotExist):
-		podmanConfig := filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), "containers/auth.json")
-		if _, err := os.Stat(podmanConfig); err == nil {
-			registryConfig = podmanConfig
-		} else if !os.IsNotExist(err) {
-			return nil, err
+var _ auth.CredentialStore = multiCredentialStore{}
+
+func (m multiCredentialStore) Basic(u *url.URL) (string, string) {
+	for _, store := range m {
+		if username, password := store.Basic(u); username != "" || password != "" {
+			return username, password
+		}
+	}
+	return "", ""
+}
+
+func (m multiCredentialStore) RefreshToken(u *url.URL, service string) string {
+	for _, store := range m {
+		if token := store.RefreshToken(u, service); token != "" {
+			return token
 		}
 	}
+	return ""
+}
 
-	if len(registryConfig) != 0 {
-		creds, err := dockercredentials.NewFromFile(registryConfig)
+func (m multiCredentialStore) SetRefreshToken(realm *url.URL, service, token string) {
+	for _, store := range m {
+		store.SetRefreshToken(realm, service, token)
+	}
+}
+
+// authFileKeychain resolves credentials from the auth files,
+// like the registry context credentials
+type authFileKeychain struct{}
+
+// Resolve implements authn.Keychain
+func (authFileKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	files, err := AuthFiles()
+	if err != nil {
+		return nil, err
+	}
+	key := target.RegistryStr()
+	if key == name.DefaultRegistry {
+		key = authn.DefaultAuthKey
+	}
+	for _, path := range files {
+		cfg, err := loadAuthConfig(path, key)
 		if err != nil {
 			return nil, err
 		}
-		ctx.WithCredentials(creds)
+		if cfg == (types.AuthConfig{}) {
+			continue
+		}
+		return authn.FromConfig(authn.AuthConfig{
+			Username:      cfg.Username,
+			Password:      cfg.Password,
+			Auth:          cfg.Auth,
+			IdentityToken: cfg.IdentityToken,
+			RegistryToken: cfg.RegistryToken,
+		}), nil
+	}
+	return authn.Anonymous, nil
+}
+
+// loadAuthConfig returns the credentials of the registry in the auth file,
+// including the credentials of its credential helpers
+func loadAuthConfig(path, key string) (types.AuthConfig, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return types.AuthConfig{}, err
+	}
+	defer f.Close()
+	cf, err := dockercfg.LoadFromReader(f)
+	if err != nil {
+		return types.AuthConfig{}, fmt.Errorf("error reading registry credentials from %s: %v", path, err)
 	}
-	ctx.Retries = 3
-	ctx.DisableDigestVerification = skipVerification
-	return ctx, nil
+	return cf.GetAuthConfig(key)
 }
@@ -1,13 +1,21 @@
 package image
 
 import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
 
+	dockercfg "github.com/docker/cli/cli/config"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/openshift/library-go/pkg/image/registryclient"
 	"github.com/stretchr/testify/require"
 )
 
-func TestNewContext(t *testing.T) {
+func TestClientsContext(t *testing.T) {
 
 	tests := []struct {
 		name             string
@@ -29,7 +37,7 @@ func TestNewContext(t *testing.T) {
 	}}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			regctx, err := NewContext(test.skipVerification)
+			regctx, err := NewClients().Context(test.skipVerification)
 			if test.err != "" {
 				require.EqualError(t, err, test.err)
 			} else {
@@ -39,3 +47,79 @@ func TestNewContext(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthFiles(t *testing.T) {
+	tmp := t.TempDir()
+	writeAuthFile := func(path, registry, username, password string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+		auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+		data := fmt.Sprintf(`{"auths":{%q:{"auth":%q}}}`, registry, auth)
+		require.NoError(t, os.WriteFile(path, []byte(data), 0600))
+	}
+
+	dockerConfig := filepath.Join(tmp, "docker", "config.json")
+	runtimeAuth := filepath.Join(tmp, "runtime", "containers", "auth.json")
+	configAuth := filepath.Join(tmp, "config", "containers", "auth.json")
+	writeAuthFile(dockerConfig, "quay.io", "docker", "docker-pass")
+	writeAuthFile(runtimeAuth, "quay.io", "podman", "podman-pass")
+	writeAuthFile(configAuth, "registry.example.com", "persistent", "persistent-pass")
+	// The docker config directory is only read from the environment once
+	prevDir := dockercfg.Dir()
+	dockercfg.SetDir(filepath.Dir(dockerConfig))
+	t.Cleanup(func() { dockercfg.SetDir(prevDir) })
+	t.Setenv("XDG_RUNTIME_DIR", filepath.Join(tmp, "runtime"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tmp, "config"))
+	t.Setenv(registryAuthFileEnv, filepath.Join(tmp, "missing.json"))
+
+	files, err := AuthFiles()
+	require.NoError(t, err)
+	require.Equal(t, []string{dockerConfig, runtimeAuth, configAuth}, files)
+
+	type spec struct {
+		name     string
+		registry string
+		expAuth  *authn.AuthConfig
+	}
+	cases := []spec{
+		{
+			name:     "Valid/DockerConfigFirst",
+			registry: "quay.io",
+			expAuth:  &authn.AuthConfig{Username: "docker", Password: "docker-pass"},
+		},
+		{
+			name:     "Valid/PodmanConfigDir",
+			registry: "registry.example.com",
+			expAuth:  &authn.AuthConfig{Username: "persistent", Password: "persistent-pass"},
+		},
+		{
+			name:     "Valid/NoCredentials",
+			registry: "docker.io",
+			expAuth:  &authn.AuthConfig{},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			reg, err := name.NewRegistry(c.registry)
+			require.NoError(t, err)
+			authenticator, err := authFileKeychain{}.Resolve(reg)
+			require.NoError(t, err)
+			cfg, err := authenticator.Authorization()
+			require.NoError(t, err)
+			require.Equal(t, c.expAuth, cfg)
+		})
+	}
+
+	// REGISTRY_AUTH_FILE takes precedence over the other files
+	authFile := filepath.Join(tmp, "auth.json")
+	writeAuthFile(authFile, "quay.io", "override", "override-pass")
+	t.Setenv(registryAuthFileEnv, authFile)
+	store, err := loadCredentials()
+	require.NoError(t, err)
+	username, password := store.Basic(&url.URL{Host: "quay.io"})
+	require.Equal(t, "override", username)
+	require.Equal(t, "override-pass", password)
+	username, password = store.Basic(&url.URL{Host: "registry.example.com"})
+	require.Equal(t, "persistent", username)
+	require.Equal(t, "persistent-pass", password)
+}
@@ -0,0 +1,51 @@
+package image
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// prefixTransport sends the requests to the registry API of hosts
+// under the path prefix of their registry
+type prefixTransport struct {
+	base http.RoundTripper
+	// prefixes holds the path prefixes of registries by host, with or without the port
+	prefixes map[string]string
+}
+
+func (t *prefixTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	prefix, ok := t.prefixes[req.URL.Host]
+	if !ok {
+		prefix, ok = t.prefixes[req.URL.Hostname()]
+	}
+	// Upload locations returned by registries aware of
+	// the prefix already have it and are sent unchanged
+	if !ok || (req.URL.Path != "/v2" && !strings.HasPrefix(req.URL.Path, "/v2/")) {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.URL.Path = prefix + req.URL.Path
+	req.URL.RawPath = ""
+	return t.base.RoundTrip(req)
+}
+
+// TrimPathPrefix returns the repository path of a registry host without the
+// path prefix configured for the host with SetRegistries, for destinations
+// including the prefix (e.g. artifactory.example.com/artifactory/api/docker/docker-local/ns).
+func (c *Clients) TrimPathPrefix(host, repository string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prefix, ok := c.pathPrefixes[host]
+	if hostname, _, err := net.SplitHostPort(host); !ok && err == nil {
+		prefix, ok = c.pathPrefixes[hostname]
+	}
+	if !ok {
+		return repository
+	}
+	prefix = strings.TrimPrefix(prefix, "/")
+	if repository == prefix {
+		return ""
+	}
+	return strings.TrimPrefix(repository, prefix+"/")
+}
@@ -0,0 +1,127 @@
+package image
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+func TestRegistryPathPrefix(t *testing.T) {
+	const prefix = "/artifactory/api/docker/docker-local"
+	reg := registry.New()
+	mux := http.NewServeMux()
+	mux.Handle(prefix+"/", http.StripPrefix(prefix, reg))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	type spec struct {
+		name       string
+		registries []v1alpha2.Registry
+		expError   bool
+	}
+	cases := []spec{
+		{
+			name:     "Invalid/NoPathPrefix",
+			expError: true,
+		},
+		{
+			name:       "Valid/HostAndPort",
+			registries: []v1alpha2.Registry{{Host: u.Host, Insecure: true, PathPrefix: prefix}},
+		},
+		{
+			name:       "Valid/TrailingSlash",
+			registries: []v1alpha2.Registry{{Host: u.Hostname(), Insecure: true, PathPrefix: prefix + "/"}},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			clients := NewClients()
+			require.NoError(t, clients.SetRegistries(c.registries))
+			ctx := context.Background()
+
+			img, err := random.Image(1024, 2)
+			require.NoError(t, err)
+			ref, err := name.ParseReference(u.Host+"/mirror/ubi8/ubi:latest", name.Insecure)
+			require.NoError(t, err)
+			err = remote.Write(ref, img, clients.RemoteOptions(ctx, true)...)
+			if c.expError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			got, err := remote.Image(ref, clients.RemoteOptions(ctx, true)...)
+			require.NoError(t, err)
+			expDigest, err := img.Digest()
+			require.NoError(t, err)
+			gotDigest, err := got.Digest()
+			require.NoError(t, err)
+			require.Equal(t, expDigest, gotDigest)
+		})
+	}
+}
+
+func TestTrimPathPrefix(t *testing.T) {
+	clients := NewClients()
+	require.NoError(t, clients.SetRegistries([]v1alpha2.Registry{
+		{Host: "artifactory.example.com", PathPrefix: "/artifactory/api/docker/docker-local/"},
+	}))
+
+	type spec struct {
+		name          string
+		host          string
+		repository    string
+		expRepository string
+	}
+	cases := []spec{
+		{
+			name:          "Valid/Prefix",
+			host:          "artifactory.example.com",
+			repository:    "artifactory/api/docker/docker-local",
+			expRepository: "",
+		},
+		{
+			name:          "Valid/PrefixAndNamespace",
+			host:          "artifactory.example.com:443",
+			repository:    "artifactory/api/docker/docker-local/mirror",
+			expRepository: "mirror",
+		},
+		{
+			name:          "Valid/NoPrefix",
+			host:          "artifactory.example.com",
+			repository:    "mirror",
+			expRepository: "mirror",
+		},
+		{
+			name:          "Valid/PartialPrefix",
+			host:          "artifactory.example.com",
+			repository:    "artifactory/api/docker/docker-local-2",
+			expRepository: "artifactory/api/docker/docker-local-2",
+		},
+		{
+			name:          "Valid/OtherHost",
+			host:          "registry.example.com",
+			repository:    "artifactory/api/docker/docker-local",
+			expRepository: "artifactory/api/docker/docker-local",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			require.Equal(t, c.expRepository, clients.TrimPathPrefix(c.host, c.repository))
+		})
+	}
+}
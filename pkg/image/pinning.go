@@ -0,0 +1,73 @@
+package image
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+
+	"github.com/openshift/oc-mirror/pkg/config"
+)
+
+// parsePublicKeyPins returns the SHA-256 hashes of the pinned
+// public keys, which are base64 encoded with a sha256// prefix
+func parsePublicKeyPins(pins []string) ([][]byte, error) {
+	hashes := make([][]byte, 0, len(pins))
+	for _, pin := range pins {
+		hash, err := config.ParsePublicKeyPin(pin)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// verifyPublicKeyPins returns a TLS connection check failing unless a
+// certificate of a verified chain of the server has a pinned public key,
+// which detects interception by a proxy whose CA is trusted. Certificates
+// presented beyond the verified chains are ignored, since anyone can append
+// them. When certificates are not verified, only the public key of the
+// leaf certificate is matched.
+func verifyPublicKeyPins(host string, hashes [][]byte) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		var certs []*x509.Certificate
+		switch {
+		case len(cs.VerifiedChains) != 0:
+			for _, chain := range cs.VerifiedChains {
+				certs = append(certs, chain...)
+			}
+		case len(cs.PeerCertificates) != 0:
+			certs = cs.PeerCertificates[:1]
+		}
+		for _, cert := range certs {
+			sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			for _, hash := range hashes {
+				if bytes.Equal(sum[:], hash) {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("the certificate of %s does not match its pinned public keys, the connection may be intercepted", host)
+	}
+}
+
+// VerifyConnection returns the check of the pinned public keys
+// of a host, with or without a port, or nil if none is pinned.
+// Clients of other servers than registries, such as Cincinnati,
+// use it to apply the pins of the registries configuration.
+func (c *Clients) VerifyConnection(host string) func(tls.ConnectionState) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cfg, ok := c.hostTLS[host]; ok && cfg.VerifyConnection != nil {
+		return cfg.VerifyConnection
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		if cfg, ok := c.hostTLS[hostname]; ok {
+			return cfg.VerifyConnection
+		}
+	}
+	return nil
+}
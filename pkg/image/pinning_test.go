@@ -0,0 +1,173 @@
+package image
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/config"
+)
+
+func TestPinnedPublicKeys(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	sum := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	pin := config.PublicKeyPinPrefix + base64.StdEncoding.EncodeToString(sum[:])
+	otherPin := config.PublicKeyPinPrefix + base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+	type spec struct {
+		name       string
+		registries []v1alpha2.Registry
+		expError   bool
+		expLoadErr bool
+	}
+	cases := []spec{
+		{
+			name:       "Valid/NotPinned",
+			registries: []v1alpha2.Registry{{Host: u.Host, Insecure: true}},
+		},
+		{
+			name:       "Valid/PinnedHostAndPort",
+			registries: []v1alpha2.Registry{{Host: u.Host, Insecure: true, PinnedPublicKeys: []string{otherPin, pin}}},
+		},
+		{
+			name:       "Valid/PinnedHostname",
+			registries: []v1alpha2.Registry{{Host: u.Hostname(), Insecure: true, PinnedPublicKeys: []string{pin}}},
+		},
+		{
+			name:       "Invalid/OtherKey",
+			registries: []v1alpha2.Registry{{Host: u.Host, Insecure: true, PinnedPublicKeys: []string{otherPin}}},
+			expError:   true,
+		},
+		{
+			name:       "Invalid/NoPrefix",
+			registries: []v1alpha2.Registry{{Host: u.Host, PinnedPublicKeys: []string{base64.StdEncoding.EncodeToString(sum[:])}}},
+			expLoadErr: true,
+		},
+		{
+			name:       "Invalid/NotSHA256",
+			registries: []v1alpha2.Registry{{Host: u.Host, PinnedPublicKeys: []string{config.PublicKeyPinPrefix + "c2hvcnQ="}}},
+			expLoadErr: true,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			clients := NewClients()
+			err := clients.SetRegistries(c.registries)
+			if c.expLoadErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			// Pins are verified even when certificates are not
+			client := &http.Client{Transport: clients.Transport(true)}
+			resp, err := client.Get(server.URL + "/v2/")
+			if c.expError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+	}
+
+	clients := NewClients()
+	require.NoError(t, clients.SetRegistries([]v1alpha2.Registry{{Host: "api.openshift.com", PinnedPublicKeys: []string{otherPin}}}))
+	require.Nil(t, clients.VerifyConnection("quay.io"))
+	verify := clients.VerifyConnection("api.openshift.com:443")
+	require.NotNil(t, verify)
+	require.Error(t, verify(tls.ConnectionState{PeerCertificates: []*x509.Certificate{server.Certificate()}}))
+}
+
+func TestVerifyPublicKeyPins(t *testing.T) {
+	newCert := func(name string) *x509.Certificate {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		require.NoError(t, err)
+		cert, err := x509.ParseCertificate(der)
+		require.NoError(t, err)
+		return cert
+	}
+	pinned, forged, intermediate := newCert("registry.example.com"), newCert("registry.example.com"), newCert("intermediate")
+	sum := sha256.Sum256(pinned.RawSubjectPublicKeyInfo)
+	verify := verifyPublicKeyPins("registry.example.com", [][]byte{sum[:]})
+
+	type spec struct {
+		name     string
+		cs       tls.ConnectionState
+		expError bool
+	}
+	cases := []spec{
+		{
+			name: "Valid/VerifiedLeaf",
+			cs: tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{pinned, intermediate},
+				VerifiedChains:   [][]*x509.Certificate{{pinned, intermediate}},
+			},
+		},
+		{
+			name: "Valid/VerifiedIntermediate",
+			cs: tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{intermediate, pinned},
+				VerifiedChains:   [][]*x509.Certificate{{intermediate, pinned}},
+			},
+		},
+		{
+			name: "Valid/UnverifiedLeaf",
+			cs:   tls.ConnectionState{PeerCertificates: []*x509.Certificate{pinned}},
+		},
+		{
+			name: "Invalid/PinnedOutsideVerifiedChain",
+			cs: tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{forged, pinned},
+				VerifiedChains:   [][]*x509.Certificate{{forged, intermediate}},
+			},
+			expError: true,
+		},
+		{
+			name:     "Invalid/UnverifiedForgedLeafWithPinnedAppended",
+			cs:       tls.ConnectionState{PeerCertificates: []*x509.Certificate{forged, pinned}},
+			expError: true,
+		},
+		{
+			name:     "Invalid/NoCertificates",
+			expError: true,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			err := verify(c.cs)
+			if c.expError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
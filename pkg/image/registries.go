@@ -0,0 +1,320 @@
+package image
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/openshift/library-go/pkg/image/reference"
+	"github.com/openshift/library-go/pkg/image/registryclient"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+// SetRegistries configures the TLS, proxy, address, and credential options of
+// registries by hostname. Client certificates are presented to registries that require
+// mutual TLS, registry certificates are verified with the CA bundle of their
+// registry and against its pinned public keys, and credentials are read from the credential helper or Vault secret
+// of their registry. Connections to registries with addresses go to them instead
+// of the addresses their name resolves to, and requests to the registry API of
+// registries with a path prefix are sent under it. The insecure options only apply
+// to the registries marked insecure, if any.
+// Transports and contexts created before are replaced.
+func (c *Clients) SetRegistries(registries []v1alpha2.Registry) error {
+	hosts := make(map[string]*tls.Config, len(registries))
+	insecureHosts := make(map[string]struct{}, len(registries))
+	proxies := make(map[string]*url.URL, len(registries))
+	addresses := make(map[string][]string, len(registries))
+	pathPrefixes := make(map[string]string, len(registries))
+	sources := make(map[string]credentialSource, len(registries))
+	var rootCAs *x509.CertPool
+	for _, reg := range registries {
+		if reg.Insecure {
+			insecureHosts[reg.Host] = struct{}{}
+		}
+		if source := newCredentialSource(reg, c.HTTPClient); source != nil {
+			sources[reg.Host] = source
+		}
+		if len(reg.Addresses) != 0 {
+			addresses[reg.Host] = reg.Addresses
+		}
+		if prefix := strings.TrimSuffix(reg.PathPrefix, "/"); prefix != "" {
+			pathPrefixes[reg.Host] = prefix
+		}
+		if reg.Proxy != "" {
+			proxy, err := url.Parse(reg.Proxy)
+			if err != nil {
+				return fmt.Errorf("invalid proxy for registry %q: %v", reg.Host, err)
+			}
+			proxies[reg.Host] = proxy
+		}
+		cfg := &tls.Config{}
+		if reg.CAFile != "" {
+			pool, err := loadCABundle(reg.CAFile)
+			if err != nil {
+				return fmt.Errorf("error loading CA bundle for registry %q: %v", reg.Host, err)
+			}
+			cfg.RootCAs = pool
+			if rootCAs == nil {
+				rootCAs = systemCertPool()
+			}
+			if err := appendCABundle(rootCAs, reg.CAFile); err != nil {
+				return fmt.Errorf("error loading CA bundle for registry %q: %v", reg.Host, err)
+			}
+		}
+		if reg.ClientCert != "" {
+			cert, err := tls.LoadX509KeyPair(reg.ClientCert, reg.ClientKey)
+			if err != nil {
+				return fmt.Errorf("error loading client certificate for registry %q: %v", reg.Host, err)
+			}
+			cfg.Certificates = []tls.Certificate{cert}
+		}
+		if len(reg.PinnedPublicKeys) != 0 {
+			hashes, err := parsePublicKeyPins(reg.PinnedPublicKeys)
+			if err != nil {
+				return fmt.Errorf("invalid public key pins for registry %q: %v", reg.Host, err)
+			}
+			cfg.VerifyConnection = verifyPublicKeyPins(reg.Host, hashes)
+		}
+		hosts[reg.Host] = cfg
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hostTLS = hosts
+	c.insecureHosts = insecureHosts
+	c.proxies = proxies
+	c.addresses = addresses
+	c.pathPrefixes = pathPrefixes
+	c.rootCAs = rootCAs
+	c.external = newExternalCredentials(sources)
+	c.transports = map[bool]http.RoundTripper{}
+	c.contexts = map[bool]*registryclient.Context{}
+	return nil
+}
+
+// AllowsInsecure returns whether the options skipping TLS verification or
+// using plain HTTP apply to all the registry hosts, with or without a port.
+// They only apply to the registries marked insecure with SetRegistries.
+func (c *Clients) AllowsInsecure(hosts ...string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, host := range hosts {
+		if !allowsInsecure(c.insecureHosts, host) {
+			return false
+		}
+	}
+	return true
+}
+
+// AllowsInsecureImages returns whether the insecure options apply to
+// the registries of all the images, for clients that cannot apply them
+// per registry. Images that cannot be parsed do not allow them.
+func (c *Clients) AllowsInsecureImages(images ...string) bool {
+	hosts := make([]string, 0, len(images))
+	for _, img := range images {
+		ref, err := reference.Parse(img)
+		if err != nil {
+			return false
+		}
+		hosts = append(hosts, ref.DockerClientDefaults().Registry)
+	}
+	return c.AllowsInsecure(hosts...)
+}
+
+func allowsInsecure(insecureHosts map[string]struct{}, host string) bool {
+	if _, ok := insecureHosts[host]; ok {
+		return true
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		_, ok := insecureHosts[hostname]
+		return ok
+	}
+	return false
+}
+
+// MarkInsecure returns the registries with the hosts marked insecure,
+// adding the hosts that are not listed. The registries are not modified.
+func MarkInsecure(registries []v1alpha2.Registry, hosts []string) []v1alpha2.Registry {
+	marked := make([]v1alpha2.Registry, len(registries), len(registries)+len(hosts))
+	copy(marked, registries)
+	for _, host := range hosts {
+		found := false
+		for i := range marked {
+			if marked[i].Host == host {
+				marked[i].Insecure = true
+				found = true
+			}
+		}
+		if !found {
+			marked = append(marked, v1alpha2.Registry{Host: host, Insecure: true})
+		}
+	}
+	return marked
+}
+
+// Proxy returns the proxy of the host of a request, which is the proxy
+// of its registry if one is configured, or the proxy of the environment
+// set with HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+// Every client of `oc mirror` uses it, including the clients of
+// Cincinnati and of downloads.
+func (c *Clients) Proxy(req *http.Request) (*url.URL, error) {
+	c.mu.Lock()
+	proxy, ok := c.proxies[req.URL.Host]
+	if !ok {
+		proxy, ok = c.proxies[req.URL.Hostname()]
+	}
+	c.mu.Unlock()
+	if ok {
+		return proxy, nil
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+// HTTPClient returns the client of HTTP servers that are not registries
+func (c *Clients) HTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = c.Proxy
+	transport.DialContext = c.DialContext
+	return &http.Client{Transport: transport}
+}
+
+// RootCAs returns the system trust store with the CA bundles of all
+// the registries, for clients that cannot trust CAs per registry.
+// It returns nil when no CA bundle is configured.
+func (c *Clients) RootCAs() *x509.CertPool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rootCAs
+}
+
+// loadCABundle returns the system trust store with the CA bundle
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pool := systemCertPool()
+	if err := appendCABundle(pool, path); err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+func appendCABundle(pool *x509.CertPool, path string) error {
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no certificates found in %s", path)
+	}
+	return nil
+}
+
+func systemCertPool() *x509.CertPool {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		return x509.NewCertPool()
+	}
+	return pool
+}
+
+// newBaseTransport returns the transport for registries, routing requests
+// to registries with TLS options to a transport using them and sending
+// requests to registries with a path prefix under it. The insecure
+// transport only skips TLS verification and allows plain HTTP for
+// the registries marked insecure.
+func (c *Clients) newBaseTransport(insecure bool) http.RoundTripper {
+	rt := c.newTLSTransport(insecure)
+	if len(c.pathPrefixes) == 0 {
+		return rt
+	}
+	prefixes := make(map[string]string, len(c.pathPrefixes))
+	for host, prefix := range c.pathPrefixes {
+		prefixes[host] = prefix
+	}
+	return &prefixTransport{base: rt, prefixes: prefixes}
+}
+
+// newTLSTransport returns the transport routing requests by the TLS
+// options of their registry
+func (c *Clients) newTLSTransport(insecure bool) http.RoundTripper {
+	base := c.newHostTransport(false)
+	if len(c.hostTLS) == 0 && !insecure {
+		return base
+	}
+	hosts := make(map[string]http.RoundTripper, len(c.hostTLS)+len(c.insecureHosts))
+	if insecure {
+		for host := range c.insecureHosts {
+			hosts[host] = c.newHostTransport(true)
+		}
+	}
+	for host, cfg := range c.hostTLS {
+		rt := c.newHostTransport(insecure && allowsInsecure(c.insecureHosts, host))
+		rt.TLSClientConfig.Certificates = cfg.Certificates
+		rt.TLSClientConfig.RootCAs = cfg.RootCAs
+		rt.TLSClientConfig.VerifyConnection = cfg.VerifyConnection
+		hosts[host] = rt
+	}
+	var rt http.RoundTripper = &hostTransport{base: base, hosts: hosts}
+	if insecure {
+		insecureHosts := make(map[string]struct{}, len(c.insecureHosts))
+		for host := range c.insecureHosts {
+			insecureHosts[host] = struct{}{}
+		}
+		rt = &secureTransport{base: rt, insecureHosts: insecureHosts}
+	}
+	return rt
+}
+
+// newHostTransport returns a registry transport using
+// the proxies and addresses of the registries
+func (c *Clients) newHostTransport(insecure bool) *http.Transport {
+	rt := newRegistryTransport(insecure)
+	rt.Proxy = c.Proxy
+	rt.DialContext = (&hostDialer{dialer: newRegistryDialer(), addresses: c.addresses}).DialContext
+	return rt
+}
+
+// secureTransport rejects plain HTTP requests to
+// the registries that are not marked insecure
+type secureTransport struct {
+	base          http.RoundTripper
+	insecureHosts map[string]struct{}
+	warned        sync.Map
+}
+
+func (t *secureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if allowsInsecure(t.insecureHosts, req.URL.Host) {
+		return t.base.RoundTrip(req)
+	}
+	if _, warned := t.warned.LoadOrStore(req.URL.Host, struct{}{}); !warned {
+		logrus.Warnf("registry %s is not marked insecure, ignoring insecure options for it", req.URL.Host)
+	}
+	if req.URL.Scheme == "http" {
+		return nil, fmt.Errorf("plain HTTP is not allowed for registry %s: it is not marked insecure in the configuration or with --insecure-registry", req.URL.Host)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// hostTransport routes requests by registry host,
+// with or without the port
+type hostTransport struct {
+	base  http.RoundTripper
+	hosts map[string]http.RoundTripper
+}
+
+func (t *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt, ok := t.hosts[req.URL.Host]; ok {
+		return rt.RoundTrip(req)
+	}
+	if rt, ok := t.hosts[req.URL.Hostname()]; ok {
+		return rt.RoundTrip(req)
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,316 @@
+package image
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+func TestSetRegistries(t *testing.T) {
+	certFile, keyFile, pool := writeClientCert(t)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	server.StartTLS()
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	type spec struct {
+		name       string
+		registries []v1alpha2.Registry
+		expError   bool
+	}
+	cases := []spec{
+		{
+			name:     "Invalid/NoClientCert",
+			expError: true,
+		},
+		{
+			name:       "Invalid/OtherHost",
+			registries: []v1alpha2.Registry{{Host: "registry.example.com", ClientCert: certFile, ClientKey: keyFile}},
+			expError:   true,
+		},
+		{
+			name:       "Valid/HostAndPort",
+			registries: []v1alpha2.Registry{{Host: u.Host, Insecure: true, ClientCert: certFile, ClientKey: keyFile}},
+		},
+		{
+			name:       "Valid/Hostname",
+			registries: []v1alpha2.Registry{{Host: u.Hostname(), Insecure: true, ClientCert: certFile, ClientKey: keyFile}},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			clients := NewClients()
+			require.NoError(t, clients.SetRegistries(c.registries))
+			client := &http.Client{Transport: clients.Transport(true)}
+			resp, err := client.Get(server.URL + "/v2/")
+			if c.expError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+	}
+}
+
+func TestSetRegistriesMissingKey(t *testing.T) {
+	certFile, _, _ := writeClientCert(t)
+	err := NewClients().SetRegistries([]v1alpha2.Registry{
+		{Host: "registry.example.com", ClientCert: certFile, ClientKey: filepath.Join(t.TempDir(), "client.key")},
+	})
+	require.Error(t, err)
+}
+
+// writeClientCert writes a self-signed client certificate and its key,
+// and returns their paths and a pool verifying the certificate
+func writeClientCert(t *testing.T) (string, string, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "oc-mirror"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
+
+func TestSetRegistriesCAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+	invalidCAFile := filepath.Join(t.TempDir(), "invalid.crt")
+	require.NoError(t, os.WriteFile(invalidCAFile, []byte("not a certificate"), 0600))
+
+	type spec struct {
+		name       string
+		registries []v1alpha2.Registry
+		expError   bool
+		expLoadErr bool
+	}
+	cases := []spec{
+		{
+			name:     "Invalid/SystemTrust",
+			expError: true,
+		},
+		{
+			name:       "Invalid/OtherHost",
+			registries: []v1alpha2.Registry{{Host: "registry.example.com", CAFile: caFile}},
+			expError:   true,
+		},
+		{
+			name:       "Invalid/NoCertificates",
+			registries: []v1alpha2.Registry{{Host: u.Host, CAFile: invalidCAFile}},
+			expLoadErr: true,
+		},
+		{
+			name:       "Valid/CAFile",
+			registries: []v1alpha2.Registry{{Host: u.Host, CAFile: caFile}},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			clients := NewClients()
+			err := clients.SetRegistries(c.registries)
+			if c.expLoadErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			client := &http.Client{Transport: clients.Transport(false)}
+			resp, err := client.Get(server.URL + "/v2/")
+			if c.expError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.NotNil(t, clients.RootCAs())
+		})
+	}
+}
+
+func TestSetRegistriesProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+	}))
+	defer proxy.Close()
+
+	clients := NewClients()
+	require.NoError(t, clients.SetRegistries([]v1alpha2.Registry{{Host: "registry.example.com", Insecure: true, Proxy: proxy.URL}}))
+
+	client := &http.Client{Transport: clients.Transport(true)}
+	resp, err := client.Get("http://registry.example.com/v2/")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, []string{"http://registry.example.com/v2/"}, proxied)
+
+	req, err := http.NewRequest(http.MethodGet, "https://registry.example.com:5000/v2/", nil)
+	require.NoError(t, err)
+	u, err := clients.Proxy(req)
+	require.NoError(t, err)
+	require.Equal(t, proxy.URL, u.String())
+
+	req, err = http.NewRequest(http.MethodGet, "https://other.example.com/v2/", nil)
+	require.NoError(t, err)
+	u, err = clients.Proxy(req)
+	require.NoError(t, err)
+	if u != nil {
+		require.NotEqual(t, proxy.URL, u.String())
+	}
+}
+
+func TestSetRegistriesInsecure(t *testing.T) {
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer httpServer.Close()
+	tlsURL, err := url.Parse(tlsServer.URL)
+	require.NoError(t, err)
+	httpURL, err := url.Parse(httpServer.URL)
+	require.NoError(t, err)
+
+	type spec struct {
+		name       string
+		registries []v1alpha2.Registry
+		url        string
+		expError   bool
+	}
+	cases := []spec{
+		{
+			name:       "Valid/SkipTLSInsecureRegistry",
+			registries: []v1alpha2.Registry{{Host: tlsURL.Host, Insecure: true}},
+			url:        tlsServer.URL,
+		},
+		{
+			name:       "Valid/PlainHTTPInsecureHostname",
+			registries: []v1alpha2.Registry{{Host: httpURL.Hostname(), Insecure: true}},
+			url:        httpServer.URL,
+		},
+		{
+			name:     "Invalid/SkipTLSNoRegistries",
+			url:      tlsServer.URL,
+			expError: true,
+		},
+		{
+			name:     "Invalid/PlainHTTPNoRegistries",
+			url:      httpServer.URL,
+			expError: true,
+		},
+		{
+			name:       "Invalid/SkipTLSNoRegistryMarkedInsecure",
+			registries: []v1alpha2.Registry{{Host: tlsURL.Host}},
+			url:        tlsServer.URL,
+			expError:   true,
+		},
+		{
+			name:       "Invalid/PlainHTTPNoRegistryMarkedInsecure",
+			registries: []v1alpha2.Registry{{Host: "registry.example.com"}},
+			url:        httpServer.URL,
+			expError:   true,
+		},
+		{
+			name:       "Invalid/SkipTLSOtherRegistry",
+			registries: []v1alpha2.Registry{{Host: "registry.example.com", Insecure: true}},
+			url:        tlsServer.URL,
+			expError:   true,
+		},
+		{
+			name:       "Invalid/PlainHTTPOtherRegistry",
+			registries: []v1alpha2.Registry{{Host: "registry.example.com", Insecure: true}},
+			url:        httpServer.URL,
+			expError:   true,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			clients := NewClients()
+			require.NoError(t, clients.SetRegistries(c.registries))
+			client := &http.Client{Transport: clients.Transport(true)}
+			resp, err := client.Get(c.url + "/v2/")
+			if c.expError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+	}
+
+	clients := NewClients()
+	require.False(t, clients.AllowsInsecureImages("registry.example.com/catalog:latest"))
+	require.NoError(t, clients.SetRegistries([]v1alpha2.Registry{{Host: "localhost", Insecure: true}}))
+	require.True(t, clients.AllowsInsecureImages("localhost:5000/catalog:latest"))
+	require.False(t, clients.AllowsInsecureImages("localhost:5000/catalog:latest", "registry.example.com/catalog:latest"))
+	require.False(t, clients.AllowsInsecureImages("quay.io/catalog:latest"))
+	require.NoError(t, clients.SetRegistries([]v1alpha2.Registry{{Host: "localhost", Proxy: "http://proxy.example.com:3128"}}))
+	require.False(t, clients.AllowsInsecure("localhost:5000"))
+	require.False(t, clients.AllowsInsecure("registry.example.com"))
+}
+
+func TestMarkInsecure(t *testing.T) {
+	registries := []v1alpha2.Registry{{Host: "localhost:5000", Proxy: "http://proxy.example.com:3128"}, {Host: "quay.io"}}
+	marked := MarkInsecure(registries, []string{"localhost:5000", "registry.example.com"})
+	require.Equal(t, []v1alpha2.Registry{
+		{Host: "localhost:5000", Proxy: "http://proxy.example.com:3128", Insecure: true},
+		{Host: "quay.io"},
+		{Host: "registry.example.com", Insecure: true},
+	}, marked)
+	require.False(t, registries[0].Insecure)
+}
+
+// insecureClients returns registry clients for which the registry is insecure
+func insecureClients(t *testing.T, host string) *Clients {
+	c := NewClients()
+	require.NoError(t, c.SetRegistries([]v1alpha2.Registry{{Host: host, Insecure: true}}))
+	return c
+}
@@ -0,0 +1,222 @@
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/docker/docker-credential-helpers/credentials"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+const (
+	// vaultTokenEnv holds the token used to read Vault secrets
+	vaultTokenEnv = "VAULT_TOKEN"
+	// vaultAddrEnv holds the address of the Vault server
+	// of the secrets configured without an address
+	vaultAddrEnv = "VAULT_ADDR"
+	// vaultNamespaceEnv holds the Vault Enterprise namespace of the secrets
+	vaultNamespaceEnv = "VAULT_NAMESPACE"
+	// vaultTokenFile is the token file written by `vault login`
+	// in the home directory, used when VAULT_TOKEN is not set
+	vaultTokenFile = ".vault-token"
+)
+
+// credentialSource returns the credentials of a registry host
+type credentialSource func(host string) (*credentials.Credentials, error)
+
+// externalCredentials gets the credentials of the registries configured with
+// a credential helper or a Vault secret when they are needed, instead of from
+// an auth file on disk, caching them for cloudCredentialTTL.
+// They take precedence over the credentials of the docker config.
+type externalCredentials struct {
+	mu      sync.Mutex
+	sources map[string]credentialSource
+	cache   map[string]cachedCredentials
+	now     func() time.Time
+}
+
+func newExternalCredentials(sources map[string]credentialSource) *externalCredentials {
+	return &externalCredentials{
+		sources: sources,
+		cache:   map[string]cachedCredentials{},
+		now:     time.Now,
+	}
+}
+
+// newCredentialSource returns the credential source of
+// a registry, or nil if its credentials are not external
+func newCredentialSource(reg v1alpha2.Registry, httpClient func() *http.Client) credentialSource {
+	switch {
+	case reg.CredentialHelper != "":
+		return helperSource(reg.CredentialHelper, client.NewShellProgramFunc)
+	case reg.Vault != nil:
+		return vaultSource(*reg.Vault, httpClient)
+	default:
+		return nil
+	}
+}
+
+// get returns the credentials of the registry host, or nil
+// if it is not configured with a source or the source fails
+func (c *externalCredentials) get(host string) *credentials.Credentials {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := host
+	source, ok := c.sources[key]
+	if !ok {
+		// Registries configured without a port match every port
+		if i := strings.LastIndexByte(host, ':'); i > 0 {
+			key = host[:i]
+			source, ok = c.sources[key]
+		}
+	}
+	if !ok {
+		return nil
+	}
+	if cached, ok := c.cache[key]; ok && c.now().Before(cached.expires) {
+		return cached.creds
+	}
+	// Failures are cached too, so a failing source
+	// is not queried again for every request
+	creds, err := source(key)
+	if err != nil {
+		logrus.Warnf("unable to get credentials for registry %s: %v", host, err)
+		creds = nil
+	} else {
+		logrus.Debugf("using external credentials for registry %s", host)
+	}
+	c.cache[key] = cachedCredentials{creds: creds, expires: c.now().Add(cloudCredentialTTL)}
+	return creds
+}
+
+// Resolve implements authn.Keychain
+func (c *externalCredentials) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	creds := c.get(target.RegistryStr())
+	switch {
+	case creds == nil:
+		return authn.Anonymous, nil
+	case creds.Username == identityTokenUsername:
+		return authn.FromConfig(authn.AuthConfig{IdentityToken: creds.Secret}), nil
+	default:
+		return authn.FromConfig(authn.AuthConfig{Username: creds.Username, Password: creds.Secret}), nil
+	}
+}
+
+// helperSource runs a program implementing the docker credential helper
+// protocol, such as an exec based credential provider
+func helperSource(program string, programFunc func(string) client.ProgramFunc) credentialSource {
+	return func(host string) (*credentials.Credentials, error) {
+		creds, err := client.Get(programFunc(program), host)
+		if err != nil {
+			return nil, fmt.Errorf("credential helper %s: %v", program, err)
+		}
+		return creds, nil
+	}
+}
+
+// vaultSource reads the credentials from a key/value secret of a Vault server
+func vaultSource(vault v1alpha2.VaultCredentials, httpClient func() *http.Client) credentialSource {
+	return func(host string) (*credentials.Credentials, error) {
+		addr := vault.Address
+		if addr == "" {
+			addr = os.Getenv(vaultAddrEnv)
+		}
+		if addr == "" {
+			return nil, fmt.Errorf("vault address is not set in the configuration or %s", vaultAddrEnv)
+		}
+		token, err := vaultToken()
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(addr, "/")+"/v1/"+strings.TrimPrefix(vault.Path, "/"), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-Vault-Token", token)
+		if namespace := os.Getenv(vaultNamespaceEnv); namespace != "" {
+			req.Header.Set("X-Vault-Namespace", namespace)
+		}
+		resp, err := httpClient().Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("error reading vault secret %s: %v", vault.Path, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("error reading vault secret %s: %s", vault.Path, resp.Status)
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		values, err := vaultSecretData(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vault secret %s: %v", vault.Path, err)
+		}
+
+		usernameKey, passwordKey := vault.UsernameKey, vault.PasswordKey
+		if usernameKey == "" {
+			usernameKey = "username"
+		}
+		if passwordKey == "" {
+			passwordKey = "password"
+		}
+		password, ok := values[passwordKey].(string)
+		if !ok || password == "" {
+			return nil, fmt.Errorf("vault secret %s has no %q key", vault.Path, passwordKey)
+		}
+		// Secrets without a username hold identity tokens
+		username, _ := values[usernameKey].(string)
+		if username == "" {
+			username = identityTokenUsername
+		}
+		return &credentials.Credentials{ServerURL: host, Username: username, Secret: password}, nil
+	}
+}
+
+// vaultSecretData returns the values of a key/value secret,
+// which are nested in a data field with version 2 of the engine
+func vaultSecretData(body []byte) (map[string]interface{}, error) {
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return nil, err
+	}
+	if nested, ok := secret.Data["data"].(map[string]interface{}); ok {
+		if _, ok := secret.Data["metadata"]; ok {
+			return nested, nil
+		}
+	}
+	return secret.Data, nil
+}
+
+// vaultToken returns the token of VAULT_TOKEN,
+// or of the token file written by `vault login`
+func vaultToken() (string, error) {
+	if token := os.Getenv(vaultTokenEnv); token != "" {
+		return token, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(home, vaultTokenFile))
+	if err != nil {
+		return "", fmt.Errorf("vault token is not set in %s or ~/%s", vaultTokenEnv, vaultTokenFile)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,124 @@
+package image
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+func TestExternalCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/registries/quay":
+			fmt.Fprint(w, `{"data":{"data":{"user":"robot","token":"quay-token"},"metadata":{"version":1}}}`)
+		case "/v1/kv/registries/example":
+			fmt.Fprint(w, `{"data":{"password":"identity-token"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv(vaultTokenEnv, "vault-token")
+	t.Setenv(vaultAddrEnv, server.URL)
+
+	var calls int
+	var host string
+	helper := helperSource("docker-credential-exec", func(path string) client.ProgramFunc {
+		return func(args ...string) client.Program {
+			return &fakeHelper{output: `{"Username":"exec","Secret":"exec-secret"}`, host: &host, calls: &calls}
+		}
+	})
+	defaultClient := func() *http.Client { return http.DefaultClient }
+	now := time.Now()
+	creds := newExternalCredentials(map[string]credentialSource{
+		"registry.example.com": helper,
+		"quay.io": vaultSource(v1alpha2.VaultCredentials{
+			Address:     server.URL,
+			Path:        "secret/data/registries/quay",
+			UsernameKey: "user",
+			PasswordKey: "token",
+		}, defaultClient),
+		"example.io": vaultSource(v1alpha2.VaultCredentials{Path: "kv/registries/example"}, defaultClient),
+		"missing.io": vaultSource(v1alpha2.VaultCredentials{Path: "kv/registries/missing"}, defaultClient),
+	})
+	creds.now = func() time.Time { return now }
+
+	type spec struct {
+		name     string
+		registry string
+		expAuth  *authn.AuthConfig
+	}
+	cases := []spec{
+		{
+			name:     "Valid/HelperAnyPort",
+			registry: "registry.example.com:5000",
+			expAuth:  &authn.AuthConfig{Username: "exec", Password: "exec-secret"},
+		},
+		{
+			name:     "Valid/VaultKV2",
+			registry: "quay.io",
+			expAuth:  &authn.AuthConfig{Username: "robot", Password: "quay-token"},
+		},
+		{
+			name:     "Valid/VaultKV1IdentityToken",
+			registry: "example.io",
+			expAuth:  &authn.AuthConfig{IdentityToken: "identity-token"},
+		},
+		{
+			name:     "Valid/VaultMissingSecret",
+			registry: "missing.io",
+			expAuth:  &authn.AuthConfig{},
+		},
+		{
+			name:     "Valid/NotConfigured",
+			registry: "docker.io",
+			expAuth:  &authn.AuthConfig{},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			reg, err := name.NewRegistry(c.registry)
+			require.NoError(t, err)
+			authenticator, err := creds.Resolve(reg)
+			require.NoError(t, err)
+			cfg, err := authenticator.Authorization()
+			require.NoError(t, err)
+			require.Equal(t, c.expAuth, cfg)
+		})
+	}
+	require.Equal(t, "registry.example.com", host)
+
+	// Credentials are cached until they expire
+	calls = 0
+	require.NotNil(t, creds.get("registry.example.com"))
+	require.Equal(t, 0, calls)
+	now = now.Add(cloudCredentialTTL)
+	require.NotNil(t, creds.get("registry.example.com"))
+	require.Equal(t, 1, calls)
+
+	// External credentials take precedence over the docker config
+	store := &cloudCredentialStore{
+		store:    &staticStore{host: "quay.io", username: "user", password: "pass"},
+		cloud:    newCloudCredentials(),
+		external: creds,
+	}
+	username, password := store.Basic(&url.URL{Host: "quay.io"})
+	require.Equal(t, "robot", username)
+	require.Equal(t, "quay-token", password)
+	require.Equal(t, "identity-token", store.RefreshToken(&url.URL{Host: "example.io"}, "example.io"))
+}
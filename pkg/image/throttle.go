@@ -0,0 +1,215 @@
+package image
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Registries throttling clients answer 429 Too Many Requests, or 502,
+// 503, or 504 while overloaded. Throttled requests are retried after the
+// delay the registry asks for in Retry-After, and the number of concurrent
+// requests to the registry is halved, then raised again by one request
+// each time as many requests as the limit succeed.
+const (
+	// maxHostRequests is the number of concurrent requests to
+	// a registry before it throttles clients, as for idle connections
+	maxHostRequests = 32
+	// throttleRetries is the number of times a throttled request is retried
+	throttleRetries = 5
+	// maxThrottleDelay caps the delay before retrying a throttled request
+	maxThrottleDelay = 2 * time.Minute
+)
+
+// baseThrottleDelay is the delay before retrying a throttled request without
+// Retry-After, doubled for each retry. It is a variable for testing.
+var baseThrottleDelay = time.Second
+
+// throttleTransport limits the concurrent requests to each registry,
+// and retries requests throttled by the registry
+type throttleTransport struct {
+	base  http.RoundTripper
+	hosts *hostLimits
+}
+
+var _ http.RoundTripper = &throttleTransport{}
+
+func (t *throttleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	limit := t.hosts.get(req.URL.Host)
+	for attempt := 0; ; attempt++ {
+		if err := limit.acquire(req); err != nil {
+			return nil, err
+		}
+		resp, err := t.base.RoundTrip(req)
+		switch {
+		case err != nil:
+			limit.release()
+			return nil, err
+		case !throttled(resp.StatusCode):
+			limit.succeeded()
+			return resp, nil
+		}
+
+		delay := retryAfter(resp.Header.Get("Retry-After"), attempt)
+		concurrent := limit.throttled(delay)
+		if attempt >= throttleRetries || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+		logrus.Warnf("registry %s is throttling requests (%s), retrying in %s with at most %d concurrent requests",
+			req.URL.Host, resp.Status, delay, concurrent)
+		_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+
+		if req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// throttled returns whether the status code asks clients to slow down
+func throttled(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter returns the delay before retrying a throttled request from
+// the Retry-After header, in seconds or as a date, or doubles the base
+// delay for each attempt when the header is missing or invalid
+func retryAfter(header string, attempt int) time.Duration {
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(header); err == nil {
+		if delay = time.Until(date); delay < 0 {
+			delay = 0
+		}
+	} else {
+		delay = baseThrottleDelay << attempt
+	}
+	if delay > maxThrottleDelay {
+		delay = maxThrottleDelay
+	}
+	return delay
+}
+
+// hostLimits holds the request limits of the registries of a run
+type hostLimits struct {
+	mu     sync.Mutex
+	limits map[string]*hostLimit
+}
+
+func newHostLimits() *hostLimits {
+	return &hostLimits{limits: map[string]*hostLimit{}}
+}
+
+func (h *hostLimits) get(host string) *hostLimit {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	l, ok := h.limits[host]
+	if !ok {
+		l = &hostLimit{limit: maxHostRequests, changed: make(chan struct{})}
+		h.limits[host] = l
+	}
+	return l
+}
+
+// hostLimit limits the concurrent requests to a registry
+type hostLimit struct {
+	mu        sync.Mutex
+	limit     int
+	inflight  int
+	successes int
+	// resume is the time throttled requests can be retried
+	resume time.Time
+	// changed is closed when requests complete or the limit changes
+	changed chan struct{}
+}
+
+// acquire waits until a request can be made to the registry
+func (l *hostLimit) acquire(req *http.Request) error {
+	for {
+		if err := req.Context().Err(); err != nil {
+			return err
+		}
+		l.mu.Lock()
+		wait := time.Until(l.resume)
+		if wait <= 0 && l.inflight < l.limit {
+			l.inflight++
+			l.mu.Unlock()
+			return nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		}
+		select {
+		case <-changed:
+		case <-expired:
+		case <-req.Context().Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// release completes a request that failed before the registry answered
+func (l *hostLimit) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inflight--
+	l.notify()
+}
+
+// succeeded completes a request that was not throttled
+// and raises the limit after as many successes
+func (l *hostLimit) succeeded() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inflight--
+	if l.successes++; l.successes >= l.limit && l.limit < maxHostRequests {
+		l.limit++
+		l.successes = 0
+	}
+	l.notify()
+}
+
+// throttled completes a throttled request, halving the limit and pausing
+// requests to the registry for the delay. It returns the new limit.
+func (l *hostLimit) throttled(delay time.Duration) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inflight--
+	l.successes = 0
+	if l.limit > 1 {
+		l.limit /= 2
+	}
+	if resume := time.Now().Add(delay); resume.After(l.resume) {
+		l.resume = resume
+	}
+	l.notify()
+	return l.limit
+}
+
+func (l *hostLimit) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
@@ -0,0 +1,137 @@
+package image
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottleTransport(t *testing.T) {
+	type spec struct {
+		name       string
+		throttles  int64
+		retryAfter string
+		body       func() *http.Request
+		expStatus  int
+		expCalls   int64
+		expLimit   int
+	}
+	newRequest := func(url string) *http.Request {
+		req, err := http.NewRequest(http.MethodPut, url, strings.NewReader("blob"))
+		require.NoError(t, err)
+		return req
+	}
+	cases := []spec{
+		{
+			name:       "Valid/RetryAfter",
+			throttles:  2,
+			retryAfter: "0",
+			expStatus:  http.StatusOK,
+			expCalls:   3,
+			expLimit:   maxHostRequests / 4,
+		},
+		{
+			name:      "Valid/Backoff",
+			throttles: 1,
+			expStatus: http.StatusOK,
+			expCalls:  2,
+			expLimit:  maxHostRequests / 2,
+		},
+		{
+			name:       "Invalid/RetriesExhausted",
+			throttles:  throttleRetries + 1,
+			retryAfter: "0",
+			expStatus:  http.StatusTooManyRequests,
+			expCalls:   throttleRetries + 1,
+			expLimit:   1,
+		},
+	}
+	baseThrottleDelay = time.Millisecond
+	t.Cleanup(func() { baseThrottleDelay = time.Second })
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var calls int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.Equal(t, "blob", string(body))
+				if atomic.AddInt64(&calls, 1) <= c.throttles {
+					if c.retryAfter != "" {
+						w.Header().Set("Retry-After", c.retryAfter)
+					}
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			t.Cleanup(server.Close)
+
+			hosts := newHostLimits()
+			rt := &throttleTransport{base: http.DefaultTransport, hosts: hosts}
+			resp, err := rt.RoundTrip(newRequest(server.URL))
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			require.Equal(t, c.expStatus, resp.StatusCode)
+			require.Equal(t, c.expCalls, atomic.LoadInt64(&calls))
+			require.Equal(t, c.expLimit, hosts.get(server.Listener.Addr().String()).limit)
+		})
+	}
+}
+
+func TestThrottleTransportUnreplayable(t *testing.T) {
+	var calls int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	// Streamed bodies cannot be sent again
+	req, err := http.NewRequest(http.MethodPut, server.URL, ioutil.NopCloser(strings.NewReader("blob")))
+	require.NoError(t, err)
+	rt := &throttleTransport{base: http.DefaultTransport, hosts: newHostLimits()}
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, int64(1), calls)
+}
+
+func TestHostLimitAcquire(t *testing.T) {
+	l := newHostLimits().get("registry.example.com")
+	l.limit = 1
+	req, err := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/", nil)
+	require.NoError(t, err)
+	require.NoError(t, l.acquire(req))
+
+	// Requests past the limit wait for a request to complete
+	acquired := make(chan error)
+	go func() { acquired <- l.acquire(req) }()
+	select {
+	case <-acquired:
+		t.Fatal("acquired past the limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+	l.succeeded()
+	require.NoError(t, <-acquired)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, l.acquire(req.WithContext(ctx)), context.Canceled)
+}
+
+func TestRetryAfter(t *testing.T) {
+	require.Equal(t, 3*time.Second, retryAfter("3", 0))
+	require.Equal(t, maxThrottleDelay, retryAfter("3600", 0))
+	require.Equal(t, time.Duration(0), retryAfter(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0))
+	require.Equal(t, 4*baseThrottleDelay, retryAfter("", 2))
+	require.Equal(t, 2*baseThrottleDelay, retryAfter("soon", 1))
+}
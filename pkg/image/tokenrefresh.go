@@ -0,0 +1,306 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Registry bearer tokens expire, often after a few minutes, and the auth
+// layers of the registry libraries keep using a token until a request fails
+// with it. tokenRefreshTransport records the token requests that go through
+// it, and renews the tokens of registry requests with them before they expire,
+// or after a request fails with 401 Unauthorized, retrying the request.
+const (
+	// defaultTokenLifetime is the lifetime of tokens without expires_in,
+	// as in the docker token authentication specification
+	defaultTokenLifetime = 60 * time.Second
+	// maxTokenRefreshMargin is the time before their expiry tokens are renewed
+	maxTokenRefreshMargin = 30 * time.Second
+	// tokenRetention is the time tokens no longer used are kept for
+	tokenRetention = time.Hour
+	// maxTokenResponseSize caps the size of token responses read
+	maxTokenResponseSize = 1 << 20
+)
+
+// tokenRefreshTransport renews the bearer tokens of registry requests
+type tokenRefreshTransport struct {
+	base   http.RoundTripper
+	tokens *bearerTokens
+}
+
+var _ http.RoundTripper = &tokenRefreshTransport{}
+
+func (t *tokenRefreshTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if tokenReq := newTokenRequest(req); tokenReq != nil {
+		return t.recordToken(req, tokenReq)
+	}
+
+	token := t.tokens.get(bearerToken(req.Header.Get("Authorization")))
+	if token == nil {
+		return t.base.RoundTrip(req)
+	}
+	current, err := token.current(req.Context(), t.base, "")
+	if err != nil {
+		// The registry answers 401 if the token expired
+		logrus.Debugf("unable to renew the token of registry %s: %v", req.URL.Host, err)
+	}
+	resp, err := t.base.RoundTrip(withToken(req, current))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || (req.Body != nil && req.GetBody == nil) {
+		return resp, err
+	}
+
+	renewed, rerr := token.current(req.Context(), t.base, current)
+	if rerr != nil {
+		logrus.Debugf("unable to renew the token of registry %s: %v", req.URL.Host, rerr)
+		return resp, nil
+	}
+	logrus.Debugf("registry %s rejected the token of %s %s, retrying with a renewed token", req.URL.Host, req.Method, req.URL.Path)
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+
+	retry := withToken(req, renewed)
+	if req.Body != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	return t.base.RoundTrip(retry)
+}
+
+// recordToken makes a token request and records the token it returns
+func (t *tokenRefreshTransport) recordToken(req *http.Request, tokenReq *tokenRequest) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxTokenResponseSize+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if len(data) > maxTokenResponseSize {
+		// Not a token response, pass the body through
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	if token, expires, err := parseTokenResponse(data, t.tokens.now()); err == nil {
+		t.tokens.add(token, expires, tokenReq)
+	}
+	return resp, nil
+}
+
+// withToken returns a copy of the request using the bearer token
+func withToken(req *http.Request, token string) *http.Request {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+// bearerToken returns the token of a bearer authorization header
+func bearerToken(header string) string {
+	if len(header) > len("Bearer ") && strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return header[len("Bearer "):]
+	}
+	return ""
+}
+
+// tokenRequest is a request for a registry token that can be made again
+type tokenRequest struct {
+	method string
+	url    *url.URL
+	header http.Header
+	body   []byte
+}
+
+// newTokenRequest returns the token request of req, or nil if it does not
+// request a token. Tokens are requested with GET requests with a service
+// parameter, or POST requests of OAuth2 grants.
+func newTokenRequest(req *http.Request) *tokenRequest {
+	tokenReq := &tokenRequest{method: req.Method, url: req.URL, header: req.Header.Clone()}
+	switch req.Method {
+	case http.MethodGet:
+		if req.URL.Query().Get("service") == "" {
+			return nil
+		}
+		return tokenReq
+	case http.MethodPost:
+		mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		if mediaType != "application/x-www-form-urlencoded" || req.GetBody == nil {
+			return nil
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil
+		}
+		defer body.Close()
+		data, err := ioutil.ReadAll(io.LimitReader(body, maxTokenResponseSize))
+		if err != nil {
+			return nil
+		}
+		if form, err := url.ParseQuery(string(data)); err != nil || form.Get("grant_type") == "" {
+			return nil
+		}
+		tokenReq.body = data
+		return tokenReq
+	default:
+		return nil
+	}
+}
+
+// do makes the token request again, returning the new token and its expiry
+func (r *tokenRequest) do(ctx context.Context, rt http.RoundTripper, now time.Time) (string, time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, r.method, r.url.String(), bytes.NewReader(r.body))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if r.body == nil {
+		req.Body = nil
+	}
+	req.Header = r.header.Clone()
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("token request to %s failed: %s", r.url.Host, resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxTokenResponseSize))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return parseTokenResponse(data, now)
+}
+
+// parseTokenResponse returns the token of a token response and its expiry
+func parseTokenResponse(data []byte, now time.Time) (string, time.Time, error) {
+	var resp struct {
+		Token       string    `json:"token"`
+		AccessToken string    `json:"access_token"`
+		ExpiresIn   int       `json:"expires_in"`
+		IssuedAt    time.Time `json:"issued_at"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return "", time.Time{}, err
+	}
+	token := resp.Token
+	if token == "" {
+		token = resp.AccessToken
+	}
+	if token == "" {
+		return "", time.Time{}, fmt.Errorf("token response has no token")
+	}
+	lifetime := defaultTokenLifetime
+	if resp.ExpiresIn > 0 {
+		lifetime = time.Duration(resp.ExpiresIn) * time.Second
+	}
+	issued := now
+	// Only trust the issue time of the registry when clocks agree
+	if !resp.IssuedAt.IsZero() && resp.IssuedAt.Before(now) && now.Sub(resp.IssuedAt) < lifetime {
+		issued = resp.IssuedAt
+	}
+	return token, issued.Add(lifetime), nil
+}
+
+// bearerTokens holds the tokens of a run by the token the
+// auth layers use, which may have been renewed since
+type bearerTokens struct {
+	mu     sync.Mutex
+	tokens map[string]*renewableToken
+	now    func() time.Time
+}
+
+func newBearerTokens() *bearerTokens {
+	return &bearerTokens{tokens: map[string]*renewableToken{}, now: time.Now}
+}
+
+func (b *bearerTokens) get(token string) *renewableToken {
+	if token == "" {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t, ok := b.tokens[token]
+	if !ok {
+		return nil
+	}
+	t.used = b.now()
+	return t
+}
+
+func (b *bearerTokens) add(token string, expires time.Time, req *tokenRequest) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	for key, t := range b.tokens {
+		if now.Sub(t.used) > tokenRetention {
+			delete(b.tokens, key)
+		}
+	}
+	b.tokens[token] = &renewableToken{
+		req:      req,
+		token:    token,
+		lifetime: expires.Sub(now),
+		expires:  expires,
+		used:     now,
+		now:      b.now,
+	}
+}
+
+// renewableToken is a bearer token and the request renewing it
+type renewableToken struct {
+	// mu serializes renewals so concurrent requests renew a token once
+	mu       sync.Mutex
+	req      *tokenRequest
+	token    string
+	lifetime time.Duration
+	expires  time.Time
+	used     time.Time
+	now      func() time.Time
+}
+
+// current returns the token, renewing it when it is about to expire or when
+// the registry rejected it and it was not renewed since. The token is
+// returned with the error when it cannot be renewed.
+func (t *renewableToken) current(ctx context.Context, rt http.RoundTripper, rejected string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	margin := t.lifetime / 4
+	if margin > maxTokenRefreshMargin {
+		margin = maxTokenRefreshMargin
+	}
+	now := t.now()
+	if rejected != "" && rejected != t.token {
+		return t.token, nil
+	}
+	if rejected == "" && now.Add(margin).Before(t.expires) {
+		return t.token, nil
+	}
+	token, expires, err := t.req.do(ctx, rt, now)
+	if err != nil {
+		return t.token, err
+	}
+	logrus.Debugf("renewed the token of registry %s", t.req.url.Host)
+	t.token, t.lifetime, t.expires = token, expires.Sub(now), expires
+	return token, nil
+}
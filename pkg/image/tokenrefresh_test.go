@@ -0,0 +1,119 @@
+package image
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenRefreshTransport(t *testing.T) {
+	var mu sync.Mutex
+	var issued int
+	valid := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/token":
+			if r.Method == http.MethodPost {
+				require.NoError(t, r.ParseForm())
+				require.Equal(t, "refresh_token", r.PostForm.Get("grant_type"))
+			}
+			issued++
+			token := fmt.Sprintf("token-%d", issued)
+			valid[token] = true
+			fmt.Fprintf(w, `{"token":%q,"expires_in":300}`, token)
+		case "/v2/foo/blobs/uploads/":
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.Equal(t, "blob", string(body))
+			if !valid[bearerToken(r.Header.Get("Authorization"))] {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, bearerToken(r.Header.Get("Authorization")))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	now := time.Now()
+	tokens := newBearerTokens()
+	tokens.now = func() time.Time { return now }
+	rt := &tokenRefreshTransport{base: http.DefaultTransport, tokens: tokens}
+
+	requestToken := func(req *http.Request) string {
+		resp, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		token, _, err := parseTokenResponse(readBody(t, resp), now)
+		require.NoError(t, err)
+		return token
+	}
+	upload := func(token string) (int, string) {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v2/foo/blobs/uploads/", strings.NewReader("blob"))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode, string(readBody(t, resp))
+	}
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/token?service=registry&scope=repository:foo:push", nil)
+	require.NoError(t, err)
+	token := requestToken(req)
+	require.Equal(t, "token-1", token)
+	status, used := upload(token)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "token-1", used)
+
+	// Tokens are renewed before they expire
+	now = now.Add(280 * time.Second)
+	status, used = upload(token)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "token-2", used)
+	status, used = upload(token)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "token-2", used)
+
+	// Requests rejected by the registry are retried with a renewed token
+	mu.Lock()
+	delete(valid, "token-2")
+	mu.Unlock()
+	status, used = upload(token)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "token-3", used)
+
+	// Tokens of OAuth2 grants are renewed too
+	form := "grant_type=refresh_token&service=registry&refresh_token=secret"
+	req, err = http.NewRequest(http.MethodPost, server.URL+"/token", strings.NewReader(form))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	token = requestToken(req)
+	require.Equal(t, "token-4", token)
+	mu.Lock()
+	delete(valid, "token-4")
+	mu.Unlock()
+	status, used = upload(token)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "token-5", used)
+
+	// Unknown tokens are passed through
+	status, _ = upload("unknown")
+	require.Equal(t, http.StatusUnauthorized, status)
+}
+
+func readBody(t *testing.T, resp *http.Response) []byte {
+	data, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return data
+}
@@ -0,0 +1,295 @@
+package image
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	gcrtransport "github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/sirupsen/logrus"
+)
+
+// Registries keep the blob upload sessions of interrupted pushes until they
+// purge them on their own, and the registry API cannot list them. While
+// uploads are recorded, the sessions started and completed through the
+// transports of the clients are appended to a journal, so the sessions
+// left open by an interrupted run can be aborted later.
+
+// uploadRecord is a line of the upload journal
+type uploadRecord struct {
+	// Session is the upload URL without its query, which identifies the session
+	Session string `json:"session"`
+	// Location is the last location of the session, whose
+	// query can hold the state of the upload
+	Location string `json:"location,omitempty"`
+	Insecure bool   `json:"insecure,omitempty"`
+	Done     bool   `json:"done,omitempty"`
+}
+
+// UploadSession is a blob upload session left open in a registry
+type UploadSession struct {
+	Location string
+	// Insecure is true when the session was started
+	// with the insecure options of the registry
+	Insecure bool
+}
+
+// uploadJournal appends the upload sessions to a file
+type uploadJournal struct {
+	mu   sync.Mutex
+	file *os.File
+	// open holds the records of the open sessions by session URL
+	open map[string]uploadRecord
+}
+
+// RecordUploads records the upload sessions in the journal at path until the
+// returned function is called. The journal is removed when no session is left open.
+func (c *Clients) RecordUploads(path string) (func() error, error) {
+	records, err := readUploadJournal(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening upload journal: %v", err)
+	}
+	j := &uploadJournal{file: file, open: records}
+
+	c.mu.Lock()
+	c.uploads = j
+	c.mu.Unlock()
+	return func() error {
+		c.mu.Lock()
+		if c.uploads == j {
+			c.uploads = nil
+		}
+		c.mu.Unlock()
+		return j.close(path)
+	}, nil
+}
+
+func (c *Clients) uploadJournal() *uploadJournal {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.uploads
+}
+
+// record appends the session to the journal
+func (j *uploadJournal) record(rec uploadRecord) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return
+	}
+	if rec.Done {
+		if _, ok := j.open[rec.Session]; !ok {
+			return
+		}
+		delete(j.open, rec.Session)
+	} else {
+		j.open[rec.Session] = rec
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		logrus.Debugf("error recording upload session %s: %v", rec.Session, err)
+	}
+}
+
+// close closes the journal and removes it when no session is left open
+func (j *uploadJournal) close(path string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	if err != nil {
+		return err
+	}
+	if len(j.open) == 0 {
+		return os.Remove(path)
+	}
+	return nil
+}
+
+// uploadTransport records the upload sessions of the
+// requests that go through it in the journal of the clients
+type uploadTransport struct {
+	base     http.RoundTripper
+	clients  *Clients
+	insecure bool
+}
+
+func (t *uploadTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || !strings.Contains(req.URL.Path, "/blobs/uploads/") {
+		return resp, err
+	}
+	j := t.clients.uploadJournal()
+	if j == nil {
+		return resp, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusAccepted && (req.Method == http.MethodPost || req.Method == http.MethodPatch):
+		location, perr := req.URL.Parse(resp.Header.Get("Location"))
+		if perr != nil || resp.Header.Get("Location") == "" {
+			break
+		}
+		j.record(uploadRecord{Session: uploadSession(location), Location: location.String(), Insecure: t.insecure})
+	case req.Method == http.MethodPut && resp.StatusCode == http.StatusCreated,
+		req.Method == http.MethodDelete && resp.StatusCode < 300,
+		resp.StatusCode == http.StatusNotFound:
+		j.record(uploadRecord{Session: uploadSession(req.URL), Done: true})
+	}
+	return resp, err
+}
+
+// uploadSession returns the session URL of an upload location
+func uploadSession(location *url.URL) string {
+	u := *location
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// readUploadJournal returns the records of the sessions left
+// open in the journal, or none when there is no journal
+func readUploadJournal(path string) (map[string]uploadRecord, error) {
+	open := map[string]uploadRecord{}
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return open, nil
+		}
+		return nil, fmt.Errorf("error reading upload journal: %v", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var rec uploadRecord
+		// A run killed while writing leaves a truncated last line
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil || rec.Session == "" {
+			continue
+		}
+		if rec.Done {
+			delete(open, rec.Session)
+			continue
+		}
+		open[rec.Session] = rec
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading upload journal: %v", err)
+	}
+	return open, nil
+}
+
+// OpenUploadSessions returns the upload sessions left
+// open in the journal at path, sorted by location
+func OpenUploadSessions(path string) ([]UploadSession, error) {
+	records, err := readUploadJournal(path)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]UploadSession, 0, len(records))
+	for _, rec := range records {
+		sessions = append(sessions, UploadSession{Location: rec.Location, Insecure: rec.Insecure})
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Location < sessions[j].Location })
+	return sessions, nil
+}
+
+// WriteUploadSessions replaces the journal at path with the open
+// sessions, removing it when there are none
+func WriteUploadSessions(path string, sessions []UploadSession) error {
+	if len(sessions) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	var data []byte
+	for _, s := range sessions {
+		location, err := url.Parse(s.Location)
+		if err != nil {
+			return err
+		}
+		line, err := json.Marshal(uploadRecord{Session: uploadSession(location), Location: s.Location, Insecure: s.Insecure})
+		if err != nil {
+			return err
+		}
+		data = append(data, append(line, '\n')...)
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// AbortUpload cancels the upload session in its registry.
+// Sessions the registry no longer has are aborted already.
+func (c *Clients) AbortUpload(ctx context.Context, s UploadSession) error {
+	location, err := url.Parse(s.Location)
+	if err != nil {
+		return err
+	}
+	repository, err := uploadRepository(location.Path)
+	if err != nil {
+		return err
+	}
+	var opts []name.Option
+	if location.Scheme == "http" {
+		opts = append(opts, name.Insecure)
+	}
+	repo, err := name.NewRepository(location.Host+"/"+repository, opts...)
+	if err != nil {
+		return err
+	}
+	auth, err := c.Keychain().Resolve(repo.Registry)
+	if err != nil {
+		return err
+	}
+	rt, err := gcrtransport.NewWithContext(ctx, repo.Registry, auth, c.Transport(s.Insecure), []string{repo.Scope(gcrtransport.PushScope)})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, location.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 300 || resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return fmt.Errorf("unexpected status %s", resp.Status)
+}
+
+// uploadRepository returns the repository of an upload location,
+// which can be under the path prefix of the registry
+func uploadRepository(path string) (string, error) {
+	i := strings.LastIndex(path, "/blobs/uploads/")
+	j := strings.Index(path, "/v2/")
+	if i < 0 || j < 0 || j+len("/v2/") >= i {
+		return "", fmt.Errorf("%s is not a blob upload location", path)
+	}
+	return path[j+len("/v2/") : i], nil
+}
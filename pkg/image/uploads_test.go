@@ -0,0 +1,139 @@
+package image
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordUploads(t *testing.T) {
+	var mu sync.Mutex
+	var deleted []string
+	reg := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete && strings.Contains(r.URL.Path, "/blobs/uploads/") {
+			mu.Lock()
+			deleted = append(deleted, r.URL.Path)
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	c := NewClients()
+	path := filepath.Join(t.TempDir(), "uploads")
+	stop, err := c.RecordUploads(path)
+	require.NoError(t, err)
+
+	// Completed uploads leave no open session
+	img, err := crane.Image(map[string][]byte{"file": []byte("content")})
+	require.NoError(t, err)
+	require.NoError(t, crane.Push(img, u.Host+"/test/image:latest", c.CraneOptions(context.Background(), false)...))
+	sessions, err := OpenUploadSessions(path)
+	require.NoError(t, err)
+	require.Empty(t, sessions)
+
+	// An interrupted upload is left open, and the journal is kept
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/v2/test/image/blobs/uploads/", nil)
+	require.NoError(t, err)
+	resp, err := (&http.Client{Transport: c.Transport(false)}).Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.NoError(t, stop())
+	sessions, err = OpenUploadSessions(path)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	require.True(t, strings.HasPrefix(sessions[0].Location, server.URL+"/v2/test/image/blobs/uploads/"))
+
+	// Aborting the session while recording closes it and removes the journal
+	stop, err = c.RecordUploads(path)
+	require.NoError(t, err)
+	require.NoError(t, c.AbortUpload(context.Background(), sessions[0]))
+	require.NoError(t, stop())
+	require.Len(t, deleted, 1)
+	require.Equal(t, strings.TrimPrefix(sessions[0].Location, server.URL), deleted[0])
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestWriteUploadSessions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "uploads")
+	sessions := []UploadSession{
+		{Location: "https://registry.example.com/v2/ns/a/blobs/uploads/1?_state=abc"},
+		{Location: "https://registry.example.com/v2/ns/b/blobs/uploads/2", Insecure: true},
+	}
+	require.NoError(t, WriteUploadSessions(path, sessions))
+	// A truncated line of an interrupted run is ignored
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"session":"https://registry.example.com/v2/ns/c/blo`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	read, err := OpenUploadSessions(path)
+	require.NoError(t, err)
+	require.Equal(t, sessions, read)
+
+	require.NoError(t, WriteUploadSessions(path, nil))
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+	require.NoError(t, WriteUploadSessions(path, nil))
+}
+
+func TestUploadRepository(t *testing.T) {
+	type spec struct {
+		name     string
+		path     string
+		exp      string
+		expError string
+	}
+	cases := []spec{
+		{
+			name: "Valid/Repository",
+			path: "/v2/ns/image/blobs/uploads/1234",
+			exp:  "ns/image",
+		},
+		{
+			name: "Valid/PathPrefix",
+			path: "/artifactory/api/docker/docker-local/v2/ns/image/blobs/uploads/1234",
+			exp:  "ns/image",
+		},
+		{
+			name:     "Invalid/NoRepository",
+			path:     "/v2/blobs/uploads/1234",
+			expError: "/v2/blobs/uploads/1234 is not a blob upload location",
+		},
+		{
+			name:     "Invalid/NotUpload",
+			path:     "/v2/ns/image/manifests/latest",
+			expError: "/v2/ns/image/manifests/latest is not a blob upload location",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			repository, err := uploadRepository(c.path)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, repository)
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"bufio"
+	"io"
+)
+
+// Metadata is pushed to registries in content defined chunks, so
+// the chunks of the metadata that did not change between sequences
+// keep their digests and are not pushed again.
+const (
+	minChunkSize = 1 << 20
+	maxChunkSize = 16 << 20
+	// chunkMask selects the high bits of the rolling hash, setting
+	// a boundary every 4MiB on average past the minimum chunk size
+	chunkMask = uint64(1<<22-1) << 42
+)
+
+// gear is the table of the rolling hash, generated from
+// a fixed seed so chunk boundaries are stable between runs
+var gear = func() (table [256]uint64) {
+	x := uint64(0x6f632d6d6972726f)
+	for i := range table {
+		// splitmix64
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// chunkOffsets returns the end offsets of the content defined chunks of r.
+// Content that is empty is a single empty chunk.
+func chunkOffsets(r io.Reader) ([]int64, error) {
+	br := bufio.NewReader(r)
+	var offsets []int64
+	var offset, size int64
+	var hash uint64
+	for {
+		c, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		offset++
+		size++
+		hash = hash<<1 + gear[c]
+		if (size >= minChunkSize && hash&chunkMask == 0) || size >= maxChunkSize {
+			offsets = append(offsets, offset)
+			size, hash = 0, 0
+		}
+	}
+	if size != 0 || len(offsets) == 0 {
+		offsets = append(offsets, offset)
+	}
+	return offsets, nil
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkOffsets(t *testing.T) {
+	data := make([]byte, 40<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	offsets, err := chunkOffsets(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Greater(t, len(offsets), 1)
+	require.Equal(t, int64(len(data)), offsets[len(offsets)-1])
+	var start int64
+	for _, end := range offsets[:len(offsets)-1] {
+		require.GreaterOrEqual(t, end-start, int64(minChunkSize))
+		require.LessOrEqual(t, end-start, int64(maxChunkSize))
+		start = end
+	}
+
+	// Inserting data only changes the chunks around it
+	changed := append(append(append([]byte{}, data[:10<<20]...), []byte("inserted")...), data[10<<20:]...)
+	changedOffsets, err := chunkOffsets(bytes.NewReader(changed))
+	require.NoError(t, err)
+	shifted := map[int64]struct{}{}
+	for _, end := range changedOffsets {
+		shifted[end-int64(len("inserted"))] = struct{}{}
+	}
+	for _, end := range offsets {
+		if end > 10<<20+maxChunkSize {
+			require.Contains(t, shifted, end)
+		}
+	}
+
+	offsets, err = chunkOffsets(bytes.NewReader(nil))
+	require.NoError(t, err)
+	require.Equal(t, []int64{0}, offsets)
+}
@@ -94,6 +94,17 @@ func (b *localDirBackend) WriteMetadata(ctx context.Context, meta *v1alpha2.Meta
 // In this implementation, key is a file path.
 func (b *localDirBackend) ReadObject(_ context.Context, fpath string, obj interface{}) error {
 
+	// Stream to writers to avoid holding large objects in memory
+	if w, ok := obj.(io.Writer); ok {
+		f, err := b.fs.Open(fpath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	}
+
 	data, err := afero.ReadFile(b.fs, fpath)
 	if err != nil {
 		return err
@@ -105,8 +116,6 @@ func (b *localDirBackend) ReadObject(_ context.Context, fpath string, obj interf
 			return io.ErrShortBuffer
 		}
 		copy(v, data)
-	case io.Writer:
-		_, err = v.Write(data)
 	default:
 		err = json.Unmarshal(data, obj)
 	}
@@ -130,7 +139,9 @@ func (b *localDirBackend) WriteObject(ctx context.Context, fpath string, obj int
 	case string:
 		data = []byte(v)
 	case io.Reader:
-		data, err = io.ReadAll(v)
+		// Stream readers to avoid holding large objects in memory
+		_, err = io.Copy(w, v)
+		return err
 	default:
 		data, err = json.Marshal(obj)
 	}
@@ -151,7 +162,7 @@ func (b *localDirBackend) GetWriter(_ context.Context, fpath string) (io.Writer,
 		return nil, fmt.Errorf("error creating object child path: %v", err)
 	}
 
-	w, err := b.fs.OpenFile(fpath, os.O_WRONLY|os.O_CREATE, 0640)
+	w, err := b.fs.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
 	if err != nil {
 		return nil, fmt.Errorf("error opening object file: %v", err)
 	}
@@ -1,27 +1,26 @@
 package storage
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"context"
-	"crypto/tls"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"net"
-	"net/http"
 	"os"
-	"path/filepath"
-	"time"
 
-	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
-	"github.com/mholt/archiver/v3"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/sirupsen/logrus"
 
 	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
 	"github.com/openshift/oc/pkg/cli/image/imagesource"
 )
 
@@ -35,10 +34,11 @@ type registryBackend struct {
 	src imagesource.TypedImageReference
 	// Registry client options
 	insecure bool
+	clients  *image.Clients
 }
 
-func NewRegistryBackend(cfg *v1alpha2.RegistryConfig, dir string) (Backend, error) {
-	b := registryBackend{}
+func NewRegistryBackend(cfg *v1alpha2.RegistryConfig, dir string, clients *image.Clients) (Backend, error) {
+	b := registryBackend{clients: clients}
 	b.insecure = cfg.SkipTLS
 
 	ref, err := imagesource.ParseReference(cfg.ImageURL)
@@ -88,28 +88,13 @@ func (b *registryBackend) ReadObject(ctx context.Context, fpath string, obj inte
 
 // WriteObject writes the provided object to disk and registry.
 // In this implementation, key is a file path.
-func (b *registryBackend) WriteObject(ctx context.Context, fpath string, obj interface{}) (err error) {
-	var data []byte
-	switch v := obj.(type) {
-	case []byte:
-		data = v
-	case string:
-		data = []byte(v)
-	case io.Reader:
-		data, err = io.ReadAll(v)
-	default:
-		data, err = json.Marshal(obj)
-	}
-	if err != nil {
-		return err
-	}
-
+func (b *registryBackend) WriteObject(ctx context.Context, fpath string, obj interface{}) error {
 	// Write metadata to disk for packing into archive
 	if err := b.localDirBackend.WriteObject(ctx, fpath, obj); err != nil {
 		return err
 	}
 	logrus.Debugf("Pushing metadata to registry at %s", b.src)
-	return b.pushImage(ctx, data, fpath)
+	return b.pushImage(ctx, fpath)
 }
 
 // GetWriter returns an os.File as a writer.
@@ -120,8 +105,12 @@ func (b *registryBackend) GetWriter(ctx context.Context, fpath string) (io.Write
 
 // Open reads the provided object from a registry source and provides an io.ReadCloser
 func (b *registryBackend) Open(ctx context.Context, fpath string) (io.ReadCloser, error) {
-	if _, err := b.Stat(ctx, fpath); err != nil {
-		if !errors.Is(err, os.ErrNotExist) {
+	if err := b.exists(ctx); err != nil {
+		return nil, err
+	}
+	// Objects missing on disk are unpacked from the image
+	if _, err := b.localDirBackend.Stat(ctx, fpath); err != nil {
+		if !errors.Is(err, ErrMetadataNotExist) {
 			return nil, err
 		}
 		if err := b.unpack(ctx, fpath); err != nil {
@@ -131,33 +120,72 @@ func (b *registryBackend) Open(ctx context.Context, fpath string) (io.ReadCloser
 	return b.localDirBackend.Open(ctx, fpath)
 }
 
+// unpack writes the files of the metadata image to disk.
+// Chunks of a file are in consecutive layers in order, so the
+// entries of a file are appended to it as its layers are read.
 func (b *registryBackend) unpack(ctx context.Context, fpath string) error {
-	tempTar := fmt.Sprintf("%s.tar", b.src.Ref.Name)
 	opts := b.getOpts(ctx)
 	img, err := crane.Pull(b.src.Ref.Exact(), opts...)
 	if err != nil {
 		return err
 	}
-	w, err := b.GetWriter(ctx, tempTar)
+	layers, err := img.Layers()
 	if err != nil {
 		return err
 	}
-	defer b.localDirBackend.fs.Remove(tempTar)
+	files := map[string]io.WriteCloser{}
+	defer func() {
+		for _, w := range files {
+			w.Close()
+		}
+	}()
+	for _, layer := range layers {
+		if err := b.unpackLayer(ctx, layer, files); err != nil {
+			return err
+		}
+	}
+	for name, w := range files {
+		delete(files, name)
+		if err := w.Close(); err != nil {
+			return err
+		}
+	}
+	// adjust perms, the files are written user-writable only
+	return b.localDirBackend.fs.Chmod(fpath, 0600)
+}
 
-	if err := crane.Export(img, w); err != nil {
+// unpackLayer appends the regular files of the layer to their files
+func (b *registryBackend) unpackLayer(ctx context.Context, layer v1.Layer, files map[string]io.WriteCloser) error {
+	rc, err := layer.Uncompressed()
+	if err != nil {
 		return err
 	}
-	arc := archiver.Tar{
-		OverwriteExisting:      true,
-		MkdirAll:               true,
-		ImplicitTopLevelFolder: false,
-		StripComponents:        0,
-		ContinueOnError:        false,
+	defer rc.Close()
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading metadata image layer: %v", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		w, ok := files[hdr.Name]
+		if !ok {
+			fw, err := b.GetWriter(ctx, hdr.Name)
+			if err != nil {
+				return err
+			}
+			w = fw.(io.WriteCloser)
+			files[hdr.Name] = w
+		}
+		if _, err := io.Copy(w, tr); err != nil {
+			return fmt.Errorf("error writing %s: %v", hdr.Name, err)
+		}
 	}
-	if err := arc.Unarchive(filepath.Join(b.localDirBackend.dir, tempTar), b.localDirBackend.dir); err != nil {
-		return err
-	} // adjust perms, unpack leaves the file user-writable only
-	return b.localDirBackend.fs.Chmod(fpath, 0600)
 }
 
 // Stat checks the existence of the metadata from a registry source
@@ -188,16 +216,64 @@ func (b *registryBackend) CheckConfig(storage v1alpha2.StorageConfig) error {
 	return nil
 }
 
-// pushImage will push a v1.Image with provided contents
-func (b *registryBackend) pushImage(ctx context.Context, data []byte, fpath string) error {
+// pushImage will push a v1.Image containing the file at fpath on disk.
+// The file is split into content defined chunks, each streamed into a
+// gzipped layer instead of read into memory, so layers of unchanged
+// chunks are already in the registry when pushing the next sequence.
+func (b *registryBackend) pushImage(ctx context.Context, fpath string) error {
 	opts := b.getOpts(ctx)
-	contents := map[string][]byte{
-		fpath: data,
+	f, err := b.localDirBackend.fs.Open(fpath)
+	if err != nil {
+		return err
+	}
+	offsets, err := chunkOffsets(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("error chunking %s: %v", fpath, err)
+	}
+
+	layers := make([]v1.Layer, 0, len(offsets))
+	var start int64
+	for _, end := range offsets {
+		layer, err := b.chunkLayer(fpath, start, end-start)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, layer)
+		start = end
+	}
+	logrus.Debugf("Pushing metadata %s in %d chunks", fpath, len(layers))
+	i, err := mutate.AppendLayers(empty.Image, layers...)
+	if err != nil {
+		return err
 	}
-	i, _ := crane.Image(contents)
 	return crane.Push(i, b.src.Ref.Exact(), opts...)
 }
 
+// chunkLayer returns a layer with a tar entry for the chunk of the file at fpath
+func (b *registryBackend) chunkLayer(fpath string, offset, size int64) (v1.Layer, error) {
+	return tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		f, err := b.localDirBackend.fs.Open(fpath)
+		if err != nil {
+			return nil, err
+		}
+		pr, pw := io.Pipe()
+		go func() {
+			defer f.Close()
+			tw := tar.NewWriter(pw)
+			err := tw.WriteHeader(&tar.Header{Name: fpath, Size: size, Typeflag: tar.TypeReg})
+			if err == nil {
+				_, err = io.Copy(tw, io.NewSectionReader(f, offset, size))
+			}
+			if err == nil {
+				err = tw.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		return pr, nil
+	}, tarball.WithCompressionLevel(gzip.BestCompression))
+}
+
 // exists checks if the image exists
 func (b *registryBackend) exists(ctx context.Context) error {
 	opts := b.getOpts(ctx)
@@ -215,7 +291,7 @@ func (b *registryBackend) exists(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
-		err = remote.CheckPushPermission(ref, authn.DefaultKeychain, b.createRT())
+		err = remote.CheckPushPermission(ref, b.clients.Keychain(), b.clients.Transport(b.insecure))
 		if err != nil {
 			return err
 		}
@@ -227,37 +303,8 @@ func (b *registryBackend) exists(ctx context.Context) error {
 	}
 }
 
-func (b *registryBackend) createRT() http.RoundTripper {
-	return &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			// By default we wrap the transport in retries, so reduce the
-			// default dial timeout to 5s to avoid 5x 30s of connection
-			// timeouts when doing the "ping" on certain http registries.
-			Timeout:   5 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		ForceAttemptHTTP2:     true,
-		MaxIdleConns:          100,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: b.insecure,
-		},
-	}
-}
-
 // TODO: Get default auth will need to update if user
 // can specify custom locations
 func (b *registryBackend) getOpts(ctx context.Context) []crane.Option {
-	options := []crane.Option{
-		crane.WithAuthFromKeychain(authn.DefaultKeychain),
-		crane.WithContext(ctx),
-		crane.WithTransport(b.createRT()),
-	}
-	if b.insecure {
-		options = append(options, crane.Insecure)
-	}
-	return options
+	return b.clients.CraneOptions(ctx, b.insecure)
 }
@@ -1,16 +1,22 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io/ioutil"
+	"math/rand"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/uuid"
 	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
 	"github.com/openshift/oc-mirror/pkg/config"
@@ -49,6 +55,7 @@ func TestRegistryBackend(t *testing.T) {
 			if err != nil {
 				t.Error(err)
 			}
+			clients := insecureClients(t, u.Host)
 
 			image := fmt.Sprintf("%s/%s", u.Host, test.image)
 			cfg := v1alpha2.RegistryConfig{
@@ -56,7 +63,7 @@ func TestRegistryBackend(t *testing.T) {
 				SkipTLS:  true,
 			}
 			ctx := context.Background()
-			backend, err := NewRegistryBackend(&cfg, filepath.Join("foo", config.SourceDir))
+			backend, err := NewRegistryBackend(&cfg, filepath.Join("foo", config.SourceDir), clients)
 			require.NoError(t, err)
 
 			m := &v1alpha2.Metadata{}
@@ -110,3 +117,92 @@ func TestRegistryBackend(t *testing.T) {
 		})
 	}
 }
+
+func TestRegistryBackendReader(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	clients := insecureClients(t, u.Host)
+
+	cfg := v1alpha2.RegistryConfig{
+		ImageURL: fmt.Sprintf("%s/metadata:latest", u.Host),
+		SkipTLS:  true,
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	backend, err := NewRegistryBackend(&cfg, dir, clients)
+	require.NoError(t, err)
+
+	// Readers are streamed to disk and to the registry
+	require.NoError(t, backend.WriteObject(ctx, "objects/data", strings.NewReader("streamed contents")))
+	require.NoError(t, backend.WriteObject(ctx, "objects/data", strings.NewReader("short")))
+	data, err := ioutil.ReadFile(filepath.Join(dir, "objects", "data"))
+	require.NoError(t, err)
+	require.Equal(t, "short", string(data))
+
+	// Reading the object back pulls it from the registry
+	require.NoError(t, os.Remove(filepath.Join(dir, "objects", "data")))
+	buf := &bytes.Buffer{}
+	rc, err := backend.Open(ctx, "objects/data")
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	require.NoError(t, backend.ReadObject(ctx, "objects/data", buf))
+	require.Equal(t, "short", buf.String())
+}
+
+func TestRegistryBackendChunks(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	clients := insecureClients(t, u.Host)
+
+	ref := fmt.Sprintf("%s/metadata:latest", u.Host)
+	cfg := v1alpha2.RegistryConfig{
+		ImageURL: ref,
+		SkipTLS:  true,
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	backend, err := NewRegistryBackend(&cfg, dir, clients)
+	require.NoError(t, err)
+
+	layerDigests := func() []string {
+		img, err := crane.Pull(ref, crane.Insecure)
+		require.NoError(t, err)
+		layers, err := img.Layers()
+		require.NoError(t, err)
+		var digests []string
+		for _, layer := range layers {
+			mt, err := layer.MediaType()
+			require.NoError(t, err)
+			require.Equal(t, types.DockerLayer, mt)
+			dgst, err := layer.Digest()
+			require.NoError(t, err)
+			digests = append(digests, dgst.String())
+		}
+		return digests
+	}
+
+	data := make([]byte, 8<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	require.NoError(t, backend.WriteObject(ctx, config.MetadataBasePath, data))
+	first := layerDigests()
+	require.Greater(t, len(first), 1)
+
+	// Changing the end of the object keeps the layers of its first chunks
+	changed := append(append([]byte{}, data...), []byte("appended")...)
+	require.NoError(t, backend.WriteObject(ctx, config.MetadataBasePath, changed))
+	second := layerDigests()
+	require.Equal(t, first[:len(first)-1], second[:len(second)-1])
+	require.NotEqual(t, first[len(first)-1], second[len(second)-1])
+
+	// Reading the object back joins the chunks
+	require.NoError(t, os.Remove(filepath.Join(dir, config.MetadataBasePath)))
+	_, err = backend.Open(ctx, config.MetadataBasePath)
+	require.NoError(t, err)
+	read, err := ioutil.ReadFile(filepath.Join(dir, config.MetadataBasePath))
+	require.NoError(t, err)
+	require.Equal(t, changed, read)
+}
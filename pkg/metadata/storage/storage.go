@@ -8,6 +8,7 @@ import (
 	"os"
 
 	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
 	"github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/yaml"
@@ -48,8 +49,9 @@ var backends = []Backend{
 	&registryBackend{},
 }
 
-// ByConfig returns backend interface based on provided config
-func ByConfig(dir string, storage v1alpha2.StorageConfig) (Backend, error) {
+// ByConfig returns backend interface based on provided config.
+// Registry backends connect with the registry clients.
+func ByConfig(dir string, storage v1alpha2.StorageConfig, clients *image.Clients) (Backend, error) {
 	var b interface{}
 	for _, bk := range backends {
 		if err := bk.CheckConfig(storage); err == nil {
@@ -63,7 +65,7 @@ func ByConfig(dir string, storage v1alpha2.StorageConfig) (Backend, error) {
 		return NewLocalBackend(storage.Local.Path)
 	case *registryBackend:
 		logrus.Debugf("Using registry backend at location %s", storage.Registry.ImageURL)
-		return NewRegistryBackend(storage.Registry, dir)
+		return NewRegistryBackend(storage.Registry, dir, clients)
 	default:
 		return nil, errors.New("unsupported backend configuration")
 	}
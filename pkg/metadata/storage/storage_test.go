@@ -13,6 +13,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/registry"
 	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
 	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
 	"github.com/stretchr/testify/require"
 )
 
@@ -27,6 +28,7 @@ func TestByConfig(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
+	clients := insecureClients(t, u.Host)
 
 	tests := []struct {
 		name        string
@@ -56,7 +58,7 @@ func TestByConfig(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 
-			backend, err := ByConfig(filepath.Join(dir, test.name), test.cfg)
+			backend, err := ByConfig(filepath.Join(dir, test.name), test.cfg, clients)
 			require.NoError(t, err)
 
 			switch v := backend.(type) {
@@ -84,3 +86,10 @@ func TestByConfig(t *testing.T) {
 		})
 	}
 }
+
+// insecureClients returns registry clients for which the registry is insecure
+func insecureClients(t *testing.T, host string) *image.Clients {
+	clients := image.NewClients()
+	require.NoError(t, clients.SetRegistries([]v1alpha2.Registry{{Host: host, Insecure: true}}))
+	return clients
+}
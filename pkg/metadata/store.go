@@ -37,7 +37,7 @@ func SyncMetadata(ctx context.Context, first storage.Backend, second storage.Bac
 
 // UpdateMetadata runs some reconciliation functions on Metadata to ensure its state is consistent
 // then uses the Backend to update the metadata storage medium.
-func UpdateMetadata(ctx context.Context, backend storage.Backend, meta *v1alpha2.Metadata, workspace string, skipTLSVerify, plainHTTP bool) error {
+func UpdateMetadata(ctx context.Context, clients *image.Clients, backend storage.Backend, meta *v1alpha2.Metadata, workspace string, skipTLSVerify, plainHTTP bool) error {
 	pastMeta := v1alpha2.NewMetadata()
 	pastReleases := map[string]string{}
 	merr := backend.ReadMetadata(ctx, &pastMeta, config.MetadataBasePath)
@@ -54,10 +54,7 @@ func UpdateMetadata(ctx context.Context, backend storage.Backend, meta *v1alpha2
 	logrus.Debugf("Resolving operator metadata")
 	var operatorErrs []error
 
-	resolver, err := containerdregistry.NewResolver("", skipTLSVerify, plainHTTP, nil)
-	if err != nil {
-		return fmt.Errorf("error creating image resolver: %v", err)
-	}
+	resolver := clients.Resolver(skipTLSVerify, plainHTTP)
 	cacheDir, err := os.MkdirTemp("", "imageset-catalog-registry-")
 	if err != nil {
 		return err
@@ -67,10 +64,18 @@ func UpdateMetadata(ctx context.Context, backend storage.Backend, meta *v1alpha2
 	logger.SetOutput(ioutil.Discard)
 	nullLogger := logrus.NewEntry(logger)
 
+	// The registry applies the insecure options to every catalog
+	refs := make([]string, 0, len(mirror.Mirror.Operators))
+	for _, operator := range mirror.Mirror.Operators {
+		refs = append(refs, operator.Catalog)
+	}
+	insecure := clients.AllowsInsecureImages(refs...)
+
 	reg, err := containerdregistry.NewRegistry(
 		containerdregistry.WithCacheDir(cacheDir),
-		containerdregistry.SkipTLSVerify(skipTLSVerify),
-		containerdregistry.WithPlainHTTP(plainHTTP),
+		containerdregistry.SkipTLSVerify(skipTLSVerify && insecure),
+		containerdregistry.WithPlainHTTP(plainHTTP && insecure),
+		containerdregistry.WithRootCAs(clients.RootCAs()),
 		// The containerd registry impl is somewhat verbose, even on the happy path,
 		// so discard all logger logs. Any important failures will be returned from
 		// registry methods and eventually logged as fatal errors.
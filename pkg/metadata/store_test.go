@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
 	"github.com/openshift/oc-mirror/pkg/metadata/storage"
 )
 
@@ -101,6 +102,7 @@ func TestUpdateMetadata_Catalogs(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
+			clients := image.NewClients()
 			inputMeta := v1alpha2.NewMetadata()
 			inputMeta.PastMirror.Mirror = c.config.Mirror
 			cfg := v1alpha2.StorageConfig{
@@ -108,9 +110,9 @@ func TestUpdateMetadata_Catalogs(t *testing.T) {
 					Path: t.TempDir(),
 				},
 			}
-			backend, err := storage.ByConfig("", cfg)
+			backend, err := storage.ByConfig("", cfg, clients)
 			require.NoError(t, err)
-			err = UpdateMetadata(context.TODO(), backend, &inputMeta, "testdata", true, true)
+			err = UpdateMetadata(context.TODO(), clients, backend, &inputMeta, "testdata", true, true)
 			if c.expError != "" {
 				require.EqualError(t, err, c.expError)
 			} else {
@@ -178,6 +180,7 @@ func TestUpdateMetadata_OCPReleases(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
+			clients := image.NewClients()
 			inputMeta := v1alpha2.NewMetadata()
 			inputMeta.PastMirror.Mirror = c.config.Mirror
 			cfg := v1alpha2.StorageConfig{
@@ -185,9 +188,9 @@ func TestUpdateMetadata_OCPReleases(t *testing.T) {
 					Path: t.TempDir(),
 				},
 			}
-			backend, err := storage.ByConfig("", cfg)
+			backend, err := storage.ByConfig("", cfg, clients)
 			require.NoError(t, err)
-			err = UpdateMetadata(context.TODO(), backend, &inputMeta, "testdata", true, true)
+			err = UpdateMetadata(context.TODO(), clients, backend, &inputMeta, "testdata", true, true)
 			if c.expError != "" {
 				require.EqualError(t, err, c.expError)
 			} else {
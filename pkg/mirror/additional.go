@@ -7,8 +7,6 @@ import (
 
 	"github.com/containerd/containerd/errdefs"
 	"github.com/openshift/oc/pkg/cli/image/imagesource"
-	"github.com/operator-framework/operator-registry/pkg/image/containerdregistry"
-	"github.com/sirupsen/logrus"
 
 	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
 	"github.com/openshift/oc-mirror/pkg/image"
@@ -26,10 +24,7 @@ func NewAdditionalOptions(mo *MirrorOptions) *AdditionalOptions {
 // Plan provides an image mapping with source and destination for provided AdditionalImages
 func (o *AdditionalOptions) Plan(ctx context.Context, imageList []v1alpha2.Image) (image.TypedImageMapping, error) {
 	mmappings := make(image.TypedImageMapping, len(imageList))
-	resolver, err := containerdregistry.NewResolver("", o.SourceSkipTLS, o.SourcePlainHTTP, nil)
-	if err != nil {
-		return nil, fmt.Errorf("error creating image resolver: %v", err)
-	}
+	resolver := o.Clients().Resolver(o.SourceSkipTLS, o.SourcePlainHTTP)
 	for _, img := range imageList {
 		// Get source image information
 		srcRef, err := imagesource.ParseReference(img.Name)
@@ -52,7 +47,7 @@ func (o *AdditionalOptions) Plan(ctx context.Context, imageList []v1alpha2.Image
 				if !isSkipErr(err) {
 					return mmappings, err
 				}
-				logrus.Warn(err)
+				o.log().Warn(err)
 				continue
 			}
 			pinnedRef, err := imagesource.ParseReference(srcImage)
@@ -7,13 +7,11 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"k8s.io/cli-runtime/pkg/genericclioptions"
 
 	"github.com/openshift/library-go/pkg/image/reference"
 	"github.com/openshift/oc/pkg/cli/image/imagesource"
 
 	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
-	"github.com/openshift/oc-mirror/pkg/cli"
 	"github.com/openshift/oc-mirror/pkg/image"
 )
 
@@ -81,14 +79,9 @@ func TestPlan_Additional(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			mo := MirrorOptions{
-				RootOptions: &cli.RootOptions{
-					Dir: tmpdir,
-					IOStreams: genericclioptions.IOStreams{
-						In:     os.Stdin,
-						Out:    os.Stdout,
-						ErrOut: os.Stderr,
-					},
-				},
+				Dir:    tmpdir,
+				Out:    os.Stdout,
+				ErrOut: os.Stderr,
 			}
 			opts := NewAdditionalOptions(&mo)
 
@@ -0,0 +1,284 @@
+package mirror
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mholt/archiver/v3"
+	"github.com/openshift/library-go/pkg/image/reference"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/archive"
+	"github.com/openshift/oc-mirror/pkg/bundle"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
+	"github.com/openshift/oc-mirror/pkg/metadata/storage"
+)
+
+// analyzeWorkers is the number of images sized concurrently
+const analyzeWorkers = 8
+
+// AnalyzeConfig plans each entry of the imageset configuration and sizes
+// the images from their remote manifests. Planning writes catalogs and
+// release data to the workspace, so a temporary workspace is used.
+func (o *MirrorOptions) AnalyzeConfig(ctx context.Context, cfg v1alpha2.ImageSetConfiguration) ([]*AnalyzedImage, error) {
+	if err := o.Clients().SetRegistries(image.MarkInsecure(cfg.Registries, o.InsecureRegistries)); err != nil {
+		return nil, err
+	}
+	tmpdir, err := ioutil.TempDir("", "oc-mirror-analyze")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpdir)
+	o.Dir = tmpdir
+	o.OutputDir = tmpdir
+	if err := bundle.MakeCreateDirs(o.Dir); err != nil {
+		return nil, err
+	}
+	return o.analyzeConfig(ctx, cfg)
+}
+
+// configEntry is an entry of an imageset configuration
+// and the configuration that only mirrors the entry
+type configEntry struct {
+	name string
+	cfg  v1alpha2.ImageSetConfiguration
+}
+
+// splitConfig splits the imageset configuration into its entries
+func splitConfig(cfg v1alpha2.ImageSetConfiguration) []configEntry {
+	var entries []configEntry
+	add := func(name string, mirror v1alpha2.Mirror) {
+		entryCfg := v1alpha2.ImageSetConfiguration{TypeMeta: cfg.TypeMeta}
+		entryCfg.Mirror = mirror
+		entryCfg.Mirror.BlockedImages = cfg.Mirror.BlockedImages
+		entries = append(entries, configEntry{name: name, cfg: entryCfg})
+	}
+
+	platform := cfg.Mirror.Platform
+	for _, ch := range platform.Channels {
+		add(fmt.Sprintf("platform.channels[%s]", ch.Name), v1alpha2.Mirror{Platform: v1alpha2.Platform{
+			Channels:     []v1alpha2.ReleaseChannel{ch},
+			Verification: platform.Verification,
+		}})
+	}
+	for _, release := range platform.Releases {
+		add(fmt.Sprintf("platform.releases[%s]", release.Name), v1alpha2.Mirror{Platform: v1alpha2.Platform{
+			Releases:     []v1alpha2.Image{release},
+			Verification: platform.Verification,
+		}})
+	}
+	for _, path := range platform.UpgradePaths {
+		add(fmt.Sprintf("platform.upgradePaths[%s-%s]", path.From, path.To), v1alpha2.Mirror{Platform: v1alpha2.Platform{
+			UpgradePaths: []v1alpha2.UpgradePath{path},
+			Verification: platform.Verification,
+		}})
+	}
+	if platform.Graph && len(platform.Channels) != 0 {
+		add("platform.graph", v1alpha2.Mirror{
+			AdditionalImages: []v1alpha2.Image{{Name: getGraphBaseImage(platform)}},
+		})
+	}
+	if len(platform.CoreOS.OSImages) != 0 || len(platform.CoreOS.MachineConfigs) != 0 {
+		add("platform.coreOS", v1alpha2.Mirror{Platform: v1alpha2.Platform{CoreOS: platform.CoreOS}})
+	}
+	for _, op := range cfg.Mirror.Operators {
+		add(fmt.Sprintf("operators[%s]", op.Catalog), v1alpha2.Mirror{Operators: []v1alpha2.Operator{op}})
+	}
+	for _, img := range cfg.Mirror.AdditionalImages {
+		add(fmt.Sprintf("additionalImages[%s]", img.Name), v1alpha2.Mirror{AdditionalImages: []v1alpha2.Image{img}})
+	}
+	for _, repo := range cfg.Mirror.Helm.Repositories {
+		for _, chart := range repo.Charts {
+			r := repo
+			r.Charts = []v1alpha2.Chart{chart}
+			add(fmt.Sprintf("helm.repositories[%s/%s]", repo.Name, chart.Name), v1alpha2.Mirror{
+				Helm: v1alpha2.Helm{Repositories: []v1alpha2.Repository{r}},
+			})
+		}
+	}
+	for _, chart := range cfg.Mirror.Helm.Local {
+		add(fmt.Sprintf("helm.local[%s]", chart.Name), v1alpha2.Mirror{
+			Helm: v1alpha2.Helm{Local: []v1alpha2.Chart{chart}},
+		})
+	}
+	return entries
+}
+
+// AnalyzedImage is an image of an imageset, its blobs,
+// and the entries that include it
+type AnalyzedImage struct {
+	Name string
+	Type v1alpha2.ImageType
+	// Entries are the configuration entries that include the image,
+	// or the type of the image when analyzing an archive
+	Entries []string
+	Blobs   image.ImageBlobs
+
+	ref reference.DockerImageReference
+}
+
+// analyzeConfig plans each entry of the configuration
+// and sizes the images from their remote manifests
+func (o *MirrorOptions) analyzeConfig(ctx context.Context, cfg v1alpha2.ImageSetConfiguration) ([]*AnalyzedImage, error) {
+	byName := map[string]*AnalyzedImage{}
+	var images []*AnalyzedImage
+	for _, entry := range splitConfig(cfg) {
+		o.log().Infof("Planning %s", entry.name)
+		entryCfg := entry.cfg
+		plan := func(ctx context.Context, cfg v1alpha2.ImageSetConfiguration) (image.TypedImageMapping, error) {
+			if len(cfg.Mirror.Operators) != 0 {
+				operator := NewOperatorOptions(o)
+				operator.SkipImagePin = o.SkipImagePin
+				return operator.PlanFull(ctx, cfg)
+			}
+			return image.TypedImageMapping{}, nil
+		}
+		mapping, err := o.run(ctx, &entryCfg, v1alpha2.NewMetadata(), plan)
+		if err != nil {
+			return nil, fmt.Errorf("error planning %s: %v", entry.name, err)
+		}
+		for src := range mapping {
+			name := src.Ref.Exact()
+			img, found := byName[name]
+			if !found {
+				img = &AnalyzedImage{Name: name, Type: src.Category, ref: src.Ref}
+				byName[name] = img
+				images = append(images, img)
+			}
+			if !containsString(img.Entries, entry.name) {
+				img.Entries = append(img.Entries, entry.name)
+			}
+		}
+	}
+
+	o.log().Infof("Getting the size of %d images", len(images))
+	work := make(chan *AnalyzedImage)
+	g, ctx := errgroup.WithContext(ctx)
+	for i := 0; i < analyzeWorkers; i++ {
+		g.Go(func() error {
+			for img := range work {
+				blobs, err := o.Clients().GetRemoteImageBlobs(ctx, img.ref, o.SourceSkipTLS, o.SourcePlainHTTP, o.SkipVerification)
+				if err != nil {
+					o.log().Warnf("unable to get the size of image %s: %v", img.Name, err)
+				}
+				img.Blobs = blobs
+			}
+			return nil
+		})
+	}
+	g.Go(func() error {
+		defer close(work)
+		for _, img := range images {
+			select {
+			case work <- img:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	return images, g.Wait()
+}
+
+// AnalyzeArchive sizes the images of the imageset at path, an archive or
+// a directory of archives, from the blobs in its archives. Imagesets do not
+// record the configuration entries of images, so images are attributed
+// to their type.
+func (o *MirrorOptions) AnalyzeArchive(ctx context.Context, path string) ([]*AnalyzedImage, error) {
+	a := archive.NewArchiver()
+	filesInArchive, err := bundle.ReadImageSet(a, path)
+	if err != nil {
+		return nil, err
+	}
+	metaArchive, ok := filesInArchive[config.MetadataBasePath]
+	if !ok {
+		return nil, errors.New("metadata is not in archive")
+	}
+	tmpdir, err := ioutil.TempDir("", "oc-mirror-analyze")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpdir)
+	if err := a.Extract(metaArchive, config.MetadataBasePath, tmpdir); err != nil {
+		return nil, err
+	}
+	workspace, err := storage.NewLocalBackend(tmpdir)
+	if err != nil {
+		return nil, err
+	}
+	var meta v1alpha2.Metadata
+	if err := workspace.ReadMetadata(ctx, &meta, config.MetadataBasePath); err != nil {
+		return nil, err
+	}
+	assocs, err := image.ConvertToAssociationSet(meta.PastMirror.Associations)
+	if err != nil {
+		return nil, err
+	}
+
+	archives := map[string]struct{}{}
+	for _, path := range filesInArchive {
+		archives[path] = struct{}{}
+	}
+	sizes := map[string]int64{}
+	for path := range archives {
+		if err := archiveBlobSizes(a, path, sizes); err != nil {
+			return nil, err
+		}
+	}
+	return archivedImages(assocs, sizes), nil
+}
+
+// archiveBlobSizes adds the sizes of the blobs in the archive to sizes
+func archiveBlobSizes(a archive.Archiver, path string, sizes map[string]int64) error {
+	return a.Walk(path, func(f archiver.File) error {
+		hdr, ok := f.Header.(*tar.Header)
+		if !ok {
+			return fmt.Errorf("file type not currently implemented %v", f.Header)
+		}
+		name := filepath.Clean(hdr.Name)
+		if filepath.Dir(name) == config.BlobDir && hdr.Typeflag == tar.TypeReg {
+			sizes[filepath.Base(name)] = hdr.Size
+		}
+		return nil
+	})
+}
+
+// archivedImages returns the images of the associations with the
+// sizes of their blobs. Blobs that are not archived, because a previous
+// imageset included them, are left out.
+func archivedImages(assocs image.AssociationSet, sizes map[string]int64) []*AnalyzedImage {
+	var images []*AnalyzedImage
+	for _, key := range assocs.Keys() {
+		img := &AnalyzedImage{
+			Name:  key,
+			Blobs: image.ImageBlobs{Layers: map[string]int64{}, Configs: map[string]int64{}},
+		}
+		for _, assoc := range assocs[key] {
+			if assoc.Name == key {
+				img.Type = assoc.Type
+			}
+			for i, digest := range assoc.LayerDigests {
+				size, found := sizes[digest]
+				if !found {
+					continue
+				}
+				// The config blob is associated after the layers
+				if i == len(assoc.LayerDigests)-1 {
+					img.Blobs.Configs[digest] = size
+				} else {
+					img.Blobs.Layers[digest] = size
+				}
+			}
+		}
+		img.Entries = []string{img.Type.String()}
+		images = append(images, img)
+	}
+	return images
+}
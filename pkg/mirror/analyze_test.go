@@ -0,0 +1,104 @@
+package mirror
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/archive"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestSplitConfig(t *testing.T) {
+	cfg := v1alpha2.ImageSetConfiguration{}
+	cfg.Mirror = v1alpha2.Mirror{
+		Platform: v1alpha2.Platform{
+			Graph: true,
+			Channels: []v1alpha2.ReleaseChannel{
+				{Name: "stable-4.9"},
+				{Name: "stable-4.10"},
+			},
+			BootImages: v1alpha2.BootImages{Platforms: []string{"metal"}},
+		},
+		Operators: []v1alpha2.Operator{
+			{Catalog: "registry.redhat.io/redhat/redhat-operator-index:v4.10"},
+		},
+		AdditionalImages: []v1alpha2.Image{
+			{Name: "registry.redhat.io/ubi8/ubi:latest"},
+		},
+		Helm: v1alpha2.Helm{
+			Repositories: []v1alpha2.Repository{
+				{Name: "podinfo", URL: "https://stefanprodan.github.io/podinfo", Charts: []v1alpha2.Chart{{Name: "podinfo"}, {Name: "frontend"}}},
+			},
+		},
+		BlockedImages: []v1alpha2.Image{{Name: "alpine"}},
+	}
+
+	entries := splitConfig(cfg)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.name)
+		require.Equal(t, cfg.Mirror.BlockedImages, entry.cfg.Mirror.BlockedImages)
+		// Downloads that do not add images are not planned
+		require.False(t, entry.cfg.Mirror.Platform.Graph)
+		require.Empty(t, entry.cfg.Mirror.Platform.BootImages.Platforms)
+	}
+	require.Equal(t, []string{
+		"platform.channels[stable-4.9]",
+		"platform.channels[stable-4.10]",
+		"platform.graph",
+		"operators[registry.redhat.io/redhat/redhat-operator-index:v4.10]",
+		"additionalImages[registry.redhat.io/ubi8/ubi:latest]",
+		"helm.repositories[podinfo/podinfo]",
+		"helm.repositories[podinfo/frontend]",
+	}, names)
+
+	require.Equal(t, []v1alpha2.ReleaseChannel{{Name: "stable-4.10"}}, entries[1].cfg.Mirror.Platform.Channels)
+	require.Equal(t, []v1alpha2.Image{{Name: graphBaseImage}}, entries[2].cfg.Mirror.AdditionalImages)
+	require.Equal(t, []v1alpha2.Chart{{Name: "frontend"}}, entries[6].cfg.Mirror.Helm.Repositories[0].Charts)
+}
+
+func TestAnalyzeArchive(t *testing.T) {
+	// Archive two blobs of an image, leaving out a layer
+	// from a previous imageset
+	path := filepath.Join(t.TempDir(), "mirror_seq2_000000.tar")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	tw := tar.NewWriter(f)
+	for name, size := range map[string]int{
+		"blobs/sha256:layer2":                        2048,
+		"blobs/sha256:config":                        512,
+		"v2/ubi8/ubi/manifests/sha256:d31c6ea5c50be": 100,
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(size), Typeflag: tar.TypeReg}))
+		_, err := tw.Write(make([]byte, size))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, f.Close())
+
+	sizes := map[string]int64{}
+	require.NoError(t, archiveBlobSizes(archive.NewArchiver(), path, sizes))
+	require.Equal(t, map[string]int64{"sha256:layer2": 2048, "sha256:config": 512}, sizes)
+
+	assocs := image.AssociationSet{
+		"registry.redhat.io/ubi8/ubi:latest": image.Associations{
+			"registry.redhat.io/ubi8/ubi:latest": {
+				Name:         "registry.redhat.io/ubi8/ubi:latest",
+				Path:         "ubi8/ubi",
+				Type:         v1alpha2.TypeGeneric,
+				LayerDigests: []string{"sha256:layer1", "sha256:layer2", "sha256:config"},
+			},
+		},
+	}
+	images := archivedImages(assocs, sizes)
+	require.Len(t, images, 1)
+	require.Equal(t, []string{"generic"}, images[0].Entries)
+	require.Equal(t, v1alpha2.TypeGeneric, images[0].Type)
+	require.Equal(t, map[string]int64{"sha256:layer2": 2048}, images[0].Blobs.Layers)
+	require.Equal(t, map[string]int64{"sha256:config": 512}, images[0].Blobs.Configs)
+}
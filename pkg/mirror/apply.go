@@ -0,0 +1,221 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// applyFieldManager is the field manager used for server-side apply
+const applyFieldManager = "oc-mirror"
+
+// ClusterClients create the clients of the cluster manifests are
+// applied to. The factory of the kubectl libraries implements it.
+type ClusterClients interface {
+	KubernetesClientSet() (*kubernetes.Clientset, error)
+	ToRESTMapper() (meta.RESTMapper, error)
+	DynamicClient() (dynamic.Interface, error)
+}
+
+// applyReleaseSignatures creates or updates the release signature
+// ConfigMaps found in sigDir on the cluster configured by f
+func (o *MirrorOptions) applyReleaseSignatures(ctx context.Context, f ClusterClients, sigDir string) (err error) {
+	done := o.startPhase(phaseApply)
+	defer func() { done(err) }()
+
+	cms, err := loadSignatureConfigMaps(sigDir)
+	if err != nil {
+		return err
+	}
+	if len(cms) == 0 {
+		o.log().Debug("No release signatures found, skipping signature apply")
+		return nil
+	}
+
+	client, err := f.KubernetesClientSet()
+	if err != nil {
+		return fmt.Errorf("error creating cluster client: %v", err)
+	}
+
+	for _, cm := range cms {
+		if err := applyConfigMap(ctx, client.CoreV1(), cm); err != nil {
+			return fmt.Errorf("error applying release signature %s/%s: %v", cm.Namespace, cm.Name, err)
+		}
+		o.log().Infof("Applied release signature %s/%s", cm.Namespace, cm.Name)
+	}
+	return nil
+}
+
+// applyConfigMap creates the ConfigMap or replaces the
+// existing ConfigMap of the same name
+func applyConfigMap(ctx context.Context, client corev1client.ConfigMapsGetter, cm *corev1.ConfigMap) error {
+	cms := client.ConfigMaps(cm.Namespace)
+	_, err := cms.Create(ctx, cm, metav1.CreateOptions{})
+	if err == nil || !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	existing, err := cms.Get(ctx, cm.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	cm.ResourceVersion = existing.ResourceVersion
+	_, err = cms.Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
+
+// applyManifests applies the generated manifests in dir and the release signature
+// ConfigMaps in sigDir to the cluster configured by f using server-side apply.
+// With dryRun set, the changes are validated by the server but not persisted.
+func (o *MirrorOptions) applyManifests(ctx context.Context, f ClusterClients, dir, sigDir string, dryRun bool) (err error) {
+	done := o.startPhase(phaseApply)
+	defer func() { done(err) }()
+
+	objs, err := readManifests(dir)
+	if err != nil {
+		return err
+	}
+	sigObjs, err := readManifests(sigDir)
+	if err != nil {
+		return err
+	}
+	objs = append(objs, sigObjs...)
+	if len(objs) == 0 {
+		o.log().Info("No manifests found to apply")
+		return nil
+	}
+
+	mapper, err := f.ToRESTMapper()
+	if err != nil {
+		return fmt.Errorf("error creating cluster REST mapper: %v", err)
+	}
+	client, err := f.DynamicClient()
+	if err != nil {
+		return fmt.Errorf("error creating cluster client: %v", err)
+	}
+
+	force := true
+	opts := metav1.PatchOptions{FieldManager: applyFieldManager, Force: &force}
+	suffix := ""
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+		suffix = " (server dry run)"
+	}
+
+	for _, obj := range objs {
+		desc := fmt.Sprintf("%s %s", obj.GetKind(), obj.GetName())
+		if obj.GetNamespace() != "" {
+			desc = fmt.Sprintf("%s %s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+		}
+
+		gvk := obj.GroupVersionKind()
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			if meta.IsNoMatchError(err) {
+				// e.g. the UpdateService CRD is only present
+				// when the update service operator is installed
+				o.log().Warnf("Skipping %s: %s is not served by the cluster", desc, gvk)
+				continue
+			}
+			return err
+		}
+
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		resource := client.Resource(mapping.Resource)
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			_, err = resource.Namespace(obj.GetNamespace()).Patch(ctx, obj.GetName(), types.ApplyPatchType, data, opts)
+		} else {
+			_, err = resource.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, opts)
+		}
+		if err != nil {
+			if dryRun && apierrors.IsNotFound(err) {
+				// The namespace is not created during a dry run
+				o.log().Infof("Would apply %s%s: %v", desc, suffix, err)
+				continue
+			}
+			return fmt.Errorf("error applying %s: %v", desc, err)
+		}
+		o.log().Infof("Applied %s%s", desc, suffix)
+	}
+	return nil
+}
+
+// readManifests reads the Kubernetes objects in the YAML and JSON files in dir.
+// Namespaces are returned first so namespaced objects can be created in them.
+func readManifests(dir string) ([]*unstructured.Unstructured, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var objs []*unstructured.Unstructured
+	for _, file := range files {
+		if file.IsDir() || file.Name() == kustomizationFile || file.Name() == configSnapshotFile {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(file.Name())) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		fileObjs, err := decodeManifests(data)
+		if err != nil {
+			return nil, fmt.Errorf("error reading manifest %s: %v", file.Name(), err)
+		}
+		objs = append(objs, fileObjs...)
+	}
+
+	sort.SliceStable(objs, func(i, j int) bool {
+		return objs[i].GetKind() == "Namespace" && objs[j].GetKind() != "Namespace"
+	})
+	return objs, nil
+}
+
+// decodeManifests decodes the objects in a multi-document YAML or JSON manifest
+func decodeManifests(data []byte) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objs, nil
+			}
+			return nil, err
+		}
+		// Skip empty documents
+		if len(obj.Object) == 0 {
+			continue
+		}
+		objs = append(objs, obj)
+	}
+}
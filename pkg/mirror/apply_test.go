@@ -0,0 +1,55 @@
+package mirror
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadManifests(t *testing.T) {
+	dir := t.TempDir()
+	icsps := `---
+apiVersion: operator.openshift.io/v1alpha1
+kind: ImageContentSourcePolicy
+metadata:
+  name: release-0
+---
+apiVersion: operator.openshift.io/v1alpha1
+kind: ImageContentSourcePolicy
+metadata:
+  name: generic-0
+`
+	updateService := `---
+apiVersion: updateservice.operator.openshift.io/v1
+kind: UpdateService
+metadata:
+  name: update-service-oc-mirror
+  namespace: openshift-update-service
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: openshift-update-service
+`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "imageContentSourcePolicy.yaml"), []byte(icsps), 0640))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "updateService.yaml"), []byte(updateService), 0640))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "mapping.txt"), []byte("a=b\n"), 0640))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "signature-sha256-3e590f0381f73fe7.json"),
+		[]byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"sha256-3e590f0381f73fe7","namespace":"openshift-config-managed"}}`), 0640))
+
+	objs, err := readManifests(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, obj := range objs {
+		names = append(names, obj.GetKind()+"/"+obj.GetName())
+	}
+	require.Equal(t, []string{
+		"Namespace/openshift-update-service",
+		"ImageContentSourcePolicy/release-0",
+		"ImageContentSourcePolicy/generic-0",
+		"ConfigMap/sha256-3e590f0381f73fe7",
+		"UpdateService/update-service-oc-mirror",
+	}, names)
+}
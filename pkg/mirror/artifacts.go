@@ -0,0 +1,190 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/openshift/library-go/pkg/verify/util"
+	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+// sigstoreArtifactSuffixes are the tag suffixes of the cosign
+// signatures, attestations, and SBOMs attached to an image
+var sigstoreArtifactSuffixes = []string{".sig", ".att", ".sbom"}
+
+// planSigstoreArtifacts returns the mapping of the cosign signatures, attestations,
+// and SBOMs attached to the registry images of the mapping with the sigstore tag
+// convention (sha256-<hash>.<suffix>), and of the manifests referring to them
+// listed by the OCI referrers API. Artifacts are mirrored next to their image.
+func (o *MirrorOptions) planSigstoreArtifacts(ctx context.Context, mapping image.TypedImageMapping) (image.TypedImageMapping, error) {
+	var insecure bool
+	if o.SourcePlainHTTP || o.SourceSkipTLS {
+		insecure = true
+	}
+
+	srcs := make([]image.TypedImage, 0, len(mapping))
+	for src := range mapping {
+		if src.Type == imagesource.DestinationRegistry {
+			srcs = append(srcs, src)
+		}
+	}
+	sort.Slice(srcs, func(i, j int) bool {
+		return srcs[i].Ref.Exact() < srcs[j].Ref.Exact()
+	})
+
+	var mu sync.Mutex
+	artifacts := image.TypedImageMapping{}
+	work := make(chan image.TypedImage)
+	g, ctx := errgroup.WithContext(ctx)
+	for i := 0; i < analyzeWorkers; i++ {
+		g.Go(func() error {
+			for src := range work {
+				found, err := o.imageArtifacts(ctx, src, mapping[src], insecure)
+				if err != nil {
+					o.log().Warnf("error finding the signatures and attestations of %s: %v", src.Ref.Exact(), err)
+					continue
+				}
+				mu.Lock()
+				artifacts.Merge(found)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	g.Go(func() error {
+		defer close(work)
+		for _, src := range srcs {
+			select {
+			case work <- src:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	o.log().Infof("Found %d signatures, attestations, and SBOMs of %d images", len(artifacts), len(srcs))
+	return artifacts, nil
+}
+
+// imageArtifacts returns the mapping of the artifacts attached to an image
+func (o *MirrorOptions) imageArtifacts(ctx context.Context, src, dst image.TypedImage, insecure bool) (image.TypedImageMapping, error) {
+	nameOpts := getNameOpts(insecure)
+	remoteOpts := o.getRemoteOpts(ctx, insecure)
+
+	repo, err := name.NewRepository(src.Ref.AsRepository().Exact(), nameOpts...)
+	if err != nil {
+		return nil, err
+	}
+	digest := src.Ref.ID
+	if digest == "" {
+		desc, err := remote.Head(repo.Tag(src.Ref.Tag), remoteOpts...)
+		if err != nil {
+			return nil, err
+		}
+		digest = desc.Digest.String()
+	}
+	prefix, err := util.DigestToKeyPrefix(digest, "-")
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := image.TypedImageMapping{}
+	add := func(tag, id string) {
+		artifactSrc, artifactDst := src.TypedImageReference, dst.TypedImageReference
+		artifactSrc.Ref.Tag, artifactSrc.Ref.ID = tag, id
+		artifactDst.Ref.Tag, artifactDst.Ref.ID = tag, id
+		artifacts.Add(artifactSrc, artifactDst, v1alpha2.TypeGeneric)
+	}
+	for _, suffix := range sigstoreArtifactSuffixes {
+		tag := prefix + suffix
+		desc, err := remote.Head(repo.Tag(tag), remoteOpts...)
+		if err != nil {
+			if isNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		add(tag, desc.Digest.String())
+	}
+
+	referrers, err := o.listReferrers(ctx, repo, digest, insecure)
+	if err != nil {
+		return nil, err
+	}
+	for _, desc := range referrers {
+		add("", desc.Digest.String())
+	}
+	return artifacts, nil
+}
+
+// listReferrers returns the manifests referring to the digest listed by
+// the OCI referrers API. Registries without the API have no referrers.
+func (o *MirrorOptions) listReferrers(ctx context.Context, repo name.Repository, digest string, insecure bool) ([]v1.Descriptor, error) {
+	auth, err := o.Clients().Keychain().Resolve(repo)
+	if err != nil {
+		return nil, err
+	}
+	rt, err := transport.NewWithContext(ctx, repo.Registry, auth, o.Clients().Transport(insecure), []string{repo.Scope(transport.PullScope)})
+	if err != nil {
+		return nil, err
+	}
+	u := url.URL{
+		Scheme: repo.Registry.Scheme(),
+		Host:   repo.RegistryStr(),
+		Path:   fmt.Sprintf("/v2/%s/referrers/%s", repo.RepositoryStr(), digest),
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(types.OCIImageIndex))
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusBadRequest:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unexpected status listing referrers of %s: %s", digest, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var index v1.IndexManifest
+	if err := json.Unmarshal(data, &index); err != nil {
+		// Registries serving something else than an
+		// index do not implement the referrers API
+		o.log().Debugf("invalid referrers of %s: %v", digest, err)
+		return nil, nil
+	}
+	return index.Manifests, nil
+}
+
+// isNotFound returns whether the registry error is a 404
+func isNotFound(err error) bool {
+	var terr *transport.Error
+	return errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound
+}
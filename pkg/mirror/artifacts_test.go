@@ -0,0 +1,65 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestPlanSigstoreArtifacts(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	markInsecure(t, u.Host)
+
+	img, err := crane.Image(map[string][]byte{"file": []byte("content")})
+	require.NoError(t, err)
+	ref := fmt.Sprintf("%s/team/app:v1", u.Host)
+	require.NoError(t, crane.Push(img, ref))
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	sig, err := crane.Image(map[string][]byte{"sig": []byte("signature")})
+	require.NoError(t, err)
+	sigTag := fmt.Sprintf("sha256-%s.sig", digest.Hex)
+	require.NoError(t, crane.Push(sig, fmt.Sprintf("%s/team/app:%s", u.Host, sigTag)))
+	sigDigest, err := sig.Digest()
+	require.NoError(t, err)
+
+	unsigned, err := crane.Image(map[string][]byte{"file": []byte("other")})
+	require.NoError(t, err)
+	unsignedRef := fmt.Sprintf("%s/team/other:v1", u.Host)
+	require.NoError(t, crane.Push(unsigned, unsignedRef))
+
+	mapping := image.TypedImageMapping{}
+	for _, r := range []string{ref, unsignedRef} {
+		src, err := imagesource.ParseReference(r)
+		require.NoError(t, err)
+		dst, err := imagesource.ParseReference("file://" + src.Ref.AsRepository().RepositoryName() + ":v1")
+		require.NoError(t, err)
+		mapping.Add(src, dst, v1alpha2.TypeGeneric)
+	}
+
+	opts := &MirrorOptions{SourcePlainHTTP: true}
+	artifacts, err := opts.planSigstoreArtifacts(context.TODO(), mapping)
+	require.NoError(t, err)
+	require.Len(t, artifacts, 1)
+	for src, dst := range artifacts {
+		require.Equal(t, sigTag, src.Ref.Tag)
+		require.Equal(t, sigDigest.String(), src.Ref.ID)
+		require.Equal(t, imagesource.DestinationFile, dst.Type)
+		require.Equal(t, "team/app", dst.Ref.RepositoryName())
+		require.Equal(t, sigTag, dst.Ref.Tag)
+	}
+}
@@ -0,0 +1,78 @@
+package mirror
+
+import (
+	"fmt"
+	"path/filepath"
+
+	units "github.com/docker/go-units"
+
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
+	"github.com/openshift/oc-mirror/pkg/image/blobcache"
+)
+
+// parseCacheMaxSize parses the size the blob cache is pruned to,
+// returning zero when the size is not set or zero for no limit
+func parseCacheMaxSize(size string) (int64, error) {
+	if size == "" {
+		return 0, nil
+	}
+	maxSize, err := units.RAMInBytes(size)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cache max size %q: %v", size, err)
+	}
+	if maxSize < 0 {
+		return 0, fmt.Errorf("cache max size %q must not be negative", size)
+	}
+	return maxSize, nil
+}
+
+// openBlobCache returns the blob cache of the run, or nil if no cache is configured
+func (o *MirrorOptions) openBlobCache() (*blobcache.Cache, error) {
+	if o.CacheDir == "" {
+		return nil, nil
+	}
+	maxSize, err := parseCacheMaxSize(o.CacheMaxSize)
+	if err != nil {
+		return nil, err
+	}
+	return blobcache.New(o.CacheDir, maxSize)
+}
+
+// cacheBlobs adds the downloaded blobs of the associations to the cache
+// and prunes the cache to its max size
+func (o *MirrorOptions) cacheBlobs(cache *blobcache.Cache, assocs image.AssociationSet) {
+	v2Dir := filepath.Join(o.Dir, config.SourceDir, config.V2Dir)
+	var added int
+	seen := map[string]struct{}{}
+	for _, imageName := range assocs.Keys() {
+		values, _ := assocs.Search(imageName)
+		for _, assoc := range values {
+			for _, digest := range assoc.LayerDigests {
+				if _, found := seen[digest]; found {
+					continue
+				}
+				seen[digest] = struct{}{}
+				path := filepath.Join(v2Dir, filepath.FromSlash(assoc.Path), config.BlobDir, digest)
+				ok, err := cache.Add(digest, path)
+				if err != nil {
+					o.log().Warnf("unable to add blob %s of image %s to the blob cache: %v", digest, assoc.Name, err)
+					continue
+				}
+				if ok {
+					added++
+				}
+			}
+		}
+	}
+	stats, err := cache.Prune()
+	if err != nil {
+		o.log().Warnf("unable to prune the blob cache %s: %v", cache.Dir(), err)
+		return
+	}
+	if stats.RemovedBlobs != 0 {
+		o.log().Infof("Evicted %d blobs (%s) from the blob cache", stats.RemovedBlobs, units.BytesSize(float64(stats.RemovedBytes)))
+	}
+	o.log().Infof("Added %d blobs to the blob cache %s, which holds %d blobs (%s)",
+		added, cache.Dir(), stats.Blobs, units.BytesSize(float64(stats.Bytes)))
+}
@@ -0,0 +1,83 @@
+package mirror
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestParseCacheMaxSize(t *testing.T) {
+	type spec struct {
+		name     string
+		size     string
+		exp      int64
+		expError string
+	}
+	cases := []spec{
+		{name: "Valid/Unset", size: "", exp: 0},
+		{name: "Valid/Size", size: "200GiB", exp: 200 * 1024 * 1024 * 1024},
+		{name: "Invalid/Size", size: "large", expError: `invalid cache max size "large": invalid size: 'large'`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			size, err := parseCacheMaxSize(c.size)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, size)
+		})
+	}
+}
+
+func TestCacheBlobs(t *testing.T) {
+	o := &MirrorOptions{
+		Dir:          t.TempDir(),
+		CacheDir:     t.TempDir(),
+		CacheMaxSize: "1KiB",
+	}
+	cache, err := o.openBlobCache()
+	require.NoError(t, err)
+
+	// Blobs shared between repositories are cached once
+	v2Dir := filepath.Join(o.Dir, config.SourceDir, config.V2Dir)
+	layer := digest.FromString("layer").String()
+	cfg := digest.FromString("config").String()
+	assocs := image.AssociationSet{}
+	for _, repo := range []string{"ubi8/ubi", "ubi8/ubi-minimal"} {
+		blobDir := filepath.Join(v2Dir, repo, config.BlobDir)
+		require.NoError(t, os.MkdirAll(blobDir, 0750))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(blobDir, layer), []byte("layer"), 0600))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(blobDir, cfg), []byte("config"), 0600))
+		name := "registry.redhat.io/" + repo + ":latest"
+		assocs.Add(name, v1alpha2.Association{
+			Name:         name,
+			Path:         repo,
+			ID:           digest.FromString(repo).String(),
+			TagSymlink:   "latest",
+			Type:         v1alpha2.TypeGeneric,
+			LayerDigests: []string{layer, cfg},
+		})
+	}
+	o.cacheBlobs(cache, assocs)
+
+	stats, err := cache.Prune()
+	require.NoError(t, err)
+	require.Equal(t, 2, stats.Blobs)
+	require.Equal(t, int64(len("layer")+len("config")), stats.Bytes)
+
+	// Cached blobs are linked into the workspace of the next run
+	dst := filepath.Join(t.TempDir(), layer)
+	_, linked, err := cache.Link(layer, dst)
+	require.NoError(t, err)
+	require.True(t, linked)
+}
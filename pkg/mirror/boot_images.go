@@ -0,0 +1,269 @@
+package mirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/config"
+)
+
+const (
+	// CoreOS stream metadata pinned by the installer for each release branch
+	coreosStreamURLFmt = "https://raw.githubusercontent.com/openshift/installer/release-%s/data/data/coreos/rhcos.json"
+	// checksumFile stores the sha256sum formatted checksums of the downloaded artifacts
+	checksumFile = "sha256sum.txt"
+)
+
+// coreosArchitectures maps Cincinnati architectures to CoreOS stream architectures
+var coreosArchitectures = map[string]string{
+	"amd64":   "x86_64",
+	"arm64":   "aarch64",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+}
+
+// coreosStream is the subset of the CoreOS stream metadata
+// needed to locate boot artifacts.
+type coreosStream struct {
+	Architectures map[string]struct {
+		Artifacts map[string]struct {
+			Release string                               `json:"release"`
+			Formats map[string]map[string]coreosArtifact `json:"formats"`
+		} `json:"artifacts"`
+	} `json:"architectures"`
+}
+
+type coreosArtifact struct {
+	Location string `json:"location"`
+	Sha256   string `json:"sha256"`
+}
+
+// downloadBootImages downloads the RHCOS boot artifacts for the requested platforms
+// for every minor version covered by the release channels.
+func (o *ReleaseOptions) downloadBootImages(ctx context.Context, channels []v1alpha2.ReleaseChannel, bootImages v1alpha2.BootImages) error {
+	streams, err := getBootImageStreams(channels)
+	if err != nil {
+		return err
+	}
+
+	archs := o.getBootImageArchitectures(o.getArchitectures(channels))
+
+	bootImagesDir := filepath.Join(o.Dir, config.SourceDir, config.BootImagesDir)
+	for _, stream := range streams {
+		meta, err := o.getCoreOSStream(ctx, fmt.Sprintf(coreosStreamURLFmt, stream))
+		if err != nil {
+			return fmt.Errorf("error retrieving RHCOS stream metadata for %s: %v", stream, err)
+		}
+		for _, arch := range archs {
+			dir := filepath.Join(bootImagesDir, stream, arch)
+			if err := o.downloadBootArtifacts(ctx, meta, arch, bootImages.Platforms, dir); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// getBootImageArchitectures returns the sorted CoreOS stream architectures
+// of the release architectures, without duplicates.
+func (o *MirrorOptions) getBootImageArchitectures(releaseArchs []string) []string {
+	found := map[string]struct{}{}
+	for _, arch := range releaseArchs {
+		if arch == "multi" {
+			// Heterogeneous clusters can boot any architecture
+			for _, a := range coreosArchitectures {
+				found[a] = struct{}{}
+			}
+			continue
+		}
+		coreosArch, ok := coreosArchitectures[arch]
+		if !ok {
+			o.log().Warnf("No RHCOS boot images available for architecture %s, skipping", arch)
+			continue
+		}
+		found[coreosArch] = struct{}{}
+	}
+
+	archs := make([]string, 0, len(found))
+	for arch := range found {
+		archs = append(archs, arch)
+	}
+	sort.Strings(archs)
+	return archs
+}
+
+// getBootImageStreams returns the minor versions between the minimum and maximum
+// version of each channel. RHCOS boot images are published per minor version.
+func getBootImageStreams(channels []v1alpha2.ReleaseChannel) ([]string, error) {
+	found := map[string]struct{}{}
+	for _, ch := range channels {
+		min, err := semver.Parse(ch.MinVersion)
+		if err != nil {
+			return nil, fmt.Errorf("channel %q: %v", ch.Name, err)
+		}
+		max, err := semver.Parse(ch.MaxVersion)
+		if err != nil {
+			return nil, fmt.Errorf("channel %q: %v", ch.Name, err)
+		}
+		for minor := min.Minor; minor <= max.Minor && min.Major == max.Major; minor++ {
+			found[fmt.Sprintf("%d.%d", min.Major, minor)] = struct{}{}
+		}
+	}
+
+	streams := make([]string, 0, len(found))
+	for stream := range found {
+		streams = append(streams, stream)
+	}
+	sort.Strings(streams)
+	return streams, nil
+}
+
+func (o *MirrorOptions) downloadBootArtifacts(ctx context.Context, meta coreosStream, arch string, platforms []string, dir string) error {
+	archMeta, ok := meta.Architectures[arch]
+	if !ok {
+		o.log().Warnf("RHCOS stream metadata does not contain architecture %s, skipping", arch)
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+
+	var checksums []string
+	for _, platform := range platforms {
+		platformMeta, ok := archMeta.Artifacts[platform]
+		if !ok {
+			o.log().Warnf("RHCOS stream metadata does not contain platform %s for architecture %s, skipping", platform, arch)
+			continue
+		}
+		for _, format := range platformMeta.Formats {
+			for _, artifact := range format {
+				fileName := path.Base(artifact.Location)
+				o.log().Infof("Downloading RHCOS boot image %s", fileName)
+				if err := o.downloadVerifiedFile(ctx, artifact.Location, filepath.Join(dir, fileName), artifact.Sha256); err != nil {
+					return fmt.Errorf("error downloading %s: %v", artifact.Location, err)
+				}
+				checksums = append(checksums, fmt.Sprintf("%s  %s", artifact.Sha256, fileName))
+			}
+		}
+	}
+
+	return writeChecksums(dir, checksums)
+}
+
+// writeChecksums writes the sha256sum formatted checksum lines to
+// the checksum file in dir
+func writeChecksums(dir string, checksums []string) error {
+	if len(checksums) == 0 {
+		return nil
+	}
+	sort.Strings(checksums)
+	data := []byte(strings.Join(checksums, "\n") + "\n")
+	return os.WriteFile(filepath.Join(dir, checksumFile), data, 0640)
+}
+
+func (o *MirrorOptions) getCoreOSStream(ctx context.Context, url string) (coreosStream, error) {
+	var meta coreosStream
+	resp, err := o.httpGet(ctx, url)
+	if err != nil {
+		return meta, err
+	}
+	defer resp.Body.Close()
+
+	return meta, json.NewDecoder(resp.Body).Decode(&meta)
+}
+
+// downloadVerifiedFile downloads the file at url to dst and
+// checks the content against the expected sha256 checksum.
+// The file is removed when the download fails.
+func (o *MirrorOptions) downloadVerifiedFile(ctx context.Context, url, dst, expSha256 string) (err error) {
+	resp, err := o.httpGet(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	dst = filepath.Clean(dst)
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			if rerr := os.Remove(dst); rerr != nil && !errors.Is(rerr, os.ErrNotExist) {
+				o.log().Warnf("error removing partially downloaded file %s: %v", dst, rerr)
+			}
+		}
+	}()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, hasher), resp.Body); err != nil {
+		return err
+	}
+
+	if actual := hex.EncodeToString(hasher.Sum(nil)); expSha256 != "" && actual != expSha256 {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", expSha256, actual)
+	}
+	return nil
+}
+
+func (o *MirrorOptions) httpGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	tls, err := getTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	client := http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: tls,
+			Proxy:           o.Clients().Proxy,
+			DialContext:     o.Clients().DialContext,
+		},
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected HTTP status: %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// unpackBootImages will unpack the RHCOS boot images if they exist
+func (o *MirrorOptions) unpackBootImages(dstDir string, filesInArchive map[string]string) error {
+	if err := unpack(config.BootImagesDir, dstDir, filesInArchive); err != nil {
+		nferr := &ErrArchiveFileNotFound{}
+		if errors.As(err, &nferr) || errors.Is(err, os.ErrNotExist) {
+			o.log().Debug("No RHCOS boot images found in archive, skipping")
+			return nil
+		}
+		return err
+	}
+	o.log().Infof("Wrote RHCOS boot images to %s", dstDir)
+	return nil
+}
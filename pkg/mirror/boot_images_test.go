@@ -0,0 +1,132 @@
+package mirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+func TestGetBootImageStreams(t *testing.T) {
+	tests := []struct {
+		name     string
+		channels []v1alpha2.ReleaseChannel
+		exp      []string
+		expError string
+	}{
+		{
+			name: "Valid/SingleMinor",
+			channels: []v1alpha2.ReleaseChannel{
+				{Name: "stable-4.9", MinVersion: "4.9.10", MaxVersion: "4.9.18"},
+			},
+			exp: []string{"4.9"},
+		},
+		{
+			name: "Valid/MultipleMinorsAcrossChannels",
+			channels: []v1alpha2.ReleaseChannel{
+				{Name: "stable-4.7", MinVersion: "4.6.13", MaxVersion: "4.7.18"},
+				{Name: "stable-4.8", MinVersion: "4.7.20", MaxVersion: "4.8.2"},
+			},
+			exp: []string{"4.6", "4.7", "4.8"},
+		},
+		{
+			name: "Invalid/BadVersion",
+			channels: []v1alpha2.ReleaseChannel{
+				{Name: "stable-4.9", MinVersion: "4.9", MaxVersion: "4.9.18"},
+			},
+			expError: `channel "stable-4.9": No Major.Minor.Patch elements found`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			streams, err := getBootImageStreams(test.channels)
+			if test.expError != "" {
+				require.EqualError(t, err, test.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.exp, streams)
+		})
+	}
+}
+
+func TestGetBootImageArchitectures(t *testing.T) {
+	tests := []struct {
+		name  string
+		archs []string
+		exp   []string
+	}{
+		{
+			name:  "Valid/Single",
+			archs: []string{"amd64"},
+			exp:   []string{"x86_64"},
+		},
+		{
+			name:  "Valid/MultiWithDuplicates",
+			archs: []string{"s390x", "multi", "amd64"},
+			exp:   []string{"aarch64", "ppc64le", "s390x", "x86_64"},
+		},
+		{
+			name:  "Valid/UnknownArchitecture",
+			archs: []string{"riscv64", "arm64"},
+			exp:   []string{"aarch64"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.exp, NewMirrorOptions().getBootImageArchitectures(test.archs))
+		})
+	}
+}
+
+func TestDownloadVerifiedFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("rhcos"))
+	}))
+	defer server.Close()
+	sum := sha256.Sum256([]byte("rhcos"))
+
+	tests := []struct {
+		name     string
+		sha256   string
+		expError string
+	}{
+		{
+			name: "Valid/NoChecksum",
+		},
+		{
+			name:   "Valid/MatchingChecksum",
+			sha256: hex.EncodeToString(sum[:]),
+		},
+		{
+			name:     "Invalid/ChecksumMismatch",
+			sha256:   "0000000000000000000000000000000000000000000000000000000000000000",
+			expError: "checksum mismatch",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dst := filepath.Join(t.TempDir(), "rhcos.iso")
+			err := NewMirrorOptions().downloadVerifiedFile(context.Background(), server.URL+"/rhcos.iso", dst, test.sha256)
+			if test.expError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), test.expError)
+				// Partially written files are removed
+				require.NoFileExists(t, dst)
+				return
+			}
+			require.NoError(t, err)
+			data, err := os.ReadFile(dst)
+			require.NoError(t, err)
+			require.Equal(t, "rhcos", string(data))
+		})
+	}
+}
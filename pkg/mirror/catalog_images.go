@@ -15,8 +15,6 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/layout"
 	"github.com/openshift/library-go/pkg/image/reference"
 	"github.com/operator-framework/operator-registry/pkg/containertools"
-	"github.com/operator-framework/operator-registry/pkg/image/containerdregistry"
-	"github.com/sirupsen/logrus"
 
 	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
 	"github.com/openshift/oc-mirror/pkg/config"
@@ -31,7 +29,7 @@ func (o *MirrorOptions) unpackCatalog(dstDir string, filesInArchive map[string]s
 	if err := unpack(config.CatalogsDir, dstDir, filesInArchive); err != nil {
 		nferr := &ErrArchiveFileNotFound{}
 		if errors.As(err, &nferr) || errors.Is(err, os.ErrNotExist) {
-			logrus.Debug("No catalogs found in archive, skipping catalog rebuild")
+			o.log().Debug("No catalogs found in archive, skipping catalog rebuild")
 			return found, nil
 		}
 		return found, err
@@ -106,10 +104,7 @@ func (o *MirrorOptions) rebuildCatalogs(ctx context.Context, dstDir string) (ima
 		return nil, err
 	}
 
-	resolver, err := containerdregistry.NewResolver("", o.DestSkipTLS, o.DestPlainHTTP, nil)
-	if err != nil {
-		return nil, fmt.Errorf("error creating image resolver: %v", err)
-	}
+	resolver := o.Clients().Resolver(o.DestSkipTLS, o.DestPlainHTTP)
 
 	// Resolve the image's digest for ICSP creation.
 	for source, dest := range refs {
@@ -121,6 +116,11 @@ func (o *MirrorOptions) rebuildCatalogs(ctx context.Context, dstDir string) (ima
 		refs[source] = dest
 	}
 
+	if err := o.signImages(ctx, refs); err != nil {
+		return nil, err
+	}
+	o.recordRebuiltImages(refs)
+
 	return refs, nil
 }
 
@@ -138,13 +138,13 @@ func (o *MirrorOptions) processCatalogRefs(ctx context.Context, catalogsByImage
 
 		// Check push permissions before trying to resolve for Quay compatibility
 		nameOpts := getNameOpts(destInsecure)
-		remoteOpts := getRemoteOpts(ctx, destInsecure)
+		remoteOpts := o.getRemoteOpts(ctx, destInsecure)
 		imgBuilder := &builder.ImageBuilder{
 			NameOpts:   nameOpts,
 			RemoteOpts: remoteOpts,
 		}
 
-		logrus.Infof("Rendering catalog image %q with file-based catalog ", refExact)
+		o.log().Infof("Rendering catalog image %q with file-based catalog ", refExact)
 
 		add, err := builder.LayerFromPath("/configs", filepath.Join(artifactDir, config.IndexDir, "index.json"))
 		if err != nil {
@@ -0,0 +1,140 @@
+package mirror
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+const (
+	// checksumsFile lists the SHA-256 checksum of each file
+	// in the results directory in sha256sum format
+	checksumsFile = "SHA256SUMS"
+	// checksumsSignatureFile is the armored detached
+	// signature of the checksums file
+	checksumsSignatureFile = checksumsFile + ".asc"
+)
+
+// writeChecksums writes the checksums of every file in the results directory
+// and signs them with the private key at SignResultsKey when set.
+// Consumers can verify the results with `sha256sum -c SHA256SUMS`
+// and `gpg --verify SHA256SUMS.asc SHA256SUMS`.
+func (o *MirrorOptions) writeChecksums() error {
+	dir, err := o.createResultsDir()
+	if err != nil {
+		return err
+	}
+	data, err := generateChecksums(dir)
+	if err != nil {
+		return fmt.Errorf("error generating results checksums: %v", err)
+	}
+	path := filepath.Join(dir, checksumsFile)
+	if err := ioutil.WriteFile(path, data, 0640); err != nil {
+		return fmt.Errorf("error writing results checksums: %v", err)
+	}
+	o.log().Infof("Wrote results checksums to %s", path)
+
+	if o.SignResultsKey == "" {
+		return nil
+	}
+	entity, err := readSigningKey(o.SignResultsKey)
+	if err != nil {
+		return err
+	}
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSignText(&sig, entity, bytes.NewReader(data), nil); err != nil {
+		return fmt.Errorf("error signing results checksums: %v", err)
+	}
+	path = filepath.Join(dir, checksumsSignatureFile)
+	if err := ioutil.WriteFile(path, sig.Bytes(), 0640); err != nil {
+		return fmt.Errorf("error writing results checksums signature: %v", err)
+	}
+	o.log().Infof("Wrote results checksums signature to %s", path)
+	return nil
+}
+
+// generateChecksums returns the sha256sum formatted checksums of the
+// files under dir, sorted by path and excluding the checksums themselves and the run log
+func generateChecksums(dir string) ([]byte, error) {
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		// The run log is written until the run ends
+		if rel == checksumsFile || rel == checksumsSignatureFile || isRunLog(rel) {
+			return nil
+		}
+		paths = append(paths, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	for _, rel := range paths {
+		sum, err := fileChecksum(filepath.Join(dir, rel))
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "%s  %s\n", sum, filepath.ToSlash(rel))
+	}
+	return buf.Bytes(), nil
+}
+
+// fileChecksum returns the hex encoded SHA-256 checksum of the file at path
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readSigningKey reads the first private key from the
+// armored or binary unencrypted keyring at path
+func readSigningKey(path string) (*openpgp.Entity, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading signing key: %v", err)
+	}
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	if err != nil {
+		keyring, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing signing key %s: %v", path, err)
+		}
+	}
+	for _, entity := range keyring {
+		if entity.PrivateKey == nil {
+			continue
+		}
+		if entity.PrivateKey.Encrypted {
+			return nil, fmt.Errorf("signing key %s is encrypted, a passphrase-less key is required", path)
+		}
+		return entity, nil
+	}
+	return nil, fmt.Errorf("no private key found in %s", path)
+}
@@ -0,0 +1,55 @@
+package mirror
+
+import (
+	"bytes"
+	"crypto"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+func TestWriteChecksums(t *testing.T) {
+	expChecksums := `2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae  imageContentSourcePolicy.yaml
+fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9  release-signatures/signature-1.json
+`
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "release-signatures"), 0750))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "imageContentSourcePolicy.yaml"), []byte("foo"), 0640))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "release-signatures", "signature-1.json"), []byte("bar"), 0640))
+
+	pgpConfig := &packet.Config{DefaultHash: crypto.SHA256}
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", pgpConfig)
+	require.NoError(t, err)
+	keyBuf := &bytes.Buffer{}
+	w, err := armor.Encode(keyBuf, openpgp.PrivateKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.SerializePrivate(w, pgpConfig))
+	require.NoError(t, w.Close())
+	keyPath := filepath.Join(t.TempDir(), "key.asc")
+	require.NoError(t, ioutil.WriteFile(keyPath, keyBuf.Bytes(), 0600))
+
+	opts := &MirrorOptions{resultsDir: dir, SignResultsKey: keyPath}
+	require.NoError(t, opts.writeChecksums())
+	// Rewriting must not include the checksums or signature themselves
+	require.NoError(t, opts.writeChecksums())
+
+	checksums, err := ioutil.ReadFile(filepath.Join(dir, checksumsFile))
+	require.NoError(t, err)
+	require.Equal(t, expChecksums, string(checksums))
+
+	sig, err := os.Open(filepath.Join(dir, checksumsSignatureFile))
+	require.NoError(t, err)
+	defer sig.Close()
+	signer, err := openpgp.CheckArmoredDetachedSignature(openpgp.EntityList{entity}, bytes.NewReader(checksums), sig)
+	require.NoError(t, err)
+	require.Equal(t, entity.PrimaryKey.KeyId, signer.PrimaryKey.KeyId)
+
+	opts.SignResultsKey = filepath.Join(t.TempDir(), "missing.asc")
+	require.Error(t, opts.writeChecksums())
+}
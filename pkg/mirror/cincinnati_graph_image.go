@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
@@ -18,8 +19,6 @@ import (
 	"github.com/openshift/oc-mirror/pkg/image"
 	"github.com/openshift/oc-mirror/pkg/image/builder"
 	"github.com/openshift/oc/pkg/cli/image/imagesource"
-	"github.com/operator-framework/operator-registry/pkg/image/containerdregistry"
-	"github.com/sirupsen/logrus"
 )
 
 // This is a temporary solution until this data is distributed as container images
@@ -40,7 +39,7 @@ func (o *MirrorOptions) unpackRelease(dstDir string, filesInArchive map[string]s
 	if err := unpack(config.GraphDataDir, dstDir, filesInArchive); err != nil {
 		nferr := &ErrArchiveFileNotFound{}
 		if errors.As(err, &nferr) || errors.Is(err, os.ErrNotExist) {
-			logrus.Debug("No  graph data found in archive, skipping graph image build")
+			o.log().Debug("No  graph data found in archive, skipping graph image build")
 			return found, nil
 		}
 		return found, err
@@ -49,8 +48,16 @@ func (o *MirrorOptions) unpackRelease(dstDir string, filesInArchive map[string]s
 	return found, nil
 }
 
+// getGraphBaseImage returns the configured graph base image or the default base image
+func getGraphBaseImage(platform v1alpha2.Platform) string {
+	if platform.GraphBaseImage != "" {
+		return platform.GraphBaseImage
+	}
+	return graphBaseImage
+}
+
 // buildGraphImage builds and publishes an image containing the unpacked Cincinnati graph data
-func (o *MirrorOptions) buildGraphImage(ctx context.Context, dstDir string) (image.TypedImageMapping, error) {
+func (o *MirrorOptions) buildGraphImage(ctx context.Context, dstDir, baseImage string) (image.TypedImageMapping, error) {
 	refs := image.TypedImageMapping{}
 
 	var destInsecure bool
@@ -59,7 +66,7 @@ func (o *MirrorOptions) buildGraphImage(ctx context.Context, dstDir string) (ima
 	}
 
 	nameOpts := getNameOpts(destInsecure)
-	remoteOpts := getRemoteOpts(ctx, destInsecure)
+	remoteOpts := o.getRemoteOpts(ctx, destInsecure)
 	var err error
 	mirrorRef := imagesource.TypedImageReference{Type: imagesource.DestinationRegistry}
 	mirrorRef.Ref, err = reference.Parse(o.ToMirror)
@@ -67,11 +74,11 @@ func (o *MirrorOptions) buildGraphImage(ctx context.Context, dstDir string) (ima
 		return nil, err
 	}
 
-	// The UBI image has been pulled and is expected to be available
+	// The base image has been pulled and is expected to be available
 	// as a base for the graph image
-	ubiImage, err := imagesource.ParseReference(graphBaseImage)
+	ubiImage, err := imagesource.ParseReference(baseImage)
 	if err != nil {
-		return refs, fmt.Errorf("error parsing image %q: %v", graphBaseImage, err)
+		return refs, fmt.Errorf("error parsing image %q: %v", baseImage, err)
 	}
 
 	ubiImage.Ref.Registry = mirrorRef.Ref.Registry
@@ -80,6 +87,9 @@ func (o *MirrorOptions) buildGraphImage(ctx context.Context, dstDir string) (ima
 	graphImage := ubiImage
 	graphImage.Ref.Namespace = path.Join(o.UserNamespace, "openshift")
 	graphImage.Ref.Name = "graph-image"
+	// The base image may be pinned by digest
+	graphImage.Ref.ID = ""
+	graphImage.Ref.Tag = "latest"
 
 	imgBuilder := builder.ImageBuilder{
 		NameOpts:   nameOpts,
@@ -102,16 +112,13 @@ func (o *MirrorOptions) buildGraphImage(ctx context.Context, dstDir string) (ima
 		return refs, fmt.Errorf("error creating OCI layout: %v", err)
 	}
 	if err := imgBuilder.Run(ctx, graphImage.Ref.Exact(), layoutPath, update, add); err != nil {
-		return refs, nil
+		return refs, fmt.Errorf("error building graph image %q: %v", graphImage.Ref.Exact(), err)
 	}
 
 	// Add to mapping for UpdateService manifest generation
 	refs.Add(graphImage, graphImage, v1alpha2.TypeCincinnatiGraph)
 
-	resolver, err := containerdregistry.NewResolver("", o.DestSkipTLS, o.DestPlainHTTP, nil)
-	if err != nil {
-		return nil, fmt.Errorf("error creating image resolver: %v", err)
-	}
+	resolver := o.Clients().Resolver(o.DestSkipTLS, o.DestPlainHTTP)
 
 	// Resolve the image's digest for UpdateService manifest creation
 	for source, dest := range refs {
@@ -123,11 +130,17 @@ func (o *MirrorOptions) buildGraphImage(ctx context.Context, dstDir string) (ima
 		refs[source] = dest
 	}
 
+	if err := o.signImages(ctx, refs); err != nil {
+		return nil, err
+	}
+	o.recordRebuiltImages(refs)
+
 	return refs, nil
 }
 
-// downloadsGraphData will download the current Cincinnati graph data
-func downloadGraphData(ctx context.Context, dir, url string) error {
+// downloadsGraphData will download the current Cincinnati graph data.
+// The source may be an http(s) URL or a local path to the graph data archive.
+func (o *MirrorOptions) downloadGraphData(ctx context.Context, dir, source string) error {
 	// TODO(jpower432): It would be helpful to validate
 	// the source of this downloaded file before processing
 	// it further
@@ -138,7 +151,21 @@ func downloadGraphData(ctx context.Context, dir, url string) error {
 	}
 	defer out.Close()
 
-	req, err := http.NewRequest("GET", graphURL, nil)
+	u, err := url.Parse(source)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https":
+	case "file":
+		return o.copyGraphData(out, u.Path)
+	case "":
+		return o.copyGraphData(out, source)
+	default:
+		return fmt.Errorf("unsupported graph data source scheme %q", u.Scheme)
+	}
+
+	req, err := http.NewRequest("GET", source, nil)
 	if err != nil {
 		return err
 	}
@@ -150,7 +177,8 @@ func downloadGraphData(ctx context.Context, dir, url string) error {
 	}
 	transport := &http.Transport{
 		TLSClientConfig: tls,
-		Proxy:           http.ProxyFromEnvironment,
+		Proxy:           o.Clients().Proxy,
+		DialContext:     o.Clients().DialContext,
 	}
 	client.Transport = transport
 	timeoutCtx, cancel := context.WithTimeout(ctx, getDataTimeout)
@@ -169,3 +197,15 @@ func downloadGraphData(ctx context.Context, dir, url string) error {
 	_, err = io.Copy(out, resp.Body)
 	return err
 }
+
+// copyGraphData copies a local graph data archive to out
+func (o *MirrorOptions) copyGraphData(out io.Writer, path string) error {
+	o.log().Infof("Using local graph data from %s", path)
+	in, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	_, err = io.Copy(out, in)
+	return err
+}
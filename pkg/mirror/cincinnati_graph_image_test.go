@@ -0,0 +1,61 @@
+package mirror
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadGraphData(t *testing.T) {
+	data := []byte("graph data")
+	localArchive := filepath.Join(t.TempDir(), "graph.tar.gz")
+	require.NoError(t, ioutil.WriteFile(localArchive, data, 0640))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name     string
+		source   string
+		expError string
+	}{
+		{
+			name:   "Valid/LocalPath",
+			source: localArchive,
+		},
+		{
+			name:   "Valid/FileURL",
+			source: "file://" + localArchive,
+		},
+		{
+			name:   "Valid/HTTP",
+			source: server.URL + "/graph.tar.gz",
+		},
+		{
+			name:     "Invalid/Scheme",
+			source:   "ftp://example.com/graph.tar.gz",
+			expError: `unsupported graph data source scheme "ftp"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			err := NewMirrorOptions().downloadGraphData(context.TODO(), dir, test.source)
+			if test.expError != "" {
+				require.EqualError(t, err, test.expError)
+				return
+			}
+			require.NoError(t, err)
+			actual, err := ioutil.ReadFile(filepath.Join(dir, outputFile))
+			require.NoError(t, err)
+			require.Equal(t, data, actual)
+		})
+	}
+}
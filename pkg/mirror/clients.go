@@ -0,0 +1,158 @@
+package mirror
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/config"
+)
+
+const (
+	// clientsURLFmt is the location of the published client
+	// binaries for a release architecture and version
+	clientsURLFmt = "https://mirror.openshift.com/pub/openshift-v4/%s/clients/ocp/%s"
+	// releaseVersionLabel is set on release payloads to the release version
+	releaseVersionLabel = "io.openshift.release"
+)
+
+// clientFiles are the published binary archives for each supported operating system
+var clientFiles = map[string][]string{
+	"linux":   {"openshift-client-linux-%s.tar.gz", "openshift-install-linux-%s.tar.gz"},
+	"mac":     {"openshift-client-mac-%s.tar.gz", "openshift-install-mac-%s.tar.gz"},
+	"windows": {"openshift-client-windows-%s.zip"},
+}
+
+// downloadClients downloads the client and installer binaries
+// matching the version of each release payload
+func (o *ReleaseOptions) downloadClients(ctx context.Context, releases downloads, clients v1alpha2.Clients) error {
+	clientsDir := filepath.Join(o.Dir, config.SourceDir, config.ClientsDir)
+
+	opts := o.Clients().CraneOptions(ctx, o.insecure)
+
+	for img := range releases {
+		version, arch, err := getReleaseVersion(img, opts...)
+		if err != nil {
+			return fmt.Errorf("error retrieving version for release %s: %v", img, err)
+		}
+		o.log().Infof("Adding client binaries for release %s", version)
+
+		baseURL := fmt.Sprintf(clientsURLFmt, arch, version)
+		sums, err := o.getChecksums(ctx, baseURL+"/"+checksumFile)
+		if err != nil {
+			return fmt.Errorf("error retrieving client checksums for release %s: %v", version, err)
+		}
+
+		dir := filepath.Join(clientsDir, version, arch)
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			return err
+		}
+
+		var checksums []string
+		for _, opSys := range clients.OperatingSystems {
+			for _, fileFmt := range clientFiles[opSys] {
+				fileName := fmt.Sprintf(fileFmt, version)
+				sum, ok := sums[fileName]
+				if !ok {
+					o.log().Warnf("Client binary %s is not published for release %s, skipping", fileName, version)
+					continue
+				}
+				o.log().Infof("Downloading client binary %s", fileName)
+				if err := o.downloadVerifiedFile(ctx, baseURL+"/"+fileName, filepath.Join(dir, fileName), sum); err != nil {
+					return fmt.Errorf("error downloading %s: %v", fileName, err)
+				}
+				checksums = append(checksums, fmt.Sprintf("%s  %s", sum, fileName))
+			}
+		}
+
+		if err := writeChecksums(dir, checksums); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getReleaseVersion returns the release version and the
+// client download architecture of a release payload
+func getReleaseVersion(img string, opts ...crane.Option) (string, string, error) {
+	arch := "multi"
+	desc, err := crane.Head(img, opts...)
+	if err != nil {
+		return "", "", err
+	}
+
+	data, err := crane.Config(img, opts...)
+	if err != nil {
+		return "", "", err
+	}
+	cfg, err := v1.ParseConfigFile(bytes.NewReader(data))
+	if err != nil {
+		return "", "", err
+	}
+	version, ok := cfg.Config.Labels[releaseVersionLabel]
+	if !ok {
+		return "", "", fmt.Errorf("label %s not found", releaseVersionLabel)
+	}
+
+	if desc.MediaType != types.OCIImageIndex && desc.MediaType != types.DockerManifestList {
+		coreosArch, ok := coreosArchitectures[cfg.Architecture]
+		if !ok {
+			return "", "", fmt.Errorf("unsupported architecture %s", cfg.Architecture)
+		}
+		arch = coreosArch
+	}
+
+	return version, arch, nil
+}
+
+// getChecksums retrieves the published checksums at url
+func (o *MirrorOptions) getChecksums(ctx context.Context, url string) (map[string]string, error) {
+	resp, err := o.httpGet(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return parseChecksums(resp.Body)
+}
+
+// parseChecksums parses sha256sum formatted lines into
+// a map of file names to checksums
+func parseChecksums(r io.Reader) (map[string]string, error) {
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		sums[fields[1]] = fields[0]
+	}
+	return sums, scanner.Err()
+}
+
+// unpackClients will unpack the client binaries if they exist
+func (o *MirrorOptions) unpackClients(dstDir string, filesInArchive map[string]string) error {
+	if err := unpack(config.ClientsDir, dstDir, filesInArchive); err != nil {
+		nferr := &ErrArchiveFileNotFound{}
+		if errors.As(err, &nferr) || errors.Is(err, os.ErrNotExist) {
+			o.log().Debug("No client binaries found in archive, skipping")
+			return nil
+		}
+		return err
+	}
+	o.log().Infof("Wrote client binaries to %s", dstDir)
+	return nil
+}
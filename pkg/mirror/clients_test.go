@@ -0,0 +1,22 @@
+package mirror
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChecksums(t *testing.T) {
+	data := `abc123  openshift-client-linux-4.9.10.tar.gz
+def456  openshift-install-linux-4.9.10.tar.gz
+
+malformed line here
+`
+	sums, err := parseChecksums(strings.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"openshift-client-linux-4.9.10.tar.gz":  "abc123",
+		"openshift-install-linux-4.9.10.tar.gz": "def456",
+	}, sums)
+}
@@ -0,0 +1,144 @@
+package mirror
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+const (
+	// containersConfigDir is the results subdirectory for
+	// containers host configuration
+	containersConfigDir  = "containers"
+	containersPolicyFile = "policy.json"
+	// registriesDDir is the registries.d directory for signature storage configuration
+	registriesDDir  = "registries.d"
+	registriesDFile = "oc-mirror.yaml"
+	// defaultPolicyKeyPath is the Red Hat release key shipped on RHEL and RHCOS hosts
+	defaultPolicyKeyPath = "/etc/pki/rpm-gpg/RPM-GPG-KEY-redhat-release"
+)
+
+// containersPolicy is a containers-policy.json(5) configuration
+type containersPolicy struct {
+	Default    []policyRequirement                       `json:"default"`
+	Transports map[string]map[string][]policyRequirement `json:"transports"`
+}
+
+type policyRequirement struct {
+	Type           string          `json:"type"`
+	KeyType        string          `json:"keyType,omitempty"`
+	KeyPath        string          `json:"keyPath,omitempty"`
+	SignedIdentity *signedIdentity `json:"signedIdentity,omitempty"`
+}
+
+type signedIdentity struct {
+	Type         string `json:"type"`
+	Prefix       string `json:"prefix,omitempty"`
+	SignedPrefix string `json:"signedPrefix,omitempty"`
+}
+
+// registriesDConfig is a containers-registries.d(5) configuration
+type registriesDConfig struct {
+	Docker map[string]registriesDNamespace `json:"docker"`
+}
+
+type registriesDNamespace struct {
+	Lookaside string `json:"lookaside,omitempty"`
+}
+
+// writeContainersPolicy writes a policy.json requiring release signatures for the source
+// and mirrored release repositories and a registries.d configuration describing where
+// the signatures for the mirrored repositories are stored.
+func (o *MirrorOptions) writeContainersPolicy(mapping image.TypedImageMapping, dir string) error {
+	releases := image.ByCategory(mapping, v1alpha2.TypeOCPRelease)
+	if len(releases) == 0 {
+		o.log().Debug("No release images found, skipping containers policy")
+		return nil
+	}
+
+	keyPath := o.PolicyKeyPath
+	if keyPath == "" {
+		keyPath = defaultPolicyKeyPath
+	}
+	policy, registriesD := generateContainersPolicy(releases, keyPath, o.SignatureLookaside)
+
+	policyData, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal containers policy: %v", err)
+	}
+	registriesDData, err := yaml.Marshal(registriesD)
+	if err != nil {
+		return fmt.Errorf("unable to marshal registries.d configuration: %v", err)
+	}
+
+	policyDir := filepath.Join(dir, containersConfigDir)
+	if err := os.MkdirAll(filepath.Join(policyDir, registriesDDir), 0750); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(policyDir, containersPolicyFile), append(policyData, '\n'), 0640); err != nil {
+		return fmt.Errorf("error writing containers policy: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(policyDir, registriesDDir, registriesDFile), registriesDData, 0640); err != nil {
+		return fmt.Errorf("error writing registries.d configuration: %v", err)
+	}
+	o.log().Infof("Wrote containers policy to %s", policyDir)
+	return nil
+}
+
+// generateContainersPolicy creates the policy and registries.d configuration for the release mapping
+func generateContainersPolicy(releases image.TypedImageMapping, keyPath, lookaside string) (containersPolicy, registriesDConfig) {
+	policy := containersPolicy{
+		Default:    []policyRequirement{{Type: "insecureAcceptAnything"}},
+		Transports: map[string]map[string][]policyRequirement{"docker": {}},
+	}
+	registriesD := registriesDConfig{Docker: map[string]registriesDNamespace{}}
+
+	// Sort for stable output
+	var sources []image.TypedImage
+	for src := range releases {
+		sources = append(sources, src)
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].Ref.Exact() < sources[j].Ref.Exact()
+	})
+
+	for _, src := range sources {
+		srcRepo := src.Ref.AsRepository().Exact()
+		dstRepo := releases[src].Ref.AsRepository().Exact()
+
+		// Pulls through a registries.conf mirror are
+		// evaluated against the source repository
+		policy.Transports["docker"][srcRepo] = []policyRequirement{{
+			Type:    "signedBy",
+			KeyType: "GPGKeys",
+			KeyPath: keyPath,
+		}}
+		// Signatures name the source repository, so direct
+		// pulls from the mirror remap the identity
+		policy.Transports["docker"][dstRepo] = []policyRequirement{{
+			Type:    "signedBy",
+			KeyType: "GPGKeys",
+			KeyPath: keyPath,
+			SignedIdentity: &signedIdentity{
+				Type:         "remapIdentity",
+				Prefix:       dstRepo,
+				SignedPrefix: srcRepo,
+			},
+		}}
+
+		// GPG signatures are only read from lookasides, the release
+		// signatures pushed to the registry are not sigstore signatures
+		if lookaside != "" {
+			registriesD.Docker[dstRepo] = registriesDNamespace{Lookaside: lookaside}
+		}
+	}
+	return policy, registriesD
+}
@@ -0,0 +1,63 @@
+package mirror
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestGenerateContainersPolicy(t *testing.T) {
+	expPolicy := `{
+  "default": [
+    {
+      "type": "insecureAcceptAnything"
+    }
+  ],
+  "transports": {
+    "docker": {
+      "quay.io/openshift-release-dev/ocp-release": [
+        {
+          "type": "signedBy",
+          "keyType": "GPGKeys",
+          "keyPath": "/etc/pki/rpm-gpg/RPM-GPG-KEY-redhat-release"
+        }
+      ],
+      "reg.mirror.com/openshift/release-images": [
+        {
+          "type": "signedBy",
+          "keyType": "GPGKeys",
+          "keyPath": "/etc/pki/rpm-gpg/RPM-GPG-KEY-redhat-release",
+          "signedIdentity": {
+            "type": "remapIdentity",
+            "prefix": "reg.mirror.com/openshift/release-images",
+            "signedPrefix": "quay.io/openshift-release-dev/ocp-release"
+          }
+        }
+      ]
+    }
+  }
+}`
+	expRegistriesD := `docker:
+  reg.mirror.com/openshift/release-images:
+    lookaside: https://sigs.mirror.com
+`
+
+	digest := "@sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8"
+	src, err := image.ParseTypedImage("quay.io/openshift-release-dev/ocp-release"+digest, v1alpha2.TypeOCPRelease)
+	require.NoError(t, err)
+	dst, err := image.ParseTypedImage("reg.mirror.com/openshift/release-images"+digest, v1alpha2.TypeOCPRelease)
+	require.NoError(t, err)
+
+	policy, registriesD := generateContainersPolicy(image.TypedImageMapping{src: dst}, defaultPolicyKeyPath, "https://sigs.mirror.com")
+	policyData, err := json.MarshalIndent(policy, "", "  ")
+	require.NoError(t, err)
+	require.Equal(t, expPolicy, string(policyData))
+	registriesDData, err := yaml.Marshal(registriesD)
+	require.NoError(t, err)
+	require.Equal(t, expRegistriesD, string(registriesDData))
+}
@@ -0,0 +1,80 @@
+package mirror
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+const machineConfigKind = "MachineConfig"
+
+// getCoreOSImages returns the layered RHCOS images to mirror.
+// The machine-os-content and extension images shipped in the release
+// payload are mirrored with the payload itself.
+func (o *MirrorOptions) getCoreOSImages(coreOS v1alpha2.CoreOS) ([]v1alpha2.Image, error) {
+	seen := map[string]struct{}{}
+	var images []v1alpha2.Image
+	add := func(img string) {
+		if _, ok := seen[img]; ok {
+			return
+		}
+		seen[img] = struct{}{}
+		images = append(images, v1alpha2.Image{Name: img})
+	}
+
+	for _, img := range coreOS.OSImages {
+		add(img.Name)
+	}
+
+	for _, path := range coreOS.MachineConfigs {
+		urls, err := readOSImageURLs(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading MachineConfig %s: %v", path, err)
+		}
+		for _, u := range urls {
+			o.log().Debugf("Found osImageURL %s in %s", u, path)
+			add(u)
+		}
+	}
+
+	return images, nil
+}
+
+// readOSImageURLs returns the osImageURL overrides of the
+// MachineConfig manifests in the file at path
+func readOSImageURLs(path string) ([]string, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var urls []string
+	decoder := utilyaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if obj.GetKind() != machineConfigKind {
+			continue
+		}
+		u, found, err := unstructured.NestedString(obj.Object, "spec", "osImageURL")
+		if err != nil {
+			return nil, err
+		}
+		if found && u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls, nil
+}
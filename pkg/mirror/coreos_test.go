@@ -0,0 +1,78 @@
+package mirror
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+func TestGetCoreOSImages(t *testing.T) {
+	machineConfigs := `apiVersion: machineconfiguration.openshift.io/v1
+kind: MachineConfig
+metadata:
+  name: 99-worker-layered
+spec:
+  osImageURL: quay.io/example/custom-rhcos@sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: unrelated
+data:
+  osImageURL: quay.io/example/ignored:latest
+---
+apiVersion: machineconfiguration.openshift.io/v1
+kind: MachineConfig
+metadata:
+  name: 99-master-layered
+spec:
+  osImageURL: quay.io/example/custom-rhcos@sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8
+`
+	mcPath := filepath.Join(t.TempDir(), "machineconfigs.yaml")
+	require.NoError(t, ioutil.WriteFile(mcPath, []byte(machineConfigs), 0640))
+
+	tests := []struct {
+		name     string
+		coreOS   v1alpha2.CoreOS
+		exp      []v1alpha2.Image
+		expError string
+	}{
+		{
+			name: "Valid/OSImagesAndMachineConfigs",
+			coreOS: v1alpha2.CoreOS{
+				OSImages:       []v1alpha2.Image{{Name: "quay.io/example/other-rhcos:4.10"}},
+				MachineConfigs: []string{mcPath},
+			},
+			exp: []v1alpha2.Image{
+				{Name: "quay.io/example/other-rhcos:4.10"},
+				{Name: "quay.io/example/custom-rhcos@sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8"},
+			},
+		},
+		{
+			name:   "Valid/Empty",
+			coreOS: v1alpha2.CoreOS{},
+		},
+		{
+			name: "Invalid/MissingMachineConfig",
+			coreOS: v1alpha2.CoreOS{
+				MachineConfigs: []string{"missing.yaml"},
+			},
+			expError: "error reading MachineConfig missing.yaml: open missing.yaml: no such file or directory",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			images, err := NewMirrorOptions().getCoreOSImages(test.coreOS)
+			if test.expError != "" {
+				require.EqualError(t, err, test.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.exp, images)
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package mirror
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/openshift/library-go/pkg/verify/util"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+const (
+	// cosignPasswordEnv holds the password of encrypted cosign keys
+	cosignPasswordEnv = "COSIGN_PASSWORD"
+	// cosignSignatureType is the type of the simple signing
+	// payload of cosign container image signatures
+	cosignSignatureType = "cosign container image signature"
+)
+
+// cosignPayload is the simple signing payload signed by cosign
+type cosignPayload struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional map[string]interface{} `json:"optional"`
+}
+
+// encryptedCosignKey is the content of an encrypted cosign private key,
+// a PKCS #8 key sealed with a key derived from the password by scrypt
+type encryptedCosignKey struct {
+	KDF struct {
+		Name   string `json:"name"`
+		Params struct {
+			N int `json:"N"`
+			R int `json:"r"`
+			P int `json:"p"`
+		} `json:"params"`
+		Salt []byte `json:"salt"`
+	} `json:"kdf"`
+	Cipher struct {
+		Name  string `json:"name"`
+		Nonce []byte `json:"nonce"`
+	} `json:"cipher"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// signImages signs the images oc-mirror builds with the cosign key,
+// or keyless with a Fulcio certificate, and pushes the signatures next
+// to them using the sigstore attached tag convention (sha256-<hash>.sig).
+// The destination images of the mapping must be pinned by digest.
+func (o *MirrorOptions) signImages(ctx context.Context, mapping image.TypedImageMapping) error {
+	signer, err := o.cosignSigner(ctx)
+	if err != nil || signer == nil {
+		return err
+	}
+	return o.pushCosignSignatures(ctx, signer, mapping)
+}
+
+// cosignSigner returns the signer of the cosign key or of keyless
+// signing, or nil when neither is configured
+func (o *MirrorOptions) cosignSigner(ctx context.Context) (cosignSigner, error) {
+	switch {
+	case o.CosignKey != "":
+		key, err := loadCosignKey(o.CosignKey)
+		if err != nil {
+			return nil, fmt.Errorf("error loading cosign key: %v", err)
+		}
+		return &keySigner{key: key}, nil
+	case o.CosignKeyless:
+		token, err := o.identityToken()
+		if err != nil {
+			return nil, err
+		}
+		signer, err := newKeylessSigner(ctx, o.Clients().HTTPClient(), o.FulcioURL, o.RekorURL, token)
+		if err != nil {
+			return nil, fmt.Errorf("error requesting keyless signing certificate: %v", err)
+		}
+		return signer, nil
+	default:
+		return nil, nil
+	}
+}
+
+// pushCosignSignatures signs the destination images of the mapping
+// and pushes the cosign signatures next to them
+func (o *MirrorOptions) pushCosignSignatures(ctx context.Context, signer cosignSigner, mapping image.TypedImageMapping) error {
+	var destInsecure bool
+	if o.DestPlainHTTP || o.DestSkipTLS {
+		destInsecure = true
+	}
+	nameOpts := getNameOpts(destInsecure)
+	remoteOpts := o.getRemoteOpts(ctx, destInsecure)
+
+	for _, dst := range mapping {
+		repo := dst.Ref.AsRepository()
+		payload, err := cosignSignaturePayload(repo.Exact(), dst.Ref.ID)
+		if err != nil {
+			return err
+		}
+		annotations, err := signer.sign(ctx, payload)
+		if err != nil {
+			return fmt.Errorf("error signing %s: %v", dst.Ref.Exact(), err)
+		}
+		img, err := mutate.Append(
+			mutate.ConfigMediaType(mutate.MediaType(empty.Image, types.OCIManifestSchema1), types.OCIConfigJSON),
+			mutate.Addendum{
+				Layer:       &blobLayer{content: payload, mediaType: sigstoreSignatureMediaType},
+				Annotations: annotations,
+			})
+		if err != nil {
+			return err
+		}
+
+		prefix, err := util.DigestToKeyPrefix(dst.Ref.ID, "-")
+		if err != nil {
+			return err
+		}
+		tag, err := name.NewTag(fmt.Sprintf("%s:%s%s", repo.Exact(), prefix, sigstoreSignatureTagSuffix), nameOpts...)
+		if err != nil {
+			return err
+		}
+		o.log().Infof("Pushing cosign signature %s", tag)
+		if err := remote.Write(tag, img, remoteOpts...); err != nil {
+			return fmt.Errorf("error pushing cosign signature %s: %v", tag, err)
+		}
+	}
+	return nil
+}
+
+// cosignSignaturePayload returns the simple signing payload of an image
+func cosignSignaturePayload(repository, digest string) ([]byte, error) {
+	if digest == "" {
+		return nil, fmt.Errorf("image %s is not pinned by digest", repository)
+	}
+	var payload cosignPayload
+	payload.Critical.Identity.DockerReference = repository
+	payload.Critical.Image.DockerManifestDigest = digest
+	payload.Critical.Type = cosignSignatureType
+	return json.Marshal(payload)
+}
+
+// cosignSigner signs simple signing payloads and returns
+// the annotations of the cosign signature layer
+type cosignSigner interface {
+	sign(ctx context.Context, payload []byte) (map[string]string, error)
+}
+
+// keySigner signs payloads with a cosign private key
+type keySigner struct {
+	key crypto.Signer
+}
+
+func (s *keySigner) sign(_ context.Context, payload []byte) (map[string]string, error) {
+	sig, err := signPayload(s.key, payload)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{sigstoreSignatureAnnotation: base64.StdEncoding.EncodeToString(sig)}, nil
+}
+
+// signPayload signs the SHA-256 digest of the payload
+func signPayload(signer crypto.Signer, payload []byte) ([]byte, error) {
+	digest := sha256.Sum256(payload)
+	return signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+// loadCosignKey reads a PEM encoded ECDSA or RSA private key.
+// Keys generated by `cosign generate-key-pair` are decrypted
+// with the password set in COSIGN_PASSWORD.
+func loadCosignKey(path string) (crypto.Signer, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not PEM encoded", path)
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "ENCRYPTED COSIGN PRIVATE KEY", "ENCRYPTED SIGSTORE PRIVATE KEY":
+		der, err := decryptCosignKey(block.Bytes, []byte(os.Getenv(cosignPasswordEnv)))
+		if err != nil {
+			return nil, err
+		}
+		key, err = x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return nil, err
+		}
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		return k, nil
+	case *rsa.PrivateKey:
+		return k, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+}
+
+// decryptCosignKey decrypts an encrypted cosign private key
+func decryptCosignKey(data, password []byte) ([]byte, error) {
+	var enc encryptedCosignKey
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return nil, fmt.Errorf("error reading encrypted key: %v", err)
+	}
+	if enc.KDF.Name != "scrypt" || enc.Cipher.Name != "nacl/secretbox" {
+		return nil, fmt.Errorf("unsupported key encryption %s and %s", enc.KDF.Name, enc.Cipher.Name)
+	}
+	if len(enc.Cipher.Nonce) != 24 {
+		return nil, fmt.Errorf("invalid nonce length %d", len(enc.Cipher.Nonce))
+	}
+	derived, err := scrypt.Key(password, enc.KDF.Salt, enc.KDF.Params.N, enc.KDF.Params.R, enc.KDF.Params.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	var key [32]byte
+	var nonce [24]byte
+	copy(key[:], derived)
+	copy(nonce[:], enc.Cipher.Nonce)
+	der, ok := secretbox.Open(nil, enc.Ciphertext, &nonce, &key)
+	if !ok {
+		return nil, errors.New("error decrypting key: invalid password")
+	}
+	return der, nil
+}
@@ -0,0 +1,310 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// cosignBundleAnnotation holds the Rekor bundle proving
+	// that a keyless signature is in the transparency log
+	cosignBundleAnnotation = "dev.sigstore.cosign/bundle"
+	// sigstoreIDTokenEnv holds the OIDC identity token of keyless signing
+	sigstoreIDTokenEnv = "SIGSTORE_ID_TOKEN"
+	// defaultFulcioURL is the public Fulcio instance
+	defaultFulcioURL = "https://fulcio.sigstore.dev"
+	// defaultRekorURL is the public Rekor instance
+	defaultRekorURL = "https://rekor.sigstore.dev"
+	// sigstoreTimeout bounds the requests to Fulcio and Rekor
+	sigstoreTimeout = time.Minute
+)
+
+// fulcioCertRequest is the request of the Fulcio v2 signingCert API
+type fulcioCertRequest struct {
+	Credentials struct {
+		OIDCIdentityToken string `json:"oidcIdentityToken"`
+	} `json:"credentials"`
+	PublicKeyRequest struct {
+		PublicKey struct {
+			Algorithm string `json:"algorithm"`
+			Content   string `json:"content"`
+		} `json:"publicKey"`
+		ProofOfPossession []byte `json:"proofOfPossession"`
+	} `json:"publicKeyRequest"`
+}
+
+// fulcioCertChain is a PEM encoded certificate followed by its issuers
+type fulcioCertChain struct {
+	Chain struct {
+		Certificates []string `json:"certificates"`
+	} `json:"chain"`
+}
+
+// fulcioCertResponse is the response of the Fulcio v2 signingCert API,
+// with the certificate transparency timestamp embedded or detached
+type fulcioCertResponse struct {
+	SignedCertificateEmbeddedSct *fulcioCertChain `json:"signedCertificateEmbeddedSct"`
+	SignedCertificateDetachedSct *fulcioCertChain `json:"signedCertificateDetachedSct"`
+}
+
+// rekorEntry is a hashedrekord entry of the Rekor transparency log
+type rekorEntry struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Spec       struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+		Signature struct {
+			Content   []byte `json:"content"`
+			PublicKey struct {
+				Content []byte `json:"content"`
+			} `json:"publicKey"`
+		} `json:"signature"`
+	} `json:"spec"`
+}
+
+// rekorLogEntry is an entry of the transparency log returned by Rekor
+type rekorLogEntry struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+	Verification   struct {
+		SignedEntryTimestamp []byte `json:"signedEntryTimestamp"`
+	} `json:"verification"`
+}
+
+// rekorBundle is the content of the cosign bundle annotation
+type rekorBundle struct {
+	SignedEntryTimestamp []byte `json:"SignedEntryTimestamp"`
+	Payload              struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogIndex       int64  `json:"logIndex"`
+		LogID          string `json:"logID"`
+	} `json:"Payload"`
+}
+
+// keylessSigner signs payloads with an ephemeral key certified by
+// Fulcio for an OIDC identity, and records the signatures in Rekor.
+// The certificate is short lived, so a signer is created per batch of images.
+type keylessSigner struct {
+	key      *ecdsa.PrivateKey
+	certPEM  string
+	chainPEM string
+	rekorURL string
+	client   *http.Client
+}
+
+// newKeylessSigner generates an ephemeral key and requests
+// its signing certificate from Fulcio for the identity token
+func newKeylessSigner(ctx context.Context, client *http.Client, fulcioURL, rekorURL, token string) (*keylessSigner, error) {
+	subject, err := tokenSubject(token)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	// Fulcio checks the key is held by the
+	// requester with a signature of the subject
+	proof, err := signPayload(key, []byte(subject))
+	if err != nil {
+		return nil, err
+	}
+
+	var req fulcioCertRequest
+	req.Credentials.OIDCIdentityToken = token
+	req.PublicKeyRequest.PublicKey.Algorithm = "ECDSA"
+	req.PublicKeyRequest.PublicKey.Content = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	req.PublicKeyRequest.ProofOfPossession = proof
+	var resp fulcioCertResponse
+	if err := postSigstoreJSON(ctx, client, strings.TrimSuffix(fulcioURL, "/")+"/api/v2/signingCert", token, req, &resp); err != nil {
+		return nil, err
+	}
+
+	chain := resp.SignedCertificateEmbeddedSct
+	if chain == nil {
+		chain = resp.SignedCertificateDetachedSct
+	}
+	if chain == nil || len(chain.Chain.Certificates) == 0 {
+		return nil, errors.New("no certificate in the Fulcio response")
+	}
+	certs := make([]string, 0, len(chain.Chain.Certificates))
+	for _, cert := range chain.Chain.Certificates {
+		certs = append(certs, strings.TrimSpace(cert)+"\n")
+	}
+	block, _ := pem.Decode([]byte(certs[0]))
+	if block == nil {
+		return nil, errors.New("invalid certificate in the Fulcio response")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if !key.PublicKey.Equal(cert.PublicKey) {
+		return nil, errors.New("the Fulcio certificate is not issued for the signing key")
+	}
+
+	return &keylessSigner{
+		key:      key,
+		certPEM:  certs[0],
+		chainPEM: strings.Join(certs[1:], ""),
+		rekorURL: rekorURL,
+		client:   client,
+	}, nil
+}
+
+func (s *keylessSigner) sign(ctx context.Context, payload []byte) (map[string]string, error) {
+	sig, err := signPayload(s.key, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(payload)
+	var entry rekorEntry
+	entry.APIVersion = "0.0.1"
+	entry.Kind = "hashedrekord"
+	entry.Spec.Data.Hash.Algorithm = "sha256"
+	entry.Spec.Data.Hash.Value = hex.EncodeToString(hash[:])
+	entry.Spec.Signature.Content = sig
+	entry.Spec.Signature.PublicKey.Content = []byte(s.certPEM)
+	var resp map[string]rekorLogEntry
+	if err := postSigstoreJSON(ctx, s.client, strings.TrimSuffix(s.rekorURL, "/")+"/api/v1/log/entries", "", entry, &resp); err != nil {
+		return nil, fmt.Errorf("error recording signature in Rekor: %v", err)
+	}
+	if len(resp) != 1 {
+		return nil, fmt.Errorf("expected one Rekor log entry, got %d", len(resp))
+	}
+
+	var bundle rekorBundle
+	for _, logEntry := range resp {
+		bundle.SignedEntryTimestamp = logEntry.Verification.SignedEntryTimestamp
+		bundle.Payload.Body = logEntry.Body
+		bundle.Payload.IntegratedTime = logEntry.IntegratedTime
+		bundle.Payload.LogIndex = logEntry.LogIndex
+		bundle.Payload.LogID = logEntry.LogID
+	}
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := map[string]string{
+		sigstoreSignatureAnnotation: base64.StdEncoding.EncodeToString(sig),
+		cosignCertificateAnnotation: s.certPEM,
+		cosignBundleAnnotation:      string(data),
+	}
+	if s.chainPEM != "" {
+		annotations[cosignChainAnnotation] = s.chainPEM
+	}
+	return annotations, nil
+}
+
+// identityToken returns the OIDC identity token of keyless signing
+func (o *MirrorOptions) identityToken() (string, error) {
+	if o.CosignIdentityToken == "" {
+		if token := strings.TrimSpace(os.Getenv(sigstoreIDTokenEnv)); token != "" {
+			return token, nil
+		}
+		return "", fmt.Errorf("--cosign-keyless requires an identity token in --cosign-identity-token or %s", sigstoreIDTokenEnv)
+	}
+	data, err := ioutil.ReadFile(filepath.Clean(o.CosignIdentityToken))
+	if err != nil {
+		return "", fmt.Errorf("error reading identity token: %v", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("identity token %s is empty", o.CosignIdentityToken)
+	}
+	return token, nil
+}
+
+// tokenSubject returns the subject Fulcio certifies for the identity
+// token: its email when it has one, its subject otherwise.
+// The token is verified by Fulcio, not here.
+func tokenSubject(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("identity token is not a JWT")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", fmt.Errorf("invalid identity token: %v", err)
+	}
+	var claims struct {
+		Email   string `json:"email"`
+		Subject string `json:"sub"`
+	}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return "", fmt.Errorf("invalid identity token: %v", err)
+	}
+	switch {
+	case claims.Email != "":
+		return claims.Email, nil
+	case claims.Subject != "":
+		return claims.Subject, nil
+	default:
+		return "", errors.New("identity token has no email or subject")
+	}
+}
+
+// postSigstoreJSON posts the JSON encoded request to a sigstore
+// service and decodes the JSON response
+func postSigstoreJSON(ctx context.Context, client *http.Client, u, token string, in, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, sigstoreTimeout)
+	defer cancel()
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		if m := strings.TrimSpace(string(msg)); m != "" {
+			return fmt.Errorf("unexpected status %s: %s", resp.Status, m)
+		}
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,226 @@
+package mirror
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestSignImagesKeyless(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	markInsecure(t, u.Host)
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fulcio"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}))
+
+	token := testIdentityToken(t, map[string]string{"sub": "1234", "email": "ci@example.com"})
+
+	// Fulcio certifies the requested key for the email of the token
+	fulcio := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req fulcioCertRequest
+		if r.URL.Path != "/api/v2/signingCert" || json.NewDecoder(r.Body).Decode(&req) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		block, _ := pem.Decode([]byte(req.PublicKeyRequest.PublicKey.Content))
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil || req.Credentials.OIDCIdentityToken != token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		hash := sha256.Sum256([]byte("ci@example.com"))
+		if !ecdsa.VerifyASN1(pub.(*ecdsa.PublicKey), hash[:], req.PublicKeyRequest.ProofOfPossession) {
+			http.Error(w, "invalid proof of possession", http.StatusBadRequest)
+			return
+		}
+		leafTmpl := &x509.Certificate{
+			SerialNumber:    big.NewInt(2),
+			NotBefore:       time.Now().Add(-time.Minute),
+			NotAfter:        time.Now().Add(10 * time.Minute),
+			KeyUsage:        x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+			EmailAddresses:  []string{"ci@example.com"},
+			ExtraExtensions: []pkix.Extension{{Id: fulcioIssuerOID, Value: []byte("https://accounts.example.com")}},
+		}
+		leafDER, err := x509.CreateCertificate(rand.Reader, leafTmpl, ca, pub, caKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var resp fulcioCertResponse
+		resp.SignedCertificateEmbeddedSct = &fulcioCertChain{}
+		resp.SignedCertificateEmbeddedSct.Chain.Certificates = []string{
+			string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})),
+			caPEM,
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(fulcio.Close)
+
+	var entries []rekorEntry
+	rekor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var entry rekorEntry
+		if r.URL.Path != "/api/v1/log/entries" || json.NewDecoder(r.Body).Decode(&entry) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		entries = append(entries, entry)
+		logEntry := rekorLogEntry{Body: "Ym9keQ==", IntegratedTime: 1, LogID: "c0ffee", LogIndex: int64(len(entries))}
+		logEntry.Verification.SignedEntryTimestamp = []byte("set")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]rekorLogEntry{fmt.Sprintf("uuid-%d", len(entries)): logEntry})
+	}))
+	t.Cleanup(rekor.Close)
+
+	img, err := crane.Image(map[string][]byte{"configs/index.json": []byte("{}")})
+	require.NoError(t, err)
+	ref := fmt.Sprintf("%s/redhat/redhat-operator-index:v4.10", u.Host)
+	require.NoError(t, crane.Push(img, ref))
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	dst, err := imagesource.ParseReference(fmt.Sprintf("%s@%s", ref, digest))
+	require.NoError(t, err)
+	mapping := image.TypedImageMapping{}
+	mapping.Add(dst, dst, v1alpha2.TypeOperatorCatalog)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte(token+"\n"), 0600))
+	opts := &MirrorOptions{
+		DestPlainHTTP:       true,
+		CosignKeyless:       true,
+		CosignIdentityToken: tokenFile,
+		FulcioURL:           fulcio.URL,
+		RekorURL:            rekor.URL + "/",
+	}
+	require.NoError(t, opts.signImages(context.TODO(), mapping))
+
+	sigImg, err := crane.Pull(fmt.Sprintf("%s/redhat/redhat-operator-index:sha256-%s.sig", u.Host, digest.Hex), crane.Insecure)
+	require.NoError(t, err)
+	manifest, err := sigImg.Manifest()
+	require.NoError(t, err)
+	require.Len(t, manifest.Layers, 1)
+	annotations := manifest.Layers[0].Annotations
+	require.Equal(t, caPEM, annotations[cosignChainAnnotation])
+	var bundle rekorBundle
+	require.NoError(t, json.Unmarshal([]byte(annotations[cosignBundleAnnotation]), &bundle))
+	require.Equal(t, []byte("set"), bundle.SignedEntryTimestamp)
+	require.Equal(t, int64(1), bundle.Payload.LogIndex)
+
+	// The signature verifies for the identity of the token
+	// and is recorded in the transparency log
+	layers, err := sigImg.Layers()
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	signers := imageSigners{
+		identities: []v1alpha2.SignerIdentity{{Issuer: "https://accounts.example.com", Subject: "ci@example.com"}},
+		roots:      roots,
+	}
+	require.NoError(t, verifyCosignSignature(layers[0], annotations, digest.String(), signers))
+	require.Len(t, entries, 1)
+	sig, err := base64.StdEncoding.DecodeString(annotations[sigstoreSignatureAnnotation])
+	require.NoError(t, err)
+	require.Equal(t, sig, entries[0].Spec.Signature.Content)
+	require.Equal(t, annotations[cosignCertificateAnnotation], string(entries[0].Spec.Signature.PublicKey.Content))
+	rc, err := layers[0].Compressed()
+	require.NoError(t, err)
+	defer rc.Close()
+	payload, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	hash := sha256.Sum256(payload)
+	require.Equal(t, hex.EncodeToString(hash[:]), entries[0].Spec.Data.Hash.Value)
+}
+
+func TestTokenSubject(t *testing.T) {
+	type spec struct {
+		name     string
+		token    string
+		exp      string
+		expError string
+	}
+	cases := []spec{
+		{
+			name:  "Valid/Email",
+			token: testIdentityToken(t, map[string]string{"sub": "1234", "email": "ci@example.com"}),
+			exp:   "ci@example.com",
+		},
+		{
+			name:  "Valid/Subject",
+			token: testIdentityToken(t, map[string]string{"sub": "https://github.com/org/repo/.github/workflows/ci.yaml@refs/heads/main"}),
+			exp:   "https://github.com/org/repo/.github/workflows/ci.yaml@refs/heads/main",
+		},
+		{
+			name:     "Invalid/NoSubject",
+			token:    testIdentityToken(t, map[string]string{"iss": "https://accounts.example.com"}),
+			expError: "identity token has no email or subject",
+		},
+		{
+			name:     "Invalid/NotJWT",
+			token:    "token",
+			expError: "identity token is not a JWT",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			subject, err := tokenSubject(c.token)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, subject)
+		})
+	}
+}
+
+// testIdentityToken returns an unsigned JWT with the claims
+func testIdentityToken(t *testing.T, claims map[string]string) string {
+	data, err := json.Marshal(claims)
+	require.NoError(t, err)
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256"}`))
+	return header + "." + base64.RawURLEncoding.EncodeToString(data) + ".c2ln"
+}
@@ -0,0 +1,161 @@
+package mirror
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestSignImages(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	markInsecure(t, u.Host)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keyFile := writeEncryptedCosignKey(t, key, "password")
+	t.Setenv(cosignPasswordEnv, "password")
+
+	img, err := crane.Image(map[string][]byte{"configs/index.json": []byte("{}")})
+	require.NoError(t, err)
+	ref := fmt.Sprintf("%s/redhat/redhat-operator-index:v4.10", u.Host)
+	require.NoError(t, crane.Push(img, ref))
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	dst, err := imagesource.ParseReference(fmt.Sprintf("%s@%s", ref, digest))
+	require.NoError(t, err)
+	mapping := image.TypedImageMapping{}
+	mapping.Add(dst, dst, v1alpha2.TypeOperatorCatalog)
+
+	opts := &MirrorOptions{DestPlainHTTP: true, CosignKey: keyFile}
+	require.NoError(t, opts.signImages(context.TODO(), mapping))
+
+	sigRef := fmt.Sprintf("%s/redhat/redhat-operator-index:sha256-%s.sig", u.Host, digest.Hex)
+	sigImg, err := crane.Pull(sigRef, crane.Insecure)
+	require.NoError(t, err)
+	manifest, err := sigImg.Manifest()
+	require.NoError(t, err)
+	require.Len(t, manifest.Layers, 1)
+	require.Equal(t, sigstoreSignatureMediaType, manifest.Layers[0].MediaType)
+	sig, err := base64.StdEncoding.DecodeString(manifest.Layers[0].Annotations[sigstoreSignatureAnnotation])
+	require.NoError(t, err)
+
+	layers, err := sigImg.Layers()
+	require.NoError(t, err)
+	rc, err := layers[0].Compressed()
+	require.NoError(t, err)
+	defer rc.Close()
+	payload, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+
+	var signed cosignPayload
+	require.NoError(t, json.Unmarshal(payload, &signed))
+	require.Equal(t, u.Host+"/redhat/redhat-operator-index", signed.Critical.Identity.DockerReference)
+	require.Equal(t, digest.String(), signed.Critical.Image.DockerManifestDigest)
+	require.Equal(t, cosignSignatureType, signed.Critical.Type)
+	hash := sha256.Sum256(payload)
+	require.True(t, ecdsa.VerifyASN1(&key.PublicKey, hash[:], sig))
+}
+
+func TestLoadCosignKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	plainFile := filepath.Join(t.TempDir(), "cosign.key")
+	require.NoError(t, os.WriteFile(plainFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+	encryptedFile := writeEncryptedCosignKey(t, key, "password")
+
+	type spec struct {
+		name     string
+		path     string
+		password string
+		expError string
+	}
+	cases := []spec{
+		{
+			name: "Valid/Plain",
+			path: plainFile,
+		},
+		{
+			name:     "Valid/Encrypted",
+			path:     encryptedFile,
+			password: "password",
+		},
+		{
+			name:     "Invalid/WrongPassword",
+			path:     encryptedFile,
+			password: "wrong",
+			expError: "error decrypting key: invalid password",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Setenv(cosignPasswordEnv, c.password)
+			signer, err := loadCosignKey(c.path)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, key.Public(), signer.Public())
+		})
+	}
+}
+
+// writeEncryptedCosignKey writes the key in the format of `cosign generate-key-pair`
+func writeEncryptedCosignKey(t *testing.T, key *ecdsa.PrivateKey, password string) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	var enc encryptedCosignKey
+	enc.KDF.Name = "scrypt"
+	enc.KDF.Params.N, enc.KDF.Params.R, enc.KDF.Params.P = 1024, 8, 1
+	enc.KDF.Salt = make([]byte, 32)
+	_, err = rand.Read(enc.KDF.Salt)
+	require.NoError(t, err)
+	enc.Cipher.Name = "nacl/secretbox"
+	var nonce [24]byte
+	_, err = rand.Read(nonce[:])
+	require.NoError(t, err)
+	enc.Cipher.Nonce = nonce[:]
+
+	derived, err := scrypt.Key([]byte(password), enc.KDF.Salt, enc.KDF.Params.N, enc.KDF.Params.R, enc.KDF.Params.P, 32)
+	require.NoError(t, err)
+	var secret [32]byte
+	copy(secret[:], derived)
+	enc.Ciphertext = secretbox.Seal(nil, der, &nonce, &secret)
+
+	data, err := json.Marshal(enc)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "cosign.key")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED COSIGN PRIVATE KEY", Bytes: data}), 0600))
+	return path
+}
@@ -0,0 +1,346 @@
+package mirror
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/openshift/library-go/pkg/image/reference"
+	"github.com/openshift/library-go/pkg/verify/util"
+	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+const (
+	// cosignCertificateAnnotation holds the PEM encoded
+	// certificate of a keyless signature
+	cosignCertificateAnnotation = "dev.sigstore.cosign/certificate"
+	// cosignChainAnnotation holds the PEM encoded intermediate
+	// certificates of a keyless signature
+	cosignChainAnnotation = "dev.sigstore.cosign/chain"
+)
+
+var (
+	// fulcioIssuerOID is the certificate extension holding
+	// the raw OIDC issuer of the identity
+	fulcioIssuerOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	// fulcioIssuerV2OID is the certificate extension holding
+	// the DER encoded OIDC issuer of the identity
+	fulcioIssuerV2OID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// imageSigners are the loaded signers trusted
+// for the repositories matching a pattern
+type imageSigners struct {
+	pattern    string
+	keys       []crypto.PublicKey
+	identities []v1alpha2.SignerIdentity
+	roots      *x509.CertPool
+}
+
+// verifyImageSignatures verifies the cosign signatures of the source images
+// of the mapping that match an entry of the image verification.
+// Failures fail the run with the enforce policy and are logged otherwise.
+func (o *MirrorOptions) verifyImageSignatures(ctx context.Context, verification v1alpha2.ImageVerification, mapping image.TypedImageMapping) error {
+	if len(verification.Images) == 0 {
+		return nil
+	}
+	signers, err := loadImageSigners(verification.Images)
+	if err != nil {
+		return err
+	}
+
+	var insecure bool
+	if o.SourcePlainHTTP || o.SourceSkipTLS {
+		insecure = true
+	}
+
+	srcs := make([]image.TypedImage, 0, len(mapping))
+	for src := range mapping {
+		if src.Type == imagesource.DestinationRegistry {
+			srcs = append(srcs, src)
+		}
+	}
+	sort.Slice(srcs, func(i, j int) bool {
+		return srcs[i].Ref.Exact() < srcs[j].Ref.Exact()
+	})
+
+	var errs []error
+	var verified int
+	for _, src := range srcs {
+		repo := src.Ref.AsRepository().Exact()
+		s, ok := matchImageSigners(signers, repo)
+		if !ok {
+			continue
+		}
+		if err := o.verifyImageSignature(ctx, src.Ref, s, insecure); err != nil {
+			errs = append(errs, fmt.Errorf("image %s: %v", src.Ref.Exact(), err))
+			continue
+		}
+		verified++
+	}
+	o.log().Infof("Verified the signatures of %d images", verified)
+
+	if len(errs) == 0 {
+		return nil
+	}
+	if verification.Policy == v1alpha2.VerificationPolicyEnforce {
+		return fmt.Errorf("error verifying image signatures: %v", utilerrors.NewAggregate(errs))
+	}
+	for _, err := range errs {
+		o.log().Warnf("Signature verification failed for %v", err)
+	}
+	return nil
+}
+
+// loadImageSigners loads the public keys and CAs of the image verification
+func loadImageSigners(images []v1alpha2.ImageSigners) ([]imageSigners, error) {
+	signers := make([]imageSigners, 0, len(images))
+	for _, img := range images {
+		s := imageSigners{pattern: img.Pattern, identities: img.Identities}
+		for _, keyPath := range img.PublicKeys {
+			key, err := loadPublicKey(keyPath)
+			if err != nil {
+				return nil, fmt.Errorf("error loading public key %s: %v", keyPath, err)
+			}
+			s.keys = append(s.keys, key)
+		}
+		if img.CAFile != "" {
+			data, err := ioutil.ReadFile(filepath.Clean(img.CAFile))
+			if err != nil {
+				return nil, err
+			}
+			s.roots = x509.NewCertPool()
+			if !s.roots.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("no certificates found in %s", img.CAFile)
+			}
+		}
+		signers = append(signers, s)
+	}
+	return signers, nil
+}
+
+// matchImageSigners returns the first signers matching the repository
+func matchImageSigners(signers []imageSigners, repo string) (imageSigners, bool) {
+	for _, s := range signers {
+		if prefix := strings.TrimSuffix(s.pattern, "/**"); prefix != s.pattern {
+			if strings.HasPrefix(repo, prefix+"/") {
+				return s, true
+			}
+			continue
+		}
+		if ok, _ := path.Match(s.pattern, repo); ok {
+			return s, true
+		}
+	}
+	return imageSigners{}, false
+}
+
+// verifyImageSignature verifies that a signature of the image
+// attached with the sigstore tag convention is trusted
+func (o *MirrorOptions) verifyImageSignature(ctx context.Context, ref reference.DockerImageReference, signers imageSigners, insecure bool) error {
+	nameOpts := getNameOpts(insecure)
+	remoteOpts := o.getRemoteOpts(ctx, insecure)
+
+	digest := ref.ID
+	if digest == "" {
+		imgRef, err := name.ParseReference(ref.Exact(), nameOpts...)
+		if err != nil {
+			return err
+		}
+		desc, err := remote.Head(imgRef, remoteOpts...)
+		if err != nil {
+			return err
+		}
+		digest = desc.Digest.String()
+	}
+	prefix, err := util.DigestToKeyPrefix(digest, "-")
+	if err != nil {
+		return err
+	}
+	repo, err := name.NewRepository(ref.AsRepository().Exact(), nameOpts...)
+	if err != nil {
+		return err
+	}
+	sigImg, err := remote.Image(repo.Tag(prefix+sigstoreSignatureTagSuffix), remoteOpts...)
+	if err != nil {
+		return fmt.Errorf("error fetching signatures: %v", err)
+	}
+	manifest, err := sigImg.Manifest()
+	if err != nil {
+		return err
+	}
+	layers, err := sigImg.Layers()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, desc := range manifest.Layers {
+		if i >= len(layers) {
+			break
+		}
+		err := verifyCosignSignature(layers[i], desc.Annotations, digest, signers)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return errors.New("no signatures found")
+	}
+	return fmt.Errorf("no trusted signature: %v", utilerrors.NewAggregate(errs))
+}
+
+// verifyCosignSignature verifies a signature layer with the public keys
+// or, for keyless signatures, with the key of the certificate of a trusted identity
+func verifyCosignSignature(layer v1.Layer, annotations map[string]string, digest string, signers imageSigners) error {
+	sig, err := base64.StdEncoding.DecodeString(annotations[sigstoreSignatureAnnotation])
+	if err != nil || len(sig) == 0 {
+		return errors.New("invalid signature annotation")
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	payload, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	var signed cosignPayload
+	if err := json.Unmarshal(payload, &signed); err != nil {
+		return fmt.Errorf("invalid signature payload: %v", err)
+	}
+	if signed.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("signature is for digest %s", signed.Critical.Image.DockerManifestDigest)
+	}
+
+	hash := sha256.Sum256(payload)
+	for _, key := range signers.keys {
+		if verifySignature(key, hash[:], sig) {
+			return nil
+		}
+	}
+	if certPEM, ok := annotations[cosignCertificateAnnotation]; ok && signers.roots != nil {
+		cert, err := verifyIdentityCertificate(certPEM, annotations[cosignChainAnnotation], signers)
+		if err != nil {
+			return err
+		}
+		if verifySignature(cert.PublicKey, hash[:], sig) {
+			return nil
+		}
+	}
+	return errors.New("signature is not signed by a trusted key or identity")
+}
+
+// verifyIdentityCertificate verifies that the certificate is issued by the
+// trusted CAs to a trusted identity. Keyless certificates are short lived,
+// so the chain is verified at the time the certificate was issued.
+func verifyIdentityCertificate(certPEM, chainPEM string, signers imageSigners) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return nil, errors.New("invalid signature certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	intermediates := x509.NewCertPool()
+	intermediates.AppendCertsFromPEM([]byte(chainPEM))
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         signers.roots,
+		Intermediates: intermediates,
+		CurrentTime:   cert.NotBefore,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return nil, fmt.Errorf("untrusted signature certificate: %v", err)
+	}
+
+	issuer := certificateIssuer(cert)
+	subjects := append([]string{}, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		subjects = append(subjects, u.String())
+	}
+	for _, id := range signers.identities {
+		if id.Issuer != issuer {
+			continue
+		}
+		for _, subject := range subjects {
+			if id.Subject == subject {
+				return cert, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("untrusted signer identity %v issued by %q", subjects, issuer)
+}
+
+// certificateIssuer returns the OIDC issuer of a keyless certificate
+func certificateIssuer(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(fulcioIssuerV2OID):
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err == nil {
+				return issuer
+			}
+		case ext.Id.Equal(fulcioIssuerOID):
+			return string(ext.Value)
+		}
+	}
+	return ""
+}
+
+// verifySignature verifies an ECDSA or RSA signature of a SHA-256 hash
+func verifySignature(key crypto.PublicKey, hash, sig []byte) bool {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(k, hash, sig)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, hash, sig) == nil
+	default:
+		return false
+	}
+}
+
+// loadPublicKey reads a PEM encoded ECDSA or RSA public key
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not PEM encoded", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+}
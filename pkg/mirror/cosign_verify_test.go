@@ -0,0 +1,215 @@
+package mirror
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestVerifyImageSignatures(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	markInsecure(t, u.Host)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keyFile, pubFile := writeCosignKeyPair(t, key)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, otherPubFile := writeCosignKeyPair(t, other)
+
+	// Sign the image as cosign would
+	img, err := crane.Image(map[string][]byte{"file": []byte("content")})
+	require.NoError(t, err)
+	ref := fmt.Sprintf("%s/team/app:v1", u.Host)
+	require.NoError(t, crane.Push(img, ref))
+	src, err := imagesource.ParseReference(ref)
+	require.NoError(t, err)
+	mapping := image.TypedImageMapping{}
+	mapping.Add(src, src, v1alpha2.TypeGeneric)
+	signOpts := &MirrorOptions{DestPlainHTTP: true, CosignKey: keyFile}
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	signed := image.TypedImageMapping{}
+	pinned := src
+	pinned.Ref.ID = digest.String()
+	signed.Add(pinned, pinned, v1alpha2.TypeGeneric)
+	require.NoError(t, signOpts.signImages(context.TODO(), signed))
+
+	type spec struct {
+		name         string
+		verification v1alpha2.ImageVerification
+		expError     bool
+	}
+	cases := []spec{
+		{
+			name: "Valid/TrustedKey",
+			verification: v1alpha2.ImageVerification{
+				Policy: v1alpha2.VerificationPolicyEnforce,
+				Images: []v1alpha2.ImageSigners{{Pattern: u.Host + "/team/*", PublicKeys: []string{pubFile}}},
+			},
+		},
+		{
+			name: "Valid/UntrustedKeyWarn",
+			verification: v1alpha2.ImageVerification{
+				Images: []v1alpha2.ImageSigners{{Pattern: u.Host + "/**", PublicKeys: []string{otherPubFile}}},
+			},
+		},
+		{
+			name: "Valid/NoMatchingPattern",
+			verification: v1alpha2.ImageVerification{
+				Policy: v1alpha2.VerificationPolicyEnforce,
+				Images: []v1alpha2.ImageSigners{{Pattern: u.Host + "/other/*", PublicKeys: []string{otherPubFile}}},
+			},
+		},
+		{
+			name: "Invalid/UntrustedKeyEnforce",
+			verification: v1alpha2.ImageVerification{
+				Policy: v1alpha2.VerificationPolicyEnforce,
+				Images: []v1alpha2.ImageSigners{{Pattern: u.Host + "/**", PublicKeys: []string{otherPubFile}}},
+			},
+			expError: true,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			opts := &MirrorOptions{SourcePlainHTTP: true}
+			err := opts.verifyImageSignatures(context.TODO(), c.verification, mapping)
+			if c.expError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyCosignSignatureIdentity(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fulcio"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	// Keyless certificates expire minutes after they are issued
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leafTmpl := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(-50 * time.Minute),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		EmailAddresses:  []string{"ci@example.com"},
+		ExtraExtensions: []pkix.Extension{{Id: fulcioIssuerOID, Value: []byte("https://accounts.example.com")}},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTmpl, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER}))
+
+	digest := "sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8"
+	payload, err := cosignSignaturePayload("registry.example.com/team/app", digest)
+	require.NoError(t, err)
+	sig, err := signPayload(key, payload)
+	require.NoError(t, err)
+	layer := &blobLayer{content: payload, mediaType: sigstoreSignatureMediaType}
+	annotations := map[string]string{
+		sigstoreSignatureAnnotation: base64.StdEncoding.EncodeToString(sig),
+		cosignCertificateAnnotation: certPEM,
+	}
+
+	type spec struct {
+		name       string
+		identities []v1alpha2.SignerIdentity
+		digest     string
+		expError   bool
+	}
+	cases := []spec{
+		{
+			name:       "Valid/TrustedIdentity",
+			identities: []v1alpha2.SignerIdentity{{Issuer: "https://accounts.example.com", Subject: "ci@example.com"}},
+			digest:     digest,
+		},
+		{
+			name:       "Invalid/UntrustedSubject",
+			identities: []v1alpha2.SignerIdentity{{Issuer: "https://accounts.example.com", Subject: "dev@example.com"}},
+			digest:     digest,
+			expError:   true,
+		},
+		{
+			name:       "Invalid/UntrustedIssuer",
+			identities: []v1alpha2.SignerIdentity{{Issuer: "https://other.example.com", Subject: "ci@example.com"}},
+			digest:     digest,
+			expError:   true,
+		},
+		{
+			name:       "Invalid/OtherDigest",
+			identities: []v1alpha2.SignerIdentity{{Issuer: "https://accounts.example.com", Subject: "ci@example.com"}},
+			digest:     "sha256:" + fmt.Sprintf("%x", sha256.Sum256([]byte("other"))),
+			expError:   true,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			signers := imageSigners{identities: c.identities, roots: roots}
+			err := verifyCosignSignature(layer, annotations, c.digest, signers)
+			if c.expError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+// writeCosignKeyPair writes the unencrypted private key and the public key
+func writeCosignKeyPair(t *testing.T, key *ecdsa.PrivateKey) (string, string) {
+	dir := t.TempDir()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	keyFile := filepath.Join(dir, "cosign.key")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+	pubDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	pubFile := filepath.Join(dir, "cosign.pub")
+	require.NoError(t, os.WriteFile(pubFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0600))
+	return keyFile, pubFile
+}
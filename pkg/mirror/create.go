@@ -6,13 +6,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/sirupsen/logrus"
 
 	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
 	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/exitcode"
 	"github.com/openshift/oc-mirror/pkg/image"
 	"github.com/openshift/oc-mirror/pkg/metadata/storage"
 )
@@ -28,22 +29,22 @@ func (o *MirrorOptions) Create(ctx context.Context, cfg v1alpha2.ImageSetConfigu
 	var err error
 	if !cfg.StorageConfig.IsSet() {
 		meta.SingleUse = true
-		logrus.Warnf("backend is not configured in %s, using stateless mode", o.ConfigPath)
+		o.log().Warnf("backend is not configured in %s, using stateless mode", o.ConfigPath)
 		cfg.StorageConfig.Local = &v1alpha2.LocalConfig{Path: path}
-		backend, err = storage.ByConfig(path, cfg.StorageConfig)
+		backend, err = storage.ByConfig(path, cfg.StorageConfig, o.Clients())
 		if err != nil {
-			return meta, image.TypedImageMapping{}, fmt.Errorf("error opening backend: %v", err)
+			return meta, image.TypedImageMapping{}, exitcode.With(exitcode.StorageError, fmt.Errorf("error opening backend: %v", err))
 		}
 		defer func() {
 			if err := backend.Cleanup(ctx, config.MetadataBasePath); err != nil {
-				logrus.Error(err)
+				o.log().Error(err)
 			}
 		}()
 	} else {
 		meta.SingleUse = false
-		backend, err = storage.ByConfig(path, cfg.StorageConfig)
+		backend, err = storage.ByConfig(path, cfg.StorageConfig, o.Clients())
 		if err != nil {
-			return meta, image.TypedImageMapping{}, fmt.Errorf("error opening backend: %v", err)
+			return meta, image.TypedImageMapping{}, exitcode.With(exitcode.StorageError, fmt.Errorf("error opening backend: %v", err))
 		}
 	}
 	thisRun := v1alpha2.PastMirror{
@@ -52,13 +53,13 @@ func (o *MirrorOptions) Create(ctx context.Context, cfg v1alpha2.ImageSetConfigu
 	// Run full or diff mirror.
 	merr := backend.ReadMetadata(ctx, &meta, config.MetadataBasePath)
 	if merr != nil && !errors.Is(merr, storage.ErrMetadataNotExist) {
-		return meta, image.TypedImageMapping{}, merr
+		return meta, image.TypedImageMapping{}, exitcode.With(exitcode.StorageError, merr)
 	}
 	// New metadata files get a full mirror, with complete/heads-only catalogs, release images,
 	// and a new UUID. Otherwise, use data from the last mirror to mirror just the layer diff.
 	switch {
 	case merr != nil:
-		logrus.Info("No metadata detected, creating new workspace")
+		o.log().Info("No metadata detected, creating new workspace")
 		meta.Uid = uuid.New()
 		thisRun.Sequence = 1
 		thisRun.Mirror = cfg.Mirror
@@ -95,7 +96,7 @@ func (o *MirrorOptions) run(ctx context.Context, cfg *v1alpha2.ImageSetConfigura
 
 	mmappings := image.TypedImageMapping{}
 
-	if len(cfg.Mirror.Platform.Channels) != 0 {
+	if len(cfg.Mirror.Platform.Channels) != 0 || len(cfg.Mirror.Platform.Releases) != 0 || len(cfg.Mirror.Platform.UpgradePaths) != 0 {
 		release := NewReleaseOptions(o)
 		mappings, err := release.Plan(ctx, meta.PastMirror, cfg)
 		if err != nil {
@@ -103,22 +104,47 @@ func (o *MirrorOptions) run(ctx context.Context, cfg *v1alpha2.ImageSetConfigura
 		}
 		mmappings.Merge(mappings)
 
-		if cfg.Mirror.Platform.Graph {
-			logrus.Info("Adding graph data")
+		if cfg.Mirror.Platform.Graph && len(cfg.Mirror.Platform.Channels) != 0 {
+			o.log().Info("Adding graph data")
 			// Always add the graph base image to the metadata if needed,
 			// to ensure it does not get pruned before use.
-			cfg.Mirror.AdditionalImages = append(cfg.Mirror.AdditionalImages, v1alpha2.Image{Name: graphBaseImage})
+			baseImage := getGraphBaseImage(cfg.Mirror.Platform)
+			if !strings.Contains(baseImage, "@") {
+				o.log().Warnf("Graph base image %s is not pinned by digest, the graph image build may not be reproducible", baseImage)
+			}
+			cfg.Mirror.AdditionalImages = append(cfg.Mirror.AdditionalImages, v1alpha2.Image{Name: baseImage})
 
 			releaseDir := filepath.Join(o.Dir, config.SourceDir, config.GraphDataDir)
 			if err := os.MkdirAll(releaseDir, 0750); err != nil {
 				return mmappings, err
 			}
-			if err := downloadGraphData(ctx, releaseDir, graphURL); err != nil {
+			source := graphURL
+			if cfg.Mirror.Platform.GraphDataSource != "" {
+				source = cfg.Mirror.Platform.GraphDataSource
+			}
+			if err := o.downloadGraphData(ctx, releaseDir, source); err != nil {
+				return mmappings, err
+			}
+		}
+
+		if len(cfg.Mirror.Platform.BootImages.Platforms) != 0 && len(cfg.Mirror.Platform.Channels) != 0 {
+			o.log().Info("Adding RHCOS boot images")
+			if err := release.downloadBootImages(ctx, cfg.Mirror.Platform.Channels, cfg.Mirror.Platform.BootImages); err != nil {
 				return mmappings, err
 			}
 		}
 	}
 
+	// Layered RHCOS images are mirrored as additional images
+	osImages, err := o.getCoreOSImages(cfg.Mirror.Platform.CoreOS)
+	if err != nil {
+		return mmappings, err
+	}
+	if len(osImages) != 0 {
+		o.log().Info("Adding RHCOS layered images")
+		cfg.Mirror.AdditionalImages = append(cfg.Mirror.AdditionalImages, osImages...)
+	}
+
 	mappings, err := operatorPlan(ctx, *cfg)
 	if err != nil {
 		return mmappings, err
@@ -144,7 +170,19 @@ func (o *MirrorOptions) run(ctx context.Context, cfg *v1alpha2.ImageSetConfigura
 	}
 
 	if len(cfg.Mirror.Samples) != 0 {
-		logrus.Debugf("sample images full not implemented")
+		o.log().Debugf("sample images full not implemented")
+	}
+
+	if err := o.verifyImageSignatures(ctx, cfg.Mirror.Verification, mmappings); err != nil {
+		return mmappings, err
+	}
+
+	if o.MirrorSigstoreArtifacts {
+		artifacts, err := o.planSigstoreArtifacts(ctx, mmappings)
+		if err != nil {
+			return mmappings, err
+		}
+		mmappings.Merge(artifacts)
 	}
 
 	return mmappings, nil
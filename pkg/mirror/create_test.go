@@ -6,10 +6,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
-	"k8s.io/cli-runtime/pkg/genericclioptions"
 
 	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
-	"github.com/openshift/oc-mirror/pkg/cli"
 )
 
 func TestCreate(t *testing.T) {
@@ -22,15 +20,9 @@ func TestCreate(t *testing.T) {
 	cfg.Mirror.AdditionalImages = append(cfg.Mirror.AdditionalImages, img)
 
 	opts := MirrorOptions{
-		RootOptions: &cli.RootOptions{
-			Dir:      path,
-			LogLevel: "info",
-			IOStreams: genericclioptions.IOStreams{
-				In:     os.Stdin,
-				Out:    os.Stdout,
-				ErrOut: os.Stderr,
-			},
-		},
+		Dir:       path,
+		Out:       os.Stdout,
+		ErrOut:    os.Stderr,
 		OutputDir: path,
 	}
 	_, mappings, err := opts.Create(ctx, cfg)
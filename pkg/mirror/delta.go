@@ -0,0 +1,147 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/docker/distribution"
+	"github.com/opencontainers/go-digest"
+	"github.com/openshift/library-go/pkg/image/registryclient"
+	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+// parseDeltaRegistry parses the registry host and namespace of --delta-registry,
+// given like the destination of a mirror to registry run
+func parseDeltaRegistry(deltaRegistry string) (registry, namespace string, err error) {
+	ref, err := imagesource.ParseReference(strings.TrimPrefix(deltaRegistry, "docker://"))
+	if err != nil {
+		return "", "", fmt.Errorf("invalid delta registry %q: %v", deltaRegistry, err)
+	}
+	if ref.Ref.ID != "" || ref.Ref.Tag != "" {
+		return "", "", fmt.Errorf("invalid delta registry %q: must consist of registry host and namespace(s) only", deltaRegistry)
+	}
+	return ref.Ref.Registry, ref.Ref.AsRepository().RepositoryName(), nil
+}
+
+// deltaRepo returns the repository of the image in the delta registry,
+// which publishing to the registry mirrors the image to
+func deltaRepo(registry, namespace, imageName string) (imagesource.TypedImageReference, error) {
+	ref, err := imagesource.ParseReference(imageName)
+	if err != nil {
+		return ref, err
+	}
+	ref.Ref.Registry = registry
+	ref.Ref.Namespace = path.Join(namespace, ref.Ref.Namespace)
+	ref.Ref.Tag = ""
+	ref.Ref.ID = ""
+	return ref, nil
+}
+
+// registryBlobs returns the digests of the blobs of the associations that the
+// delta registry already has. A blob is only returned when the repositories
+// of all the images using it have it, so publishing the imageset can fetch
+// the blobs left out of it from the repository of any of those images.
+func (o *MirrorOptions) registryBlobs(ctx context.Context, assocs image.AssociationSet) (map[string]struct{}, error) {
+	registry, namespace, err := parseDeltaRegistry(o.DeltaRegistry)
+	if err != nil {
+		return nil, err
+	}
+	regctx, err := o.Clients().Context(o.SkipVerification)
+	if err != nil {
+		return nil, fmt.Errorf("error creating registry context: %v", err)
+	}
+	insecure := o.DestPlainHTTP || o.DestSkipTLS
+
+	type blobRepo struct {
+		repo   string
+		digest string
+	}
+	repos := map[string]imagesource.TypedImageReference{}
+	checks := map[blobRepo]struct{}{}
+	for _, imageName := range assocs.Keys() {
+		repo, err := deltaRepo(registry, namespace, imageName)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing image %q: %v", imageName, err)
+		}
+		repos[repo.Ref.Exact()] = repo
+		values, _ := assocs.Search(imageName)
+		for _, assoc := range values {
+			for _, dgst := range assoc.LayerDigests {
+				checks[blobRepo{repo: repo.Ref.Exact(), digest: dgst}] = struct{}{}
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	present := map[string]bool{}
+	work := make(chan blobRepo)
+	g, ctx := errgroup.WithContext(ctx)
+	for i := 0; i < analyzeWorkers; i++ {
+		g.Go(func() error {
+			for check := range work {
+				found, err := o.hasBlob(ctx, regctx, repos[check.repo], check.digest, insecure)
+				if err != nil {
+					return err
+				}
+				mu.Lock()
+				if seen, ok := present[check.digest]; !ok || seen {
+					present[check.digest] = found
+				}
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	g.Go(func() error {
+		defer close(work)
+		for check := range checks {
+			select {
+			case work <- check:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return nil, fmt.Errorf("error querying delta registry %s: %v", o.DeltaRegistry, err)
+	}
+
+	blobs := map[string]struct{}{}
+	for dgst, found := range present {
+		if found {
+			blobs[dgst] = struct{}{}
+		}
+	}
+	return blobs, nil
+}
+
+// hasBlob returns whether the repository has the blob. Registries that cannot
+// be reached are an error, but blobs that cannot be queried are kept in the
+// imageset, since they may not exist in the registry.
+func (o *MirrorOptions) hasBlob(ctx context.Context, regctx *registryclient.Context, ref imagesource.TypedImageReference, layerDigest string, insecure bool) (bool, error) {
+	dgst, err := digest.Parse(layerDigest)
+	if err != nil {
+		return false, err
+	}
+	repo, err := regctx.RepositoryForRef(ctx, ref.Ref, insecure)
+	if err != nil {
+		return false, fmt.Errorf("create repo for %s: %v", ref.Ref.Exact(), err)
+	}
+	switch _, err := repo.Blobs(ctx).Stat(ctx, dgst); {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, distribution.ErrBlobUnknown):
+		return false, nil
+	default:
+		o.log().Debugf("keeping blob %s in the imageset: error querying %s: %v", layerDigest, ref.Ref.Exact(), err)
+		return false, nil
+	}
+}
@@ -0,0 +1,133 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestParseDeltaRegistry(t *testing.T) {
+	type spec struct {
+		name         string
+		registry     string
+		expRegistry  string
+		expNamespace string
+		expError     string
+	}
+	cases := []spec{
+		{
+			name:         "Valid/Namespace",
+			registry:     "docker://registry.example.com:5000/mirror/ocp",
+			expRegistry:  "registry.example.com:5000",
+			expNamespace: "mirror/ocp",
+		},
+		{
+			name:         "Valid/NoScheme",
+			registry:     "registry.example.com/mirror",
+			expRegistry:  "registry.example.com",
+			expNamespace: "mirror",
+		},
+		{
+			name:     "Invalid/Tag",
+			registry: "docker://registry.example.com/mirror:latest",
+			expError: `invalid delta registry "docker://registry.example.com/mirror:latest": must consist of registry host and namespace(s) only`,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			host, namespace, err := parseDeltaRegistry(c.registry)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expRegistry, host)
+			require.Equal(t, c.expNamespace, namespace)
+		})
+	}
+}
+
+func TestRegistryBlobs(t *testing.T) {
+	// The test registry shares blobs between repositories,
+	// so the ubi-minimal repository is made to have none
+	reg := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/ubi-minimal/blobs/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	markInsecure(t, u.Host)
+
+	// The mirror registry has the ubi image from a previous publish
+	layer, err := crane.Layer(map[string][]byte{"file": []byte("content")})
+	require.NoError(t, err)
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+	require.NoError(t, crane.Push(img, fmt.Sprintf("%s/mirror/ubi8/ubi:latest", u.Host), crane.Insecure))
+	present, err := layer.Digest()
+	require.NoError(t, err)
+	missing := "sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8"
+
+	assoc := func(name string, layers ...string) image.AssociationSet {
+		return image.AssociationSet{name: image.Associations{name: {
+			Name:         name,
+			Path:         "ubi8/ubi",
+			ID:           "sha256:d31c6ea5c50be93d6eb94d2b508f0208e84a308c011c6454ebf291d48b37df19",
+			TagSymlink:   "latest",
+			Type:         v1alpha2.TypeGeneric,
+			LayerDigests: layers,
+		}}}
+	}
+
+	type spec struct {
+		name   string
+		assocs image.AssociationSet
+		exp    map[string]struct{}
+	}
+	shared := assoc("registry.redhat.io/ubi8/ubi:latest", present.String(), missing)
+	shared.Merge(assoc("registry.redhat.io/ubi8/ubi-minimal:latest", present.String()))
+	cases := []spec{
+		{
+			name:   "Valid/Present",
+			assocs: assoc("registry.redhat.io/ubi8/ubi:latest", present.String(), missing),
+			exp:    map[string]struct{}{present.String(): {}},
+		},
+		{
+			// The blob is kept since the ubi-minimal repository does not have it
+			name:   "Valid/MissingFromARepository",
+			assocs: shared,
+			exp:    map[string]struct{}{},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			o := &MirrorOptions{
+				DeltaRegistry: fmt.Sprintf("docker://%s/mirror", u.Host),
+				DestPlainHTTP: true,
+			}
+			blobs, err := o.registryBlobs(context.Background(), c.assocs)
+			require.NoError(t, err)
+			require.Equal(t, c.exp, blobs)
+		})
+	}
+}
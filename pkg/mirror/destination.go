@@ -0,0 +1,72 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/metadata/storage"
+)
+
+// ReadDestinationMetadata reads the metadata in the storage of the configuration
+// and the metadata images published to the destination namespace
+func (o *MirrorOptions) ReadDestinationMetadata(ctx context.Context, cfg *v1alpha2.ImageSetConfiguration) ([]v1alpha2.Metadata, error) {
+	var metas []v1alpha2.Metadata
+	read := func(storageCfg v1alpha2.StorageConfig) error {
+		// Registry backends unpack the metadata to a local directory
+		tmpdir, err := ioutil.TempDir("", "oc-mirror-metadata")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmpdir)
+		backend, err := storage.ByConfig(tmpdir, storageCfg, o.Clients())
+		if err != nil {
+			return err
+		}
+		meta := v1alpha2.NewMetadata()
+		switch err := backend.ReadMetadata(ctx, &meta, config.MetadataBasePath); {
+		case errors.Is(err, storage.ErrMetadataNotExist):
+			return nil
+		case err != nil:
+			return err
+		}
+		metas = append(metas, meta)
+		return nil
+	}
+
+	if cfg != nil && cfg.StorageConfig.IsSet() {
+		if err := read(cfg.StorageConfig); err != nil {
+			return nil, fmt.Errorf("error reading the metadata of the imageset configuration: %v", err)
+		}
+		if len(metas) != 0 && !reflect.DeepEqual(metas[0].PastMirror.Mirror, cfg.Mirror) {
+			o.log().Warn("the imageset configuration changed since the last run, the content it adds or removes is only recorded after a run updates the metadata")
+		}
+	}
+
+	insecure := o.DestPlainHTTP || o.DestSkipTLS
+	repo, err := name.NewRepository(path.Join(o.ToMirror, o.UserNamespace, "oc-mirror"), getNameOpts(insecure)...)
+	if err != nil {
+		return nil, err
+	}
+	uids, err := remote.List(repo, o.getRemoteOpts(ctx, insecure)...)
+	if err != nil && !isNotFound(err) {
+		return nil, fmt.Errorf("error listing the metadata images of %s: %v", repo, err)
+	}
+	for _, uid := range uids {
+		o.log().Debugf("Reading metadata image %s:%s", repo, uid)
+		imageCfg := v1alpha2.StorageConfig{Registry: &v1alpha2.RegistryConfig{ImageURL: repo.Tag(uid).String(), SkipTLS: insecure}}
+		if err := read(imageCfg); err != nil {
+			return nil, fmt.Errorf("error reading metadata image %s:%s: %v", repo, uid, err)
+		}
+	}
+	return metas, nil
+}
@@ -0,0 +1,7 @@
+// Package mirror plans, associates, archives, and publishes imagesets.
+//
+// It is the core of the oc-mirror command and of the ocmirror library, which
+// configure a run with the fields of MirrorOptions. The logger and registry
+// clients of a run are set with the options of NewMirrorOptions, so runs can
+// be embedded without the command line and the state of the process.
+package mirror
@@ -0,0 +1,68 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+
+	"github.com/containerd/containerd/errdefs"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
+	"github.com/openshift/oc-mirror/pkg/metadata"
+	"github.com/openshift/oc-mirror/pkg/metadata/storage"
+)
+
+// writeDryRunMetadata associates the planned images from their manifests
+// in the source registries, without downloading their blobs, and writes
+// the metadata the imageset would contain to the results directory.
+func (o *MirrorOptions) writeDryRunMetadata(ctx context.Context, mapping image.TypedImageMapping, prevAssocs image.AssociationSet, meta *v1alpha2.Metadata) (string, error) {
+	var pastAssocs image.AssociationSet
+	if !o.IgnoreHistory {
+		pastAssocs = prevAssocs
+	}
+	done := o.startPhase(phaseAssociate)
+	assocs, errs := o.Clients().AssociateRemoteImageLayers(ctx, mapping, pastAssocs, o.SourceSkipTLS, o.SourcePlainHTTP, o.SkipVerification, o.parallelOptions())
+	done(errs)
+	skipErr := func(err error) bool {
+		ierr := &image.ErrInvalidImage{}
+		cerr := &image.ErrInvalidComponent{}
+		return errors.As(err, &ierr) || errors.As(err, &cerr) || (o.SkipMissing && errors.Is(err, errdefs.ErrNotFound))
+	}
+	if errs != nil {
+		for _, e := range errs.Errors() {
+			if err := o.checkErr(e, skipErr); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	var err error
+	meta.PastMirror.Associations, err = image.ConvertFromAssociationSet(assocs)
+	if err != nil {
+		return "", err
+	}
+	prevAssocs.Merge(assocs)
+	meta.PastAssociations, err = image.ConvertFromAssociationSet(prevAssocs)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := o.createResultsDir()
+	if err != nil {
+		return "", err
+	}
+	backend, err := storage.ByConfig(dir, v1alpha2.StorageConfig{
+		Local: &v1alpha2.LocalConfig{Path: dir},
+	}, o.Clients())
+	if err != nil {
+		return "", err
+	}
+	if err := metadata.UpdateMetadata(ctx, o.Clients(), backend, meta, filepath.Join(o.Dir, config.SourceDir), o.SourceSkipTLS, o.SourcePlainHTTP); err != nil {
+		return "", err
+	}
+	metadataPath := filepath.Join(dir, config.MetadataBasePath)
+	o.log().Infof("Wrote imageset metadata to %s", metadataPath)
+	return metadataPath, nil
+}
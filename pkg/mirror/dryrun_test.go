@@ -0,0 +1,72 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestWriteDryRunMetadata(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	markInsecure(t, u.Host)
+
+	layer, err := crane.Layer(map[string][]byte{"file": []byte("content")})
+	require.NoError(t, err)
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+	require.NoError(t, crane.Push(img, fmt.Sprintf("%s/ubi8/ubi:latest", u.Host), crane.Insecure))
+	dgst, err := img.Digest()
+	require.NoError(t, err)
+
+	src, err := image.ParseTypedImage(u.Host+"/ubi8/ubi:latest", v1alpha2.TypeGeneric)
+	require.NoError(t, err)
+	dst, err := image.ParseTypedImage("file://ubi8/ubi:latest", v1alpha2.TypeGeneric)
+	require.NoError(t, err)
+	mapping := image.TypedImageMapping{src: dst}
+
+	o := &MirrorOptions{
+		Dir:             t.TempDir(),
+		SourcePlainHTTP: true,
+	}
+	meta := v1alpha2.NewMetadata()
+	meta.PastMirror.Sequence = 1
+	path, err := o.writeDryRunMetadata(context.Background(), mapping, image.AssociationSet{}, &meta)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(o.resultsDir, config.MetadataBasePath), path)
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	var got v1alpha2.Metadata
+	require.NoError(t, json.Unmarshal(data, &got))
+	require.Equal(t, 1, got.PastMirror.Sequence)
+	require.Len(t, got.PastMirror.Associations, 1)
+	assoc := got.PastMirror.Associations[0]
+	require.Equal(t, "ubi8/ubi", assoc.Path)
+	require.Equal(t, dgst.String(), assoc.ID)
+	require.Equal(t, "latest", assoc.TagSymlink)
+	require.Len(t, assoc.LayerDigests, 2)
+	require.Equal(t, got.PastMirror.Associations, got.PastAssociations)
+
+	// The metadata is not a generated manifest
+	manifests, _, err := listResultFiles(o.resultsDir)
+	require.NoError(t, err)
+	require.Empty(t, manifests)
+}
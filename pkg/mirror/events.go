@@ -0,0 +1,159 @@
+package mirror
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	units "github.com/docker/go-units"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+// Phases of a run
+const (
+	phasePlan      = "plan"
+	phaseMirror    = "mirror"
+	phaseAssociate = "associate"
+	phaseArchive   = "archive"
+	phaseUpload    = "upload"
+	phaseUnpack    = "unpack"
+	phasePush      = "push"
+	phaseManifests = "manifests"
+	phaseApply     = "apply"
+)
+
+// eventsStdout is the events path that writes events to stdout
+const eventsStdout = "-"
+
+// eventEmitter writes run events as newline delimited JSON,
+// and passes them to its handler if any.
+// The methods of a nil eventEmitter are no-ops.
+type eventEmitter struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	closer  io.Closer
+	handler func(v1alpha2.Event)
+	log     logrus.FieldLogger
+}
+
+// newEventEmitter returns an eventEmitter writing to the file at path,
+// or to stdout if path is "-"
+func newEventEmitter(path string, stdout io.Writer, log logrus.FieldLogger) (*eventEmitter, error) {
+	if path == eventsStdout {
+		return &eventEmitter{enc: json.NewEncoder(stdout), log: log}, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("error opening events output: %v", err)
+	}
+	return &eventEmitter{enc: json.NewEncoder(f), closer: f, log: log}, nil
+}
+
+// emit writes the event, setting its time if unset
+func (e *eventEmitter) emit(event v1alpha2.Event) {
+	if e == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.enc != nil {
+		if err := e.enc.Encode(event); err != nil {
+			e.log.Debugf("error writing event: %v", err)
+		}
+	}
+	if e.handler != nil {
+		e.handler(event)
+	}
+}
+
+// Close closes the events output
+func (e *eventEmitter) Close() error {
+	if e == nil || e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// observe emits the event described by a line of oc image mirror output
+func (e *eventEmitter) observe(line string) {
+	if e == nil {
+		return
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	switch {
+	case fields[0] == "uploading:" && len(fields) == 4:
+		size, _ := units.RAMInBytes(fields[3])
+		e.emit(v1alpha2.Event{Type: v1alpha2.EventBlobFetched, Image: fields[1], Digest: fields[2], Size: size})
+	case fields[0] == "mounted:" && len(fields) == 4:
+		size, _ := units.RAMInBytes(fields[3])
+		e.emit(v1alpha2.Event{Type: v1alpha2.EventBlobMounted, Image: fields[1], Digest: fields[2], Size: size})
+	case strings.HasPrefix(fields[0], "sha256:") && len(fields) == 2:
+		e.emit(v1alpha2.Event{Type: v1alpha2.EventImagePushed, Image: fields[1], Digest: fields[0]})
+	case strings.HasPrefix(line, "warning: Image ") && strings.HasSuffix(line, " does not exist and will not be mirrored"):
+		e.emit(v1alpha2.Event{Type: v1alpha2.EventImageSkipped, Image: fields[2]})
+	case fields[0] == "error:":
+		e.emit(v1alpha2.Event{Type: v1alpha2.EventError, Error: strings.TrimPrefix(line, "error: ")})
+	}
+}
+
+// startPhase marks the start of a phase of the run.
+// The returned function marks the end of the phase with its error.
+func (o *MirrorOptions) startPhase(phase string) func(error) {
+	start := time.Now()
+	o.events.emit(v1alpha2.Event{Type: v1alpha2.EventPhaseStart, Phase: phase})
+	endSpan := o.tracer.start(phase, attribute.String("oc_mirror.phase", phase))
+	stopProgress := func() {}
+	switch phase {
+	case phaseMirror, phaseArchive, phaseUnpack, phasePush:
+		stopProgress = o.startProgress(phase)
+	}
+	return func(err error) {
+		stopProgress()
+		o.metrics.observePhase(phase, time.Since(start))
+		success := err == nil
+		o.phases = append(o.phases, v1alpha2.PhaseTiming{
+			Name:            phase,
+			DurationSeconds: time.Since(start).Seconds(),
+			Success:         success,
+		})
+		event := v1alpha2.Event{
+			Type:            v1alpha2.EventPhaseEnd,
+			Phase:           phase,
+			DurationSeconds: time.Since(start).Seconds(),
+			Success:         &success,
+		}
+		if err != nil {
+			event.Error = err.Error()
+		}
+		o.events.emit(event)
+		endSpan(err)
+	}
+}
+
+// runEndEvent returns the event marking the end of the run with its results
+func runEndEvent(results *v1alpha2.Results) v1alpha2.Event {
+	success := results.Success
+	event := v1alpha2.Event{
+		Type:            v1alpha2.EventRunEnd,
+		Operation:       results.Operation,
+		DurationSeconds: results.DurationSeconds,
+		Success:         &success,
+	}
+	if !success && len(results.Failures) != 0 {
+		event.Error = results.Failures[len(results.Failures)-1].Error
+	}
+	return event
+}
@@ -0,0 +1,98 @@
+package mirror
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+func TestEventEmitter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	events, err := newEventEmitter(path, nil, logrus.New())
+	require.NoError(t, err)
+	opts := &MirrorOptions{events: events}
+
+	done := opts.startPhase(phaseMirror)
+	for _, line := range []string{
+		"uploading: reg.mirror.com/ubi8/ubi sha256:9e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8 2MiB",
+		"mounted: reg.mirror.com/ubi8/ubi-minimal sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8 1.5KiB",
+		"sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8 reg.mirror.com/ubi8/ubi:latest",
+		"warning: Image registry.redhat.io/ubi8/ubi-micro:latest does not exist and will not be mirrored",
+		"error: unable to retrieve source image registry.redhat.io/ubi8/ubi-init:latest manifests",
+		"info: Mirroring completed in 1.2s (1.7MB/s)",
+	} {
+		events.observe(line)
+	}
+	done(errors.New("one or more errors occurred"))
+	require.NoError(t, events.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var got []v1alpha2.Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event v1alpha2.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		require.False(t, event.Time.IsZero())
+		got = append(got, event)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, got, 7)
+
+	failed := false
+	exp := []v1alpha2.Event{
+		{Type: v1alpha2.EventPhaseStart, Phase: phaseMirror},
+		{Type: v1alpha2.EventBlobFetched, Image: "reg.mirror.com/ubi8/ubi", Digest: "sha256:9e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8", Size: 2 * 1024 * 1024},
+		{Type: v1alpha2.EventBlobMounted, Image: "reg.mirror.com/ubi8/ubi-minimal", Digest: "sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8", Size: 1536},
+		{Type: v1alpha2.EventImagePushed, Image: "reg.mirror.com/ubi8/ubi:latest", Digest: "sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8"},
+		{Type: v1alpha2.EventImageSkipped, Image: "registry.redhat.io/ubi8/ubi-micro:latest"},
+		{Type: v1alpha2.EventError, Error: "unable to retrieve source image registry.redhat.io/ubi8/ubi-init:latest manifests"},
+		{Type: v1alpha2.EventPhaseEnd, Phase: phaseMirror, Success: &failed, Error: "one or more errors occurred"},
+	}
+	// Times and durations vary between runs
+	for i := range got {
+		got[i].Time = exp[i].Time
+		got[i].DurationSeconds = 0
+	}
+	require.Equal(t, exp, got)
+}
+
+func TestEventEmitterHandler(t *testing.T) {
+	var got []v1alpha2.Event
+	events := &eventEmitter{handler: func(e v1alpha2.Event) { got = append(got, e) }}
+	events.observe("sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8 reg.mirror.com/ubi8/ubi:latest")
+	events.emit(v1alpha2.Event{Type: v1alpha2.EventPhaseStart, Phase: phasePush})
+	require.NoError(t, events.Close())
+
+	require.Len(t, got, 2)
+	require.Equal(t, v1alpha2.EventImagePushed, got[0].Type)
+	require.Equal(t, "reg.mirror.com/ubi8/ubi:latest", got[0].Image)
+	require.False(t, got[0].Time.IsZero())
+	require.Equal(t, v1alpha2.EventPhaseStart, got[1].Type)
+}
+
+func TestRunEndEvent(t *testing.T) {
+	results := newResults(v1alpha2.OperationDiskToMirror)
+	results.DurationSeconds = 1.5
+	results.Failures = []v1alpha2.Failure{{Error: "skipped"}, {Error: "failed"}}
+	event := runEndEvent(results)
+	require.Equal(t, v1alpha2.EventRunEnd, event.Type)
+	require.Equal(t, v1alpha2.OperationDiskToMirror, event.Operation)
+	require.Equal(t, 1.5, event.DurationSeconds)
+	require.False(t, *event.Success)
+	require.Equal(t, "failed", event.Error)
+
+	// A nil emitter does not emit
+	var events *eventEmitter
+	events.emit(event)
+	require.NoError(t, events.Close())
+}
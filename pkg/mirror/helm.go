@@ -9,7 +9,6 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/sirupsen/logrus"
 	"helm.sh/helm/v3/pkg/action"
 	helmchart "helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
@@ -49,7 +48,7 @@ func (h *HelmOptions) PullCharts(ctx context.Context, cfg v1alpha2.ImageSetConfi
 	var images []v1alpha2.Image
 
 	// Create a temp file for to hold repo information
-	cleanup, file, err := mktempFile(h.Dir)
+	cleanup, file, err := h.mktempFile(h.Dir)
 	if err != nil {
 		return nil, err
 	}
@@ -74,7 +73,7 @@ func (h *HelmOptions) PullCharts(ctx context.Context, cfg v1alpha2.ImageSetConfi
 	for _, chart := range cfg.Mirror.Helm.Local {
 
 		// find images associations with chart (default values)
-		img, err := findImages(chart.Path, chart.ImagePaths...)
+		img, err := h.findImages(chart.Path, chart.ImagePaths...)
 		if err != nil {
 			return nil, err
 		}
@@ -90,7 +89,7 @@ func (h *HelmOptions) PullCharts(ctx context.Context, cfg v1alpha2.ImageSetConfi
 		}
 
 		for _, chart := range repo.Charts {
-			logrus.Infof("Pulling chart %s", chart.Name)
+			h.log().Infof("Pulling chart %s", chart.Name)
 			// TODO: Do something with the returned verifications
 			ref := fmt.Sprintf("%s/%s", repo.Name, chart.Name)
 			dest := filepath.Join(h.Dir, config.SourceDir, config.HelmDir)
@@ -100,7 +99,7 @@ func (h *HelmOptions) PullCharts(ctx context.Context, cfg v1alpha2.ImageSetConfi
 			}
 
 			// find images associations with chart (default values)
-			img, err := findImages(path, chart.ImagePaths...)
+			img, err := h.findImages(path, chart.ImagePaths...)
 			if err != nil {
 				return nil, err
 			}
@@ -115,9 +114,9 @@ func (h *HelmOptions) PullCharts(ctx context.Context, cfg v1alpha2.ImageSetConfi
 }
 
 // FindImages will download images found in a Helm chart on disk
-func findImages(path string, imagePaths ...string) (images []v1alpha2.Image, err error) {
+func (o *MirrorOptions) findImages(path string, imagePaths ...string) (images []v1alpha2.Image, err error) {
 
-	logrus.Debugf("Reading from path %s", path)
+	o.log().Debugf("Reading from path %s", path)
 
 	// Get all json paths where images
 	// are located
@@ -136,7 +135,7 @@ func findImages(path string, imagePaths ...string) (images []v1alpha2.Image, err
 	// Process each YAML document seperately
 	for _, single := range bytes.Split([]byte(manifest), []byte("\n---\n")) {
 
-		imgs, err := search(single, p...)
+		imgs, err := o.search(single, p...)
 
 		if err != nil {
 			return nil, err
@@ -202,7 +201,7 @@ func (h *HelmOptions) repoAdd(chartRepo v1alpha2.Repository) error {
 
 	// Check for existing repo name
 	if helmFile.Has(chartRepo.Name) {
-		logrus.Infof("repository name (%s) already exists", chartRepo.Name)
+		h.log().Infof("repository name (%s) already exists", chartRepo.Name)
 		return nil
 	}
 
@@ -242,12 +241,12 @@ func parseJSONPath(input interface{}, parser *jsonpath.JSONPath, template string
 }
 
 // search will return images from parsed object
-func search(yamlData []byte, paths ...string) (images []v1alpha2.Image, err error) {
+func (o *MirrorOptions) search(yamlData []byte, paths ...string) (images []v1alpha2.Image, err error) {
 
 	var data interface{}
 	// yaml.Unmarshal will convert YAMl to JSON first
 	if err := yaml.Unmarshal(yamlData, &data); err != nil {
-		logrus.Error(err)
+		o.log().Error(err)
 	}
 
 	j := jsonpath.New("")
@@ -260,7 +259,7 @@ func search(yamlData []byte, paths ...string) (images []v1alpha2.Image, err erro
 		}
 
 		for _, result := range results {
-			logrus.Debugf("Found image %s", result)
+			o.log().Debugf("Found image %s", result)
 			img := v1alpha2.Image{
 				Name: result,
 			}
@@ -274,11 +273,11 @@ func search(yamlData []byte, paths ...string) (images []v1alpha2.Image, err erro
 
 // mkTempFile will make a temporary file and return the name
 // and cleanup method
-func mktempFile(dir string) (func(), string, error) {
+func (h *HelmOptions) mktempFile(dir string) (func(), string, error) {
 	file, err := ioutil.TempFile(dir, "repo.*")
 	return func() {
 		if err := os.Remove(file.Name()); err != nil {
-			logrus.Fatal(err)
+			h.log().Fatal(err)
 		}
 	}, file.Name(), err
 }
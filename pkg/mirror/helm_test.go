@@ -66,7 +66,7 @@ func TestSearch(t *testing.T) {
 		b, err := ioutil.ReadFile(tt.path)
 		require.NoError(t, err)
 
-		imgs, err := search(b, tt.ipaths...)
+		imgs, err := NewMirrorOptions().search(b, tt.ipaths...)
 		require.NoError(t, err)
 
 		if !reflect.DeepEqual(imgs, tt.want) {
@@ -154,7 +154,7 @@ func TestFindImages(t *testing.T) {
 		{Name: "ghcr.io/stefanprodan/podinfo:6.0.0"},
 	}
 
-	imgs, err := findImages(path, ipaths...)
+	imgs, err := NewMirrorOptions().findImages(path, ipaths...)
 	require.NoError(t, err)
 
 	if !reflect.DeepEqual(imgs, want) {
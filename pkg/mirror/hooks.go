@@ -0,0 +1,129 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+// configHooks returns the hooks of the configuration of the run. Only
+// configurations given with --config run commands on the host, so the
+// hooks of remotely submitted configurations are ignored.
+func (o *MirrorOptions) configHooks(cfg v1alpha2.ImageSetConfiguration) []v1alpha2.Hook {
+	if o.RemoteConfig {
+		if len(cfg.Hooks) != 0 {
+			o.log().Warn("Ignoring the hooks of the remotely submitted imageset configuration")
+		}
+		return nil
+	}
+	return cfg.Hooks
+}
+
+// withHooks runs fn between the before and after hooks of the phase.
+// The after hooks also run when fn failed, and their failure is
+// only returned when fn succeeded.
+func (o *MirrorOptions) withHooks(ctx context.Context, phase v1alpha2.HookPhase, fn func() error) error {
+	if err := o.runHooks(ctx, phase, v1alpha2.HookBefore, nil); err != nil {
+		return err
+	}
+	err := fn()
+	phaseErr := err
+	if errors.Is(err, ErrNoUpdatesExist) {
+		// The run stops without failing when there is nothing to mirror
+		phaseErr = nil
+	}
+	if herr := o.runHooks(ctx, phase, v1alpha2.HookAfter, phaseErr); herr != nil {
+		if err == nil {
+			return herr
+		}
+		o.log().Error(herr)
+	}
+	return err
+}
+
+// runHooks runs the hooks of the phase in the order of the configuration
+func (o *MirrorOptions) runHooks(ctx context.Context, phase v1alpha2.HookPhase, when v1alpha2.HookWhen, phaseErr error) error {
+	for _, hook := range o.hooks {
+		if hook.Phase != phase || hook.When != when {
+			continue
+		}
+		if err := o.runHook(ctx, hook, phaseErr); err != nil {
+			if !hook.ContinueOnError {
+				return err
+			}
+			o.log().Warn(err)
+		}
+	}
+	return nil
+}
+
+func (o *MirrorOptions) runHook(ctx context.Context, hook v1alpha2.Hook, phaseErr error) error {
+	name := hook.Name
+	if name == "" {
+		name = strings.Join(hook.Command, " ")
+	}
+	if hook.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(hook.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+	o.log().Infof("Running hook %q %s the %s phase", name, hook.When, hook.Phase)
+	cmd := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+	cmd.Env = append(os.Environ(), o.hookEnv(hook, phaseErr)...)
+	cmd.Stdout = o.Out
+	cmd.Stderr = o.ErrOut
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %ds", hook.TimeoutSeconds)
+		}
+		return fmt.Errorf("hook %q %s the %s phase failed: %v", name, hook.When, hook.Phase, err)
+	}
+	return nil
+}
+
+// hookEnv returns the environment variables with the context
+// of the run passed to a hook, and the variables of the hook
+func (o *MirrorOptions) hookEnv(hook v1alpha2.Hook, phaseErr error) []string {
+	env := []string{
+		"OC_MIRROR_HOOK_PHASE=" + string(hook.Phase),
+		"OC_MIRROR_HOOK_WHEN=" + string(hook.When),
+		"OC_MIRROR_OPERATION=" + string(o.operation()),
+		"OC_MIRROR_WORKSPACE=" + o.Dir,
+	}
+	if o.ConfigPath != "" {
+		env = append(env, "OC_MIRROR_CONFIG="+o.ConfigPath)
+	}
+	if o.OutputDir != "" {
+		env = append(env, "OC_MIRROR_OUTPUT_DIR="+o.OutputDir)
+	}
+	if o.From != "" {
+		env = append(env, "OC_MIRROR_FROM="+o.From)
+	}
+	if o.ToMirror != "" {
+		env = append(env, "OC_MIRROR_DESTINATION="+path.Join(o.ToMirror, o.UserNamespace))
+	}
+	if hook.When == v1alpha2.HookAfter {
+		env = append(env, "OC_MIRROR_PHASE_SUCCESS="+strconv.FormatBool(phaseErr == nil))
+		if phaseErr != nil {
+			env = append(env, "OC_MIRROR_PHASE_ERROR="+phaseErr.Error())
+		}
+	}
+	names := make([]string, 0, len(hook.Env))
+	for name := range hook.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+hook.Env[name])
+	}
+	return env
+}
@@ -0,0 +1,157 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+func TestWithHooks(t *testing.T) {
+	type spec struct {
+		name     string
+		hooks    []v1alpha2.Hook
+		fnErr    error
+		expRun   bool
+		expLog   string
+		expError string
+	}
+	shell := func(when v1alpha2.HookWhen, script string) v1alpha2.Hook {
+		return v1alpha2.Hook{Phase: v1alpha2.HookPhaseArchive, When: when, Command: []string{"sh", "-c", script}}
+	}
+	cases := []spec{
+		{
+			name: "Valid/BeforeAndAfter",
+			hooks: []v1alpha2.Hook{
+				shell(v1alpha2.HookAfter, `echo "after $OC_MIRROR_PHASE_SUCCESS" >> "$LOG"`),
+				shell(v1alpha2.HookBefore, `echo "before $OC_MIRROR_HOOK_PHASE $OC_MIRROR_OPERATION $OC_MIRROR_OUTPUT_DIR" >> "$LOG"`),
+				{Phase: v1alpha2.HookPhasePlan, When: v1alpha2.HookBefore, Command: []string{"false"}},
+			},
+			expRun: true,
+			expLog: "before archive mirrorToDisk archives\nafter true\n",
+		},
+		{
+			name: "Valid/AfterFailedPhase",
+			hooks: []v1alpha2.Hook{
+				shell(v1alpha2.HookAfter, `echo "after $OC_MIRROR_PHASE_SUCCESS $OC_MIRROR_PHASE_ERROR" >> "$LOG"; exit 1`),
+			},
+			fnErr:    errors.New("disk full"),
+			expRun:   true,
+			expLog:   "after false disk full\n",
+			expError: "disk full",
+		},
+		{
+			name: "Valid/NoUpdates",
+			hooks: []v1alpha2.Hook{
+				shell(v1alpha2.HookAfter, `echo "after $OC_MIRROR_PHASE_SUCCESS" >> "$LOG"`),
+			},
+			fnErr:    ErrNoUpdatesExist,
+			expRun:   true,
+			expLog:   "after true\n",
+			expError: ErrNoUpdatesExist.Error(),
+		},
+		{
+			name: "Valid/ContinueOnError",
+			hooks: []v1alpha2.Hook{
+				{Phase: v1alpha2.HookPhaseArchive, When: v1alpha2.HookBefore, Command: []string{"false"}, ContinueOnError: true},
+			},
+			expRun: true,
+		},
+		{
+			name: "Invalid/BeforeFailed",
+			hooks: []v1alpha2.Hook{
+				{Name: "mount", Phase: v1alpha2.HookPhaseArchive, When: v1alpha2.HookBefore, Command: []string{"false"}},
+			},
+			expError: `hook "mount" before the archive phase failed: exit status 1`,
+		},
+		{
+			name: "Invalid/AfterFailed",
+			hooks: []v1alpha2.Hook{
+				{Phase: v1alpha2.HookPhaseArchive, When: v1alpha2.HookAfter, Command: []string{"sh", "-c", "exit 3"}},
+			},
+			expRun:   true,
+			expError: `hook "sh -c exit 3" after the archive phase failed: exit status 3`,
+		},
+		{
+			name: "Invalid/Timeout",
+			hooks: []v1alpha2.Hook{
+				{Name: "scan", Phase: v1alpha2.HookPhaseArchive, When: v1alpha2.HookBefore, Command: []string{"sleep", "10"}, TimeoutSeconds: 1},
+			},
+			expError: `hook "scan" before the archive phase failed: timed out after 1s`,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			log := filepath.Join(t.TempDir(), "hooks.log")
+			for i := range c.hooks {
+				c.hooks[i].Env = map[string]string{"LOG": log}
+			}
+			o := &MirrorOptions{
+				Out:       ioutil.Discard,
+				ErrOut:    ioutil.Discard,
+				Dir:       "archives/oc-mirror-workspace",
+				OutputDir: "archives",
+				hooks:     c.hooks,
+			}
+			var ran bool
+			err := o.withHooks(context.Background(), v1alpha2.HookPhaseArchive, func() error {
+				ran = true
+				return c.fnErr
+			})
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, c.expRun, ran)
+			data, _ := ioutil.ReadFile(log)
+			require.Equal(t, c.expLog, string(data))
+		})
+	}
+}
+
+func TestHookEnv(t *testing.T) {
+	o := &MirrorOptions{
+		Dir:           "oc-mirror-workspace",
+		ConfigPath:    "imageset-config.yaml",
+		ToMirror:      "registry.example.com:5000",
+		UserNamespace: "mirror",
+	}
+	hook := v1alpha2.Hook{Phase: v1alpha2.HookPhasePublish, When: v1alpha2.HookAfter, Env: map[string]string{"TICKET": "CHG-1", "A": "b"}}
+	env := o.hookEnv(hook, errors.New("push failed"))
+	require.Equal(t, []string{
+		"OC_MIRROR_HOOK_PHASE=publish",
+		"OC_MIRROR_HOOK_WHEN=after",
+		"OC_MIRROR_OPERATION=mirrorToMirror",
+		"OC_MIRROR_WORKSPACE=oc-mirror-workspace",
+		"OC_MIRROR_CONFIG=imageset-config.yaml",
+		"OC_MIRROR_DESTINATION=registry.example.com:5000/mirror",
+		"OC_MIRROR_PHASE_SUCCESS=false",
+		"OC_MIRROR_PHASE_ERROR=push failed",
+		"A=b",
+		"TICKET=CHG-1",
+	}, env)
+
+	hook.When = v1alpha2.HookBefore
+	for _, e := range o.hookEnv(hook, nil) {
+		require.False(t, strings.HasPrefix(e, "OC_MIRROR_PHASE_"))
+	}
+}
+
+func TestConfigHooks(t *testing.T) {
+	cfg := v1alpha2.ImageSetConfiguration{}
+	cfg.Hooks = []v1alpha2.Hook{{Phase: v1alpha2.HookPhasePlan, When: v1alpha2.HookBefore, Command: []string{"true"}}}
+
+	o := &MirrorOptions{}
+	require.Equal(t, cfg.Hooks, o.configHooks(cfg))
+
+	o.RemoteConfig = true
+	require.Nil(t, o.configHooks(cfg))
+}
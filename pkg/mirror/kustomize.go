@@ -0,0 +1,117 @@
+package mirror
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/oc-mirror/pkg/config"
+)
+
+const (
+	kustomizationFile       = "kustomization.yaml"
+	kustomizationAPIVersion = "kustomize.config.k8s.io/v1beta1"
+	kustomizationKind       = "Kustomization"
+)
+
+// kustomization is the subset of the kustomize
+// configuration written for the results
+type kustomization struct {
+	APIVersion string   `json:"apiVersion"`
+	Kind       string   `json:"kind"`
+	Resources  []string `json:"resources,omitempty"`
+}
+
+// writeKustomization writes a kustomization.yaml to dir referencing the
+// generated manifests and release signature ConfigMaps in dir.
+// Release signatures are copied from sigDir when it is outside of dir,
+// because kustomize only loads resources under the kustomization root.
+func (o *MirrorOptions) writeKustomization(dir, sigDir string) error {
+	dstSigDir := filepath.Join(dir, config.ReleaseSignatureDir)
+	if filepath.Clean(sigDir) != filepath.Clean(dstSigDir) {
+		if err := copySignatureConfigMaps(sigDir, dstSigDir); err != nil {
+			return err
+		}
+	}
+
+	resources, err := kustomizationResources(dir)
+	if err != nil {
+		return err
+	}
+	if len(resources) == 0 {
+		o.log().Debug("No manifests generated, skipping kustomization")
+		return nil
+	}
+
+	data, err := yaml.Marshal(kustomization{
+		APIVersion: kustomizationAPIVersion,
+		Kind:       kustomizationKind,
+		Resources:  resources,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to marshal kustomization yaml: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, kustomizationFile), data, 0640); err != nil {
+		return fmt.Errorf("error writing kustomization: %v", err)
+	}
+	o.log().Infof("Wrote kustomization to %s", dir)
+	return nil
+}
+
+// kustomizationResources returns the manifests in dir and
+// the release signatures directory, relative to dir
+func kustomizationResources(dir string) ([]string, error) {
+	var resources []string
+	for _, sub := range []string{"", config.ReleaseSignatureDir} {
+		files, err := ioutil.ReadDir(filepath.Join(dir, sub))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, file := range files {
+			if file.IsDir() || file.Name() == kustomizationFile || file.Name() == configSnapshotFile {
+				continue
+			}
+			switch strings.ToLower(filepath.Ext(file.Name())) {
+			case ".yaml", ".yml", ".json":
+				resources = append(resources, filepath.ToSlash(filepath.Join(sub, file.Name())))
+			}
+		}
+	}
+	sort.Strings(resources)
+	return resources, nil
+}
+
+// copySignatureConfigMaps copies the release signature ConfigMaps in src to dst
+func copySignatureConfigMaps(src, dst string) error {
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := os.MkdirAll(dst, 0750); err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(src, file.Name()))
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dst, file.Name()), data, 0640); err != nil {
+			return err
+		}
+	}
+	return nil
+}
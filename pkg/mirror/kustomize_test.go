@@ -0,0 +1,37 @@
+package mirror
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/config"
+)
+
+func TestWriteKustomization(t *testing.T) {
+	expKustomization := `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- catalogSource-redhat-operators.yaml
+- imageContentSourcePolicy.yaml
+- release-signatures/signature-sha256-3e590f0381f73fe7.json
+`
+
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "imageContentSourcePolicy.yaml"), []byte("---\n"), 0640))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "catalogSource-redhat-operators.yaml"), []byte("---\n"), 0640))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "mapping.txt"), []byte("a=b\n"), 0640))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, config.HelmDir), 0750))
+
+	sigDir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sigDir, "signature-sha256-3e590f0381f73fe7.json"), []byte("{}"), 0640))
+
+	require.NoError(t, NewMirrorOptions().writeKustomization(dir, sigDir))
+	data, err := ioutil.ReadFile(filepath.Join(dir, kustomizationFile))
+	require.NoError(t, err)
+	require.Equal(t, expKustomization, string(data))
+	require.FileExists(t, filepath.Join(dir, config.ReleaseSignatureDir, "signature-sha256-3e590f0381f73fe7.json"))
+}
@@ -0,0 +1,109 @@
+package mirror
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	operatorv1alpha1 "github.com/openshift/api/operator/v1alpha1"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+const (
+	// legacyDir is the results subdirectory for output in
+	// the formats of oc adm catalog mirror and oc adm release mirror
+	legacyDir = "legacy"
+	// legacyICSPName is the name of the single ICSP written for legacy tooling
+	legacyICSPName = "oc-mirror"
+	// imageContentSourcesFile holds the install-config imageContentSources
+	// printed by oc adm release mirror
+	imageContentSourcesFile = "install-config-imageContentSources.yaml"
+)
+
+// writeLegacyOutput writes the image mapping, a single repository scoped
+// ImageContentSourcePolicy, and the install-config imageContentSources in
+// the formats produced by oc adm catalog mirror and oc adm release mirror
+func (o *MirrorOptions) writeLegacyOutput(mapping image.TypedImageMapping, dir string) error {
+	if len(mapping) == 0 {
+		o.log().Debug("No images mirrored, skipping legacy output")
+		return nil
+	}
+	legacyPath := filepath.Join(dir, legacyDir)
+	if err := os.MkdirAll(legacyPath, 0750); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(legacyPath, mappingFile), generateLegacyMapping(mapping), 0640); err != nil {
+		return fmt.Errorf("error writing image mapping: %v", err)
+	}
+
+	registryMapping, err := getRegistryMapping(o.log(), repositoryICSPScope, mapping)
+	if err != nil {
+		return err
+	}
+	icsps, err := buildICSPs(legacyICSPName, icspSizeLimit, 0, registryMapping, &GenericBuilder{Log: o.log()})
+	if err != nil {
+		return err
+	}
+	if err := o.WriteICSPs(legacyPath, icsps); err != nil {
+		return err
+	}
+
+	releases := image.ByCategory(mapping, v1alpha2.TypeOCPRelease, v1alpha2.TypeOCPReleaseContent)
+	if len(releases) != 0 {
+		data, err := generateImageContentSources(o.log(), releases)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(legacyPath, imageContentSourcesFile), data, 0640); err != nil {
+			return fmt.Errorf("error writing imageContentSources: %v", err)
+		}
+	}
+	o.log().Infof("Wrote legacy mapping and manifests to %s", legacyPath)
+	return nil
+}
+
+// generateLegacyMapping creates a sorted source=destination
+// mapping usable with oc image mirror -f
+func generateLegacyMapping(mapping image.TypedImageMapping) []byte {
+	lines := make([]string, 0, len(mapping))
+	for src, dst := range mapping {
+		lines = append(lines, fmt.Sprintf("%s=%s", src.Ref.Exact(), dst.Ref.Exact()))
+	}
+	sort.Strings(lines)
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// generateImageContentSources creates the install-config imageContentSources
+// stanza for the release images
+func generateImageContentSources(log logrus.FieldLogger, releases image.TypedImageMapping) ([]byte, error) {
+	registryMapping, err := getRegistryMapping(log, repositoryICSPScope, releases)
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]string, 0, len(registryMapping))
+	for source := range registryMapping {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	var imageContentSources []operatorv1alpha1.RepositoryDigestMirrors
+	for _, source := range sources {
+		imageContentSources = append(imageContentSources, operatorv1alpha1.RepositoryDigestMirrors{
+			Source:  source,
+			Mirrors: []string{registryMapping[source]},
+		})
+	}
+	data, err := yaml.Marshal(map[string]interface{}{"imageContentSources": imageContentSources})
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal imageContentSources yaml: %v", err)
+	}
+	return data, nil
+}
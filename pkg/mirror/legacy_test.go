@@ -0,0 +1,61 @@
+package mirror
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestWriteLegacyOutput(t *testing.T) {
+	expMapping := `quay.io/openshift-release-dev/ocp-release@sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8=reg.mirror.com/openshift/release-images@sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8
+registry.redhat.io/ubi8/ubi@sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8=reg.mirror.com/ubi8/ubi@sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8
+`
+	expImageContentSources := `imageContentSources:
+- mirrors:
+  - reg.mirror.com/openshift/release-images
+  source: quay.io/openshift-release-dev/ocp-release
+`
+	expICSP := `---
+apiVersion: operator.openshift.io/v1alpha1
+kind: ImageContentSourcePolicy
+metadata:
+  name: oc-mirror-0
+spec:
+  repositoryDigestMirrors:
+  - mirrors:
+    - reg.mirror.com/openshift/release-images
+    source: quay.io/openshift-release-dev/ocp-release
+  - mirrors:
+    - reg.mirror.com/ubi8/ubi
+    source: registry.redhat.io/ubi8/ubi
+`
+
+	digest := "@sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8"
+	parse := func(ref string, typ v1alpha2.ImageType) image.TypedImage {
+		img, err := image.ParseTypedImage(ref+digest, typ)
+		require.NoError(t, err)
+		return img
+	}
+	mapping := image.TypedImageMapping{
+		parse("quay.io/openshift-release-dev/ocp-release", v1alpha2.TypeOCPRelease): parse("reg.mirror.com/openshift/release-images", v1alpha2.TypeOCPRelease),
+		parse("registry.redhat.io/ubi8/ubi", v1alpha2.TypeGeneric):                  parse("reg.mirror.com/ubi8/ubi", v1alpha2.TypeGeneric),
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, NewMirrorOptions().writeLegacyOutput(mapping, dir))
+
+	for file, exp := range map[string]string{
+		mappingFile:                     expMapping,
+		imageContentSourcesFile:         expImageContentSources,
+		"imageContentSourcePolicy.yaml": expICSP,
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, legacyDir, file))
+		require.NoError(t, err)
+		require.Equal(t, exp, string(data), file)
+	}
+}
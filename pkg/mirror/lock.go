@@ -0,0 +1,11 @@
+package mirror
+
+const (
+	// WorkspaceLockFile is the file in the workspace locked by a run, since
+	// concurrent runs would corrupt the metadata and images of the workspace
+	WorkspaceLockFile = ".oc-mirror.lock"
+
+	// UploadJournalFile is the file in the workspace recording the blob
+	// upload sessions of runs, which interrupted runs leave open
+	UploadJournalFile = ".oc-mirror-uploads"
+)
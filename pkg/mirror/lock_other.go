@@ -0,0 +1,40 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package mirror
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WorkspaceLock is an exclusive lock on a workspace. The lock file
+// is left behind when the process is killed and must then be removed.
+type WorkspaceLock struct {
+	path string
+}
+
+// LockWorkspace locks the workspace, failing if another run holds the lock
+func LockWorkspace(dir string) (*WorkspaceLock, error) {
+	path := filepath.Join(dir, WorkspaceLockFile)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("workspace %s is in use by another oc-mirror run (remove %s if no run is in progress)", dir, path)
+		}
+		return nil, fmt.Errorf("error locking workspace: %v", err)
+	}
+	fmt.Fprintf(file, "%d\n", os.Getpid())
+	file.Close()
+	return &WorkspaceLock{path: path}, nil
+}
+
+// Unlock releases the lock
+func (l *WorkspaceLock) Unlock() {
+	if l == nil {
+		return
+	}
+	_ = os.Remove(l.path)
+}
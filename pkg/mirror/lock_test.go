@@ -0,0 +1,22 @@
+package mirror
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	lock, err := LockWorkspace(dir)
+	require.NoError(t, err)
+
+	_, err = LockWorkspace(dir)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is in use by another oc-mirror run")
+
+	lock.Unlock()
+	lock, err = LockWorkspace(dir)
+	require.NoError(t, err)
+	lock.Unlock()
+}
@@ -0,0 +1,58 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package mirror
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// WorkspaceLock is an exclusive lock on a workspace. The lock is released
+// by the kernel when the process exits, so interrupted runs leave no stale lock.
+type WorkspaceLock struct {
+	file *os.File
+}
+
+// LockWorkspace locks the workspace, failing if another run holds the lock
+func LockWorkspace(dir string) (*WorkspaceLock, error) {
+	path := filepath.Join(dir, WorkspaceLockFile)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("error opening workspace lock: %v", err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, workspaceLockedError(dir, path)
+		}
+		return nil, fmt.Errorf("error locking workspace: %v", err)
+	}
+	// Record the process holding the lock for the error of other runs
+	if err := file.Truncate(0); err == nil {
+		fmt.Fprintf(file, "%d\n", os.Getpid())
+	}
+	return &WorkspaceLock{file: file}, nil
+}
+
+// Unlock releases the lock
+func (l *WorkspaceLock) Unlock() {
+	if l == nil {
+		return
+	}
+	_ = syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	l.file.Close()
+}
+
+func workspaceLockedError(dir, path string) error {
+	pid, err := ioutil.ReadFile(path)
+	if err != nil || len(strings.TrimSpace(string(pid))) == 0 {
+		return fmt.Errorf("workspace %s is in use by another oc-mirror run", dir)
+	}
+	return fmt.Errorf("workspace %s is in use by another oc-mirror run (pid %s)", dir, strings.TrimSpace(string(pid)))
+}
@@ -28,7 +28,14 @@ const (
 	repositoryICSPScope = "repository"
 	namespaceICSPScope  = "namespace"
 	icspKind            = "ImageContentSourcePolicy"
-	updateServiceKind   = "UpdateService"
+	// categoryICSPGrouping generates ICSPs for each image category
+	categoryICSPGrouping = "category"
+	// registryICSPGrouping generates ICSPs for each source registry
+	registryICSPGrouping = "registry"
+	updateServiceKind    = "UpdateService"
+	// updateServiceNamespace is the namespace the OpenShift Update Service
+	// operator is installed into by default.
+	updateServiceNamespace = "openshift-update-service"
 )
 
 var icspTypeMeta = metav1.TypeMeta{
@@ -44,7 +51,11 @@ type ICSPBuilder interface {
 
 var _ ICSPBuilder = &ReleaseBuilder{}
 
-type ReleaseBuilder struct{}
+type ReleaseBuilder struct {
+	// Log receives the images left out of the mappings,
+	// the standard logger if unset
+	Log logrus.FieldLogger
+}
 
 func (b *ReleaseBuilder) New(icspName string, icspCount int) operatorv1alpha1.ImageContentSourcePolicy {
 	name := strings.Join(strings.Split(icspName, "/"), "-") + "-" + strconv.Itoa(icspCount)
@@ -63,12 +74,16 @@ func (b *ReleaseBuilder) GetMapping(_ string, mapping image.TypedImageMapping) (
 	// Scope is set to repository for release because
 	// they are mirrored as different repo names by
 	// release planner
-	return getRegistryMapping(repositoryICSPScope, mapping)
+	return getRegistryMapping(builderLog(b.Log), repositoryICSPScope, mapping)
 }
 
 var _ ICSPBuilder = &OperatorBuilder{}
 
-type OperatorBuilder struct{}
+type OperatorBuilder struct {
+	// Log receives the images left out of the mappings,
+	// the standard logger if unset
+	Log logrus.FieldLogger
+}
 
 func (b *OperatorBuilder) New(icspName string, icspCount int) operatorv1alpha1.ImageContentSourcePolicy {
 	name := strings.Join(strings.Split(icspName, "/"), "-") + "-" + strconv.Itoa(icspCount)
@@ -85,12 +100,16 @@ func (b *OperatorBuilder) New(icspName string, icspCount int) operatorv1alpha1.I
 }
 
 func (b *OperatorBuilder) GetMapping(icspScope string, mapping image.TypedImageMapping) (map[string]string, error) {
-	return getRegistryMapping(icspScope, mapping)
+	return getRegistryMapping(builderLog(b.Log), icspScope, mapping)
 }
 
 var _ ICSPBuilder = &GenericBuilder{}
 
-type GenericBuilder struct{}
+type GenericBuilder struct {
+	// Log receives the images left out of the mappings,
+	// the standard logger if unset
+	Log logrus.FieldLogger
+}
 
 func (b *GenericBuilder) New(icspName string, icspCount int) operatorv1alpha1.ImageContentSourcePolicy {
 	name := strings.Join(strings.Split(icspName, "/"), "-") + "-" + strconv.Itoa(icspCount)
@@ -106,22 +125,44 @@ func (b *GenericBuilder) New(icspName string, icspCount int) operatorv1alpha1.Im
 }
 
 func (b *GenericBuilder) GetMapping(icspScope string, mapping image.TypedImageMapping) (map[string]string, error) {
-	return getRegistryMapping(icspScope, mapping)
+	return getRegistryMapping(builderLog(b.Log), icspScope, mapping)
 }
 
 // GenerateICSP will generate ImageContentSourcePolicy objects based on image mapping and an ICSPBuilder
 func GenerateICSP(icspName, icspScope string, byteLimit int, mapping image.TypedImageMapping, builder ICSPBuilder) (icsps []operatorv1alpha1.ImageContentSourcePolicy, err error) {
+	return GenerateICSPWithLimits(icspName, icspScope, byteLimit, 0, mapping, builder)
+}
+
+// GenerateICSPWithLimits will generate ImageContentSourcePolicy objects based on image mapping and an ICSPBuilder.
+// Each ImageContentSourcePolicy is limited to byteLimit bytes and, if mirrorLimit is greater than zero,
+// mirrorLimit repository digest mirrors.
+func GenerateICSPWithLimits(icspName, icspScope string, byteLimit, mirrorLimit int, mapping image.TypedImageMapping, builder ICSPBuilder) (icsps []operatorv1alpha1.ImageContentSourcePolicy, err error) {
 	registryMapping, err := builder.GetMapping(icspScope, mapping)
 	if err != nil {
 		return nil, err
 	}
+	return buildICSPs(icspName, byteLimit, mirrorLimit, registryMapping, builder)
+}
+
+// buildICSPs splits the registry mapping into ImageContentSourcePolicy objects within the limits
+func buildICSPs(icspName string, byteLimit, mirrorLimit int, registryMapping map[string]string, builder ICSPBuilder) (icsps []operatorv1alpha1.ImageContentSourcePolicy, err error) {
+	// Stable ICSP content
+	keys := make([]string, 0, len(registryMapping))
+	for key := range registryMapping {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
 
-	for len(registryMapping) != 0 {
+	var icspCount int
+	for len(keys) != 0 {
 
-		var icspCount int
 		icsp := builder.New(icspName, icspCount)
 
-		for key := range registryMapping {
+		var added int
+		for _, key := range keys {
+			if mirrorLimit > 0 && added == mirrorLimit {
+				break
+			}
 			icsp.Spec.RepositoryDigestMirrors = append(icsp.Spec.RepositoryDigestMirrors, operatorv1alpha1.RepositoryDigestMirrors{
 				Source:  key,
 				Mirrors: []string{registryMapping[key]},
@@ -132,45 +173,107 @@ func GenerateICSP(icspName, icspScope string, byteLimit int, mapping image.Typed
 				return nil, fmt.Errorf("unable to marshal ImageContentSourcePolicy yaml: %v", err)
 			}
 			if len(y) > byteLimit {
-				if lenMirrors := len(icsp.Spec.RepositoryDigestMirrors); lenMirrors > 0 {
-					if lenMirrors == 1 {
-						return nil, fmt.Errorf("repository digest mirror for %q cannot fit into any ICSP with byte limit %d", key, byteLimit)
-					}
-					icsp.Spec.RepositoryDigestMirrors = icsp.Spec.RepositoryDigestMirrors[:lenMirrors-1]
+				lenMirrors := len(icsp.Spec.RepositoryDigestMirrors)
+				if lenMirrors == 1 {
+					return nil, fmt.Errorf("repository digest mirror for %q cannot fit into any ICSP with byte limit %d", key, byteLimit)
 				}
-				icspCount++
+				icsp.Spec.RepositoryDigestMirrors = icsp.Spec.RepositoryDigestMirrors[:lenMirrors-1]
 				break
 			}
-			delete(registryMapping, key)
+			added++
 		}
+		keys = keys[added:]
+		icspCount++
+
+		icsps = append(icsps, icsp)
+	}
 
-		if len(icsp.Spec.RepositoryDigestMirrors) != 0 {
-			icsps = append(icsps, icsp)
+	return icsps, nil
+}
+
+// icspSource is a set of images sharing an ICSPBuilder
+type icspSource struct {
+	name    string
+	mapping image.TypedImageMapping
+	builder ICSPBuilder
+}
+
+// generateICSPsByRegistry will generate ImageContentSourcePolicy objects for each source registry
+// across the image sources. Each source determines the scope of its own mirrors.
+func generateICSPsByRegistry(namePrefix, icspScope string, byteLimit, mirrorLimit int, sources []icspSource) ([]operatorv1alpha1.ImageContentSourcePolicy, error) {
+	byRegistry := map[string]map[string]string{}
+	for _, source := range sources {
+		registryMapping, err := source.builder.GetMapping(icspScope, source.mapping)
+		if err != nil {
+			return nil, err
+		}
+		for src, dst := range registryMapping {
+			registry := strings.SplitN(src, "/", 2)[0]
+			if _, ok := byRegistry[registry]; !ok {
+				byRegistry[registry] = map[string]string{}
+			}
+			byRegistry[registry][src] = dst
 		}
 	}
 
+	// Sort the registries so the generated manifests are stable across runs
+	registries := make([]string, 0, len(byRegistry))
+	for registry := range byRegistry {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+
+	var icsps []operatorv1alpha1.ImageContentSourcePolicy
+	for _, registry := range registries {
+		// Registry ports are not valid in object names
+		name := namePrefix + strings.ReplaceAll(registry, ":", "-")
+		registryICSPs, err := buildICSPs(name, byteLimit, mirrorLimit, byRegistry[registry], &GenericBuilder{})
+		if err != nil {
+			return nil, err
+		}
+		icsps = append(icsps, registryICSPs...)
+	}
 	return icsps, nil
 }
 
-func aggregateICSPs(icsps [][]byte) []byte {
+func aggregateManifests(manifests [][]byte) []byte {
 	aggregation := []byte{}
-	for _, icsp := range icsps {
+	for _, manifest := range manifests {
 		aggregation = append(aggregation, []byte("---\n")...)
-		aggregation = append(aggregation, icsp...)
+		aggregation = append(aggregation, manifest...)
 	}
 	return aggregation
 }
 
-func getRegistryMapping(icspScope string, mapping image.TypedImageMapping) (map[string]string, error) {
+// builderLog returns the logger of an ICSP builder
+func builderLog(log logrus.FieldLogger) logrus.FieldLogger {
+	if log == nil {
+		return logrus.StandardLogger()
+	}
+	return log
+}
+
+func getRegistryMapping(log logrus.FieldLogger, icspScope string, mapping image.TypedImageMapping) (map[string]string, error) {
 	registryMapping := map[string]string{}
 	for k, v := range mapping {
 		if len(v.Ref.ID) == 0 {
-			logrus.Warnf("no digest mapping available for %s, skip writing to ImageContentSourcePolicy", k)
+			log.Warnf("no digest mapping available for %s, skip writing to ImageContentSourcePolicy", k)
 			continue
 		}
 		switch {
 		case icspScope == registryICSPScope:
-			registryMapping[k.Ref.Registry] = v.Ref.Registry
+			mirror, ok := registryScopeMirror(k.Ref, v.Ref)
+			if !ok {
+				// The mirrored repository path does not end with the source
+				// repository path, so a registry level mirror would not resolve
+				log.Debugf("cannot mirror %s at registry scope, using repository scope", k.Ref.Exact())
+				registryMapping[k.Ref.AsRepository().String()] = v.Ref.AsRepository().String()
+				continue
+			}
+			if existing, found := registryMapping[k.Ref.Registry]; found && existing != mirror {
+				return registryMapping, fmt.Errorf("registry %s is mirrored to both %s and %s, use a narrower ICSP scope", k.Ref.Registry, existing, mirror)
+			}
+			registryMapping[k.Ref.Registry] = mirror
 		case icspScope == namespaceICSPScope && k.Ref.Namespace == "":
 			fallthrough
 		case icspScope == repositoryICSPScope:
@@ -187,6 +290,17 @@ func getRegistryMapping(icspScope string, mapping image.TypedImageMapping) (map[
 	return registryMapping, nil
 }
 
+// registryScopeMirror returns the mirror location of the source registry.
+// Mirrored repositories may be nested under a user namespace, which is kept.
+func registryScopeMirror(source, dest reference.DockerImageReference) (string, bool) {
+	sourcePath := strings.TrimPrefix(source.AsRepository().String(), source.Registry+"/")
+	destRepo := dest.AsRepository().String()
+	if !strings.HasSuffix(destRepo, "/"+sourcePath) {
+		return "", false
+	}
+	return strings.TrimSuffix(destRepo, "/"+sourcePath), true
+}
+
 func generateCatalogSource(name string, dest reference.DockerImageReference) ([]byte, error) {
 	// Prefer tag over digest for automatic updates.
 	if dest.Tag != "" {
@@ -221,7 +335,7 @@ type updateService struct {
 	Spec              cincinnativ1.UpdateServiceSpec `json:"spec"`
 }
 
-func generateUpdateService(name string, releaseRepo, graphDataImage reference.DockerImageReference) ([]byte, error) {
+func generateUpdateService(name, namespace string, releaseRepo, graphDataImage reference.DockerImageReference) ([]byte, error) {
 	var updateServiceMeta = metav1.TypeMeta{
 		APIVersion: cincinnativ1.GroupVersion.String(),
 		Kind:       updateServiceKind,
@@ -230,7 +344,8 @@ func generateUpdateService(name string, releaseRepo, graphDataImage reference.Do
 	obj := updateService{
 		TypeMeta: updateServiceMeta,
 		ObjectMeta: metav1.ObjectMeta{
-			Name: name,
+			Name:      name,
+			Namespace: namespace,
 		},
 		Spec: cincinnativ1.UpdateServiceSpec{
 			Replicas:       2,
@@ -248,11 +363,27 @@ func generateUpdateService(name string, releaseRepo, graphDataImage reference.Do
 	return cs, nil
 }
 
+func generateNamespace(name string) ([]byte, error) {
+	obj := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata": map[string]interface{}{
+			"name": name,
+		},
+	}
+	ns, err := yaml.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal Namespace yaml: %v", err)
+	}
+
+	return ns, nil
+}
+
 // WriteICSPs will write provided ImageContentSourcePolicy objects to disk
-func WriteICSPs(dir string, icsps []operatorv1alpha1.ImageContentSourcePolicy) error {
+func (o *MirrorOptions) WriteICSPs(dir string, icsps []operatorv1alpha1.ImageContentSourcePolicy) error {
 
 	if len(icsps) == 0 {
-		logrus.Debug("No ICSPs generated to write")
+		o.log().Debug("No ICSPs generated to write")
 		return nil
 	}
 
@@ -275,19 +406,19 @@ func WriteICSPs(dir string, icsps []operatorv1alpha1.ImageContentSourcePolicy) e
 		}
 	}
 
-	if err := ioutil.WriteFile(filepath.Join(dir, "imageContentSourcePolicy.yaml"), aggregateICSPs(icspBytes), os.ModePerm); err != nil {
+	if err := ioutil.WriteFile(filepath.Join(dir, "imageContentSourcePolicy.yaml"), aggregateManifests(icspBytes), os.ModePerm); err != nil {
 		return fmt.Errorf("error writing ImageContentSourcePolicy: %v", err)
 	}
 
-	logrus.Infof("Wrote ICSP manifests to %s", dir)
+	o.log().Infof("Wrote ICSP manifests to %s", dir)
 
 	return nil
 }
 
 // WriteCatalogSource will generate a CatalogSource object and write it to disk
-func WriteCatalogSource(mapping image.TypedImageMapping, dir string) error {
+func (o *MirrorOptions) WriteCatalogSource(mapping image.TypedImageMapping, dir string) error {
 	if len(mapping) == 0 {
-		logrus.Debug("No catalogs found in mapping")
+		o.log().Debug("No catalogs found in mapping")
 		return nil
 	}
 
@@ -301,19 +432,28 @@ func WriteCatalogSource(mapping image.TypedImageMapping, dir string) error {
 			return fmt.Errorf("error writing CatalogSource: %v", err)
 		}
 	}
-	logrus.Infof("Wrote CatalogSource manifests to %s", dir)
+	o.log().Infof("Wrote CatalogSource manifests to %s", dir)
 	return nil
 }
 
 // WriteUpdateService will generate an UpdateService object and write it to disk
-func WriteUpdateService(release, graph image.TypedImage, dir string) error {
-	updateService, err := generateUpdateService("update-service-oc-mirror", release.Ref, graph.Ref)
+// along with the Namespace it is created in
+func (o *MirrorOptions) WriteUpdateService(release, graph image.TypedImage, dir string) error {
+	namespace, err := generateNamespace(updateServiceNamespace)
+	if err != nil {
+		return err
+	}
+	updateService, err := generateUpdateService("update-service-oc-mirror", updateServiceNamespace, release.Ref, graph.Ref)
 	if err != nil {
 		return err
 	}
-	if err := ioutil.WriteFile(filepath.Join(dir, "updateService.yaml"), updateService, os.ModePerm); err != nil {
+	if err := ioutil.WriteFile(filepath.Join(dir, "updateService.yaml"), aggregateManifests([][]byte{namespace, updateService}), os.ModePerm); err != nil {
 		return fmt.Errorf("error writing UpdateService: %v", err)
 	}
-	logrus.Infof("Wrote UpdateService manifests to %s", dir)
+	o.log().Infof("Wrote UpdateService manifests to %s", dir)
+	// The route to the policy engine is created by the operator, so it
+	// can only be discovered after the UpdateService is applied.
+	o.log().Infof("After applying, retrieve the graph URL with: oc get updateservice %s -n %s -o jsonpath='{.status.policyEngineURI}/api/upgrades_info/v1/graph'",
+		"update-service-oc-mirror", updateServiceNamespace)
 	return nil
 }
@@ -6,6 +6,7 @@ import (
 	operatorv1alpha1 "github.com/openshift/api/operator/v1alpha1"
 	"github.com/openshift/library-go/pkg/image/reference"
 	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -402,6 +403,7 @@ func TestGenerateUpdateService(t *testing.T) {
 kind: UpdateService
 metadata:
   name: test
+  namespace: test-ns
 spec:
   graphDataImage: registry.com/graph:latest
   releases: registry.com/releases
@@ -412,7 +414,129 @@ spec:
 	require.NoError(t, err)
 	graph, err := reference.Parse("registry.com/graph:latest")
 	require.NoError(t, err)
-	data, err := generateUpdateService("test", release, graph)
+	data, err := generateUpdateService("test", "test-ns", release, graph)
 	require.NoError(t, err)
 	require.Equal(t, expCfg, string(data))
 }
+
+func TestGenerateICSPWithLimits(t *testing.T) {
+	mapping := image.TypedImageMapping{}
+	for _, name := range []string{"image1", "image2", "image3"} {
+		src, err := image.ParseTypedImage("some.registry/ns-"+name+"/"+name+"@sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8", v1alpha2.TypeGeneric)
+		require.NoError(t, err)
+		dst, err := image.ParseTypedImage("disconn.registry/ns-"+name+"/"+name+"@sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8", v1alpha2.TypeGeneric)
+		require.NoError(t, err)
+		mapping[src] = dst
+	}
+
+	icsps, err := GenerateICSPWithLimits("test", namespaceICSPScope, icspSizeLimit, 2, mapping, &GenericBuilder{})
+	require.NoError(t, err)
+	require.Len(t, icsps, 2)
+	require.Equal(t, "test-0", icsps[0].Name)
+	require.Equal(t, []operatorv1alpha1.RepositoryDigestMirrors{
+		{Source: "some.registry/ns-image1", Mirrors: []string{"disconn.registry/ns-image1"}},
+		{Source: "some.registry/ns-image2", Mirrors: []string{"disconn.registry/ns-image2"}},
+	}, icsps[0].Spec.RepositoryDigestMirrors)
+	require.Equal(t, "test-1", icsps[1].Name)
+	require.Equal(t, []operatorv1alpha1.RepositoryDigestMirrors{
+		{Source: "some.registry/ns-image3", Mirrors: []string{"disconn.registry/ns-image3"}},
+	}, icsps[1].Spec.RepositoryDigestMirrors)
+}
+
+func TestGenerateICSPsByRegistry(t *testing.T) {
+	parse := func(ref string, typ v1alpha2.ImageType) image.TypedImage {
+		img, err := image.ParseTypedImage(ref, typ)
+		require.NoError(t, err)
+		return img
+	}
+	digest := "@sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8"
+	sources := []icspSource{
+		{
+			name: "generic",
+			mapping: image.TypedImageMapping{
+				parse("registry.one:5000/ns/image"+digest, v1alpha2.TypeGeneric): parse("disconn.registry/ns/image"+digest, v1alpha2.TypeGeneric),
+				parse("quay.io/ns/image"+digest, v1alpha2.TypeGeneric):           parse("disconn.registry/ns/image"+digest, v1alpha2.TypeGeneric),
+			},
+			builder: &GenericBuilder{},
+		},
+		{
+			name: "operator",
+			mapping: image.TypedImageMapping{
+				parse("registry.one:5000/ops/bundle"+digest, v1alpha2.TypeOperatorBundle): parse("disconn.registry/ops/bundle"+digest, v1alpha2.TypeOperatorBundle),
+				parse("registry.two/ops/bundle"+digest, v1alpha2.TypeOperatorBundle):      parse("disconn.registry/ops/bundle"+digest, v1alpha2.TypeOperatorBundle),
+			},
+			builder: &OperatorBuilder{},
+		},
+	}
+
+	icsps, err := generateICSPsByRegistry("mirror-", namespaceICSPScope, icspSizeLimit, 0, sources)
+	require.NoError(t, err)
+	// ICSPs are ordered by registry
+	var names []string
+	for _, icsp := range icsps {
+		names = append(names, icsp.Name)
+	}
+	require.Equal(t, []string{"mirror-quay.io-0", "mirror-registry.one-5000-0", "mirror-registry.two-0"}, names)
+	require.Equal(t, []operatorv1alpha1.RepositoryDigestMirrors{
+		{Source: "quay.io/ns", Mirrors: []string{"disconn.registry/ns"}},
+	}, icsps[0].Spec.RepositoryDigestMirrors)
+	require.Equal(t, []operatorv1alpha1.RepositoryDigestMirrors{
+		{Source: "registry.one:5000/ns", Mirrors: []string{"disconn.registry/ns"}},
+		{Source: "registry.one:5000/ops", Mirrors: []string{"disconn.registry/ops"}},
+	}, icsps[1].Spec.RepositoryDigestMirrors)
+	require.Equal(t, []operatorv1alpha1.RepositoryDigestMirrors{
+		{Source: "registry.two/ops", Mirrors: []string{"disconn.registry/ops"}},
+	}, icsps[2].Spec.RepositoryDigestMirrors)
+}
+
+func TestGetRegistryMappingRegistryScope(t *testing.T) {
+	digest := "@sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8"
+	parse := func(ref string) image.TypedImage {
+		img, err := image.ParseTypedImage(ref+digest, v1alpha2.TypeGeneric)
+		require.NoError(t, err)
+		return img
+	}
+
+	tests := []struct {
+		name     string
+		mapping  image.TypedImageMapping
+		exp      map[string]string
+		expError string
+	}{
+		{
+			name: "Valid/UserNamespace",
+			mapping: image.TypedImageMapping{
+				parse("quay.io/ns1/image"): parse("disconn.registry/user/ns1/image"),
+				parse("quay.io/ns2/image"): parse("disconn.registry/user/ns2/image"),
+			},
+			exp: map[string]string{"quay.io": "disconn.registry/user"},
+		},
+		{
+			name: "Valid/RenamedRepository",
+			mapping: image.TypedImageMapping{
+				parse("quay.io/ns1/image"): parse("disconn.registry/user/other"),
+			},
+			exp: map[string]string{"quay.io/ns1/image": "disconn.registry/user/other"},
+		},
+		{
+			name: "Invalid/Conflict",
+			mapping: image.TypedImageMapping{
+				parse("quay.io/ns1/image"): parse("disconn.registry/user1/ns1/image"),
+				parse("quay.io/ns2/image"): parse("disconn.registry/user2/ns2/image"),
+			},
+			expError: "use a narrower ICSP scope",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mapping, err := getRegistryMapping(logrus.New(), registryICSPScope, test.mapping)
+			if test.expError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), test.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.exp, mapping)
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+// metricsNamespace prefixes the names of the run metrics
+const metricsNamespace = "oc_mirror"
+
+// runMetrics are the Prometheus metrics of a run.
+// The methods of a nil runMetrics are no-ops.
+type runMetrics struct {
+	registry      *prometheus.Registry
+	phaseDuration *prometheus.GaugeVec
+	images        *prometheus.GaugeVec
+	failures      prometheus.Gauge
+	runDuration   prometheus.Gauge
+	runSuccess    prometheus.Gauge
+	lastRun       prometheus.Gauge
+
+	mu     sync.Mutex
+	phases map[string]time.Duration
+	server *http.Server
+	addr   string
+	log    logrus.FieldLogger
+}
+
+// newRunMetrics returns the metrics of a run of the operation.
+// Transfer metrics are read from the transfer statistics of the options.
+func (o *MirrorOptions) newRunMetrics(operation v1alpha2.Operation) *runMetrics {
+	if o.transfer == nil {
+		o.transfer = &transferStats{}
+	}
+	labels := prometheus.Labels{"operation": string(operation)}
+	m := &runMetrics{
+		log:      o.log(),
+		registry: prometheus.NewRegistry(),
+		phaseDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			Name:        "phase_duration_seconds",
+			Help:        "Duration of each completed phase of the run.",
+			ConstLabels: labels,
+		}, []string{"phase"}),
+		images: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			Name:        "images",
+			Help:        "Images processed by the run by image type and result.",
+			ConstLabels: labels,
+		}, []string{"type", "result"}),
+		failures: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			Name:        "failures",
+			Help:        "Errors encountered during the run.",
+			ConstLabels: labels,
+		}),
+		runDuration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			Name:        "run_duration_seconds",
+			Help:        "Duration of the run.",
+			ConstLabels: labels,
+		}),
+		runSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			Name:        "run_success",
+			Help:        "1 if the run completed without errors, 0 otherwise.",
+			ConstLabels: labels,
+		}),
+		lastRun: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			Name:        "run_completion_timestamp_seconds",
+			Help:        "Unix time the run completed.",
+			ConstLabels: labels,
+		}),
+		phases: map[string]time.Duration{},
+	}
+
+	stats := o.transfer
+	transfer := func(read func() float64) func() float64 {
+		return func() float64 {
+			stats.mu.Lock()
+			defer stats.mu.Unlock()
+			return read()
+		}
+	}
+	m.registry.MustRegister(
+		m.phaseDuration, m.images, m.failures, m.runDuration, m.runSuccess, m.lastRun,
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			Name:        "transferred_bytes_total",
+			Help:        "Size of the blobs copied by the run.",
+			ConstLabels: labels,
+		}, transfer(func() float64 { return float64(stats.bytes) })),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			Name:        "copied_blobs_total",
+			Help:        "Blobs copied by the run.",
+			ConstLabels: labels,
+		}, transfer(func() float64 { return float64(stats.blobs) })),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			Name:        "mounted_blobs_total",
+			Help:        "Blobs mounted from other repositories of the destination by the run.",
+			ConstLabels: labels,
+		}, transfer(func() float64 { return float64(stats.mounted) })),
+	)
+	return m
+}
+
+// observePhase adds the duration of a completed phase
+func (m *runMetrics) observePhase(phase string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.phases[phase] += d
+	m.phaseDuration.WithLabelValues(phase).Set(m.phases[phase].Seconds())
+}
+
+// complete sets the metrics of the outcome of the run
+func (m *runMetrics) complete(results *v1alpha2.Results, mapping image.TypedImageMapping, stats *transferStats) {
+	if m == nil {
+		return
+	}
+	stats.mu.Lock()
+	summaries := summarizeImages(mapping, stats.skipped, results.Failures)
+	stats.mu.Unlock()
+	for category, s := range summaries {
+		m.images.WithLabelValues(category, "mirrored").Set(float64(s.total - s.skipped - s.failed))
+		m.images.WithLabelValues(category, "skipped").Set(float64(s.skipped))
+		m.images.WithLabelValues(category, "failed").Set(float64(s.failed))
+	}
+	m.failures.Set(float64(len(results.Failures)))
+	m.runDuration.Set(results.DurationSeconds)
+	if results.Success {
+		m.runSuccess.Set(1)
+	} else {
+		m.runSuccess.Set(0)
+	}
+	m.lastRun.Set(float64(results.EndTime.Unix()))
+}
+
+// writeTextfile writes the metrics in the Prometheus text format to path
+// for the node_exporter textfile collector. The file is replaced atomically
+// so the collector never reads a partially written file.
+func (m *runMetrics) writeTextfile(path string) error {
+	if m == nil {
+		return nil
+	}
+	families, err := m.registry.Gather()
+	if err != nil {
+		return fmt.Errorf("error gathering metrics: %v", err)
+	}
+	var buf bytes.Buffer
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(&buf, family); err != nil {
+			return fmt.Errorf("error encoding metrics: %v", err)
+		}
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("error writing metrics: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing metrics: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing metrics: %v", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error writing metrics: %v", err)
+	}
+	m.log.Infof("Wrote run metrics to %s", path)
+	return nil
+}
+
+// serve serves the metrics at /metrics on addr until shutdown
+func (m *runMetrics) serve(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("error listening for metrics on %s: %v", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	m.server = &http.Server{Handler: mux}
+	m.addr = listener.Addr().String()
+	go func() {
+		if err := m.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			m.log.Errorf("error serving metrics: %v", err)
+		}
+	}()
+	m.log.Infof("Serving run metrics at http://%s/metrics", m.addr)
+	return nil
+}
+
+// shutdown stops serving the metrics after linger, so
+// the final values of the run can still be scraped
+func (m *runMetrics) shutdown(linger time.Duration) {
+	if m == nil || m.server == nil {
+		return
+	}
+	if linger > 0 {
+		m.log.Infof("Serving final run metrics for %s", linger)
+		time.Sleep(linger)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.server.Shutdown(ctx); err != nil {
+		m.log.Errorf("error stopping metrics server: %v", err)
+	}
+}
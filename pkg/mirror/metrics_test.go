@@ -0,0 +1,76 @@
+package mirror
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestRunMetrics(t *testing.T) {
+	parse := func(ref string, typ v1alpha2.ImageType) image.TypedImage {
+		img, err := image.ParseTypedImage(ref, typ)
+		require.NoError(t, err)
+		return img
+	}
+	mapping := image.TypedImageMapping{
+		parse("registry.redhat.io/ubi8/ubi:latest", v1alpha2.TypeGeneric):       parse("reg.mirror.com/ubi8/ubi:latest", v1alpha2.TypeGeneric),
+		parse("registry.redhat.io/ubi8/ubi-micro:latest", v1alpha2.TypeGeneric): parse("reg.mirror.com/ubi8/ubi-micro:latest", v1alpha2.TypeGeneric),
+	}
+
+	opts := &MirrorOptions{}
+	metrics := opts.newRunMetrics(v1alpha2.OperationMirrorToMirror)
+	require.NoError(t, metrics.serve("127.0.0.1:0"))
+	defer metrics.shutdown(0)
+
+	opts.transfer.observe("uploading: reg.mirror.com/ubi8/ubi sha256:9e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8 2KiB")
+	opts.transfer.observe("warning: Image registry.redhat.io/ubi8/ubi-micro:latest does not exist and will not be mirrored")
+	metrics.observePhase(phaseMirror, 2*time.Second)
+	metrics.observePhase(phaseMirror, time.Second)
+
+	results := newResults(v1alpha2.OperationMirrorToMirror)
+	results.Success = true
+	results.DurationSeconds = 4
+	metrics.complete(results, mapping, opts.transfer)
+
+	expLines := []string{
+		`oc_mirror_copied_blobs_total{operation="mirrorToMirror"} 1`,
+		`oc_mirror_failures{operation="mirrorToMirror"} 0`,
+		`oc_mirror_images{operation="mirrorToMirror",result="mirrored",type="generic"} 1`,
+		`oc_mirror_images{operation="mirrorToMirror",result="skipped",type="generic"} 1`,
+		`oc_mirror_phase_duration_seconds{operation="mirrorToMirror",phase="mirror"} 3`,
+		`oc_mirror_run_duration_seconds{operation="mirrorToMirror"} 4`,
+		`oc_mirror_run_success{operation="mirrorToMirror"} 1`,
+		`oc_mirror_transferred_bytes_total{operation="mirrorToMirror"} 2048`,
+	}
+
+	path := filepath.Join(t.TempDir(), "oc-mirror.prom")
+	require.NoError(t, metrics.writeTextfile(path))
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	for _, line := range expLines {
+		require.Contains(t, string(data), line)
+	}
+
+	resp, err := http.Get("http://" + metrics.addr + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	for _, line := range expLines {
+		require.Contains(t, string(body), line)
+	}
+
+	// Nil metrics are a no-op
+	var nilMetrics *runMetrics
+	nilMetrics.observePhase(phaseMirror, time.Second)
+	require.NoError(t, nilMetrics.writeTextfile(path))
+	nilMetrics.shutdown(0)
+}
@@ -0,0 +1,1113 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	operatorv1alpha1 "github.com/openshift/api/operator/v1alpha1"
+	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	imagemanifest "github.com/openshift/oc/pkg/cli/image/manifest"
+	"github.com/openshift/oc/pkg/cli/image/mirror"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/bundle"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/exitcode"
+	"github.com/openshift/oc-mirror/pkg/image"
+	"github.com/openshift/oc-mirror/pkg/metadata"
+	"github.com/openshift/oc-mirror/pkg/metadata/storage"
+)
+
+// CompleteDestination sets the output directory, or the mirror registry
+// and namespace, of a file:// or docker:// destination
+func (o *MirrorOptions) CompleteDestination(destination string) error {
+	splitIdx := strings.Index(destination, "://")
+	if splitIdx == -1 {
+		return fmt.Errorf("no scheme delimiter in destination argument")
+	}
+	typStr, ref := destination[:splitIdx], destination[splitIdx+3:]
+
+	switch typStr {
+	case "file":
+		ref = filepath.Clean(ref)
+		if ref == "" {
+			ref = "."
+		}
+		o.OutputDir = ref
+		// If the destination is on disk, made the output dir the
+		// parent dir for the workspace
+		o.Dir = filepath.Join(o.OutputDir, o.Dir)
+	case "docker":
+		mirror, err := imagesource.ParseReference(ref)
+		if err != nil {
+			return err
+		}
+		o.ToMirror = mirror.Ref.Registry
+		o.UserNamespace = mirror.Ref.AsRepository().RepositoryName()
+		if mirror.Ref.ID != "" || mirror.Ref.Tag != "" {
+			return fmt.Errorf("destination registry must consist of registry host and namespace(s) only")
+		}
+	default:
+		return fmt.Errorf("unknown destination scheme %q", typStr)
+	}
+
+	if len(o.FilterOptions) == 0 {
+		o.FilterOptions = []string{"amd64"}
+	}
+
+	return nil
+}
+
+// ConfigureRegistries applies the registry configuration
+// of the imageset configuration to the shared registry clients,
+// with the registries of --insecure-registry marked insecure.
+// It is the only part of the configuration used when publishing.
+// Registries are configured before Validate connects to the destination.
+func (o *MirrorOptions) ConfigureRegistries() error {
+	var registries []v1alpha2.Registry
+	if len(o.ConfigPath) != 0 {
+		cfg, err := config.ReadConfig(o.ConfigPath)
+		if err != nil {
+			return err
+		}
+		registries = cfg.Registries
+	}
+	registries = image.MarkInsecure(registries, o.InsecureRegistries)
+	if o.SourceSkipTLS || o.SourcePlainHTTP || o.DestSkipTLS || o.DestPlainHTTP {
+		var allowed bool
+		for _, reg := range registries {
+			allowed = allowed || reg.Insecure
+		}
+		if !allowed {
+			o.log().Warn("insecure options do not apply to any registry, mark registries insecure in the configuration or with --insecure-registry")
+		}
+	}
+	if err := o.Clients().SetRegistries(registries); err != nil {
+		return err
+	}
+	o.trimDestinationPathPrefix()
+	return nil
+}
+
+// trimDestinationPathPrefix removes the path prefix of the registry API
+// of the mirror registry from the namespace of the destination
+func (o *MirrorOptions) trimDestinationPathPrefix() {
+	if len(o.ToMirror) == 0 {
+		return
+	}
+	namespace := o.Clients().TrimPathPrefix(o.ToMirror, o.UserNamespace)
+	if namespace != o.UserNamespace {
+		o.log().Debugf("Using namespace %q of %s without the path prefix of its registry API", namespace, o.ToMirror)
+		o.UserNamespace = namespace
+	}
+}
+
+func (o *MirrorOptions) Validate() error {
+	switch {
+	case len(o.From) > 0 && len(o.ToMirror) == 0:
+		return fmt.Errorf("must specify a registry destination")
+	case len(o.OutputDir) > 0 && len(o.ConfigPath) == 0:
+		return fmt.Errorf("must specify a configuration file with --config")
+	case len(o.ToMirror) > 0 && len(o.ConfigPath) == 0 && len(o.From) == 0:
+		return fmt.Errorf("must specify --config or --from with registry destination")
+	}
+	return o.ValidateOptions()
+}
+
+// ValidateOptions validates the options of a run
+// besides its configuration and source
+func (o *MirrorOptions) ValidateOptions() error {
+	var destInsecure bool
+	if o.DestPlainHTTP || o.DestSkipTLS {
+		destInsecure = true
+	}
+
+	// Attempt to login to registry
+	// FIXME(jpower432): CheckPushPermissions is slated for deprecation
+	// must replace with its replacement
+	if len(o.ToMirror) > 0 {
+		o.log().Infof("Checking push permissions for %s", o.ToMirror)
+		ref := path.Join(o.ToMirror, o.UserNamespace, "oc-mirror")
+		o.log().Debugf("Using image %s to check permissions", ref)
+		imgRef, err := name.ParseReference(ref, getNameOpts(destInsecure)...)
+		if err != nil {
+			return err
+		}
+		if err := remote.CheckPushPermission(imgRef, o.Clients().Keychain(), o.Clients().Transport(destInsecure)); err != nil {
+			return fmt.Errorf("error checking push permissions for %s: %v", o.ToMirror, err)
+		}
+	}
+
+	if len(o.From) > 0 {
+		if _, err := os.Stat(o.From); err != nil {
+			return err
+		}
+	}
+
+	switch o.ICSPGrouping {
+	case "", categoryICSPGrouping, registryICSPGrouping:
+	default:
+		return fmt.Errorf("ICSP grouping %q is not supported", o.ICSPGrouping)
+	}
+	switch o.ICSPScope {
+	case "", registryICSPScope, namespaceICSPScope, repositoryICSPScope:
+	default:
+		return fmt.Errorf("ICSP scope %q is not supported", o.ICSPScope)
+	}
+	if _, err := parseClusterOverlays(o.ClusterOverlays); err != nil {
+		return err
+	}
+	if _, err := parseWebhooks(o.Webhooks); err != nil {
+		return err
+	}
+	if _, err := parseRunLogMaxSize(o.RunLogMaxSize); err != nil {
+		return err
+	}
+	if o.ResultsDirTemplate != "" {
+		if _, err := parseResultsDirTemplate(o.ResultsDirTemplate); err != nil {
+			return err
+		}
+	}
+	switch o.QuotaCheck {
+	case "", quotaCheckWarn, quotaCheckFail, quotaCheckSkip:
+	default:
+		return fmt.Errorf("quota check %q is not supported: must be %s, %s, or %s", o.QuotaCheck, quotaCheckWarn, quotaCheckFail, quotaCheckSkip)
+	}
+	if o.RunLogBackups < 0 {
+		return fmt.Errorf("--run-log-backups must not be negative")
+	}
+	if o.MaxConcurrentDownloads < 0 {
+		return fmt.Errorf("--max-concurrent-downloads must not be negative")
+	}
+	if o.MaxConcurrentDownloads > maxConcurrentDownloads {
+		return fmt.Errorf("--max-concurrent-downloads must be at most %d", maxConcurrentDownloads)
+	}
+	if _, err := parseCacheMaxSize(o.CacheMaxSize); err != nil {
+		return err
+	}
+	if o.CacheDir != "" && len(o.ToMirror) > 0 {
+		return fmt.Errorf("--cache-dir is only supported when mirroring to disk")
+	}
+	if o.CosignKey != "" {
+		if len(o.ToMirror) == 0 {
+			return fmt.Errorf("--cosign-key is only supported when mirroring to a registry")
+		}
+		if _, err := loadCosignKey(o.CosignKey); err != nil {
+			return fmt.Errorf("error loading cosign key: %v", err)
+		}
+	}
+	if o.CosignKeyless {
+		if o.CosignKey != "" {
+			return fmt.Errorf("--cosign-key and --cosign-keyless are mutually exclusive")
+		}
+		if len(o.ToMirror) == 0 {
+			return fmt.Errorf("--cosign-keyless is only supported when mirroring to a registry")
+		}
+		if _, err := o.identityToken(); err != nil {
+			return err
+		}
+	}
+	if o.PushSigstoreSignatures && o.CosignKey == "" && !o.CosignKeyless {
+		return fmt.Errorf("--push-sigstore-signatures requires --cosign-key or --cosign-keyless")
+	}
+	if len(o.ReleaseSignatureKeys) != 0 {
+		if len(o.From) == 0 {
+			return fmt.Errorf("--release-signature-key is only supported when publishing")
+		}
+		for _, path := range o.ReleaseSignatureKeys {
+			if _, err := readPublicKeyring(path); err != nil {
+				return err
+			}
+		}
+	}
+	if o.UploadURL != "" {
+		if len(o.OutputDir) == 0 {
+			return fmt.Errorf("--upload-url is only supported when mirroring to disk")
+		}
+		if o.DryRun {
+			return fmt.Errorf("--upload-url is not supported with --dry-run")
+		}
+		if _, err := parseUploadURL(o.UploadURL); err != nil {
+			return err
+		}
+	}
+	if o.Resume {
+		if len(o.OutputDir) == 0 {
+			return fmt.Errorf("--resume is only supported when mirroring to disk")
+		}
+		if o.DryRun {
+			return fmt.Errorf("--resume is not supported with --dry-run")
+		}
+	}
+	if o.DeltaRegistry != "" {
+		if len(o.ToMirror) > 0 {
+			return fmt.Errorf("--delta-registry is only supported when mirroring to disk")
+		}
+		if _, _, err := parseDeltaRegistry(o.DeltaRegistry); err != nil {
+			return err
+		}
+	}
+	if o.SizeReport && len(o.ToMirror) > 0 {
+		return fmt.Errorf("--size-report is only supported when mirroring to disk")
+	}
+	if err := sortImageSizes(nil, o.SizeReportSort); err != nil {
+		return err
+	}
+	if o.MaxICSPMirrors < 0 {
+		return fmt.Errorf("--max-icsp-mirrors must not be negative")
+	}
+
+	for _, arch := range o.FilterOptions {
+		if _, ok := config.SupportedArchitectures[arch]; !ok {
+			return fmt.Errorf("architecture %q is not a supported release architecture", arch)
+		}
+	}
+
+	return nil
+}
+
+// Execute runs the mirroring configured by the options and returns
+// its results, which are also returned when the run failed after
+// they were collected. The cluster clients are only used to apply manifests.
+// The options can be executed again once the run returns.
+func (o *MirrorOptions) Execute(ctx context.Context, f ClusterClients) (*v1alpha2.Results, error) {
+	o.resetRun()
+	err := o.execute(ctx, f)
+	return o.results, err
+}
+
+// resetRun clears the state of the previous run
+func (o *MirrorOptions) resetRun() {
+	o.continuedOnError = false
+	o.resultsDir = ""
+	o.results = nil
+	o.hooks = nil
+	o.resume = nil
+	o.failures = nil
+	o.transfer = nil
+	o.events = nil
+	o.progress = nil
+	o.metrics = nil
+	o.tracer = nil
+	o.phases = nil
+	o.rebuiltImages = nil
+}
+
+func (o *MirrorOptions) execute(ctx context.Context, f ClusterClients) (err error) {
+	if o.OutputDir != "" {
+		if err := os.MkdirAll(o.OutputDir, 0750); err != nil {
+			return err
+		}
+	}
+
+	var sourceInsecure bool
+	if o.SourcePlainHTTP || o.SourceSkipTLS {
+		sourceInsecure = true
+	}
+	var destInsecure bool
+	if o.DestPlainHTTP || o.DestSkipTLS {
+		destInsecure = true
+	}
+
+	cleanup := func() error {
+		if !o.SkipCleanup {
+			return os.RemoveAll(filepath.Join(o.Dir, config.SourceDir))
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(o.Dir, os.ModePerm); err != nil {
+		return err
+	}
+	lock, err := LockWorkspace(o.Dir)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	// Record the upload sessions, which cleanup aborts when the run is interrupted
+	stopUploads, err := o.Clients().RecordUploads(filepath.Join(o.Dir, UploadJournalFile))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := stopUploads(); err != nil {
+			o.log().Debugf("error closing upload journal: %v", err)
+		}
+	}()
+
+	runLog, err := o.openRunLog()
+	if err != nil {
+		return err
+	}
+	defer o.closeRunLog(runLog)
+
+	if o.OutputEvents != "" {
+		if o.events, err = newEventEmitter(o.OutputEvents, os.Stdout, o.log()); err != nil {
+			return err
+		}
+		defer o.events.Close()
+	}
+	if o.EventHandler != nil {
+		if o.events == nil {
+			o.events = &eventEmitter{log: o.log()}
+		}
+		o.events.handler = o.EventHandler
+	}
+
+	if o.MetricsTextfile != "" || o.MetricsAddr != "" {
+		o.metrics = o.newRunMetrics(o.operation())
+		if o.MetricsAddr != "" {
+			if err := o.metrics.serve(o.MetricsAddr); err != nil {
+				return err
+			}
+			defer o.metrics.shutdown(o.MetricsLinger)
+		}
+	}
+
+	if o.TraceExporter != "" {
+		exporter, err := newSpanExporter(ctx, o.TraceExporter)
+		if err != nil {
+			return err
+		}
+		o.tracer = newRunTracer(exporter, o.log())
+		defer o.tracer.shutdown()
+	}
+
+	var mapping image.TypedImageMapping
+	var meta v1alpha2.Metadata
+	results := newResults(o.operation())
+	results.DryRun = o.DryRun
+	o.results = results
+	if o.transfer == nil {
+		o.transfer = &transferStats{}
+	}
+	o.events.emit(v1alpha2.Event{Type: v1alpha2.EventRunStart, Operation: results.Operation})
+	endRunSpan := o.tracer.startRun(results.Operation)
+	defer func() {
+		if rerr := o.completeResults(results, mapping, meta.PastMirror.Sequence, err); rerr != nil {
+			o.log().Errorf("error collecting run results: %v", rerr)
+		}
+		endRunSpan(err)
+		o.events.emit(runEndEvent(results))
+		o.metrics.complete(results, mapping, o.transfer)
+		if o.MetricsTextfile != "" {
+			if merr := o.metrics.writeTextfile(o.MetricsTextfile); merr != nil {
+				o.log().Errorf("error writing run metrics: %v", merr)
+			}
+		}
+		if cerr := o.snapshotConfig(results); cerr != nil {
+			o.log().Errorf("error saving imageset configuration: %v", cerr)
+		}
+		if werr := o.writeResults(results); werr != nil {
+			o.log().Errorf("error writing run results: %v", werr)
+		}
+		if o.Provenance && err == nil {
+			if perr := o.writeProvenance(results, mapping); perr != nil {
+				o.log().Errorf("error writing provenance: %v", perr)
+			}
+		}
+		if cerr := o.writeChecksums(); cerr != nil {
+			o.log().Errorf("error writing results checksums: %v", cerr)
+		}
+		if o.Quiet {
+			if serr := o.writeSummary(o.Out, results, mapping); serr != nil {
+				o.log().Errorf("error writing run summary: %v", serr)
+			}
+		}
+		o.notifyWebhooks(context.Background(), results)
+	}()
+
+	switch {
+	case o.ManifestsOnly:
+		o.log().Info("Not implemented yet")
+	case len(o.OutputDir) > 0 && o.From == "":
+		cfg, err := config.ReadConfig(o.ConfigPath)
+		if err != nil {
+			return exitcode.With(exitcode.ConfigError, err)
+		}
+
+		o.hooks = o.configHooks(cfg)
+
+		if err := bundle.MakeCreateDirs(o.Dir); err != nil {
+			return err
+		}
+
+		err = o.withHooks(ctx, v1alpha2.HookPhasePlan, func() (err error) {
+			done := o.startPhase(phasePlan)
+			meta, mapping, err = o.Create(ctx, cfg)
+			done(err)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		prevAssociations, err := o.removePreviouslyMirrored(mapping, meta)
+		if err != nil {
+			if errors.Is(err, ErrNoUpdatesExist) {
+				o.log().Infof("no new images detected, process stopping")
+				return nil
+			}
+			return err
+		}
+
+		if o.DryRun {
+			mappingPath := filepath.Join(o.Dir, mappingFile)
+			o.log().Infof("Writing image mapping to %s", mappingPath)
+			if err := image.WriteImageMapping(mapping, mappingPath); err != nil {
+				return err
+			}
+			results.Mappings = append(results.Mappings, mappingPath)
+			metadataPath, err := o.writeDryRunMetadata(ctx, mapping, prevAssociations, &meta)
+			if err != nil {
+				return err
+			}
+			results.Reports = append(results.Reports, metadataPath)
+			return cleanup()
+		}
+
+		o.resume, err = o.openResumeState(meta.PastMirror.Sequence)
+		if err != nil {
+			return err
+		}
+		// Images in the complete archives of
+		// an interrupted run are not mirrored again
+		remaining, packedAssocs, err := o.resume.packedImages(mapping)
+		if err != nil {
+			return err
+		}
+		if len(packedAssocs) != 0 {
+			o.log().Infof("Skipping %d images in the complete archives of the interrupted run", len(packedAssocs))
+		}
+
+		cache, err := o.openBlobCache()
+		if err != nil {
+			return err
+		}
+		o.reuseLocalBlobs(ctx, cache, remaining)
+
+		// Mirror planned images
+		done := o.startPhase(phaseMirror)
+		err = o.mirrorMappings(cfg, remaining, sourceInsecure)
+		done(err)
+		if err != nil {
+			return err
+		}
+
+		// Create and store associations
+		assocDir := filepath.Join(o.Dir, config.SourceDir)
+		done = o.startPhase(phaseAssociate)
+		assocs, errs := image.AssociateLocalImageLayers(ctx, assocDir, remaining, o.parallelOptions())
+		done(errs)
+		assocs.Merge(packedAssocs)
+
+		if cache != nil {
+			o.cacheBlobs(cache, assocs)
+		}
+
+		skipErr := func(err error) bool {
+			ierr := &image.ErrInvalidImage{}
+			cerr := &image.ErrInvalidComponent{}
+			return errors.As(err, &ierr) || errors.As(err, &cerr)
+		}
+
+		if errs != nil {
+			for _, e := range errs.Errors() {
+				if err := o.checkErr(e, skipErr); err != nil {
+					return err
+				}
+			}
+		}
+
+		if o.SizeReport {
+			reports, err := o.writeSizeReport(assocs)
+			if err != nil {
+				return err
+			}
+			results.Reports = append(results.Reports, reports...)
+		}
+
+		// Pack the images set
+		var tmpBackend storage.Backend
+		err = o.withHooks(ctx, v1alpha2.HookPhaseArchive, func() (err error) {
+			done := o.startPhase(phaseArchive)
+			tmpBackend, err = o.Pack(ctx, prevAssociations, assocs, &meta, cfg.ArchiveSize)
+			done(err)
+			return err
+		})
+		if err != nil {
+			if errors.Is(err, ErrNoUpdatesExist) {
+				o.log().Infof("no updates detected, process stopping")
+				return o.resume.remove()
+			}
+			return err
+		}
+
+		// Sync metadata from temporary backend to target backend
+		if cfg.StorageConfig.IsSet() {
+			targetBackend, err := storage.ByConfig(o.Dir, cfg.StorageConfig, o.Clients())
+			if err != nil {
+				return exitcode.With(exitcode.StorageError, err)
+			}
+			if err := metadata.SyncMetadata(ctx, tmpBackend, targetBackend); err != nil {
+				return exitcode.With(exitcode.StorageError, err)
+			}
+		}
+		if err := o.resume.remove(); err != nil {
+			return err
+		}
+
+		if o.UploadURL != "" {
+			done := o.startPhase(phaseUpload)
+			results.Uploads, err = o.uploadArchives(ctx, meta.PastMirror.Sequence)
+			done(err)
+			if err != nil {
+				return err
+			}
+		}
+	case len(o.ToMirror) > 0 && len(o.From) > 0:
+		// Hooks are read from the configuration,
+		// which is optional when publishing
+		if len(o.ConfigPath) > 0 {
+			cfg, err := config.ReadConfig(o.ConfigPath)
+			if err != nil {
+				return exitcode.With(exitcode.ConfigError, err)
+			}
+			o.hooks = o.configHooks(cfg)
+		}
+		// Publish from disk to registry
+		// this takes care of syncing the metadata to the
+		// registry backends and generating the CatalogSource
+		err = o.withHooks(ctx, v1alpha2.HookPhasePublish, func() (err error) {
+			done := o.startPhase(phasePush)
+			mapping, err = o.Publish(ctx)
+			done(err)
+			return err
+		})
+		if err != nil {
+			serr := &SequenceError{}
+			if errors.As(err, &serr) {
+				return exitcode.With(exitcode.SequenceError, fmt.Errorf(
+					"error occurred during publishing, expecting imageset with prefix mirror_seq%d: %v",
+					serr.wantSeq,
+					err,
+				))
+			}
+			return err
+		}
+		dir, err := o.createResultsDir()
+		if err != nil {
+			return err
+		}
+		done := o.startPhase(phaseManifests)
+		err = o.generateAllManifests(mapping, dir)
+		done(err)
+		if err != nil {
+			return err
+		}
+		if o.PushSigstoreSignatures {
+			sigDir := filepath.Join(o.OutputDir, config.ReleaseSignatureDir)
+			if err := o.pushSigstoreSignatures(ctx, mapping, sigDir); err != nil {
+				return err
+			}
+		}
+		switch {
+		case o.Apply || o.ApplyDryRun:
+			sigDir := filepath.Join(o.OutputDir, config.ReleaseSignatureDir)
+			if err := o.applyManifests(ctx, f, dir, sigDir, o.ApplyDryRun); err != nil {
+				return err
+			}
+		case o.ApplySignatures:
+			sigDir := filepath.Join(o.OutputDir, config.ReleaseSignatureDir)
+			if err := o.applyReleaseSignatures(ctx, f, sigDir); err != nil {
+				return err
+			}
+		}
+		if o.Kustomize {
+			if err := o.writeKustomization(dir, filepath.Join(o.OutputDir, config.ReleaseSignatureDir)); err != nil {
+				return err
+			}
+		}
+		if len(o.ClusterOverlays) != 0 {
+			if err := o.writeClusterOverlays(dir, filepath.Join(o.OutputDir, config.ReleaseSignatureDir)); err != nil {
+				return err
+			}
+		}
+	case len(o.ToMirror) > 0 && len(o.ConfigPath) > 0:
+		cfg, err := config.ReadConfig(o.ConfigPath)
+		if err != nil {
+			return exitcode.With(exitcode.ConfigError, err)
+		}
+		o.hooks = o.configHooks(cfg)
+		if err := bundle.MakeCreateDirs(o.Dir); err != nil {
+			return err
+		}
+		err = o.withHooks(ctx, v1alpha2.HookPhasePlan, func() (err error) {
+			done := o.startPhase(phasePlan)
+			meta, mapping, err = o.Create(ctx, cfg)
+			done(err)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		// Change the destination to registry
+		// TODO(jpower432): Investigate whether oc can produce
+		// registry to registry mapping
+		mapping.ToRegistry(o.ToMirror, o.UserNamespace)
+
+		prevAssociations, err := o.removePreviouslyMirrored(mapping, meta)
+		if err != nil {
+			if errors.Is(err, ErrNoUpdatesExist) {
+				o.log().Infof("no new images detected, process stopping")
+				return nil
+			}
+			return err
+		}
+
+		if o.DryRun {
+			mappingPath := filepath.Join(o.Dir, mappingFile)
+			o.log().Infof("Writing image mapping to %s", mappingPath)
+			if err := image.WriteImageMapping(mapping, mappingPath); err != nil {
+				return err
+			}
+			results.Mappings = append(results.Mappings, mappingPath)
+			return cleanup()
+		}
+
+		err = o.checkDestinationQuota(ctx, mappingDestinations(mapping), func() (int64, error) {
+			return o.remoteMappingSize(ctx, mapping)
+		})
+		if err != nil {
+			return err
+		}
+
+		// Mirror planned images
+		// TODO(jpower432): Investigate how to mirror to mirror and
+		// specific source and dest TLS configuration
+		err = o.withHooks(ctx, v1alpha2.HookPhasePublish, func() error {
+			done := o.startPhase(phaseMirror)
+			err := o.mirrorMappings(cfg, mapping, destInsecure)
+			done(err)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		// Create associations, reusing those of images
+		// that did not change since previous runs
+		var pastAssociations image.AssociationSet
+		if !o.IgnoreHistory {
+			pastAssociations, err = image.ConvertToAssociationSet(meta.PastAssociations)
+			if err != nil {
+				return err
+			}
+		}
+		done := o.startPhase(phaseAssociate)
+		assocs, errs := o.Clients().AssociateRemoteImageLayers(ctx, mapping, pastAssociations, o.SourceSkipTLS, o.SourcePlainHTTP, o.SkipVerification, o.parallelOptions())
+		done(errs)
+		skipErr := func(err error) bool {
+			ierr := &image.ErrInvalidImage{}
+			cerr := &image.ErrInvalidComponent{}
+			return errors.As(err, &ierr) || errors.As(err, &cerr) || (o.SkipMissing && errors.Is(err, errdefs.ErrNotFound))
+		}
+
+		if errs != nil {
+			for _, e := range errs.Errors() {
+				if err := o.checkErr(e, skipErr); err != nil {
+					return err
+				}
+			}
+		}
+
+		meta.PastMirror.Associations, err = image.ConvertFromAssociationSet(assocs)
+		if err != nil {
+			return err
+		}
+		prevAssociations.Merge(assocs)
+		meta.PastAssociations, err = image.ConvertFromAssociationSet(prevAssociations)
+		if err != nil {
+			return err
+		}
+
+		dir, err := o.createResultsDir()
+		if err != nil {
+			return err
+		}
+
+		// process catalog FBC images
+		if len(cfg.Mirror.Operators) > 0 {
+			ctlgRefs, err := o.rebuildCatalogs(ctx, filepath.Join(o.Dir, config.SourceDir))
+			if err != nil {
+				return fmt.Errorf("error rebuilding catalog images from file-based catalogs: %v", err)
+			}
+			mapping.Merge(ctlgRefs)
+		}
+		// process Cincinnati graph data image
+		if len(cfg.Mirror.Platform.Channels) > 0 || len(cfg.Mirror.Platform.Releases) > 0 || len(cfg.Mirror.Platform.UpgradePaths) > 0 {
+			// Move release signatures into results dir
+			srcSignaturePath := filepath.Join(o.Dir, config.SourceDir, config.ReleaseSignatureDir)
+			dstSignaturePath := filepath.Join(dir, config.ReleaseSignatureDir)
+			if err := os.Rename(srcSignaturePath, dstSignaturePath); err != nil {
+				return err
+			}
+			o.log().Debugf("Moved any release signatures to %s", dir)
+
+			// Move RHCOS boot images into results dir
+			srcBootImagesPath := filepath.Join(o.Dir, config.SourceDir, config.BootImagesDir)
+			if _, err := os.Stat(srcBootImagesPath); err == nil {
+				dstBootImagesPath := filepath.Join(dir, config.BootImagesDir)
+				if err := os.Rename(srcBootImagesPath, dstBootImagesPath); err != nil {
+					return err
+				}
+				o.log().Debugf("Moved RHCOS boot images to %s", dir)
+			}
+
+			// Move client binaries into results dir
+			srcClientsPath := filepath.Join(o.Dir, config.SourceDir, config.ClientsDir)
+			if _, err := os.Stat(srcClientsPath); err == nil {
+				dstClientsPath := filepath.Join(dir, config.ClientsDir)
+				if err := os.Rename(srcClientsPath, dstClientsPath); err != nil {
+					return err
+				}
+				o.log().Debugf("Moved client binaries to %s", dir)
+			}
+
+			if cfg.Mirror.Platform.Graph && len(cfg.Mirror.Platform.Channels) > 0 {
+				graphRef, err := o.buildGraphImage(ctx, filepath.Join(o.Dir, config.SourceDir), getGraphBaseImage(cfg.Mirror.Platform))
+				if err != nil {
+					return fmt.Errorf("error building cincinnati graph image: %v", err)
+				}
+				mapping.Merge(graphRef)
+			}
+		}
+		done = o.startPhase(phaseManifests)
+		err = o.generateAllManifests(mapping, dir)
+		done(err)
+		if err != nil {
+			return err
+		}
+		if o.PushSigstoreSignatures {
+			sigDir := filepath.Join(dir, config.ReleaseSignatureDir)
+			if err := o.pushSigstoreSignatures(ctx, mapping, sigDir); err != nil {
+				return err
+			}
+		}
+		switch {
+		case o.Apply || o.ApplyDryRun:
+			sigDir := filepath.Join(dir, config.ReleaseSignatureDir)
+			if err := o.applyManifests(ctx, f, dir, sigDir, o.ApplyDryRun); err != nil {
+				return err
+			}
+		case o.ApplySignatures:
+			sigDir := filepath.Join(dir, config.ReleaseSignatureDir)
+			if err := o.applyReleaseSignatures(ctx, f, sigDir); err != nil {
+				return err
+			}
+		}
+		if o.Kustomize {
+			if err := o.writeKustomization(dir, filepath.Join(dir, config.ReleaseSignatureDir)); err != nil {
+				return err
+			}
+		}
+		if len(o.ClusterOverlays) != 0 {
+			if err := o.writeClusterOverlays(dir, filepath.Join(dir, config.ReleaseSignatureDir)); err != nil {
+				return err
+			}
+		}
+
+		// Move charts into results dir
+		srcHelmPath := filepath.Join(o.Dir, config.SourceDir, config.HelmDir)
+		dstHelmPath := filepath.Join(dir, config.HelmDir)
+		if err := os.Rename(srcHelmPath, dstHelmPath); err != nil {
+			return err
+		}
+		o.log().Debugf("Moved any downloaded Helm charts to %s", dir)
+		// Sync metadata from disk to source and target backends
+		if cfg.StorageConfig.IsSet() {
+			sourceBackend, err := storage.ByConfig(o.Dir, cfg.StorageConfig, o.Clients())
+			if err != nil {
+				return exitcode.With(exitcode.StorageError, err)
+			}
+			metaImage := o.newMetadataImage(meta.Uid.String())
+			targetCfg := v1alpha2.StorageConfig{
+				Registry: &v1alpha2.RegistryConfig{
+					ImageURL: metaImage,
+					SkipTLS:  destInsecure,
+				},
+			}
+
+			targetBackend, err := storage.ByConfig(o.Dir, targetCfg, o.Clients())
+			if err != nil {
+				return exitcode.With(exitcode.StorageError, err)
+			}
+			// Update source metadata
+			err = metadata.UpdateMetadata(ctx, o.Clients(), sourceBackend, &meta, filepath.Join(o.Dir, config.SourceDir), o.SourceSkipTLS, o.SourcePlainHTTP)
+			if err != nil {
+				return exitcode.With(exitcode.StorageError, err)
+			}
+			// Sync target metadata
+			err = metadata.SyncMetadata(ctx, sourceBackend, targetBackend)
+			if err != nil {
+				return exitcode.With(exitcode.StorageError, err)
+			}
+		}
+	}
+
+	if o.continuedOnError {
+		return exitcode.With(exitcode.PartialFailure, fmt.Errorf("one or more errors occurred"))
+	}
+
+	return cleanup()
+}
+
+// removePreviouslyMirrored will check if an image has been previously mirrored
+// and remove it from the mapping if found. The new past associations are returned.
+func (o *MirrorOptions) removePreviouslyMirrored(images image.TypedImageMapping, meta v1alpha2.Metadata) (image.AssociationSet, error) {
+	prevDownloads, err := image.ConvertToAssociationSet(meta.PastAssociations)
+	if err != nil {
+		return image.AssociationSet{}, err
+	}
+
+	if o.IgnoreHistory {
+		return prevDownloads, nil
+	}
+
+	var keep []string
+	for srcRef := range images {
+		// All keys need to specify image with digest.
+		// Tagged images will need to be redownloaded to
+		// ensure their digests have not be updated.
+		if srcRef.Ref.ID == "" {
+			continue
+		}
+		if found := prevDownloads.SetContainsKey(srcRef.Ref.String()); found {
+			o.log().Debugf("skipping previously mirrored image %s", srcRef.Ref.String())
+			images.Remove(srcRef)
+			keep = append(keep, srcRef.Ref.String())
+		}
+	}
+
+	o.transfer.skipPrevious(len(keep))
+
+	prunedDownloads, err := image.Prune(prevDownloads, keep)
+	if err != nil {
+		return prunedDownloads, err
+	}
+
+	if len(images) == 0 {
+		return image.AssociationSet{}, ErrNoUpdatesExist
+	}
+
+	return prunedDownloads, prunedDownloads.Validate()
+}
+
+// mirrorImage downloads individual images from an image mapping
+func (o *MirrorOptions) mirrorMappings(cfg v1alpha2.ImageSetConfiguration, images image.TypedImageMapping, insecure bool) error {
+
+	opts, err := o.newMirrorImageOptions(insecure)
+	if err != nil {
+		return err
+	}
+
+	// Create mapping from source and destination images
+	var mappings []mirror.Mapping
+	for srcRef, dstRef := range images {
+		if bundle.IsBlocked(cfg.Mirror.BlockedImages, srcRef.Ref) {
+			o.log().Warnf("skipping blocked image %s", srcRef.String())
+			o.transfer.skip(srcRef.TypedImageReference.String())
+			continue
+		}
+
+		mappings = append(mappings, mirror.Mapping{
+			Source:      srcRef.TypedImageReference,
+			Destination: dstRef.TypedImageReference,
+			Name:        srcRef.Ref.Name,
+		})
+	}
+	opts.Mappings = mappings
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	defer o.transfer.time(time.Now())
+	return o.checkErr(opts.Run(), nil)
+}
+
+const (
+	// defaultMaxPerRegistry is the default number of
+	// concurrent requests to each registry
+	defaultMaxPerRegistry = 2
+	// defaultMaxConcurrentDownloads is the default number of
+	// blobs downloaded concurrently when mirroring to disk
+	defaultMaxConcurrentDownloads = 6
+	// maxConcurrentDownloads keeps the concurrent requests to
+	// each source registry within what registries tolerate
+	maxConcurrentDownloads = 32
+)
+
+// parallelOptions returns the concurrency limits of the
+// requests to registries while mirroring and associating images
+func (o *MirrorOptions) parallelOptions() imagemanifest.ParallelOptions {
+	maxPerRegistry := o.MaxPerRegistry
+	// The library limits blob copies per destination registry, and
+	// the workspace is the only destination when mirroring to disk,
+	// so the download limit applies unless only --max-per-registry is set
+	if len(o.OutputDir) > 0 && len(o.ToMirror) == 0 {
+		switch {
+		case o.MaxConcurrentDownloads > 0:
+			maxPerRegistry = o.MaxConcurrentDownloads
+		case maxPerRegistry == 0:
+			maxPerRegistry = defaultMaxConcurrentDownloads
+		}
+	}
+	if maxPerRegistry == 0 {
+		maxPerRegistry = defaultMaxPerRegistry
+	}
+	return imagemanifest.ParallelOptions{MaxPerRegistry: maxPerRegistry}
+}
+
+func (o *MirrorOptions) newMirrorImageOptions(insecure bool) (*mirror.MirrorImageOptions, error) {
+	opts := mirror.NewMirrorImageOptions(o.mirrorStreams())
+	opts.SkipMissing = o.SkipMissing
+	opts.ContinueOnError = o.ContinueOnError
+	opts.DryRun = o.DryRun
+	opts.FileDir = filepath.Join(o.Dir, config.SourceDir)
+	opts.FromFileDir = o.From
+	opts.SecurityOptions.Insecure = insecure
+	opts.SecurityOptions.SkipVerification = o.SkipVerification
+	opts.FilterOptions = imagemanifest.FilterOptions{FilterByOS: ".*"}
+	opts.KeepManifestList = true
+	opts.SkipMultipleScopes = true
+	opts.ParallelOptions = o.parallelOptions()
+	regctx, err := o.Clients().Context(o.SkipVerification)
+	if err != nil {
+		return opts, fmt.Errorf("error creating registry context: %v", err)
+	}
+	opts.SecurityOptions.CachedContext = regctx
+
+	return opts, nil
+}
+
+func (o *MirrorOptions) generateAllManifests(mapping image.TypedImageMapping, dir string) error {
+
+	allICSPs := []operatorv1alpha1.ImageContentSourcePolicy{}
+	releases := image.ByCategory(mapping, v1alpha2.TypeOCPRelease, v1alpha2.TypeOCPReleaseContent)
+	graphs := image.ByCategory(mapping, v1alpha2.TypeCincinnatiGraph)
+	generic := image.ByCategory(mapping, v1alpha2.TypeGeneric)
+	operator := image.ByCategory(mapping, v1alpha2.TypeOperatorBundle, v1alpha2.TypeOperatorCatalog)
+
+	byteLimit := o.MaxICSPSize
+	if byteLimit == 0 {
+		byteLimit = icspSizeLimit
+	}
+
+	scope := o.ICSPScope
+	if scope == "" {
+		scope = namespaceICSPScope
+	}
+
+	sources := []icspSource{
+		{name: "release", mapping: releases, builder: &ReleaseBuilder{Log: o.log()}},
+		{name: "generic", mapping: generic, builder: &GenericBuilder{Log: o.log()}},
+		{name: "operator", mapping: operator, builder: &OperatorBuilder{Log: o.log()}},
+	}
+
+	if len(graphs) == 1 {
+		releaseImages := image.ByCategory(releases, v1alpha2.TypeOCPRelease)
+		if len(releaseImages) != 0 {
+			for _, graph := range graphs {
+				var release image.TypedImage
+				// Just grab the first release image.
+				// The value is used as a repo and all release images
+				// are stored in the same repo
+				for _, v := range releaseImages {
+					release = v
+					break
+				}
+				if err := o.WriteUpdateService(release, graph, dir); err != nil {
+					return err
+				}
+			}
+		}
+
+	}
+
+	ctlgRefs := image.ByCategory(operator, v1alpha2.TypeOperatorCatalog)
+	if len(ctlgRefs) != 0 {
+		if err := o.WriteCatalogSource(ctlgRefs, dir); err != nil {
+			return err
+		}
+	}
+
+	switch o.ICSPGrouping {
+	case registryICSPGrouping:
+		icsps, err := generateICSPsByRegistry(o.ICSPNamePrefix, scope, byteLimit, o.MaxICSPMirrors, sources)
+		if err != nil {
+			return fmt.Errorf("error generating ICSP manifests: %v", err)
+		}
+		allICSPs = append(allICSPs, icsps...)
+	default:
+		for _, source := range sources {
+			icsps, err := GenerateICSPWithLimits(o.ICSPNamePrefix+source.name, scope, byteLimit, o.MaxICSPMirrors, source.mapping, source.builder)
+			if err != nil {
+				return fmt.Errorf("error generating ICSP manifests: %v", err)
+			}
+			allICSPs = append(allICSPs, icsps...)
+		}
+	}
+
+	if err := o.WriteICSPs(dir, allICSPs); err != nil {
+		return err
+	}
+
+	if o.RegistriesConf {
+		if err := o.writeRegistriesConf(dir, allICSPs); err != nil {
+			return err
+		}
+	}
+
+	if o.LegacyOutput {
+		if err := o.writeLegacyOutput(mapping, dir); err != nil {
+			return err
+		}
+	}
+
+	if o.ContainersPolicy {
+		return o.writeContainersPolicy(mapping, dir)
+	}
+	return nil
+}
+
+func (o *MirrorOptions) checkErr(err error, acceptableErr func(error) bool) error {
+
+	if err == nil {
+		return nil
+	}
+
+	var skip, skipAllTypes bool
+	if acceptableErr != nil {
+		skip = acceptableErr(err)
+	} else {
+		skipAllTypes = true
+	}
+	// Instead of returning an error, just log it.
+	if o.ContinueOnError && (skip || skipAllTypes) {
+		o.log().Warn(err)
+		o.continuedOnError = true
+		o.addFailure(err)
+	} else {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,459 @@
+package mirror
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestCompleteDestination(t *testing.T) {
+	type spec struct {
+		name     string
+		args     []string
+		opts     *MirrorOptions
+		expOpts  *MirrorOptions
+		expError string
+	}
+
+	cases := []spec{
+		{
+			name: "Valid/FileDest",
+			args: []string{"file://foo"},
+			opts: &MirrorOptions{
+				Dir: "bar",
+			},
+			expOpts: &MirrorOptions{
+				OutputDir:     "foo",
+				Dir:           "foo/bar",
+				FilterOptions: []string{"amd64"},
+			},
+		},
+		{
+			name: "Valid/FileDestRel",
+			args: []string{"file://./foo"},
+			opts: &MirrorOptions{
+				Dir: "bar",
+			},
+			expOpts: &MirrorOptions{
+				OutputDir:     "foo",
+				Dir:           "foo/bar",
+				FilterOptions: []string{"amd64"},
+			},
+		},
+		{
+			name: "Valid/EmptyFileDest",
+			args: []string{"file://"},
+			opts: &MirrorOptions{
+				Dir: "bar",
+			},
+			expOpts: &MirrorOptions{
+				OutputDir:     ".",
+				Dir:           "bar",
+				FilterOptions: []string{"amd64"},
+			},
+		},
+		{
+			name: "Valid/RegDest",
+			args: []string{"docker://reg.com"},
+			opts: &MirrorOptions{},
+			expOpts: &MirrorOptions{
+				ToMirror:      "reg.com",
+				FilterOptions: []string{"amd64"},
+			},
+		},
+		{
+			name: "Valid/RegNamespace",
+			args: []string{"docker://reg.com/foo/bar"},
+			opts: &MirrorOptions{},
+			expOpts: &MirrorOptions{
+				ToMirror:      "reg.com",
+				UserNamespace: "foo/bar",
+				FilterOptions: []string{"amd64"},
+			},
+		},
+		{
+			name: "Valid/SetFilterOps",
+			args: []string{"file://foo"},
+			opts: &MirrorOptions{
+				Dir:           "bar",
+				FilterOptions: []string{"amd64", "ppc64le"},
+			},
+			expOpts: &MirrorOptions{
+				OutputDir:     "foo",
+				Dir:           "foo/bar",
+				FilterOptions: []string{"amd64", "ppc64le"},
+			},
+		},
+		{
+			name:     "Invalid/TaggedReg",
+			args:     []string{"docker://reg.com/foo/bar:latest"},
+			opts:     &MirrorOptions{},
+			expError: "destination registry must consist of registry host and namespace(s) only",
+		},
+		{
+			name:     "Invalid/EmptyRegistry",
+			args:     []string{"docker://"},
+			opts:     &MirrorOptions{},
+			expError: `"" is not a valid image reference: repository name must have at least one component`,
+		},
+		{
+			name:     "Invalid/EmptyScheme",
+			args:     []string{"://foo"},
+			opts:     &MirrorOptions{},
+			expError: `unknown destination scheme ""`,
+		},
+		{
+			name:     "Invalid/NoSchemeDelim",
+			args:     []string{"foo"},
+			opts:     &MirrorOptions{},
+			expError: "no scheme delimiter in destination argument",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.opts.CompleteDestination(c.args[0])
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, c.expOpts, c.opts)
+			}
+		})
+	}
+}
+
+func TestConfigureRegistriesPathPrefix(t *testing.T) {
+	cfg := `apiVersion: mirror.openshift.io/v1alpha2
+kind: ImageSetConfiguration
+registries:
+- host: artifactory.example.com
+  pathPrefix: /artifactory/api/docker/docker-local
+`
+	path := filepath.Join(t.TempDir(), "imageset-config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(cfg), 0600))
+	defer func() {
+		require.NoError(t, image.SharedClients().SetRegistries(nil))
+	}()
+
+	type spec struct {
+		name         string
+		destination  string
+		expMirror    string
+		expNamespace string
+	}
+	cases := []spec{
+		{
+			name:         "Valid/DestinationWithPrefix",
+			destination:  "docker://artifactory.example.com/artifactory/api/docker/docker-local/mirror",
+			expMirror:    "artifactory.example.com",
+			expNamespace: "mirror",
+		},
+		{
+			name:         "Valid/DestinationWithoutPrefix",
+			destination:  "docker://artifactory.example.com/mirror",
+			expMirror:    "artifactory.example.com",
+			expNamespace: "mirror",
+		},
+		{
+			name:         "Valid/OtherRegistry",
+			destination:  "docker://registry.example.com/artifactory/api/docker/docker-local",
+			expMirror:    "registry.example.com",
+			expNamespace: "artifactory/api/docker/docker-local",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			o := &MirrorOptions{ConfigPath: path}
+			require.NoError(t, o.CompleteDestination(c.destination))
+			require.NoError(t, o.ConfigureRegistries())
+			require.Equal(t, c.expMirror, o.ToMirror)
+			require.Equal(t, c.expNamespace, o.UserNamespace)
+		})
+	}
+}
+
+func TestConfigureRegistriesInsecure(t *testing.T) {
+	cfg := `apiVersion: mirror.openshift.io/v1alpha2
+kind: ImageSetConfiguration
+registries:
+- host: registry.example.com
+  insecure: true
+`
+	path := filepath.Join(t.TempDir(), "imageset-config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(cfg), 0600))
+	defer func() {
+		require.NoError(t, image.SharedClients().SetRegistries(nil))
+	}()
+
+	type spec struct {
+		name        string
+		configPath  string
+		insecure    []string
+		expAllowed  []string
+		expRejected []string
+	}
+	cases := []spec{
+		{
+			name:        "Valid/NoRegistries",
+			expRejected: []string{"localhost:5000", "registry.example.com"},
+		},
+		{
+			name:        "Valid/InsecureRegistryFlag",
+			insecure:    []string{"localhost:5000"},
+			expAllowed:  []string{"localhost:5000"},
+			expRejected: []string{"registry.example.com"},
+		},
+		{
+			name:        "Valid/ConfigAndFlag",
+			configPath:  path,
+			insecure:    []string{"localhost:5000"},
+			expAllowed:  []string{"localhost:5000", "registry.example.com"},
+			expRejected: []string{"quay.io"},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			o := &MirrorOptions{ConfigPath: c.configPath, InsecureRegistries: c.insecure, DestPlainHTTP: true}
+			require.NoError(t, o.ConfigureRegistries())
+			for _, host := range c.expAllowed {
+				require.True(t, image.SharedClients().AllowsInsecure(host), host)
+			}
+			for _, host := range c.expRejected {
+				require.False(t, image.SharedClients().AllowsInsecure(host), host)
+			}
+		})
+	}
+}
+
+func TestMirrorValidate(t *testing.T) {
+
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Error(err)
+	}
+
+	type spec struct {
+		name     string
+		opts     *MirrorOptions
+		expError string
+	}
+
+	cases := []spec{
+		{
+			name: "Invalid/NoDest",
+			opts: &MirrorOptions{
+				From: "dir",
+			},
+			expError: "must specify a registry destination",
+		},
+		{
+			name: "Invalid/NoSource",
+			opts: &MirrorOptions{
+				ToMirror: u.Host,
+			},
+			expError: `must specify --config or --from with registry destination`,
+		},
+		{
+			name: "Invalid/NoConfig",
+			opts: &MirrorOptions{
+				OutputDir: "dir",
+			},
+			expError: `must specify a configuration file with --config`,
+		},
+		{
+			name: "Invalid/UnsupportReleaseArch",
+			opts: &MirrorOptions{
+				ConfigPath:    "foo",
+				ToMirror:      u.Host,
+				FilterOptions: []string{"sparc64"},
+			},
+			expError: "architecture \"sparc64\" is not a supported release architecture",
+		},
+		{
+			name: "Invalid/MaxConcurrentDownloads",
+			opts: &MirrorOptions{
+				ConfigPath:             "foo",
+				OutputDir:              t.TempDir(),
+				MaxConcurrentDownloads: 64,
+			},
+			expError: "--max-concurrent-downloads must be at most 32",
+		},
+		{
+			name: "Invalid/ResumeToMirror",
+			opts: &MirrorOptions{
+				ConfigPath: "foo",
+				ToMirror:   u.Host,
+				Resume:     true,
+			},
+			expError: "--resume is only supported when mirroring to disk",
+		},
+		{
+			name: "Invalid/ResumeDryRun",
+			opts: &MirrorOptions{
+				ConfigPath: "foo",
+				OutputDir:  t.TempDir(),
+				DryRun:     true,
+				Resume:     true,
+			},
+			expError: "--resume is not supported with --dry-run",
+		},
+		{
+			name: "Invalid/UploadToMirror",
+			opts: &MirrorOptions{
+				ConfigPath: "foo",
+				ToMirror:   u.Host,
+				UploadURL:  "https://nexus.example.com/repository/mirror",
+			},
+			expError: "--upload-url is only supported when mirroring to disk",
+		},
+		{
+			name: "Invalid/UploadScheme",
+			opts: &MirrorOptions{
+				ConfigPath: "foo",
+				OutputDir:  t.TempDir(),
+				UploadURL:  "ftp://nexus.example.com/repository/mirror",
+			},
+			expError: `upload URL "ftp://nexus.example.com/repository/mirror" must be an http or https URL`,
+		},
+		{
+			name: "Invalid/DeltaRegistryToMirror",
+			opts: &MirrorOptions{
+				From:          t.TempDir(),
+				ToMirror:      u.Host,
+				DeltaRegistry: u.Host,
+			},
+			expError: "--delta-registry is only supported when mirroring to disk",
+		},
+		{
+			name: "Invalid/ReleaseSignatureKeyToDisk",
+			opts: &MirrorOptions{
+				ConfigPath:           "foo",
+				OutputDir:            t.TempDir(),
+				ReleaseSignatureKeys: []string{"key.pub"},
+			},
+			expError: "--release-signature-key is only supported when publishing",
+		},
+		{
+			name: "Valid/MirrortoDisk",
+			opts: &MirrorOptions{
+				ConfigPath: "foo",
+				ToMirror:   u.Host,
+			},
+			expError: "",
+		},
+		{
+			name: "Valid/DisktoMirror",
+			opts: &MirrorOptions{
+				From:     t.TempDir(),
+				ToMirror: u.Host,
+			},
+			expError: "",
+		},
+		{
+			name: "Valid/MirrorToMirror",
+			opts: &MirrorOptions{
+				ConfigPath: "foo",
+				ToMirror:   u.Host,
+			},
+			expError: "",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.opts.Validate()
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewMirrorImageOptions(t *testing.T) {
+	type spec struct {
+		name              string
+		opts              *MirrorOptions
+		expMaxPerRegistry int
+	}
+	cases := []spec{
+		{
+			name: "Valid/MirrorToDisk",
+			opts: &MirrorOptions{
+				OutputDir:              "archives",
+				MaxPerRegistry:         2,
+				MaxConcurrentDownloads: 8,
+			},
+			expMaxPerRegistry: 8,
+		},
+		{
+			name:              "Valid/MirrorToDiskDefault",
+			opts:              &MirrorOptions{OutputDir: "archives"},
+			expMaxPerRegistry: defaultMaxConcurrentDownloads,
+		},
+		{
+			name: "Valid/MirrorToDiskMaxPerRegistry",
+			opts: &MirrorOptions{
+				OutputDir:      "archives",
+				MaxPerRegistry: 12,
+			},
+			expMaxPerRegistry: 12,
+		},
+		{
+			name: "Valid/MirrorToMirror",
+			opts: &MirrorOptions{
+				ToMirror:               "reg.mirror.com",
+				MaxPerRegistry:         2,
+				MaxConcurrentDownloads: 8,
+			},
+			expMaxPerRegistry: 2,
+		},
+		{
+			name:              "Valid/MirrorToMirrorDefault",
+			opts:              &MirrorOptions{ToMirror: "reg.mirror.com"},
+			expMaxPerRegistry: defaultMaxPerRegistry,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.opts.Dir = t.TempDir()
+			opts, err := c.opts.newMirrorImageOptions(false)
+			require.NoError(t, err)
+			require.Equal(t, c.expMaxPerRegistry, opts.ParallelOptions.MaxPerRegistry)
+		})
+	}
+}
+
+// markInsecure marks the registry insecure in the shared
+// clients for the duration of the test
+func markInsecure(t *testing.T, host string) {
+	require.NoError(t, image.SharedClients().SetRegistries([]v1alpha2.Registry{{Host: host, Insecure: true}}))
+	t.Cleanup(func() {
+		require.NoError(t, image.SharedClients().SetRegistries(nil))
+	})
+}
+
+func TestResetRun(t *testing.T) {
+	o := NewMirrorOptions()
+	o.resultsDir = "results-1"
+	o.continuedOnError = true
+	o.failures = []v1alpha2.Failure{{Error: "failed"}}
+	o.resetRun()
+	require.Empty(t, o.ResultsDir())
+	require.False(t, o.continuedOnError)
+	require.Empty(t, o.failures)
+}
@@ -76,7 +76,7 @@ func (o *OperatorOptions) complete() {
 	}
 
 	if o.Logger == nil {
-		o.Logger = logrus.NewEntry(logrus.New())
+		o.Logger = logrus.NewEntry(o.log())
 	}
 }
 
@@ -93,7 +93,7 @@ func (o *OperatorOptions) run(ctx context.Context, cfg v1alpha2.ImageSetConfigur
 		defer cleanup()
 	}
 
-	reg, err := o.createRegistry()
+	reg, err := o.createRegistry(cfg.Mirror.Operators)
 	if err != nil {
 		return nil, fmt.Errorf("error creating container registry: %v", err)
 	}
@@ -133,7 +133,14 @@ func (o *OperatorOptions) mktempDir() (func(), error) {
 	}, os.MkdirAll(o.tmp, os.ModePerm)
 }
 
-func (o *OperatorOptions) createRegistry() (*containerdregistry.Registry, error) {
+func (o *OperatorOptions) createRegistry(catalogs []v1alpha2.Operator) (*containerdregistry.Registry, error) {
+	// The registry applies the insecure options to every catalog
+	refs := make([]string, 0, len(catalogs))
+	for _, ctlg := range catalogs {
+		refs = append(refs, ctlg.Catalog)
+	}
+	insecure := o.Clients().AllowsInsecureImages(refs...)
+
 	cacheDir, err := os.MkdirTemp("", "imageset-catalog-registry-")
 	if err != nil {
 		return nil, err
@@ -145,8 +152,9 @@ func (o *OperatorOptions) createRegistry() (*containerdregistry.Registry, error)
 
 	return containerdregistry.NewRegistry(
 		containerdregistry.WithCacheDir(cacheDir),
-		containerdregistry.SkipTLSVerify(o.SourceSkipTLS),
-		containerdregistry.WithPlainHTTP(o.SourcePlainHTTP),
+		containerdregistry.SkipTLSVerify(o.SourceSkipTLS && insecure),
+		containerdregistry.WithPlainHTTP(o.SourcePlainHTTP && insecure),
+		containerdregistry.WithRootCAs(o.Clients().RootCAs()),
 		// The containerd registry impl is somewhat verbose, even on the happy path,
 		// so discard all logger logs. Any important failures will be returned from
 		// registry methods and eventually logged as fatal errors.
@@ -191,7 +199,7 @@ func (o *OperatorOptions) renderDCFull(ctx context.Context, reg *containerdregis
 			return nil, err
 		}
 
-		verifyOperatorPkgFound(dic, dc)
+		o.verifyOperatorPkgFound(dic, dc)
 	}
 
 	return dc, nil
@@ -272,16 +280,16 @@ func (o *OperatorOptions) renderDCDiff(ctx context.Context, reg *containerdregis
 		}
 	}
 
-	verifyOperatorPkgFound(dic, dc)
+	o.verifyOperatorPkgFound(dic, dc)
 
 	return dc, nil
 }
 
 // verifyOperatorPkgFound will verify that each of the requested operator packages were
 // found and added to the DeclarativeConfig.
-func verifyOperatorPkgFound(dic action.DiffIncludeConfig, dc *declcfg.DeclarativeConfig) {
-	logrus.Debug("DiffIncludeConfig: ", dic)
-	logrus.Debug("DeclarativeConfig: ", dc)
+func (o *MirrorOptions) verifyOperatorPkgFound(dic action.DiffIncludeConfig, dc *declcfg.DeclarativeConfig) {
+	o.log().Debug("DiffIncludeConfig: ", dic)
+	o.log().Debug("DeclarativeConfig: ", dc)
 
 	dcMap := make(map[string]bool)
 
@@ -291,11 +299,11 @@ func verifyOperatorPkgFound(dic action.DiffIncludeConfig, dc *declcfg.Declarativ
 	}
 
 	for _, pkg := range dic.Packages {
-		logrus.Debug("Checking for package: ", pkg)
+		o.log().Debug("Checking for package: ", pkg)
 
 		if !dcMap[pkg.Name] {
 			// The operator package wasn't found. Log the error and continue on.
-			logrus.Errorf("Operator %s was not found, please check name, startingVersion, and channels in the config file.", pkg.Name)
+			o.log().Errorf("Operator %s was not found, please check name, startingVersion, and channels in the config file.", pkg.Name)
 		}
 	}
 }
@@ -310,10 +318,7 @@ func (o *OperatorOptions) plan(ctx context.Context, dc *declcfg.DeclarativeConfi
 	}
 
 	if !o.SkipImagePin {
-		resolver, err := containerdregistry.NewResolver("", o.SourceSkipTLS, o.SourcePlainHTTP, nil)
-		if err != nil {
-			return nil, fmt.Errorf("error creating image resolver: %v", err)
-		}
+		resolver := o.Clients().Resolver(o.SourceSkipTLS, o.SourcePlainHTTP)
 		if err := o.pinImages(ctx, dc, resolver); err != nil {
 			return nil, fmt.Errorf("error pinning images in catalog %s: %v", ctlgRef, err)
 		}
@@ -384,12 +389,12 @@ func (o *OperatorOptions) plan(ctx context.Context, dc *declcfg.DeclarativeConfi
 		mappings[srcRef] = dstRef
 	}
 
-	return mappings, validateMapping(*dc, mappings)
+	return mappings, o.validateMapping(*dc, mappings)
 }
 
 // validateMapping will search for bundle and related images in mapping
 // and log a warning if an image does not exist and will not be mirrored
-func validateMapping(dc declcfg.DeclarativeConfig, mapping image.TypedImageMapping) error {
+func (o *MirrorOptions) validateMapping(dc declcfg.DeclarativeConfig, mapping image.TypedImageMapping) error {
 	var errs []error
 	validateFunc := func(img string) error {
 		ref, err := image.ParseTypedImage(img, v1alpha2.TypeOperatorBundle)
@@ -398,7 +403,7 @@ func validateMapping(dc declcfg.DeclarativeConfig, mapping image.TypedImageMappi
 		}
 		_, ok := mapping[ref]
 		if !ok {
-			logrus.Warnf("image %s is not included in mapping", img)
+			o.log().Warnf("image %s is not included in mapping", img)
 		}
 		return nil
 	}
@@ -434,15 +439,15 @@ func (o *OperatorOptions) pinImages(ctx context.Context, dc *declcfg.Declarative
 	for i, b := range dc.Bundles {
 
 		if !image.IsImagePinned(b.Image) {
-			logrus.Warnf("bundle %s: pinning bundle image %s to digest", b.Name, b.Image)
+			o.log().Warnf("bundle %s: pinning bundle image %s to digest", b.Name, b.Image)
 
 			if !image.IsImageTagged(b.Image) {
-				logrus.Warnf("bundle %s: bundle image tag not set", b.Name)
+				o.log().Warnf("bundle %s: bundle image tag not set", b.Name)
 				continue
 			}
 			if dc.Bundles[i].Image, err = image.ResolveToPin(ctx, resolver, b.Image); err != nil {
 				if isSkipErr(err) {
-					logrus.Warnf("skipping bundle %s image %s resolve error: %v", b.Name, b.Image, err)
+					o.log().Warnf("skipping bundle %s image %s resolve error: %v", b.Name, b.Image, err)
 				} else {
 					errs = append(errs, err)
 				}
@@ -450,16 +455,16 @@ func (o *OperatorOptions) pinImages(ctx context.Context, dc *declcfg.Declarative
 		}
 		for j, ri := range b.RelatedImages {
 			if !image.IsImagePinned(ri.Image) {
-				logrus.Warnf("bundle %s: pinning related image %s to digest", ri.Name, ri.Image)
+				o.log().Warnf("bundle %s: pinning related image %s to digest", ri.Name, ri.Image)
 
 				if !image.IsImageTagged(ri.Image) {
-					logrus.Warnf("bundle %s: related image tag not set", b.Name)
+					o.log().Warnf("bundle %s: related image tag not set", b.Name)
 					continue
 				}
 
 				if b.RelatedImages[j].Image, err = image.ResolveToPin(ctx, resolver, ri.Image); err != nil {
 					if isSkipErr(err) {
-						logrus.Warnf("skipping bundle %s related image %s=%s resolve error: %v", b.Name, ri.Name, ri.Image, err)
+						o.log().Warnf("skipping bundle %s related image %s=%s resolve error: %v", b.Name, ri.Name, ri.Image, err)
 					} else {
 						errs = append(errs, err)
 					}
@@ -490,7 +495,7 @@ func (o *OperatorOptions) writeLayout(ctx context.Context, ctlgRef imgreference.
 	if err != nil {
 		return err
 	}
-	desc, err := remote.Get(ref, getRemoteOpts(ctx, o.insecure)...)
+	desc, err := remote.Get(ref, o.getRemoteOpts(ctx, o.insecure)...)
 	if err != nil {
 		return err
 	}
@@ -556,7 +561,7 @@ func (o *OperatorOptions) writeDC(dc *declcfg.DeclarativeConfig, ctlgRef imgrefe
 
 func (o *OperatorOptions) newMirrorCatalogOptions(ctlgRef imgreference.DockerImageReference, fileDir string) (*catalog.MirrorCatalogOptions, error) {
 
-	opts := catalog.NewMirrorCatalogOptions(o.IOStreams)
+	opts := catalog.NewMirrorCatalogOptions(o.streams())
 	opts.DryRun = o.DryRun
 	opts.FileDir = fileDir
 	opts.MaxPathComponents = 2
@@ -572,7 +577,7 @@ func (o *OperatorOptions) newMirrorCatalogOptions(ctlgRef imgreference.DockerIma
 
 	opts.SecurityOptions.Insecure = o.insecure
 
-	regctx, err := image.NewContext(o.SkipVerification)
+	regctx, err := o.Clients().Context(o.SkipVerification)
 	if err != nil {
 		return nil, fmt.Errorf("error creating registry context: %v", err)
 	}
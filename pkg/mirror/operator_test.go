@@ -155,7 +155,8 @@ func TestPinImages(t *testing.T) {
 
 func TestVerifyOperatorPkgFound(t *testing.T) {
 
-	hook := test.NewGlobal()
+	logger, hook := test.NewNullLogger()
+	opts := NewMirrorOptions(WithLogger(logger))
 
 	type testvopf struct {
 		desc        string
@@ -208,7 +209,7 @@ func TestVerifyOperatorPkgFound(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.desc, func(t *testing.T) {
-			verifyOperatorPkgFound(c.dic, c.dc)
+			opts.verifyOperatorPkgFound(c.dic, c.dc)
 
 			assert.Equal(t, c.logCount, len(hook.AllEntries()))
 			if c.logCount > 0 && len(hook.Entries) > 0 {
@@ -0,0 +1,467 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+// MirrorOptions configure a run. Create them with NewMirrorOptions
+// to set the default values of the options.
+type MirrorOptions struct {
+	// Dir is the workspace directory of the run
+	Dir string
+	// Out and ErrOut receive the output of the run
+	Out    io.Writer
+	ErrOut io.Writer
+	// JSONLogs logs the output of the oc libraries as structured
+	// entries, for loggers writing JSON
+	JSONLogs         bool
+	OutputDir        string
+	ConfigPath       string
+	SkipImagePin     bool
+	ManifestsOnly    bool
+	From             string
+	ToMirror         string
+	UserNamespace    string
+	DryRun           bool
+	SourceSkipTLS    bool
+	DestSkipTLS      bool
+	SourcePlainHTTP  bool
+	DestPlainHTTP    bool
+	SkipVerification bool
+	SkipCleanup      bool
+	SkipMissing      bool
+	ContinueOnError  bool
+	IgnoreHistory    bool
+	FilterOptions    []string
+	MaxPerRegistry   int
+	// MaxConcurrentDownloads is the number of blobs
+	// downloaded concurrently when mirroring to disk
+	MaxConcurrentDownloads int
+	// InsecureRegistries are the registries the insecure options
+	// apply to, in addition to those marked insecure in the configuration
+	InsecureRegistries []string
+	// PushSigstoreSignatures signs the release images that have release
+	// signatures with cosign and pushes the signatures to the destination
+	// registry with the sigstore attached tag convention
+	PushSigstoreSignatures bool
+	// MirrorSigstoreArtifacts mirrors the cosign signatures,
+	// attestations, SBOMs, and OCI referrers of the images
+	MirrorSigstoreArtifacts bool
+	// ReleaseSignatureKeys are the paths of the GPG public keys
+	// the release signatures in the archives are verified with
+	ReleaseSignatureKeys []string
+	// CosignKey is the path to the cosign private key
+	// signing the catalog and graph images oc-mirror builds
+	CosignKey string
+	// CosignKeyless signs the catalog and graph images oc-mirror
+	// builds with a short-lived certificate issued by Fulcio
+	CosignKeyless bool
+	// CosignIdentityToken is the path to the OIDC identity
+	// token the keyless signing certificate is issued for
+	CosignIdentityToken string
+	// FulcioURL is the Fulcio instance issuing keyless signing certificates
+	FulcioURL string
+	// RekorURL is the Rekor transparency log recording keyless signatures
+	RekorURL string
+	// SignatureBundle is a local directory or tar archive
+	// containing release signatures
+	SignatureBundle string
+	// ApplySignatures applies the release signature
+	// ConfigMaps to the cluster after publishing
+	ApplySignatures bool
+	// MaxICSPSize is the maximum size in bytes of
+	// a generated ImageContentSourcePolicy
+	MaxICSPSize int
+	// MaxICSPMirrors is the maximum number of repository digest
+	// mirrors in a generated ImageContentSourcePolicy
+	MaxICSPMirrors int
+	// ICSPGrouping is the strategy used to group
+	// mirrors into ImageContentSourcePolicies
+	ICSPGrouping string
+	// ICSPScope is the scope of the generated mirrors
+	// (registry, namespace, repository)
+	ICSPScope string
+	// ICSPNamePrefix is prepended to the names of the
+	// generated ImageContentSourcePolicies
+	ICSPNamePrefix string
+	// Kustomize writes a kustomization.yaml referencing
+	// the generated manifests to the results directory
+	Kustomize bool
+	// ContainersPolicy writes a containers policy.json and registries.d
+	// configuration for the mirrored releases to the results directory
+	ContainersPolicy bool
+	// PolicyKeyPath is the release key path used in the containers policy
+	PolicyKeyPath string
+	// SignatureLookaside is the signature lookaside URL
+	// used in the registries.d configuration
+	SignatureLookaside string
+	// RegistriesConf writes a registries.conf drop-in equivalent
+	// to the generated ImageContentSourcePolicies
+	RegistriesConf bool
+	// Apply applies the generated manifests and release
+	// signature ConfigMaps to the cluster after publishing
+	Apply bool
+	// ApplyDryRun previews the apply with a server-side dry run
+	ApplyDryRun bool
+	// LegacyOutput writes the image mapping and manifests in the
+	// formats of oc adm catalog mirror and oc adm release mirror
+	LegacyOutput bool
+	// ClusterOverlays are name=registry[/namespace] clusters
+	// to write per-cluster variants of the manifests for
+	ClusterOverlays []string
+	// SignResultsKey is the path of the private key used to sign
+	// the checksums and provenance of the results directory
+	SignResultsKey string
+	// Provenance writes an in-toto provenance statement of
+	// the archives and rebuilt images to the results directory
+	Provenance bool
+	// Quiet suppresses per-blob output and info logs
+	// and prints a summary table at the end of the run
+	Quiet bool
+	// OutputEvents is the path of the file to write run events to
+	// as newline delimited JSON, or "-" for stdout
+	OutputEvents string
+	// ProgressInterval is the interval between progress
+	// reports of long running phases, 0 disables them
+	ProgressInterval time.Duration
+	// MetricsTextfile is the path of the file to write run
+	// metrics to for the node_exporter textfile collector
+	MetricsTextfile string
+	// MetricsAddr is the address to serve run metrics on
+	MetricsAddr string
+	// MetricsLinger is how long to serve metrics after the run completes
+	MetricsLinger time.Duration
+	// SizeReport writes a report of the size of each mirrored image
+	SizeReport bool
+	// SizeReportSort is the sort order of the size report
+	SizeReportSort string
+	// TraceExporter is where to export trace spans of the run phases
+	TraceExporter string
+	// RunLogMaxSize is the size of the debug log of the run
+	// in the results directory before it is rotated
+	RunLogMaxSize string
+	// RunLogBackups is the number of rotated run logs kept
+	RunLogBackups int
+	// ResultsDirTemplate is the name template of the results directory of the run
+	ResultsDirTemplate string
+	// LatestLink links the latest results directory of the workspace to the run
+	LatestLink bool
+	// CacheDir is the blob cache directory shared across runs
+	CacheDir string
+	// CacheMaxSize is the size the blob cache is pruned to
+	CacheMaxSize string
+	// Resume continues an interrupted run mirroring to disk
+	// of the same configuration and imageset sequence
+	Resume bool
+	// DeltaRegistry is the mirror registry and namespace whose
+	// blobs are left out of the imageset when mirroring to disk
+	DeltaRegistry string
+	// QuotaCheck is what to do when the imageset may not fit
+	// in the quota of the destination Quay organizations
+	QuotaCheck string
+	// Webhooks are [format=]URL webhooks notified with
+	// the results when the run completes
+	Webhooks []string
+	// UploadURL is the URL of the directory of an artifact
+	// repository the archives are uploaded to when mirroring to disk
+	UploadURL string
+	// EventHandler is called with each event of the run, in order,
+	// when oc-mirror is embedded in another program
+	EventHandler func(v1alpha2.Event)
+	// RemoteConfig is set when the imageset configuration of the run was
+	// submitted remotely instead of given with --config, so its hooks
+	// are not run
+	RemoteConfig bool
+	// logger logs the run, the standard logger when nil
+	logger *logrus.Logger
+	// imageClients are the registry clients of the run,
+	// the clients shared by the process when nil
+	imageClients *image.Clients
+	// cancelCh is a channel listening for command cancellations
+	cancelCh         <-chan struct{}
+	once             sync.Once
+	continuedOnError bool
+	// resultsDir is the results directory of the run
+	resultsDir string
+	// results are the results of the run
+	results *v1alpha2.Results
+	// hooks are the hooks of the imageset configuration of the run
+	hooks []v1alpha2.Hook
+	// resume records the progress of a create run
+	resume *resumeState
+	// failures are the errors skipped during the run
+	failures []v1alpha2.Failure
+	// transfer are the transfer statistics of the run
+	transfer *transferStats
+	// events emits the run events
+	events *eventEmitter
+	// progress tracks the progress of the current phase
+	progress *progressTracker
+	// metrics are the Prometheus metrics of the run
+	metrics *runMetrics
+	// tracer traces the phases of the run
+	tracer *runTracer
+	// phases are the timings of the completed phases of the run
+	phases []v1alpha2.PhaseTiming
+	// rebuiltImages are the catalog and graph images built by the run
+	rebuiltImages image.TypedImageMapping
+}
+
+// defaultDir is the default workspace directory
+const defaultDir = "oc-mirror-workspace"
+
+// Option configures the options of a run
+type Option func(*MirrorOptions)
+
+// WithLogger logs the run with the logger
+func WithLogger(logger *logrus.Logger) Option {
+	return func(o *MirrorOptions) {
+		o.logger = logger
+	}
+}
+
+// WithOutput writes the output of the run to w, and logs
+// the run to w unless a logger is set with WithLogger
+func WithOutput(w io.Writer) Option {
+	return func(o *MirrorOptions) {
+		o.Out = w
+		o.ErrOut = w
+	}
+}
+
+// WithClients uses the registry clients for the run,
+// which are configured with the registries of the run
+func WithClients(clients *image.Clients) Option {
+	return func(o *MirrorOptions) {
+		o.imageClients = clients
+	}
+}
+
+// NewMirrorOptions returns the options of a run with their default values.
+// Without options, the output of the run is discarded and the run has
+// its own logger and registry clients.
+func NewMirrorOptions(opts ...Option) *MirrorOptions {
+	o := &MirrorOptions{
+		Dir:                defaultDir,
+		Out:                ioutil.Discard,
+		ErrOut:             ioutil.Discard,
+		FulcioURL:          defaultFulcioURL,
+		RekorURL:           defaultRekorURL,
+		MaxICSPSize:        icspSizeLimit,
+		ICSPGrouping:       categoryICSPGrouping,
+		ICSPScope:          namespaceICSPScope,
+		PolicyKeyPath:      defaultPolicyKeyPath,
+		ProgressInterval:   defaultProgressInterval,
+		SizeReportSort:     sortBySize,
+		QuotaCheck:         quotaCheckWarn,
+		RunLogMaxSize:      defaultRunLogMaxSize,
+		RunLogBackups:      defaultRunLogBackups,
+		ResultsDirTemplate: defaultResultsDirTemplate,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.logger == nil {
+		o.logger = logrus.New()
+		o.logger.SetOutput(o.ErrOut)
+	}
+	if o.imageClients == nil {
+		o.imageClients = image.NewClients()
+	}
+	return o
+}
+
+// log returns the logger of the run. Options not created
+// with NewMirrorOptions log to the standard logger.
+func (o *MirrorOptions) log() *logrus.Logger {
+	if o == nil || o.logger == nil {
+		return logrus.StandardLogger()
+	}
+	return o.logger
+}
+
+// Clients returns the registry clients of the run. Options not
+// created with NewMirrorOptions use the shared registry clients.
+func (o *MirrorOptions) Clients() *image.Clients {
+	if o.imageClients == nil {
+		return image.SharedClients()
+	}
+	return o.imageClients
+}
+
+func (o *MirrorOptions) BindFlags(fs *pflag.FlagSet) {
+	fs.StringVarP(&o.ConfigPath, "config", "c", o.ConfigPath, "Path to imageset configuration file")
+	fs.BoolVar(&o.SkipImagePin, "skip-image-pin", o.SkipImagePin, "Do not replace image tags with digest pins in operator catalogs")
+	fs.StringVar(&o.From, "from", o.From, "The path to an input file (e.g. archived imageset)")
+	fs.BoolVar(&o.ManifestsOnly, "manifests-only", o.ManifestsOnly, "Generate manifests and do not mirror")
+	fs.BoolVar(&o.DryRun, "dry-run", o.DryRun, "Plan the images and write their mapping without mirroring them, "+
+		"and when mirroring to disk, the metadata of the imageset")
+	fs.BoolVar(&o.SourceSkipTLS, "source-skip-tls", o.SourceSkipTLS, "Disable TLS validation for source registry")
+	fs.BoolVar(&o.DestSkipTLS, "dest-skip-tls", o.DestSkipTLS, "Disable TLS validation for destination registry")
+	fs.BoolVar(&o.SourcePlainHTTP, "source-use-http", o.SourcePlainHTTP, "Use plain HTTP for source registry")
+	fs.BoolVar(&o.DestPlainHTTP, "dest-use-http", o.DestPlainHTTP, "Use plain HTTP for destination registry")
+	fs.StringSliceVar(&o.InsecureRegistries, "insecure-registry", o.InsecureRegistries, "Registry the insecure options apply to, "+
+		"in addition to the registries marked insecure in the configuration (can be repeated)")
+	fs.BoolVar(&o.SkipVerification, "skip-verification", o.SkipVerification, "Skip digest verification")
+	fs.BoolVar(&o.SkipCleanup, "skip-cleanup", o.SkipCleanup, "Skip removal of artifact directories")
+	fs.BoolVar(&o.IgnoreHistory, "ignore-history", o.IgnoreHistory, "Ignores past mirrors when downloading images and packing layers")
+	fs.StringSliceVar(&o.FilterOptions, "filter-by-os", o.FilterOptions, "A regular expression to control which release image is picked when multiple variants are available")
+	fs.BoolVar(&o.ContinueOnError, "continue-on-error", o.ContinueOnError, "If an error occurs, keep going "+
+		"and attempt to mirror as much as possible")
+	fs.BoolVar(&o.SkipMissing, "skip-missing", o.SkipMissing, "If an input image is not found, skip them. "+
+		"404/NotFound errors encountered while pulling images explicitly specified in the config "+
+		"will not be skipped")
+	fs.IntVar(&o.MaxPerRegistry, "max-per-registry", o.MaxPerRegistry, fmt.Sprintf("Number of concurrent requests allowed per registry (default %d). "+
+		"When mirroring to disk, it is used instead of --max-concurrent-downloads unless that flag is set", defaultMaxPerRegistry))
+	fs.IntVar(&o.MaxConcurrentDownloads, "max-concurrent-downloads", o.MaxConcurrentDownloads, fmt.Sprintf("Number of blobs "+
+		"downloaded concurrently when mirroring to disk, across all source registries (default %d, max %d). "+
+		"Also bounds the images of each source registry associated concurrently", defaultMaxConcurrentDownloads, maxConcurrentDownloads))
+	fs.BoolVar(&o.PushSigstoreSignatures, "push-sigstore-signatures", o.PushSigstoreSignatures, "Sign the release images "+
+		"that have release signatures with --cosign-key or --cosign-keyless and push the cosign signatures to the "+
+		"destination registry using the sigstore attached tag convention (sha256-<digest>.sig)")
+	fs.BoolVar(&o.MirrorSigstoreArtifacts, "mirror-sigstore-artifacts", o.MirrorSigstoreArtifacts, "Mirror the cosign "+
+		"signatures, attestations, and SBOMs (sha256-<digest>.sig, .att, and .sbom tags) and the OCI referrers of the images")
+	fs.StringArrayVar(&o.ReleaseSignatureKeys, "release-signature-key", o.ReleaseSignatureKeys, "Path to an armored or "+
+		"binary GPG public key the release signatures in the archives must be signed with when publishing. Can be repeated")
+	fs.StringVar(&o.CosignKey, "cosign-key", o.CosignKey, "Path to a cosign private key used to sign the catalog "+
+		"and graph images built when publishing, and the release images with --push-sigstore-signatures. "+
+		"Encrypted keys are decrypted with the password in COSIGN_PASSWORD")
+	fs.BoolVar(&o.CosignKeyless, "cosign-keyless", o.CosignKeyless, "Sign the catalog and graph images built when "+
+		"publishing with a short-lived certificate issued by Fulcio for an OIDC identity token, and record the "+
+		"signatures in the Rekor transparency log")
+	fs.StringVar(&o.CosignIdentityToken, "cosign-identity-token", o.CosignIdentityToken, "Path to the OIDC identity "+
+		"token used by --cosign-keyless. Defaults to the token in "+sigstoreIDTokenEnv)
+	fs.StringVar(&o.FulcioURL, "fulcio-url", o.FulcioURL, "Fulcio instance issuing the --cosign-keyless certificates")
+	fs.StringVar(&o.RekorURL, "rekor-url", o.RekorURL, "Rekor transparency log recording the --cosign-keyless signatures")
+	fs.StringVar(&o.SignatureBundle, "signature-bundle", o.SignatureBundle, "Path to a directory or tar archive of "+
+		"release signatures (sha256=<digest>/signature-<n>) to use when the signature stores are unreachable")
+	fs.BoolVar(&o.ApplySignatures, "apply-signatures", o.ApplySignatures, "Apply the release signature ConfigMaps "+
+		"to the cluster configured by --kubeconfig after publishing")
+	fs.IntVar(&o.MaxICSPSize, "max-icsp-size", o.MaxICSPSize, "Maximum size in bytes of a generated ImageContentSourcePolicy")
+	fs.IntVar(&o.MaxICSPMirrors, "max-icsp-mirrors", o.MaxICSPMirrors, "Maximum number of repository digest mirrors "+
+		"in a generated ImageContentSourcePolicy (0 for no limit)")
+	fs.StringVar(&o.ICSPGrouping, "icsp-grouping", o.ICSPGrouping, "Strategy used to group mirrors into "+
+		"ImageContentSourcePolicies (category, registry)")
+	fs.StringVar(&o.ICSPScope, "icsp-scope", o.ICSPScope, "Scope of the generated ImageContentSourcePolicy mirrors "+
+		"(registry, namespace, repository). Release images are always mirrored at repository scope")
+	fs.BoolVar(&o.Kustomize, "kustomize", o.Kustomize, "Write a kustomization.yaml referencing the generated manifests "+
+		"and release signatures to the results directory")
+	fs.BoolVar(&o.ContainersPolicy, "containers-policy", o.ContainersPolicy, "Write a containers policy.json and registries.d "+
+		"configuration enforcing release signatures for the mirrored releases to the results directory")
+	fs.StringVar(&o.PolicyKeyPath, "policy-key-path", o.PolicyKeyPath, "Path of the release signing key on the hosts "+
+		"using the containers policy")
+	fs.StringVar(&o.SignatureLookaside, "signature-lookaside", o.SignatureLookaside, "Signature lookaside URL "+
+		"for the mirrored releases in the registries.d configuration")
+	fs.BoolVar(&o.RegistriesConf, "registries-conf", o.RegistriesConf, "Write a registries.conf drop-in equivalent to "+
+		"the generated ImageContentSourcePolicies for hosts outside of the cluster")
+	fs.BoolVar(&o.Apply, "apply", o.Apply, "Apply the generated manifests and release signature ConfigMaps "+
+		"to the cluster configured by --kubeconfig after publishing using server-side apply")
+	fs.BoolVar(&o.ApplyDryRun, "apply-dry-run", o.ApplyDryRun, "Preview --apply with a server-side dry run")
+	fs.BoolVar(&o.LegacyOutput, "legacy-output", o.LegacyOutput, "Write the image mapping, ImageContentSourcePolicy, "+
+		"and install-config imageContentSources in the formats of oc adm catalog mirror and oc adm release mirror")
+	fs.BoolVarP(&o.Quiet, "quiet", "q", o.Quiet, "Suppress per-blob output and info logs "+
+		"and print a summary of the run when it completes")
+	fs.StringVar(&o.OutputEvents, "output-events", o.OutputEvents, "Write structured run events as newline "+
+		"delimited JSON to this file, or to stdout with \"-\"")
+	fs.DurationVar(&o.ProgressInterval, "progress-interval", o.ProgressInterval, "Interval between progress reports "+
+		"of the download, archive, unpack, and push phases. Set to 0 to disable progress reports")
+	fs.StringVar(&o.MetricsTextfile, "metrics-textfile", o.MetricsTextfile, "Write run metrics in the Prometheus "+
+		"text format to this file for the node_exporter textfile collector (e.g. /var/lib/node_exporter/oc-mirror.prom)")
+	fs.StringVar(&o.MetricsAddr, "metrics-addr", o.MetricsAddr, "Serve run metrics at /metrics on this address during the run (e.g. :9090)")
+	fs.DurationVar(&o.MetricsLinger, "metrics-linger", o.MetricsLinger, "How long to keep serving metrics "+
+		"after the run completes so the final values can be scraped")
+	fs.BoolVar(&o.SizeReport, "size-report", o.SizeReport, "Write a report of the layer count and compressed and "+
+		"uncompressed size of each image in the imageset to the results directory")
+	fs.StringVar(&o.SizeReportSort, "size-report-sort", o.SizeReportSort, "Sort order of the size report: "+
+		"size (compressed, largest first), uncompressed, layers, or name")
+	fs.StringVar(&o.TraceExporter, "trace-exporter", o.TraceExporter, "Export OpenTelemetry spans of the run phases "+
+		"to an OTLP gRPC collector (otlp://host:port, or otlps://host:port for TLS) or as newline delimited JSON to a file (file://path)")
+	fs.StringVar(&o.CacheDir, "cache-dir", o.CacheDir, "Reuse the blobs downloaded by previous runs from this "+
+		"content-addressed blob cache directory when mirroring to disk, and add the downloaded blobs to it")
+	fs.StringVar(&o.CacheMaxSize, "cache-max-size", o.CacheMaxSize, "Size the blob cache is pruned to after each run, "+
+		"evicting the least recently used blobs first (e.g. 200GiB). The cache is not pruned if unset")
+	fs.BoolVar(&o.Resume, "resume", o.Resume, "Continue an interrupted run mirroring the same configuration to disk, "+
+		"skipping the images in its complete archives and reusing the blobs it downloaded")
+	fs.StringVar(&o.DeltaRegistry, "delta-registry", o.DeltaRegistry, "When mirroring to disk, leave the blobs this mirror "+
+		"registry already has out of the imageset (docker://registry[/namespace], the destination the imageset will be published to). "+
+		"The registry must be reachable when creating the imageset, and publishing fetches the left out blobs from it")
+	fs.StringVar(&o.QuotaCheck, "quota-check", o.QuotaCheck, "When publishing to Quay organizations with storage quotas, "+
+		"warn or fail before pushing if the imageset may not fit in the remaining quota: warn, fail, or skip. "+
+		"The Quay API is queried with the OAuth token in the "+quayAPITokenEnv+" environment variable")
+	fs.StringVar(&o.RunLogMaxSize, "run-log-max-size", o.RunLogMaxSize, "Size of the debug log written to "+
+		"the results directory of each run before it is rotated (e.g. 100MiB)")
+	fs.IntVar(&o.RunLogBackups, "run-log-backups", o.RunLogBackups, "Number of rotated debug logs of the run to keep")
+	fs.StringVar(&o.ResultsDirTemplate, "results-dir-template", o.ResultsDirTemplate, "Go template of the name of "+
+		"the results directory of the run in the workspace, using {{.Timestamp}}, {{.Time}}, {{.Sequence}}, {{.Config}}, "+
+		"and {{.Operation}} (e.g. '{{.Config}}-{{.Sequence}}')")
+	fs.BoolVar(&o.LatestLink, "latest", o.LatestLink, "Point the latest symbolic link in the workspace "+
+		"to the results directory of the run once its results are written")
+	fs.StringArrayVar(&o.Webhooks, "webhook", o.Webhooks, "Post the run results to this URL when the run completes or fails. "+
+		"Prefix the URL with slack= to post a Slack message instead of the results JSON; "+
+		"Slack incoming webhook URLs are detected automatically. Can be repeated")
+	fs.StringVar(&o.UploadURL, "upload-url", o.UploadURL, "After creating the imageset archives, upload them with "+
+		"their checksums and table of contents to this URL of a directory of a Nexus or Artifactory raw repository, or of "+
+		"another server accepting HTTP PUT (e.g. https://nexus.example.com/repository/mirror/ocp). Credentials are read from the "+
+		uploadUsernameEnv+" and "+uploadPasswordEnv+", or "+uploadTokenEnv+" environment variables")
+	fs.StringVar(&o.SignResultsKey, "sign-results-key", o.SignResultsKey, "Path to an unencrypted PGP private key "+
+		"used to sign the checksums and provenance of the results directory")
+	fs.BoolVar(&o.Provenance, "provenance", o.Provenance, "Write an in-toto SLSA provenance statement of the imageset "+
+		"archives and rebuilt images, recording the imageset configuration and source image digests, to the results directory")
+	fs.StringArrayVar(&o.ClusterOverlays, "cluster-overlay", o.ClusterOverlays, "Write a variant of the generated manifests "+
+		"for a cluster that reaches the mirror through another registry host or namespace (name=registry[/namespace]). Can be repeated")
+	fs.StringVar(&o.ICSPNamePrefix, "icsp-name-prefix", o.ICSPNamePrefix, "Prefix for the names of the generated ImageContentSourcePolicies")
+
+	// TODO(jpower432): Make this flag visible again once release architecture selection
+	// has been more thouroughly vetted
+	if err := fs.MarkHidden("filter-by-os"); err != nil {
+		o.log().Panic(err.Error())
+	}
+}
+
+func (o *MirrorOptions) init() {
+	o.cancelCh = makeCancelCh(syscall.SIGINT, syscall.SIGTERM)
+}
+
+// CancelContext will return a cancellable context and listen for
+// cancellation signals
+func (o *MirrorOptions) CancelContext(parent context.Context) (context.Context, context.CancelFunc) {
+	o.once.Do(o.init)
+	ctx, cancel := context.WithCancel(parent)
+	go func() {
+		select {
+		case <-o.cancelCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// makeCancelCh creates an interrupt listener for os signals
+// and will send a message on a returned channel
+func makeCancelCh(signals ...os.Signal) <-chan struct{} {
+	resultCh := make(chan struct{})
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, signals...)
+	go func() {
+		for {
+			<-signalCh
+			resultCh <- struct{}{}
+		}
+	}()
+	return resultCh
+}
@@ -0,0 +1,107 @@
+package mirror
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/openshift/oc-mirror/pkg/config"
+)
+
+// clustersDir is the results subdirectory for per-cluster manifests
+const clustersDir = "clusters"
+
+// clusterOverlay is a cluster served by the mirror through
+// its own registry host and namespace
+type clusterOverlay struct {
+	name   string
+	mirror string
+}
+
+// parseClusterOverlays parses name=registry[/namespace] cluster overlay specifications
+func parseClusterOverlays(specs []string) ([]clusterOverlay, error) {
+	var overlays []clusterOverlay
+	seen := map[string]struct{}{}
+	for _, spec := range specs {
+		split := strings.SplitN(spec, "=", 2)
+		if len(split) != 2 || split[0] == "" || split[1] == "" {
+			return nil, fmt.Errorf("cluster overlay %q must be in the form name=registry[/namespace]", spec)
+		}
+		name, mirror := split[0], strings.TrimSuffix(split[1], "/")
+		if errs := validation.IsDNS1123Label(name); len(errs) != 0 {
+			return nil, fmt.Errorf("cluster overlay name %q: %s", name, strings.Join(errs, ", "))
+		}
+		if _, found := seen[name]; found {
+			return nil, fmt.Errorf("cluster overlay %q: duplicate found", name)
+		}
+		seen[name] = struct{}{}
+		overlays = append(overlays, clusterOverlay{name: name, mirror: mirror})
+	}
+	return overlays, nil
+}
+
+// writeClusterOverlays writes a variant of the manifests in dir for each cluster overlay
+// to dir/clusters/<name>, with the mirror registry replaced by the registry of the cluster.
+// Release signatures in sigDir are included as is.
+func (o *MirrorOptions) writeClusterOverlays(dir, sigDir string) error {
+	overlays, err := parseClusterOverlays(o.ClusterOverlays)
+	if err != nil {
+		return err
+	}
+	mirror := path.Join(o.ToMirror, o.UserNamespace)
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	manifests := map[string][]byte{}
+	for _, file := range files {
+		if file.IsDir() || file.Name() == kustomizationFile || file.Name() == resultsFile || file.Name() == configSnapshotFile {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(file.Name())) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return err
+		}
+		manifests[file.Name()] = data
+	}
+
+	for _, overlay := range overlays {
+		clusterDir := filepath.Join(dir, clustersDir, overlay.name)
+		if err := os.MkdirAll(clusterDir, 0750); err != nil {
+			return err
+		}
+		for name, data := range manifests {
+			if err := ioutil.WriteFile(filepath.Join(clusterDir, name), replaceMirror(data, mirror, overlay.mirror), 0640); err != nil {
+				return fmt.Errorf("error writing manifests for cluster %s: %v", overlay.name, err)
+			}
+		}
+		if o.Kustomize {
+			if err := o.writeKustomization(clusterDir, sigDir); err != nil {
+				return err
+			}
+		} else if err := copySignatureConfigMaps(sigDir, filepath.Join(clusterDir, config.ReleaseSignatureDir)); err != nil {
+			return err
+		}
+		o.log().Infof("Wrote manifests for cluster %s to %s", overlay.name, clusterDir)
+	}
+	return nil
+}
+
+// replaceMirror replaces the image references under the mirror with the cluster mirror
+func replaceMirror(data []byte, mirror, clusterMirror string) []byte {
+	// Only match whole path components of the mirror
+	re := regexp.MustCompile(`(^|[\s"'=])` + regexp.QuoteMeta(mirror) + `([/@:"'\s]|$)`)
+	return re.ReplaceAll(data, []byte("${1}"+clusterMirror+"${2}"))
+}
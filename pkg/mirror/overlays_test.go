@@ -0,0 +1,111 @@
+package mirror
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/config"
+)
+
+func TestParseClusterOverlays(t *testing.T) {
+	tests := []struct {
+		name     string
+		specs    []string
+		exp      []clusterOverlay
+		expError string
+	}{
+		{
+			name:  "Valid/RegistryAndNamespace",
+			specs: []string{"edge-1=edge-1.mirror.com/fleet/", "edge-2=reg.mirror.com"},
+			exp: []clusterOverlay{
+				{name: "edge-1", mirror: "edge-1.mirror.com/fleet"},
+				{name: "edge-2", mirror: "reg.mirror.com"},
+			},
+		},
+		{
+			name:     "Invalid/MissingMirror",
+			specs:    []string{"edge-1="},
+			expError: `cluster overlay "edge-1=" must be in the form name=registry[/namespace]`,
+		},
+		{
+			name:     "Invalid/Name",
+			specs:    []string{"Edge_1=reg.mirror.com"},
+			expError: `cluster overlay name "Edge_1"`,
+		},
+		{
+			name:     "Invalid/Duplicate",
+			specs:    []string{"edge-1=reg.mirror.com", "edge-1=other.mirror.com"},
+			expError: `cluster overlay "edge-1": duplicate found`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			overlays, err := parseClusterOverlays(test.specs)
+			if test.expError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), test.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.exp, overlays)
+		})
+	}
+}
+
+func TestWriteClusterOverlays(t *testing.T) {
+	icsp := `spec:
+  repositoryDigestMirrors:
+  - mirrors:
+    - reg.mirror.com/fleet/ubi8/ubi
+    source: registry.redhat.io/ubi8/ubi
+  - mirrors:
+    - reg.mirror.com/fleetother/ubi8/ubi
+    source: registry.redhat.io/ubi8/ubi-minimal
+`
+	catalog := `spec:
+  image: "reg.mirror.com/fleet/redhat/redhat-operator-index:v4.9"
+`
+	expICSP := `spec:
+  repositoryDigestMirrors:
+  - mirrors:
+    - edge.mirror.com/edge-1/ubi8/ubi
+    source: registry.redhat.io/ubi8/ubi
+  - mirrors:
+    - reg.mirror.com/fleetother/ubi8/ubi
+    source: registry.redhat.io/ubi8/ubi-minimal
+`
+	expCatalog := `spec:
+  image: "edge.mirror.com/edge-1/redhat/redhat-operator-index:v4.9"
+`
+
+	dir := t.TempDir()
+	sigDir := filepath.Join(dir, config.ReleaseSignatureDir)
+	require.NoError(t, os.MkdirAll(sigDir, 0750))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sigDir, "signature-1.json"), []byte("{}"), 0640))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "imageContentSourcePolicy.yaml"), []byte(icsp), 0640))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "catalogSource-redhat-operator-index.yaml"), []byte(catalog), 0640))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "mapping.txt"), []byte("a=b"), 0640))
+
+	opts := &MirrorOptions{
+		ToMirror:        "reg.mirror.com",
+		UserNamespace:   "fleet",
+		ClusterOverlays: []string{"edge-1=edge.mirror.com/edge-1"},
+		Kustomize:       true,
+	}
+	require.NoError(t, opts.writeClusterOverlays(dir, sigDir))
+
+	clusterDir := filepath.Join(dir, clustersDir, "edge-1")
+	data, err := ioutil.ReadFile(filepath.Join(clusterDir, "imageContentSourcePolicy.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, expICSP, string(data))
+	data, err = ioutil.ReadFile(filepath.Join(clusterDir, "catalogSource-redhat-operator-index.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, expCatalog, string(data))
+	require.NoFileExists(t, filepath.Join(clusterDir, "mapping.txt"))
+	require.FileExists(t, filepath.Join(clusterDir, config.ReleaseSignatureDir, "signature-1.json"))
+	require.FileExists(t, filepath.Join(clusterDir, kustomizationFile))
+}
@@ -8,8 +8,6 @@ import (
 	"path/filepath"
 	"time"
 
-	"github.com/sirupsen/logrus"
-
 	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
 	"github.com/openshift/oc-mirror/pkg/archive"
 	"github.com/openshift/oc-mirror/pkg/bundle"
@@ -42,7 +40,7 @@ func (o *MirrorOptions) Pack(ctx context.Context, prevAssocs, currAssocs image.A
 	cfg := v1alpha2.StorageConfig{
 		Local: &v1alpha2.LocalConfig{Path: tmpdir},
 	}
-	tmpBackend, err := storage.ByConfig(tmpdir, cfg)
+	tmpBackend, err := storage.ByConfig(tmpdir, cfg, o.Clients())
 	if err != nil {
 		return nil, err
 	}
@@ -56,13 +54,25 @@ func (o *MirrorOptions) Pack(ctx context.Context, prevAssocs, currAssocs image.A
 	if !o.IgnoreHistory {
 		reconcileAssociation = prevAssocs
 	}
-	manifests, blobs, err := bundle.ReconcileV2Dir(reconcileAssociation, paths)
+	var registryBlobs map[string]struct{}
+	if o.DeltaRegistry != "" {
+		o.log().Infof("Checking the blobs of the imageset in the delta registry %s", o.DeltaRegistry)
+		registryBlobs, err = o.registryBlobs(ctx, currAssocs)
+		if err != nil {
+			return tmpBackend, err
+		}
+	}
+	manifests, blobs, stats, err := bundle.ReconcileV2DirExcluding(reconcileAssociation, registryBlobs, paths)
 	if err != nil {
 		return tmpBackend, fmt.Errorf("error reconciling v2 files: %v", err)
 	}
+	o.transfer.reconciled(stats)
 
-	// Stop the process if no new blobs
-	if len(blobs) == 0 {
+	// Stop the process if no new blobs. Images with all their blobs
+	// in the delta registry still need their manifests archived, and
+	// resumed runs may have archived all the blobs already.
+	resumed := o.resume != nil && len(o.resume.Archives) != 0
+	if len(blobs) == 0 && stats.RegistryBlobs == 0 && !resumed {
 		return tmpBackend, ErrNoUpdatesExist
 	}
 
@@ -76,10 +86,16 @@ func (o *MirrorOptions) Pack(ctx context.Context, prevAssocs, currAssocs image.A
 	if err != nil {
 		return tmpBackend, err
 	}
-	if err := metadata.UpdateMetadata(ctx, tmpBackend, meta, filepath.Join(o.Dir, config.SourceDir), o.SourceSkipTLS, o.SourcePlainHTTP); err != nil {
+	if err := metadata.UpdateMetadata(ctx, o.Clients(), tmpBackend, meta, filepath.Join(o.Dir, config.SourceDir), o.SourceSkipTLS, o.SourcePlainHTTP); err != nil {
 		return tmpBackend, err
 	}
 
+	if o.resume != nil {
+		if err := o.resume.setAssociations(currAssocs); err != nil {
+			return tmpBackend, err
+		}
+	}
+
 	if err := o.prepareArchive(ctx, tmpBackend, archiveSize, meta.PastMirror.Sequence, manifests, blobs); err != nil {
 		return tmpBackend, err
 	}
@@ -99,7 +115,7 @@ func (o *MirrorOptions) prepareArchive(ctx context.Context, backend storage.Back
 	segSize := defaultSegSize
 	if archiveSize != 0 {
 		segSize = archiveSize
-		logrus.Debugf("Using user provided archive size %d GiB", segSize)
+		o.log().Debugf("Using user provided archive size %d GiB", segSize)
 	}
 	segSize *= segMultiplier
 
@@ -122,6 +138,19 @@ func (o *MirrorOptions) prepareArchive(ctx context.Context, backend storage.Back
 	defer os.Chdir(cwd)
 
 	packager := archive.NewPackager(manifests, blobs)
+	if o.progress != nil {
+		// The files to archive are a subset of the source directory
+		size, err := dirSize(".")
+		if err != nil {
+			return err
+		}
+		o.progress.addTotal(size)
+		packager.SetProgress(o.progress.add)
+	}
+	if o.resume != nil {
+		packager.Resume(len(o.resume.Archives), o.resume.Blobs)
+		packager.SetSplitDone(o.resume.archived)
+	}
 	prefix := fmt.Sprintf("mirror_seq%d", seq)
 	if err := packager.CreateSplitArchive(ctx, backend, segSize, output, ".", prefix, o.SkipCleanup); err != nil {
 		return fmt.Errorf("failed to create archive: %v", err)
@@ -135,7 +164,7 @@ func (o *MirrorOptions) mktempDir() (string, func(), error) {
 	dir := filepath.Join(o.Dir, config.SourceDir, fmt.Sprintf("tmpbackend.%d", time.Now().Unix()))
 	return dir, func() {
 		if err := os.RemoveAll(dir); err != nil {
-			logrus.Error(err)
+			o.log().Error(err)
 		}
 	}, os.MkdirAll(dir, os.ModePerm)
 }
@@ -12,7 +12,6 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
-	"github.com/openshift/oc-mirror/pkg/cli"
 	"github.com/openshift/oc-mirror/pkg/config"
 	"github.com/openshift/oc-mirror/pkg/image"
 )
@@ -30,7 +29,6 @@ func TestPack(t *testing.T) {
 		{
 			desc: "Success/NoUpdates",
 			opts: &MirrorOptions{
-				RootOptions:     &cli.RootOptions{},
 				ContinueOnError: false,
 				SkipMissing:     false,
 			},
@@ -79,7 +77,6 @@ func TestPack(t *testing.T) {
 		{
 			desc: "Success/IgnoreHistory",
 			opts: &MirrorOptions{
-				RootOptions:     &cli.RootOptions{},
 				ContinueOnError: false,
 				SkipMissing:     false,
 				IgnoreHistory:   true,
@@ -130,7 +127,6 @@ func TestPack(t *testing.T) {
 		{
 			desc: "Success/Updates",
 			opts: &MirrorOptions{
-				RootOptions:     &cli.RootOptions{},
 				ContinueOnError: false,
 				SkipMissing:     false,
 			},
@@ -166,7 +162,7 @@ func TestPack(t *testing.T) {
 		t.Run(c.desc, func(t *testing.T) {
 			tmpdir := t.TempDir()
 			path := filepath.Join(tmpdir, config.SourceDir, config.V2Dir)
-			c.opts.RootOptions.Dir = tmpdir
+			c.opts.Dir = tmpdir
 			c.opts.OutputDir = tmpdir
 			err := os.MkdirAll(path, os.ModePerm)
 			require.NoError(t, err)
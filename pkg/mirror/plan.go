@@ -0,0 +1,243 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/openshift/library-go/pkg/image/reference"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/bundle"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+// Plan resolves the imageset configuration into the images it mirrors
+func (o *MirrorOptions) Plan(ctx context.Context, cfg v1alpha2.ImageSetConfiguration) (v1alpha2.ImagePlan, error) {
+	plan, err := o.planWorkspace(ctx, cfg)
+	if err != nil {
+		return v1alpha2.ImagePlan{}, err
+	}
+	return plan.imagePlan(), nil
+}
+
+// EstimatePlan resolves the imageset configuration into the images it
+// mirrors and sums their sizes by category
+func (o *MirrorOptions) EstimatePlan(ctx context.Context, cfg v1alpha2.ImageSetConfiguration) (SizeEstimate, error) {
+	plan, err := o.planWorkspace(ctx, cfg)
+	if err != nil {
+		return SizeEstimate{}, err
+	}
+	return plan.estimate(), nil
+}
+
+// planWorkspace plans the configuration in a temporary workspace
+func (o *MirrorOptions) planWorkspace(ctx context.Context, cfg v1alpha2.ImageSetConfiguration) (imagePlan, error) {
+	if err := o.Clients().SetRegistries(image.MarkInsecure(cfg.Registries, o.InsecureRegistries)); err != nil {
+		return imagePlan{}, err
+	}
+	// Planning writes catalogs and release data to the workspace
+	// and maps images to disk, so both are kept in a temporary directory
+	tmpdir, err := ioutil.TempDir("", "oc-mirror-plan")
+	if err != nil {
+		return imagePlan{}, err
+	}
+	defer os.RemoveAll(tmpdir)
+	o.Dir = tmpdir
+	o.OutputDir = tmpdir
+	if err := bundle.MakeCreateDirs(o.Dir); err != nil {
+		return imagePlan{}, err
+	}
+	return o.plan(ctx, cfg)
+}
+
+// imagePlan is the list of images an imageset configuration resolves to
+type imagePlan struct {
+	Images []plannedImage `json:"images"`
+	// Size is the total size of the unique blobs of the images
+	Size int64 `json:"size"`
+}
+
+// plannedImage is an image of the plan
+type plannedImage struct {
+	Source   string `json:"source"`
+	Digest   string `json:"digest,omitempty"`
+	Category string `json:"category"`
+	Layers   int    `json:"layers"`
+	Size     int64  `json:"size"`
+
+	blobs image.ImageBlobs
+}
+
+// planConfig returns the configuration planned without the
+// content that is downloaded rather than mirrored. The graph
+// image is built on its base image, which is mirrored instead.
+func planConfig(cfg v1alpha2.ImageSetConfiguration) v1alpha2.ImageSetConfiguration {
+	platform := cfg.Mirror.Platform
+	if platform.Graph && len(platform.Channels) != 0 {
+		cfg.Mirror.AdditionalImages = append(cfg.Mirror.AdditionalImages, v1alpha2.Image{Name: getGraphBaseImage(platform)})
+	}
+	cfg.Mirror.Platform.Graph = false
+	cfg.Mirror.Platform.BootImages = v1alpha2.BootImages{}
+	return cfg
+}
+
+// plan resolves the configuration into images, and
+// resolves their digests and sizes from their remote manifests
+func (o *MirrorOptions) plan(ctx context.Context, cfg v1alpha2.ImageSetConfiguration) (imagePlan, error) {
+	planCfg := planConfig(cfg)
+	operatorPlan := func(ctx context.Context, cfg v1alpha2.ImageSetConfiguration) (image.TypedImageMapping, error) {
+		if len(cfg.Mirror.Operators) != 0 {
+			operator := NewOperatorOptions(o)
+			operator.SkipImagePin = o.SkipImagePin
+			return operator.PlanFull(ctx, cfg)
+		}
+		return image.TypedImageMapping{}, nil
+	}
+	mapping, err := o.run(ctx, &planCfg, v1alpha2.NewMetadata(), operatorPlan)
+	if err != nil {
+		return imagePlan{}, err
+	}
+
+	refs := map[string]reference.DockerImageReference{}
+	images := make([]plannedImage, 0, len(mapping))
+	for src := range mapping {
+		name := src.Ref.Exact()
+		if _, found := refs[name]; found {
+			continue
+		}
+		refs[name] = src.Ref
+		images = append(images, plannedImage{Source: name, Digest: src.Ref.ID, Category: src.Category.String()})
+	}
+
+	o.log().Infof("Resolving %d images", len(images))
+	work := make(chan int)
+	g, gctx := errgroup.WithContext(ctx)
+	for i := 0; i < analyzeWorkers; i++ {
+		g.Go(func() error {
+			for i := range work {
+				img := &images[i]
+				ref := refs[img.Source]
+				if ref.ID == "" {
+					resolver := o.Clients().Resolver(o.SourceSkipTLS, o.SourcePlainHTTP)
+					pinned, err := image.ResolveToPin(gctx, resolver, img.Source)
+					if err != nil {
+						o.log().Warnf("unable to resolve the digest of image %s: %v", img.Source, err)
+						continue
+					}
+					pinnedRef, err := reference.Parse(pinned)
+					if err != nil {
+						return fmt.Errorf("error parsing image %s: %v", pinned, err)
+					}
+					ref.ID = pinnedRef.ID
+					img.Digest = ref.ID
+				}
+				blobs, err := o.Clients().GetRemoteImageBlobs(gctx, ref, o.SourceSkipTLS, o.SourcePlainHTTP, o.SkipVerification)
+				if err != nil {
+					o.log().Warnf("unable to get the size of image %s: %v", img.Source, err)
+				}
+				img.blobs = blobs
+			}
+			return nil
+		})
+	}
+	g.Go(func() error {
+		defer close(work)
+		for i := range images {
+			select {
+			case work <- i:
+			case <-gctx.Done():
+				return gctx.Err()
+			}
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return imagePlan{}, err
+	}
+	return newImagePlan(images), nil
+}
+
+// newImagePlan sizes the images and sorts them by category and source.
+// The total size counts blobs shared between images once.
+func newImagePlan(images []plannedImage) imagePlan {
+	allBlobs := image.BlobSizes{}
+	for i := range images {
+		images[i].Layers = len(images[i].blobs.Layers)
+		images[i].Size = images[i].blobs.Size()
+		allBlobs.Add(images[i].blobs)
+	}
+	sort.Slice(images, func(i, j int) bool {
+		if images[i].Category != images[j].Category {
+			return images[i].Category < images[j].Category
+		}
+		return images[i].Source < images[j].Source
+	})
+	return imagePlan{Images: images, Size: allBlobs.Size()}
+}
+
+// imagePlan returns the plan as an ImagePlan
+func (p imagePlan) imagePlan() v1alpha2.ImagePlan {
+	result := v1alpha2.ImagePlan{Images: make([]v1alpha2.PlannedImage, 0, len(p.Images)), Size: p.Size}
+	for _, img := range p.Images {
+		result.Images = append(result.Images, v1alpha2.PlannedImage{
+			Source:   img.Source,
+			Digest:   img.Digest,
+			Category: img.Category,
+			Layers:   img.Layers,
+			Size:     img.Size,
+		})
+	}
+	return result
+}
+
+// SizeEstimate is the estimated size of the images of a plan
+type SizeEstimate struct {
+	Categories []CategorySize `json:"categories"`
+	// Images is the number of images
+	Images int `json:"images"`
+	// Size is the total size of the unique blobs of the images
+	Size int64 `json:"size"`
+	// Unsized is the number of images that could not be sized
+	Unsized int `json:"unsized"`
+}
+
+// CategorySize is the size of the images of a category
+type CategorySize struct {
+	Category string `json:"category"`
+	Images   int    `json:"images"`
+	// Size counts the blobs shared with other categories,
+	// so the sizes of categories add up to more than the total
+	Size int64 `json:"size"`
+}
+
+// estimate sums the sizes of the images by category.
+// Blobs shared between images are counted once.
+func (p imagePlan) estimate() SizeEstimate {
+	est := SizeEstimate{Categories: []CategorySize{}, Images: len(p.Images), Size: p.Size}
+	blobs := map[string]image.BlobSizes{}
+	counts := map[string]int{}
+	for _, img := range p.Images {
+		if blobs[img.Category] == nil {
+			blobs[img.Category] = image.BlobSizes{}
+		}
+		counts[img.Category]++
+		blobs[img.Category].Add(img.blobs)
+		if img.Size == 0 {
+			est.Unsized++
+		}
+	}
+	for category, categoryBlobs := range blobs {
+		est.Categories = append(est.Categories, CategorySize{Category: category, Images: counts[category], Size: categoryBlobs.Size()})
+	}
+	sort.Slice(est.Categories, func(i, j int) bool {
+		if est.Categories[i].Size != est.Categories[j].Size {
+			return est.Categories[i].Size > est.Categories[j].Size
+		}
+		return est.Categories[i].Category < est.Categories[j].Category
+	})
+	return est
+}
@@ -0,0 +1,93 @@
+package mirror
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestPlanConfig(t *testing.T) {
+	cfg := v1alpha2.ImageSetConfiguration{}
+	cfg.Mirror = v1alpha2.Mirror{
+		Platform: v1alpha2.Platform{
+			Graph:      true,
+			Channels:   []v1alpha2.ReleaseChannel{{Name: "stable-4.10"}},
+			BootImages: v1alpha2.BootImages{Platforms: []string{"metal"}},
+		},
+		AdditionalImages: []v1alpha2.Image{{Name: "registry.redhat.io/ubi8/ubi:latest"}},
+	}
+
+	planCfg := planConfig(cfg)
+	require.False(t, planCfg.Mirror.Platform.Graph)
+	require.Empty(t, planCfg.Mirror.Platform.BootImages.Platforms)
+	require.Equal(t, []v1alpha2.Image{
+		{Name: "registry.redhat.io/ubi8/ubi:latest"},
+		{Name: getGraphBaseImage(cfg.Mirror.Platform)},
+	}, planCfg.Mirror.AdditionalImages)
+	// The configuration is not modified
+	require.True(t, cfg.Mirror.Platform.Graph)
+	require.Len(t, cfg.Mirror.AdditionalImages, 1)
+}
+
+func TestNewImagePlan(t *testing.T) {
+	images := []plannedImage{
+		{
+			Source:   "registry.redhat.io/ubi8/ubi:latest",
+			Digest:   "sha256:aaaa",
+			Category: v1alpha2.TypeGeneric.String(),
+			blobs:    image.ImageBlobs{Layers: map[string]int64{"sha256:1": 100, "sha256:2": 50}, Configs: map[string]int64{"sha256:3": 5}},
+		},
+		{
+			Source:   "quay.io/openshift-release-dev/ocp-release@sha256:bbbb",
+			Digest:   "sha256:bbbb",
+			Category: v1alpha2.TypeOCPRelease.String(),
+			blobs:    image.ImageBlobs{Layers: map[string]int64{"sha256:1": 100}, Configs: map[string]int64{"sha256:4": 5}},
+		},
+	}
+	plan := newImagePlan(images)
+	exp := v1alpha2.ImagePlan{
+		Images: []v1alpha2.PlannedImage{
+			{Source: "registry.redhat.io/ubi8/ubi:latest", Digest: "sha256:aaaa", Category: v1alpha2.TypeGeneric.String(), Layers: 2, Size: 155},
+			{Source: "quay.io/openshift-release-dev/ocp-release@sha256:bbbb", Digest: "sha256:bbbb", Category: v1alpha2.TypeOCPRelease.String(), Layers: 1, Size: 105},
+		},
+		// Shared blobs are counted once
+		Size: 160,
+	}
+	require.Equal(t, exp, plan.imagePlan())
+}
+
+func TestEstimate(t *testing.T) {
+	plan := newImagePlan([]plannedImage{
+		{
+			Source:   "registry.redhat.io/ubi8/ubi:latest",
+			Category: v1alpha2.TypeGeneric.String(),
+			blobs:    image.ImageBlobs{Layers: map[string]int64{"sha256:1": 1000, "sha256:2": 500}},
+		},
+		{
+			Source:   "registry.redhat.io/ubi8/ubi-minimal:latest",
+			Category: v1alpha2.TypeGeneric.String(),
+			blobs:    image.ImageBlobs{Layers: map[string]int64{"sha256:1": 1000}},
+		},
+		{
+			Source:   "registry.redhat.io/redhat/redhat-operator-index:v4.12",
+			Category: v1alpha2.TypeOperatorCatalog.String(),
+			blobs:    image.ImageBlobs{Layers: map[string]int64{"sha256:3": 200}},
+		},
+		{
+			Source:   "registry.example.com/unreachable:latest",
+			Category: v1alpha2.TypeGeneric.String(),
+		},
+	})
+	require.Equal(t, SizeEstimate{
+		Categories: []CategorySize{
+			{Category: v1alpha2.TypeGeneric.String(), Images: 3, Size: 1500},
+			{Category: v1alpha2.TypeOperatorCatalog.String(), Images: 1, Size: 200},
+		},
+		Images:  4,
+		Size:    1700,
+		Unsized: 1,
+	}, plan.estimate())
+}
@@ -0,0 +1,113 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/openshift/library-go/pkg/image/registryclient"
+	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+// PublishDestination returns the reference the association is published to
+func (o *MirrorOptions) PublishDestination(toMirrorRef imagesource.TypedImageReference, assoc v1alpha2.Association) (imagesource.TypedImageReference, error) {
+	src, err := imagesource.ParseReference("file://" + assoc.Path)
+	if err != nil {
+		return src, fmt.Errorf("error parsing source ref %q: %v", assoc.Path, err)
+	}
+	dst := toMirrorRef
+	dst.Ref.Name = src.Ref.Name
+	dst.Ref.Namespace = path.Join(o.UserNamespace, src.Ref.Namespace)
+	dst.Ref.Tag = assoc.TagSymlink
+	dst.Ref.ID = assoc.ID
+	return dst, nil
+}
+
+// presentImages returns the images of the associations that the destination
+// already has: the manifests of the image and of its child manifests exist
+// and the tag of the image points to the same digest. The manifests are
+// queried concurrently, and images that cannot be queried are published.
+func (o *MirrorOptions) presentImages(ctx context.Context, assocs image.AssociationSet, toMirrorRef imagesource.TypedImageReference) (map[string]struct{}, error) {
+	regctx, err := o.Clients().Context(o.SkipVerification)
+	if err != nil {
+		return nil, fmt.Errorf("error creating registry context: %v", err)
+	}
+	insecure := o.DestPlainHTTP || o.DestSkipTLS
+
+	var mu sync.Mutex
+	present := map[string]struct{}{}
+	work := make(chan string)
+	g, ctx := errgroup.WithContext(ctx)
+	for i := 0; i < analyzeWorkers; i++ {
+		g.Go(func() error {
+			for imageName := range work {
+				values, _ := assocs.Search(imageName)
+				found := len(values) != 0
+				for _, assoc := range values {
+					dst, err := o.PublishDestination(toMirrorRef, assoc)
+					if err != nil {
+						return err
+					}
+					if found, err = hasManifest(ctx, regctx, dst, insecure); err != nil {
+						o.log().Debugf("publishing image %s: error querying %s: %v", imageName, dst.Ref.Exact(), err)
+					}
+					if !found {
+						break
+					}
+				}
+				if found {
+					mu.Lock()
+					present[imageName] = struct{}{}
+					mu.Unlock()
+				}
+			}
+			return nil
+		})
+	}
+	g.Go(func() error {
+		defer close(work)
+		for _, imageName := range assocs.Keys() {
+			select {
+			case work <- imageName:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return present, nil
+}
+
+// hasManifest returns whether the repository of the reference has its manifest
+// and, if the reference has a tag, whether the tag points to the manifest
+func hasManifest(ctx context.Context, regctx *registryclient.Context, ref imagesource.TypedImageReference, insecure bool) (bool, error) {
+	dgst, err := digest.Parse(ref.Ref.ID)
+	if err != nil {
+		return false, err
+	}
+	repo, err := regctx.RepositoryForRef(ctx, ref.Ref, insecure)
+	if err != nil {
+		return false, err
+	}
+	if ref.Ref.Tag != "" {
+		desc, err := repo.Tags(ctx).Get(ctx, ref.Ref.Tag)
+		if err != nil {
+			return false, err
+		}
+		return desc.Digest == dgst, nil
+	}
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		return false, err
+	}
+	return manifests.Exists(ctx, dgst)
+}
@@ -0,0 +1,72 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestPresentImages(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	markInsecure(t, u.Host)
+
+	// The destination has the ubi image from a previous publish,
+	// and its other tag points to a different image
+	push := func(content, tag string) string {
+		layer, err := crane.Layer(map[string][]byte{"file": []byte(content)})
+		require.NoError(t, err)
+		img, err := mutate.AppendLayers(empty.Image, layer)
+		require.NoError(t, err)
+		require.NoError(t, crane.Push(img, fmt.Sprintf("%s/mirror/ubi8/ubi:%s", u.Host, tag), crane.Insecure))
+		dgst, err := img.Digest()
+		require.NoError(t, err)
+		return dgst.String()
+	}
+	published := push("content", "latest")
+	push("other", "other")
+
+	assoc := func(name, path, id, tag string) image.AssociationSet {
+		return image.AssociationSet{name: image.Associations{name: {
+			Name:       name,
+			Path:       path,
+			ID:         id,
+			TagSymlink: tag,
+			Type:       v1alpha2.TypeGeneric,
+		}}}
+	}
+	assocs := assoc("registry.redhat.io/ubi8/ubi:latest", "ubi8/ubi", published, "latest")
+	assocs.Merge(assoc("registry.redhat.io/ubi8/ubi:other", "ubi8/ubi", published, "other"))
+	assocs.Merge(assoc("registry.redhat.io/ubi8/ubi@"+published, "ubi8/ubi", published, ""))
+	assocs.Merge(assoc("registry.redhat.io/ubi8/ubi-minimal:latest", "ubi8/ubi-minimal", published, "latest"))
+
+	o := &MirrorOptions{
+		ToMirror:      u.Host,
+		UserNamespace: "mirror",
+		DestPlainHTTP: true,
+	}
+	toMirrorRef, err := imagesource.ParseReference(u.Host)
+	require.NoError(t, err)
+	toMirrorRef.Type = imagesource.DestinationRegistry
+
+	present, err := o.presentImages(context.Background(), assocs, toMirrorRef)
+	require.NoError(t, err)
+	require.Equal(t, map[string]struct{}{
+		"registry.redhat.io/ubi8/ubi:latest":       {},
+		"registry.redhat.io/ubi8/ubi@" + published: {},
+	}, present)
+}
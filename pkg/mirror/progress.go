@@ -0,0 +1,186 @@
+package mirror
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	units "github.com/docker/go-units"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultProgressInterval is the default interval between progress reports
+	defaultProgressInterval = 30 * time.Second
+	// progressBarWidth is the width of the rendered progress bar
+	progressBarWidth = 20
+)
+
+// progressTracker periodically reports the bytes completed out of the
+// bytes expected for a phase of the run, with the estimated time remaining.
+// The methods of a nil progressTracker are no-ops.
+type progressTracker struct {
+	mu    sync.Mutex
+	phase string
+	done  int64
+	total int64
+	start time.Time
+	now   func() time.Time
+	stop  chan struct{}
+	wg    sync.WaitGroup
+	log   logrus.FieldLogger
+}
+
+// newProgressTracker returns a progressTracker for phase reporting every interval
+func newProgressTracker(phase string, interval time.Duration, log logrus.FieldLogger) *progressTracker {
+	p := &progressTracker{
+		log:   log,
+		phase: phase,
+		start: time.Now(),
+		now:   time.Now,
+		stop:  make(chan struct{}),
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.report()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// add records n bytes completed
+func (p *progressTracker) add(n int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.done += n
+	p.mu.Unlock()
+}
+
+// addTotal records n more bytes expected
+func (p *progressTracker) addTotal(n int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.total += n
+	p.mu.Unlock()
+}
+
+// observe updates the progress from a line of oc image mirror output
+func (p *progressTracker) observe(line string) {
+	if p == nil {
+		return
+	}
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) == 4 && (fields[0] == "uploading:" || fields[0] == "mounted:"):
+		if size, err := units.RAMInBytes(fields[3]); err == nil {
+			p.add(size)
+		}
+	case len(fields) >= 4 && fields[0] == "stats:":
+		// The planned size of the blobs copied to each registry
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, "size=") {
+				if size, err := units.RAMInBytes(strings.TrimPrefix(field, "size=")); err == nil {
+					p.addTotal(size)
+				}
+			}
+		}
+	}
+}
+
+// finish stops the periodic reports
+func (p *progressTracker) finish() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	p.wg.Wait()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.log.WithField("phase", p.phase).Debugf("%s: %s completed in %s", p.phase,
+		units.BytesSize(float64(p.done)), p.now().Sub(p.start).Round(time.Second))
+}
+
+// report logs the current progress
+func (p *progressTracker) report() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fields := logrus.Fields{"phase": p.phase, "bytesDone": p.done, "bytesTotal": p.total}
+	if eta, ok := p.eta(); ok {
+		fields["etaSeconds"] = int64(eta.Seconds())
+	}
+	p.log.WithFields(fields).Info(p.format())
+}
+
+// eta estimates the time remaining from the average rate so far
+func (p *progressTracker) eta() (time.Duration, bool) {
+	elapsed := p.now().Sub(p.start)
+	if p.done <= 0 || p.total <= p.done || elapsed <= 0 {
+		return 0, false
+	}
+	rate := float64(p.done) / elapsed.Seconds()
+	return time.Duration(float64(p.total-p.done) / rate * float64(time.Second)), true
+}
+
+// format renders the progress as a bar with the bytes completed and the estimated time remaining
+func (p *progressTracker) format() string {
+	if p.total <= 0 {
+		return fmt.Sprintf("%s: %s", p.phase, units.BytesSize(float64(p.done)))
+	}
+	ratio := float64(p.done) / float64(p.total)
+	if ratio > 1 {
+		ratio = 1
+	}
+	filled := int(ratio * progressBarWidth)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+	msg := fmt.Sprintf("%s: [%s] %3.0f%% %s / %s", p.phase, bar, ratio*100,
+		units.BytesSize(float64(p.done)), units.BytesSize(float64(p.total)))
+	if eta, ok := p.eta(); ok {
+		msg += fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+	}
+	return msg
+}
+
+// startProgress starts tracking the progress of phase, replacing the current tracker.
+// The returned function stops tracking and restores the previous tracker.
+func (o *MirrorOptions) startProgress(phase string) func() {
+	if o.ProgressInterval <= 0 {
+		return func() {}
+	}
+	prev := o.progress
+	o.progress = newProgressTracker(phase, o.ProgressInterval, o.log())
+	return func() {
+		o.progress.finish()
+		o.progress = prev
+	}
+}
+
+// dirSize returns the total size of the regular files under dir
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
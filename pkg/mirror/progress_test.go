@@ -0,0 +1,72 @@
+package mirror
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressTracker(t *testing.T) {
+	start := time.Now()
+	p := &progressTracker{phase: phaseMirror, start: start, now: func() time.Time { return start.Add(10 * time.Second) }}
+
+	require.Equal(t, "mirror: 0B", p.format())
+
+	for _, line := range []string{
+		"  stats: shared=1 unique=3 size=4MiB ratio=0.75",
+		"  stats: shared=0 unique=1 size=4MiB",
+		"uploading: reg.mirror.com/ubi8/ubi sha256:9e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8 1MiB",
+		"mounted: reg.mirror.com/ubi8/ubi-minimal sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8 1MiB",
+		"info: Mirroring completed in 1.2s (1.7MB/s)",
+	} {
+		p.observe(line)
+	}
+	require.Equal(t, int64(8*1024*1024), p.total)
+	require.Equal(t, int64(2*1024*1024), p.done)
+
+	eta, ok := p.eta()
+	require.True(t, ok)
+	require.Equal(t, 30*time.Second, eta)
+	require.Equal(t, "mirror: [=====               ]  25% 2MiB / 8MiB, ETA 30s", p.format())
+
+	// Progress past the planned total is capped
+	p.add(8 * 1024 * 1024)
+	_, ok = p.eta()
+	require.False(t, ok)
+	require.Equal(t, "mirror: [====================] 100% 10MiB / 8MiB", p.format())
+
+	// A nil tracker is a no-op
+	var nilTracker *progressTracker
+	nilTracker.add(1)
+	nilTracker.observe("uploading: reg.mirror.com/ubi8/ubi sha256:9e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8 1MiB")
+	nilTracker.finish()
+}
+
+func TestStartProgress(t *testing.T) {
+	opts := &MirrorOptions{}
+	opts.startProgress(phaseMirror)()
+	require.Nil(t, opts.progress)
+
+	opts.ProgressInterval = time.Millisecond
+	stopPush := opts.startProgress(phasePush)
+	push := opts.progress
+	require.NotNil(t, push)
+	stopUnpack := opts.startProgress(phaseUnpack)
+	require.Equal(t, phaseUnpack, opts.progress.phase)
+	stopUnpack()
+	require.Equal(t, push, opts.progress)
+	stopPush()
+	require.Nil(t, opts.progress)
+}
+
+func TestDirSize(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("foo"), 0640))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b"), []byte("barbaz"), 0640))
+	size, err := dirSize(dir)
+	require.NoError(t, err)
+	require.Equal(t, int64(9), size)
+}
@@ -0,0 +1,209 @@
+package mirror
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+	"github.com/openshift/oc-mirror/pkg/version"
+)
+
+const (
+	// provenanceFile is the in-toto provenance statement
+	// of the artifacts produced by the run
+	provenanceFile = "provenance.json"
+	// provenanceSignatureFile is the armored detached
+	// signature of the provenance statement
+	provenanceSignatureFile = provenanceFile + ".asc"
+
+	inTotoStatementType     = "https://in-toto.io/Statement/v0.1"
+	slsaProvenancePredicate = "https://slsa.dev/provenance/v0.2"
+	// provenanceBuildType identifies runs of oc-mirror
+	// building artifacts from an imageset configuration
+	provenanceBuildType = "https://github.com/openshift/oc-mirror/ImageSetConfiguration@v1alpha2"
+	provenanceBuilderID = "https://github.com/openshift/oc-mirror"
+)
+
+// provenanceStatement is an in-toto statement
+// with a SLSA v0.2 provenance predicate
+type provenanceStatement struct {
+	Type          string              `json:"_type"`
+	PredicateType string              `json:"predicateType"`
+	Subject       []provenanceSubject `json:"subject"`
+	Predicate     provenancePredicate `json:"predicate"`
+}
+
+// provenanceSubject is an artifact identified by its digests
+type provenanceSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// provenanceMaterial is an input of the run identified by its digests
+type provenanceMaterial struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+type provenancePredicate struct {
+	Builder struct {
+		ID string `json:"id"`
+	} `json:"builder"`
+	BuildType  string `json:"buildType"`
+	Invocation struct {
+		ConfigSource provenanceMaterial `json:"configSource"`
+		Parameters   struct {
+			Operation v1alpha2.Operation `json:"operation"`
+			Sequence  int                `json:"sequence,omitempty"`
+		} `json:"parameters"`
+	} `json:"invocation"`
+	Metadata struct {
+		BuildStartedOn  time.Time `json:"buildStartedOn"`
+		BuildFinishedOn time.Time `json:"buildFinishedOn"`
+		Completeness    struct {
+			Parameters  bool `json:"parameters"`
+			Environment bool `json:"environment"`
+			Materials   bool `json:"materials"`
+		} `json:"completeness"`
+	} `json:"metadata"`
+	Materials []provenanceMaterial `json:"materials"`
+}
+
+// recordRebuiltImages records the images built by the run
+// as subjects of the provenance statement
+func (o *MirrorOptions) recordRebuiltImages(mapping image.TypedImageMapping) {
+	if o.rebuiltImages == nil {
+		o.rebuiltImages = image.TypedImageMapping{}
+	}
+	o.rebuiltImages.Merge(mapping)
+}
+
+// writeProvenance writes the provenance statement of the imageset archives and
+// the images rebuilt by the run to the results directory, and signs it with
+// the private key at SignResultsKey when set. The statement records the digests
+// of the imageset configuration and of the source images pinned by digest.
+func (o *MirrorOptions) writeProvenance(results *v1alpha2.Results, mapping image.TypedImageMapping) error {
+	dir, err := o.createResultsDir()
+	if err != nil {
+		return err
+	}
+	statement, err := o.generateProvenance(results, mapping)
+	if err != nil {
+		return fmt.Errorf("error generating provenance: %v", err)
+	}
+	data, err := json.MarshalIndent(statement, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal provenance: %v", err)
+	}
+	data = append(data, '\n')
+	path := filepath.Join(dir, provenanceFile)
+	if err := ioutil.WriteFile(path, data, 0640); err != nil {
+		return fmt.Errorf("error writing provenance: %v", err)
+	}
+	o.log().Infof("Wrote provenance to %s", path)
+
+	if o.SignResultsKey == "" {
+		return nil
+	}
+	entity, err := readSigningKey(o.SignResultsKey)
+	if err != nil {
+		return err
+	}
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&sig, entity, bytes.NewReader(data), nil); err != nil {
+		return fmt.Errorf("error signing provenance: %v", err)
+	}
+	path = filepath.Join(dir, provenanceSignatureFile)
+	if err := ioutil.WriteFile(path, sig.Bytes(), 0640); err != nil {
+		return fmt.Errorf("error writing provenance signature: %v", err)
+	}
+	o.log().Infof("Wrote provenance signature to %s", path)
+	return nil
+}
+
+// generateProvenance returns the provenance statement of the run
+func (o *MirrorOptions) generateProvenance(results *v1alpha2.Results, mapping image.TypedImageMapping) (provenanceStatement, error) {
+	statement := provenanceStatement{
+		Type:          inTotoStatementType,
+		PredicateType: slsaProvenancePredicate,
+		Subject:       []provenanceSubject{},
+	}
+
+	for _, archive := range results.Archives {
+		sum, err := fileChecksum(archive)
+		if err != nil {
+			return statement, err
+		}
+		statement.Subject = append(statement.Subject, provenanceSubject{
+			Name:   filepath.Base(archive),
+			Digest: map[string]string{"sha256": sum},
+		})
+	}
+	for _, dst := range o.rebuiltImages {
+		if dst.Ref.ID == "" {
+			continue
+		}
+		statement.Subject = append(statement.Subject, provenanceSubject{
+			Name:   dst.Ref.AsRepository().Exact(),
+			Digest: digestSet(dst.Ref.ID),
+		})
+	}
+	sort.Slice(statement.Subject, func(i, j int) bool {
+		return statement.Subject[i].Name < statement.Subject[j].Name
+	})
+
+	p := &statement.Predicate
+	p.Builder.ID = fmt.Sprintf("%s@%s", provenanceBuilderID, version.Get().GitVersion)
+	p.BuildType = provenanceBuildType
+	p.Invocation.Parameters.Operation = results.Operation
+	p.Invocation.Parameters.Sequence = results.Sequence
+	p.Metadata.BuildStartedOn = results.StartTime.UTC()
+	p.Metadata.BuildFinishedOn = results.EndTime.UTC()
+	p.Metadata.Completeness.Parameters = true
+
+	p.Materials = []provenanceMaterial{}
+	if o.ConfigPath != "" && results.ConfigDigest != "" {
+		p.Invocation.ConfigSource = provenanceMaterial{
+			URI:    filepath.Base(o.ConfigPath),
+			Digest: digestSet(results.ConfigDigest),
+		}
+		p.Materials = append(p.Materials, p.Invocation.ConfigSource)
+	}
+	seen := map[string]struct{}{}
+	var sources []provenanceMaterial
+	for src := range mapping {
+		if src.Type != imagesource.DestinationRegistry || src.Ref.ID == "" {
+			continue
+		}
+		uri := src.Ref.AsRepository().Exact() + "@" + src.Ref.ID
+		if _, ok := seen[uri]; ok {
+			continue
+		}
+		seen[uri] = struct{}{}
+		sources = append(sources, provenanceMaterial{URI: uri, Digest: digestSet(src.Ref.ID)})
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].URI < sources[j].URI
+	})
+	p.Materials = append(p.Materials, sources...)
+	return statement, nil
+}
+
+// digestSet converts an algorithm:hex digest to an in-toto digest set
+func digestSet(digest string) map[string]string {
+	alg, hex := "sha256", digest
+	if i := strings.Index(digest, ":"); i > 0 {
+		alg, hex = digest[:i], digest[i+1:]
+	}
+	return map[string]string{alg: hex}
+}
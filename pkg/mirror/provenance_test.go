@@ -0,0 +1,98 @@
+package mirror
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestWriteProvenance(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(t.TempDir(), "mirror_seq1_000000.tar")
+	require.NoError(t, ioutil.WriteFile(archive, []byte("foo"), 0640))
+
+	pgpConfig := &packet.Config{DefaultHash: crypto.SHA256}
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", pgpConfig)
+	require.NoError(t, err)
+	keyBuf := &bytes.Buffer{}
+	w, err := armor.Encode(keyBuf, openpgp.PrivateKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.SerializePrivate(w, pgpConfig))
+	require.NoError(t, w.Close())
+	keyPath := filepath.Join(t.TempDir(), "key.asc")
+	require.NoError(t, ioutil.WriteFile(keyPath, keyBuf.Bytes(), 0600))
+
+	src, err := imagesource.ParseReference("quay.io/example/app@sha256:fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9")
+	require.NoError(t, err)
+	dst, err := imagesource.ParseReference("file://example/app")
+	require.NoError(t, err)
+	mapping := image.TypedImageMapping{}
+	mapping.Add(src, dst, v1alpha2.TypeGeneric)
+
+	ctlgSrc, err := imagesource.ParseReference("file://redhat/redhat-operator-index:v4.10")
+	require.NoError(t, err)
+	ctlgDst, err := imagesource.ParseReference("reg.mirror.com/redhat/redhat-operator-index@sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae")
+	require.NoError(t, err)
+	rebuilt := image.TypedImageMapping{}
+	rebuilt.Add(ctlgSrc, ctlgDst, v1alpha2.TypeOperatorCatalog)
+
+	opts := &MirrorOptions{resultsDir: dir, SignResultsKey: keyPath, ConfigPath: "imageset-config.yaml"}
+	opts.recordRebuiltImages(rebuilt)
+	start := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	results := &v1alpha2.Results{ResultsSpec: v1alpha2.ResultsSpec{
+		Operation:    v1alpha2.OperationMirrorToDisk,
+		StartTime:    metav1.NewTime(start),
+		EndTime:      metav1.NewTime(start.Add(time.Minute)),
+		Sequence:     1,
+		Archives:     []string{archive},
+		ConfigDigest: "sha256:b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c",
+	}}
+	require.NoError(t, opts.writeProvenance(results, mapping))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, provenanceFile))
+	require.NoError(t, err)
+	var statement provenanceStatement
+	require.NoError(t, json.Unmarshal(data, &statement))
+	require.Equal(t, inTotoStatementType, statement.Type)
+	require.Equal(t, slsaProvenancePredicate, statement.PredicateType)
+	require.Equal(t, []provenanceSubject{
+		{Name: "mirror_seq1_000000.tar", Digest: map[string]string{"sha256": "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"}},
+		{Name: "reg.mirror.com/redhat/redhat-operator-index", Digest: map[string]string{"sha256": "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"}},
+	}, statement.Subject)
+	configSource := provenanceMaterial{
+		URI:    "imageset-config.yaml",
+		Digest: map[string]string{"sha256": "b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c"},
+	}
+	require.Equal(t, configSource, statement.Predicate.Invocation.ConfigSource)
+	require.Equal(t, []provenanceMaterial{
+		configSource,
+		{
+			URI:    "quay.io/example/app@sha256:fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9",
+			Digest: map[string]string{"sha256": "fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9"},
+		},
+	}, statement.Predicate.Materials)
+	require.Equal(t, v1alpha2.OperationMirrorToDisk, statement.Predicate.Invocation.Parameters.Operation)
+	require.Equal(t, start, statement.Predicate.Metadata.BuildStartedOn)
+
+	sig, err := os.Open(filepath.Join(dir, provenanceSignatureFile))
+	require.NoError(t, err)
+	defer sig.Close()
+	signer, err := openpgp.CheckArmoredDetachedSignature(openpgp.EntityList{entity}, bytes.NewReader(data), sig)
+	require.NoError(t, err)
+	require.Equal(t, entity.PrimaryKey.KeyId, signer.PrimaryKey.KeyId)
+}
@@ -0,0 +1,869 @@
+package mirror
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mholt/archiver/v3"
+	"github.com/opencontainers/go-digest"
+	"github.com/openshift/library-go/pkg/image/reference"
+	"github.com/openshift/library-go/pkg/image/registryclient"
+	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	imagemanifest "github.com/openshift/oc/pkg/cli/image/manifest"
+	imgmirror "github.com/openshift/oc/pkg/cli/image/mirror"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/archive"
+	"github.com/openshift/oc-mirror/pkg/bundle"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/exitcode"
+	"github.com/openshift/oc-mirror/pkg/image"
+	"github.com/openshift/oc-mirror/pkg/image/blobfile"
+	"github.com/openshift/oc-mirror/pkg/metadata/storage"
+)
+
+type UuidError struct {
+	InUuid   uuid.UUID
+	CurrUuid uuid.UUID
+}
+
+func (u *UuidError) Error() string {
+	return fmt.Sprintf("mismatched uuids, want %v, got %v", u.CurrUuid, u.InUuid)
+}
+
+type SequenceError struct {
+	wantSeq int
+	gotSeq  int
+}
+
+func (s *SequenceError) Error() string {
+	return fmt.Sprintf("invalid mirror sequence order, want %v, got %v", s.wantSeq, s.gotSeq)
+}
+
+type ErrArchiveFileNotFound struct {
+	filename string
+}
+
+func (e *ErrArchiveFileNotFound) Error() string {
+	return fmt.Sprintf("file %s not found in archive", e.filename)
+}
+
+// ErrBlobCorrupted is returned when the content of a blob
+// unpacked from an archive does not match its digest
+type ErrBlobCorrupted struct {
+	digest  string
+	actual  string
+	archive string
+}
+
+func (e *ErrBlobCorrupted) Error() string {
+	return fmt.Sprintf("blob %s in archive %s is corrupted: content has digest %s", e.digest, filepath.Base(e.archive), e.actual)
+}
+
+// publishPageSize is the number of images whose associations
+// are loaded at once when publishing
+const publishPageSize = 50
+
+// unpackWorkers is the number of archives of a multi-part
+// imageset extracted concurrently, bounded since extraction is IO bound
+const unpackWorkers = 4
+
+// Publish will plan a mirroring operation based on provided imageset on disk
+func (o *MirrorOptions) Publish(ctx context.Context) (image.TypedImageMapping, error) {
+
+	o.log().Infof("Publishing image set from archive %q to registry %q", o.From, o.ToMirror)
+
+	var currentMeta v1alpha2.Metadata
+	var incomingMeta v1alpha2.Metadata
+	a := archive.NewArchiver()
+	allMappings := image.TypedImageMapping{}
+	var insecure bool
+	if o.DestPlainHTTP || o.DestSkipTLS {
+		insecure = true
+	}
+
+	// Set target dir for resulting artifacts
+	if o.OutputDir == "" {
+		dir, err := o.createResultsDir()
+		o.OutputDir = dir
+		if err != nil {
+			return allMappings, err
+		}
+	}
+
+	// Create workspace
+	cleanup, tmpdir, err := o.mktempImagesDir(o.Dir)
+	if err != nil {
+		return allMappings, err
+	}
+
+	// Handle cleanup of disk
+	if !o.SkipCleanup {
+		defer cleanup()
+	}
+
+	o.log().Debugf("Unarchiving metadata into %s", tmpdir)
+
+	// Get file information from the source archives
+	filesInArchive, err := bundle.ReadImageSet(a, o.From)
+	if err != nil {
+		return allMappings, err
+	}
+
+	// Extract imageset
+	done := o.startPhase(phaseUnpack)
+	err = o.unpackImageSet(ctx, a, tmpdir)
+	done(err)
+	if err != nil {
+		return allMappings, err
+	}
+
+	// Create a local workspace backend for incoming data
+	workspace, err := storage.NewLocalBackend(tmpdir)
+	if err != nil {
+		return allMappings, fmt.Errorf("error opening local backend: %v", err)
+	}
+	// Load incoming metadta
+	if err := workspace.ReadMetadata(ctx, &incomingMeta, config.MetadataBasePath); err != nil {
+		return allMappings, fmt.Errorf("error reading incoming metadata: %v", err)
+	}
+
+	metaImage := o.newMetadataImage(incomingMeta.Uid.String())
+	// Determine stateless or stateful mode
+	var backend storage.Backend
+	if incomingMeta.SingleUse {
+		o.log().Warn("metadata has single-use label, using stateless mode")
+		cfg := v1alpha2.StorageConfig{
+			Local: &v1alpha2.LocalConfig{Path: o.Dir}}
+		backend, err = storage.ByConfig(o.Dir, cfg, o.Clients())
+		if err != nil {
+			return allMappings, exitcode.With(exitcode.StorageError, err)
+		}
+		defer func() {
+			if err := backend.Cleanup(ctx, config.MetadataBasePath); err != nil {
+				o.log().Error(err)
+			}
+		}()
+	} else {
+		cfg := v1alpha2.StorageConfig{
+			Registry: &v1alpha2.RegistryConfig{
+				ImageURL: metaImage,
+				SkipTLS:  insecure,
+			},
+		}
+		backend, err = storage.ByConfig(o.Dir, cfg, o.Clients())
+		if err != nil {
+			return allMappings, exitcode.With(exitcode.StorageError, err)
+		}
+	}
+
+	// Read in current metadata, if present
+	switch err := backend.ReadMetadata(ctx, &currentMeta, config.MetadataBasePath); {
+	case err != nil && !errors.Is(err, storage.ErrMetadataNotExist):
+		return allMappings, exitcode.With(exitcode.StorageError, err)
+	case err != nil:
+		o.log().Infof("No existing metadata found. Setting up new workspace")
+		// Check that this is the first imageset
+		incomingRun := incomingMeta.PastMirror
+		if incomingRun.Sequence != 1 {
+			return allMappings, &SequenceError{1, incomingRun.Sequence}
+		}
+	default:
+		// Complete metadata checks
+		// UUID mismatch will now be seen as a new workspace.
+		o.log().Debug("Check metadata sequence number")
+		currRun := currentMeta.PastMirror
+		incomingRun := incomingMeta.PastMirror
+		if incomingRun.Sequence != (currRun.Sequence + 1) {
+			return allMappings, &SequenceError{currRun.Sequence + 1, incomingRun.Sequence}
+		}
+	}
+
+	// Unpack chart to user destination if it exists
+	o.log().Debugf("Unpacking any provided Helm charts to %s", o.OutputDir)
+	if err := unpack(config.HelmDir, o.OutputDir, filesInArchive); err != nil {
+		return allMappings, err
+	}
+
+	// Page through image associations so the images of the first
+	// pages are pushed before the associations of the rest are loaded
+	pages, err := image.NewAssociationPages(incomingMeta.PastMirror.Associations, publishPageSize)
+	if err != nil {
+		return allMappings, err
+	}
+
+	toMirrorRef, err := imagesource.ParseReference(o.ToMirror)
+	if err != nil {
+		return allMappings, fmt.Errorf("error parsing mirror registry %q: %v", o.ToMirror, err)
+	}
+	o.log().Debugf("mirror reference: %#v", toMirrorRef)
+	if toMirrorRef.Type != imagesource.DestinationRegistry {
+		return allMappings, fmt.Errorf("destination %q must be a registry reference", o.ToMirror)
+	}
+
+	// Blobs of previous imagesets are not archived, so
+	// the archives bound the data new to the registry
+	var destRepos []string
+	for _, assoc := range incomingMeta.PastMirror.Associations {
+		destRepos = append(destRepos, path.Join(o.UserNamespace, assoc.Path))
+	}
+	err = o.checkDestinationQuota(ctx, destRepos, func() (int64, error) {
+		return archivesSize(o.From, a.String())
+	})
+	if err != nil {
+		return allMappings, err
+	}
+
+	// Blobs of a create workspace kept in the same --dir by
+	// --skip-cleanup are used instead of extracting them from
+	// the archives. Manifests and metadata are still extracted.
+	workspaceBlobs := 0
+	defer func() {
+		if workspaceBlobs != 0 {
+			o.log().Infof("Used %d blobs from the workspace %s instead of the archives", workspaceBlobs, o.workspaceV2Dir())
+		}
+	}()
+
+	skipped := 0
+	defer func() {
+		if skipped != 0 {
+			o.log().Infof("Skipped %d images already in %s", skipped, o.ToMirror)
+		}
+	}()
+
+	var errs []error
+
+	for {
+		assocs, ok := pages.Next()
+		if !ok {
+			break
+		}
+		if err := assocs.UpdatePath(); err != nil {
+			return allMappings, err
+		}
+
+		present, err := o.presentImages(ctx, assocs, toMirrorRef)
+		if err != nil {
+			return allMappings, err
+		}
+		skipped += len(present)
+
+		for _, imageName := range assocs.Keys() {
+
+			var mmapping []imgmirror.Mapping
+
+			values, _ := assocs.Search(imageName)
+
+			// Images already in the destination are not unpacked and
+			// pushed again, but are still added to the ICSP mapping
+			if _, found := present[imageName]; found {
+				for _, assoc := range values {
+					if assoc.Name != imageName {
+						continue
+					}
+					source, err := imagesource.ParseReference(imageName)
+					if err != nil {
+						errs = append(errs, err)
+						continue
+					}
+					dst, err := o.PublishDestination(toMirrorRef, assoc)
+					if err != nil {
+						errs = append(errs, err)
+						continue
+					}
+					allMappings.Add(source, dst, assoc.Type)
+				}
+				continue
+			}
+
+			// Create temp workspace for image processing
+			cleanUnpackDir, unpackDir, err := o.mktempImagesDir(tmpdir)
+			if err != nil {
+				return allMappings, err
+			}
+			// Paths of the blobs of the image unpacked from the archive by digest
+			unpackedBlobs := map[string]string{}
+			// Whether a blob of the image in the archive does not match its digest
+			corrupted := false
+
+			for _, assoc := range values {
+
+				// Map of remote layer digest to the set of paths they should be fetched to.
+				missingLayers := map[string][]string{}
+				manifestPath := filepath.Join("v2", assoc.Path, "manifests")
+
+				// Ensure child manifests are all unpacked
+				o.log().Debugf("reading assoc: %s", assoc.Name)
+				if len(assoc.ManifestDigests) != 0 {
+					for _, manifestDigest := range assoc.ManifestDigests {
+						if hasManifest := assocs.ContainsKey(imageName, manifestDigest); !hasManifest {
+							errs = append(errs, fmt.Errorf("image %q: expected associations to have manifest %s but was not found", imageName, manifestDigest))
+							continue
+						}
+						manifestArchivePath := filepath.Join(manifestPath, manifestDigest)
+						switch _, err := os.Stat(manifestArchivePath); {
+						case err == nil:
+							o.log().Debugf("Manifest found %s found in %s", manifestDigest, assoc.Path)
+						case errors.Is(err, os.ErrNotExist):
+							if err := unpack(manifestArchivePath, unpackDir, filesInArchive); err != nil {
+								errs = append(errs, err)
+							}
+						default:
+							errs = append(errs, fmt.Errorf("accessing image %q manifest %q: %v", imageName, manifestDigest, err))
+						}
+					}
+				}
+
+				// Unpack association main manifest
+				if err := unpack(filepath.Join(manifestPath, assoc.ID), unpackDir, filesInArchive); err != nil {
+					errs = append(errs, fmt.Errorf("error occured during unpacking %v", err))
+					continue
+				}
+
+				for _, layerDigest := range assoc.LayerDigests {
+					o.log().Debugf("Found layer %v for image %s", layerDigest, imageName)
+					// Construct blob path, which is adjacent to the manifests path.
+					blobPath := filepath.Join("blobs", layerDigest)
+					imagePath := filepath.Join(unpackDir, "v2", assoc.Path)
+					imageBlobPath := filepath.Join(imagePath, blobPath)
+					switch linked, err := o.linkWorkspaceBlob(assoc.Path, layerDigest, imageBlobPath); {
+					case err != nil:
+						o.log().Warnf("unable to use blob %s of the workspace: %v", layerDigest, err)
+					case linked:
+						o.log().Debugf("Blob %s found in the workspace", layerDigest)
+						workspaceBlobs++
+						continue
+					}
+					// Blobs shared by the manifests of the image are only unpacked once
+					if unpackedPath, found := unpackedBlobs[layerDigest]; found {
+						if unpackedPath == imageBlobPath {
+							continue
+						}
+						if _, err := o.linkBlobFile(unpackedPath, imageBlobPath); err != nil {
+							errs = append(errs, fmt.Errorf("placing image %q blob %q at %s: %v", imageName, layerDigest, imageBlobPath, err))
+						}
+						continue
+					}
+					aerr := &ErrArchiveFileNotFound{}
+					cerr := &ErrBlobCorrupted{}
+					switch err := o.unpackBlob(blobPath, imagePath, layerDigest, filesInArchive); {
+					case err == nil:
+						o.log().Debugf("Blob %s found in %s", layerDigest, assoc.Path)
+						unpackedBlobs[layerDigest] = imageBlobPath
+					case errors.Is(err, os.ErrNotExist) || errors.As(err, &aerr):
+						// Image layer must exist in the mirror registry since it wasn't archived,
+						// so fetch the layer and place it in the blob dir so it can be mirrored by `oc`.
+						missingLayers[layerDigest] = append(missingLayers[layerDigest], imageBlobPath)
+					case errors.As(err, &cerr):
+						// Corrupted layers would only fail when the cluster pulls
+						// the image, so the image is not pushed at all
+						corrupted = true
+						errs = append(errs, fmt.Errorf("image %q: %v", imageName, err))
+					default:
+						errs = append(errs, fmt.Errorf("accessing image %q blob %q at %s: %v", imageName, layerDigest, blobPath, err))
+					}
+				}
+
+				m := imgmirror.Mapping{Name: assoc.Name}
+				if m.Source, err = imagesource.ParseReference("file://" + assoc.Path); err != nil {
+					errs = append(errs, fmt.Errorf("error parsing source ref %q: %v", assoc.Path, err))
+					continue
+				}
+
+				if assoc.TagSymlink != "" {
+					if err := unpack(filepath.Join(manifestPath, assoc.TagSymlink), unpackDir, filesInArchive); err != nil {
+						errs = append(errs, fmt.Errorf("error unpacking symlink %v", err))
+						continue
+					}
+					m.Source.Ref.Tag = assoc.TagSymlink
+				}
+
+				m.Source.Ref.ID = assoc.ID
+				if m.Destination, err = o.PublishDestination(toMirrorRef, assoc); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+
+				// Add references for the mirror mapping
+				mmapping = append(mmapping, m)
+
+				// Add top level assocation to the ICSP mapping
+				if assoc.Name == imageName {
+					source, err := imagesource.ParseReference(imageName)
+					if err != nil {
+						errs = append(errs, err)
+						continue
+					}
+					allMappings.Add(source, m.Destination, assoc.Type)
+				}
+
+				if len(missingLayers) != 0 {
+					// Fetch all layers and mount them at the specified paths.
+					if err := o.fetchBlobs(ctx, currentMeta, missingLayers); err != nil {
+						return allMappings, err
+					}
+				}
+			}
+
+			// Mirror all mappings for this image
+			if len(mmapping) != 0 && !corrupted {
+				if err := o.publishImage(mmapping, unpackDir); err != nil {
+					errs = append(errs, err)
+				}
+			}
+
+			// Cleanup temp image processing workspace as images are processed
+			if !o.SkipCleanup {
+				cleanUnpackDir()
+			}
+		}
+	}
+	if len(errs) != 0 {
+		return allMappings, utilerrors.NewAggregate(errs)
+	}
+
+	o.log().Debug("unpack release signatures")
+	err = o.unpackReleaseSignatures(o.OutputDir, filesInArchive)
+	if err != nil {
+		return allMappings, err
+	}
+	if err := o.verifyReleaseSignatures(ctx, filepath.Join(o.OutputDir, config.ReleaseSignatureDir)); err != nil {
+		return allMappings, err
+	}
+
+	o.log().Debug("unpack RHCOS boot images")
+	if err := o.unpackBootImages(o.OutputDir, filesInArchive); err != nil {
+		return allMappings, err
+	}
+
+	o.log().Debug("unpack client binaries")
+	if err := o.unpackClients(o.OutputDir, filesInArchive); err != nil {
+		return allMappings, err
+	}
+
+	mappings, err := o.processCustomImages(ctx, tmpdir, filesInArchive, incomingMeta)
+	if err != nil {
+		return allMappings, err
+	}
+	allMappings.Merge(mappings)
+
+	// Replace old metadata with new metadata
+	if err := backend.WriteMetadata(ctx, &incomingMeta, config.MetadataBasePath); err != nil {
+		return allMappings, exitcode.With(exitcode.StorageError, err)
+	}
+
+	return allMappings, nil
+}
+
+// proccessCustomImages builds custom images for operator catalogs or Cincinnati graph data if data is present in the archive
+func (o *MirrorOptions) processCustomImages(ctx context.Context, dir string, filesInArchive map[string]string, meta v1alpha2.Metadata) (image.TypedImageMapping, error) {
+	allMappings := image.TypedImageMapping{}
+	// process catalogs
+	o.log().Debug("rebuilding catalog images")
+	found, err := o.unpackCatalog(dir, filesInArchive)
+	if err != nil {
+		return allMappings, err
+	}
+
+	if found {
+		ctlgRefs, err := o.rebuildCatalogs(ctx, dir)
+		if err != nil {
+			return allMappings, fmt.Errorf("error rebuilding catalog images from file-based catalogs: %v", err)
+		}
+		allMappings.Merge(ctlgRefs)
+	}
+
+	o.log().Debug("building cincinnati graph data image")
+	// process cincinnati graph image
+	found, err = o.unpackRelease(dir, filesInArchive)
+	if err != nil {
+		return allMappings, err
+	}
+
+	if found {
+		baseImage := getGraphBaseImage(meta.PastMirror.Mirror.Platform)
+		graphRef, err := o.buildGraphImage(ctx, dir, baseImage)
+		if err != nil {
+			return allMappings, fmt.Errorf("error building cincinnati graph image: %v", err)
+		}
+		allMappings.Merge(graphRef)
+	}
+
+	return allMappings, nil
+}
+
+// unpackImageSet unarchives all provided tar archives	if err != nil {
+func (o *MirrorOptions) unpackImageSet(ctx context.Context, a archive.Archiver, dest string) error {
+
+	// archive that we do not want to unpack
+	exclude := []string{config.BlobDir, config.V2Dir, config.HelmDir, config.BootImagesDir, config.ClientsDir}
+
+	file, err := os.Stat(o.From)
+	if err != nil {
+		return err
+	}
+
+	if file.IsDir() {
+
+		if o.progress != nil {
+			size, err := archivesSize(o.From, a.String())
+			if err != nil {
+				return err
+			}
+			o.progress.addTotal(size)
+		}
+
+		var archives []string
+		err = filepath.Walk(o.From, func(path string, info os.FileInfo, err error) error {
+
+			if err != nil {
+				return fmt.Errorf("traversing %s: %v", path, err)
+			}
+			if info == nil {
+				return fmt.Errorf("no file info")
+			}
+
+			extension := filepath.Ext(path)
+			extension = strings.TrimPrefix(extension, ".")
+
+			if extension == a.String() {
+				archives = append(archives, path)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return o.unarchiveAll(ctx, archives, dest, exclude)
+
+	} else {
+
+		o.progress.addTotal(file.Size())
+		o.log().Infof("Extracting archive %s", o.From)
+		if err := archive.Unarchive(a, o.From, dest, exclude); err != nil {
+			return err
+		}
+		o.progress.add(file.Size())
+	}
+
+	return err
+}
+
+// unarchiveAll extracts the archives of a multi-part imageset concurrently.
+// The parts hold different files, and each worker has its own archiver
+// since an archiver reads one archive at a time.
+func (o *MirrorOptions) unarchiveAll(ctx context.Context, archives []string, dest string, exclude []string) error {
+	work := make(chan string)
+	g, ctx := errgroup.WithContext(ctx)
+	for i := 0; i < unpackWorkers; i++ {
+		g.Go(func() error {
+			a := archive.NewArchiver()
+			for path := range work {
+				o.log().Debugf("Extracting archive %s", path)
+				if err := archive.Unarchive(a, path, dest, exclude); err != nil {
+					return fmt.Errorf("error extracting archive %s: %v", path, err)
+				}
+				if info, err := os.Stat(path); err == nil {
+					o.progress.add(info.Size())
+				}
+			}
+			return nil
+		})
+	}
+	g.Go(func() error {
+		defer close(work)
+		for _, path := range archives {
+			select {
+			case work <- path:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	return g.Wait()
+}
+
+// archivesSize returns the total size of the archives with extension ext under dir
+func archivesSize(dir, ext string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.TrimPrefix(filepath.Ext(path), ".") == ext {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// workspaceV2Dir returns the v2 directory of the create workspace in --dir
+func (o *MirrorOptions) workspaceV2Dir() string {
+	return filepath.Join(o.Dir, config.SourceDir, config.V2Dir)
+}
+
+// linkWorkspaceBlob links the blob of the repository from the create workspace
+// to dstPath, copying it if it cannot be linked, and returns false if the
+// workspace does not have the blob. It only replaces the extraction of the
+// blob from the archives, which are still created and read.
+func (o *MirrorOptions) linkWorkspaceBlob(repoPath, layerDigest, dstPath string) (bool, error) {
+	srcPath := filepath.Join(o.workspaceV2Dir(), repoPath, config.BlobDir, layerDigest)
+	return o.linkBlobFile(srcPath, dstPath)
+}
+
+// linkBlobFile places the blob at srcPath at dstPath without copying it when
+// the filesystem allows it, and returns false if there is no blob at srcPath
+func (o *MirrorOptions) linkBlobFile(srcPath, dstPath string) (bool, error) {
+	info, err := os.Stat(srcPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return false, nil
+	case err != nil:
+		return false, err
+	case !info.Mode().IsRegular():
+		return false, nil
+	}
+	method, err := blobfile.Place(srcPath, dstPath)
+	if err != nil {
+		return false, err
+	}
+	o.log().Debugf("%s blob %s to %s", method, srcPath, dstPath)
+	return true, nil
+}
+
+// TODO(estroz): symlink blobs instead of copying them to avoid data duplication.
+// `oc` mirror libs should be able to follow these symlinks.
+func (o *MirrorOptions) copyBlobFile(src io.Reader, dstPath string) error {
+	o.log().Debugf("copying blob to %s", dstPath)
+	if err := os.MkdirAll(filepath.Dir(dstPath), os.ModePerm); err != nil {
+		return err
+	}
+	// Allowing exisitng files to be written to for now since we
+	// some blobs appears to be written multiple time
+	// TODO: investigate this issue
+	dst, err := os.OpenFile(filepath.Clean(dstPath), os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("error creating blob file: %v", err)
+	}
+	defer dst.Close()
+	if _, err := io.Copy(dst, src); err != nil {
+		return fmt.Errorf("error copying blob %q: %v", filepath.Base(dstPath), err)
+	}
+	return nil
+}
+
+func (o *MirrorOptions) fetchBlobs(ctx context.Context, meta v1alpha2.Metadata, missingLayers map[string][]string) error {
+	regctx, err := o.Clients().Context(o.SkipVerification)
+	if err != nil {
+		return fmt.Errorf("error creating registry context: %v", err)
+	}
+
+	asSet, err := image.ConvertToAssociationSet(meta.PastAssociations)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for layerDigest, dstBlobPaths := range missingLayers {
+		imgRef, err := o.findBlobRepo(asSet, layerDigest)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error finding remote layer %q: %v", layerDigest, err))
+		}
+		if err := o.fetchBlob(ctx, regctx, imgRef.Ref, layerDigest, dstBlobPaths); err != nil {
+			errs = append(errs, fmt.Errorf("layer %s: %v", layerDigest, err))
+			continue
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// fetchBlob fetches a blob at <o.ToMirror>/<resource>/blobs/<layerDigest>
+// then copies it to each path in dstPaths.
+func (o *MirrorOptions) fetchBlob(ctx context.Context, regctx *registryclient.Context, ref reference.DockerImageReference, layerDigest string, dstPaths []string) error {
+	var insecure bool
+	if o.DestPlainHTTP || o.DestSkipTLS {
+		insecure = true
+	}
+	o.log().Debugf("copying blob %s from %s", layerDigest, ref.Exact())
+	repo, err := regctx.RepositoryForRef(ctx, ref, insecure)
+	if err != nil {
+		return fmt.Errorf("create repo for %s: %v", ref, err)
+	}
+	dgst, err := digest.Parse(layerDigest)
+	if err != nil {
+		return err
+	}
+	rc, err := repo.Blobs(ctx).Open(ctx, dgst)
+	if err != nil {
+		return fmt.Errorf("open blob: %v", err)
+	}
+	defer rc.Close()
+	if len(dstPaths) == 0 {
+		return nil
+	}
+	// The blob is downloaded once and placed at the other paths
+	if err := o.copyBlobFile(rc, dstPaths[0]); err != nil {
+		return fmt.Errorf("copy blob for %s: %v", ref, err)
+	}
+	for _, dstPath := range dstPaths[1:] {
+		if _, err := o.linkBlobFile(dstPaths[0], dstPath); err != nil {
+			return fmt.Errorf("copy blob for %s: %v", ref, err)
+		}
+	}
+
+	return nil
+}
+
+func unpack(archiveFilePath, dest string, filesInArchive map[string]string) error {
+	archivePath, found := filesInArchive[archiveFilePath]
+	if !found {
+		return &ErrArchiveFileNotFound{archiveFilePath}
+	}
+	if err := archive.NewArchiver().Extract(archivePath, archiveFilePath, dest); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(dest, archiveFilePath)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// unpackBlob unpacks the blob from the archive and checks that its content
+// matches its digest while writing it, removing the blob when it does not
+func (o *MirrorOptions) unpackBlob(blobPath, dest, layerDigest string, filesInArchive map[string]string) error {
+	expected, err := digest.Parse(layerDigest)
+	if err != nil {
+		return err
+	}
+	archivePath, found := filesInArchive[blobPath]
+	if !found {
+		return &ErrArchiveFileNotFound{blobPath}
+	}
+
+	unpackedPath := filepath.Join(dest, blobPath)
+	var actual digest.Digest
+	err = archive.NewArchiver().Walk(archivePath, func(f archiver.File) error {
+		hdr, ok := f.Header.(*tar.Header)
+		if !ok {
+			return fmt.Errorf("file type not currently implemented %v", f.Header)
+		}
+		if filepath.Clean(hdr.Name) != filepath.Clean(blobPath) || hdr.Typeflag != tar.TypeReg {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(unpackedPath), os.ModePerm); err != nil {
+			return err
+		}
+		out, err := os.Create(filepath.Clean(unpackedPath))
+		if err != nil {
+			return err
+		}
+		// The blob is hashed as it is written
+		digester := expected.Algorithm().Digester()
+		_, err = io.Copy(out, io.TeeReader(f, digester.Hash()))
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("error unpacking blob %s: %v", layerDigest, err)
+		}
+		actual = digester.Digest()
+		return archiver.ErrStopWalk
+	})
+	switch {
+	case err != nil:
+		return err
+	case actual == "":
+		return &ErrArchiveFileNotFound{blobPath}
+	case actual != expected:
+		if err := os.Remove(unpackedPath); err != nil {
+			o.log().Warnf("unable to remove corrupted blob %s: %v", unpackedPath, err)
+		}
+		return &ErrBlobCorrupted{digest: layerDigest, actual: actual.String(), archive: archivePath}
+	}
+	return nil
+}
+
+// mktempImagesDir makes a temporary directory for
+// unpacked images and returns its cleanup method
+func (o *MirrorOptions) mktempImagesDir(dir string) (func(), string, error) {
+	dir, err := ioutil.TempDir(dir, "images.*")
+	return func() {
+		if err := os.RemoveAll(dir); err != nil {
+			o.log().Fatal(err)
+		}
+	}, dir, err
+}
+
+// publishImages uses the `oc mirror` library to mirror generic images
+func (o *MirrorOptions) publishImage(mappings []imgmirror.Mapping, fromDir string) error {
+	var insecure bool
+	if o.DestPlainHTTP || o.DestSkipTLS {
+		insecure = true
+	}
+	// Mirror all file sources of each available image type to mirror registry.
+	if o.log().IsLevelEnabled(logrus.DebugLevel) {
+		var srcs []string
+		for _, m := range mappings {
+			srcs = append(srcs, m.Source.String())
+		}
+		o.log().Debugf("mirroring generic images: %q", srcs)
+	}
+	regctx, err := o.Clients().Context(o.SkipVerification)
+	if err != nil {
+		return fmt.Errorf("error creating registry context: %v", err)
+	}
+
+	genOpts := imgmirror.NewMirrorImageOptions(o.mirrorStreams())
+	genOpts.Mappings = mappings
+	genOpts.DryRun = o.DryRun
+	genOpts.FromFileDir = fromDir
+	genOpts.SkipMissing = o.SkipMissing
+	genOpts.ContinueOnError = o.ContinueOnError
+	// Filter must be a wildcard for publishing because we
+	// cannot filter images within a catalog
+	genOpts.FilterOptions = imagemanifest.FilterOptions{FilterByOS: ".*"}
+	genOpts.SkipMultipleScopes = true
+	genOpts.KeepManifestList = true
+	genOpts.SecurityOptions.CachedContext = regctx
+	genOpts.SecurityOptions.Insecure = insecure
+	if err := genOpts.Validate(); err != nil {
+		return fmt.Errorf("invalid image mirror options: %v", err)
+	}
+	defer o.transfer.time(time.Now())
+	if err := genOpts.Run(); err != nil {
+		return fmt.Errorf("error running generic image mirror: %v", err)
+	}
+
+	return nil
+}
+
+func (o *MirrorOptions) findBlobRepo(assocs image.AssociationSet, layerDigest string) (imagesource.TypedImageReference, error) {
+
+	srcRef := image.GetImageFromBlob(assocs, layerDigest)
+	if srcRef == "" {
+		return imagesource.TypedImageReference{}, fmt.Errorf("layer %q is not present in previous metadata", layerDigest)
+	}
+
+	dstRef, err := imagesource.ParseReference(srcRef)
+	dstRef.Ref.Registry = o.ToMirror
+	dstRef.Ref.Namespace = path.Join(o.UserNamespace, dstRef.Ref.Namespace)
+	return dstRef, err
+
+}
@@ -0,0 +1,330 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/uuid"
+	"github.com/openshift/library-go/pkg/image/reference"
+	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/archive"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
+	"github.com/openshift/oc-mirror/pkg/metadata/storage"
+)
+
+func TestMetadataError(t *testing.T) {
+
+	ctx := context.Background()
+
+	// Set up expected UUIDs
+	gotUUID, err := uuid.Parse("360a43c2-8a14-4b5d-906b-07491459f25f")
+	require.NoError(t, err)
+
+	type fields struct {
+		archivePath string
+	}
+	tests := []struct {
+		name     string
+		metadata string
+		fields   fields
+		want     error
+		wantErr  bool
+	}{
+		{
+			name:     "Invalid/FirstRun",
+			metadata: "",
+			fields: fields{
+				archivePath: "testdata/artifacts/testbundle_seq2.tar",
+			},
+			want:    &SequenceError{1, 2},
+			wantErr: true,
+		},
+		{
+			name:     "Invalid/OutOfOrder",
+			metadata: "testdata/configs/one.json",
+			fields: fields{
+				archivePath: "testdata/artifacts/testbundle_seq3.tar",
+			},
+			want:    &SequenceError{2, 3},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(registry.New())
+			t.Cleanup(server.Close)
+			u, err := url.Parse(server.URL)
+			require.NoError(t, err)
+			markInsecure(t, u.Host)
+
+			tmpdir := t.TempDir()
+
+			opts := &MirrorOptions{
+				Out:         os.Stdout,
+				ErrOut:      os.Stderr,
+				Dir:         tmpdir,
+				DestSkipTLS: true,
+				From:        tt.fields.archivePath,
+				ToMirror:    u.Host,
+			}
+
+			// Copy metadata in place for tests with existing
+			if tt.metadata != "" {
+				t.Log("Copying metadata")
+				input, err := ioutil.ReadFile(tt.metadata)
+				require.NoError(t, err)
+				err = os.MkdirAll(filepath.Join(tmpdir, config.PublishDir), os.ModePerm)
+				require.NoError(t, err)
+				err = ioutil.WriteFile(filepath.Join(tmpdir, config.MetadataBasePath), input, 0644)
+				require.NoError(t, err)
+				err = prepMetadata(ctx, u.Host, tmpdir, gotUUID.String())
+				require.NoError(t, err)
+			}
+
+			_, err = opts.Publish(ctx)
+
+			if !tt.wantErr {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.want.Error())
+			}
+		})
+	}
+}
+
+func TestFindBlobRepo(t *testing.T) {
+	tests := []struct {
+		name string
+
+		digest   string
+		meta     v1alpha2.Metadata
+		options  *MirrorOptions
+		expected imagesource.TypedImageReference
+		err      string
+	}{{
+		name:   "Valid/NoUserNamespace",
+		digest: "found",
+		options: &MirrorOptions{
+			ToMirror: "registry.com",
+		},
+		expected: imagesource.TypedImageReference{
+			Type: "docker",
+			Ref: reference.DockerImageReference{
+				Registry:  "registry.com",
+				Namespace: "test3",
+				Name:      "baz",
+			},
+		},
+	}, {
+		name:   "Valid/UserNamespaceAdded",
+		digest: "found",
+		options: &MirrorOptions{
+			ToMirror:      "registry.com",
+			UserNamespace: "foo",
+		},
+		expected: imagesource.TypedImageReference{
+			Type: "docker",
+			Ref: reference.DockerImageReference{
+				Registry:  "registry.com",
+				Namespace: "foo/test3",
+				Name:      "baz",
+			},
+		},
+	}, {
+		name:   "Invalid/NoRefExisting",
+		digest: "notfound",
+		options: &MirrorOptions{
+			ToMirror: "registry.com",
+		},
+		err: "layer \"notfound\" is not present in previous metadata",
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			assocs := image.AssociationSet{"registry.com/test3/baz": image.Associations{
+				"registry.com/test3/baz": {
+					Name:            "registry.com/test3/baz",
+					Path:            "single_manifest",
+					TagSymlink:      "latest",
+					ID:              "",
+					Type:            v1alpha2.TypeGeneric,
+					ManifestDigests: nil,
+					LayerDigests:    []string{"found"},
+				},
+			},
+			}
+
+			ref, err := test.options.findBlobRepo(assocs, test.digest)
+			if len(test.err) != 0 {
+				require.Equal(t, err.Error(), test.err)
+			} else {
+				require.Equal(t, test.expected, ref)
+			}
+		})
+	}
+}
+
+// prepareMetadata will ensure metadata is in the registry for testing
+func prepMetadata(ctx context.Context, host, dir, uuid string) error {
+	var meta v1alpha2.Metadata
+
+	cfg := v1alpha2.StorageConfig{
+		Registry: &v1alpha2.RegistryConfig{
+			ImageURL: fmt.Sprintf("%s/oc-mirror:%s", host, uuid),
+			SkipTLS:  true,
+		},
+	}
+
+	reg, err := storage.ByConfig(dir, cfg, image.SharedClients())
+	if err != nil {
+		return err
+	}
+	local, err := storage.NewLocalBackend(dir)
+	if err != nil {
+		return err
+	}
+
+	if err := local.ReadMetadata(ctx, &meta, config.MetadataBasePath); err != nil {
+		return err
+	}
+
+	return reg.WriteMetadata(ctx, &meta, dir)
+}
+
+func TestLinkWorkspaceBlob(t *testing.T) {
+	o := &MirrorOptions{Dir: t.TempDir()}
+	layer := "sha256:9e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8"
+	blobDir := filepath.Join(o.Dir, config.SourceDir, config.V2Dir, "ubi8", "ubi", config.BlobDir)
+	require.NoError(t, os.MkdirAll(blobDir, 0750))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(blobDir, layer), []byte("layer"), 0600))
+
+	unpackDir := t.TempDir()
+	dst := filepath.Join(unpackDir, config.V2Dir, "ubi8", "ubi", config.BlobDir, layer)
+	linked, err := o.linkWorkspaceBlob(filepath.Join("ubi8", "ubi"), layer, dst)
+	require.NoError(t, err)
+	require.True(t, linked)
+	data, err := ioutil.ReadFile(dst)
+	require.NoError(t, err)
+	require.Equal(t, "layer", string(data))
+
+	// Blobs missing from the workspace are extracted from the archives
+	linked, err = o.linkWorkspaceBlob(filepath.Join("ubi8", "ubi-minimal"), layer, filepath.Join(unpackDir, "missing"))
+	require.NoError(t, err)
+	require.False(t, linked)
+}
+
+func TestUnpackImageSet(t *testing.T) {
+	// Write a multi-part imageset with one file in each part
+	src := t.TempDir()
+	from := t.TempDir()
+	var exp []string
+	for i := 0; i < 2*unpackWorkers; i++ {
+		name := fmt.Sprintf("file%d", i)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(src, name), []byte(name), 0600))
+		part := filepath.Join(from, fmt.Sprintf("mirror_seq1_%06d.tar", i))
+		require.NoError(t, archive.NewArchiver().Archive([]string{filepath.Join(src, name)}, part))
+		exp = append(exp, name)
+	}
+
+	o := &MirrorOptions{From: from}
+	dest := t.TempDir()
+	require.NoError(t, o.unpackImageSet(context.Background(), archive.NewArchiver(), dest))
+	for _, name := range exp {
+		data, err := ioutil.ReadFile(filepath.Join(dest, name))
+		require.NoError(t, err)
+		require.Equal(t, name, string(data))
+	}
+
+	// Parts that cannot be extracted fail the unpacking
+	require.NoError(t, ioutil.WriteFile(filepath.Join(from, "mirror_seq1_999999.tar"), []byte("corrupt"), 0600))
+	err := o.unpackImageSet(context.Background(), archive.NewArchiver(), t.TempDir())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "mirror_seq1_999999.tar")
+}
+
+func TestFetchBlob(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	markInsecure(t, u.Host)
+
+	layer, err := crane.Layer(map[string][]byte{"file": []byte("content")})
+	require.NoError(t, err)
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+	require.NoError(t, crane.Push(img, fmt.Sprintf("%s/ubi8/ubi:latest", u.Host), crane.Insecure))
+	layerDigest, err := layer.Digest()
+	require.NoError(t, err)
+
+	o := &MirrorOptions{DestPlainHTTP: true}
+	regctx, err := image.SharedClients().Context(false)
+	require.NoError(t, err)
+	ref, err := reference.Parse(fmt.Sprintf("%s/ubi8/ubi", u.Host))
+	require.NoError(t, err)
+
+	// The blob is downloaded once and placed at every path
+	dir := t.TempDir()
+	paths := []string{
+		filepath.Join(dir, "ubi8", "ubi", "blobs", layerDigest.String()),
+		filepath.Join(dir, "ubi8", "ubi-minimal", "blobs", layerDigest.String()),
+	}
+	require.NoError(t, o.fetchBlob(context.Background(), regctx, ref, layerDigest.String(), paths))
+	first, err := os.Stat(paths[0])
+	require.NoError(t, err)
+	second, err := os.Stat(paths[1])
+	require.NoError(t, err)
+	require.True(t, os.SameFile(first, second))
+	size, err := layer.Size()
+	require.NoError(t, err)
+	require.Equal(t, size, first.Size())
+}
+
+func TestUnpackBlob(t *testing.T) {
+	layer := "sha256:dac1d7cfa95021764849fd102524e141488c5e3a90f861dbb5a12d9ac8584f85"
+	other := "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	from := t.TempDir()
+	archivePath := filepath.Join(from, "mirror_seq1_000000.tar")
+	writeTestArchive(t, archivePath, map[string]string{
+		"blobs/" + layer: "layer",
+		"blobs/" + other: "corrupt",
+	})
+	filesInArchive := map[string]string{
+		filepath.Join("blobs", layer): archivePath,
+		filepath.Join("blobs", other): archivePath,
+	}
+
+	dest := t.TempDir()
+	require.NoError(t, NewMirrorOptions().unpackBlob(filepath.Join("blobs", layer), dest, layer, filesInArchive))
+	data, err := ioutil.ReadFile(filepath.Join(dest, "blobs", layer))
+	require.NoError(t, err)
+	require.Equal(t, "layer", string(data))
+
+	// Corrupted blobs are not left in the unpack directory
+	err = NewMirrorOptions().unpackBlob(filepath.Join("blobs", other), dest, other, filesInArchive)
+	cerr := &ErrBlobCorrupted{}
+	require.ErrorAs(t, err, &cerr)
+	require.EqualError(t, err, "blob "+other+" in archive mirror_seq1_000000.tar is corrupted: "+
+		"content has digest sha256:11d510e067d2cdcd7559bd86d27a2f4c20babd43670346b97af99b522c1f0075")
+	_, err = os.Stat(filepath.Join(dest, "blobs", other))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	// Blobs missing from the archive are reported as such
+	aerr := &ErrArchiveFileNotFound{}
+	err = NewMirrorOptions().unpackBlob(filepath.Join("blobs", "sha256:missing"), dest, layer, filesInArchive)
+	require.ErrorAs(t, err, &aerr)
+}
@@ -0,0 +1,278 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	units "github.com/docker/go-units"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+const (
+	// quotaCheckWarn warns when the imageset may not fit in the destination quota
+	quotaCheckWarn = "warn"
+	// quotaCheckFail fails the run when the imageset may not fit in the destination quota
+	quotaCheckFail = "fail"
+	// quotaCheckSkip does not check the destination quota
+	quotaCheckSkip = "skip"
+
+	// quayAPITokenEnv is the environment variable holding
+	// the OAuth token used to query the Quay API
+	quayAPITokenEnv = "QUAY_API_TOKEN"
+
+	// quayRejectLimit is the type of Quay quota limit
+	// above which pushes are rejected
+	quayRejectLimit = "Reject"
+)
+
+// quayQuotaTimeout is the timeout of the Quay API requests
+var quayQuotaTimeout = 30 * time.Second
+
+// quayQuota is the storage quota of a Quay organization
+type quayQuota struct {
+	// used is the storage consumed by the organization
+	used int64
+	// capacity is the storage the organization
+	// can consume before pushes are rejected
+	capacity int64
+}
+
+// remaining returns the storage left before pushes are rejected
+func (q quayQuota) remaining() int64 {
+	if q.used >= q.capacity {
+		return 0
+	}
+	return q.capacity - q.used
+}
+
+// quayClient queries the quota of organizations of a Quay registry
+type quayClient struct {
+	client  *http.Client
+	baseURL string
+	token   string
+	log     logrus.FieldLogger
+}
+
+// newQuayClient returns the client of the Quay API of the destination registry
+func (o *MirrorOptions) newQuayClient() *quayClient {
+	scheme := "https"
+	if o.DestPlainHTTP {
+		scheme = "http"
+	}
+	return &quayClient{
+		client:  &http.Client{Transport: o.Clients().Transport(o.DestSkipTLS), Timeout: quayQuotaTimeout},
+		baseURL: scheme + "://" + o.ToMirror,
+		token:   os.Getenv(quayAPITokenEnv),
+		log:     o.log(),
+	}
+}
+
+// quota returns the quota of the organization, or nil if the registry is
+// not Quay, the organization has no quota, or the quota is not visible
+func (c *quayClient) quota(ctx context.Context, org string) (*quayQuota, error) {
+	var orgInfo struct {
+		QuotaReport *struct {
+			QuotaBytes      int64 `json:"quota_bytes"`
+			ConfiguredQuota int64 `json:"configured_quota"`
+		} `json:"quota_report"`
+	}
+	found, err := c.get(ctx, "/api/v1/organization/"+url.PathEscape(org), &orgInfo)
+	if err != nil || !found {
+		return nil, err
+	}
+	if orgInfo.QuotaReport == nil || orgInfo.QuotaReport.ConfiguredQuota <= 0 {
+		return nil, nil
+	}
+	quota := &quayQuota{
+		used:     orgInfo.QuotaReport.QuotaBytes,
+		capacity: orgInfo.QuotaReport.ConfiguredQuota,
+	}
+
+	// Pushes are rejected at a percentage of the configured quota
+	var quotas []struct {
+		LimitBytes int64 `json:"limit_bytes"`
+		Limits     []struct {
+			Type         string  `json:"type"`
+			LimitPercent float64 `json:"limit_percent"`
+		} `json:"limits"`
+	}
+	if found, err := c.get(ctx, "/api/v1/organization/"+url.PathEscape(org)+"/quota", &quotas); err != nil || !found {
+		c.log.Debugf("unable to read quota limits of Quay organization %q, assuming pushes are rejected at 100%%: %v", org, err)
+		return quota, nil
+	}
+	for _, q := range quotas {
+		for _, limit := range q.Limits {
+			if limit.Type == quayRejectLimit && limit.LimitPercent > 0 {
+				quota.capacity = int64(float64(q.LimitBytes) * limit.LimitPercent / 100)
+			}
+		}
+	}
+	return quota, nil
+}
+
+// get decodes the JSON response of the Quay API endpoint into v and
+// returns false if the endpoint does not exist or is not accessible
+func (c *quayClient) get(ctx context.Context, endpoint string, v interface{}) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+endpoint, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode >= 500:
+		return false, fmt.Errorf("unexpected status %s", resp.Status)
+	default:
+		// Registries other than Quay, organizations that do not exist,
+		// and organizations the token cannot read are not checked
+		c.log.Debugf("skipping quota check of %s: %s", endpoint, resp.Status)
+		return false, nil
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		return false, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return false, fmt.Errorf("error decoding %s: %v", endpoint, err)
+	}
+	return true, nil
+}
+
+// destinationOrgs returns the Quay organizations of the destination repositories
+func destinationOrgs(repos []string) []string {
+	set := map[string]struct{}{}
+	for _, repo := range repos {
+		org := strings.SplitN(strings.TrimPrefix(repo, "/"), "/", 2)[0]
+		if org != "" {
+			set[org] = struct{}{}
+		}
+	}
+	orgs := make([]string, 0, len(set))
+	for org := range set {
+		orgs = append(orgs, org)
+	}
+	sort.Strings(orgs)
+	return orgs
+}
+
+// checkDestinationQuota checks that the data to push fits in the remaining quota
+// of the Quay organizations of the destination repositories before pushing.
+// The size of the data is only computed when a quota is found, and is an upper
+// bound since blobs already in the destination do not consume quota.
+func (o *MirrorOptions) checkDestinationQuota(ctx context.Context, repos []string, size func() (int64, error)) error {
+	if o.QuotaCheck == quotaCheckSkip {
+		return nil
+	}
+	client := o.newQuayClient()
+	var total int64 = -1
+	for _, org := range destinationOrgs(repos) {
+		quota, err := client.quota(ctx, org)
+		if err != nil {
+			o.log().Warnf("unable to check the quota of destination namespace %q: %v", org, err)
+			continue
+		}
+		if quota == nil {
+			continue
+		}
+		if total < 0 {
+			if total, err = size(); err != nil {
+				o.log().Warnf("unable to estimate the size of the imageset for the quota check: %v", err)
+				return nil
+			}
+		}
+		remaining := quota.remaining()
+		if total <= remaining {
+			o.log().Infof("Imageset of up to %s fits in the remaining quota of %s of Quay organization %q",
+				units.BytesSize(float64(total)), units.BytesSize(float64(remaining)), org)
+			continue
+		}
+		err = fmt.Errorf("imageset of up to %s may not fit in the remaining quota of %s of Quay organization %q "+
+			"(%s of %s used): increase the quota or mirror fewer images",
+			units.BytesSize(float64(total)), units.BytesSize(float64(remaining)), org,
+			units.BytesSize(float64(quota.used)), units.BytesSize(float64(quota.capacity)))
+		if o.QuotaCheck == quotaCheckFail {
+			return err
+		}
+		o.log().Warn(err)
+	}
+	return nil
+}
+
+// mappingDestinations returns the destination repositories of the mapping
+func mappingDestinations(mapping image.TypedImageMapping) []string {
+	var repos []string
+	for _, dst := range mapping {
+		repos = append(repos, path.Join(dst.Ref.Namespace, dst.Ref.Name))
+	}
+	return repos
+}
+
+// remoteMappingSize returns the total size of the unique blobs of the source images of the mapping
+func (o *MirrorOptions) remoteMappingSize(ctx context.Context, mapping image.TypedImageMapping) (int64, error) {
+	var mu sync.Mutex
+	blobs := image.BlobSizes{}
+	err := o.remoteMappingBlobs(ctx, mapping, func(_, _ image.TypedImage, imgBlobs image.ImageBlobs) error {
+		mu.Lock()
+		blobs.Add(imgBlobs)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return blobs.Size(), nil
+}
+
+// remoteMappingBlobs queries the blobs of the source images of the mapping
+// concurrently and calls fn with each source and destination image and its blobs.
+// Images that cannot be queried are skipped, since mirroring reports them.
+func (o *MirrorOptions) remoteMappingBlobs(ctx context.Context, mapping image.TypedImageMapping, fn func(src, dst image.TypedImage, blobs image.ImageBlobs) error) error {
+	work := make(chan image.TypedImage)
+	g, ctx := errgroup.WithContext(ctx)
+	for i := 0; i < analyzeWorkers; i++ {
+		g.Go(func() error {
+			for src := range work {
+				imgBlobs, err := o.Clients().GetRemoteImageBlobs(ctx, src.Ref, o.SourceSkipTLS, o.SourcePlainHTTP, o.SkipVerification)
+				if err != nil {
+					o.log().Debugf("skipping image %s: error getting its blobs: %v", src.Ref.Exact(), err)
+					continue
+				}
+				if err := fn(src, mapping[src], imgBlobs); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	g.Go(func() error {
+		defer close(work)
+		for src := range mapping {
+			select {
+			case work <- src:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	return g.Wait()
+}
@@ -0,0 +1,130 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newFakeQuay(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body interface{}
+		switch r.URL.Path {
+		case "/api/v1/organization/full":
+			body = map[string]interface{}{
+				"name":         "full",
+				"quota_report": map[string]int64{"quota_bytes": 900, "configured_quota": 1000},
+			}
+		case "/api/v1/organization/full/quota":
+			body = []map[string]interface{}{{
+				"limit_bytes": 1000,
+				"limits": []map[string]interface{}{
+					{"type": "Warning", "limit_percent": 50},
+					{"type": "Reject", "limit_percent": 95},
+				},
+			}}
+		case "/api/v1/organization/empty":
+			body = map[string]interface{}{
+				"name":         "empty",
+				"quota_report": map[string]int64{"quota_bytes": 0, "configured_quota": 1000},
+			}
+		case "/api/v1/organization/noquota":
+			body = map[string]interface{}{"name": "noquota"}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCheckDestinationQuota(t *testing.T) {
+	server := newFakeQuay(t)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	type spec struct {
+		name       string
+		quotaCheck string
+		repos      []string
+		size       int64
+		expSized   bool
+		expError   string
+	}
+	cases := []spec{
+		{
+			name:       "Valid/Fits",
+			quotaCheck: quotaCheckFail,
+			repos:      []string{"empty/ubi8/ubi"},
+			size:       500,
+			expSized:   true,
+		},
+		{
+			name:       "Valid/Warn",
+			quotaCheck: quotaCheckWarn,
+			repos:      []string{"full/ubi8/ubi"},
+			size:       500,
+			expSized:   true,
+		},
+		{
+			name:       "Valid/Skip",
+			quotaCheck: quotaCheckSkip,
+			repos:      []string{"full/ubi8/ubi"},
+			size:       500,
+		},
+		{
+			name:       "Valid/NoQuota",
+			quotaCheck: quotaCheckFail,
+			repos:      []string{"noquota/ubi8/ubi", "missing/ubi8/ubi"},
+			size:       500,
+		},
+		{
+			name:       "Invalid/Fail",
+			quotaCheck: quotaCheckFail,
+			repos:      []string{"empty/ubi8/ubi", "full/ubi8/ubi"},
+			size:       500,
+			expSized:   true,
+			expError: `imageset of up to 500B may not fit in the remaining quota of 50B of Quay organization "full" ` +
+				`(900B of 950B used): increase the quota or mirror fewer images`,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			o := &MirrorOptions{
+				ToMirror:      u.Host,
+				DestPlainHTTP: true,
+				QuotaCheck:    c.quotaCheck,
+			}
+			sized := 0
+			err := o.checkDestinationQuota(context.Background(), c.repos, func() (int64, error) {
+				sized++
+				return c.size, nil
+			})
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+			} else {
+				require.NoError(t, err)
+			}
+			// The size is only computed once when a quota is found
+			if c.expSized {
+				require.Equal(t, 1, sized)
+			} else {
+				require.Equal(t, 0, sized)
+			}
+		})
+	}
+}
+
+func TestDestinationOrgs(t *testing.T) {
+	orgs := destinationOrgs([]string{"org2/ubi8/ubi", "/org1/ubi8/ubi-minimal", "org1/openshift/release", ""})
+	require.Equal(t, []string{"org1", "org2"}, orgs)
+}
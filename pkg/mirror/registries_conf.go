@@ -0,0 +1,77 @@
+package mirror
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	operatorv1alpha1 "github.com/openshift/api/operator/v1alpha1"
+)
+
+const (
+	// registriesConfDir is the registries.conf drop-in directory
+	registriesConfDir  = "registries.conf.d"
+	registriesConfFile = "99-oc-mirror.conf"
+)
+
+// writeRegistriesConf writes a registries.conf drop-in with mirrors
+// equivalent to the ImageContentSourcePolicies, for hosts that
+// cannot consume cluster resources (e.g. bootstrap and bastion hosts)
+func (o *MirrorOptions) writeRegistriesConf(dir string, icsps []operatorv1alpha1.ImageContentSourcePolicy) error {
+	if len(icsps) == 0 {
+		o.log().Debug("No ICSPs generated, skipping registries.conf")
+		return nil
+	}
+
+	confDir := filepath.Join(dir, containersConfigDir, registriesConfDir)
+	if err := os.MkdirAll(confDir, 0750); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(confDir, registriesConfFile), generateRegistriesConf(icsps), 0640); err != nil {
+		return fmt.Errorf("error writing registries.conf: %v", err)
+	}
+	o.log().Infof("Wrote registries.conf to %s", confDir)
+	return nil
+}
+
+// generateRegistriesConf creates the registries.conf (v2) content for the ICSPs
+func generateRegistriesConf(icsps []operatorv1alpha1.ImageContentSourcePolicy) []byte {
+	mirrorsBySource := map[string][]string{}
+	for _, icsp := range icsps {
+		for _, rdm := range icsp.Spec.RepositoryDigestMirrors {
+			for _, mirror := range rdm.Mirrors {
+				if !containsString(mirrorsBySource[rdm.Source], mirror) {
+					mirrorsBySource[rdm.Source] = append(mirrorsBySource[rdm.Source], mirror)
+				}
+			}
+		}
+	}
+
+	sources := make([]string, 0, len(mirrorsBySource))
+	for source := range mirrorsBySource {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by oc-mirror\n")
+	for _, source := range sources {
+		fmt.Fprintf(&buf, "\n[[registry]]\n  prefix = \"\"\n  location = %q\n  mirror-by-digest-only = true\n", source)
+		for _, mirror := range mirrorsBySource[source] {
+			fmt.Fprintf(&buf, "\n  [[registry.mirror]]\n    location = %q\n", mirror)
+		}
+	}
+	return buf.Bytes()
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
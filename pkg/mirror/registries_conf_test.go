@@ -0,0 +1,48 @@
+package mirror
+
+import (
+	"testing"
+
+	operatorv1alpha1 "github.com/openshift/api/operator/v1alpha1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateRegistriesConf(t *testing.T) {
+	expConf := `# Generated by oc-mirror
+
+[[registry]]
+  prefix = ""
+  location = "quay.io/openshift-release-dev/ocp-release"
+  mirror-by-digest-only = true
+
+  [[registry.mirror]]
+    location = "reg.mirror.com/openshift/release-images"
+
+[[registry]]
+  prefix = ""
+  location = "registry.redhat.io/ubi8"
+  mirror-by-digest-only = true
+
+  [[registry.mirror]]
+    location = "reg.mirror.com/ubi8"
+`
+
+	icsps := []operatorv1alpha1.ImageContentSourcePolicy{
+		{
+			Spec: operatorv1alpha1.ImageContentSourcePolicySpec{
+				RepositoryDigestMirrors: []operatorv1alpha1.RepositoryDigestMirrors{
+					{Source: "registry.redhat.io/ubi8", Mirrors: []string{"reg.mirror.com/ubi8"}},
+				},
+			},
+		},
+		{
+			Spec: operatorv1alpha1.ImageContentSourcePolicySpec{
+				RepositoryDigestMirrors: []operatorv1alpha1.RepositoryDigestMirrors{
+					{Source: "quay.io/openshift-release-dev/ocp-release", Mirrors: []string{"reg.mirror.com/openshift/release-images"}},
+					{Source: "registry.redhat.io/ubi8", Mirrors: []string{"reg.mirror.com/ubi8"}},
+				},
+			},
+		},
+	}
+	require.Equal(t, expConf, string(generateRegistriesConf(icsps)))
+}
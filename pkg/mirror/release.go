@@ -19,7 +19,7 @@ import (
 	"github.com/openshift/library-go/pkg/verify/store/sigstore"
 	"github.com/openshift/library-go/pkg/verify/util"
 	"github.com/openshift/oc/pkg/cli/admin/release"
-	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 
 	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
@@ -37,6 +37,9 @@ const (
 
 	// signatureFileNameFmt defines format of the release image signature file name.
 	signatureFileNameFmt = "signature-%s-%s.json"
+
+	// verifierPublicKeyPrefix is the verifier ConfigMap data key prefix for public keys
+	verifierPublicKeyPrefix = "verifier-public-key-"
 )
 
 // ReleaseOptions configures either a Full or Diff mirror operation
@@ -69,6 +72,7 @@ func (o *ReleaseOptions) Plan(ctx context.Context, lastRun v1alpha2.PastMirror,
 	var (
 		srcDir           = filepath.Join(o.Dir, config.SourceDir)
 		releaseDownloads = downloads{}
+		okdDownloads     = downloads{}
 		mmapping         = image.TypedImageMapping{}
 		errs             = []error{}
 	)
@@ -78,19 +82,24 @@ func (o *ReleaseOptions) Plan(ctx context.Context, lastRun v1alpha2.PastMirror,
 		prevChannels[ch.ReleaseChannel] = ch.MinVersion
 	}
 
-	for _, arch := range o.arch {
+	for _, arch := range o.getArchitectures(cfg.Mirror.Platform.Channels) {
 
 		versionsByChannel := make(map[string]v1alpha2.ReleaseChannel, len(cfg.Mirror.Platform.Channels))
 
 		for _, ch := range cfg.Mirror.Platform.Channels {
 
+			if !o.hasArchitecture(ch, arch) {
+				o.log().Debugf("Skipping architecture %s for channel %s", arch, ch.Name)
+				continue
+			}
+
 			var client cincinnati.Client
 			var err error
 			switch ch.Type {
 			case v1alpha2.TypeOCP:
-				client, err = cincinnati.NewOCPClient(o.uuid)
+				client, err = cincinnati.NewOCPClient(o.uuid, o.Clients())
 			case v1alpha2.TypeOKD:
-				client, err = cincinnati.NewOKDClient(o.uuid)
+				client, err = cincinnati.NewOKDClient(o.uuid, o.Clients())
 			default:
 				errs = append(errs, fmt.Errorf("invalid platform type %v", ch.Type))
 				continue
@@ -112,7 +121,7 @@ func (o *ReleaseOptions) Plan(ctx context.Context, lastRun v1alpha2.PastMirror,
 
 					// Update version to release channel
 					ch.MaxVersion = latest.String()
-					logrus.Debugf("Detected minimum version as %s", ch.MaxVersion)
+					o.log().Debugf("Detected minimum version as %s", ch.MaxVersion)
 					if len(ch.MinVersion) == 0 && ch.IsHeadsOnly() {
 						min, found := prevChannels[ch.Name]
 						if !found {
@@ -120,7 +129,7 @@ func (o *ReleaseOptions) Plan(ctx context.Context, lastRun v1alpha2.PastMirror,
 							min = latest.String()
 						}
 						ch.MinVersion = min
-						logrus.Debugf("Detected minimum version as %s", ch.MinVersion)
+						o.log().Debugf("Detected minimum version as %s", ch.MinVersion)
 					}
 				}
 
@@ -133,7 +142,7 @@ func (o *ReleaseOptions) Plan(ctx context.Context, lastRun v1alpha2.PastMirror,
 						continue
 					}
 					ch.MinVersion = first.String()
-					logrus.Debugf("Detected minimum version as %s", ch.MinVersion)
+					o.log().Debugf("Detected minimum version as %s", ch.MinVersion)
 				}
 				versionsByChannel[ch.Name] = ch
 			} else {
@@ -141,7 +150,7 @@ func (o *ReleaseOptions) Plan(ctx context.Context, lastRun v1alpha2.PastMirror,
 				// is skipped when processing release metadata.
 				// QUESTION(jpower432): This is enforced during config validation
 				// for catalogs. Should we do the same here?
-				logrus.Debugf("Processing minimum version %s and maximum version %s", ch.MinVersion, ch.MaxVersion)
+				o.log().Debugf("Processing minimum version %s and maximum version %s", ch.MinVersion, ch.MaxVersion)
 				ch.Full = true
 				versionsByChannel[ch.Name] = ch
 			}
@@ -152,6 +161,9 @@ func (o *ReleaseOptions) Plan(ctx context.Context, lastRun v1alpha2.PastMirror,
 				continue
 			}
 			releaseDownloads.Merge(downloads)
+			if ch.Type == v1alpha2.TypeOKD {
+				okdDownloads.Merge(downloads)
+			}
 		}
 
 		// Update cfg release channels with maximum and minimum versions
@@ -163,8 +175,15 @@ func (o *ReleaseOptions) Plan(ctx context.Context, lastRun v1alpha2.PastMirror,
 			}
 		}
 
-		if len(cfg.Mirror.Platform.Channels) > 1 {
-			newDownloads, err := o.getCrossChannelDownloads(ctx, arch, cfg.Mirror.Platform.Channels)
+		var archChannels []v1alpha2.ReleaseChannel
+		for _, ch := range cfg.Mirror.Platform.Channels {
+			if o.hasArchitecture(ch, arch) {
+				archChannels = append(archChannels, ch)
+			}
+		}
+
+		if len(archChannels) > 1 {
+			newDownloads, err := o.getCrossChannelDownloads(ctx, arch, archChannels)
 			if err != nil {
 				errs = append(errs, err)
 				continue
@@ -172,12 +191,34 @@ func (o *ReleaseOptions) Plan(ctx context.Context, lastRun v1alpha2.PastMirror,
 			releaseDownloads.Merge(newDownloads)
 		}
 	}
+	for _, path := range cfg.Mirror.Platform.UpgradePaths {
+		archs := path.Architectures
+		if len(archs) == 0 {
+			archs = o.arch
+		}
+		for _, arch := range archs {
+			newDownloads, err := o.getUpgradePathDownloads(ctx, path, arch)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			releaseDownloads.Merge(newDownloads)
+		}
+	}
+
+	// Explicitly requested payloads are not looked up in Cincinnati.
+	// Their referenced images are resolved from the payload itself.
+	for _, rel := range cfg.Mirror.Platform.Releases {
+		o.log().Debugf("Adding release payload %s", rel.Name)
+		releaseDownloads[rel.Name] = struct{}{}
+	}
+
 	if len(errs) != 0 {
 		return mmapping, utilerrors.NewAggregate(errs)
 	}
 
 	for img := range releaseDownloads {
-		logrus.Debugf("Starting release download for version %s", img)
+		o.log().Debugf("Starting release download for version %s", img)
 		opts, err := o.newMirrorReleaseOptions(srcDir)
 		if err != nil {
 			return mmapping, err
@@ -193,15 +234,66 @@ func (o *ReleaseOptions) Plan(ctx context.Context, lastRun v1alpha2.PastMirror,
 		mmapping.Merge(mappings)
 	}
 
-	err := o.generateReleaseSignatures(releaseDownloads)
+	// OKD payloads are not signed with the Red Hat release keys,
+	// so signatures are only gathered for OCP releases.
+	ocpDownloads := downloads{}
+	for img := range releaseDownloads {
+		if _, found := okdDownloads[img]; found {
+			o.log().Debugf("Skipping signature retrieval for OKD release %s", img)
+			continue
+		}
+		ocpDownloads[img] = struct{}{}
+	}
+
+	err := o.generateReleaseSignatures(ocpDownloads, cfg.Mirror.Platform.Verification)
 
 	if err != nil {
 		return nil, err
 	}
 
+	// Clients are only published for OCP releases
+	if len(cfg.Mirror.Platform.Clients.OperatingSystems) != 0 {
+		if err := o.downloadClients(ctx, ocpDownloads, cfg.Mirror.Platform.Clients); err != nil {
+			return nil, err
+		}
+	}
+
 	return mmapping, nil
 }
 
+// getArchitectures returns all architectures requested across the channels
+// with the command line architectures used for any channel without them.
+func (o *ReleaseOptions) getArchitectures(channels []v1alpha2.ReleaseChannel) []string {
+	var archs []string
+	seen := map[string]struct{}{}
+	for _, ch := range channels {
+		for _, arch := range o.channelArchitectures(ch) {
+			if _, found := seen[arch]; !found {
+				seen[arch] = struct{}{}
+				archs = append(archs, arch)
+			}
+		}
+	}
+	return archs
+}
+
+// hasArchitecture determines if the architecture should be mirrored for the channel
+func (o *ReleaseOptions) hasArchitecture(channel v1alpha2.ReleaseChannel, arch string) bool {
+	for _, a := range o.channelArchitectures(channel) {
+		if a == arch {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *ReleaseOptions) channelArchitectures(channel v1alpha2.ReleaseChannel) []string {
+	if len(channel.Architectures) != 0 {
+		return channel.Architectures
+	}
+	return o.arch
+}
+
 // getDownloads will prepare the downloads map for mirroring
 func (o *ReleaseOptions) getChannelDownloads(ctx context.Context, c cincinnati.Client, lastChannels []v1alpha2.ReleaseChannel, channel v1alpha2.ReleaseChannel, arch string) (downloads, error) {
 	allDownloads := downloads{}
@@ -228,7 +320,7 @@ func (o *ReleaseOptions) getChannelDownloads(ctx context.Context, c cincinnati.C
 			if err != nil {
 				return allDownloads, err
 			}
-			newDownloads := gatherUpdates(current, newest, updates)
+			newDownloads := o.gatherUpdates(current, newest, updates)
 			allDownloads.Merge(newDownloads)
 		}
 
@@ -246,7 +338,7 @@ func (o *ReleaseOptions) getChannelDownloads(ctx context.Context, c cincinnati.C
 			if err != nil {
 				return allDownloads, err
 			}
-			newDownloads := gatherUpdates(current, newest, updates)
+			newDownloads := o.gatherUpdates(current, newest, updates)
 			allDownloads.Merge(newDownloads)
 		}
 	}
@@ -267,7 +359,7 @@ func (o *ReleaseOptions) getChannelDownloads(ctx context.Context, c cincinnati.C
 		if err != nil {
 			return allDownloads, err
 		}
-		newDownloads = gatherUpdates(current, newest, updates)
+		newDownloads = o.gatherUpdates(current, newest, updates)
 
 	} else {
 		lowRange, err := semver.ParseRange(fmt.Sprintf(">=%s", first))
@@ -282,7 +374,7 @@ func (o *ReleaseOptions) getChannelDownloads(ctx context.Context, c cincinnati.C
 		if err != nil {
 			return allDownloads, err
 		}
-		newDownloads = gatherUpdates(cincinnati.Update{}, cincinnati.Update{}, versions)
+		newDownloads = o.gatherUpdates(cincinnati.Update{}, cincinnati.Update{}, versions)
 	}
 	allDownloads.Merge(newDownloads)
 
@@ -302,7 +394,7 @@ func (o *ReleaseOptions) getCrossChannelDownloads(ctx context.Context, arch stri
 	if len(ocpChannels) == 0 {
 		return downloads{}, nil
 	}
-	client, err := cincinnati.NewOCPClient(o.uuid)
+	client, err := cincinnati.NewOCPClient(o.uuid, o.Clients())
 	if err != nil {
 		return downloads{}, err
 	}
@@ -319,13 +411,13 @@ func (o *ReleaseOptions) getCrossChannelDownloads(ctx context.Context, arch stri
 	if err != nil {
 		return downloads{}, fmt.Errorf("failed to get upgrade graph: %v", err)
 	}
-	return gatherUpdates(current, newest, updates), nil
+	return o.gatherUpdates(current, newest, updates), nil
 }
 
-func gatherUpdates(current, newest cincinnati.Update, updates []cincinnati.Update) downloads {
+func (o *MirrorOptions) gatherUpdates(current, newest cincinnati.Update, updates []cincinnati.Update) downloads {
 	releaseDownloads := downloads{}
 	for _, update := range updates {
-		logrus.Debugf("Found update %s", update.Version)
+		o.log().Debugf("Found update %s", update.Version)
 		releaseDownloads[update.Image] = struct{}{}
 	}
 
@@ -341,14 +433,14 @@ func gatherUpdates(current, newest cincinnati.Update, updates []cincinnati.Updat
 }
 
 func (o *ReleaseOptions) newMirrorReleaseOptions(fileDir string) (*release.MirrorOptions, error) {
-	opts := release.NewMirrorOptions(o.IOStreams)
+	opts := release.NewMirrorOptions(o.streams())
 	opts.DryRun = o.DryRun
 	opts.ToDir = fileDir
 
 	opts.SecurityOptions.Insecure = o.insecure
 	opts.SecurityOptions.SkipVerification = o.SkipVerification
 
-	regctx, err := image.NewContext(o.SkipVerification)
+	regctx, err := o.Clients().Context(o.SkipVerification)
 	if err != nil {
 		return nil, fmt.Errorf("error creating registry context: %v", err)
 	}
@@ -402,9 +494,7 @@ type downloads map[string]struct{}
 
 func (d downloads) Merge(in downloads) {
 	for k, v := range in {
-		_, ok := d[k]
-		if ok {
-			logrus.Debugf("download %s exists", k)
+		if _, ok := d[k]; ok {
 			continue
 		}
 		d[k] = v
@@ -414,7 +504,7 @@ func (d downloads) Merge(in downloads) {
 //go:embed release-configmap.yaml
 var b []byte
 
-func (o *ReleaseOptions) generateReleaseSignatures(releaseDownloads downloads) error {
+func (o *ReleaseOptions) generateReleaseSignatures(releaseDownloads downloads, verification v1alpha2.ReleaseVerification) error {
 
 	httpClientConstructor := sigstore.NewCachedHTTPClientConstructor(o.HTTPClient, nil)
 
@@ -424,6 +514,13 @@ func (o *ReleaseOptions) generateReleaseSignatures(releaseDownloads downloads) e
 		return err
 	}
 
+	if len(verification.PublicKeys) != 0 {
+		if err := setVerifierPublicKeys(manifests, verification.PublicKeys); err != nil {
+			return err
+		}
+	}
+	enforce := verification.Policy == v1alpha2.VerificationPolicyEnforce
+
 	// Attempt to load a verifier as defined by the release being mirrored
 	imageVerifier, err := verify.NewFromManifests(manifests, httpClientConstructor.HTTPClient)
 
@@ -431,19 +528,44 @@ func (o *ReleaseOptions) generateReleaseSignatures(releaseDownloads downloads) e
 		return err
 	}
 
+	// Signatures in a local bundle are preferred so they can be
+	// provided when the signature stores are unreachable
+	if o.SignatureBundle != "" {
+		bundleStore, cleanup, err := o.newSignatureBundleStore(o.SignatureBundle, o.Dir)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		o.log().Infof("Using release signatures from %s", bundleStore)
+		imageVerifier.AddStore(bundleStore)
+	}
+
 	signatureBasePath := filepath.Join(o.Dir, config.SourceDir, config.ReleaseSignatureDir)
 	if err := os.MkdirAll(signatureBasePath, 0750); err != nil {
 		return err
 	}
 
 	for image := range releaseDownloads {
-		digest := strings.Split(image, "@")[1]
+		split := strings.Split(image, "@")
+		if len(split) != 2 {
+			// Signatures are stored by digest, so tag-only
+			// payloads cannot be looked up
+			if enforce {
+				return fmt.Errorf("release image %s is not pinned by digest and cannot be verified", image)
+			}
+			o.log().Warnf("Release image %s is not pinned by digest, skipping signature retrieval", image)
+			continue
+		}
+		digest := split[1]
 
 		ctx, cancelFn := context.WithCancel(context.Background())
 		defer cancelFn()
 		if err := imageVerifier.Verify(ctx, digest); err != nil {
+			if enforce {
+				return fmt.Errorf("release image %s failed signature verification: %v", image, err)
+			}
 			// This may be a OKD release image hence no valid signature
-			logrus.Warnf("An image was retrieved that failed verification: %v", err)
+			o.log().Warnf("An image was retrieved that failed verification: %v", err)
 			continue
 		}
 
@@ -473,6 +595,38 @@ func (o *ReleaseOptions) generateReleaseSignatures(releaseDownloads downloads) e
 	return nil
 }
 
+// setVerifierPublicKeys replaces the public keys in the verifier
+// ConfigMap manifests with the keys read from keyPaths
+func setVerifierPublicKeys(manifests []manifest.Manifest, keyPaths []string) error {
+	keys := make(map[string]interface{}, len(keyPaths))
+	for i, keyPath := range keyPaths {
+		key, err := ioutil.ReadFile(filepath.Clean(keyPath))
+		if err != nil {
+			return fmt.Errorf("error reading release verification key: %v", err)
+		}
+		keys[fmt.Sprintf("%s%d", verifierPublicKeyPrefix, i)] = string(key)
+	}
+
+	for _, m := range manifests {
+		data, found, err := unstructured.NestedMap(m.Obj.Object, "data")
+		if err != nil || !found {
+			continue
+		}
+		for k := range data {
+			if strings.HasPrefix(k, verifierPublicKeyPrefix) {
+				delete(data, k)
+			}
+		}
+		for k, v := range keys {
+			data[k] = v
+		}
+		if err := unstructured.SetNestedMap(m.Obj.Object, data, "data"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func createSignatureFileName(digest string) (string, error) {
 	parts := strings.SplitN(digest, ":", 3)
 	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
@@ -487,7 +641,7 @@ func createSignatureFileName(digest string) (string, error) {
 }
 
 func (o *ReleaseOptions) HTTPClient() (*http.Client, error) {
-	return &http.Client{}, nil
+	return o.Clients().HTTPClient(), nil
 }
 
 // unpackReleaseSignatures will unpack the release signatures if they exist
@@ -495,11 +649,11 @@ func (o *MirrorOptions) unpackReleaseSignatures(dstDir string, filesInArchive ma
 	if err := unpack(config.ReleaseSignatureDir, dstDir, filesInArchive); err != nil {
 		nferr := &ErrArchiveFileNotFound{}
 		if errors.As(err, &nferr) || errors.Is(err, os.ErrNotExist) {
-			logrus.Debug("No release signatures found in archive, skipping")
+			o.log().Debug("No release signatures found in archive, skipping")
 			return nil
 		}
 		return err
 	}
-	logrus.Infof("Wrote release signatures to %s", dstDir)
+	o.log().Infof("Wrote release signatures to %s", dstDir)
 	return nil
 }
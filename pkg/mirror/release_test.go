@@ -1,14 +1,24 @@
 package mirror
 
 import (
+	"bytes"
 	"context"
+	"crypto"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path/filepath"
 	"testing"
 
 	"github.com/google/uuid"
+	"github.com/openshift/library-go/pkg/manifest"
+	"github.com/openshift/library-go/pkg/verify"
+	"github.com/openshift/library-go/pkg/verify/store/sigstore"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
 
 	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
 	"github.com/openshift/oc-mirror/pkg/cincinnati"
@@ -342,3 +352,46 @@ func getHandlerMulti(t *testing.T, requestQuery chan<- string) http.HandlerFunc
 		}
 	}
 }
+
+func TestGetArchitectures(t *testing.T) {
+	opts := ReleaseOptions{arch: []string{"amd64"}}
+	channels := []v1alpha2.ReleaseChannel{
+		{Name: "stable-4.10"},
+		{Name: "fast-4.10", Architectures: []string{"arm64", "amd64"}},
+		{Name: "candidate-4.10", Architectures: []string{"multi"}},
+	}
+
+	require.Equal(t, []string{"amd64", "arm64", "multi"}, opts.getArchitectures(channels))
+	require.True(t, opts.hasArchitecture(channels[0], "amd64"))
+	require.False(t, opts.hasArchitecture(channels[0], "arm64"))
+	require.True(t, opts.hasArchitecture(channels[1], "arm64"))
+	require.False(t, opts.hasArchitecture(channels[2], "amd64"))
+}
+
+func TestSetVerifierPublicKeys(t *testing.T) {
+	pgpConfig := &packet.Config{DefaultHash: crypto.SHA256}
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", pgpConfig)
+	require.NoError(t, err)
+	keyBuf := &bytes.Buffer{}
+	w, err := armor.Encode(keyBuf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+	keyPath := filepath.Join(t.TempDir(), "key.asc")
+	require.NoError(t, ioutil.WriteFile(keyPath, keyBuf.Bytes(), 0640))
+
+	manifests, err := manifest.ParseManifests(bytes.NewReader(b))
+	require.NoError(t, err)
+	require.NoError(t, setVerifierPublicKeys(manifests, []string{keyPath}))
+
+	verifier, err := verify.NewFromManifests(manifests, sigstore.DefaultClient)
+	require.NoError(t, err)
+	verifiers := verifier.Verifiers()
+	require.Len(t, verifiers, 1)
+	for _, keyring := range verifiers {
+		require.Len(t, keyring, 1)
+		require.Equal(t, entity.PrimaryKey.KeyId, keyring[0].PrimaryKey.KeyId)
+	}
+
+	require.Error(t, setVerifierPublicKeys(manifests, []string{filepath.Join(t.TempDir(), "missing.asc")}))
+}
@@ -0,0 +1,224 @@
+package mirror
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+const (
+	// resultsFile is the name of the run results file in the results directory
+	resultsFile = "results.json"
+	// configSnapshotFile is the name of the copy of the
+	// imageset configuration in the results directory
+	configSnapshotFile = "imageset-config.yaml"
+	// failedImagesFile is the name of the list of images
+	// that failed to mirror in the results directory
+	failedImagesFile = "failed-images.txt"
+)
+
+// newResults returns the results of a run of the operation starting now
+func newResults(operation v1alpha2.Operation) *v1alpha2.Results {
+	results := &v1alpha2.Results{
+		ResultsSpec: v1alpha2.ResultsSpec{
+			Operation: operation,
+			StartTime: metav1.NewTime(time.Now()),
+		},
+	}
+	results.SetGroupVersionKind(v1alpha2.GroupVersion.WithKind(v1alpha2.ResultsKind))
+	return results
+}
+
+// operation returns the workflow selected by the options
+func (o *MirrorOptions) operation() v1alpha2.Operation {
+	switch {
+	case len(o.ToMirror) > 0 && len(o.From) > 0:
+		return v1alpha2.OperationDiskToMirror
+	case len(o.ToMirror) > 0:
+		return v1alpha2.OperationMirrorToMirror
+	default:
+		return v1alpha2.OperationMirrorToDisk
+	}
+}
+
+// addFailure records an error that did not stop the run
+func (o *MirrorOptions) addFailure(err error) {
+	o.failures = append(o.failures, v1alpha2.Failure{Error: err.Error()})
+	o.events.emit(v1alpha2.Event{Type: v1alpha2.EventError, Error: err.Error()})
+}
+
+// completeResults fills in the outcome of the run
+func (o *MirrorOptions) completeResults(results *v1alpha2.Results, mapping image.TypedImageMapping, sequence int, runErr error) error {
+	results.EndTime = metav1.NewTime(time.Now())
+	results.DurationSeconds = results.EndTime.Sub(results.StartTime.Time).Seconds()
+	results.Sequence = sequence
+	results.Success = runErr == nil
+	results.Failures = append(results.Failures, o.failures...)
+	results.Phases = o.phases
+	if o.transfer != nil {
+		for _, msg := range o.transfer.errors {
+			results.Failures = append(results.Failures, v1alpha2.Failure{Error: msg})
+		}
+		results.Transfer = o.transfer.counts()
+	}
+	if runErr != nil {
+		results.Failures = append(results.Failures, v1alpha2.Failure{Error: runErr.Error()})
+	}
+
+	results.Images = countImages(mapping)
+
+	if len(results.Failures) != 0 {
+		path, err := o.writeFailedImages(mapping, results.Failures)
+		if err != nil {
+			return err
+		}
+		results.FailedImages = path
+	}
+
+	if results.Operation == v1alpha2.OperationMirrorToDisk && sequence != 0 && o.OutputDir != "" {
+		archives, err := filepath.Glob(filepath.Join(o.OutputDir, fmt.Sprintf("mirror_seq%d_*.tar", sequence)))
+		if err != nil {
+			return err
+		}
+		sort.Strings(archives)
+		results.Archives = archives
+	}
+
+	if o.resultsDir != "" {
+		manifests, mappings, err := listResultFiles(o.resultsDir)
+		if err != nil {
+			return err
+		}
+		results.Manifests = manifests
+		results.Mappings = append(results.Mappings, mappings...)
+	}
+	return nil
+}
+
+// snapshotConfig copies the imageset configuration of the run
+// to the results directory and records its digest in the results
+func (o *MirrorOptions) snapshotConfig(results *v1alpha2.Results) error {
+	if o.ConfigPath == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(o.ConfigPath)
+	if err != nil {
+		// The run already failed on a missing configuration
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	dir, err := o.createResultsDir()
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, configSnapshotFile), data, 0640); err != nil {
+		return fmt.Errorf("error writing imageset configuration snapshot: %v", err)
+	}
+	results.ConfigDigest = digest.FromBytes(data).String()
+	return nil
+}
+
+// writeFailedImages writes the source=destination pairs of the images in
+// the mapping that failed to the results directory, one pair per line, in the
+// format of image mapping files. It returns the path of the list, or an empty
+// path if no failure names an image of the mapping.
+func (o *MirrorOptions) writeFailedImages(mapping image.TypedImageMapping, failures []v1alpha2.Failure) (string, error) {
+	var lines []string
+	for src, dst := range mapping {
+		if imageFailed(src, dst, failures) {
+			lines = append(lines, fmt.Sprintf("%s=%s", src.TypedImageReference.String(), dst.TypedImageReference.String()))
+		}
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	sort.Strings(lines)
+
+	dir, err := o.createResultsDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, failedImagesFile)
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0640); err != nil {
+		return "", fmt.Errorf("error writing failed images: %v", err)
+	}
+	o.log().Warnf("Wrote %d failed images to %s", len(lines), path)
+	return path, nil
+}
+
+// writeResults writes the results to the results directory of the run
+func (o *MirrorOptions) writeResults(results *v1alpha2.Results) error {
+	dir, err := o.createResultsDir()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal results: %v", err)
+	}
+	path := filepath.Join(dir, resultsFile)
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0640); err != nil {
+		return fmt.Errorf("error writing results: %v", err)
+	}
+	o.log().Infof("Wrote run results to %s", path)
+	if o.LatestLink {
+		if err := updateLatestResultsLink(dir); err != nil {
+			o.log().Warnf("unable to update the latest results link: %v", err)
+		}
+	}
+	return nil
+}
+
+// countImages counts the images in the mapping by type
+func countImages(mapping image.TypedImageMapping) v1alpha2.ImageCounts {
+	counts := v1alpha2.ImageCounts{Total: len(mapping)}
+	for src := range mapping {
+		if counts.ByType == nil {
+			counts.ByType = map[string]int{}
+		}
+		counts.ByType[src.Category.String()]++
+	}
+	return counts
+}
+
+// listResultFiles returns the paths of the manifests
+// and image mapping files generated in dir
+func listResultFiles(dir string) (manifests, mappings []string, err error) {
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || info.Name() == resultsFile || info.Name() == configSnapshotFile || info.Name() == config.MetadataFile {
+			return nil
+		}
+		if info.Name() == mappingFile {
+			mappings = append(mappings, path)
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json", ".conf":
+			manifests = append(manifests, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(manifests)
+	sort.Strings(mappings)
+	return manifests, mappings, nil
+}
@@ -0,0 +1,150 @@
+package mirror
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestWriteResults(t *testing.T) {
+	digest := "@sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8"
+	parse := func(ref string, typ v1alpha2.ImageType) image.TypedImage {
+		img, err := image.ParseTypedImage(ref+digest, typ)
+		require.NoError(t, err)
+		return img
+	}
+	mapping := image.TypedImageMapping{
+		parse("quay.io/openshift-release-dev/ocp-release", v1alpha2.TypeOCPRelease): parse("reg.mirror.com/openshift/release-images", v1alpha2.TypeOCPRelease),
+		parse("registry.redhat.io/ubi8/ubi", v1alpha2.TypeGeneric):                  parse("reg.mirror.com/ubi8/ubi", v1alpha2.TypeGeneric),
+		parse("registry.redhat.io/ubi8/ubi-minimal", v1alpha2.TypeGeneric):          parse("reg.mirror.com/ubi8/ubi-minimal", v1alpha2.TypeGeneric),
+	}
+
+	workspace := t.TempDir()
+	output := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(output, "mirror_seq2_000000.tar"), nil, 0640))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(output, "mirror_seq1_000000.tar"), nil, 0640))
+
+	o := &MirrorOptions{
+		Dir:       workspace,
+		OutputDir: output,
+	}
+	o.addFailure(errors.New("skipped image"))
+
+	results := newResults(o.operation())
+	require.NoError(t, o.completeResults(results, mapping, 2, errors.New("one or more errors occurred")))
+	require.NoError(t, o.writeResults(results))
+
+	data, err := ioutil.ReadFile(filepath.Join(o.resultsDir, resultsFile))
+	require.NoError(t, err)
+	var got v1alpha2.Results
+	require.NoError(t, json.Unmarshal(data, &got))
+
+	require.Equal(t, "mirror.openshift.io/v1alpha2", got.APIVersion)
+	require.Equal(t, v1alpha2.ResultsKind, got.Kind)
+	require.Equal(t, v1alpha2.OperationMirrorToDisk, got.Operation)
+	require.False(t, got.Success)
+	require.Equal(t, 2, got.Sequence)
+	require.Equal(t, []string{filepath.Join(output, "mirror_seq2_000000.tar")}, got.Archives)
+	require.Equal(t, v1alpha2.ImageCounts{
+		Total:  3,
+		ByType: map[string]int{"ocpRelease": 1, "generic": 2},
+	}, got.Images)
+	require.Equal(t, []v1alpha2.Failure{
+		{Error: "skipped image"},
+		{Error: "one or more errors occurred"},
+	}, got.Failures)
+}
+
+func TestWriteFailedImages(t *testing.T) {
+	digest := "@sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8"
+	parse := func(ref string) image.TypedImage {
+		img, err := image.ParseTypedImage(ref+digest, v1alpha2.TypeGeneric)
+		require.NoError(t, err)
+		return img
+	}
+	mapping := image.TypedImageMapping{
+		parse("registry.redhat.io/ubi8/ubi"):         parse("reg.mirror.com/ubi8/ubi"),
+		parse("registry.redhat.io/ubi8/ubi-minimal"): parse("reg.mirror.com/ubi8/ubi-minimal"),
+		parse("registry.redhat.io/ubi8/ubi-micro"):   parse("reg.mirror.com/ubi8/ubi-micro"),
+	}
+
+	type spec struct {
+		name     string
+		failures []v1alpha2.Failure
+		expLines string
+	}
+	cases := []spec{
+		{
+			name:     "Valid/NoImageFailed",
+			failures: []v1alpha2.Failure{{Error: "one or more errors occurred"}},
+		},
+		{
+			name: "Valid/SourceAndDestinationFailed",
+			failures: []v1alpha2.Failure{
+				{Image: "registry.redhat.io/ubi8/ubi-minimal" + digest, Error: "manifest unknown"},
+				{Error: "unauthorized to push reg.mirror.com/ubi8/ubi-micro" + digest},
+			},
+			expLines: "registry.redhat.io/ubi8/ubi-micro" + digest + "=reg.mirror.com/ubi8/ubi-micro" + digest + "\n" +
+				"registry.redhat.io/ubi8/ubi-minimal" + digest + "=reg.mirror.com/ubi8/ubi-minimal" + digest + "\n",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			o := &MirrorOptions{Dir: t.TempDir()}
+			results := newResults(o.operation())
+			o.failures = c.failures
+			require.NoError(t, o.completeResults(results, mapping, 1, nil))
+			if c.expLines == "" {
+				require.Empty(t, results.FailedImages)
+				return
+			}
+			require.Equal(t, filepath.Join(o.resultsDir, failedImagesFile), results.FailedImages)
+			data, err := ioutil.ReadFile(results.FailedImages)
+			require.NoError(t, err)
+			require.Equal(t, c.expLines, string(data))
+		})
+	}
+}
+
+func TestResultsHistory(t *testing.T) {
+	workspace := t.TempDir()
+	cfgPath := filepath.Join(t.TempDir(), "imageset-config.yaml")
+	cfg := []byte("apiVersion: mirror.openshift.io/v1alpha2\nkind: ImageSetConfiguration\n")
+	require.NoError(t, ioutil.WriteFile(cfgPath, cfg, 0640))
+
+	o := &MirrorOptions{
+		Dir:        workspace,
+		ConfigPath: cfgPath,
+	}
+	o.startPhase(phasePlan)(nil)
+	o.startPhase(phaseMirror)(errors.New("mirror failed"))
+
+	results := newResults(o.operation())
+	require.NoError(t, o.completeResults(results, nil, 0, nil))
+	require.NoError(t, o.snapshotConfig(results))
+	require.NoError(t, o.writeResults(results))
+
+	require.Len(t, results.Phases, 2)
+	require.Equal(t, phasePlan, results.Phases[0].Name)
+	require.True(t, results.Phases[0].Success)
+	require.Equal(t, phaseMirror, results.Phases[1].Name)
+	require.False(t, results.Phases[1].Success)
+
+	require.Equal(t, "sha256:1b28d1e13a511b79138639ebbca4619d7b9b2305c31ac314da2dd076ea134df5", results.ConfigDigest)
+	snapshot, err := ioutil.ReadFile(filepath.Join(o.resultsDir, configSnapshotFile))
+	require.NoError(t, err)
+	require.Equal(t, cfg, snapshot)
+
+	// The snapshot is not a generated manifest
+	manifests, _, err := listResultFiles(o.resultsDir)
+	require.NoError(t, err)
+	require.Empty(t, manifests)
+}
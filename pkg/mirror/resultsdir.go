@@ -0,0 +1,156 @@
+package mirror
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// defaultResultsDirTemplate is the default name template of the results directories
+	defaultResultsDirTemplate = "results-{{.Timestamp}}"
+	// resultsSequenceFile records the number of the last run in the workspace
+	resultsSequenceFile = ".results-sequence"
+	// latestResultsLink is the link to the results directory of the most recent run
+	latestResultsLink = "latest"
+)
+
+// resultsDirData is the data the results directory name template is executed with
+type resultsDirData struct {
+	// Timestamp is the start time of the run in Unix seconds
+	Timestamp int64
+	// Time is the start time of the run
+	Time time.Time
+	// Sequence is the number of the run in the workspace, starting at 1
+	Sequence int
+	// Config is the imageset configuration file name without its extension
+	Config string
+	// Operation is the operation of the run
+	Operation string
+}
+
+// parseResultsDirTemplate parses the results directory name template and checks
+// that it renders to a single path element
+func parseResultsDirTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("results-dir").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid results directory template: %v", err)
+	}
+	if _, err := renderResultsDirName(tmpl, resultsDirData{Time: time.Unix(0, 0), Sequence: 1, Config: "imageset-config"}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// renderResultsDirName executes the results directory name template
+func renderResultsDirName(tmpl *template.Template, data resultsDirData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("invalid results directory template: %v", err)
+	}
+	name := strings.TrimSpace(buf.String())
+	switch {
+	case name == "", name == ".", name == "..":
+		return "", fmt.Errorf("results directory template %q must render to a directory name", tmpl.Root.String())
+	case strings.ContainsAny(name, `/\`):
+		return "", fmt.Errorf("results directory template %q must not render to a path: %q", tmpl.Root.String(), name)
+	case name == latestResultsLink:
+		return "", fmt.Errorf("results directory name %q is reserved", latestResultsLink)
+	}
+	return name, nil
+}
+
+// resultsDirName returns the name of the results directory of the run
+// started at the given time
+func (o *MirrorOptions) resultsDirName(start time.Time) (string, error) {
+	text := o.ResultsDirTemplate
+	if text == "" {
+		text = defaultResultsDirTemplate
+	}
+	tmpl, err := parseResultsDirTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	data := resultsDirData{
+		Timestamp: start.Unix(),
+		Time:      start,
+		Operation: string(o.operation()),
+	}
+	if o.ConfigPath != "" {
+		base := filepath.Base(o.ConfigPath)
+		data.Config = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	// The sequence is only counted when the template uses it
+	if strings.Contains(text, ".Sequence") {
+		if data.Sequence, err = nextResultsSequence(o.Dir); err != nil {
+			return "", err
+		}
+	}
+	return renderResultsDirName(tmpl, data)
+}
+
+// nextResultsSequence increments and returns the run number recorded in the workspace
+func nextResultsSequence(workspace string) (int, error) {
+	if err := os.MkdirAll(workspace, os.ModePerm); err != nil {
+		return 0, err
+	}
+	path := filepath.Join(workspace, resultsSequenceFile)
+	var sequence int
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case err == nil:
+		if sequence, err = strconv.Atoi(strings.TrimSpace(string(data))); err != nil {
+			return 0, fmt.Errorf("invalid results sequence in %s: %v", path, err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return 0, err
+	}
+	sequence++
+	if err := ioutil.WriteFile(path, []byte(strconv.Itoa(sequence)+"\n"), 0640); err != nil {
+		return 0, fmt.Errorf("error writing results sequence: %v", err)
+	}
+	return sequence, nil
+}
+
+// uniqueDir returns the path unchanged if it does not exist, and otherwise the first
+// path with a -2, -3, ... suffix that does not exist, so runs never share results
+func uniqueDir(path string) (string, error) {
+	candidate := path
+	for i := 2; ; i++ {
+		_, err := os.Lstat(candidate)
+		if errors.Is(err, os.ErrNotExist) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s-%d", path, i)
+	}
+}
+
+// updateLatestResultsLink points the latest link of the workspace
+// to the results directory, replacing the previous link atomically
+func updateLatestResultsLink(resultsDir string) error {
+	workspace := filepath.Dir(resultsDir)
+	link := filepath.Join(workspace, latestResultsLink)
+	if info, err := os.Lstat(link); err == nil && info.Mode()&os.ModeSymlink == 0 {
+		return fmt.Errorf("%s exists and is not a symbolic link", link)
+	}
+	tmp := filepath.Join(workspace, fmt.Sprintf(".%s.%d", latestResultsLink, time.Now().UnixNano()))
+	// The link is relative so it survives moving the workspace
+	if err := os.Symlink(filepath.Base(resultsDir), tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
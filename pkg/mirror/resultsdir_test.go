@@ -0,0 +1,114 @@
+package mirror
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultsDirName(t *testing.T) {
+	start := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	type spec struct {
+		name     string
+		template string
+		expNames []string
+		expError string
+	}
+	cases := []spec{
+		{
+			name:     "Valid/Default",
+			expNames: []string{"results-1672628645", "results-1672628645"},
+		},
+		{
+			name:     "Valid/ConfigSequence",
+			template: "{{.Config}}-{{.Sequence}}",
+			expNames: []string{"imageset-config-1", "imageset-config-2"},
+		},
+		{
+			name:     "Valid/TimeOperation",
+			template: `{{.Operation}}-{{.Time.Format "20060102-150405"}}`,
+			expNames: []string{"mirrorToDisk-20230102-030405"},
+		},
+		{
+			name:     "Invalid/Path",
+			template: "results/{{.Timestamp}}",
+			expError: `results directory template "results/{{.Timestamp}}" must not render to a path: "results/0"`,
+		},
+		{
+			name:     "Invalid/Empty",
+			template: "{{.Config | printf \"%.0s\"}}",
+			expError: `results directory template "{{.Config | printf \"%.0s\"}}" must render to a directory name`,
+		},
+		{
+			name:     "Invalid/Field",
+			template: "{{.Foo}}",
+			expError: `invalid results directory template: template: results-dir:1:2: executing "results-dir" at <.Foo>: can't evaluate field Foo in type mirror.resultsDirData`,
+		},
+		{
+			name:     "Invalid/Latest",
+			template: "latest",
+			expError: `results directory name "latest" is reserved`,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			o := &MirrorOptions{
+				Dir:                t.TempDir(),
+				ConfigPath:         filepath.Join("configs", "imageset-config.yaml"),
+				ResultsDirTemplate: c.template,
+			}
+			if c.expError != "" {
+				_, err := parseResultsDirTemplate(c.template)
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			for _, exp := range c.expNames {
+				name, err := o.resultsDirName(start)
+				require.NoError(t, err)
+				require.Equal(t, exp, name)
+			}
+		})
+	}
+}
+
+func TestCreateResultsDir(t *testing.T) {
+	workspace := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(workspace, "run"), 0750))
+
+	o := &MirrorOptions{Dir: workspace, ResultsDirTemplate: "run"}
+	dir, err := o.createResultsDir()
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(workspace, "run-2"), dir)
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+
+	// The directory is created once per run
+	dir, err = o.createResultsDir()
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(workspace, "run-2"), dir)
+}
+
+func TestUpdateLatestResultsLink(t *testing.T) {
+	workspace := t.TempDir()
+	for _, name := range []string{"results-1", "results-2"} {
+		dir := filepath.Join(workspace, name)
+		require.NoError(t, os.MkdirAll(dir, 0750))
+		require.NoError(t, updateLatestResultsLink(dir))
+		target, err := os.Readlink(filepath.Join(workspace, latestResultsLink))
+		require.NoError(t, err)
+		require.Equal(t, name, target)
+	}
+	entries, err := os.ReadDir(workspace)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	// A directory named latest is not replaced
+	workspace = t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(workspace, latestResultsLink), 0750))
+	require.Error(t, updateLatestResultsLink(filepath.Join(workspace, "results-1")))
+}
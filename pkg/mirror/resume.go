@@ -0,0 +1,184 @@
+package mirror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/exitcode"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+// resumeStateFile is the name of the file in the source directory
+// of the workspace recording the progress of a create run
+const resumeStateFile = "resume.json"
+
+// resumeState is the progress of a create run, recorded
+// so an interrupted run can be continued with --resume
+type resumeState struct {
+	// ConfigDigest is the digest of the imageset configuration of the run
+	ConfigDigest string `json:"configDigest"`
+	// Sequence is the sequence of the imageset created by the run
+	Sequence int `json:"sequence"`
+	// Associations are the associations of the images
+	// of the imageset, recorded when archiving starts
+	Associations []v1alpha2.Association `json:"associations,omitempty"`
+	// Archives are the paths of the complete archives
+	Archives []string `json:"archives,omitempty"`
+	// Manifests are the paths of the manifests in the
+	// complete archives, relative to the source directory
+	Manifests []string `json:"manifests,omitempty"`
+	// Blobs are the digests of the blobs in the complete archives
+	Blobs []string `json:"blobs,omitempty"`
+
+	path string
+}
+
+// openResumeState starts recording the progress of the create run of the
+// imageset sequence. With --resume, the progress of an interrupted run of
+// the same configuration and sequence is continued.
+func (o *MirrorOptions) openResumeState(seq int) (*resumeState, error) {
+	data, err := ioutil.ReadFile(o.ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	// The state is saved while archiving, which
+	// changes to the source directory
+	dir, err := filepath.Abs(filepath.Join(o.Dir, config.SourceDir))
+	if err != nil {
+		return nil, err
+	}
+	state := &resumeState{
+		ConfigDigest: digest.FromBytes(data).String(),
+		Sequence:     seq,
+		path:         filepath.Join(dir, resumeStateFile),
+	}
+
+	var prev resumeState
+	data, err = ioutil.ReadFile(state.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if o.Resume {
+			o.log().Infof("No interrupted run to resume in %s, starting a new run", o.Dir)
+		}
+	case err != nil:
+		return nil, fmt.Errorf("error reading the progress of the interrupted run: %v", err)
+	case !o.Resume:
+		o.log().Warnf("Discarding the progress of an interrupted run in %s, use --resume to continue it", o.Dir)
+	default:
+		if err := json.Unmarshal(data, &prev); err != nil {
+			return nil, fmt.Errorf("error reading the progress of the interrupted run: %v", err)
+		}
+		if prev.ConfigDigest != state.ConfigDigest {
+			return nil, exitcode.With(exitcode.ConfigError, fmt.Errorf("cannot resume the interrupted run in %s: "+
+				"it used a different imageset configuration, run without --resume to start a new run", o.Dir))
+		}
+		if prev.Sequence != state.Sequence {
+			return nil, exitcode.With(exitcode.SequenceError, fmt.Errorf("cannot resume the interrupted run in %s: "+
+				"it created imageset sequence %d, but the next sequence is %d", o.Dir, prev.Sequence, state.Sequence))
+		}
+		prev.path = state.path
+		state = &prev
+		o.log().Infof("Resuming the interrupted run in %s with %d complete archives", o.Dir, len(state.Archives))
+	}
+	return state, state.save()
+}
+
+// save writes the state to the workspace
+func (s *resumeState) save() error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0640); err != nil {
+		return fmt.Errorf("error writing run progress: %v", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// remove removes the state once the run completes
+func (s *resumeState) remove() error {
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// setAssociations records the associations of the images of the imageset
+func (s *resumeState) setAssociations(assocs image.AssociationSet) error {
+	associations, err := image.ConvertFromAssociationSet(assocs)
+	if err != nil {
+		return err
+	}
+	s.Associations = associations
+	return s.save()
+}
+
+// archived records a complete archive and its files
+func (s *resumeState) archived(path string, manifests, blobs []string) error {
+	s.Archives = append(s.Archives, path)
+	s.Manifests = append(s.Manifests, manifests...)
+	s.Blobs = append(s.Blobs, blobs...)
+	return s.save()
+}
+
+// packedImages splits the mapping into the images left to mirror and the
+// associations of the images whose manifests and blobs are all in the
+// complete archives of the interrupted run, which are not mirrored again
+func (s *resumeState) packedImages(mapping image.TypedImageMapping) (image.TypedImageMapping, image.AssociationSet, error) {
+	packed := image.AssociationSet{}
+	if len(s.Archives) == 0 {
+		return mapping, packed, nil
+	}
+	assocs, err := image.ConvertToAssociationSet(s.Associations)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifests := make(map[string]struct{}, len(s.Manifests))
+	for _, m := range s.Manifests {
+		manifests[filepath.Clean(m)] = struct{}{}
+	}
+	blobs := make(map[string]struct{}, len(s.Blobs))
+	for _, b := range s.Blobs {
+		blobs[b] = struct{}{}
+	}
+	isPacked := func(values []v1alpha2.Association) bool {
+		for _, assoc := range values {
+			manifestDir := filepath.Join(config.V2Dir, filepath.FromSlash(assoc.Path), "manifests")
+			refs := []string{assoc.ID}
+			if assoc.TagSymlink != "" {
+				refs = append(refs, assoc.TagSymlink)
+			}
+			for _, ref := range refs {
+				if _, ok := manifests[filepath.Join(manifestDir, ref)]; !ok {
+					return false
+				}
+			}
+			for _, dgst := range assoc.LayerDigests {
+				if _, ok := blobs[dgst]; !ok {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	remaining := image.TypedImageMapping{}
+	for src, dst := range mapping {
+		key := src.Ref.String()
+		if values, found := assocs.Search(key); found && isPacked(values) {
+			packed.Add(key, values...)
+			continue
+		}
+		remaining[src] = dst
+	}
+	return remaining, packed, nil
+}
@@ -0,0 +1,143 @@
+package mirror
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestOpenResumeState(t *testing.T) {
+	type spec struct {
+		name        string
+		resume      bool
+		prevConfig  string
+		prevSeq     int
+		expArchives []string
+		expError    string
+	}
+	cases := []spec{
+		{
+			name:   "Valid/NoInterruptedRun",
+			resume: true,
+		},
+		{
+			name:        "Valid/Resume",
+			resume:      true,
+			prevConfig:  "kind: ImageSetConfiguration\n",
+			prevSeq:     2,
+			expArchives: []string{"mirror_seq2_000000.tar"},
+		},
+		{
+			name:       "Valid/Discard",
+			prevConfig: "kind: ImageSetConfiguration\n",
+			prevSeq:    2,
+		},
+		{
+			name:       "Invalid/ConfigChanged",
+			resume:     true,
+			prevConfig: "kind: ImageSetConfiguration\nmirror: {}\n",
+			prevSeq:    2,
+			expError:   "it used a different imageset configuration, run without --resume to start a new run",
+		},
+		{
+			name:       "Invalid/SequenceChanged",
+			resume:     true,
+			prevConfig: "kind: ImageSetConfiguration\n",
+			prevSeq:    1,
+			expError:   "it created imageset sequence 1, but the next sequence is 2",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			tmp := t.TempDir()
+			cfgPath := filepath.Join(tmp, "imageset-config.yaml")
+			o := &MirrorOptions{
+				Dir:        filepath.Join(tmp, "oc-mirror-workspace"),
+				ConfigPath: cfgPath,
+				Resume:     c.resume,
+			}
+			require.NoError(t, os.MkdirAll(filepath.Join(o.Dir, config.SourceDir), os.ModePerm))
+			if c.prevConfig != "" {
+				require.NoError(t, ioutil.WriteFile(cfgPath, []byte(c.prevConfig), 0640))
+				prev, err := o.openResumeState(c.prevSeq)
+				require.NoError(t, err)
+				require.NoError(t, prev.archived("mirror_seq2_000000.tar", nil, []string{"sha256:a"}))
+			}
+			require.NoError(t, ioutil.WriteFile(cfgPath, []byte("kind: ImageSetConfiguration\n"), 0640))
+
+			state, err := o.openResumeState(2)
+			if c.expError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, 2, state.Sequence)
+			require.Equal(t, c.expArchives, state.Archives)
+			require.FileExists(t, state.path)
+
+			require.NoError(t, state.remove())
+			require.NoFileExists(t, state.path)
+		})
+	}
+}
+
+func TestPackedImages(t *testing.T) {
+	parse := func(ref string) image.TypedImage {
+		img, err := image.ParseTypedImage(ref, v1alpha2.TypeGeneric)
+		require.NoError(t, err)
+		return img
+	}
+	ubi := parse("registry.redhat.io/ubi8/ubi:latest")
+	minimal := parse("registry.redhat.io/ubi8/ubi-minimal:latest")
+	mapping := image.TypedImageMapping{
+		ubi:     parse("file://ubi8/ubi:latest"),
+		minimal: parse("file://ubi8/ubi-minimal:latest"),
+	}
+	assocs := image.AssociationSet{}
+	assocs.Add(ubi.Ref.String(), v1alpha2.Association{
+		Name:         ubi.Ref.String(),
+		Path:         "ubi8/ubi",
+		ID:           "sha256:1",
+		TagSymlink:   "latest",
+		Type:         v1alpha2.TypeGeneric,
+		LayerDigests: []string{"sha256:a", "sha256:b"},
+	})
+	assocs.Add(minimal.Ref.String(), v1alpha2.Association{
+		Name:         minimal.Ref.String(),
+		Path:         "ubi8/ubi-minimal",
+		ID:           "sha256:2",
+		TagSymlink:   "latest",
+		Type:         v1alpha2.TypeGeneric,
+		LayerDigests: []string{"sha256:a", "sha256:c"},
+	})
+
+	state := &resumeState{path: filepath.Join(t.TempDir(), resumeStateFile)}
+	require.NoError(t, state.setAssociations(assocs))
+
+	// Without complete archives, all the images are mirrored
+	remaining, packed, err := state.packedImages(mapping)
+	require.NoError(t, err)
+	require.Equal(t, mapping, remaining)
+	require.Empty(t, packed)
+
+	// The ubi-minimal manifests are archived, but not its blob c
+	require.NoError(t, state.archived("mirror_seq1_000000.tar", []string{
+		"v2/ubi8/ubi/manifests/sha256:1",
+		"v2/ubi8/ubi/manifests/latest",
+		"v2/ubi8/ubi-minimal/manifests/sha256:2",
+		"v2/ubi8/ubi-minimal/manifests/latest",
+	}, []string{"sha256:a", "sha256:b"}))
+	remaining, packed, err = state.packedImages(mapping)
+	require.NoError(t, err)
+	require.Equal(t, image.TypedImageMapping{minimal: mapping[minimal]}, remaining)
+	require.Equal(t, []string{ubi.Ref.String()}, packed.Keys())
+}
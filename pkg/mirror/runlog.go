@@ -0,0 +1,218 @@
+package mirror
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	units "github.com/docker/go-units"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/oc-mirror/pkg/redact"
+)
+
+const (
+	// runLogFile is the name of the debug log of the run in the results directory
+	runLogFile = "oc-mirror.log"
+	// defaultRunLogMaxSize is the default size of the run log before it is rotated
+	defaultRunLogMaxSize = "100MiB"
+	// defaultRunLogBackups is the default number of rotated run logs kept
+	defaultRunLogBackups = 2
+)
+
+// isRunLog returns whether the file in the results directory is the run log or a rotated run log
+func isRunLog(name string) bool {
+	return name == runLogFile || strings.HasPrefix(name, runLogFile+".")
+}
+
+// runLog writes the log entries of the run up to the debug level
+// and the output of the run to the results directory
+type runLog struct {
+	writer        *rotatingWriter
+	redactWriters []*redact.Writer
+	originalHooks logrus.LevelHooks
+	out, errOut   io.Writer
+}
+
+// openRunLog starts writing the log of the run to the results directory,
+// independent of the log level of the console
+func (o *MirrorOptions) openRunLog() (*runLog, error) {
+	maxSize, err := parseRunLogMaxSize(o.RunLogMaxSize)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := o.createResultsDir()
+	if err != nil {
+		return nil, err
+	}
+	writer, err := newRotatingWriter(filepath.Join(dir, runLogFile), maxSize, o.RunLogBackups)
+	if err != nil {
+		return nil, fmt.Errorf("error opening run log: %v", err)
+	}
+
+	l := &runLog{writer: writer, originalHooks: logrus.LevelHooks{}, out: o.Out, errOut: o.ErrOut}
+	for level, hooks := range o.log().Hooks {
+		l.originalHooks[level] = hooks
+	}
+	var formatter logrus.Formatter = &logrus.TextFormatter{
+		DisableColors: true,
+		FullTimestamp: true,
+	}
+	if o.JSONLogs {
+		formatter = &logrus.JSONFormatter{}
+	}
+	// Only structured log entries are written
+	// to the log when the log format is JSON
+	if !o.JSONLogs {
+		l.redactWriters = []*redact.Writer{redact.NewWriter(writer), redact.NewWriter(writer)}
+		o.Out = io.MultiWriter(o.Out, l.redactWriters[0])
+		o.ErrOut = io.MultiWriter(o.ErrOut, l.redactWriters[1])
+	}
+	o.log().AddHook(&runLogHook{writer: writer, formatter: formatter})
+	return l, nil
+}
+
+// closeRunLog stops writing the run log and restores the log hooks and streams
+func (o *MirrorOptions) closeRunLog(l *runLog) {
+	if l == nil {
+		return
+	}
+	o.log().ReplaceHooks(l.originalHooks)
+	o.Out, o.ErrOut = l.out, l.errOut
+	for _, w := range l.redactWriters {
+		if err := w.Flush(); err != nil {
+			o.log().Errorf("error writing run log: %v", err)
+		}
+	}
+	if err := l.writer.Close(); err != nil {
+		o.log().Errorf("error closing run log: %v", err)
+	}
+}
+
+// parseRunLogMaxSize parses the size of the run log before it is rotated
+func parseRunLogMaxSize(size string) (int64, error) {
+	if size == "" {
+		size = defaultRunLogMaxSize
+	}
+	maxSize, err := units.RAMInBytes(size)
+	if err != nil {
+		return 0, fmt.Errorf("invalid run log max size %q: %v", size, err)
+	}
+	if maxSize <= 0 {
+		return 0, fmt.Errorf("run log max size %q must be greater than zero", size)
+	}
+	return maxSize, nil
+}
+
+// runLogHook writes log entries up to the debug level to the run log
+type runLogHook struct {
+	writer    io.Writer
+	formatter logrus.Formatter
+}
+
+func (h *runLogHook) Levels() []logrus.Level {
+	var levels []logrus.Level
+	for _, level := range logrus.AllLevels {
+		if level <= logrus.DebugLevel {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
+
+func (h *runLogHook) Fire(entry *logrus.Entry) error {
+	line, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	_, err = h.writer.Write(line)
+	return err
+}
+
+// rotatingWriter writes to a file that is rotated to path.1, path.2, ...
+// when it would exceed the max size, keeping up to backups rotated files
+type rotatingWriter struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	backups int
+	file    *os.File
+	size    int64
+}
+
+func newRotatingWriter(path string, maxSize int64, backups int) (*rotatingWriter, error) {
+	w := &rotatingWriter{path: path, maxSize: maxSize, backups: backups}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *rotatingWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.file, w.size = file, info.Size()
+	return nil
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	if w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the rotated files, dropping the oldest,
+// and starts a new file
+func (w *rotatingWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+	if w.backups == 0 {
+		if err := os.Remove(w.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return w.open()
+	}
+	for i := w.backups - 1; i >= 1; i-- {
+		src := fmt.Sprintf("%s.%d", w.path, i)
+		if err := os.Rename(src, fmt.Sprintf("%s.%d", w.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(w.path, w.path+".1"); err != nil {
+		return err
+	}
+	return w.open()
+}
+
+func (w *rotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
@@ -0,0 +1,124 @@
+package mirror
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingWriter(t *testing.T) {
+	type spec struct {
+		name     string
+		backups  int
+		expFiles map[string]string
+	}
+	cases := []spec{
+		{
+			name:    "Valid/KeepBackups",
+			backups: 2,
+			expFiles: map[string]string{
+				"oc-mirror.log":   "line4\n",
+				"oc-mirror.log.1": "line3\n",
+				"oc-mirror.log.2": "line2\n",
+			},
+		},
+		{
+			name:     "Valid/NoBackups",
+			backups:  0,
+			expFiles: map[string]string{"oc-mirror.log": "line4\n"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := newRotatingWriter(filepath.Join(dir, runLogFile), 10, c.backups)
+			require.NoError(t, err)
+			// Each line after the first exceeds the max size
+			for _, line := range []string{"line1\n", "line2\n", "line3\n", "line4\n"} {
+				_, err := w.Write([]byte(line))
+				require.NoError(t, err)
+			}
+			require.NoError(t, w.Close())
+			_, err = w.Write([]byte("closed\n"))
+			require.ErrorIs(t, err, os.ErrClosed)
+
+			files, err := ioutil.ReadDir(dir)
+			require.NoError(t, err)
+			got := map[string]string{}
+			for _, file := range files {
+				data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+				require.NoError(t, err)
+				got[file.Name()] = string(data)
+			}
+			require.Equal(t, c.expFiles, got)
+		})
+	}
+}
+
+func TestParseRunLogMaxSize(t *testing.T) {
+	type spec struct {
+		name     string
+		size     string
+		exp      int64
+		expError string
+	}
+	cases := []spec{
+		{name: "Valid/Default", size: "", exp: 100 * 1024 * 1024},
+		{name: "Valid/Size", size: "10MiB", exp: 10 * 1024 * 1024},
+		{name: "Invalid/Zero", size: "0", expError: `run log max size "0" must be greater than zero`},
+		{name: "Invalid/Size", size: "large", expError: `invalid run log max size "large": invalid size: 'large'`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			size, err := parseRunLogMaxSize(c.size)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, size)
+		})
+	}
+}
+
+func TestRunLog(t *testing.T) {
+	logger := logrus.StandardLogger()
+	defer logger.SetLevel(logger.GetLevel())
+	logger.SetLevel(logrus.TraceLevel)
+
+	out := &bytes.Buffer{}
+	o := &MirrorOptions{
+		Dir:    t.TempDir(),
+		Out:    out,
+		ErrOut: out,
+	}
+	runLog, err := o.openRunLog()
+	require.NoError(t, err)
+	logrus.Debug("debug entry")
+	logrus.Trace("trace entry")
+	fmt.Fprintln(o.Out, "sha256:3e590f0381f7 quay.io/ubi8/ubi")
+	o.closeRunLog(runLog)
+	logrus.Info("after close")
+
+	data, err := ioutil.ReadFile(filepath.Join(o.resultsDir, runLogFile))
+	require.NoError(t, err)
+	log := string(data)
+	require.Contains(t, log, `level=debug msg="debug entry"`)
+	require.NotContains(t, log, "trace entry")
+	require.Contains(t, log, "sha256:3e590f0381f7 quay.io/ubi8/ubi\n")
+	require.NotContains(t, log, "after close")
+	require.Equal(t, "sha256:3e590f0381f7 quay.io/ubi8/ubi\n", out.String())
+	require.Equal(t, out, o.Out)
+
+	// The run log is not checksummed since it is written until the run ends
+	sums, err := generateChecksums(o.resultsDir)
+	require.NoError(t, err)
+	require.False(t, strings.Contains(string(sums), runLogFile))
+}
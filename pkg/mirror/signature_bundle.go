@@ -0,0 +1,86 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/openshift/library-go/pkg/verify/store"
+	"github.com/openshift/library-go/pkg/verify/util"
+
+	"github.com/openshift/oc-mirror/pkg/archive"
+)
+
+// maxBundleSignatures limits the number of signatures
+// read for a single digest
+const maxBundleSignatures = 10
+
+// signatureBundleStore reads release signatures from a local
+// bundle using the same layout as the signature mirror
+// (<algo>=<hash>/signature-<n>).
+type signatureBundleStore struct {
+	dir string
+}
+
+var _ store.Store = &signatureBundleStore{}
+
+// Signatures reads the signatures for digest from the bundle
+func (s *signatureBundleStore) Signatures(ctx context.Context, name string, digest string, fn store.Callback) error {
+	digestDir, err := util.DigestToKeyPrefix(digest, "=")
+	if err != nil {
+		return err
+	}
+
+	base := filepath.Join(s.dir, digestDir, "signature-")
+	for i := 1; i <= maxBundleSignatures; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(base + strconv.Itoa(i))
+		if os.IsNotExist(err) {
+			break
+		}
+		done, err := fn(ctx, data, err)
+		if done || err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String returns a description of where this store finds signatures
+func (s *signatureBundleStore) String() string {
+	return fmt.Sprintf("file://%s", s.dir)
+}
+
+// newSignatureBundleStore creates a signature store from a bundle directory
+// or tar archive. Archives are extracted into workDir and removed by
+// the returned cleanup function.
+func (o *MirrorOptions) newSignatureBundleStore(bundle, workDir string) (*signatureBundleStore, func(), error) {
+	cleanup := func() {}
+	info, err := os.Stat(bundle)
+	if err != nil {
+		return nil, cleanup, fmt.Errorf("error reading signature bundle: %v", err)
+	}
+	if info.IsDir() {
+		return &signatureBundleStore{dir: bundle}, cleanup, nil
+	}
+
+	dir, err := ioutil.TempDir(workDir, "signatures.*")
+	if err != nil {
+		return nil, cleanup, err
+	}
+	cleanup = func() {
+		if err := os.RemoveAll(dir); err != nil {
+			o.log().Warnf("error removing extracted signature bundle: %v", err)
+		}
+	}
+	if err := archive.NewArchiver().Unarchive(bundle, dir); err != nil {
+		cleanup()
+		return nil, func() {}, fmt.Errorf("error extracting signature bundle %s: %v", bundle, err)
+	}
+	return &signatureBundleStore{dir: dir}, cleanup, nil
+}
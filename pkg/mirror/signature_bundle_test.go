@@ -0,0 +1,67 @@
+package mirror
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/archive"
+)
+
+func TestSignatureBundleStore(t *testing.T) {
+	digest := "sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8"
+
+	bundleDir := t.TempDir()
+	sigDir := filepath.Join(bundleDir, "sha256=3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8")
+	require.NoError(t, os.MkdirAll(sigDir, 0750))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sigDir, "signature-1"), []byte("sig1"), 0640))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sigDir, "signature-2"), []byte("sig2"), 0640))
+
+	bundleTar := filepath.Join(t.TempDir(), "signatures.tar")
+	require.NoError(t, archive.NewArchiver().Archive([]string{sigDir}, bundleTar))
+
+	tests := []struct {
+		name   string
+		bundle string
+		digest string
+		exp    []string
+	}{
+		{
+			name:   "Valid/Directory",
+			bundle: bundleDir,
+			digest: digest,
+			exp:    []string{"sig1", "sig2"},
+		},
+		{
+			name:   "Valid/Archive",
+			bundle: bundleTar,
+			digest: digest,
+			exp:    []string{"sig1", "sig2"},
+		},
+		{
+			name:   "Valid/NoSignatures",
+			bundle: bundleDir,
+			digest: "sha256:85dd1cac8dded83849d08fb76f527ac93b47146434398a6aed454b7d9bf67597",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store, cleanup, err := NewMirrorOptions().newSignatureBundleStore(test.bundle, t.TempDir())
+			require.NoError(t, err)
+			defer cleanup()
+
+			var sigs []string
+			err = store.Signatures(context.TODO(), "", test.digest, func(_ context.Context, signature []byte, errIn error) (bool, error) {
+				require.NoError(t, errIn)
+				sigs = append(sigs, string(signature))
+				return false, nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, test.exp, sigs)
+		})
+	}
+}
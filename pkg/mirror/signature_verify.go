@@ -0,0 +1,139 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/openshift/library-go/pkg/verify"
+	"github.com/openshift/library-go/pkg/verify/store"
+	"github.com/openshift/library-go/pkg/verify/util"
+	"golang.org/x/crypto/openpgp"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// releaseSignatureStore serves a single release signature
+type releaseSignatureStore struct {
+	name      string
+	signature []byte
+}
+
+var _ store.Store = &releaseSignatureStore{}
+
+// Signatures returns the signature regardless of the digest,
+// leaving the digest check to the verifier
+func (s *releaseSignatureStore) Signatures(ctx context.Context, name string, digest string, fn store.Callback) error {
+	_, err := fn(ctx, s.signature, nil)
+	return err
+}
+
+// String returns a description of where this store finds signatures
+func (s *releaseSignatureStore) String() string {
+	return s.name
+}
+
+// verifyReleaseSignatures verifies the release signature ConfigMaps in sigDir
+// against the keys at ReleaseSignatureKeys. Every signature must be a valid
+// signature of the release digest named by its ConfigMap by one of the keys,
+// so corrupted or substituted signature files fail the publish.
+func (o *MirrorOptions) verifyReleaseSignatures(ctx context.Context, sigDir string) error {
+	if len(o.ReleaseSignatureKeys) == 0 {
+		return nil
+	}
+	var keyring openpgp.EntityList
+	for _, path := range o.ReleaseSignatureKeys {
+		keys, err := readPublicKeyring(path)
+		if err != nil {
+			return err
+		}
+		keyring = append(keyring, keys...)
+	}
+
+	files, err := ioutil.ReadDir(sigDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			o.log().Debug("No release signatures to verify")
+			return nil
+		}
+		return err
+	}
+	var errs []error
+	var verified int
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		path := filepath.Join(sigDir, file.Name())
+		if err := verifyReleaseSignatureFile(ctx, path, keyring); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", file.Name(), err))
+			continue
+		}
+		verified++
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("error verifying release signatures: %v", utilerrors.NewAggregate(errs))
+	}
+	o.log().Infof("Verified %d release signature files", verified)
+	return nil
+}
+
+// verifyReleaseSignatureFile verifies every signature
+// in a release signature ConfigMap file
+func verifyReleaseSignatureFile(ctx context.Context, path string, keyring openpgp.EntityList) error {
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	cm, err := util.ReadConfigMap(data)
+	if err != nil {
+		return fmt.Errorf("invalid signature ConfigMap: %v", err)
+	}
+	// The ConfigMap is named after the digest with the
+	// algorithm separator replaced (sha256-<hash>)
+	i := strings.Index(cm.Name, "-")
+	if i <= 0 {
+		return fmt.Errorf("signature ConfigMap name %q is not a release digest", cm.Name)
+	}
+	digest := cm.Name[:i] + ":" + cm.Name[i+1:]
+	if len(cm.BinaryData) == 0 {
+		return errors.New("no signatures found")
+	}
+
+	keys := make([]string, 0, len(cm.BinaryData))
+	for k := range cm.BinaryData {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		verifier := verify.NewReleaseVerifier(
+			map[string]openpgp.EntityList{"release-signature-keys": keyring},
+			&releaseSignatureStore{name: k, signature: cm.BinaryData[k]},
+		)
+		if err := verifier.Verify(ctx, digest); err != nil {
+			return fmt.Errorf("signature %s is not a valid signature of %s: %v", k, digest, err)
+		}
+	}
+	return nil
+}
+
+// readPublicKeyring reads the armored or binary GPG public keys at path
+func readPublicKeyring(path string) (openpgp.EntityList, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("error reading release signature key: %v", err)
+	}
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	if err != nil {
+		keyring, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing release signature key %s: %v", path, err)
+		}
+	}
+	return keyring, nil
+}
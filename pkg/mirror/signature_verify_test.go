@@ -0,0 +1,117 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift/library-go/pkg/verify"
+	"github.com/openshift/library-go/pkg/verify/util"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+func TestVerifyReleaseSignatures(t *testing.T) {
+	digest := "sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8"
+	otherDigest := "sha256:fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9"
+
+	pgpConfig := &packet.Config{DefaultHash: crypto.SHA256}
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", pgpConfig)
+	require.NoError(t, err)
+	other, err := openpgp.NewEntity("other", "", "other@example.com", pgpConfig)
+	require.NoError(t, err)
+	keyPath := writePublicKey(t, entity)
+	otherKeyPath := writePublicKey(t, other)
+
+	sign := func(signer *openpgp.Entity, signed string) []byte {
+		payload := fmt.Sprintf(`{"critical":{"type":"atomic container signature",`+
+			`"image":{"docker-manifest-digest":%q},"identity":{"docker-reference":"quay.io/openshift-release-dev/ocp-release"}},"optional":{}}`, signed)
+		sig := &bytes.Buffer{}
+		w, err := openpgp.Sign(sig, signer, nil, pgpConfig)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(payload))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		return sig.Bytes()
+	}
+	valid := sign(entity, digest)
+	corrupted := append([]byte{}, valid...)
+	corrupted[len(corrupted)-10] ^= 0xff
+
+	type spec struct {
+		name       string
+		keys       []string
+		signatures [][]byte
+		expError   bool
+	}
+	cases := []spec{
+		{
+			name:       "Valid/TrustedKey",
+			keys:       []string{otherKeyPath, keyPath},
+			signatures: [][]byte{valid},
+		},
+		{
+			name:       "Valid/NoKeys",
+			signatures: [][]byte{sign(other, digest)},
+		},
+		{
+			name:       "Invalid/UntrustedKey",
+			keys:       []string{keyPath},
+			signatures: [][]byte{sign(other, digest)},
+			expError:   true,
+		},
+		{
+			name:       "Invalid/SubstitutedDigest",
+			keys:       []string{keyPath},
+			signatures: [][]byte{sign(entity, otherDigest)},
+			expError:   true,
+		},
+		{
+			name:       "Invalid/CorruptedSignature",
+			keys:       []string{keyPath},
+			signatures: [][]byte{valid, corrupted},
+			expError:   true,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			sigDir := t.TempDir()
+			cm, err := verify.GetSignaturesAsConfigmap(digest, c.signatures)
+			require.NoError(t, err)
+			data, err := util.ConfigMapAsBytes(cm)
+			require.NoError(t, err)
+			require.NoError(t, ioutil.WriteFile(filepath.Join(sigDir, "signature-sha256-3e590f0381f73fe7.json"), data, 0640))
+
+			opts := &MirrorOptions{ReleaseSignatureKeys: c.keys}
+			err = opts.verifyReleaseSignatures(context.TODO(), sigDir)
+			if c.expError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+
+	opts := &MirrorOptions{ReleaseSignatureKeys: []string{keyPath}}
+	require.NoError(t, opts.verifyReleaseSignatures(context.TODO(), filepath.Join(t.TempDir(), "missing")))
+}
+
+// writePublicKey writes the armored public key of the entity
+func writePublicKey(t *testing.T, entity *openpgp.Entity) string {
+	buf := &bytes.Buffer{}
+	w, err := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+	path := filepath.Join(t.TempDir(), "key.pub")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0600))
+	return path
+}
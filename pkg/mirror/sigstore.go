@@ -0,0 +1,168 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/openshift/library-go/pkg/verify/util"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+const (
+	// sigstoreSignatureMediaType is the layer media type of a simple signing payload signed with cosign
+	sigstoreSignatureMediaType types.MediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	// sigstoreSignatureAnnotation holds the base64 encoded cosign signature of the payload
+	sigstoreSignatureAnnotation = "dev.cosignproject.cosign/signature"
+	// sigstoreSignatureTagSuffix is appended to the digest based tag of cosign signatures
+	sigstoreSignatureTagSuffix = ".sig"
+)
+
+// pushSigstoreSignatures signs the mirrored release images that have
+// a release signature in sigDir with the cosign key, or keyless, and
+// pushes the cosign signatures next to them using the sigstore attached
+// tag convention (sha256-<hash>.sig), so that cosign and the sigstore
+// policy controller can verify the releases of the mirror registry.
+// The release signatures of archives are verified beforehand against
+// the keys at ReleaseSignatureKeys when they are set.
+func (o *MirrorOptions) pushSigstoreSignatures(ctx context.Context, mapping image.TypedImageMapping, sigDir string) error {
+	signatures, err := readSignatureConfigMaps(sigDir)
+	if err != nil {
+		return err
+	}
+	if len(signatures) == 0 {
+		o.log().Debug("No release signatures found, skipping release signature push")
+		return nil
+	}
+
+	releases := image.TypedImageMapping{}
+	for src, dst := range image.ByCategory(mapping, v1alpha2.TypeOCPRelease) {
+		digest := src.Ref.ID
+		if digest == "" {
+			digest = dst.Ref.ID
+		}
+		if digest == "" {
+			o.log().Debugf("Release image %s is not pinned by digest, skipping release signature push", src.Ref.Exact())
+			continue
+		}
+		prefix, err := util.DigestToKeyPrefix(digest, "-")
+		if err != nil {
+			return err
+		}
+		if _, ok := signatures[prefix]; !ok {
+			o.log().Debugf("No release signatures found for %s", digest)
+			continue
+		}
+		dst.Ref.ID = digest
+		releases[src] = dst
+	}
+	if len(releases) == 0 {
+		return nil
+	}
+
+	signer, err := o.cosignSigner(ctx)
+	if err != nil {
+		return err
+	}
+	if signer == nil {
+		return errors.New("--push-sigstore-signatures requires --cosign-key or --cosign-keyless")
+	}
+	return o.pushCosignSignatures(ctx, signer, releases)
+}
+
+// readSignatureConfigMaps returns the signatures in the release signature
+// ConfigMaps stored in dir keyed by ConfigMap name (sha256-<hash>)
+func readSignatureConfigMaps(dir string) (map[string][][]byte, error) {
+	signatures := map[string][][]byte{}
+	cms, err := loadSignatureConfigMaps(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cm := range cms {
+		// Keep the signature order stable
+		keys := make([]string, 0, len(cm.BinaryData))
+		for key := range cm.BinaryData {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			signatures[cm.Name] = append(signatures[cm.Name], cm.BinaryData[key])
+		}
+	}
+	return signatures, nil
+}
+
+// loadSignatureConfigMaps reads the release signature ConfigMaps stored in dir
+func loadSignatureConfigMaps(dir string) ([]*corev1.ConfigMap, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var cms []*corev1.ConfigMap
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		cm, err := util.ReadConfigMap(data)
+		if err != nil {
+			return nil, fmt.Errorf("error reading signature %s: %v", file.Name(), err)
+		}
+		if cm != nil {
+			cms = append(cms, cm)
+		}
+	}
+	return cms, nil
+}
+
+// blobLayer is an uncompressed layer holding arbitrary content
+type blobLayer struct {
+	content   []byte
+	mediaType types.MediaType
+}
+
+var _ v1.Layer = &blobLayer{}
+
+func (l *blobLayer) Digest() (v1.Hash, error) {
+	h, _, err := v1.SHA256(bytes.NewReader(l.content))
+	return h, err
+}
+
+func (l *blobLayer) DiffID() (v1.Hash, error) {
+	return l.Digest()
+}
+
+func (l *blobLayer) Compressed() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(l.content)), nil
+}
+
+func (l *blobLayer) Uncompressed() (io.ReadCloser, error) {
+	return l.Compressed()
+}
+
+func (l *blobLayer) Size() (int64, error) {
+	return int64(len(l.content)), nil
+}
+
+func (l *blobLayer) MediaType() (types.MediaType, error) {
+	return l.mediaType, nil
+}
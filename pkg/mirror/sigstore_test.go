@@ -0,0 +1,103 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/openshift/library-go/pkg/verify"
+	"github.com/openshift/library-go/pkg/verify/util"
+	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestPushSigstoreSignatures(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	markInsecure(t, u.Host)
+
+	digest := "sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8"
+	payload := []byte(`{"critical":{"type":"atomic container signature"}}`)
+
+	// Sign the payload with a throwaway key
+	pgpConfig := &packet.Config{DefaultHash: crypto.SHA256}
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", pgpConfig)
+	require.NoError(t, err)
+	sig := &bytes.Buffer{}
+	w, err := openpgp.Sign(sig, entity, nil, pgpConfig)
+	require.NoError(t, err)
+	_, err = w.Write(payload)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	dir := t.TempDir()
+	sigDir := filepath.Join(dir, config.ReleaseSignatureDir)
+	require.NoError(t, os.MkdirAll(sigDir, 0750))
+	cm, err := verify.GetSignaturesAsConfigmap(digest, [][]byte{sig.Bytes()})
+	require.NoError(t, err)
+	data, err := util.ConfigMapAsBytes(cm)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sigDir, "signature-sha256-3e590f0381f73fe7.json"), data, 0640))
+
+	src, err := imagesource.ParseReference(fmt.Sprintf("quay.io/openshift-release-dev/ocp-release@%s", digest))
+	require.NoError(t, err)
+	dst, err := imagesource.ParseReference(fmt.Sprintf("%s/openshift/release-images@%s", u.Host, digest))
+	require.NoError(t, err)
+	mapping := image.TypedImageMapping{}
+	mapping.Add(src, dst, v1alpha2.TypeOCPRelease)
+
+	// Release signatures are only pushed as cosign signatures
+	opts := &MirrorOptions{DestPlainHTTP: true}
+	err = opts.pushSigstoreSignatures(context.TODO(), mapping, sigDir)
+	require.EqualError(t, err, "--push-sigstore-signatures requires --cosign-key or --cosign-keyless")
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	opts.CosignKey = writeEncryptedCosignKey(t, key, "password")
+	t.Setenv(cosignPasswordEnv, "password")
+	require.NoError(t, opts.pushSigstoreSignatures(context.TODO(), mapping, sigDir))
+
+	sigRef := fmt.Sprintf("%s/openshift/release-images:sha256-%s.sig", u.Host, digest[len("sha256:"):])
+	img, err := crane.Pull(sigRef, crane.Insecure)
+	require.NoError(t, err)
+	manifest, err := img.Manifest()
+	require.NoError(t, err)
+	require.Len(t, manifest.Layers, 1)
+	require.Equal(t, sigstoreSignatureMediaType, manifest.Layers[0].MediaType)
+
+	// The signature verifies with the public key of the cosign key
+	// for the release digest, as cosign verifies it
+	layers, err := img.Layers()
+	require.NoError(t, err)
+	annotations := manifest.Layers[0].Annotations
+	require.NoError(t, verifyCosignSignature(layers[0], annotations, digest, imageSigners{keys: []crypto.PublicKey{&key.PublicKey}}))
+	require.Error(t, verifyCosignSignature(layers[0], annotations, digest, imageSigners{keys: []crypto.PublicKey{&other.PublicKey}}))
+	require.Error(t, verifyCosignSignature(layers[0], annotations, "sha256:"+strings.Repeat("0", 64), imageSigners{keys: []crypto.PublicKey{&key.PublicKey}}))
+
+	// The GPG signatures are not pushed
+	_, err = crane.Pull(fmt.Sprintf("%s/openshift/release-images:sha256-%s.gpg", u.Host, digest[len("sha256:"):]), crane.Insecure)
+	require.Error(t, err)
+}
@@ -0,0 +1,249 @@
+package mirror
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	units "github.com/docker/go-units"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+const (
+	// sizeReportFile is the file name of the image size report
+	sizeReportFile = "image-sizes.txt"
+	// sizeReportJSONFile is the file name of the image size report in JSON
+	sizeReportJSONFile = "image-sizes.json"
+)
+
+// Sort orders of the image size report
+const (
+	sortBySize         = "size"
+	sortByUncompressed = "uncompressed"
+	sortByLayers       = "layers"
+	sortByName         = "name"
+)
+
+// imageSize is the size of a mirrored image.
+// Images with several manifests count each unique blob once.
+type imageSize struct {
+	// Image is the source image reference
+	Image string `json:"image"`
+	// Type is the image type
+	Type v1alpha2.ImageType `json:"type"`
+	// Layers is the number of unique layer blobs
+	Layers int `json:"layers"`
+	// CompressedBytes is the size of the layer and config blobs
+	CompressedBytes int64 `json:"compressedBytes"`
+	// UncompressedBytes is the size of the decompressed layer and config blobs
+	UncompressedBytes int64 `json:"uncompressedBytes"`
+}
+
+// blobSize is the compressed and uncompressed size of a blob
+type blobSize struct {
+	compressed, uncompressed int64
+}
+
+// localImageSizes returns the sizes of the images of the associations
+// from the blobs in the v2 directory
+func (o *MirrorOptions) localImageSizes(v2Dir string, assocs image.AssociationSet) ([]imageSize, error) {
+	// Blobs are looked up in the repository of the association
+	// that references them, so the first path of each blob is kept
+	paths := map[string]string{}
+	for _, key := range assocs.Keys() {
+		for _, assoc := range assocs[key] {
+			for _, digest := range assoc.LayerDigests {
+				if _, found := paths[digest]; !found {
+					paths[digest] = filepath.Join(v2Dir, filepath.FromSlash(assoc.Path), config.BlobDir, digest)
+				}
+			}
+		}
+	}
+	blobs, err := o.blobSizes(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	var sizes []imageSize
+	for _, key := range assocs.Keys() {
+		size := imageSize{Image: key}
+		seen := map[string]struct{}{}
+		for _, assoc := range assocs[key] {
+			if assoc.Name == key {
+				size.Type = assoc.Type
+			}
+			for i, digest := range assoc.LayerDigests {
+				if _, found := seen[digest]; found {
+					continue
+				}
+				seen[digest] = struct{}{}
+				// The config blob is associated after the layers
+				if i != len(assoc.LayerDigests)-1 {
+					size.Layers++
+				}
+				size.CompressedBytes += blobs[digest].compressed
+				size.UncompressedBytes += blobs[digest].uncompressed
+			}
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+// blobSizes returns the sizes of the blobs at paths by digest.
+// Blobs are decompressed concurrently to measure their uncompressed size.
+func (o *MirrorOptions) blobSizes(paths map[string]string) (map[string]blobSize, error) {
+	var mu sync.Mutex
+	sizes := make(map[string]blobSize, len(paths))
+	work := make(chan string)
+	g := errgroup.Group{}
+	for i := 0; i < runtime.NumCPU(); i++ {
+		g.Go(func() error {
+			for digest := range work {
+				size, err := o.localBlobSize(paths[digest])
+				if err != nil {
+					return err
+				}
+				mu.Lock()
+				sizes[digest] = size
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	g.Go(func() error {
+		defer close(work)
+		for digest := range paths {
+			work <- digest
+		}
+		return nil
+	})
+	return sizes, g.Wait()
+}
+
+// localBlobSize returns the size of a blob file and the size of its
+// contents when decompressed. Blobs that are not gzip compressed,
+// such as image configs, have the same compressed and uncompressed size.
+// Blobs that are not found on disk have no size.
+func (o *MirrorOptions) localBlobSize(path string) (blobSize, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			o.log().Debugf("blob %s not found, skipping size", path)
+			return blobSize{}, nil
+		}
+		return blobSize{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return blobSize{}, err
+	}
+	size := blobSize{compressed: info.Size(), uncompressed: info.Size()}
+
+	r := bufio.NewReader(f)
+	magic, err := r.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		return size, nil
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return blobSize{}, fmt.Errorf("error reading compressed blob %s: %v", path, err)
+	}
+	defer gz.Close()
+	if size.uncompressed, err = io.Copy(ioutil.Discard, gz); err != nil {
+		return blobSize{}, fmt.Errorf("error reading compressed blob %s: %v", path, err)
+	}
+	return size, nil
+}
+
+// sortImageSizes sorts image sizes by the sort order, largest first
+func sortImageSizes(sizes []imageSize, by string) error {
+	var less func(a, b imageSize) bool
+	switch by {
+	case sortBySize, "":
+		less = func(a, b imageSize) bool { return a.CompressedBytes > b.CompressedBytes }
+	case sortByUncompressed:
+		less = func(a, b imageSize) bool { return a.UncompressedBytes > b.UncompressedBytes }
+	case sortByLayers:
+		less = func(a, b imageSize) bool { return a.Layers > b.Layers }
+	case sortByName:
+		less = func(a, b imageSize) bool { return a.Image < b.Image }
+	default:
+		return fmt.Errorf("size report sort order %q is not supported: must be one of %s, %s, %s, or %s",
+			by, sortBySize, sortByUncompressed, sortByLayers, sortByName)
+	}
+	sort.SliceStable(sizes, func(i, j int) bool {
+		if less(sizes[i], sizes[j]) != less(sizes[j], sizes[i]) {
+			return less(sizes[i], sizes[j])
+		}
+		return sizes[i].Image < sizes[j].Image
+	})
+	return nil
+}
+
+// writeSizeTable writes the image sizes as a table with a total to w
+func writeSizeTable(w io.Writer, sizes []imageSize) error {
+	size := func(bytes int64) string { return units.BytesSize(float64(bytes)) }
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "IMAGE\tTYPE\tLAYERS\tCOMPRESSED\tUNCOMPRESSED")
+	var total imageSize
+	for _, s := range sizes {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", s.Image, s.Type, s.Layers, size(s.CompressedBytes), size(s.UncompressedBytes))
+		total.Layers += s.Layers
+		total.CompressedBytes += s.CompressedBytes
+		total.UncompressedBytes += s.UncompressedBytes
+	}
+	fmt.Fprintf(tw, "TOTAL\t\t%d\t%s\t%s\n", total.Layers, size(total.CompressedBytes), size(total.UncompressedBytes))
+	return tw.Flush()
+}
+
+// writeSizeReport writes the size report of the images of the
+// associations to the results directory
+func (o *MirrorOptions) writeSizeReport(assocs image.AssociationSet) ([]string, error) {
+	v2Dir := filepath.Join(o.Dir, config.SourceDir, config.V2Dir)
+	sizes, err := o.localImageSizes(v2Dir, assocs)
+	if err != nil {
+		return nil, fmt.Errorf("error measuring image sizes: %v", err)
+	}
+	if err := sortImageSizes(sizes, o.SizeReportSort); err != nil {
+		return nil, err
+	}
+
+	dir, err := o.createResultsDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, sizeReportFile)
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := writeSizeTable(f, sizes); err != nil {
+		return nil, fmt.Errorf("error writing size report: %v", err)
+	}
+	data, err := json.MarshalIndent(sizes, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	jsonPath := filepath.Join(dir, sizeReportJSONFile)
+	if err := ioutil.WriteFile(jsonPath, append(data, '\n'), 0640); err != nil {
+		return nil, fmt.Errorf("error writing size report: %v", err)
+	}
+	o.log().Infof("Wrote image size report to %s", path)
+	return []string{path, jsonPath}, f.Close()
+}
@@ -0,0 +1,154 @@
+package mirror
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestLocalImageSizes(t *testing.T) {
+	v2Dir := filepath.Join(t.TempDir(), "v2")
+	writeBlob := func(path, digest string, data []byte, compress bool) int64 {
+		dir := filepath.Join(v2Dir, path, "blobs")
+		require.NoError(t, os.MkdirAll(dir, 0750))
+		if compress {
+			buf := &bytes.Buffer{}
+			gz := gzip.NewWriter(buf)
+			_, err := gz.Write(data)
+			require.NoError(t, err)
+			require.NoError(t, gz.Close())
+			data = buf.Bytes()
+		}
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, digest), data, 0640))
+		return int64(len(data))
+	}
+	layer1 := writeBlob("ubi8/ubi", "sha256:layer1", bytes.Repeat([]byte("a"), 4096), true)
+	config1 := writeBlob("ubi8/ubi", "sha256:config1", []byte(`{"architecture":"amd64"}`), false)
+	layer2 := writeBlob("ocp/release", "sha256:layer2", bytes.Repeat([]byte("b"), 8192), true)
+	layer3 := writeBlob("ocp/release", "sha256:layer3", bytes.Repeat([]byte("c"), 1024), true)
+	config2 := writeBlob("ocp/release", "sha256:config2", []byte(`{"architecture":"amd64"}`), false)
+	config3 := writeBlob("ocp/release", "sha256:config3", []byte(`{"architecture":"arm64"}`), false)
+
+	assocs := image.AssociationSet{
+		"registry.redhat.io/ubi8/ubi:latest": image.Associations{
+			"registry.redhat.io/ubi8/ubi:latest": {
+				Name:         "registry.redhat.io/ubi8/ubi:latest",
+				Path:         "ubi8/ubi",
+				Type:         v1alpha2.TypeGeneric,
+				LayerDigests: []string{"sha256:layer1", "sha256:config1"},
+			},
+		},
+		// A manifest list with two manifests sharing a layer
+		"quay.io/openshift-release-dev/ocp-release:4.9.0": image.Associations{
+			"quay.io/openshift-release-dev/ocp-release:4.9.0": {
+				Name:            "quay.io/openshift-release-dev/ocp-release:4.9.0",
+				Path:            "ocp/release",
+				Type:            v1alpha2.TypeOCPRelease,
+				ManifestDigests: []string{"sha256:amd64", "sha256:arm64"},
+			},
+			"sha256:amd64": {
+				Name:         "sha256:amd64",
+				Path:         "ocp/release",
+				Type:         v1alpha2.TypeOCPRelease,
+				LayerDigests: []string{"sha256:layer2", "sha256:layer3", "sha256:config2"},
+			},
+			"sha256:arm64": {
+				Name:         "sha256:arm64",
+				Path:         "ocp/release",
+				Type:         v1alpha2.TypeOCPRelease,
+				LayerDigests: []string{"sha256:layer2", "sha256:config3"},
+			},
+		},
+	}
+
+	sizes, err := NewMirrorOptions().localImageSizes(v2Dir, assocs)
+	require.NoError(t, err)
+	require.NoError(t, sortImageSizes(sizes, sortByName))
+	exp := []imageSize{
+		{
+			Image:             "quay.io/openshift-release-dev/ocp-release:4.9.0",
+			Type:              v1alpha2.TypeOCPRelease,
+			Layers:            2,
+			CompressedBytes:   layer2 + layer3 + config2 + config3,
+			UncompressedBytes: 8192 + 1024 + config2 + config3,
+		},
+		{
+			Image:             "registry.redhat.io/ubi8/ubi:latest",
+			Type:              v1alpha2.TypeGeneric,
+			Layers:            1,
+			CompressedBytes:   layer1 + config1,
+			UncompressedBytes: 4096 + config1,
+		},
+	}
+	require.Equal(t, exp, sizes)
+}
+
+func TestSortImageSizes(t *testing.T) {
+	sizes := func() []imageSize {
+		return []imageSize{
+			{Image: "b", Layers: 3, CompressedBytes: 100, UncompressedBytes: 900},
+			{Image: "a", Layers: 1, CompressedBytes: 300, UncompressedBytes: 400},
+			{Image: "c", Layers: 3, CompressedBytes: 200, UncompressedBytes: 200},
+		}
+	}
+	names := func(sizes []imageSize) (names []string) {
+		for _, s := range sizes {
+			names = append(names, s.Image)
+		}
+		return names
+	}
+
+	type spec struct {
+		name     string
+		by       string
+		exp      []string
+		expError string
+	}
+	cases := []spec{
+		{name: "Valid/Default", by: "", exp: []string{"a", "c", "b"}},
+		{name: "Valid/Size", by: sortBySize, exp: []string{"a", "c", "b"}},
+		{name: "Valid/Uncompressed", by: sortByUncompressed, exp: []string{"b", "a", "c"}},
+		{name: "Valid/LayersThenName", by: sortByLayers, exp: []string{"b", "c", "a"}},
+		{name: "Valid/Name", by: sortByName, exp: []string{"a", "b", "c"}},
+		{
+			name:     "Invalid/Order",
+			by:       "date",
+			expError: `size report sort order "date" is not supported: must be one of size, uncompressed, layers, or name`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := sizes()
+			err := sortImageSizes(s, c.by)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, names(s))
+		})
+	}
+}
+
+func TestWriteSizeTable(t *testing.T) {
+	sizes := []imageSize{
+		{Image: "quay.io/openshift-release-dev/ocp-release:4.9.0", Type: v1alpha2.TypeOCPRelease, Layers: 5, CompressedBytes: 300 * 1024 * 1024, UncompressedBytes: 900 * 1024 * 1024},
+		{Image: "registry.redhat.io/ubi8/ubi:latest", Type: v1alpha2.TypeGeneric, Layers: 1, CompressedBytes: 36 * 1024 * 1024, UncompressedBytes: 100 * 1024 * 1024},
+	}
+	out := &bytes.Buffer{}
+	require.NoError(t, writeSizeTable(out, sizes))
+	exp := `IMAGE                                            TYPE        LAYERS  COMPRESSED  UNCOMPRESSED
+quay.io/openshift-release-dev/ocp-release:4.9.0  ocpRelease  5       300MiB      900MiB
+registry.redhat.io/ubi8/ubi:latest               generic     1       36MiB       100MiB
+TOTAL                                                        6       336MiB      1000MiB
+`
+	require.Equal(t, exp, out.String())
+}
@@ -0,0 +1,427 @@
+package mirror
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	units "github.com/docker/go-units"
+	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	"github.com/sirupsen/logrus"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/bundle"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+// transferStats collects the transfer statistics of a run
+// from the output of the oc image mirror library
+type transferStats struct {
+	mu sync.Mutex
+	// bytes is the size of the uploaded blobs
+	bytes int64
+	// blobs and mounted count the uploaded and cross-repository mounted blobs
+	blobs, mounted int
+	// mountedBytes is the size of the mounted blobs
+	mountedBytes int64
+	// cachedBlobs and cachedBytes count the blobs linked from the blob cache
+	cachedBlobs int
+	cachedBytes int64
+	// reusedBlobs and reusedBytes count the blobs linked from other repositories of the workspace
+	reusedBlobs int
+	reusedBytes int64
+	// planned is the size of the blobs in the mirror plans
+	planned int64
+	// previousImages counts the images skipped as previously mirrored
+	previousImages int
+	// archive counts the blobs left out of the imageset archive
+	archive bundle.ReconcileStats
+	// duration is the time spent mirroring
+	duration time.Duration
+	// skipped are the source references of images skipped during the run
+	skipped map[string]struct{}
+	// errors are the errors reported while mirroring
+	errors []string
+}
+
+// skip records the source image reference as skipped
+func (s *transferStats) skip(ref string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.skipped == nil {
+		s.skipped = map[string]struct{}{}
+	}
+	s.skipped[ref] = struct{}{}
+}
+
+// observe updates the statistics from a line of mirror output
+func (s *transferStats) observe(line string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	switch {
+	case fields[0] == "uploading:" && len(fields) == 4:
+		size, err := units.RAMInBytes(fields[3])
+		s.mu.Lock()
+		s.blobs++
+		if err == nil {
+			s.bytes += size
+		}
+		s.mu.Unlock()
+	case fields[0] == "mounted:" && len(fields) == 4:
+		size, err := units.RAMInBytes(fields[3])
+		s.mu.Lock()
+		s.mounted++
+		if err == nil {
+			s.mountedBytes += size
+		}
+		s.mu.Unlock()
+	case fields[0] == "stats:":
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, "size=") {
+				if size, err := units.RAMInBytes(strings.TrimPrefix(field, "size=")); err == nil {
+					s.mu.Lock()
+					s.planned += size
+					s.mu.Unlock()
+				}
+			}
+		}
+	case strings.HasPrefix(line, "warning: Image ") && strings.HasSuffix(line, " does not exist and will not be mirrored"):
+		s.skip(fields[2])
+	case fields[0] == "error:":
+		s.mu.Lock()
+		s.errors = append(s.errors, strings.TrimPrefix(line, "error: "))
+		s.mu.Unlock()
+	}
+}
+
+// skipPrevious records images skipped as previously mirrored
+func (s *transferStats) skipPrevious(images int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.previousImages += images
+	s.mu.Unlock()
+}
+
+// cached records blobs linked from the blob cache instead of downloaded
+func (s *transferStats) cached(blobs int, bytes int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.cachedBlobs += blobs
+	s.cachedBytes += bytes
+	s.mu.Unlock()
+}
+
+// reused records blobs linked from the workspace instead of downloaded
+func (s *transferStats) reused(blobs int, bytes int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.reusedBlobs += blobs
+	s.reusedBytes += bytes
+	s.mu.Unlock()
+}
+
+// reconciled records the blobs left out of the imageset archive
+func (s *transferStats) reconciled(stats bundle.ReconcileStats) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.archive.PreviousBlobs += stats.PreviousBlobs
+	s.archive.PreviousBytes += stats.PreviousBytes
+	s.archive.RegistryBlobs += stats.RegistryBlobs
+	s.archive.RegistryBytes += stats.RegistryBytes
+	s.archive.DuplicateBlobs += stats.DuplicateBlobs
+	s.archive.DuplicateBytes += stats.DuplicateBytes
+	s.mu.Unlock()
+}
+
+// counts returns the transfer counts of the run
+func (s *transferStats) counts() v1alpha2.TransferCounts {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := v1alpha2.TransferCounts{
+		Bytes:          s.bytes,
+		Blobs:          s.blobs,
+		MountedBytes:   s.mountedBytes,
+		MountedBlobs:   s.mounted,
+		CachedBytes:    s.cachedBytes,
+		CachedBlobs:    s.cachedBlobs,
+		ReusedBytes:    s.reusedBytes,
+		ReusedBlobs:    s.reusedBlobs,
+		PreviousImages: s.previousImages,
+		PreviousBytes:  s.archive.PreviousBytes,
+		PreviousBlobs:  s.archive.PreviousBlobs,
+		RegistryBytes:  s.archive.RegistryBytes,
+		RegistryBlobs:  s.archive.RegistryBlobs,
+		DuplicateBytes: s.archive.DuplicateBytes,
+		DuplicateBlobs: s.archive.DuplicateBlobs,
+	}
+	// Blobs found in the destination are skipped without any output
+	if present := s.planned - s.bytes - s.mountedBytes - s.cachedBytes - s.reusedBytes; present > 0 {
+		counts.PresentBytes = present
+	}
+	return counts
+}
+
+// time adds the duration of a mirror operation started at start
+func (s *transferStats) time(start time.Time) {
+	s.mu.Lock()
+	s.duration += time.Since(start)
+	s.mu.Unlock()
+}
+
+// mirrorLogComponent is the component field of log entries
+// with the output of the oc image mirror library
+const mirrorLogComponent = "image-mirror"
+
+// mirrorWriter passes each line written by the oc image mirror library to observe
+// and to out. When quiet, only errors and warnings are written to out.
+// When log is set, the lines are logged as structured entries instead.
+type mirrorWriter struct {
+	mu      sync.Mutex
+	out     io.Writer
+	observe func(line string)
+	quiet   bool
+	log     *logrus.Entry
+	buf     []byte
+}
+
+func (w *mirrorWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := w.buf[:i+1]
+		w.observe(strings.TrimSpace(string(line)))
+		if w.log != nil {
+			logMirrorLine(w.log, strings.TrimSpace(string(line)))
+		} else if !w.quiet || bytes.HasPrefix(line, []byte("error:")) || bytes.HasPrefix(line, []byte("warning:")) {
+			if _, err := w.out.Write(line); err != nil {
+				return 0, err
+			}
+		}
+		w.buf = w.buf[i+1:]
+	}
+}
+
+// logMirrorLine logs a line of oc image mirror output at the level of its prefix
+func logMirrorLine(log *logrus.Entry, line string) {
+	switch {
+	case line == "":
+	case strings.HasPrefix(line, "error: "):
+		log.Error(strings.TrimPrefix(line, "error: "))
+	case strings.HasPrefix(line, "warning: "):
+		log.Warn(strings.TrimPrefix(line, "warning: "))
+	case strings.HasPrefix(line, "info: "):
+		log.Info(strings.TrimPrefix(line, "info: "))
+	default:
+		log.Info(line)
+	}
+}
+
+// mirrorStreams returns the streams passed to the oc image mirror library
+func (o *MirrorOptions) mirrorStreams() genericclioptions.IOStreams {
+	if o.transfer == nil {
+		o.transfer = &transferStats{}
+	}
+	observe := func(line string) {
+		o.transfer.observe(line)
+		o.events.observe(line)
+		o.progress.observe(line)
+	}
+	var log *logrus.Entry
+	if o.JSONLogs {
+		log = o.log().WithField("component", mirrorLogComponent)
+	}
+	return genericclioptions.IOStreams{
+		Out:    &mirrorWriter{out: o.Out, observe: observe, quiet: o.Quiet, log: log},
+		ErrOut: &mirrorWriter{out: o.ErrOut, observe: observe, quiet: o.Quiet, log: log},
+	}
+}
+
+// streams returns the output streams of the run for the oc libraries
+func (o *MirrorOptions) streams() genericclioptions.IOStreams {
+	return genericclioptions.IOStreams{Out: o.Out, ErrOut: o.ErrOut}
+}
+
+// categorySummary counts the images of a category by outcome
+type categorySummary struct {
+	total, skipped, failed int
+}
+
+// summarizeImages counts the images in the mapping by category and outcome.
+// An image failed if a failure names it, its source, or its destination reference.
+func summarizeImages(mapping image.TypedImageMapping, skipped map[string]struct{}, failures []v1alpha2.Failure) map[string]*categorySummary {
+	summaries := map[string]*categorySummary{}
+	for src, dst := range mapping {
+		category := src.Category.String()
+		if summaries[category] == nil {
+			summaries[category] = &categorySummary{}
+		}
+		summary := summaries[category]
+		summary.total++
+
+		refs := []string{src.TypedImageReference.String(), src.Ref.Exact()}
+		if hasRef(skipped, refs) {
+			summary.skipped++
+			continue
+		}
+		if imageFailed(src, dst, failures) {
+			summary.failed++
+		}
+	}
+	return summaries
+}
+
+// imageFailed returns true if a failure names the image, its source
+// reference, or its destination reference in a registry. Destinations on disk
+// are relative paths that could match the end of other references.
+func imageFailed(src, dst image.TypedImage, failures []v1alpha2.Failure) bool {
+	for _, failure := range failures {
+		if failure.Image == src.TypedImageReference.String() || failure.Image == src.Ref.Exact() || containsRef(failure.Error, src.Ref.Exact()) {
+			return true
+		}
+		if dst.Type == imagesource.DestinationRegistry && containsRef(failure.Error, dst.Ref.Exact()) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRef returns true if any of the references is in refs
+func hasRef(set map[string]struct{}, refs []string) bool {
+	for _, ref := range refs {
+		if _, found := set[ref]; found {
+			return true
+		}
+	}
+	return false
+}
+
+// containsRef returns true if msg contains the complete image reference ref
+func containsRef(msg, ref string) bool {
+	for offset := 0; ; {
+		i := strings.Index(msg[offset:], ref)
+		if i < 0 {
+			return false
+		}
+		end := offset + i + len(ref)
+		if end == len(msg) || !strings.ContainsRune(refChars, rune(msg[end])) {
+			return true
+		}
+		offset += i + 1
+	}
+}
+
+// refChars are the characters that can continue an image reference
+const refChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789._-:@/"
+
+// writeSummary writes a table summarizing the results of the run to out
+func (o *MirrorOptions) writeSummary(out io.Writer, results *v1alpha2.Results, mapping image.TypedImageMapping) error {
+	stats := o.transfer
+	if stats == nil {
+		stats = &transferStats{}
+	}
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	summaries := summarizeImages(mapping, stats.skipped, results.Failures)
+	var categories []string
+	for category := range summaries {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CATEGORY\tIMAGES\tMIRRORED\tSKIPPED\tFAILED")
+	var total categorySummary
+	for _, category := range categories {
+		s := summaries[category]
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\n", category, s.total, s.total-s.skipped-s.failed, s.skipped, s.failed)
+		total.total += s.total
+		total.skipped += s.skipped
+		total.failed += s.failed
+	}
+	fmt.Fprintf(tw, "TOTAL\t%d\t%d\t%d\t%d\n", total.total, total.total-total.skipped-total.failed, total.skipped, total.failed)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+
+	status := "succeeded"
+	if !results.Success {
+		status = "failed"
+	}
+	tw = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Result:\t%s %s\n", results.Operation, status)
+	fmt.Fprintf(tw, "Duration:\t%s\n", time.Duration(results.DurationSeconds*float64(time.Second)).Round(10*time.Millisecond))
+	fmt.Fprintf(tw, "Mirroring duration:\t%s\n", stats.duration.Round(10*time.Millisecond))
+	writeTransferSummary(tw, results.Transfer)
+	if len(results.Failures) != 0 {
+		fmt.Fprintf(tw, "Errors:\t%d\n", len(results.Failures))
+	}
+	for _, archive := range results.Archives {
+		fmt.Fprintf(tw, "Archive:\t%s\n", archive)
+	}
+	if o.resultsDir != "" {
+		fmt.Fprintf(tw, "Results:\t%s\n", o.resultsDir)
+		for _, path := range append(append([]string{}, results.Manifests...), results.Mappings...) {
+			fmt.Fprintf(tw, "\t%s\n", filepath.Base(path))
+		}
+	}
+	return tw.Flush()
+}
+
+// writeTransferSummary writes the transferred blobs and
+// the blobs skipped by incremental and deduplicated transfers
+func writeTransferSummary(w io.Writer, t v1alpha2.TransferCounts) {
+	size := func(bytes int64) string { return units.BytesSize(float64(bytes)) }
+	fmt.Fprintf(w, "Transferred:\t%s in %d blobs\n", size(t.Bytes), t.Blobs)
+	if t.MountedBlobs != 0 {
+		fmt.Fprintf(w, "Mounted:\t%s in %d blobs\n", size(t.MountedBytes), t.MountedBlobs)
+	}
+	if t.CachedBlobs != 0 {
+		fmt.Fprintf(w, "From blob cache:\t%s in %d blobs\n", size(t.CachedBytes), t.CachedBlobs)
+	}
+	if t.ReusedBlobs != 0 {
+		fmt.Fprintf(w, "From workspace:\t%s in %d blobs\n", size(t.ReusedBytes), t.ReusedBlobs)
+	}
+	if t.PresentBytes != 0 {
+		fmt.Fprintf(w, "Already present:\t%s (estimated)\n", size(t.PresentBytes))
+	}
+	if saved := t.MountedBytes + t.CachedBytes + t.ReusedBytes + t.PresentBytes; saved != 0 {
+		fmt.Fprintf(w, "Transfer savings:\t%s (%.0f%% of %s)\n", size(saved),
+			100*float64(saved)/float64(saved+t.Bytes), size(saved+t.Bytes))
+	}
+	if t.PreviousImages != 0 {
+		fmt.Fprintf(w, "Previously mirrored:\t%d images\n", t.PreviousImages)
+	}
+	if t.PreviousBlobs != 0 {
+		fmt.Fprintf(w, "Previously archived:\t%s in %d blobs\n", size(t.PreviousBytes), t.PreviousBlobs)
+	}
+	if t.RegistryBlobs != 0 {
+		fmt.Fprintf(w, "In mirror registry:\t%s in %d blobs\n", size(t.RegistryBytes), t.RegistryBlobs)
+	}
+	if t.DuplicateBlobs != 0 {
+		fmt.Fprintf(w, "Deduplicated:\t%s in %d blobs\n", size(t.DuplicateBytes), t.DuplicateBlobs)
+	}
+}
@@ -0,0 +1,202 @@
+package mirror
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/bundle"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestMirrorWriter(t *testing.T) {
+	output := `sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8 reg.mirror.com/ubi8/ubi
+uploading: reg.mirror.com/ubi8/ubi sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8 1.5KiB
+uploading: reg.mirror.com/ubi8/ubi sha256:9e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8 2MiB
+mounted: reg.mirror.com/ubi8/ubi-minimal sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8 1.5KiB
+warning: Image registry.redhat.io/ubi8/ubi-micro:latest does not exist and will not be mirrored
+error: unable to retrieve source image registry.redhat.io/ubi8/ubi-init:latest manifests
+info: Mirroring completed in 1.2s (1.7MB/s)
+`
+	tests := []struct {
+		name   string
+		quiet  bool
+		expOut string
+	}{
+		{
+			name:   "Valid/Verbose",
+			expOut: output,
+		},
+		{
+			name:  "Valid/Quiet",
+			quiet: true,
+			expOut: `warning: Image registry.redhat.io/ubi8/ubi-micro:latest does not exist and will not be mirrored
+error: unable to retrieve source image registry.redhat.io/ubi8/ubi-init:latest manifests
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			stats := &transferStats{}
+			w := &mirrorWriter{out: out, observe: stats.observe, quiet: test.quiet}
+			// Write partial lines to check buffering
+			data := []byte(output)
+			for len(data) > 0 {
+				n := 17
+				if n > len(data) {
+					n = len(data)
+				}
+				_, err := w.Write(data[:n])
+				require.NoError(t, err)
+				data = data[n:]
+			}
+			require.Equal(t, test.expOut, out.String())
+			require.Equal(t, int64(1536+2*1024*1024), stats.bytes)
+			require.Equal(t, 2, stats.blobs)
+			require.Equal(t, 1, stats.mounted)
+			require.Equal(t, int64(1536), stats.mountedBytes)
+			require.Equal(t, map[string]struct{}{"registry.redhat.io/ubi8/ubi-micro:latest": {}}, stats.skipped)
+			require.Equal(t, []string{"unable to retrieve source image registry.redhat.io/ubi8/ubi-init:latest manifests"}, stats.errors)
+		})
+	}
+}
+
+func TestMirrorWriterLog(t *testing.T) {
+	logger, hook := logtest.NewNullLogger()
+	out := &bytes.Buffer{}
+	w := &mirrorWriter{
+		out:     out,
+		observe: func(string) {},
+		log:     logger.WithField("component", mirrorLogComponent),
+	}
+	_, err := w.Write([]byte(`uploading: reg.mirror.com/ubi8/ubi sha256:9e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8 2MiB
+
+warning: Image registry.redhat.io/ubi8/ubi-micro:latest does not exist and will not be mirrored
+error: unable to retrieve source image registry.redhat.io/ubi8/ubi-init:latest manifests
+info: Mirroring completed in 1.2s (1.7MB/s)
+`))
+	require.NoError(t, err)
+	require.Empty(t, out.String())
+
+	entries := hook.AllEntries()
+	require.Len(t, entries, 4)
+	exp := []struct {
+		level logrus.Level
+		msg   string
+	}{
+		{logrus.InfoLevel, "uploading: reg.mirror.com/ubi8/ubi sha256:9e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8 2MiB"},
+		{logrus.WarnLevel, "Image registry.redhat.io/ubi8/ubi-micro:latest does not exist and will not be mirrored"},
+		{logrus.ErrorLevel, "unable to retrieve source image registry.redhat.io/ubi8/ubi-init:latest manifests"},
+		{logrus.InfoLevel, "Mirroring completed in 1.2s (1.7MB/s)"},
+	}
+	for i, entry := range entries {
+		require.Equal(t, exp[i].level, entry.Level)
+		require.Equal(t, exp[i].msg, entry.Message)
+		require.Equal(t, mirrorLogComponent, entry.Data["component"])
+	}
+}
+
+func TestContainsRef(t *testing.T) {
+	require.True(t, containsRef("unable to retrieve source image registry.redhat.io/ubi8/ubi:latest manifests", "registry.redhat.io/ubi8/ubi:latest"))
+	require.True(t, containsRef("image registry.redhat.io/ubi8/ubi:latest", "registry.redhat.io/ubi8/ubi:latest"))
+	require.False(t, containsRef("image registry.redhat.io/ubi8/ubi:latest-1", "registry.redhat.io/ubi8/ubi:latest"))
+	require.False(t, containsRef("image registry.redhat.io/ubi8/ubi-minimal:latest", "registry.redhat.io/ubi8/ubi"))
+}
+
+func TestWriteSummary(t *testing.T) {
+	parse := func(ref string, typ v1alpha2.ImageType) image.TypedImage {
+		img, err := image.ParseTypedImage(ref, typ)
+		require.NoError(t, err)
+		return img
+	}
+	mapping := image.TypedImageMapping{
+		parse("registry.redhat.io/ubi8/ubi:latest", v1alpha2.TypeGeneric):                        parse("reg.mirror.com/ubi8/ubi:latest", v1alpha2.TypeGeneric),
+		parse("registry.redhat.io/ubi8/ubi-micro:latest", v1alpha2.TypeGeneric):                  parse("reg.mirror.com/ubi8/ubi-micro:latest", v1alpha2.TypeGeneric),
+		parse("registry.redhat.io/ubi8/ubi-init:latest", v1alpha2.TypeGeneric):                   parse("reg.mirror.com/ubi8/ubi-init:latest", v1alpha2.TypeGeneric),
+		parse("quay.io/openshift-release-dev/ocp-release:4.9.0-x86_64", v1alpha2.TypeOCPRelease): parse("reg.mirror.com/openshift/release-images:4.9.0-x86_64", v1alpha2.TypeOCPRelease),
+	}
+	opts := &MirrorOptions{
+		resultsDir: "results-1639608409",
+		transfer: &transferStats{
+			bytes:          2 * 1024 * 1024,
+			blobs:          2,
+			mounted:        1,
+			mountedBytes:   1024 * 1024,
+			planned:        8 * 1024 * 1024,
+			previousImages: 3,
+			skipped:        map[string]struct{}{"registry.redhat.io/ubi8/ubi-micro:latest": {}},
+		},
+	}
+	results := newResults(v1alpha2.OperationMirrorToMirror)
+	results.Transfer = opts.transfer.counts()
+	results.DurationSeconds = 12.5
+	results.Failures = []v1alpha2.Failure{{Error: "unable to retrieve source image registry.redhat.io/ubi8/ubi-init:latest manifests"}}
+	results.Manifests = []string{"results-1639608409/imageContentSourcePolicy.yaml"}
+	results.Mappings = []string{"results-1639608409/mapping.txt"}
+
+	out := &bytes.Buffer{}
+	require.NoError(t, opts.writeSummary(out, results, mapping))
+	exp := `CATEGORY    IMAGES  MIRRORED  SKIPPED  FAILED
+generic     3       1         1        1
+ocpRelease  1       1         0        0
+TOTAL       4       2         1        1
+
+Result:               mirrorToMirror failed
+Duration:             12.5s
+Mirroring duration:   0s
+Transferred:          2MiB in 2 blobs
+Mounted:              1MiB in 1 blobs
+Already present:      5MiB (estimated)
+Transfer savings:     6MiB (75% of 8MiB)
+Previously mirrored:  3 images
+Errors:               1
+Results:              results-1639608409
+                      imageContentSourcePolicy.yaml
+                      mapping.txt
+`
+	require.Equal(t, exp, out.String())
+}
+
+func TestTransferStatsCounts(t *testing.T) {
+	stats := &transferStats{}
+	for _, line := range []string{
+		"  stats: shared=0 unique=2 size=3MiB ratio=1.00",
+		"  stats: shared=1 unique=1 size=1MiB",
+		"uploading: reg.mirror.com/ubi8/ubi sha256:9e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8 2MiB",
+		"mounted: reg.mirror.com/ubi8/ubi-minimal sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8 512KiB",
+	} {
+		stats.observe(line)
+	}
+	stats.skipPrevious(2)
+	stats.cached(1, 512*1024)
+	stats.reused(1, 256*1024)
+	stats.reconciled(bundle.ReconcileStats{PreviousBlobs: 4, PreviousBytes: 4096, RegistryBlobs: 2, RegistryBytes: 2048, DuplicateBlobs: 1, DuplicateBytes: 512})
+
+	exp := v1alpha2.TransferCounts{
+		Bytes:          2 * 1024 * 1024,
+		Blobs:          1,
+		MountedBytes:   512 * 1024,
+		MountedBlobs:   1,
+		CachedBytes:    512 * 1024,
+		CachedBlobs:    1,
+		ReusedBytes:    256 * 1024,
+		ReusedBlobs:    1,
+		PresentBytes:   768 * 1024,
+		PreviousImages: 2,
+		PreviousBytes:  4096,
+		PreviousBlobs:  4,
+		RegistryBytes:  2048,
+		RegistryBlobs:  2,
+		DuplicateBytes: 512,
+		DuplicateBlobs: 1,
+	}
+	require.Equal(t, exp, stats.counts())
+
+	// Without a mirror plan nothing is estimated to be present
+	require.Zero(t, (&transferStats{bytes: 1024}).counts().PresentBytes)
+}
@@ -0,0 +1,204 @@
+package mirror
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpgrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/version"
+)
+
+const (
+	// tracerName is the name of the tracer of the run spans
+	tracerName = "github.com/openshift/oc-mirror"
+	// traceShutdownTimeout bounds the export of the remaining spans
+	traceShutdownTimeout = 10 * time.Second
+)
+
+// newSpanExporter returns the span exporter of target, which is one of
+// otlp://host:port, otlps://host:port, or file://path
+func newSpanExporter(ctx context.Context, target string) (sdktrace.SpanExporter, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trace exporter %q: %v", target, err)
+	}
+	switch u.Scheme {
+	case "otlp", "otlps":
+		if u.Host == "" {
+			return nil, fmt.Errorf("trace exporter %q must include a host and port", target)
+		}
+		opts := []otlpgrpc.Option{otlpgrpc.WithEndpoint(u.Host)}
+		if u.Scheme == "otlp" {
+			opts = append(opts, otlpgrpc.WithInsecure())
+		} else {
+			opts = append(opts, otlpgrpc.WithTLSCredentials(credentials.NewTLS(&tls.Config{})))
+		}
+		return otlp.NewExporter(ctx, otlpgrpc.NewDriver(opts...))
+	case "file":
+		// Accept relative paths such as file://spans.ndjson
+		path := u.Host + u.Path
+		if path == "" {
+			return nil, fmt.Errorf("trace exporter %q must include a file path", target)
+		}
+		return newFileSpanExporter(path)
+	default:
+		return nil, fmt.Errorf("trace exporter %q is not supported: must be otlp://, otlps://, or file://", target)
+	}
+}
+
+// runTracer traces the phases of a run as children of a root run span.
+// The methods of a nil runTracer are no-ops.
+type runTracer struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+
+	mu sync.Mutex
+	// ctxs is the stack of contexts of the open spans
+	ctxs []context.Context
+	log  logrus.FieldLogger
+}
+
+// newRunTracer returns a tracer exporting spans to exporter
+func newRunTracer(exporter sdktrace.SpanExporter, log logrus.FieldLogger) *runTracer {
+	res := resource.NewWithAttributes(
+		semconv.ServiceNameKey.String("oc-mirror"),
+		semconv.ServiceVersionKey.String(version.Get().GitVersion),
+	)
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	return &runTracer{
+		provider: provider,
+		tracer:   provider.Tracer(tracerName),
+		ctxs:     []context.Context{context.Background()},
+		log:      log,
+	}
+}
+
+// start starts a span as a child of the innermost open span.
+// The returned function ends the span with its error.
+func (t *runTracer) start(name string, attrs ...attribute.KeyValue) func(error) {
+	if t == nil {
+		return func(error) {}
+	}
+	t.mu.Lock()
+	ctx, span := t.tracer.Start(t.ctxs[len(t.ctxs)-1], name, trace.WithAttributes(attrs...))
+	t.ctxs = append(t.ctxs, ctx)
+	t.mu.Unlock()
+	return func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		for i := len(t.ctxs) - 1; i > 0; i-- {
+			if t.ctxs[i] == ctx {
+				t.ctxs = append(t.ctxs[:i], t.ctxs[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// startRun starts the root span of a run of operation
+func (t *runTracer) startRun(operation v1alpha2.Operation) func(error) {
+	return t.start("oc-mirror "+string(operation), attribute.String("oc_mirror.operation", string(operation)))
+}
+
+// shutdown exports the remaining spans and stops the tracer
+func (t *runTracer) shutdown() {
+	if t == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), traceShutdownTimeout)
+	defer cancel()
+	if err := t.provider.Shutdown(ctx); err != nil {
+		t.log.Errorf("error exporting trace spans: %v", err)
+	}
+}
+
+// fileSpan is a span as written by the file span exporter
+type fileSpan struct {
+	TraceID         string                 `json:"traceID"`
+	SpanID          string                 `json:"spanID"`
+	ParentSpanID    string                 `json:"parentSpanID,omitempty"`
+	Name            string                 `json:"name"`
+	StartTime       time.Time              `json:"startTime"`
+	EndTime         time.Time              `json:"endTime"`
+	DurationSeconds float64                `json:"durationSeconds"`
+	Attributes      map[string]interface{} `json:"attributes,omitempty"`
+	Status          string                 `json:"status"`
+	StatusMessage   string                 `json:"statusMessage,omitempty"`
+}
+
+// fileSpanExporter writes spans to a file as newline delimited JSON
+type fileSpanExporter struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+func newFileSpanExporter(path string) (*fileSpanExporter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("error creating trace file: %v", err)
+	}
+	return &fileSpanExporter{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+func (e *fileSpanExporter) ExportSpans(_ context.Context, spans []*sdktrace.SpanSnapshot) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, s := range spans {
+		span := fileSpan{
+			TraceID:         s.SpanContext.TraceID().String(),
+			SpanID:          s.SpanContext.SpanID().String(),
+			Name:            s.Name,
+			StartTime:       s.StartTime,
+			EndTime:         s.EndTime,
+			DurationSeconds: s.EndTime.Sub(s.StartTime).Seconds(),
+			Status:          strings.ToLower(s.StatusCode.String()),
+			StatusMessage:   s.StatusMessage,
+		}
+		if s.Parent.HasSpanID() {
+			span.ParentSpanID = s.Parent.SpanID().String()
+		}
+		if len(s.Attributes) != 0 {
+			span.Attributes = make(map[string]interface{}, len(s.Attributes))
+			for _, kv := range s.Attributes {
+				span.Attributes[string(kv.Key)] = kv.Value.AsInterface()
+			}
+		}
+		if err := e.enc.Encode(span); err != nil {
+			return fmt.Errorf("error writing trace span: %v", err)
+		}
+	}
+	return nil
+}
+
+func (e *fileSpanExporter) Shutdown(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.file.Close()
+}
@@ -0,0 +1,144 @@
+package mirror
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+type memorySpanExporter struct {
+	mu    sync.Mutex
+	spans []*sdktrace.SpanSnapshot
+}
+
+func (e *memorySpanExporter) ExportSpans(_ context.Context, spans []*sdktrace.SpanSnapshot) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *memorySpanExporter) Shutdown(context.Context) error { return nil }
+
+func TestRunTracer(t *testing.T) {
+	exporter := &memorySpanExporter{}
+	opts := &MirrorOptions{tracer: newRunTracer(exporter, logrus.New())}
+
+	endRun := opts.tracer.startRun(v1alpha2.OperationDiskToMirror)
+	done := opts.startPhase(phasePush)
+	unpackDone := opts.startPhase(phaseUnpack)
+	unpackDone(nil)
+	done(errors.New("error pushing images"))
+	done = opts.startPhase(phaseManifests)
+	done(nil)
+	endRun(nil)
+	opts.tracer.shutdown()
+
+	spans := map[string]*sdktrace.SpanSnapshot{}
+	for _, span := range exporter.spans {
+		spans[span.Name] = span
+	}
+	require.Len(t, spans, 4)
+	run := spans["oc-mirror diskToMirror"]
+	require.NotNil(t, run)
+	require.False(t, run.Parent.IsValid())
+	require.Equal(t, codes.Unset, run.StatusCode)
+
+	require.Equal(t, run.SpanContext.SpanID(), spans[phasePush].Parent.SpanID())
+	require.Equal(t, spans[phasePush].SpanContext.SpanID(), spans[phaseUnpack].Parent.SpanID())
+	require.Equal(t, run.SpanContext.SpanID(), spans[phaseManifests].Parent.SpanID())
+	require.Equal(t, codes.Error, spans[phasePush].StatusCode)
+	require.Equal(t, "error pushing images", spans[phasePush].StatusMessage)
+	for _, span := range exporter.spans {
+		require.Equal(t, run.SpanContext.TraceID(), span.SpanContext.TraceID())
+	}
+}
+
+func TestFileSpanExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.ndjson")
+	exporter, err := newSpanExporter(context.Background(), "file://"+path)
+	require.NoError(t, err)
+	tracer := newRunTracer(exporter, logrus.New())
+	endRun := tracer.startRun(v1alpha2.OperationMirrorToDisk)
+	tracer.start(phaseArchive)(errors.New("disk full"))
+	endRun(nil)
+	tracer.shutdown()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var spans []fileSpan
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var span fileSpan
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &span))
+		spans = append(spans, span)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, spans, 2)
+
+	require.Equal(t, phaseArchive, spans[0].Name)
+	require.Equal(t, "error", spans[0].Status)
+	require.Equal(t, "disk full", spans[0].StatusMessage)
+	require.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+	require.Equal(t, "", spans[1].ParentSpanID)
+	require.Equal(t, string(v1alpha2.OperationMirrorToDisk), spans[1].Attributes["oc_mirror.operation"])
+}
+
+func TestNewSpanExporter(t *testing.T) {
+	type spec struct {
+		name     string
+		target   string
+		expError string
+	}
+
+	cases := []spec{
+		{
+			name:   "Valid/OTLP",
+			target: "otlp://localhost:4317",
+		},
+		{
+			name:   "Valid/OTLPWithTLS",
+			target: "otlps://collector.example.com:4317",
+		},
+		{
+			name:     "Invalid/MissingHost",
+			target:   "otlp://",
+			expError: `trace exporter "otlp://" must include a host and port`,
+		},
+		{
+			name:     "Invalid/MissingPath",
+			target:   "file://",
+			expError: `trace exporter "file://" must include a file path`,
+		},
+		{
+			name:     "Invalid/UnsupportedScheme",
+			target:   "jaeger://localhost:14268",
+			expError: `trace exporter "jaeger://localhost:14268" is not supported: must be otlp://, otlps://, or file://`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			exporter, err := newSpanExporter(context.Background(), c.target)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, exporter.Shutdown(context.Background()))
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver/v4"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/cincinnati"
+)
+
+// defaultChannelGroup is the channel group used for upgrade paths
+const defaultChannelGroup = "stable"
+
+// upgradePathVersion is an upgrade path endpoint
+type upgradePathVersion struct {
+	version semver.Version
+	// minorOnly is true when only the x.y version was specified
+	minorOnly bool
+}
+
+// channel returns the channel in the channel group for the endpoint minor version
+func (v upgradePathVersion) channel(group string) string {
+	return fmt.Sprintf("%s-%d.%d", group, v.version.Major, v.version.Minor)
+}
+
+// parseUpgradePathVersion parses an x.y or x.y.z upgrade path endpoint
+func parseUpgradePathVersion(s string) (upgradePathVersion, error) {
+	if strings.Count(s, ".") == 1 {
+		v, err := semver.Parse(s + ".0")
+		if err != nil {
+			return upgradePathVersion{}, err
+		}
+		return upgradePathVersion{version: v, minorOnly: true}, nil
+	}
+	v, err := semver.Parse(s)
+	if err != nil {
+		return upgradePathVersion{}, err
+	}
+	return upgradePathVersion{version: v}, nil
+}
+
+// parseUpgradePath returns the validated endpoints of the upgrade path
+func parseUpgradePath(path v1alpha2.UpgradePath) (upgradePathVersion, upgradePathVersion, error) {
+	from, err := parseUpgradePathVersion(path.From)
+	if err != nil {
+		return upgradePathVersion{}, upgradePathVersion{}, fmt.Errorf("invalid upgrade path start %q: %v", path.From, err)
+	}
+	to, err := parseUpgradePathVersion(path.To)
+	if err != nil {
+		return upgradePathVersion{}, upgradePathVersion{}, fmt.Errorf("invalid upgrade path target %q: %v", path.To, err)
+	}
+	if from.version.Major != to.version.Major {
+		return upgradePathVersion{}, upgradePathVersion{}, fmt.Errorf("upgrade path %s to %s crosses major versions", path.From, path.To)
+	}
+	if from.version.GT(to.version) {
+		return upgradePathVersion{}, upgradePathVersion{}, fmt.Errorf("upgrade path start %s is greater than target %s", path.From, path.To)
+	}
+	return from, to, nil
+}
+
+// getUpgradePathDownloads returns the release payloads in the upgrade
+// subgraph of the path for the architecture
+func (o *ReleaseOptions) getUpgradePathDownloads(ctx context.Context, path v1alpha2.UpgradePath, arch string) (downloads, error) {
+	from, to, err := parseUpgradePath(path)
+	if err != nil {
+		return downloads{}, err
+	}
+	group := path.ChannelGroup
+	if group == "" {
+		group = defaultChannelGroup
+	}
+	sourceChannel, targetChannel := from.channel(group), to.channel(group)
+
+	client, err := cincinnati.NewOCPClient(o.uuid, o.Clients())
+	if err != nil {
+		return downloads{}, err
+	}
+
+	start := from.version
+	if from.minorOnly {
+		start, err = getMinorBound(ctx, client, sourceChannel, from.version, true)
+		if err != nil {
+			return downloads{}, err
+		}
+	}
+	end := to.version
+	if to.minorOnly {
+		end, err = getMinorBound(ctx, client, targetChannel, to.version, false)
+		if err != nil {
+			return downloads{}, err
+		}
+	}
+
+	o.log().Infof("Calculating upgrade path from %s in %s to %s in %s for architecture %s", start, sourceChannel, end, targetChannel, arch)
+	current, newest, updates, err := cincinnati.CalculateUpgrades(ctx, client, arch, sourceChannel, targetChannel, start, end)
+	if err != nil {
+		return downloads{}, fmt.Errorf("failed to get upgrade graph for %s to %s: %v", path.From, path.To, err)
+	}
+	return o.gatherUpdates(current, newest, updates), nil
+}
+
+// getMinorBound returns the first or latest release of the
+// minor version in the channel. Channels also contain releases
+// of the previous minor, so those are ignored.
+func getMinorBound(ctx context.Context, c cincinnati.Client, channel string, minor semver.Version, min bool) (semver.Version, error) {
+	versions, err := cincinnati.GetVersions(ctx, c, channel)
+	if err != nil {
+		return semver.Version{}, err
+	}
+	var bound semver.Version
+	var found bool
+	for _, v := range versions {
+		if v.Major != minor.Major || v.Minor != minor.Minor {
+			continue
+		}
+		if !found || (min && v.LT(bound)) || (!min && v.GT(bound)) {
+			bound = v
+			found = true
+		}
+	}
+	if !found {
+		return semver.Version{}, fmt.Errorf("no %d.%d releases found in channel %s", minor.Major, minor.Minor, channel)
+	}
+	return bound, nil
+}
@@ -0,0 +1,61 @@
+package mirror
+
+import (
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+func TestParseUpgradePath(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     v1alpha2.UpgradePath
+		expFrom  upgradePathVersion
+		expTo    upgradePathVersion
+		expError string
+	}{
+		{
+			name:    "Valid/MinorVersions",
+			path:    v1alpha2.UpgradePath{From: "4.10", To: "4.12"},
+			expFrom: upgradePathVersion{version: semver.MustParse("4.10.0"), minorOnly: true},
+			expTo:   upgradePathVersion{version: semver.MustParse("4.12.0"), minorOnly: true},
+		},
+		{
+			name:    "Valid/ExactVersions",
+			path:    v1alpha2.UpgradePath{From: "4.10.20", To: "4.12.3"},
+			expFrom: upgradePathVersion{version: semver.MustParse("4.10.20")},
+			expTo:   upgradePathVersion{version: semver.MustParse("4.12.3")},
+		},
+		{
+			name:     "Invalid/Reversed",
+			path:     v1alpha2.UpgradePath{From: "4.12", To: "4.10"},
+			expError: "upgrade path start 4.12 is greater than target 4.10",
+		},
+		{
+			name:     "Invalid/MajorVersion",
+			path:     v1alpha2.UpgradePath{From: "3.11", To: "4.1"},
+			expError: "upgrade path 3.11 to 4.1 crosses major versions",
+		},
+		{
+			name:     "Invalid/Version",
+			path:     v1alpha2.UpgradePath{From: "four", To: "4.12"},
+			expError: "invalid upgrade path start \"four\": No Major.Minor.Patch elements found",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			from, to, err := parseUpgradePath(test.path)
+			if test.expError != "" {
+				require.EqualError(t, err, test.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expFrom, from)
+			require.Equal(t, test.expTo, to)
+			require.Equal(t, "stable-4.10", from.channel(defaultChannelGroup))
+		})
+	}
+}
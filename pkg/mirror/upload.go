@@ -0,0 +1,237 @@
+package mirror
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// uploadUsernameEnv and uploadPasswordEnv are the environment variables
+	// holding the basic auth credentials of the upload URL
+	uploadUsernameEnv = "OC_MIRROR_UPLOAD_USERNAME"
+	uploadPasswordEnv = "OC_MIRROR_UPLOAD_PASSWORD"
+	// uploadTokenEnv is the environment variable holding
+	// the bearer token of the upload URL
+	uploadTokenEnv = "OC_MIRROR_UPLOAD_TOKEN"
+)
+
+var (
+	// uploadAttempts is the number of times a file upload is attempted
+	uploadAttempts = 3
+	// uploadBackoff is the wait between upload attempts
+	uploadBackoff = 5 * time.Second
+)
+
+// archiveTOC is the table of contents of the archives of an imageset
+type archiveTOC struct {
+	Sequence int          `json:"sequence"`
+	Archives []tocArchive `json:"archives"`
+}
+
+// tocArchive lists the files of an archive
+type tocArchive struct {
+	Name   string   `json:"name"`
+	Size   int64    `json:"size"`
+	SHA256 string   `json:"sha256"`
+	Files  []string `json:"files"`
+}
+
+// archiveChecksumsName and archiveTOCName return the names of the checksums
+// and table of contents of the archives of an imageset sequence
+func archiveChecksumsName(sequence int) string {
+	return fmt.Sprintf("mirror_seq%d_SHA256SUMS", sequence)
+}
+
+func archiveTOCName(sequence int) string {
+	return fmt.Sprintf("mirror_seq%d_toc.json", sequence)
+}
+
+// parseUploadURL parses the URL of the directory the archives are uploaded to
+func parseUploadURL(upload string) (*url.URL, error) {
+	u, err := url.Parse(upload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload URL: %v", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("upload URL %q must be an http or https URL", u.Redacted())
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("upload URL %q must not have a query or fragment", u.Redacted())
+	}
+	return u, nil
+}
+
+// uploadArchives writes the checksums and table of contents of the archives
+// of the imageset sequence next to them, then uploads the archives, followed
+// by their checksums and table of contents, to the upload URL with HTTP PUT,
+// so consumers can wait for the checksums before downloading the archives.
+// It returns the redacted URLs of the uploaded files.
+func (o *MirrorOptions) uploadArchives(ctx context.Context, sequence int) ([]string, error) {
+	base, err := parseUploadURL(o.UploadURL)
+	if err != nil {
+		return nil, err
+	}
+	archives, err := filepath.Glob(filepath.Join(o.OutputDir, fmt.Sprintf("mirror_seq%d_*.tar", sequence)))
+	if err != nil {
+		return nil, err
+	}
+	if len(archives) == 0 {
+		return nil, nil
+	}
+	sort.Strings(archives)
+
+	toc := archiveTOC{Sequence: sequence}
+	var sums strings.Builder
+	for _, archive := range archives {
+		entry, err := describeArchive(archive)
+		if err != nil {
+			return nil, err
+		}
+		toc.Archives = append(toc.Archives, entry)
+		fmt.Fprintf(&sums, "%s  %s\n", entry.SHA256, entry.Name)
+	}
+	checksumsPath := filepath.Join(o.OutputDir, archiveChecksumsName(sequence))
+	if err := ioutil.WriteFile(checksumsPath, []byte(sums.String()), 0640); err != nil {
+		return nil, fmt.Errorf("error writing archive checksums: %v", err)
+	}
+	data, err := json.MarshalIndent(toc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	tocPath := filepath.Join(o.OutputDir, archiveTOCName(sequence))
+	if err := ioutil.WriteFile(tocPath, append(data, '\n'), 0640); err != nil {
+		return nil, fmt.Errorf("error writing archive table of contents: %v", err)
+	}
+
+	checksums := make(map[string]string, len(toc.Archives))
+	for _, entry := range toc.Archives {
+		checksums[entry.Name] = entry.SHA256
+	}
+	var uploaded []string
+	for _, file := range append(archives, checksumsPath, tocPath) {
+		name := filepath.Base(file)
+		target := *base
+		target.Path = path.Join(base.Path, name)
+		o.log().Infof("Uploading %s to %s", name, target.Redacted())
+		if err := o.uploadFile(ctx, target.String(), file, checksums[name]); err != nil {
+			return uploaded, fmt.Errorf("error uploading %s to %s: %v", name, target.Redacted(), err)
+		}
+		uploaded = append(uploaded, target.Redacted())
+	}
+	return uploaded, nil
+}
+
+// describeArchive returns the size, checksum, and files of the archive
+func describeArchive(archive string) (tocArchive, error) {
+	entry := tocArchive{Name: filepath.Base(archive), Files: []string{}}
+	f, err := os.Open(filepath.Clean(archive))
+	if err != nil {
+		return entry, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	tr := tar.NewReader(io.TeeReader(f, h))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return entry, fmt.Errorf("error reading archive %s: %v", archive, err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			entry.Files = append(entry.Files, hdr.Name)
+		}
+	}
+	// Hash the padding after the last entry
+	if _, err := io.Copy(h, f); err != nil {
+		return entry, fmt.Errorf("error reading archive %s: %v", archive, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return entry, err
+	}
+	entry.Size = info.Size()
+	entry.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return entry, nil
+}
+
+// uploadFile puts the file to the URL, retrying on connection
+// errors and server errors. The checksum of the file, if known,
+// is sent for servers verifying it, such as Artifactory.
+func (o *MirrorOptions) uploadFile(ctx context.Context, u, file, checksum string) error {
+	var err error
+	for attempt := 1; attempt <= uploadAttempts; attempt++ {
+		var retry bool
+		if retry, err = o.uploadFileOnce(ctx, u, file, checksum); err == nil || !retry {
+			return err
+		}
+		if attempt < uploadAttempts {
+			o.log().Debugf("upload attempt %d failed, retrying: %v", attempt, err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(uploadBackoff):
+			}
+		}
+	}
+	return err
+}
+
+// uploadFileOnce puts the file to the URL and
+// returns whether a failed request can be retried
+func (o *MirrorOptions) uploadFileOnce(ctx context.Context, u, file, checksum string) (bool, error) {
+	f, err := os.Open(filepath.Clean(file))
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, f)
+	if err != nil {
+		return false, err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if checksum != "" {
+		req.Header.Set("X-Checksum-Sha256", checksum)
+	}
+	if username := os.Getenv(uploadUsernameEnv); username != "" {
+		req.SetBasicAuth(username, os.Getenv(uploadPasswordEnv))
+	} else if token := os.Getenv(uploadTokenEnv); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := o.Clients().HTTPClient().Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Errorf("unexpected status %s", resp.Status)
+		if msg := strings.TrimSpace(string(body)); msg != "" {
+			err = fmt.Errorf("unexpected status %s: %s", resp.Status, msg)
+		}
+		return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, err
+	}
+	return false, nil
+}
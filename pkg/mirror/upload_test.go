@@ -0,0 +1,130 @@
+package mirror
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestArchive writes a tar archive of the files to path
+func writeTestArchive(t *testing.T, path string, files map[string]string) {
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+}
+
+func TestUploadArchives(t *testing.T) {
+	defer func(backoff time.Duration) { uploadBackoff = backoff }(uploadBackoff)
+	uploadBackoff = 0
+	t.Setenv(uploadUsernameEnv, "admin")
+	t.Setenv(uploadPasswordEnv, "secret")
+
+	var mu sync.Mutex
+	uploads := map[string][]byte{}
+	checksums := map[string]string{}
+	failed := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		data, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		mu.Lock()
+		defer mu.Unlock()
+		// Fail the first attempt of each file
+		if !failed[r.URL.Path] {
+			failed[r.URL.Path] = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		uploads[r.URL.Path] = data
+		checksums[r.URL.Path] = r.Header.Get("X-Checksum-Sha256")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	output := t.TempDir()
+	writeTestArchive(t, filepath.Join(output, "mirror_seq1_000000.tar"), map[string]string{"publish/.metadata.json": "{}"})
+	writeTestArchive(t, filepath.Join(output, "mirror_seq2_000000.tar"), map[string]string{"publish/.metadata.json": "{}"})
+	writeTestArchive(t, filepath.Join(output, "mirror_seq2_000001.tar"), map[string]string{"blobs/sha256:abc": "layer"})
+
+	o := &MirrorOptions{
+		Dir:       t.TempDir(),
+		OutputDir: output,
+		UploadURL: server.URL + "/repository/mirror/",
+	}
+	uploaded, err := o.uploadArchives(context.Background(), 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		server.URL + "/repository/mirror/mirror_seq2_000000.tar",
+		server.URL + "/repository/mirror/mirror_seq2_000001.tar",
+		server.URL + "/repository/mirror/mirror_seq2_SHA256SUMS",
+		server.URL + "/repository/mirror/mirror_seq2_toc.json",
+	}, uploaded)
+	require.Len(t, uploads, 4)
+
+	var expSums string
+	for _, name := range []string{"mirror_seq2_000000.tar", "mirror_seq2_000001.tar"} {
+		data, err := ioutil.ReadFile(filepath.Join(output, name))
+		require.NoError(t, err)
+		require.Equal(t, data, uploads["/repository/mirror/"+name])
+		sum := sha256.Sum256(data)
+		require.Equal(t, hex.EncodeToString(sum[:]), checksums["/repository/mirror/"+name])
+		expSums += hex.EncodeToString(sum[:]) + "  " + name + "\n"
+	}
+	require.Equal(t, expSums, string(uploads["/repository/mirror/mirror_seq2_SHA256SUMS"]))
+	data, err := ioutil.ReadFile(filepath.Join(output, "mirror_seq2_SHA256SUMS"))
+	require.NoError(t, err)
+	require.Equal(t, expSums, string(data))
+
+	var toc archiveTOC
+	require.NoError(t, json.Unmarshal(uploads["/repository/mirror/mirror_seq2_toc.json"], &toc))
+	require.Equal(t, 2, toc.Sequence)
+	require.Len(t, toc.Archives, 2)
+	require.Equal(t, "mirror_seq2_000001.tar", toc.Archives[1].Name)
+	require.Equal(t, []string{"blobs/sha256:abc"}, toc.Archives[1].Files)
+	require.Equal(t, checksums["/repository/mirror/mirror_seq2_000001.tar"], toc.Archives[1].SHA256)
+}
+
+func TestUploadArchivesError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "repository is read-only", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	output := t.TempDir()
+	writeTestArchive(t, filepath.Join(output, "mirror_seq1_000000.tar"), map[string]string{"publish/.metadata.json": "{}"})
+	o := &MirrorOptions{
+		Dir:       t.TempDir(),
+		OutputDir: output,
+		UploadURL: server.URL + "/repository/mirror",
+	}
+	uploaded, err := o.uploadArchives(context.Background(), 1)
+	require.EqualError(t, err, "error uploading mirror_seq1_000000.tar to "+server.URL+
+		"/repository/mirror/mirror_seq1_000000.tar: unexpected status 403 Forbidden: repository is read-only")
+	require.Empty(t, uploaded)
+}
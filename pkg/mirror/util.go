@@ -0,0 +1,70 @@
+package mirror
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func (o *MirrorOptions) getRemoteOpts(ctx context.Context, insecure bool) []remote.Option {
+	return o.Clients().RemoteOptions(ctx, insecure)
+}
+
+func getNameOpts(insecure bool) (options []name.Option) {
+	if insecure {
+		options = append(options, name.Insecure)
+	}
+	return options
+}
+
+// ResultsDir returns the results directory of the last
+// run, or an empty string if it did not create one
+func (o *MirrorOptions) ResultsDir() string {
+	return o.resultsDir
+}
+
+// createResultsDir creates the results directory of the run.
+// The same directory is returned for the rest of the run.
+func (o *MirrorOptions) createResultsDir() (resultsDir string, err error) {
+	if o.resultsDir != "" {
+		return o.resultsDir, nil
+	}
+	name, err := o.resultsDirName(time.Now())
+	if err != nil {
+		return "", err
+	}
+	resultsDir, err = uniqueDir(filepath.Join(o.Dir, name))
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(resultsDir, os.ModePerm); err != nil {
+		return resultsDir, err
+	}
+	o.resultsDir = resultsDir
+	return resultsDir, nil
+}
+
+func (o *MirrorOptions) newMetadataImage(uid string) string {
+	repo := path.Join(o.ToMirror, o.UserNamespace, "oc-mirror")
+	return fmt.Sprintf("%s:%s", repo, uid)
+}
+
+func getTLSConfig() (*tls.Config, error) {
+	certPool, err := x509.SystemCertPool()
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		RootCAs:    certPool,
+		MinVersion: tls.VersionTLS12,
+	}
+	return config, nil
+}
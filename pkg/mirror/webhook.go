@@ -0,0 +1,193 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	units "github.com/docker/go-units"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+const (
+	// jsonWebhook receives the run results as JSON
+	jsonWebhook = "json"
+	// slackWebhook receives a summary of the run as a Slack message
+	slackWebhook = "slack"
+
+	// maxWebhookFailures is the number of failures listed in a Slack message
+	maxWebhookFailures = 5
+)
+
+var (
+	// webhookTimeout is the timeout of a single webhook request
+	webhookTimeout = 30 * time.Second
+	// webhookAttempts is the number of times a webhook is attempted
+	webhookAttempts = 3
+	// webhookBackoff is the wait between webhook attempts
+	webhookBackoff = 5 * time.Second
+)
+
+// webhook is a URL notified when the run completes
+type webhook struct {
+	format string
+	url    string
+}
+
+// parseWebhooks parses [format=]URL webhook specifications.
+// The format defaults to slack for Slack incoming webhook URLs and json otherwise.
+func parseWebhooks(specs []string) ([]webhook, error) {
+	var hooks []webhook
+	for _, spec := range specs {
+		hook := webhook{url: spec}
+		for _, format := range []string{jsonWebhook, slackWebhook} {
+			if strings.HasPrefix(spec, format+"=") {
+				hook.format, hook.url = format, strings.TrimPrefix(spec, format+"=")
+			}
+		}
+		u, err := url.Parse(hook.url)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("webhook %q must be in the form [json=|slack=]http(s)://host/path", spec)
+		}
+		if hook.format == "" {
+			hook.format = jsonWebhook
+			if u.Host == "hooks.slack.com" {
+				hook.format = slackWebhook
+			}
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, nil
+}
+
+// notifyWebhooks sends the results of the run to the configured webhooks.
+// Delivery errors are logged and do not fail the run.
+func (o *MirrorOptions) notifyWebhooks(ctx context.Context, results *v1alpha2.Results) {
+	hooks, err := parseWebhooks(o.Webhooks)
+	if err != nil {
+		o.log().Errorf("error parsing webhooks: %v", err)
+		return
+	}
+	for _, hook := range hooks {
+		payload, err := webhookPayload(hook.format, results)
+		if err != nil {
+			o.log().Errorf("error creating webhook payload: %v", err)
+			continue
+		}
+		if err := o.postWebhook(ctx, hook.url, payload); err != nil {
+			o.log().Errorf("error notifying webhook %s: %v", redactURL(hook.url), err)
+			continue
+		}
+		o.log().Infof("Notified webhook %s", redactURL(hook.url))
+	}
+}
+
+// webhookPayload returns the JSON body sent to a webhook of the format
+func webhookPayload(format string, results *v1alpha2.Results) ([]byte, error) {
+	switch format {
+	case slackWebhook:
+		return json.Marshal(struct {
+			Text string `json:"text"`
+		}{Text: slackMessage(results)})
+	default:
+		return json.Marshal(results)
+	}
+}
+
+// slackMessage summarizes the results in Slack message markup
+func slackMessage(results *v1alpha2.Results) string {
+	var b strings.Builder
+	status, icon := "succeeded", ":white_check_mark:"
+	if !results.Success {
+		status, icon = "failed", ":x:"
+	}
+	duration := time.Duration(results.DurationSeconds * float64(time.Second)).Round(time.Second)
+	fmt.Fprintf(&b, "%s *oc-mirror %s %s* in %s\n", icon, results.Operation, status, duration)
+	fmt.Fprintf(&b, "Images: %d", results.Images.Total)
+	if results.Transfer.Blobs != 0 || results.Transfer.Bytes != 0 {
+		fmt.Fprintf(&b, ", transferred %s in %d blobs", units.BytesSize(float64(results.Transfer.Bytes)), results.Transfer.Blobs)
+	}
+	b.WriteString("\n")
+	if results.Sequence != 0 {
+		fmt.Fprintf(&b, "Imageset sequence: %d\n", results.Sequence)
+	}
+	for _, archive := range results.Archives {
+		fmt.Fprintf(&b, "Archive: `%s`\n", archive)
+	}
+	if len(results.Failures) != 0 {
+		fmt.Fprintf(&b, "Failures (%d):\n", len(results.Failures))
+		for i, failure := range results.Failures {
+			if i == maxWebhookFailures {
+				fmt.Fprintf(&b, "• and %d more\n", len(results.Failures)-maxWebhookFailures)
+				break
+			}
+			fmt.Fprintf(&b, "• %s\n", failure.Error)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// postWebhook posts the payload to the URL, retrying
+// on connection errors and server errors
+func (o *MirrorOptions) postWebhook(ctx context.Context, u string, payload []byte) error {
+	var err error
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		var retry bool
+		if retry, err = o.postWebhookOnce(ctx, u, payload); err == nil || !retry {
+			return err
+		}
+		if attempt < webhookAttempts {
+			o.log().Debugf("webhook attempt %d failed, retrying: %v", attempt, err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(webhookBackoff):
+			}
+		}
+	}
+	return err
+}
+
+// postWebhookOnce posts the payload to the URL and
+// returns whether a failed request can be retried
+func (o *MirrorOptions) postWebhookOnce(ctx context.Context, u string, payload []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := o.Clients().HTTPClient().Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Errorf("unexpected status %s", resp.Status)
+		if msg := strings.TrimSpace(string(body)); msg != "" {
+			err = fmt.Errorf("unexpected status %s: %s", resp.Status, msg)
+		}
+		return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, err
+	}
+	return false, nil
+}
+
+// redactURL returns the URL without its path and query,
+// which carry the secret of Slack and most other webhooks
+func redactURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "<invalid URL>"
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
@@ -0,0 +1,179 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+)
+
+func TestParseWebhooks(t *testing.T) {
+	type spec struct {
+		name     string
+		specs    []string
+		exp      []webhook
+		expError string
+	}
+	cases := []spec{
+		{
+			name:  "Valid/DefaultJSON",
+			specs: []string{"https://ci.example.com/hooks/oc-mirror?token=abc"},
+			exp:   []webhook{{format: jsonWebhook, url: "https://ci.example.com/hooks/oc-mirror?token=abc"}},
+		},
+		{
+			name:  "Valid/DetectSlack",
+			specs: []string{"https://hooks.slack.com/services/T000/B000/XXXX"},
+			exp:   []webhook{{format: slackWebhook, url: "https://hooks.slack.com/services/T000/B000/XXXX"}},
+		},
+		{
+			name:  "Valid/ExplicitFormats",
+			specs: []string{"slack=https://chat.example.com/hooks/abc", "json=http://hooks.slack.com/services/T000"},
+			exp: []webhook{
+				{format: slackWebhook, url: "https://chat.example.com/hooks/abc"},
+				{format: jsonWebhook, url: "http://hooks.slack.com/services/T000"},
+			},
+		},
+		{
+			name:     "Invalid/Scheme",
+			specs:    []string{"teams=https://example.com"},
+			expError: `webhook "teams=https://example.com" must be in the form [json=|slack=]http(s)://host/path`,
+		},
+		{
+			name:     "Invalid/NoHost",
+			specs:    []string{"slack=/hooks"},
+			expError: `webhook "slack=/hooks" must be in the form [json=|slack=]http(s)://host/path`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hooks, err := parseWebhooks(c.specs)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, hooks)
+		})
+	}
+}
+
+func TestSlackMessage(t *testing.T) {
+	results := newResults(v1alpha2.OperationMirrorToDisk)
+	results.DurationSeconds = 182.4
+	results.Sequence = 2
+	results.Archives = []string{"/archives/mirror_seq2_000000.tar"}
+	results.Images.Total = 42
+	results.Transfer = v1alpha2.TransferCounts{Bytes: 3 * 1024 * 1024 * 1024, Blobs: 120}
+	for i := 0; i < 7; i++ {
+		results.Failures = append(results.Failures, v1alpha2.Failure{Error: fmt.Sprintf("error %d", i)})
+	}
+
+	exp := ":x: *oc-mirror mirrorToDisk failed* in 3m2s\n" +
+		"Images: 42, transferred 3GiB in 120 blobs\n" +
+		"Imageset sequence: 2\n" +
+		"Archive: `/archives/mirror_seq2_000000.tar`\n" +
+		"Failures (7):\n" +
+		"• error 0\n• error 1\n• error 2\n• error 3\n• error 4\n" +
+		"• and 2 more"
+	require.Equal(t, exp, slackMessage(results))
+
+	results.Success = true
+	results.Failures = nil
+	results.Sequence = 0
+	results.Archives = nil
+	results.Transfer = v1alpha2.TransferCounts{}
+	require.Equal(t, ":white_check_mark: *oc-mirror mirrorToDisk succeeded* in 3m2s\nImages: 42", slackMessage(results))
+}
+
+func TestNotifyWebhooks(t *testing.T) {
+	defer func(backoff time.Duration) { webhookBackoff = backoff }(webhookBackoff)
+	webhookBackoff = time.Millisecond
+
+	var jsonBodies, slackBodies [][]byte
+	var flaky int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		switch r.URL.Path {
+		case "/json":
+			// Fail the first attempt to exercise the retry
+			if flaky++; flaky == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			jsonBodies = append(jsonBodies, body)
+		case "/slack":
+			slackBodies = append(slackBodies, body)
+		default:
+			http.Error(w, "no such hook", http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	opts := &MirrorOptions{
+		Webhooks: []string{
+			server.URL + "/json",
+			"slack=" + server.URL + "/slack",
+			server.URL + "/missing",
+		},
+	}
+	results := newResults(v1alpha2.OperationMirrorToMirror)
+	results.Success = true
+	results.Images.Total = 3
+	opts.notifyWebhooks(context.Background(), results)
+
+	require.Len(t, jsonBodies, 1)
+	var got v1alpha2.Results
+	require.NoError(t, json.Unmarshal(jsonBodies[0], &got))
+	require.Equal(t, v1alpha2.OperationMirrorToMirror, got.Operation)
+	require.True(t, got.Success)
+	require.Equal(t, 3, got.Images.Total)
+
+	require.Len(t, slackBodies, 1)
+	var msg struct {
+		Text string `json:"text"`
+	}
+	require.NoError(t, json.Unmarshal(slackBodies[0], &msg))
+	require.Equal(t, ":white_check_mark: *oc-mirror mirrorToMirror succeeded* in 0s\nImages: 3", msg.Text)
+}
+
+func TestPostWebhook(t *testing.T) {
+	defer func(backoff time.Duration) { webhookBackoff = backoff }(webhookBackoff)
+	webhookBackoff = time.Millisecond
+
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		switch r.URL.Path {
+		case "/unavailable":
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+		default:
+			http.Error(w, "invalid payload", http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	err := NewMirrorOptions().postWebhook(context.Background(), server.URL+"/unavailable", []byte("{}"))
+	require.EqualError(t, err, "unexpected status 503 Service Unavailable: try later")
+	require.Equal(t, webhookAttempts, attempts)
+
+	// Client errors are not retried
+	attempts = 0
+	err = NewMirrorOptions().postWebhook(context.Background(), server.URL+"/invalid", []byte("{}"))
+	require.EqualError(t, err, "unexpected status 400 Bad Request: invalid payload")
+	require.Equal(t, 1, attempts)
+}
+
+func TestRedactURL(t *testing.T) {
+	require.Equal(t, "https://hooks.slack.com", redactURL("https://hooks.slack.com/services/T000/B000/XXXX"))
+}
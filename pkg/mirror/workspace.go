@@ -0,0 +1,125 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	units "github.com/docker/go-units"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
+	"github.com/openshift/oc-mirror/pkg/image/blobcache"
+)
+
+// indexWorkspaceBlobs returns the paths of the blobs
+// in the repositories of the v2 directory by digest
+func indexWorkspaceBlobs(v2Dir string) (map[string]string, error) {
+	blobs := map[string]string{}
+	err := filepath.WalkDir(v2Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() || filepath.Base(filepath.Dir(path)) != config.BlobDir {
+			return nil
+		}
+		if _, err := digest.Parse(d.Name()); err != nil {
+			return nil
+		}
+		if _, found := blobs[d.Name()]; !found {
+			blobs[d.Name()] = path
+		}
+		return nil
+	})
+	return blobs, err
+}
+
+// reuseLocalBlobs links the blobs of the images of the mapping that are
+// already on disk into their destination repositories in the workspace so
+// they are not downloaded again. Blobs are taken from other repositories of
+// the workspace, such as those left by a previous run with --skip-cleanup,
+// and from the blob cache when one is set.
+// Reusing blobs only saves downloads, so errors are logged and the blobs downloaded.
+func (o *MirrorOptions) reuseLocalBlobs(ctx context.Context, cache *blobcache.Cache, mapping image.TypedImageMapping) {
+	v2Dir := filepath.Join(o.Dir, config.SourceDir, config.V2Dir)
+	workspace, err := indexWorkspaceBlobs(v2Dir)
+	if err != nil {
+		o.log().Warnf("unable to read the blobs of the workspace %s: %v", v2Dir, err)
+		workspace = map[string]string{}
+	}
+	if len(workspace) == 0 && cache == nil {
+		return
+	}
+
+	var mu sync.Mutex
+	seen := map[string]struct{}{}
+	var reusedBlobs, cachedBlobs int
+	var reusedBytes, cachedBytes int64
+	err = o.remoteMappingBlobs(ctx, mapping, func(src, dst image.TypedImage, imgBlobs image.ImageBlobs) error {
+		all := image.BlobSizes{}
+		all.Add(imgBlobs)
+		repoDir := filepath.Join(v2Dir, filepath.FromSlash(dst.Ref.AsRepository().String()), config.BlobDir)
+		for dgst, size := range all {
+			dstPath := filepath.Join(repoDir, dgst)
+			// Images of a repository share blobs
+			mu.Lock()
+			_, found := seen[dstPath]
+			seen[dstPath] = struct{}{}
+			mu.Unlock()
+			if found {
+				continue
+			}
+			if _, err := os.Stat(dstPath); err == nil {
+				continue
+			}
+
+			if srcPath, ok := workspace[dgst]; ok {
+				linked, err := o.linkBlobFile(srcPath, dstPath)
+				if err != nil {
+					o.log().Warnf("unable to reuse blob %s of image %s from the workspace: %v", dgst, src.Ref.Exact(), err)
+				} else if linked {
+					mu.Lock()
+					reusedBlobs++
+					reusedBytes += size
+					mu.Unlock()
+					continue
+				}
+			}
+
+			if cache == nil {
+				continue
+			}
+			cachedSize, linked, err := cache.Link(dgst, dstPath)
+			if err != nil {
+				o.log().Warnf("unable to use cached blob %s of image %s: %v", dgst, src.Ref.Exact(), err)
+				continue
+			}
+			if linked {
+				mu.Lock()
+				cachedBlobs++
+				cachedBytes += cachedSize
+				mu.Unlock()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		o.log().Warnf("unable to reuse local blobs: %v", err)
+	}
+
+	o.transfer.reused(reusedBlobs, reusedBytes)
+	if reusedBlobs != 0 {
+		o.log().Infof("Reusing %d blobs (%s) already in the workspace", reusedBlobs, units.BytesSize(float64(reusedBytes)))
+	}
+	if cache != nil {
+		o.transfer.cached(cachedBlobs, cachedBytes)
+		o.log().Infof("Using %d blobs (%s) from the blob cache %s", cachedBlobs, units.BytesSize(float64(cachedBytes)), cache.Dir())
+	}
+}
@@ -0,0 +1,95 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/openshift/oc/pkg/cli/image/imagesource"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+func TestIndexWorkspaceBlobs(t *testing.T) {
+	v2Dir := t.TempDir()
+	layer := "sha256:3e590f0381f73fe7d191499f3347571891501b2fa6cc283f0868268f699b8ab8"
+	for _, path := range []string{
+		filepath.Join("ubi8", "ubi", config.BlobDir, layer),
+		filepath.Join("ubi8", "ubi-minimal", config.BlobDir, layer),
+		filepath.Join("ubi8", "ubi", config.BlobDir, "partial"),
+		filepath.Join("ubi8", "ubi", "manifests", layer),
+	} {
+		path = filepath.Join(v2Dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+		require.NoError(t, ioutil.WriteFile(path, []byte("blob"), 0600))
+	}
+
+	blobs, err := indexWorkspaceBlobs(v2Dir)
+	require.NoError(t, err)
+	require.Len(t, blobs, 1)
+	require.Contains(t, blobs, layer)
+	require.Equal(t, config.BlobDir, filepath.Base(filepath.Dir(blobs[layer])))
+
+	blobs, err = indexWorkspaceBlobs(filepath.Join(v2Dir, "missing"))
+	require.NoError(t, err)
+	require.Empty(t, blobs)
+}
+
+func TestReuseLocalBlobs(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	markInsecure(t, u.Host)
+
+	layer, err := crane.Layer(map[string][]byte{"file": []byte("content")})
+	require.NoError(t, err)
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+	imgDigest, err := img.Digest()
+	require.NoError(t, err)
+	layerDigest, err := layer.Digest()
+	require.NoError(t, err)
+	layerSize, err := layer.Size()
+	require.NoError(t, err)
+	require.NoError(t, crane.Push(img, fmt.Sprintf("%s/ubi8/ubi:latest", u.Host), crane.Insecure))
+
+	o := &MirrorOptions{
+		Dir:             t.TempDir(),
+		SourcePlainHTTP: true,
+		transfer:        &transferStats{},
+	}
+	// A previous run left the layer in another repository of the workspace
+	v2Dir := filepath.Join(o.Dir, config.SourceDir, config.V2Dir)
+	previous := filepath.Join(v2Dir, "ubi8", "ubi-init", config.BlobDir, layerDigest.String())
+	require.NoError(t, os.MkdirAll(filepath.Dir(previous), 0750))
+	require.NoError(t, ioutil.WriteFile(previous, []byte("layer"), 0600))
+
+	src, err := imagesource.ParseReference(fmt.Sprintf("%s/ubi8/ubi@%s", u.Host, imgDigest))
+	require.NoError(t, err)
+	dst, err := imagesource.ParseReference(fmt.Sprintf("file://ubi8/ubi@%s", imgDigest))
+	require.NoError(t, err)
+	mapping := image.TypedImageMapping{}
+	mapping.Add(src, dst, v1alpha2.TypeGeneric)
+
+	o.reuseLocalBlobs(context.Background(), nil, mapping)
+
+	data, err := ioutil.ReadFile(filepath.Join(v2Dir, "ubi8", "ubi", config.BlobDir, layerDigest.String()))
+	require.NoError(t, err)
+	require.Equal(t, "layer", string(data))
+	counts := o.transfer.counts()
+	require.Equal(t, 1, counts.ReusedBlobs)
+	require.Equal(t, layerSize, counts.ReusedBytes)
+}
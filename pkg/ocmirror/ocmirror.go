@@ -0,0 +1,176 @@
+// Package ocmirror runs oc-mirror from Go programs, such as installers and
+// appliances, without executing the oc-mirror binary and parsing its logs.
+//
+// Plan resolves an imageset configuration into the images it mirrors.
+// Create mirrors an imageset to archives on disk, Publish mirrors the
+// archives to a registry, and Mirror mirrors an imageset directly to a
+// registry. Runs return the results written to results.json, and their
+// progress is reported to the handler of WithEventHandler.
+//
+// Each run logs to the output of WithOutput with its own logger and
+// uses its own registry clients, unless WithLogger and WithClients set
+// them. Runs lock their workspace, so runs of a workspace fail while
+// another run of it is in progress. Errors keep the class of the failure,
+// which ExitCode returns as the exit code of the oc-mirror command.
+package ocmirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/exitcode"
+	"github.com/openshift/oc-mirror/pkg/mirror"
+)
+
+// configFile is the name of the imageset configuration
+// written for the run
+const configFile = "imageset-config.yaml"
+
+// Plan resolves the imageset configuration into the images it mirrors,
+// with their digests and sizes, without downloading them
+func Plan(ctx context.Context, cfg v1alpha2.ImageSetConfiguration, opts ...Option) (v1alpha2.ImagePlan, error) {
+	return newOptions(opts).mirrorOptions().Plan(ctx, cfg)
+}
+
+// Create mirrors the imageset configuration to archives in the output
+// directory, which also holds the workspace of the run
+func Create(ctx context.Context, cfg v1alpha2.ImageSetConfiguration, outputDir string, opts ...Option) (*v1alpha2.Results, error) {
+	o := newOptions(opts)
+	if o.workspace != "" {
+		return nil, exitcode.With(exitcode.ConfigError, errors.New("the workspace of Create is in the output directory and cannot be set"))
+	}
+	if err := checkNoScheme(outputDir); err != nil {
+		return nil, err
+	}
+	return run(ctx, o.mirrorOptions(), &cfg, "file://"+outputDir)
+}
+
+// Mirror mirrors the imageset configuration to the registry and namespace
+// of the destination, e.g. registry.example.com:5000/mirror
+func Mirror(ctx context.Context, cfg v1alpha2.ImageSetConfiguration, destination string, opts ...Option) (*v1alpha2.Results, error) {
+	if err := checkNoScheme(destination); err != nil {
+		return nil, err
+	}
+	return run(ctx, newOptions(opts).mirrorOptions(), &cfg, "docker://"+destination)
+}
+
+// Publish mirrors the archives in the directory to the registry
+// and namespace of the destination, e.g. registry.example.com:5000/mirror
+func Publish(ctx context.Context, archivesDir, destination string, opts ...Option) (*v1alpha2.Results, error) {
+	if err := checkNoScheme(destination); err != nil {
+		return nil, err
+	}
+	mo := newOptions(opts).mirrorOptions()
+	mo.From = archivesDir
+	return run(ctx, mo, nil, "docker://"+destination)
+}
+
+// ExitCode returns the exit code the oc-mirror command would
+// have exited with for the error of a run
+func ExitCode(err error) int {
+	return exitcode.Of(err)
+}
+
+// checkNoScheme checks that a destination has no scheme,
+// since the function of the run sets it
+func checkNoScheme(destination string) error {
+	if strings.Contains(destination, "://") {
+		return exitcode.With(exitcode.ConfigError, fmt.Errorf("destination %q must not have a scheme", destination))
+	}
+	return nil
+}
+
+// run runs oc-mirror to the destination with the options
+func run(ctx context.Context, mo *mirror.MirrorOptions, cfg *v1alpha2.ImageSetConfiguration, destination string) (*v1alpha2.Results, error) {
+	if cfg != nil {
+		dir, err := ioutil.TempDir("", "oc-mirror-config")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		if mo.ConfigPath, err = writeConfig(dir, *cfg); err != nil {
+			return nil, err
+		}
+	}
+	if err := mo.CompleteDestination(destination); err != nil {
+		return nil, exitcode.With(exitcode.ConfigError, err)
+	}
+	if err := mo.ConfigureRegistries(); err != nil {
+		return nil, exitcode.With(exitcode.ConfigError, err)
+	}
+	if err := mo.Validate(); err != nil {
+		return nil, exitcode.With(exitcode.ConfigError, err)
+	}
+	// Manifests are not applied, so no cluster client is needed
+	return mo.Execute(ctx, nil)
+}
+
+// newOptions returns the options of a run
+func newOptions(opts []Option) options {
+	o := options{output: ioutil.Discard, architectures: []string{"amd64"}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// mirrorOptions returns the options of the oc-mirror command
+// with their default values and the options of the run
+func (o options) mirrorOptions() *mirror.MirrorOptions {
+	mopts := []mirror.Option{mirror.WithOutput(o.output)}
+	if o.logger != nil {
+		mopts = append(mopts, mirror.WithLogger(o.logger))
+	}
+	if o.clients != nil {
+		mopts = append(mopts, mirror.WithClients(o.clients))
+	}
+	mo := mirror.NewMirrorOptions(mopts...)
+
+	if o.workspace != "" {
+		mo.Dir = o.workspace
+	}
+	mo.SourceSkipTLS = o.sourceSkipTLS
+	mo.SourcePlainHTTP = o.sourcePlainHTTP
+	mo.DestSkipTLS = o.destSkipTLS
+	mo.DestPlainHTTP = o.destPlainHTTP
+	mo.InsecureRegistries = o.insecureRegistries
+	mo.SkipVerification = o.skipVerification
+	mo.SkipMissing = o.skipMissing
+	mo.ContinueOnError = o.continueOnError
+	mo.SkipCleanup = o.skipCleanup
+	mo.IgnoreHistory = o.ignoreHistory
+	mo.DryRun = o.dryRun
+	mo.FilterOptions = o.architectures
+	if o.maxPerRegistry > 0 {
+		mo.MaxPerRegistry = o.maxPerRegistry
+	}
+	if o.maxConcurrentDownloads > 0 {
+		mo.MaxConcurrentDownloads = o.maxConcurrentDownloads
+	}
+	mo.EventHandler = o.eventHandler
+	return mo
+}
+
+// writeConfig writes the imageset configuration to the directory
+// and returns its path
+func writeConfig(dir string, cfg v1alpha2.ImageSetConfiguration) (string, error) {
+	cfg.APIVersion = v1alpha2.GroupVersion.String()
+	cfg.Kind = v1alpha2.ImageSetConfigurationKind
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling imageset configuration: %v", err)
+	}
+	path := filepath.Join(dir, configFile)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
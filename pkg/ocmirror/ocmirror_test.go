@@ -0,0 +1,102 @@
+package ocmirror
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/config"
+	"github.com/openshift/oc-mirror/pkg/exitcode"
+)
+
+func TestMirrorOptions(t *testing.T) {
+	mo := newOptions(nil).mirrorOptions()
+	require.Equal(t, "oc-mirror-workspace", mo.Dir)
+	require.Zero(t, mo.MaxPerRegistry)
+	require.Equal(t, []string{"amd64"}, mo.FilterOptions)
+	require.False(t, mo.ContinueOnError)
+	require.Nil(t, mo.EventHandler)
+
+	out := &bytes.Buffer{}
+	var events []v1alpha2.Event
+	mo = newOptions([]Option{
+		WithWorkspace("/var/lib/oc-mirror"),
+		WithOutput(out),
+		WithDestSkipTLS(),
+		WithInsecureRegistries("localhost:5000"),
+		WithContinueOnError(),
+		WithArchitectures("amd64", "arm64"),
+		WithMaxPerRegistry(6),
+		WithEventHandler(func(e v1alpha2.Event) { events = append(events, e) }),
+	}).mirrorOptions()
+	require.Equal(t, "/var/lib/oc-mirror", mo.Dir)
+	require.Equal(t, out, mo.Out)
+	require.True(t, mo.DestSkipTLS)
+	require.Equal(t, []string{"localhost:5000"}, mo.InsecureRegistries)
+	require.False(t, mo.SourceSkipTLS)
+	require.True(t, mo.ContinueOnError)
+	require.Equal(t, []string{"amd64", "arm64"}, mo.FilterOptions)
+	require.Equal(t, 6, mo.MaxPerRegistry)
+	mo.EventHandler(v1alpha2.Event{Type: v1alpha2.EventRunStart})
+	require.Len(t, events, 1)
+}
+
+func TestWriteConfig(t *testing.T) {
+	cfg := v1alpha2.ImageSetConfiguration{}
+	cfg.Mirror.AdditionalImages = []v1alpha2.Image{{Name: "registry.example.com/ubi8/ubi:latest"}}
+	cfg.StorageConfig.Local = &v1alpha2.LocalConfig{Path: "/metadata"}
+
+	path, err := writeConfig(t.TempDir(), cfg)
+	require.NoError(t, err)
+	got, err := config.ReadConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, cfg.ImageSetConfigurationSpec, got.ImageSetConfigurationSpec)
+}
+
+func TestInvalidRuns(t *testing.T) {
+	ctx := context.Background()
+	cfg := v1alpha2.ImageSetConfiguration{}
+
+	type spec struct {
+		name     string
+		run      func() error
+		expError string
+	}
+	cases := []spec{
+		{
+			name: "Invalid/CreateWithWorkspace",
+			run: func() error {
+				_, err := Create(ctx, cfg, t.TempDir(), WithWorkspace("workspace"))
+				return err
+			},
+			expError: "the workspace of Create is in the output directory and cannot be set",
+		},
+		{
+			name: "Invalid/DestinationWithScheme",
+			run: func() error {
+				_, err := Mirror(ctx, cfg, "docker://registry.example.com/mirror")
+				return err
+			},
+			expError: `destination "docker://registry.example.com/mirror" must not have a scheme`,
+		},
+		{
+			name: "Invalid/DestinationWithTag",
+			run: func() error {
+				_, err := Publish(ctx, t.TempDir(), "registry.example.com/mirror:latest")
+				return err
+			},
+			expError: "destination registry must consist of registry host and namespace(s) only",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			err := c.run()
+			require.EqualError(t, err, c.expError)
+			require.Equal(t, exitcode.ConfigError, ExitCode(err))
+		})
+	}
+}
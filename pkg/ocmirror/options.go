@@ -0,0 +1,147 @@
+package ocmirror
+
+import (
+	"io"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/oc-mirror/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/pkg/image"
+)
+
+// Option configures a run of oc-mirror
+type Option func(*options)
+
+type options struct {
+	workspace              string
+	output                 io.Writer
+	sourceSkipTLS          bool
+	sourcePlainHTTP        bool
+	destSkipTLS            bool
+	destPlainHTTP          bool
+	insecureRegistries     []string
+	skipVerification       bool
+	skipMissing            bool
+	continueOnError        bool
+	skipCleanup            bool
+	ignoreHistory          bool
+	dryRun                 bool
+	architectures          []string
+	maxPerRegistry         int
+	maxConcurrentDownloads int
+	eventHandler           func(v1alpha2.Event)
+	logger                 *logrus.Logger
+	clients                *image.Clients
+}
+
+// WithWorkspace sets the workspace directory of Mirror and Publish runs,
+// which defaults to oc-mirror-workspace in the working directory.
+// Create keeps its workspace in the output directory.
+func WithWorkspace(dir string) Option {
+	return func(o *options) { o.workspace = dir }
+}
+
+// WithOutput writes the output of the image copies to w,
+// which is discarded by default
+func WithOutput(w io.Writer) Option {
+	return func(o *options) { o.output = w }
+}
+
+// WithLogger logs the run with the logger instead of
+// a logger writing to the output of the run
+func WithLogger(logger *logrus.Logger) Option {
+	return func(o *options) { o.logger = logger }
+}
+
+// WithClients uses the registry clients for the run, e.g. to share
+// them between runs. Runs create their own registry clients by default.
+// The clients are configured with the registries of each run.
+func WithClients(clients *image.Clients) Option {
+	return func(o *options) { o.clients = clients }
+}
+
+// WithSourceSkipTLS skips the verification of the TLS
+// certificates of the source registries
+func WithSourceSkipTLS() Option {
+	return func(o *options) { o.sourceSkipTLS = true }
+}
+
+// WithSourcePlainHTTP uses plain HTTP for the source registries
+func WithSourcePlainHTTP() Option {
+	return func(o *options) { o.sourcePlainHTTP = true }
+}
+
+// WithDestSkipTLS skips the verification of the TLS
+// certificates of the destination registry
+func WithDestSkipTLS() Option {
+	return func(o *options) { o.destSkipTLS = true }
+}
+
+// WithDestPlainHTTP uses plain HTTP for the destination registry
+func WithDestPlainHTTP() Option {
+	return func(o *options) { o.destPlainHTTP = true }
+}
+
+// WithInsecureRegistries marks the registries insecure, so the options
+// skipping TLS verification or using plain HTTP apply to them in addition
+// to the registries marked insecure in the configuration
+func WithInsecureRegistries(hosts ...string) Option {
+	return func(o *options) { o.insecureRegistries = append(o.insecureRegistries, hosts...) }
+}
+
+// WithSkipVerification skips the verification of the digests of the images
+func WithSkipVerification() Option {
+	return func(o *options) { o.skipVerification = true }
+}
+
+// WithSkipMissing skips the images of the configuration
+// that are missing from their source registry
+func WithSkipMissing() Option {
+	return func(o *options) { o.skipMissing = true }
+}
+
+// WithContinueOnError skips the images that fail to mirror and lists
+// them in the failures of the results instead of failing the run
+func WithContinueOnError() Option {
+	return func(o *options) { o.continueOnError = true }
+}
+
+// WithSkipCleanup keeps the downloaded images in the workspace after the run
+func WithSkipCleanup() Option {
+	return func(o *options) { o.skipCleanup = true }
+}
+
+// WithIgnoreHistory mirrors all the images of the configuration,
+// ignoring the images mirrored by previous runs
+func WithIgnoreHistory() Option {
+	return func(o *options) { o.ignoreHistory = true }
+}
+
+// WithDryRun plans a Create run and writes its mapping and
+// imageset metadata without downloading image blobs
+func WithDryRun() Option {
+	return func(o *options) { o.dryRun = true }
+}
+
+// WithArchitectures sets the architectures of the releases mirrored,
+// amd64 by default
+func WithArchitectures(architectures ...string) Option {
+	return func(o *options) { o.architectures = architectures }
+}
+
+// WithMaxPerRegistry sets the number of concurrent requests per registry
+func WithMaxPerRegistry(n int) Option {
+	return func(o *options) { o.maxPerRegistry = n }
+}
+
+// WithMaxConcurrentDownloads sets the number of blobs
+// downloaded concurrently by Create
+func WithMaxConcurrentDownloads(n int) Option {
+	return func(o *options) { o.maxConcurrentDownloads = n }
+}
+
+// WithEventHandler calls fn with each event of the run, in order,
+// to track its phases and progress
+func WithEventHandler(fn func(v1alpha2.Event)) Option {
+	return func(o *options) { o.eventHandler = fn }
+}
@@ -0,0 +1,109 @@
+// Package redact scrubs credentials and other secrets from log output.
+package redact
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sync"
+)
+
+// redacted replaces secrets in log output
+const redacted = "REDACTED"
+
+// secretPatterns match the secrets that libraries may include in errors and
+// debug output: authorization headers, URL-embedded credentials, the tokens of
+// registry token responses, and the secret query parameters of signed URLs
+var secretPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{
+		re:   regexp.MustCompile(`(?i)(authorization["']?\s*[:=]\s*[\["']?(?:(?:bearer|basic)\s+)?)[^\s"',\]]+`),
+		repl: "${1}" + redacted,
+	},
+	{
+		// Credentials outside of a header are only recognized by their
+		// length so that prose such as "basic authentication" is kept
+		re:   regexp.MustCompile(`(?i)\b(bearer|basic)(\s+)[A-Za-z0-9\-._~+/]{16,}=*`),
+		repl: "${1}${2}" + redacted,
+	},
+	{
+		re:   regexp.MustCompile(`(?i)\b([a-z][a-z0-9+.\-]*://)[^/\s:@"']+:[^/\s@"']+@`),
+		repl: "${1}" + redacted + "@",
+	},
+	{
+		re:   regexp.MustCompile(`(?i)("(?:access_token|refresh_token|token|identitytoken|registrytoken|password|auth)"\s*:\s*")[^"]*(")`),
+		repl: "${1}" + redacted + "${2}",
+	},
+	{
+		re:   regexp.MustCompile(`(?i)([?&][^=&\s"']*(?:token|signature|sig|credential|secret|password|key|code|auth)[^=&\s"']*=)[^&\s"']+`),
+		repl: "${1}" + redacted,
+	},
+}
+
+// String returns s with the bearer tokens, basic authentication credentials,
+// URL-embedded credentials, and secret URL query parameters it contains redacted
+func String(s string) string {
+	for _, p := range secretPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+// maxRedactLine bounds the output a Writer buffers
+// while waiting for the end of a line
+const maxRedactLine = 64 * 1024
+
+// Writer scrubs secrets from output line by line, so that
+// a secret split across writes is redacted as a whole. Output
+// that does not end a line is buffered until Flush is called.
+type Writer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	pending []byte
+}
+
+// NewWriter returns a writer redacting secrets
+// from each line before writing it to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (r *Writer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending = append(r.pending, p...)
+	n := bytes.LastIndexByte(r.pending, '\n') + 1
+	if n == 0 && len(r.pending) < maxRedactLine {
+		return len(p), nil
+	}
+	if n == 0 {
+		n = len(r.pending)
+	}
+	err := r.write(n)
+	if err != nil {
+		return 0, err
+	}
+	// Report the original length so callers do not treat
+	// a shorter redacted write as a short write
+	return len(p), nil
+}
+
+// Flush writes the buffered output that does not end a line
+func (r *Writer) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.write(len(r.pending))
+}
+
+// write redacts and writes the first n bytes of the pending output
+func (r *Writer) write(n int) error {
+	if n == 0 {
+		return nil
+	}
+	out := String(string(r.pending[:n]))
+	r.pending = append(r.pending[:0], r.pending[n:]...)
+	_, err := io.WriteString(r.w, out)
+	return err
+}
@@ -0,0 +1,180 @@
+// Package schedule parses cron schedules and finds the times they are due.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scheduleMacros are the cron macros and the schedules they stand for
+var scheduleMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames   = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// scheduleField is the range of values of a field of a cron schedule
+type scheduleField struct {
+	name     string
+	min, max int
+	// names are the names of the values starting at min
+	names []string
+}
+
+var scheduleFields = []scheduleField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: monthNames},
+	// Sunday is both 0 and 7
+	{name: "day of week", min: 0, max: 7, names: weekdayNames},
+}
+
+// Schedule is a cron schedule in the five field format of crontab(5)
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny record whether the day fields start with *,
+	// since days match either day field when both are restricted
+	domAny, dowAny bool
+}
+
+// Parse parses a cron schedule of five fields (minute, hour,
+// day of month, month, and day of week) or a macro such as @daily
+func Parse(spec string) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if expanded, ok := scheduleMacros[strings.ToLower(spec)]; ok {
+		spec = expanded
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != len(scheduleFields) {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields (minute hour day-of-month month day-of-week), got %d", spec, len(fields))
+	}
+	bits := make([]uint64, len(fields))
+	for i, field := range fields {
+		var err error
+		if bits[i], err = parseScheduleField(field, scheduleFields[i]); err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %v", spec, err)
+		}
+	}
+	s := &Schedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: strings.HasPrefix(fields[2], "*"),
+		dowAny: strings.HasPrefix(fields[4], "*"),
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// parseScheduleField parses a comma separated list of values, ranges,
+// and stepped ranges into a bit set of the values of the field
+func parseScheduleField(text string, field scheduleField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(text, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i != -1 {
+			var err error
+			rng = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s field", part[i+1:], field.name)
+			}
+		}
+		low, high := field.min, field.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if low, err = parseScheduleValue(bounds[0], field); err != nil {
+				return 0, err
+			}
+			if high, err = parseScheduleValue(bounds[1], field); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range %q in %s field", rng, field.name)
+			}
+		default:
+			var err error
+			if low, err = parseScheduleValue(rng, field); err != nil {
+				return 0, err
+			}
+			// A single value is only extended to the maximum with a step
+			if !strings.Contains(part, "/") {
+				high = low
+			}
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// parseScheduleValue parses a number or name within the range of the field
+func parseScheduleValue(text string, field scheduleField) (int, error) {
+	for i, name := range field.names {
+		if strings.EqualFold(text, name) {
+			return field.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil || v < field.min || v > field.max {
+		return 0, fmt.Errorf("invalid value %q in %s field: must be %d-%d", text, field.name, field.min, field.max)
+	}
+	return v, nil
+}
+
+// dayMatches returns whether the schedule runs on the day of t
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first time after t the schedule runs, in the location of t,
+// or the zero time if the schedule does not run in the next five years
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	limit := t.AddDate(5, 0, 0)
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			// The next hour may be the same wall clock hour when clocks go back
+			if !next.After(t) {
+				next = t.Add(time.Hour)
+			}
+			t = next
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,123 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	type spec struct {
+		name     string
+		spec     string
+		expError string
+	}
+	cases := []spec{
+		{name: "Valid/Weekly", spec: "0 2 * * 6"},
+		{name: "Valid/Macro", spec: "@daily"},
+		{name: "Valid/ListsRangesSteps", spec: "*/15 8-18/2 1,15 jan-jun mon-fri"},
+		{
+			name:     "Invalid/Fields",
+			spec:     "0 2 * *",
+			expError: `invalid schedule "0 2 * *": expected 5 fields (minute hour day-of-month month day-of-week), got 4`,
+		},
+		{
+			name:     "Invalid/Value",
+			spec:     "60 2 * * *",
+			expError: `invalid schedule "60 2 * * *": invalid value "60" in minute field: must be 0-59`,
+		},
+		{
+			name:     "Invalid/Range",
+			spec:     "0 2 * * fri-mon",
+			expError: `invalid schedule "0 2 * * fri-mon": invalid range "fri-mon" in day of week field`,
+		},
+		{
+			name:     "Invalid/Step",
+			spec:     "*/0 * * * *",
+			expError: `invalid schedule "*/0 * * * *": invalid step "0" in minute field`,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Parse(c.spec)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// 2023-01-04 is a Wednesday
+	from := time.Date(2023, 1, 4, 10, 30, 15, 0, time.UTC)
+	type spec struct {
+		name string
+		spec string
+		exp  []time.Time
+	}
+	cases := []spec{
+		{
+			name: "Valid/Weekly",
+			spec: "0 2 * * 6",
+			exp: []time.Time{
+				time.Date(2023, 1, 7, 2, 0, 0, 0, time.UTC),
+				time.Date(2023, 1, 14, 2, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "Valid/Steps",
+			spec: "*/20 10-11 * * *",
+			exp: []time.Time{
+				time.Date(2023, 1, 4, 10, 40, 0, 0, time.UTC),
+				time.Date(2023, 1, 4, 11, 0, 0, 0, time.UTC),
+				time.Date(2023, 1, 4, 11, 20, 0, 0, time.UTC),
+				time.Date(2023, 1, 4, 11, 40, 0, 0, time.UTC),
+				time.Date(2023, 1, 5, 10, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "Valid/DayOfMonthOrWeek",
+			spec: "0 0 15 * sun",
+			exp: []time.Time{
+				time.Date(2023, 1, 8, 0, 0, 0, 0, time.UTC),
+				time.Date(2023, 1, 15, 0, 0, 0, 0, time.UTC),
+				time.Date(2023, 1, 22, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "Valid/SundaySeven",
+			spec: "0 0 * feb 7",
+			exp: []time.Time{
+				time.Date(2023, 2, 5, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "Valid/Yearly",
+			spec: "@yearly",
+			exp: []time.Time{
+				time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "Valid/Never",
+			spec: "0 0 30 feb *",
+			exp:  []time.Time{{}},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			s, err := Parse(c.spec)
+			require.NoError(t, err)
+			next := from
+			for _, exp := range c.exp {
+				next = s.Next(next)
+				require.Equal(t, exp, next)
+			}
+		})
+	}
+}